@@ -0,0 +1,135 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// acceptPacer paces the connections accepted by the proxy to
+// Options.AcceptRate, so that the restart of a fleet of applications opening
+// many connections each doesn't overwhelm the proxy and Spanner with
+// handshakes. Connections waiting to be accepted stay in the accept queue of
+// the listener.
+type acceptPacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// Number of intervals the pacer may fall behind, ie: accept connections
+	// without delay after being idle.
+	burst time.Duration
+	next  time.Time
+}
+
+func newAcceptPacer(opts Options) *acceptPacer {
+	if opts.AcceptRate <= 0 {
+		return nil
+	}
+	interval := time.Duration(float64(time.Second) / opts.AcceptRate)
+	burst := opts.AcceptBurst
+	if burst <= 0 {
+		burst = max(1, int(opts.AcceptRate))
+	}
+	return &acceptPacer{interval: interval, burst: time.Duration(burst) * interval}
+}
+
+// wait blocks until the next connection can be accepted without exceeding
+// AcceptRate. Returns whether the connection was delayed, and false once ctx
+// is done.
+func (p *acceptPacer) wait(ctx context.Context) (delayed bool, ok bool) {
+	if p == nil {
+		return false, true
+	}
+	p.mu.Lock()
+	now := time.Now()
+	if earliest := now.Add(-p.burst); p.next.Before(earliest) {
+		p.next = earliest
+	}
+	p.next = p.next.Add(p.interval)
+	delay := p.next.Sub(now)
+	p.mu.Unlock()
+	if delay <= 0 {
+		return false, true
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true, true
+	case <-ctx.Done():
+		return true, false
+	}
+}
+
+// handshakeLimiter bounds the number of driver connections completing their
+// handshake concurrently to Options.MaxConcurrentHandshakes. Connections
+// wait for a slot before their first request is read.
+type handshakeLimiter struct {
+	slots chan struct{}
+}
+
+func newHandshakeLimiter(opts Options) *handshakeLimiter {
+	if opts.MaxConcurrentHandshakes <= 0 {
+		return nil
+	}
+	return &handshakeLimiter{slots: make(chan struct{}, opts.MaxConcurrentHandshakes)}
+}
+
+// acquire waits for a handshake slot, and returns the function releasing it.
+// Returns false once ctx is done.
+func (l *handshakeLimiter) acquire(
+	ctx context.Context,
+	stats *proxyStats,
+) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		stats.handshakeQueued(1)
+		defer stats.handshakeQueued(-1)
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-l.slots }) }, true
+}
+
+// admitHandshake waits for a handshake slot for the connection, which is
+// released once the connection completes its handshake or is closed.
+// Connections of protocols without handshake are admitted right away.
+func (dc *driverConnection) admitHandshake(
+	ctx context.Context,
+	limiter *handshakeLimiter,
+) bool {
+	if _, ok := dc.protocol.(StreamProtocol); ok {
+		return true
+	}
+	release, ok := limiter.acquire(ctx, dc.adapterClient.stats)
+	if !ok {
+		return false
+	}
+	dc.handshakeMu.Lock()
+	defer dc.handshakeMu.Unlock()
+	dc.releaseHandshake = release
+	return true
+}
@@ -0,0 +1,96 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptPacer(t *testing.T) {
+	assert.Nil(t, newAcceptPacer(Options{}))
+	var disabled *acceptPacer
+	delayed, ok := disabled.wait(context.Background())
+	assert.False(t, delayed)
+	assert.True(t, ok)
+
+	pacer := newAcceptPacer(Options{AcceptRate: 20, AcceptBurst: 2})
+	require.NotNil(t, pacer)
+	assert.Equal(t, 50*time.Millisecond, pacer.interval)
+
+	// The burst is accepted without delay, the next connection is paced.
+	for i := 0; i < 2; i++ {
+		delayed, ok = pacer.wait(context.Background())
+		assert.False(t, delayed)
+		assert.True(t, ok)
+	}
+	start := time.Now()
+	delayed, ok = pacer.wait(context.Background())
+	assert.True(t, delayed)
+	assert.True(t, ok)
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pacer = newAcceptPacer(Options{AcceptRate: 0.001})
+	pacer.wait(ctx)
+	_, ok = pacer.wait(ctx)
+	assert.False(t, ok)
+}
+
+func TestHandshakeLimiter(t *testing.T) {
+	stats := newProxyStats()
+	var disabled *handshakeLimiter
+	_, ok := disabled.acquire(context.Background(), stats)
+	assert.True(t, ok)
+
+	limiter := newHandshakeLimiter(Options{MaxConcurrentHandshakes: 1})
+	release, ok := limiter.acquire(context.Background(), stats)
+	require.True(t, ok)
+
+	acquired := make(chan func())
+	go func() {
+		next, _ := limiter.acquire(context.Background(), stats)
+		acquired <- next
+	}()
+	assert.Eventually(t, func() bool {
+		return stats.snapshot().HandshakeQueueDepth == 1
+	}, time.Second, time.Millisecond)
+
+	// Releasing twice frees a single slot.
+	release()
+	release()
+	next := <-acquired
+	assert.Equal(t, int64(0), stats.snapshot().HandshakeQueueDepth)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok = limiter.acquire(ctx, stats)
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), stats.snapshot().HandshakeQueueDepth)
+	next()
+
+	stats.recordHandshake(20 * time.Millisecond)
+	assert.Equal(t, int64(1), stats.snapshot().HandshakeLatency.Count)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+)
+
+// AttachmentProvider computes custom attachments sent to Spanner along with a
+// QUERY, EXECUTE or BATCH request, ie: a request priority or tag. It is called
+// concurrently by all driver connections and must not modify the frame.
+type AttachmentProvider func(frm *frame.Frame) map[string]string
+
+// optInAttachments are the attachment keys defined by the proxy that change
+// the results or the effects of requests. A backend that does not know one of
+// them serves the request as if it was not set, ie: reads current data or
+// runs a Partitioned DML statement as regular DML, so requests are only sent
+// with the keys listed in Options.SupportedAttachments.
+var optInAttachments = []string{
+	readTimestamp,
+	exactStaleness,
+	partitionedDML,
+	insertMutation,
+	requestPriority,
+	directedReadOptions,
+}
+
+// isReservedAttachment reports whether an attachment key is set by the proxy
+// itself.
+func isReservedAttachment(key string) bool {
+	switch key {
+	case maxCommitDelay, readTimestamp, exactStaleness, partitionedDML,
+		insertMutation, requestPriority, directedReadOptions, returnRowCount:
+		return true
+	}
+	return strings.HasPrefix(key, preparedQueryIdAttachmentPrefix) ||
+		strings.HasPrefix(key, preparedResultStatePrefix)
+}
+
+// tryInsertProvidedAttachments adds the attachments of
+// Options.AttachmentProvider. Returns an error message if the provider returned
+// a reserved key.
+func (re *requestExecutor) tryInsertProvidedAttachments(
+	frame *frame.Frame, attachments map[string]string,
+) message.Message {
+	if re.opts.AttachmentProvider == nil {
+		return nil
+	}
+	provided := re.opts.AttachmentProvider(frame)
+	for key := range provided {
+		if isReservedAttachment(key) {
+			return &message.ServerError{
+				ErrorMessage: fmt.Sprintf(
+					"Attachment %q of the AttachmentProvider is reserved",
+					key,
+				),
+			}
+		}
+	}
+	for key, value := range provided {
+		attachments[key] = value
+	}
+	return nil
+}
+
+// supportsAttachment reports whether the Adapter backend honors the opt-in
+// attachment key, as listed in Options.SupportedAttachments.
+func supportsAttachment(opts *Options, key string) bool {
+	return slices.Contains(opts.SupportedAttachments, key)
+}
+
+// validateSupportedAttachments returns an error if an option relies on an
+// opt-in attachment key missing from Options.SupportedAttachments.
+func validateSupportedAttachments(opts Options) error {
+	required := []struct {
+		option string
+		set    bool
+		key    string
+	}{
+		{"WeakConsistencyStaleness", opts.WeakConsistencyStaleness > 0, exactStaleness},
+		{"ReadYourWrites", opts.ReadYourWrites && opts.WeakConsistencyStaleness > 0, readTimestamp},
+		{"EnablePartitionedDMLFor", len(opts.EnablePartitionedDMLFor) > 0, partitionedDML},
+		{"EnableMutationsFor", len(opts.EnableMutationsFor) > 0, insertMutation},
+		{"FullScanPolicy", opts.FullScanPolicy == FullScanLowPriority, requestPriority},
+		{"DirectedReadOptions", opts.DirectedReadOptions != nil, directedReadOptions},
+	}
+	for _, r := range required {
+		if r.set && !supportsAttachment(&opts, r.key) {
+			return fmt.Errorf(
+				"%s requires an Adapter backend honoring the %q attachment, list it in SupportedAttachments",
+				r.option,
+				r.key,
+			)
+		}
+	}
+	return nil
+}
+
+// tryCheckSupportedAttachments returns an Invalid error message if a request
+// carries an opt-in attachment missing from Options.SupportedAttachments, so
+// that it fails rather than being served as if the attachment was not set.
+func (re *requestExecutor) tryCheckSupportedAttachments(
+	attachments map[string]string,
+) message.Message {
+	for _, key := range optInAttachments {
+		if _, ok := attachments[key]; ok && !supportsAttachment(re.opts, key) {
+			return &message.Invalid{
+				ErrorMessage: fmt.Sprintf(
+					"The Spanner Adapter backend is not known to support the %q attachment the request requires",
+					key,
+				),
+			}
+		}
+	}
+	return nil
+}
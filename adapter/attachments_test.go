@@ -0,0 +1,129 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsReservedAttachment(t *testing.T) {
+	for _, key := range []string{
+		maxCommitDelay,
+		readTimestamp,
+		exactStaleness,
+		partitionedDML,
+		insertMutation,
+		requestPriority,
+		directedReadOptions,
+		returnRowCount,
+		preparedQueryIdAttachmentPrefix + "id",
+		preparedResultStatePrefix + "query",
+	} {
+		assert.True(t, isReservedAttachment(key), key)
+	}
+	assert.False(t, isReservedAttachment("request_tag"))
+}
+
+func TestValidateSupportedAttachments(t *testing.T) {
+	assert.NoError(t, validateSupportedAttachments(Options{}))
+	assert.ErrorContains(t, validateSupportedAttachments(Options{
+		EnableMutationsFor: []string{"ks.events"},
+	}), "EnableMutationsFor")
+	assert.NoError(t, validateSupportedAttachments(Options{
+		EnableMutationsFor:   []string{"ks.events"},
+		SupportedAttachments: []string{insertMutation},
+	}))
+	assert.ErrorContains(t, validateSupportedAttachments(Options{
+		WeakConsistencyStaleness: time.Second,
+		ReadYourWrites:           true,
+		SupportedAttachments:     []string{exactStaleness},
+	}), "ReadYourWrites")
+}
+
+func TestCheckSupportedAttachments(t *testing.T) {
+	re := &requestExecutor{opts: &Options{SupportedAttachments: []string{requestPriority}}}
+	assert.Nil(t, re.tryCheckSupportedAttachments(nil))
+	assert.Nil(t, re.tryCheckSupportedAttachments(map[string]string{
+		requestPriority: lowPriority,
+		maxCommitDelay:  "10",
+		"request_tag":   "tag",
+	}))
+	assert.IsType(t, &message.Invalid{}, re.tryCheckSupportedAttachments(map[string]string{
+		partitionedDML: "true",
+	}))
+}
+
+func TestPrepareProvidedAttachments(t *testing.T) {
+	var provided map[string]string
+	var calls []primitive.OpCode
+	re := &requestExecutor{
+		opts: &Options{
+			AttachmentProvider: func(frm *frame.Frame) map[string]string {
+				calls = append(calls, frm.Header.OpCode)
+				return provided
+			},
+			MaxCommitDelay: 10,
+		},
+	}
+	newFrame := func(msg message.Message) *frame.Frame {
+		return &frame.Frame{
+			Header: &frame.Header{
+				Version: primitive.ProtocolVersion4,
+				OpCode:  msg.GetOpCode(),
+			},
+			Body: &frame.Body{Message: msg},
+		}
+	}
+
+	provided = map[string]string{"request_tag": "checkout"}
+	for _, msg := range []message.Message{
+		&message.Query{Query: "SELECT * FROM t"},
+		&message.Batch{Children: []*message.BatchChild{
+			{Query: "INSERT INTO t (id) VALUES (1)"},
+		}},
+	} {
+		req := &requestState{pb: &adapterpb.AdaptMessageRequest{}}
+		assert.Nil(t, re.prepareCassandraAttachments(newFrame(msg), req))
+		assert.Equal(t, "checkout", req.pb.Attachments["request_tag"])
+	}
+
+	// Prepare requests have no attachments.
+	req := &requestState{pb: &adapterpb.AdaptMessageRequest{}}
+	assert.Nil(t, re.prepareCassandraAttachments(
+		newFrame(&message.Prepare{Query: "SELECT * FROM t"}), req))
+	assert.Equal(t, []primitive.OpCode{primitive.OpCodeQuery, primitive.OpCodeBatch}, calls)
+
+	// Reserved keys fail the request.
+	provided = map[string]string{maxCommitDelay: "0"}
+	req = &requestState{pb: &adapterpb.AdaptMessageRequest{}}
+	assert.IsType(
+		t,
+		&message.ServerError{},
+		re.prepareCassandraAttachments(newFrame(&message.Batch{}), req),
+	)
+	assert.Equal(t, "10", req.pb.Attachments[maxCommitDelay])
+}
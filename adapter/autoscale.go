@@ -0,0 +1,126 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// Interval between two scaling decisions of an autoscaled channel pool.
+	channelScaleInterval = 10 * time.Second
+	// Number of in-flight AdaptMessage calls a single channel is sized for.
+	targetInFlightPerChannel = 50
+	// Number of consecutive oversized intervals after which the pool shrinks by
+	// one channel. Growing is immediate.
+	channelShrinkIntervals = 3
+)
+
+// autoscale periodically resizes the pool until it is closed.
+func (p *channelPool) autoscale(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.scale(context.Background())
+		}
+	}
+}
+
+// desiredSize returns the number of channels needed for peak in-flight calls,
+// within the bounds of the pool.
+func (p *channelPool) desiredSize(peak int64) int {
+	desired := int(
+		(peak + targetInFlightPerChannel - 1) / targetInFlightPerChannel,
+	)
+	return min(max(desired, p.minSize), p.maxSize)
+}
+
+// scale makes a single scaling decision based on the peak number of in-flight
+// calls since the previous decision.
+func (p *channelPool) scale(ctx context.Context) {
+	peak := p.peakInFlight.Swap(p.inFlight.Load())
+	desired := p.desiredSize(peak)
+	current := p.size()
+	switch {
+	case desired > current:
+		p.oversizedIntervals = 0
+		p.grow(ctx, desired-current, peak)
+	case desired < current:
+		p.oversizedIntervals++
+		if p.oversizedIntervals >= channelShrinkIntervals {
+			p.oversizedIntervals = 0
+			p.shrink(peak)
+		}
+	default:
+		p.oversizedIntervals = 0
+	}
+}
+
+// grow dials n additional channels.
+func (p *channelPool) grow(ctx context.Context, n int, peak int64) {
+	for i := 0; i < n; i++ {
+		client, err := p.dial(ctx)
+		if err != nil {
+			logger.Error("Failed to dial additional gRPC channel", zap.Error(err))
+			return
+		}
+		p.mu.Lock()
+		ch := &grpcChannel{
+			id:       len(p.channels),
+			pool:     p,
+			client:   client,
+			dialTime: time.Now(),
+		}
+		p.channels = append(p.channels, ch)
+		p.mu.Unlock()
+		logger.Info("Added gRPC channel to pool",
+			zap.Int("channel_id", ch.id),
+			zap.Int64("peak_in_flight", peak))
+	}
+}
+
+// shrink removes the most recently added channel from the pool. Its connection
+// is closed after a grace period to let in-flight streams finish.
+func (p *channelPool) shrink(peak int64) {
+	p.mu.Lock()
+	if len(p.channels) <= p.minSize {
+		p.mu.Unlock()
+		return
+	}
+	ch := p.channels[len(p.channels)-1]
+	p.channels = p.channels[:len(p.channels)-1]
+	p.mu.Unlock()
+
+	ch.mu.Lock()
+	ch.retired = true
+	client := ch.client
+	ch.mu.Unlock()
+	logger.Info("Removed gRPC channel from pool",
+		zap.Int("channel_id", ch.id),
+		zap.Int64("peak_in_flight", peak))
+	if client != nil {
+		time.AfterFunc(retiredChannelCloseDelay, func() { client.Close() })
+	}
+}
@@ -0,0 +1,275 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// BatchAtomicity controls how the statements of LOGGED or UNLOGGED batches
+// are applied by Spanner.
+type BatchAtomicity int
+
+const (
+	// BatchAtomic applies the statements of a batch in a single Spanner
+	// transaction, atomically across partitions.
+	BatchAtomic BatchAtomicity = iota
+	// BatchPerPartition applies the statements of each partition of a batch
+	// in a transaction of its own, like Cassandra applies UNLOGGED batches:
+	// the partitions are sent concurrently, and the driver is answered once
+	// all of them completed, with the first error if any.
+	BatchPerPartition
+	// BatchSinglePartition rejects batches spanning more than one partition
+	// with an Invalid error, and applies the others atomically.
+	BatchSinglePartition
+)
+
+// ParseBatchAtomicity parses a batch atomicity name (atomic, per-partition or
+// single-partition).
+func ParseBatchAtomicity(atomicity string) (BatchAtomicity, error) {
+	switch strings.ToLower(atomicity) {
+	case "", "atomic":
+		return BatchAtomic, nil
+	case "per-partition":
+		return BatchPerPartition, nil
+	case "single-partition":
+		return BatchSinglePartition, nil
+	default:
+		return BatchAtomic, fmt.Errorf("invalid batch atomicity '%s'", atomicity)
+	}
+}
+
+// batchTypeName returns the CQL keyword of a batch type.
+func batchTypeName(typ primitive.BatchType) string {
+	switch typ {
+	case primitive.BatchTypeLogged:
+		return "LOGGED"
+	case primitive.BatchTypeUnlogged:
+		return "UNLOGGED"
+	default:
+		return "COUNTER"
+	}
+}
+
+// partitionKey is the table of a prepared statement and the indices of the
+// bind variables of its partition key.
+type partitionKey struct {
+	table   string
+	indices []uint16
+}
+
+// partitionKeys remembers the partition keys of the statements of prepared
+// query ids, to find the partitions the statements of batches write to.
+type partitionKeys struct {
+	cache *lru.Cache
+}
+
+func newPartitionKeys(size int) (*partitionKeys, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &partitionKeys{cache: cache}, nil
+}
+
+func (pk *partitionKeys) remember(id []byte, key partitionKey) {
+	if pk != nil {
+		pk.cache.Add(string(id), key)
+	}
+}
+
+func (pk *partitionKeys) lookup(id []byte) (partitionKey, bool) {
+	if pk == nil {
+		return partitionKey{}, false
+	}
+	key, ok := pk.cache.Get(string(id))
+	if !ok {
+		return partitionKey{}, false
+	}
+	return key.(partitionKey), true
+}
+
+// partitionOf returns the table of a statement of a batch and the partition
+// it writes to. Unprepared statements and executions of unknown prepared query
+// ids have no known partition.
+func (pk *partitionKeys) partitionOf(child *message.BatchChild) (string, string, bool) {
+	if child.Query != "" {
+		return "", "", false
+	}
+	key, ok := pk.lookup(child.Id)
+	if !ok {
+		return "", "", false
+	}
+	var partition strings.Builder
+	partition.WriteString(key.table)
+	for _, i := range key.indices {
+		if int(i) >= len(child.Values) {
+			return "", "", false
+		}
+		// Length prefixed so that distinct keys never collide.
+		value := child.Values[i]
+		partition.WriteString("/")
+		if value == nil || value.Contents == nil {
+			partition.WriteString("null")
+			continue
+		}
+		partition.WriteString(strconv.Itoa(len(value.Contents)))
+		partition.WriteString(":")
+		partition.Write(value.Contents)
+	}
+	return key.table, partition.String(), true
+}
+
+// rememberPartitionKey records the partition key of the prepared query id
+// returned by the server for req.
+func (dc *driverConnection) rememberPartitionKey(
+	req *requestState,
+	encoded []byte,
+) {
+	if dc.executor.partitionKeys == nil {
+		return
+	}
+	if _, ok := req.frame.Body.Message.(*message.Prepare); !ok {
+		return
+	}
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return
+	}
+	prepared, ok := frm.Body.Message.(*message.PreparedResult)
+	if !ok || prepared.VariablesMetadata == nil ||
+		len(prepared.VariablesMetadata.PkIndices) == 0 ||
+		len(prepared.VariablesMetadata.Columns) == 0 {
+		return
+	}
+	column := prepared.VariablesMetadata.Columns[0]
+	dc.executor.partitionKeys.remember(prepared.PreparedQueryId, partitionKey{
+		table:   column.Keyspace + "." + column.Table,
+		indices: prepared.VariablesMetadata.PkIndices,
+	})
+}
+
+// batchAtomicity returns the atomicity of batches of the type of batch.
+// COUNTER batches are always applied atomically.
+func (re *requestExecutor) batchAtomicity(batch *message.Batch) BatchAtomicity {
+	switch batch.Type {
+	case primitive.BatchTypeLogged:
+		return re.opts.LoggedBatchAtomicity
+	case primitive.BatchTypeUnlogged:
+		return re.opts.UnloggedBatchAtomicity
+	}
+	return BatchAtomic
+}
+
+// batchPartitions applies Options.LoggedBatchAtomicity and
+// Options.UnloggedBatchAtomicity to batch. Returns the indices of the
+// statements of each partition of batch if it is applied per partition and
+// spans more than one, or an Invalid error message if it spans more than one
+// partition and only single partition batches are accepted. Statements of
+// unknown partitions are not validated, and are applied together.
+func (re *requestExecutor) batchPartitions(batch *message.Batch) ([][]int, message.Message) {
+	atomicity := re.batchAtomicity(batch)
+	if atomicity == BatchAtomic {
+		return nil, nil
+	}
+	var groups [][]int
+	var unknown []int
+	byPartition := make(map[string]int)
+	tables := make(map[string]bool)
+	for i, child := range batch.Children {
+		table, partition, ok := re.partitionKeys.partitionOf(child)
+		if !ok {
+			unknown = append(unknown, i)
+			continue
+		}
+		tables[table] = true
+		group, ok := byPartition[partition]
+		if !ok {
+			group = len(groups)
+			byPartition[partition] = group
+			groups = append(groups, nil)
+		}
+		groups[group] = append(groups[group], i)
+	}
+	if len(groups) > 1 && atomicity == BatchSinglePartition {
+		names := make([]string, 0, len(tables))
+		for table := range tables {
+			names = append(names, table)
+		}
+		sort.Strings(names)
+		typ := batchTypeName(batch.Type)
+		return nil, &message.Invalid{
+			ErrorMessage: fmt.Sprintf(
+				"%s batch spans %d partitions of %s, the proxy only accepts single partition %s batches",
+				typ,
+				len(groups),
+				strings.Join(names, ", "),
+				typ,
+			),
+		}
+	}
+	if atomicity != BatchPerPartition {
+		return nil, nil
+	}
+	if len(unknown) > 0 {
+		groups = append(groups, unknown)
+	}
+	if len(groups) <= 1 {
+		return nil, nil
+	}
+	return groups, nil
+}
+
+// tryCheckBatchAtomicity records the partitions of a batch applied per
+// partition in req, for trySplitBatch to send them separately, or returns an
+// Invalid error message for batches spanning more than one partition where
+// only single partition batches are accepted. Batches committing explicit
+// transactions are always applied atomically.
+func (dc *driverConnection) tryCheckBatchAtomicity(req *requestState) message.Message {
+	batch, ok := req.frame.Body.Message.(*message.Batch)
+	if !ok || req.commit {
+		return nil
+	}
+	groups, errMsg := dc.executor.batchPartitions(batch)
+	if errMsg != nil {
+		return errMsg
+	}
+	req.batchPartitions = groups
+	return nil
+}
+
+// partitionBatch returns a batch of the statements of each group of indices of
+// statements of batch.
+func partitionBatch(batch *message.Batch, groups [][]int) []*message.Batch {
+	parts := make([]*message.Batch, len(groups))
+	for i, group := range groups {
+		part := *batch
+		part.Children = make([]*message.BatchChild, len(group))
+		for j, child := range group {
+			part.Children[j] = batch.Children[child]
+		}
+		parts[i] = &part
+	}
+	return parts
+}
@@ -0,0 +1,108 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchAtomicity(t *testing.T) {
+	for name, want := range map[string]BatchAtomicity{
+		"":                 BatchAtomic,
+		"atomic":           BatchAtomic,
+		"Per-Partition":    BatchPerPartition,
+		"single-partition": BatchSinglePartition,
+	} {
+		got, err := ParseBatchAtomicity(name)
+		require.NoError(t, err)
+		assert.Equal(t, want, got, name)
+	}
+	_, err := ParseBatchAtomicity("logged")
+	assert.ErrorContains(t, err, "invalid batch atomicity")
+}
+
+func TestBatchPartitions(t *testing.T) {
+	keys, err := newPartitionKeys(10)
+	require.NoError(t, err)
+	// INSERT INTO ks.users (name, id) VALUES (?, ?)
+	keys.remember([]byte("users"), partitionKey{table: "ks.users", indices: []uint16{1}})
+	// UPDATE ks.orders SET total = ? WHERE user_id = ? AND id = ?
+	keys.remember([]byte("orders"), partitionKey{table: "ks.orders", indices: []uint16{1}})
+	re := &requestExecutor{
+		opts: &Options{
+			LoggedBatchAtomicity:   BatchSinglePartition,
+			UnloggedBatchAtomicity: BatchPerPartition,
+			MaxBatchStatements:     1,
+		},
+		partitionKeys: keys,
+	}
+	value := func(b byte) *primitive.Value { return primitive.NewValue([]byte{b}) }
+	user := func(id byte) *message.BatchChild {
+		return &message.BatchChild{Id: []byte("users"), Values: []*primitive.Value{value(0), value(id)}}
+	}
+	order := func(userId byte) *message.BatchChild {
+		return &message.BatchChild{
+			Id:     []byte("orders"),
+			Values: []*primitive.Value{value(0), value(userId), value(1)},
+		}
+	}
+	unprepared := &message.BatchChild{Query: "INSERT INTO ks.users (id) VALUES (3)"}
+	batch := func(typ primitive.BatchType, children ...*message.BatchChild) *message.Batch {
+		return &message.Batch{Type: typ, Children: children}
+	}
+
+	// Single partition batches are accepted, whatever their statements.
+	groups, errMsg := re.batchPartitions(batch(primitive.BatchTypeLogged, user(1), user(1), unprepared))
+	assert.Nil(t, errMsg)
+	assert.Nil(t, groups)
+
+	// Equal keys of distinct tables are distinct partitions.
+	_, errMsg = re.batchPartitions(batch(primitive.BatchTypeLogged, user(1), order(1)))
+	require.IsType(t, &message.Invalid{}, errMsg)
+	assert.Equal(t,
+		"LOGGED batch spans 2 partitions of ks.orders, ks.users, the proxy only accepts single partition LOGGED batches",
+		errMsg.(*message.Invalid).ErrorMessage)
+
+	// Unlogged batches are split by partition, unknown partitions last.
+	unlogged := batch(primitive.BatchTypeUnlogged, user(1), unprepared, user(2), user(1), order(1))
+	groups, errMsg = re.batchPartitions(unlogged)
+	assert.Nil(t, errMsg)
+	assert.Equal(t, [][]int{{0, 3}, {2}, {4}, {1}}, groups)
+	parts := partitionBatch(unlogged, groups)
+	require.Len(t, parts, 4)
+	assert.Equal(t, []*message.BatchChild{unlogged.Children[0], unlogged.Children[3]}, parts[0].Children)
+	assert.Equal(t, primitive.BatchTypeUnlogged, parts[0].Type)
+	// Batches applied per partition are not split by MaxBatchStatements.
+	assert.False(t, re.splittable(unlogged))
+
+	groups, errMsg = re.batchPartitions(batch(primitive.BatchTypeUnlogged, user(1), user(1)))
+	assert.Nil(t, errMsg)
+	assert.Nil(t, groups)
+
+	// Counter batches are always applied atomically.
+	groups, errMsg = re.batchPartitions(batch(primitive.BatchTypeCounter, user(1), user(2)))
+	assert.Nil(t, errMsg)
+	assert.Nil(t, groups)
+}
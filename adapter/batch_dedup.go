@@ -0,0 +1,185 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+)
+
+// Default time the responses of batches with an idempotency token are kept.
+const defaultBatchDedupWindow = 10 * time.Minute
+
+// batchToken is the state of the batch of an idempotency token.
+type batchToken struct {
+	key string
+	// Closed once the batch completed.
+	done chan struct{}
+	// Encoded response of the applied batch, nil if it failed. Only set
+	// before done is closed.
+	response []byte
+	// Time the batch was claimed.
+	claimed time.Time
+	// Time after which the response is no longer replayed.
+	expires time.Time
+}
+
+// batchTokens holds the batches of the idempotency tokens sent with
+// IdempotencyTokenPayloadKey, in flight or applied, so that their replays
+// are answered with the response of the batch rather than applied again.
+type batchTokens struct {
+	mu     sync.Mutex
+	cache  *lru.Cache
+	window time.Duration
+
+	// Number of replays answered with the response of an applied batch.
+	duplicates atomic.Int64
+}
+
+func newBatchTokens(opts Options) (*batchTokens, error) {
+	if opts.BatchDedupCacheSize <= 0 {
+		return nil, nil
+	}
+	cache, err := lru.New(opts.BatchDedupCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	window := opts.BatchDedupWindow
+	if window <= 0 {
+		window = defaultBatchDedupWindow
+	}
+	return &batchTokens{cache: cache, window: window}, nil
+}
+
+// claim returns the batch of key, and whether the caller claimed it and must
+// apply the batch and complete it. Batches that failed, whose response
+// expired, or left in flight for longer than the window (ie: as their
+// connection panicked) are claimed again.
+func (bt *batchTokens) claim(key string) (*batchToken, bool) {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	if val, ok := bt.cache.Get(key); ok {
+		token := val.(*batchToken)
+		select {
+		case <-token.done:
+			if token.response != nil && time.Now().Before(token.expires) {
+				return token, false
+			}
+		default:
+			if time.Since(token.claimed) < bt.window {
+				return token, false
+			}
+		}
+	}
+	token := &batchToken{key: key, done: make(chan struct{}), claimed: time.Now()}
+	bt.cache.Add(key, token)
+	return token, true
+}
+
+// complete records the encoded response of the batch of token, nil if it
+// failed, and wakes up its replays.
+func (bt *batchTokens) complete(token *batchToken, response []byte) {
+	if opCode, ok := responseOpCode(response); !ok || opCode == primitive.OpCodeError {
+		response = nil
+	}
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	token.response = response
+	token.expires = time.Now().Add(bt.window)
+	close(token.done)
+	if response == nil {
+		if val, ok := bt.cache.Peek(token.key); ok && val == token {
+			bt.cache.Remove(token.key)
+		}
+	}
+}
+
+// tryServeDuplicateBatch claims the idempotency token of a batch sent with
+// IdempotencyTokenPayloadKey. Replays of a batch in flight wait for it to
+// complete, and replays of an applied batch are answered with its response
+// without being sent to Spanner. Returns false if req must be sent.
+func (dc *driverConnection) tryServeDuplicateBatch(
+	ctx context.Context,
+	req *requestState,
+) bool {
+	bt := dc.executor.batchTokens
+	if bt == nil || req.commit {
+		return false
+	}
+	if _, ok := req.frame.Body.Message.(*message.Batch); !ok {
+		return false
+	}
+	value := req.frame.Body.CustomPayload[IdempotencyTokenPayloadKey]
+	if len(value) == 0 {
+		return false
+	}
+	key := dc.database() + "/" + string(value)
+	for {
+		token, claimed := bt.claim(key)
+		if claimed {
+			req.batchToken = token
+			return false
+		}
+		timer := time.NewTimer(time.Until(token.claimed.Add(bt.window)))
+		select {
+		case <-token.done:
+			timer.Stop()
+		case <-timer.C:
+			// The batch was abandoned, and may be applied by this replay.
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+			return true
+		}
+		if token.response == nil {
+			// The batch failed, and may be applied by this replay.
+			continue
+		}
+		bt.duplicates.Add(1)
+		logger.Debug("Answering replayed batch with the response of its idempotency token",
+			append(dc.requestLogFields(req), zap.ByteString("token", value))...,
+		)
+		response := withStreamId(token.response, req.frame.Header.StreamId)
+		if _, err := dc.driverConn.Write(response); err != nil {
+			logger.Debug("Error writing replayed batch response to connection",
+				zap.Int("connectionID", dc.connectionID),
+				zap.Error(err),
+			)
+		}
+		return true
+	}
+}
+
+// completeBatchToken completes the idempotency token claimed by req, if any,
+// with the encoded response of its batch, nil if it failed. The response is
+// recorded before it is written to the driver, so that the replays of a batch
+// whose response was lost are not applied again.
+func (dc *driverConnection) completeBatchToken(req *requestState, response []byte) {
+	if req.batchToken == nil {
+		return
+	}
+	dc.executor.batchTokens.complete(req.batchToken, response)
+	req.batchToken = nil
+}
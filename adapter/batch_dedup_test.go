@@ -0,0 +1,128 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeBatchWithToken(t *testing.T, conn net.Conn, streamId int16, token string) {
+	t.Helper()
+	frm := frame.NewFrame(primitive.ProtocolVersion4, streamId, &message.Batch{
+		Type: primitive.BatchTypeLogged,
+		Children: []*message.BatchChild{
+			{Query: "INSERT INTO ks.payments (id, amount) VALUES (1, 10)"},
+		},
+		Consistency: primitive.ConsistencyLevelQuorum,
+	})
+	if token != "" {
+		frm.SetCustomPayload(map[string][]byte{IdempotencyTokenPayloadKey: []byte(token)})
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(frm, buf))
+	_, err := conn.Write(buf.Bytes())
+	require.NoError(t, err)
+}
+
+func TestBatchDedup(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{BatchDedupCacheSize: 10})
+	var applied atomic.Int64
+	var fail atomic.Bool
+	MockPayloadAdaptMessageGrpc("cassandra", func(payload []byte) ([]byte, error) {
+		req, err := codec.DecodeFrame(bytes.NewBuffer(payload))
+		if err != nil {
+			return nil, err
+		}
+		var msg message.Message = &message.VoidResult{}
+		if fail.Load() {
+			msg = &message.ServerError{ErrorMessage: "failed"}
+		} else {
+			applied.Add(1)
+		}
+		response := frame.NewFrame(req.Header.Version, req.Header.StreamId, msg)
+		response.Header.IsResponse = true
+		buf := bytes.NewBuffer(nil)
+		if err := codec.EncodeFrame(response, buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	})
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	send := func(streamId int16, token string) message.Message {
+		t.Helper()
+		writeBatchWithToken(t, conn, streamId, token)
+		got, err := codec.DecodeFrame(conn)
+		require.NoError(t, err)
+		assert.Equal(t, streamId, got.Header.StreamId)
+		return got.Body.Message
+	}
+
+	// Replays are answered with the response of the applied batch.
+	assert.IsType(t, &message.VoidResult{}, send(1, "payment-1"))
+	assert.IsType(t, &message.VoidResult{}, send(2, "payment-1"))
+	assert.Equal(t, int64(1), applied.Load())
+	assert.Equal(t, int64(1), proxy.Stats().DuplicateBatches)
+
+	// Other tokens and batches without a token are applied.
+	assert.IsType(t, &message.VoidResult{}, send(3, "payment-2"))
+	assert.IsType(t, &message.VoidResult{}, send(4, ""))
+	assert.IsType(t, &message.VoidResult{}, send(5, ""))
+	assert.Equal(t, int64(4), applied.Load())
+
+	// Failed batches are applied by their next replay.
+	fail.Store(true)
+	assert.IsType(t, &message.ServerError{}, send(6, "payment-3"))
+	fail.Store(false)
+	assert.IsType(t, &message.VoidResult{}, send(7, "payment-3"))
+	assert.Equal(t, int64(5), applied.Load())
+	assert.Equal(t, int64(1), proxy.Stats().DuplicateBatches)
+}
+
+func TestBatchTokensWaitForInFlightBatch(t *testing.T) {
+	bt, err := newBatchTokens(Options{BatchDedupCacheSize: 10})
+	require.NoError(t, err)
+	token, claimed := bt.claim("db/payment")
+	require.True(t, claimed)
+	replay, claimed := bt.claim("db/payment")
+	assert.False(t, claimed)
+	assert.Same(t, token, replay)
+
+	bt.complete(token, encodeResponse(t, &message.VoidResult{}))
+	<-replay.done
+	assert.NotNil(t, replay.response)
+	_, claimed = bt.claim("db/payment")
+	assert.False(t, claimed)
+
+	none, err := newBatchTokens(Options{})
+	require.NoError(t, err)
+	assert.Nil(t, none)
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// Default maximum number of parts of a split batch sent concurrently.
+const defaultBatchSplitParallelism = 4
+
+// splittable reports whether batch is an UNLOGGED batch of plain INSERT
+// statements, ie: a batch of cqlsh COPY FROM, larger than
+// Options.MaxBatchStatements. Cassandra does not apply such batches
+// atomically, and their statements can be applied in any order. Batches
+// applied per partition or in a single partition are not split.
+func (re *requestExecutor) splittable(batch *message.Batch) bool {
+	limit := re.opts.MaxBatchStatements
+	if limit <= 0 || batch.Type != primitive.BatchTypeUnlogged ||
+		len(batch.Children) <= limit || re.batchAtomicity(batch) != BatchAtomic {
+		return false
+	}
+	for _, child := range batch.Children {
+		if child.Query != "" {
+			if _, ok := simpleInsertTable(child.Query); !ok {
+				return false
+			}
+		} else if _, ok := re.mutations.lookup(child.Id); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// splitBatch splits the statements of batch into batches of at most limit
+// statements.
+func splitBatch(batch *message.Batch, limit int) []*message.Batch {
+	var parts []*message.Batch
+	for start := 0; start < len(batch.Children); start += limit {
+		part := *batch
+		part.Children = batch.Children[start:min(start+limit, len(batch.Children))]
+		parts = append(parts, &part)
+	}
+	return parts
+}
+
+// trySplitBatch sends the statements of a batch applied per partition as
+// concurrent batches of the statements of each partition, or those of a
+// splittable batch as concurrent batches of at most
+// Options.MaxBatchStatements statements, and answers the driver once all of
+// them completed, with the first error if any. Returns false, without sending
+// anything, for other requests.
+func (dc *driverConnection) trySplitBatch(ctx context.Context, req *requestState) bool {
+	batch, ok := req.frame.Body.Message.(*message.Batch)
+	if !ok || req.commit {
+		return false
+	}
+	var parts []*message.Batch
+	switch {
+	case len(req.batchPartitions) > 1:
+		parts = partitionBatch(batch, req.batchPartitions)
+	case dc.executor.splittable(batch):
+		parts = splitBatch(batch, dc.executor.opts.MaxBatchStatements)
+	default:
+		return false
+	}
+	parallelism := dc.executor.opts.BatchSplitParallelism
+	if parallelism <= 0 {
+		parallelism = defaultBatchSplitParallelism
+	}
+	payloads := make([][]byte, len(parts))
+	errs := make([]error, len(parts))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			payloads[i], errs[i] = dc.sendBatchPart(ctx, req, part)
+		}()
+	}
+	wg.Wait()
+	dc.recordWrite(req, time.Now())
+
+	response := payloads[len(payloads)-1]
+	for i, err := range errs {
+		if err != nil {
+			logger.Error("Error sending part of a split batch",
+				append(dc.requestLogFields(req), zap.Int("part", i), zap.Error(err))...,
+			)
+			_ = dc.writeRequestError(ctx, req, err)
+			return true
+		}
+		if opCode, ok := responseOpCode(payloads[i]); ok && opCode == primitive.OpCodeError {
+			response = payloads[i]
+			break
+		}
+	}
+	if response == nil {
+		dc.completeBatchToken(req, nil)
+		return true
+	}
+	dc.completeBatchToken(req, response)
+	if _, err := dc.driverConn.Write(response); err != nil {
+		logger.Debug("Error writing merged payload to connection",
+			zap.Int("connectionID", dc.connectionID),
+			zap.Error(err),
+		)
+	}
+	return true
+}
+
+// sendBatchPart sends part of the batch of req, and returns its encoded
+// response.
+func (dc *driverConnection) sendBatchPart(
+	ctx context.Context,
+	req *requestState,
+	part *message.Batch,
+) ([]byte, error) {
+	return dc.sendDerivedRequest(ctx, req, part, true)
+}
+
+// sendDerivedRequest sends msg in place of the message of req, ie: part of its
+// batch or a page of its result, and returns its encoded response.
+func (dc *driverConnection) sendDerivedRequest(
+	ctx context.Context,
+	req *requestState,
+	msg message.Message,
+	enableRouteToLeader bool,
+) ([]byte, error) {
+	frm := req.frame.DeepCopy()
+	frm.Body.Message = msg
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(frm, buf); err != nil {
+		return nil, err
+	}
+	derived := &requestState{
+		pb: &adapterpb.AdaptMessageRequest{
+			Name:        req.pb.Name,
+			Protocol:    req.pb.Protocol,
+			Payload:     buf.Bytes(),
+			Attachments: req.pb.Attachments,
+		},
+		frame:           *frm,
+		received:        req.received,
+		affinityKey:     req.affinityKey,
+		throwOnOverload: req.throwOnOverload,
+		client:          req.client,
+		retryBudget:     req.retryBudget,
+	}
+	start := time.Now()
+	pbCli, ch, err := dc.executor.submit(
+		dc.labelContext(ctx),
+		derived,
+		enableRouteToLeader,
+	)
+	if err != nil {
+		recordCompletion(derived.metrics, err)
+		return nil, err
+	}
+	derived.sent = time.Now()
+	payload, err := dc.receiveGrpcResponse(pbCli, derived)
+	dc.adapterClient.recordResult(ch, time.Since(start), err)
+	recordCompletion(derived.metrics, err)
+	return payload, err
+}
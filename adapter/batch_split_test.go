@@ -0,0 +1,119 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplittable(t *testing.T) {
+	mutations, err := newMutationStatements(10)
+	require.NoError(t, err)
+	mutations.remember([]byte("insert"), "ks.users")
+	re := &requestExecutor{
+		opts:      &Options{MaxBatchStatements: 2},
+		mutations: mutations,
+	}
+	insert := &message.BatchChild{Query: "INSERT INTO ks.users (id) VALUES (?)"}
+	prepared := &message.BatchChild{Id: []byte("insert")}
+	update := &message.BatchChild{Query: "UPDATE ks.users SET name = ? WHERE id = ?"}
+	unknown := &message.BatchChild{Id: []byte("unknown")}
+	batch := func(typ primitive.BatchType, children ...*message.BatchChild) *message.Batch {
+		return &message.Batch{Type: typ, Children: children}
+	}
+
+	testCases := []struct {
+		name  string
+		batch *message.Batch
+		want  bool
+	}{
+		{
+			name:  "Large unlogged batch of inserts",
+			batch: batch(primitive.BatchTypeUnlogged, insert, prepared, insert),
+			want:  true,
+		},
+		{
+			name:  "Small batch",
+			batch: batch(primitive.BatchTypeUnlogged, insert, prepared),
+		},
+		{
+			name:  "Logged batch",
+			batch: batch(primitive.BatchTypeLogged, insert, prepared, insert),
+		},
+		{
+			name:  "Batch with an update",
+			batch: batch(primitive.BatchTypeUnlogged, insert, update, insert),
+		},
+		{
+			name:  "Batch with an unknown prepared statement",
+			batch: batch(primitive.BatchTypeUnlogged, insert, unknown, insert),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, re.splittable(tc.batch))
+		})
+	}
+
+	re.opts.MaxBatchStatements = 0
+	assert.False(t, re.splittable(batch(primitive.BatchTypeUnlogged, insert, insert, insert)))
+}
+
+func TestSplitBatch(t *testing.T) {
+	batch := &message.Batch{
+		Type:        primitive.BatchTypeUnlogged,
+		Consistency: primitive.ConsistencyLevelQuorum,
+	}
+	for i := 0; i < 5; i++ {
+		batch.Children = append(batch.Children, &message.BatchChild{
+			Query:  "INSERT INTO ks.users (id) VALUES (?)",
+			Values: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, byte(i)})},
+		})
+	}
+
+	parts := splitBatch(batch, 2)
+	require.Len(t, parts, 3)
+	var children []*message.BatchChild
+	for i, part := range parts {
+		assert.Equal(t, primitive.BatchTypeUnlogged, part.Type)
+		assert.Equal(t, primitive.ConsistencyLevelQuorum, part.Consistency)
+		assert.LessOrEqual(t, len(part.Children), 2, "part %d", i)
+		children = append(children, part.Children...)
+	}
+	assert.Equal(t, batch.Children, children)
+}
+
+func TestResponseOpCode(t *testing.T) {
+	opCode, ok := responseOpCode(encodeResponse(t, &message.Invalid{ErrorMessage: "invalid"}))
+	require.True(t, ok)
+	assert.Equal(t, primitive.OpCodeError, opCode)
+
+	opCode, ok = responseOpCode(encodeResponse(t, &message.VoidResult{}))
+	require.True(t, ok)
+	assert.Equal(t, primitive.OpCodeResult, opCode)
+
+	_, ok = responseOpCode([]byte{0x84})
+	assert.False(t, ok)
+}
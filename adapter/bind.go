@@ -0,0 +1,147 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// markerName returns the name of the bind marker starting at tokens[i], or
+// an empty string for a positional marker. Unquoted names are case
+// insensitive and returned lower cased.
+func markerName(tokens []cqlToken, i int) string {
+	if !tokens[i].is(":") {
+		return ""
+	}
+	if tokens[i+1].kind == cqlQuotedIdentifier {
+		return tokens[i+1].text
+	}
+	return strings.ToLower(tokens[i+1].text)
+}
+
+// bindMarkerNames returns the names of the bind markers of a statement in
+// order, with empty names for positional markers.
+func bindMarkerNames(query string) []string {
+	tokens := tokenizeCQL(query)
+	var names []string
+	for i := range tokens {
+		if isBindMarker(tokens, i) {
+			names = append(names, markerName(tokens, i))
+		}
+	}
+	return names
+}
+
+// positionalValues returns the named values bound to the bind markers of a
+// statement, in the order of the markers. Markers sharing a name are bound to
+// the same value.
+func positionalValues(
+	names []string,
+	named map[string]*primitive.Value,
+) ([]*primitive.Value, error) {
+	byName := make(map[string]*primitive.Value, len(named))
+	for name, value := range named {
+		byName[strings.ToLower(name)] = value
+	}
+	used := make(map[string]bool, len(names))
+	values := make([]*primitive.Value, len(names))
+	for i, name := range names {
+		if name == "" {
+			return nil, fmt.Errorf("named values can not be bound to positional bind markers")
+		}
+		value, ok := named[name]
+		if !ok {
+			value, ok = byName[strings.ToLower(name)]
+		}
+		if !ok {
+			return nil, fmt.Errorf("no value bound to bind marker :%s", name)
+		}
+		values[i] = value
+		used[strings.ToLower(name)] = true
+	}
+	for name := range named {
+		if !used[strings.ToLower(name)] {
+			return nil, fmt.Errorf("unknown bind marker :%s", name)
+		}
+	}
+	return values, nil
+}
+
+// preparedMarkers remembers the names of the bind markers of the statements of
+// prepared query ids, so that the named values of their executions can be
+// bound by position.
+type preparedMarkers struct {
+	cache *lru.Cache
+}
+
+func newPreparedMarkers(size int) (*preparedMarkers, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &preparedMarkers{cache: cache}, nil
+}
+
+func (pm *preparedMarkers) remember(id []byte, names []string) {
+	if pm != nil {
+		pm.cache.Add(string(id), names)
+	}
+}
+
+func (pm *preparedMarkers) lookup(id []byte) ([]string, bool) {
+	if pm == nil {
+		return nil, false
+	}
+	names, ok := pm.cache.Get(string(id))
+	if !ok {
+		return nil, false
+	}
+	return names.([]string), true
+}
+
+// rememberBindMarkers records the names of the bind markers of the statement
+// of the prepared query id returned by the server for req, if it has named
+// markers.
+func (dc *driverConnection) rememberBindMarkers(
+	req *requestState,
+	encoded []byte,
+) {
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok || !strings.Contains(prepare.Query, ":") {
+		return
+	}
+	// Drivers bind values to the statement they prepared.
+	names := bindMarkerNames(prepare.Query)
+	if req.ttl != nil {
+		names = req.ttl.names
+	}
+	named := false
+	for _, name := range names {
+		named = named || name != ""
+	}
+	if !named {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		dc.executor.markers.remember(id, names)
+	}
+}
@@ -0,0 +1,169 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindMarkerNames(t *testing.T) {
+	assert.Equal(
+		t,
+		[]string{"id", "Data", "id"},
+		bindMarkerNames(`SELECT * FROM t WHERE id = :ID AND data = :"Data" OR id = :id`),
+	)
+	assert.Equal(
+		t,
+		[]string{"", "limit"},
+		bindMarkerNames("SELECT * FROM t WHERE m = {'a': 1} AND id = ? LIMIT :limit"),
+	)
+	assert.Empty(t, bindMarkerNames("INSERT INTO t (id, m) VALUES (1, {'a': 'b'})"))
+	// Markers are recognized after any keyword.
+	assert.Equal(
+		t,
+		[]string{"ids", "v", "k"},
+		bindMarkerNames("SELECT * FROM t WHERE id IN :ids AND s CONTAINS :v AND m CONTAINS KEY :k ALLOW FILTERING"),
+	)
+	assert.Equal(
+		t,
+		[]string{"ttl", "a"},
+		bindMarkerNames("UPDATE t USING TTL :ttl SET u = {f:'x', g:true, h:now()}, v = :a WHERE id = 1"),
+	)
+}
+
+func TestPositionalValues(t *testing.T) {
+	a, b := primitive.NewValue([]byte("a")), primitive.NewValue([]byte("b"))
+
+	values, err := positionalValues(
+		[]string{"a", "b", "a"},
+		map[string]*primitive.Value{"A": a, "b": b},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []*primitive.Value{a, b, a}, values)
+
+	// Quoted names are case sensitive, but drivers may lower case them.
+	values, err = positionalValues(
+		[]string{"Data"},
+		map[string]*primitive.Value{"Data": a},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []*primitive.Value{a}, values)
+
+	for _, tc := range []struct {
+		names []string
+		named map[string]*primitive.Value
+	}{
+		{[]string{"a", "b"}, map[string]*primitive.Value{"a": a}},
+		{[]string{"a"}, map[string]*primitive.Value{"a": a, "c": b}},
+		{[]string{""}, map[string]*primitive.Value{"a": a}},
+	} {
+		_, err := positionalValues(tc.names, tc.named)
+		assert.Error(t, err, tc.names)
+	}
+}
+
+func TestPositionalValuesOfContainsAndIn(t *testing.T) {
+	ids, v := primitive.NewValue([]byte("ids")), primitive.NewValue([]byte("v"))
+	values, err := positionalValues(
+		bindMarkerNames("SELECT * FROM t WHERE k IN :ids AND s CONTAINS :v"),
+		map[string]*primitive.Value{"ids": ids, "v": v},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []*primitive.Value{ids, v}, values)
+}
+
+func TestPreparedMarkers(t *testing.T) {
+	markers, err := newPreparedMarkers(10)
+	require.NoError(t, err)
+	markers.remember([]byte("id"), []string{"a"})
+	names, ok := markers.lookup([]byte("id"))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"a"}, names)
+	_, ok = markers.lookup([]byte("other"))
+	assert.False(t, ok)
+
+	var unset *preparedMarkers
+	unset.remember([]byte("id"), []string{"a"})
+	_, ok = unset.lookup([]byte("id"))
+	assert.False(t, ok)
+}
+
+func TestBindNamedTTLOptions(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	rw, err := rewriteTTL(
+		"INSERT INTO sessions (id, data) VALUES (:id, :data) USING TTL :ttl",
+		testTTLColumns,
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"INSERT INTO sessions (id, data, expires_at) VALUES (:id, :data, :spanner_expiration)",
+		rw.query,
+	)
+	id, data := primitive.NewValue([]byte("id")), primitive.NewValue([]byte("data"))
+
+	options, err := bindTTLOptions(rw, &message.QueryOptions{
+		NamedValues: map[string]*primitive.Value{
+			"data": data,
+			"ttl":  int32Value(60),
+			"id":   id,
+		},
+	}, now)
+	require.NoError(t, err)
+	assert.Empty(t, options.NamedValues)
+	require.Len(t, options.PositionalValues, 3)
+	assert.Equal(t, id, options.PositionalValues[0])
+	assert.Equal(t, data, options.PositionalValues[1])
+	assert.Equal(
+		t,
+		uint64(now.Add(time.Minute).UnixMilli()),
+		binary.BigEndian.Uint64(options.PositionalValues[2].Contents),
+	)
+
+	_, err = bindTTLOptions(rw, &message.QueryOptions{
+		NamedValues: map[string]*primitive.Value{"id": id},
+	}, now)
+	assert.Error(t, err)
+
+	// The TTL variable keeps the name of its marker.
+	column := func(name string, dt datatype.DataType) *message.ColumnMetadata {
+		return &message.ColumnMetadata{Keyspace: "ks", Table: "sessions", Name: name, Type: dt}
+	}
+	got := rw.variables(&message.VariablesMetadata{
+		Columns: []*message.ColumnMetadata{
+			column("id", datatype.Varchar),
+			column("data", datatype.Varchar),
+			column("spanner_expiration", datatype.Timestamp),
+		},
+	})
+	assert.Equal(t, []*message.ColumnMetadata{
+		column("id", datatype.Varchar),
+		column("data", datatype.Varchar),
+		column("ttl", datatype.Int),
+	}, got.Columns)
+}
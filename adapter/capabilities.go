@@ -0,0 +1,106 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// protocolVersions returns the native protocol versions accepted from
+// drivers.
+func protocolVersions() []int {
+	var versions []int
+	for _, v := range primitive.SupportedProtocolVersions() {
+		versions = append(versions, int(v))
+	}
+	return versions
+}
+
+// directPathStatus describes a directPath state of AdapterClient.
+func directPathStatus(state int32) string {
+	switch state {
+	case directPathUsed:
+		return "used"
+	case directPathNotUsed:
+		return "not used"
+	}
+	return "unknown"
+}
+
+// startupFields returns the fields of the summary logged when the proxy
+// starts, so that operators can verify its configuration at a glance.
+func (proxy *TCPProxy) startupFields() []zap.Field {
+	opts := proxy.EffectiveOptions()
+	return []zap.Field{
+		zap.String("tcp_port", opts.TCPEndpoint),
+		zap.String("listen_network", opts.ListenNetwork),
+		zap.Int("listener_shards", max(opts.ListenerShards, 1)),
+		zap.String("database_uri", opts.DatabaseUri),
+		zap.String("spanner_endpoint", opts.SpannerEndpoint),
+		zap.Strings("failover_endpoints", opts.FailoverEndpoints),
+		zap.Strings("candidate_endpoints", opts.CandidateEndpoints),
+		zap.Bool("direct_access", directAccessEnabled(opts)),
+		zap.String("grpc_dial_network", opts.GrpcDialNetwork),
+		zap.String("grpc_compression", opts.GrpcCompression),
+		zap.Int("grpc_channels", proxy.client.channels.size()),
+		zap.Int("min_grpc_channels", opts.MinGrpcChannels),
+		zap.Int("max_grpc_channels", opts.MaxGrpcChannels),
+		zap.Int("read_channels", opts.ReadChannels),
+		zap.Int("write_channels", opts.WriteChannels),
+		zap.Bool("channel_affinity", opts.ChannelAffinity),
+		zap.Ints("protocol_versions", protocolVersions()),
+		zap.Bool("adapt_message_retry", !opts.DisableAdaptMessageRetry),
+		zap.Duration("retry_initial_backoff", opts.RetryBackoff.Initial),
+		zap.Duration("retry_max_backoff", opts.RetryBackoff.Max),
+		zap.Float64("retry_budget_ratio", opts.RetryBudgetRatio),
+		zap.Int("max_transaction_retries", opts.MaxTransactionRetries),
+		zap.Bool("low_priority_full_scans", opts.FullScanPolicy == FullScanLowPriority),
+		zap.Bool("statement_policy", opts.StatementPolicy != nil),
+		zap.Bool("strict_consistency", opts.StrictConsistency),
+		zap.Duration("weak_consistency_staleness", opts.WeakConsistencyStaleness),
+		zap.Bool("read_your_writes", opts.ReadYourWrites),
+		zap.Int("read_cache_tables", len(opts.ReadCacheTTLs)),
+		zap.Int("mutation_tables", len(opts.EnableMutationsFor)),
+		zap.Int("max_connections", opts.MaxConnections),
+		zap.Int("max_frame_size", opts.MaxFrameSize),
+		zap.Int("max_request_size", opts.MaxRequestSize),
+		zap.Int("max_response_size", opts.MaxResponseSize),
+		zap.Int("prepared_cache_size", opts.PreparedCacheSize),
+		zap.Bool("builtin_metrics", opts.EnableBuiltInMetrics),
+		zap.Bool("cqlsh_compatibility", opts.CqlshCompatibility),
+	}
+}
+
+// logFirstHandshake logs, once per client, the capabilities negotiated by the
+// first driver connection completing its handshake: its protocol version,
+// and whether Spanner is reached over DirectPath.
+func (dc *driverConnection) logFirstHandshake(version primitive.ProtocolVersion) {
+	client := dc.adapterClient
+	if !client.firstHandshake.CompareAndSwap(false, true) {
+		return
+	}
+	logger.Info("Spanner proxy completed the handshake of its first connection",
+		zap.Int("connectionID", dc.connectionID),
+		zap.String("remote_addr", dc.driverConn.RemoteAddr().String()),
+		zap.String("database_uri", client.opts.DatabaseUri),
+		zap.Int("protocol_version", int(version)),
+		zap.String("direct_path", directPathStatus(client.directPath.Load())),
+		zap.Int("grpc_channels", client.channels.size()),
+	)
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+)
+
+const defaultCaptureSampleRate = 0.01
+
+// CaptureRecord is a request recorded to Options.CaptureFile, which holds one
+// JSON encoded record per line. A record holds a single statement, or the
+// statements of a batch.
+type CaptureRecord struct {
+	// Time the request was received.
+	Time time.Time `json:"time"`
+	// Keyspace of the connection, set by USE statements.
+	Keyspace string `json:"keyspace,omitempty"`
+	// Consistency level code of the request, ie: 6 for LOCAL_QUORUM.
+	Consistency uint16 `json:"consistency"`
+	// Batch type code of batches, ie: 0 for LOGGED, nil otherwise.
+	BatchType *uint8 `json:"batch_type,omitempty"`
+	// Statements of the request.
+	Statements []CapturedStatement `json:"statements"`
+}
+
+// CapturedStatement is a statement of a CaptureRecord along with its bound
+// values.
+type CapturedStatement struct {
+	Query string `json:"query"`
+	// Bound values in the encoding of the CQL type of their bind marker, nil
+	// for null values.
+	Values [][]byte `json:"values,omitempty"`
+	// Names of the bind markers of Values, if bound by name.
+	Names []string `json:"names,omitempty"`
+	// Indexes of the unset values of Values.
+	Unset []int `json:"unset,omitempty"`
+}
+
+// requestCapture records a sample of the requests to Options.CaptureFile.
+type requestCapture struct {
+	rate float64
+	// Statements of prepared query ids, to record the statements of EXECUTE
+	// requests.
+	statements *lru.Cache
+
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+func newRequestCapture(opts Options) (*requestCapture, error) {
+	rate := opts.CaptureSampleRate
+	if rate <= 0 {
+		rate = defaultCaptureSampleRate
+	}
+	if rate > 1 {
+		return nil, fmt.Errorf("invalid capture sample rate %v, expected at most 1", rate)
+	}
+	statements, err := lru.New(opts.PreparedCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(
+		opts.CaptureFile,
+		os.O_CREATE|os.O_WRONLY|os.O_APPEND,
+		0o600,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture file: %w", err)
+	}
+	return &requestCapture{
+		rate:       rate,
+		statements: statements,
+		file:       file,
+		enc:        json.NewEncoder(file),
+	}, nil
+}
+
+func (rc *requestCapture) close() {
+	if rc == nil {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	_ = rc.file.Close()
+}
+
+// record returns the record of a QUERY, EXECUTE or BATCH request, and false
+// for other requests and for the executions of statements prepared before
+// the proxy started.
+func (rc *requestCapture) record(
+	frm *frame.Frame,
+	keyspace string,
+	received time.Time,
+) (*CaptureRecord, bool) {
+	rec := &CaptureRecord{Time: received, Keyspace: keyspace}
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		rec.Statements = []CapturedStatement{capturedStatement(msg.Query, msg.Options)}
+		rec.Consistency = capturedConsistency(msg.Options)
+	case *message.Execute:
+		query, ok := rc.statements.Get(string(msg.QueryId))
+		if !ok {
+			return nil, false
+		}
+		rec.Statements = []CapturedStatement{capturedStatement(query.(string), msg.Options)}
+		rec.Consistency = capturedConsistency(msg.Options)
+	case *message.Batch:
+		for _, child := range msg.Children {
+			query := child.Query
+			if query == "" {
+				stmt, ok := rc.statements.Get(string(child.Id))
+				if !ok {
+					return nil, false
+				}
+				query = stmt.(string)
+			}
+			rec.Statements = append(
+				rec.Statements,
+				capturedStatement(query, &message.QueryOptions{PositionalValues: child.Values}),
+			)
+		}
+		batchType := uint8(msg.Type)
+		rec.BatchType = &batchType
+		rec.Consistency = uint16(msg.Consistency)
+	default:
+		return nil, false
+	}
+	return rec, true
+}
+
+// capturedConsistency returns the consistency level of a captured statement,
+// ONE if it has no options.
+func capturedConsistency(options *message.QueryOptions) uint16 {
+	if options == nil {
+		return uint16(primitive.ConsistencyLevelOne)
+	}
+	return uint16(options.Consistency)
+}
+
+func capturedStatement(query string, options *message.QueryOptions) CapturedStatement {
+	stmt := CapturedStatement{Query: query}
+	if options == nil {
+		return stmt
+	}
+	add := func(value *primitive.Value) {
+		if value != nil && value.Type == primitive.ValueTypeUnset {
+			stmt.Unset = append(stmt.Unset, len(stmt.Values))
+		}
+		if value == nil || value.Type != primitive.ValueTypeRegular {
+			stmt.Values = append(stmt.Values, nil)
+			return
+		}
+		stmt.Values = append(stmt.Values, value.Contents)
+	}
+	for _, value := range options.PositionalValues {
+		add(value)
+	}
+	for name, value := range options.NamedValues {
+		stmt.Names = append(stmt.Names, name)
+		add(value)
+	}
+	return stmt
+}
+
+// captureRequest records the request of frm to Options.CaptureFile with a
+// probability of Options.CaptureSampleRate. Requests are recorded as sent by
+// the driver, before any translation by the proxy.
+func (dc *driverConnection) captureRequest(frm *frame.Frame, received time.Time) {
+	rc := dc.executor.capture
+	if rc == nil || rand.Float64() >= rc.rate {
+		return
+	}
+	rec, ok := rc.record(frm, dc.keyspace, received)
+	if !ok {
+		return
+	}
+	rc.mu.Lock()
+	err := rc.enc.Encode(rec)
+	rc.mu.Unlock()
+	if err != nil {
+		logger.Error("Error writing captured request",
+			zap.Int("connectionID", dc.connectionID),
+			zap.Error(err))
+	}
+}
+
+// rememberCapturedStatement records the statement of the prepared query id
+// returned by the server for req, to capture its executions.
+func (dc *driverConnection) rememberCapturedStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	if dc.executor.capture == nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		dc.executor.capture.statements.Add(string(id), prepare.Query)
+	}
+}
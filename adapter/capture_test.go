@@ -0,0 +1,135 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	_, err := newRequestCapture(Options{
+		CaptureFile:       path,
+		CaptureSampleRate: 2,
+		PreparedCacheSize: 10,
+	})
+	assert.Error(t, err)
+	rc, err := newRequestCapture(Options{
+		CaptureFile:       path,
+		CaptureSampleRate: 1,
+		PreparedCacheSize: 10,
+	})
+	require.NoError(t, err)
+	dc := &driverConnection{
+		executor: &requestExecutor{capture: rc},
+		codec:    codec,
+		keyspace: "ks",
+	}
+	newRequest := func(msg message.Message) *requestState {
+		return &requestState{frame: *frame.NewFrame(primitive.ProtocolVersion4, 1, msg)}
+	}
+	received := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// Executions of statements prepared before are not captured.
+	execute := newRequest(&message.Execute{
+		QueryId: []byte("id"),
+		Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelLocalQuorum,
+			PositionalValues: []*primitive.Value{
+				primitive.NewValue(encodeInt(1)),
+				primitive.NewNullValue(),
+				primitive.NewUnsetValue(),
+			},
+		},
+	})
+	dc.captureRequest(&execute.frame, received)
+
+	prepared := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.PreparedResult{
+		PreparedQueryId:   []byte("id"),
+		VariablesMetadata: &message.VariablesMetadata{},
+		ResultMetadata:    &message.RowsMetadata{},
+	})
+	prepared.Header.IsResponse = true
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(prepared, buf))
+	dc.rememberCapturedStatement(
+		newRequest(&message.Prepare{Query: "INSERT INTO users (id, a, b) VALUES (?, ?, ?)"}),
+		buf.Bytes(),
+	)
+	dc.captureRequest(&execute.frame, received)
+
+	batch := newRequest(&message.Batch{
+		Type: primitive.BatchTypeUnlogged,
+		Children: []*message.BatchChild{
+			{Query: "DELETE FROM users WHERE id = 2"},
+			{Id: []byte("id"), Values: []*primitive.Value{primitive.NewValue(encodeInt(3))}},
+		},
+		Consistency: primitive.ConsistencyLevelQuorum,
+	})
+	dc.captureRequest(&batch.frame, received)
+	// Other requests are not captured.
+	dc.captureRequest(&newRequest(&message.Options{}).frame, received)
+	rc.close()
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	var records []CaptureRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec CaptureRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.Len(t, records, 2)
+
+	assert.True(t, received.Equal(records[0].Time))
+	assert.Equal(t, "ks", records[0].Keyspace)
+	assert.Equal(t, uint16(primitive.ConsistencyLevelLocalQuorum), records[0].Consistency)
+	assert.Nil(t, records[0].BatchType)
+	assert.Equal(t, []CapturedStatement{{
+		Query:  "INSERT INTO users (id, a, b) VALUES (?, ?, ?)",
+		Values: [][]byte{encodeInt(1), nil, nil},
+		Unset:  []int{2},
+	}}, records[0].Statements)
+
+	require.NotNil(t, records[1].BatchType)
+	assert.Equal(t, uint8(primitive.BatchTypeUnlogged), *records[1].BatchType)
+	assert.Equal(t, uint16(primitive.ConsistencyLevelQuorum), records[1].Consistency)
+	assert.Equal(t, []CapturedStatement{
+		{Query: "DELETE FROM users WHERE id = 2"},
+		{
+			Query:  "INSERT INTO users (id, a, b) VALUES (?, ?, ?)",
+			Values: [][]byte{encodeInt(3)},
+		},
+	}, records[1].Statements)
+}
@@ -0,0 +1,702 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"google.golang.org/api/option"
+)
+
+const (
+	// Keyspace of the tables serving the change streams of the database.
+	changeStreamKeyspace = "cdc"
+	// Interval of the heartbeat records of change stream queries, which the
+	// proxy ignores.
+	changeStreamHeartbeat = 10 * time.Second
+	// Default maximum time spent reading a page of a change stream query,
+	// below the default request timeout of gocql.
+	defaultChangeStreamReadTimeout = 10 * time.Second
+)
+
+var (
+	changeStreamSelectPattern = regexp.MustCompile(
+		`(?is)^\s*select\s+(.+?)\s+from\s+"?cdc"?\s*\.\s*"?(\w+)"?\s+where\s+(.+?)\s*;?\s*$`,
+	)
+	changeStreamAndPattern       = regexp.MustCompile(`(?i)\s+and\s+`)
+	changeStreamConditionPattern = regexp.MustCompile(
+		`(?is)^"?(\w+)"?\s*=\s*(\?|'[^']*'|\d+)$`,
+	)
+	// Layouts of the timestamp literals of change stream queries.
+	changeStreamTimeLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999-0700",
+		"2006-01-02T15:04:05.999999999-0700",
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02",
+	}
+)
+
+// changeStreamColumns are the columns of the rows of change stream queries,
+// one row per modification of a data change record.
+var changeStreamColumns = []columnDef{
+	{"partition_token", datatype.Varchar},
+	{"commit_timestamp", datatype.Timestamp},
+	{"record_sequence", datatype.Varchar},
+	{"server_transaction_id", datatype.Varchar},
+	{"table_name", datatype.Varchar},
+	{"mod_type", datatype.Varchar},
+	{"keys", datatype.Varchar},
+	{"new_values", datatype.Varchar},
+	{"old_values", datatype.Varchar},
+}
+
+// changeRecord is a modification of a row read from a Spanner change stream.
+// Keys, NewValues and OldValues are JSON objects keyed by column name.
+type changeRecord struct {
+	PartitionToken      string
+	CommitTimestamp     time.Time
+	RecordSequence      string
+	ServerTransactionID string
+	TableName           string
+	ModType             string
+	Keys                string
+	NewValues           string
+	OldValues           string
+	// Index of the modification in its data change record.
+	ModIndex int
+}
+
+// before reports whether r is ordered before other in the results of change
+// stream queries: by commit timestamp, then by transaction, record sequence
+// and modification.
+func (r *changeRecord) before(other *changeRecord) bool {
+	if !r.CommitTimestamp.Equal(other.CommitTimestamp) {
+		return r.CommitTimestamp.Before(other.CommitTimestamp)
+	}
+	if r.ServerTransactionID != other.ServerTransactionID {
+		return r.ServerTransactionID < other.ServerTransactionID
+	}
+	if r.RecordSequence != other.RecordSequence {
+		return r.RecordSequence < other.RecordSequence
+	}
+	return r.ModIndex < other.ModIndex
+}
+
+// changeRecordPage collects the first limit records ordered after the last
+// record of the previous page. Records are added in any order, and at most
+// twice limit records are held at once.
+type changeRecordPage struct {
+	// Last record of the previous page, or nil for the first page.
+	after *changeRecord
+	// Maximum number of records of the page, or 0 for no limit.
+	limit   int
+	records []changeRecord
+	// Last record of the page once records were dropped past the limit.
+	cutoff *changeRecord
+}
+
+func (p *changeRecordPage) add(r changeRecord) {
+	if p.after != nil && !p.after.before(&r) {
+		return
+	}
+	if p.cutoff != nil && !r.before(p.cutoff) {
+		return
+	}
+	p.records = append(p.records, r)
+	if p.limit > 0 && len(p.records) >= 2*p.limit {
+		p.truncate()
+	}
+}
+
+// truncate orders the records and drops the records past the limit.
+func (p *changeRecordPage) truncate() {
+	sort.Slice(p.records, func(i, j int) bool {
+		return p.records[i].before(&p.records[j])
+	})
+	if p.limit > 0 && len(p.records) > p.limit {
+		p.records = p.records[:p.limit]
+		cutoff := p.records[p.limit-1]
+		p.cutoff = &cutoff
+	}
+}
+
+// result returns the ordered records of the page, and whether records were
+// dropped past its limit.
+func (p *changeRecordPage) result() ([]changeRecord, bool) {
+	p.truncate()
+	return p.records, p.cutoff != nil
+}
+
+// The STRUCTs returned by change stream queries, decoded leniently so that
+// fields added to them do not break decoding.
+type changeStreamRow struct {
+	ChangeRecord []*changeStreamRecord `spanner:"ChangeRecord"`
+}
+
+type changeStreamRecord struct {
+	DataChangeRecord      []*dataChangeRecord      `spanner:"data_change_record"`
+	ChildPartitionsRecord []*childPartitionsRecord `spanner:"child_partitions_record"`
+}
+
+type dataChangeRecord struct {
+	CommitTimestamp     time.Time        `spanner:"commit_timestamp"`
+	RecordSequence      string           `spanner:"record_sequence"`
+	ServerTransactionID string           `spanner:"server_transaction_id"`
+	TableName           string           `spanner:"table_name"`
+	ModType             string           `spanner:"mod_type"`
+	Mods                []*dataChangeMod `spanner:"mods"`
+}
+
+type dataChangeMod struct {
+	Keys      spanner.NullJSON `spanner:"keys"`
+	NewValues spanner.NullJSON `spanner:"new_values"`
+	OldValues spanner.NullJSON `spanner:"old_values"`
+}
+
+type childPartitionsRecord struct {
+	StartTimestamp  time.Time         `spanner:"start_timestamp"`
+	ChildPartitions []*childPartition `spanner:"child_partitions"`
+}
+
+type childPartition struct {
+	Token string `spanner:"token"`
+}
+
+// changeStreamPartition is a partition of a change stream to read from its
+// start timestamp. The initial partition has an empty token.
+type changeStreamPartition struct {
+	token string
+	start time.Time
+}
+
+// changeStreamReader reads the change streams of the databases served by a
+// proxy with Options.EnableChangeStreams, with a Spanner client per database
+// created on first use.
+type changeStreamReader struct {
+	clientOpts []option.ClientOption
+	// Maximum time spent reading a page of a change stream query.
+	timeout time.Duration
+	// Adds the records of a change stream to a page, replaced in tests.
+	read func(
+		ctx context.Context,
+		databaseUri, stream string,
+		start, end time.Time,
+		page *changeRecordPage,
+	) error
+
+	mu      sync.Mutex
+	clients map[string]*spanner.Client
+}
+
+func newChangeStreamReader(opts Options) (*changeStreamReader, error) {
+	clientOpts, err := getAllClientOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	r := &changeStreamReader{
+		clientOpts: clientOpts,
+		timeout:    opts.ChangeStreamReadTimeout,
+		clients:    make(map[string]*spanner.Client),
+	}
+	if r.timeout <= 0 {
+		r.timeout = defaultChangeStreamReadTimeout
+	}
+	r.read = r.readRecords
+	return r, nil
+}
+
+// client returns the Spanner client of a database.
+func (r *changeStreamReader) client(
+	ctx context.Context,
+	databaseUri string,
+) (*spanner.Client, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.clients[databaseUri]; ok {
+		return client, nil
+	}
+	client, err := spanner.NewClientWithConfig(
+		ctx,
+		databaseUri,
+		spanner.ClientConfig{},
+		r.clientOpts...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.clients[databaseUri] = client
+	return client, nil
+}
+
+// close closes the Spanner clients of the reader. No-op on a nil reader.
+func (r *changeStreamReader) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for databaseUri, client := range r.clients {
+		client.Close()
+		delete(r.clients, databaseUri)
+	}
+}
+
+// readRecords adds the data change records of stream committed in
+// [start, end) to page, following the child partitions of every partition
+// read.
+func (r *changeStreamReader) readRecords(
+	ctx context.Context,
+	databaseUri, stream string,
+	start, end time.Time,
+	page *changeRecordPage,
+) error {
+	client, err := r.client(ctx, databaseUri)
+	if err != nil {
+		return err
+	}
+	pending := []changeStreamPartition{{start: start}}
+	seen := make(map[string]bool)
+	for len(pending) > 0 {
+		partition := pending[0]
+		pending = pending[1:]
+		children, err := readChangeStreamPartition(
+			ctx, client, stream, partition, end, page,
+		)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if !seen[child.token] {
+				seen[child.token] = true
+				pending = append(pending, child)
+			}
+		}
+	}
+	return nil
+}
+
+// readChangeStreamPartition adds the data change records of a partition to
+// page, and returns its child partitions.
+func readChangeStreamPartition(
+	ctx context.Context,
+	client *spanner.Client,
+	stream string,
+	partition changeStreamPartition,
+	end time.Time,
+	page *changeRecordPage,
+) ([]changeStreamPartition, error) {
+	stmt := spanner.Statement{
+		SQL: fmt.Sprintf(
+			`SELECT ChangeRecord FROM READ_%s(
+				start_timestamp => @start,
+				end_timestamp => @end,
+				partition_token => @token,
+				heartbeat_milliseconds => @heartbeat)`,
+			stream,
+		),
+		Params: map[string]interface{}{
+			"start":     partition.start,
+			"end":       end,
+			"token":     spanner.NullString{StringVal: partition.token, Valid: partition.token != ""},
+			"heartbeat": changeStreamHeartbeat.Milliseconds(),
+		},
+	}
+	var children []changeStreamPartition
+	err := client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var decoded changeStreamRow
+		if err := row.ToStructLenient(&decoded); err != nil {
+			return err
+		}
+		for _, record := range decoded.ChangeRecord {
+			for _, data := range record.DataChangeRecord {
+				for i, mod := range data.Mods {
+					page.add(changeRecord{
+						PartitionToken:      partition.token,
+						CommitTimestamp:     data.CommitTimestamp,
+						RecordSequence:      data.RecordSequence,
+						ServerTransactionID: data.ServerTransactionID,
+						TableName:           data.TableName,
+						ModType:             data.ModType,
+						Keys:                nullJSONString(mod.Keys),
+						NewValues:           nullJSONString(mod.NewValues),
+						OldValues:           nullJSONString(mod.OldValues),
+						ModIndex:            i,
+					})
+				}
+			}
+			for _, child := range record.ChildPartitionsRecord {
+				for _, p := range child.ChildPartitions {
+					children = append(children, changeStreamPartition{
+						token: p.Token,
+						start: child.StartTimestamp,
+					})
+				}
+			}
+		}
+		return nil
+	})
+	return children, err
+}
+
+func nullJSONString(v spanner.NullJSON) string {
+	if !v.Valid {
+		return ""
+	}
+	return v.String()
+}
+
+// changeStreamQuery is a SELECT query on a change stream:
+// SELECT <columns> FROM cdc.<stream> WHERE start_time = <timestamp>
+// [AND end_time = <timestamp>]. Timestamps are either literals or bind
+// markers.
+type changeStreamQuery struct {
+	stream     string
+	projection []int
+	start, end time.Time
+	// Names of the conditions given by bind markers, in order.
+	markers []string
+}
+
+// parseChangeStreamQuery parses a query on the cdc keyspace. Returns nil if
+// query does not select from the cdc keyspace.
+func parseChangeStreamQuery(query string) (*changeStreamQuery, message.Message) {
+	m := changeStreamSelectPattern.FindStringSubmatch(query)
+	if m == nil {
+		return nil, nil
+	}
+	csq := &changeStreamQuery{stream: m[2]}
+	projection, errMsg := csq.table().projection(m[1])
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	csq.projection = projection
+	for _, condition := range changeStreamAndPattern.Split(m[3], -1) {
+		c := changeStreamConditionPattern.FindStringSubmatch(strings.TrimSpace(condition))
+		if c == nil {
+			return nil, invalidChangeStreamQuery(csq.stream)
+		}
+		name := strings.ToLower(c[1])
+		var target *time.Time
+		switch name {
+		case "start_time":
+			target = &csq.start
+		case "end_time":
+			target = &csq.end
+		default:
+			return nil, invalidChangeStreamQuery(csq.stream)
+		}
+		if c[2] == "?" {
+			csq.markers = append(csq.markers, name)
+			continue
+		}
+		t, err := parseChangeStreamTime(c[2])
+		if err != nil {
+			return nil, &message.Invalid{ErrorMessage: err.Error()}
+		}
+		*target = t
+	}
+	if csq.start.IsZero() && !csq.bound("start_time") {
+		return nil, invalidChangeStreamQuery(csq.stream)
+	}
+	return csq, nil
+}
+
+func invalidChangeStreamQuery(stream string) message.Message {
+	return &message.Invalid{
+		ErrorMessage: fmt.Sprintf(
+			"Only WHERE start_time = <timestamp> [AND end_time = <timestamp>] is supported on table %s.%s",
+			changeStreamKeyspace,
+			stream,
+		),
+	}
+}
+
+// parseChangeStreamTime parses a timestamp literal, either a quoted date and
+// time or a number of milliseconds since the epoch.
+func parseChangeStreamTime(literal string) (time.Time, error) {
+	if !strings.HasPrefix(literal, "'") {
+		ms, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %s", literal)
+		}
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	value := strings.Trim(literal, "'")
+	for _, layout := range changeStreamTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %s", literal)
+}
+
+func (csq *changeStreamQuery) bound(name string) bool {
+	for _, marker := range csq.markers {
+		if marker == name {
+			return true
+		}
+	}
+	return false
+}
+
+// bind sets the timestamps given by the bind markers of the query, bound by
+// position or by name.
+func (csq *changeStreamQuery) bind(options *message.QueryOptions) message.Message {
+	if len(csq.markers) == 0 {
+		return nil
+	}
+	for i, name := range csq.markers {
+		var value *primitive.Value
+		switch {
+		case options == nil:
+		case len(options.NamedValues) > 0:
+			value = options.NamedValues[name]
+		case i < len(options.PositionalValues):
+			value = options.PositionalValues[i]
+		}
+		if value == nil || len(value.Contents) != 8 {
+			return &message.Invalid{
+				ErrorMessage: fmt.Sprintf("Expected a timestamp value for %s", name),
+			}
+		}
+		t := time.UnixMilli(int64(binary.BigEndian.Uint64(value.Contents))).UTC()
+		if name == "start_time" {
+			csq.start = t
+		} else {
+			csq.end = t
+		}
+	}
+	return nil
+}
+
+func (csq *changeStreamQuery) table() *virtualTable {
+	return &virtualTable{
+		keyspace: changeStreamKeyspace,
+		name:     csq.stream,
+		columns:  virtualColumns(changeStreamKeyspace, csq.stream, changeStreamColumns),
+	}
+}
+
+// result returns the rows of the modifications of records.
+func (csq *changeStreamQuery) result(records []changeRecord) *message.RowsResult {
+	data := make(message.RowSet, 0, len(records))
+	for _, r := range records {
+		row := message.Row{
+			[]byte(r.PartitionToken),
+			encodeBigint(r.CommitTimestamp.UnixMilli()),
+			[]byte(r.RecordSequence),
+			[]byte(r.ServerTransactionID),
+			[]byte(r.TableName),
+			[]byte(r.ModType),
+			nullableBytes(r.Keys),
+			nullableBytes(r.NewValues),
+			nullableBytes(r.OldValues),
+		}
+		projected := make(message.Row, 0, len(csq.projection))
+		for _, i := range csq.projection {
+			projected = append(projected, row[i])
+		}
+		data = append(data, projected)
+	}
+	return &message.RowsResult{
+		Metadata: csq.table().metadata(csq.projection),
+		Data:     data,
+	}
+}
+
+func nullableBytes(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
+
+// changeStreamPagingState encodes the paging state of a change stream query:
+// the end of the time window read by its first page, which later pages keep
+// reading, and the last record returned.
+func changeStreamPagingState(end time.Time, last *changeRecord) []byte {
+	return []byte(strings.Join([]string{
+		strconv.FormatInt(end.UnixNano(), 10),
+		strconv.FormatInt(last.CommitTimestamp.UnixNano(), 10),
+		last.ServerTransactionID,
+		last.RecordSequence,
+		strconv.Itoa(last.ModIndex),
+	}, "\x00"))
+}
+
+// parseChangeStreamPagingState decodes a paging state returned by
+// changeStreamPagingState.
+func parseChangeStreamPagingState(state []byte) (time.Time, *changeRecord, error) {
+	fields := strings.Split(string(state), "\x00")
+	if len(fields) != 5 {
+		return time.Time{}, nil, fmt.Errorf("invalid paging state")
+	}
+	end, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("invalid paging state")
+	}
+	commit, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("invalid paging state")
+	}
+	modIndex, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("invalid paging state")
+	}
+	return time.Unix(0, end).UTC(), &changeRecord{
+		CommitTimestamp:     time.Unix(0, commit).UTC(),
+		ServerTransactionID: fields[2],
+		RecordSequence:      fields[3],
+		ModIndex:            modIndex,
+	}, nil
+}
+
+// serveChangeStream answers a change stream query, reading the records
+// committed from its start time up to its end time, or up to now. Results are
+// paged with the page size of the driver: every page reads the change stream
+// again, from the commit timestamp of the last record of the previous page.
+func (dc *driverConnection) serveChangeStream(
+	ctx context.Context,
+	csq *changeStreamQuery,
+	options *message.QueryOptions,
+) message.Message {
+	if errMsg := csq.bind(options); errMsg != nil {
+		return errMsg
+	}
+	start, end := csq.start, csq.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	page := &changeRecordPage{}
+	if options != nil {
+		page.limit = int(options.PageSize)
+		if len(options.PagingState) > 0 {
+			var err error
+			end, page.after, err = parseChangeStreamPagingState(options.PagingState)
+			if err != nil {
+				return &message.ProtocolError{ErrorMessage: err.Error()}
+			}
+			if page.after.CommitTimestamp.After(start) {
+				start = page.after.CommitTimestamp
+			}
+		}
+	}
+	if !csq.start.Before(end) {
+		return &message.Invalid{ErrorMessage: "start_time must be before end_time"}
+	}
+	reader := dc.executor.changeStreams
+	ctx, cancel := context.WithTimeout(ctx, reader.timeout)
+	defer cancel()
+	err := reader.read(
+		ctx,
+		dc.adapterClient.opts.DatabaseUri,
+		csq.stream,
+		start,
+		end,
+		page,
+	)
+	if err != nil {
+		return &message.ServerError{
+			ErrorMessage: fmt.Sprintf(
+				"Failed to read change stream %s: %v",
+				csq.stream,
+				err,
+			),
+		}
+	}
+	records, more := page.result()
+	result := csq.result(records)
+	if more {
+		result.Metadata.PagingState = changeStreamPagingState(
+			end,
+			&records[len(records)-1],
+		)
+	}
+	return result
+}
+
+// tryServeChangeStream answers QUERY, PREPARE and EXECUTE requests on the
+// tables of the cdc keyspace with Options.EnableChangeStreams. Returns nil if
+// the request does not target a change stream.
+func (dc *driverConnection) tryServeChangeStream(
+	ctx context.Context,
+	frm *frame.Frame,
+) message.Message {
+	if dc.executor.changeStreams == nil {
+		return nil
+	}
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		csq, errMsg := parseChangeStreamQuery(msg.Query)
+		if csq == nil {
+			return errMsg
+		}
+		return dc.serveChangeStream(ctx, csq, msg.Options)
+	case *message.Prepare:
+		csq, errMsg := parseChangeStreamQuery(msg.Query)
+		if csq == nil {
+			return errMsg
+		}
+		table := csq.table()
+		variables := &message.VariablesMetadata{}
+		for _, name := range csq.markers {
+			variables.Columns = append(variables.Columns, &message.ColumnMetadata{
+				Keyspace: changeStreamKeyspace,
+				Table:    csq.stream,
+				Name:     name,
+				Type:     datatype.Timestamp,
+			})
+		}
+		id := virtualQueryId(msg.Query)
+		dc.globalState.Store(string(id), msg.Query)
+		return &message.PreparedResult{
+			PreparedQueryId:   id,
+			VariablesMetadata: variables,
+			ResultMetadata:    table.metadata(csq.projection),
+		}
+	case *message.Execute:
+		if !strings.HasPrefix(string(msg.QueryId), virtualQueryIdPrefix) {
+			return nil
+		}
+		query, found := dc.globalState.Load(string(msg.QueryId))
+		if !found {
+			return nil
+		}
+		csq, errMsg := parseChangeStreamQuery(query)
+		if csq == nil {
+			return errMsg
+		}
+		return dc.serveChangeStream(ctx, csq, msg.Options)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,282 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChangeStreamQuery(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(5 * time.Minute)
+
+	csq, errMsg := parseChangeStreamQuery(
+		"SELECT * FROM cdc.orders_stream WHERE start_time = '2025-06-01T10:00:00Z' AND end_time = '2025-06-01 10:05:00+0000'",
+	)
+	require.Nil(t, errMsg)
+	assert.Equal(t, "orders_stream", csq.stream)
+	assert.Len(t, csq.projection, len(changeStreamColumns))
+	assert.Equal(t, start, csq.start)
+	assert.Equal(t, end, csq.end)
+
+	csq, errMsg = parseChangeStreamQuery(
+		"select table_name, new_values from cdc.orders_stream where start_time = 1748772000000",
+	)
+	require.Nil(t, errMsg)
+	assert.Equal(t, []int{4, 7}, csq.projection)
+	assert.Equal(t, start, csq.start)
+	assert.True(t, csq.end.IsZero())
+
+	csq, errMsg = parseChangeStreamQuery(
+		"SELECT * FROM cdc.orders_stream WHERE start_time = ? AND end_time = ?",
+	)
+	require.Nil(t, errMsg)
+	assert.Equal(t, []string{"start_time", "end_time"}, csq.markers)
+
+	// Queries on other keyspaces are not change stream queries.
+	csq, errMsg = parseChangeStreamQuery("SELECT * FROM ks.orders WHERE id = 1")
+	assert.Nil(t, csq)
+	assert.Nil(t, errMsg)
+
+	for _, query := range []string{
+		"SELECT * FROM cdc.orders_stream WHERE end_time = '2025-06-01T10:00:00Z'",
+		"SELECT * FROM cdc.orders_stream WHERE partition_token = 'abc'",
+		"SELECT * FROM cdc.orders_stream WHERE start_time = 'yesterday'",
+		"SELECT unknown FROM cdc.orders_stream WHERE start_time = 0",
+	} {
+		_, errMsg = parseChangeStreamQuery(query)
+		assert.IsType(t, &message.Invalid{}, errMsg, query)
+	}
+}
+
+func TestTryServeChangeStream(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	var readStart, readEnd time.Time
+	reader := &changeStreamReader{
+		read: func(
+			ctx context.Context,
+			databaseUri, stream string,
+			start, end time.Time,
+			page *changeRecordPage,
+		) error {
+			assert.Equal(t, "db", databaseUri)
+			assert.Equal(t, "orders_stream", stream)
+			readStart, readEnd = start, end
+			page.add(changeRecord{
+				PartitionToken:  "p1",
+				CommitTimestamp: start.Add(time.Second),
+				RecordSequence:  "00000001",
+				TableName:       "orders",
+				ModType:         "INSERT",
+				Keys:            `{"id":"1"}`,
+				NewValues:       `{"total":"10"}`,
+			})
+			return nil
+		},
+		timeout: time.Minute,
+	}
+	globalState, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{opts: Options{DatabaseUri: "db"}},
+		executor:      &requestExecutor{changeStreams: reader},
+		globalState:   globalState,
+	}
+
+	query := "SELECT commit_timestamp, table_name, keys, old_values FROM cdc.orders_stream WHERE start_time = '2025-06-01T10:00:00Z'"
+	result := dc.tryServeChangeStream(
+		context.Background(),
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{Query: query}),
+	)
+	require.IsType(t, &message.RowsResult{}, result)
+	rows := result.(*message.RowsResult)
+	assert.Equal(t, start, readStart)
+	assert.False(t, readEnd.IsZero())
+	require.Len(t, rows.Data, 1)
+	assert.Equal(t, encodeBigint(start.Add(time.Second).UnixMilli()), rows.Data[0][0])
+	assert.Equal(t, []byte("orders"), rows.Data[0][1])
+	assert.Equal(t, []byte(`{"id":"1"}`), rows.Data[0][2])
+	assert.Nil(t, rows.Data[0][3])
+
+	// Prepared queries are bound with timestamp values.
+	query = "SELECT * FROM cdc.orders_stream WHERE start_time = ? AND end_time = ?"
+	prepared := dc.tryServeChangeStream(
+		context.Background(),
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Prepare{Query: query}),
+	)
+	require.IsType(t, &message.PreparedResult{}, prepared)
+	assert.Len(t, prepared.(*message.PreparedResult).VariablesMetadata.Columns, 2)
+	end := start.Add(time.Minute)
+	result = dc.tryServeChangeStream(
+		context.Background(),
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Execute{
+			QueryId: prepared.(*message.PreparedResult).PreparedQueryId,
+			Options: &message.QueryOptions{PositionalValues: []*primitive.Value{
+				primitive.NewValue(encodeBigint(start.UnixMilli())),
+				primitive.NewValue(encodeBigint(end.UnixMilli())),
+			}},
+		}),
+	)
+	require.IsType(t, &message.RowsResult{}, result)
+	assert.Equal(t, start, readStart)
+	assert.Equal(t, end, readEnd)
+
+	// Other queries are not served.
+	assert.Nil(t, dc.tryServeChangeStream(
+		context.Background(),
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{Query: "SELECT * FROM ks.orders"}),
+	))
+	dc.executor.changeStreams = nil
+	assert.Nil(t, dc.tryServeChangeStream(
+		context.Background(),
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{Query: query}),
+	))
+}
+
+func TestChangeRecordPage(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	record := func(second int, mod int) changeRecord {
+		return changeRecord{
+			CommitTimestamp:     start.Add(time.Duration(second) * time.Second),
+			ServerTransactionID: "t",
+			RecordSequence:      "00000001",
+			ModIndex:            mod,
+		}
+	}
+	// Records are added out of order, and at most twice the limit are held.
+	page := &changeRecordPage{limit: 2}
+	for _, second := range []int{5, 3, 4, 1, 2, 6} {
+		page.add(record(second, 0))
+		assert.Less(t, len(page.records), 4)
+	}
+	page.add(record(1, 1))
+	records, more := page.result()
+	assert.True(t, more)
+	assert.Equal(t, []changeRecord{record(1, 0), record(1, 1)}, records)
+
+	// The next page starts after the last record of the previous one.
+	page = &changeRecordPage{limit: 2, after: &records[1]}
+	for _, second := range []int{1, 2, 1, 3} {
+		page.add(record(second, 0))
+	}
+	page.add(record(1, 1))
+	records, more = page.result()
+	assert.False(t, more)
+	assert.Equal(t, []changeRecord{record(2, 0), record(3, 0)}, records)
+
+	// Pages are not limited without a page size.
+	page = &changeRecordPage{}
+	for second := 0; second < 10; second++ {
+		page.add(record(second, 0))
+	}
+	records, more = page.result()
+	assert.False(t, more)
+	assert.Len(t, records, 10)
+}
+
+func TestServeChangeStreamPages(t *testing.T) {
+	start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	var reads []time.Time
+	var ends []time.Time
+	reader := &changeStreamReader{
+		read: func(
+			ctx context.Context,
+			databaseUri, stream string,
+			start, end time.Time,
+			page *changeRecordPage,
+		) error {
+			deadline, ok := ctx.Deadline()
+			assert.True(t, ok)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 10*time.Second)
+			reads = append(reads, start)
+			ends = append(ends, end)
+			for second := 1; second <= 5; second++ {
+				commit := time.Date(2025, 6, 1, 10, 0, second, 0, time.UTC)
+				if !commit.Before(start) {
+					page.add(changeRecord{
+						CommitTimestamp: commit,
+						RecordSequence:  "00000001",
+						TableName:       "orders",
+					})
+				}
+			}
+			return nil
+		},
+		timeout: time.Minute,
+	}
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{opts: Options{DatabaseUri: "db"}},
+		executor:      &requestExecutor{changeStreams: reader},
+	}
+
+	query := "SELECT commit_timestamp FROM cdc.orders_stream WHERE start_time = '2025-06-01T10:00:00Z'"
+	var commits [][]byte
+	var pagingState []byte
+	for pages := 0; pages < 3; pages++ {
+		result := dc.tryServeChangeStream(
+			context.Background(),
+			frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{
+				Query:   query,
+				Options: &message.QueryOptions{PageSize: 2, PagingState: pagingState},
+			}),
+		)
+		require.IsType(t, &message.RowsResult{}, result)
+		rows := result.(*message.RowsResult)
+		assert.LessOrEqual(t, len(rows.Data), 2)
+		for _, row := range rows.Data {
+			commits = append(commits, row[0])
+		}
+		pagingState = rows.Metadata.PagingState
+		if pagingState == nil {
+			break
+		}
+	}
+	assert.Nil(t, pagingState)
+	require.Len(t, commits, 5)
+	for i, commit := range commits {
+		assert.Equal(t, encodeBigint(start.Add(time.Duration(i+1)*time.Second).UnixMilli()), commit)
+	}
+	// Later pages resume from the last record, up to the end of the first page.
+	assert.Equal(t, []time.Time{
+		start,
+		start.Add(2 * time.Second),
+		start.Add(4 * time.Second),
+	}, reads)
+	assert.True(t, ends[0].Equal(ends[1]))
+	assert.True(t, ends[0].Equal(ends[2]))
+
+	// Invalid paging states are rejected.
+	result := dc.tryServeChangeStream(
+		context.Background(),
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{
+			Query:   query,
+			Options: &message.QueryOptions{PageSize: 2, PagingState: []byte("x")},
+		}),
+	)
+	assert.IsType(t, &message.ProtocolError{}, result)
+}
@@ -0,0 +1,411 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	vkit "cloud.google.com/go/spanner/adapter/apiv1"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Default number of consecutive transport failures after which a channel is
+	// re-dialed.
+	defaultUnhealthyChannelThreshold = 5
+	// Time to wait before closing a replaced channel so that in-flight streams
+	// on it can complete.
+	retiredChannelCloseDelay = time.Minute
+	// Smoothing factor of the per-channel latency moving average.
+	channelLatencyEWMAWeight = 0.2
+)
+
+// grpcChannel is a single gapic client backed by one gRPC connection, together
+// with the health statistics collected since it was last dialed.
+type grpcChannel struct {
+	id int
+	// Pool the channel belongs to.
+	pool *channelPool
+
+	mu                  sync.RWMutex
+	client              *vkit.Client
+	dialTime            time.Time
+	consecutiveFailures int
+	requests            int64
+	failures            int64
+	latencyEWMA         time.Duration
+	redialing           bool
+	// Number of AdaptMessage calls acquired on this channel whose result was
+	// not recorded yet.
+	inFlight int64
+	// Whether the channel was removed from the pool by autoscaling.
+	retired bool
+}
+
+// gapicClient returns the gapic client currently serving this channel.
+func (ch *grpcChannel) gapicClient() *vkit.Client {
+	ch.mu.RLock()
+	defer ch.mu.RUnlock()
+	return ch.client
+}
+
+// channelContextKey is the context key of the channel an AdaptMessage call is
+// sent on.
+type channelContextKey struct{}
+
+// withChannel returns a context sending the AdaptMessage calls of
+// AdaptMessageGrpc on ch, so that the pool can track the health of the channel
+// it acquired for the call.
+func withChannel(ctx context.Context, ch *grpcChannel) context.Context {
+	return context.WithValue(ctx, channelContextKey{}, ch)
+}
+
+// channelOf returns the channel an AdaptMessage call is sent on: the channel
+// set by withChannel, or otherwise a channel picked from the pool of cl.
+func channelOf(ctx context.Context, cl *AdapterClient) *grpcChannel {
+	if ch, ok := ctx.Value(channelContextKey{}).(*grpcChannel); ok {
+		return ch
+	}
+	return cl.channels.pick()
+}
+
+// channelPool is a pool of gRPC channels that are handed out round-robin.
+// Channels that keep failing with transport errors are re-dialed in the
+// background without interrupting traffic on the rest of the pool. The pool
+// has a fixed size unless autoscaling is enabled with MaxGrpcChannels.
+type channelPool struct {
+	mu        sync.RWMutex
+	channels  []*grpcChannel
+	next      atomic.Uint64
+	threshold int
+	// Dials a channel to the active endpoint.
+	dial func(ctx context.Context) (*vkit.Client, error)
+
+	// Prioritized Spanner endpoints: SpannerEndpoint followed by
+	// FailoverEndpoints.
+	endpoints []string
+	// Index in endpoints of the endpoint the channels are dialed to.
+	active       atomic.Int64
+	dialEndpoint func(ctx context.Context, i int) (*vkit.Client, error)
+	// Checks the health of an endpoint, overwritten in tests.
+	probe func(ctx context.Context, i int) error
+	// Number of consecutive transport failures across the pool.
+	failureStreak atomic.Int64
+	failingOver   atomic.Bool
+	// Serializes the switches of the active endpoint.
+	switchMu sync.Mutex
+	// Number of times the active endpoint changed.
+	failovers atomic.Int64
+
+	// Number of times any channel in the pool has been re-dialed.
+	redials atomic.Int64
+
+	// Bounds of the pool size, equal unless autoscaling is enabled.
+	minSize int
+	maxSize int
+	// Number of in-flight AdaptMessage calls across the pool.
+	inFlight atomic.Int64
+	// Highest number of in-flight calls since the last scaling decision.
+	peakInFlight atomic.Int64
+	// Number of consecutive scaling decisions the pool was larger than needed.
+	oversizedIntervals int
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	// Background loops of the pool (autoscaling, endpoint health checks),
+	// waited for by close.
+	loops sync.WaitGroup
+}
+
+func newChannelPool(
+	ctx context.Context,
+	opts Options,
+	clientOpts []option.ClientOption,
+) (*channelPool, error) {
+	size := opts.NumGrpcChannels
+	if size <= 0 {
+		size = 1
+	}
+	minSize, maxSize := size, size
+	if opts.MaxGrpcChannels > 0 {
+		minSize = max(opts.MinGrpcChannels, 1)
+		maxSize = max(opts.MaxGrpcChannels, minSize)
+		size = min(max(size, minSize), maxSize)
+	}
+	threshold := opts.UnhealthyChannelThreshold
+	if threshold == 0 {
+		threshold = defaultUnhealthyChannelThreshold
+	}
+	// Every pool member owns exactly one connection so that it can be replaced
+	// individually.
+	clientOpts = append(
+		clientOpts[:len(clientOpts):len(clientOpts)],
+		option.WithGRPCConnectionPool(1),
+	)
+	primary := opts.SpannerEndpoint
+	if primary == "" {
+		primary = defaultSpannerEndpoint
+	}
+	pool := &channelPool{
+		threshold: threshold,
+		minSize:   minSize,
+		maxSize:   maxSize,
+		stop:      make(chan struct{}),
+		endpoints: append([]string{primary}, opts.FailoverEndpoints...),
+	}
+	pool.dialEndpoint = func(ctx context.Context, i int) (*vkit.Client, error) {
+		if i == 0 {
+			return vkit.NewClient(ctx, clientOpts...)
+		}
+		return vkit.NewClient(ctx, append(
+			clientOpts[:len(clientOpts):len(clientOpts)],
+			option.WithEndpoint(pool.endpoints[i]),
+		)...)
+	}
+	pool.dial = func(ctx context.Context) (*vkit.Client, error) {
+		return pool.dialEndpoint(ctx, int(pool.active.Load()))
+	}
+	pool.probe = pool.probeEndpoint
+	for i := 0; i < size; i++ {
+		client, err := pool.dial(ctx)
+		if err != nil {
+			pool.close()
+			return nil, err
+		}
+		pool.channels = append(pool.channels, &grpcChannel{
+			id:       i,
+			pool:     pool,
+			client:   client,
+			dialTime: time.Now(),
+		})
+	}
+	if maxSize > minSize {
+		pool.startLoop(func() { pool.autoscale(channelScaleInterval) })
+	}
+	if len(pool.endpoints) > 1 {
+		pool.startLoop(func() { pool.checkEndpoints(endpointHealthCheckInterval) })
+	}
+	return pool, nil
+}
+
+// startLoop runs a background loop of the pool, which must return once the
+// pool is closed.
+func (p *channelPool) startLoop(loop func()) {
+	p.loops.Add(1)
+	go func() {
+		defer p.loops.Done()
+		loop()
+	}()
+}
+
+// pick returns the next channel in round-robin order.
+func (p *channelPool) pick() *grpcChannel {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	n := p.next.Add(1) - 1
+	return p.channels[n%uint64(len(p.channels))]
+}
+
+// pickAffine returns the channel key is pinned to, or the next channel in
+// round-robin order while the pinned channel is failing. Keys are remapped
+// when the size of the pool changes.
+func (p *channelPool) pickAffine(key int) *grpcChannel {
+	p.mu.RLock()
+	ch := p.channels[uint(key)%uint(len(p.channels))]
+	p.mu.RUnlock()
+	ch.mu.RLock()
+	healthy := ch.consecutiveFailures == 0 && !ch.redialing
+	ch.mu.RUnlock()
+	if healthy {
+		return ch
+	}
+	return p.pick()
+}
+
+// acquire picks a channel for an AdaptMessage call, whose result must then be
+// passed to recordResult.
+func (p *channelPool) acquire() *grpcChannel {
+	return p.track(p.pick())
+}
+
+// acquireAffine is acquire for a call pinned to the channel of key.
+func (p *channelPool) acquireAffine(key int) *grpcChannel {
+	return p.track(p.pickAffine(key))
+}
+
+// track counts a call acquired on ch as in-flight.
+func (p *channelPool) track(ch *grpcChannel) *grpcChannel {
+	ch.mu.Lock()
+	ch.inFlight++
+	ch.mu.Unlock()
+	inFlight := p.inFlight.Add(1)
+	for {
+		peak := p.peakInFlight.Load()
+		if inFlight <= peak || p.peakInFlight.CompareAndSwap(peak, inFlight) {
+			return ch
+		}
+	}
+}
+
+// size returns the number of channels of the pool.
+func (p *channelPool) size() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.channels)
+}
+
+// close stops the background loops of the pool and closes all its channels.
+func (p *channelPool) close() {
+	p.closeOnce.Do(func() { close(p.stop) })
+	p.loops.Wait()
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, ch := range p.channels {
+		if client := ch.gapicClient(); client != nil {
+			client.Close()
+		}
+	}
+}
+
+// isChannelFailure reports whether err indicates that the underlying
+// connection, rather than the request itself, is broken.
+func isChannelFailure(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	case codes.Internal:
+		return strings.Contains(s.Message(), "RST_STREAM") ||
+			strings.Contains(s.Message(), "INTERNAL_ERROR")
+	default:
+		return false
+	}
+}
+
+// recordResult updates the health statistics of ch with the outcome of a
+// single AdaptMessage call and triggers a re-dial once the channel has failed
+// too many times in a row, or a failover once the whole pool has.
+func (p *channelPool) recordResult(
+	ch *grpcChannel,
+	latency time.Duration,
+	err error,
+) {
+	ch.mu.Lock()
+	if ch.inFlight > 0 {
+		ch.inFlight--
+		p.inFlight.Add(-1)
+	}
+	ch.requests++
+	if ch.latencyEWMA == 0 {
+		ch.latencyEWMA = latency
+	} else {
+		ch.latencyEWMA = time.Duration(
+			channelLatencyEWMAWeight*float64(latency) +
+				(1-channelLatencyEWMAWeight)*float64(ch.latencyEWMA),
+		)
+	}
+	if !isChannelFailure(err) {
+		ch.consecutiveFailures = 0
+		ch.mu.Unlock()
+		p.failureStreak.Store(0)
+		return
+	}
+	streak := p.failureStreak.Add(1)
+	ch.failures++
+	ch.consecutiveFailures++
+	shouldRedial := p.threshold > 0 &&
+		ch.consecutiveFailures >= p.threshold &&
+		!ch.redialing
+	if shouldRedial {
+		ch.redialing = true
+	}
+	ch.mu.Unlock()
+
+	if shouldRedial {
+		go p.redial(ch)
+	}
+	if p.shouldFailover(streak) {
+		go p.failover()
+	}
+}
+
+// redial replaces the connection of ch with a freshly dialed one. The retired
+// connection is closed after a grace period to let in-flight streams finish.
+func (p *channelPool) redial(ch *grpcChannel) {
+	ch.mu.RLock()
+	logger.Info("Re-dialing unhealthy gRPC channel",
+		zap.Int("channel_id", ch.id),
+		zap.Int("consecutive_failures", ch.consecutiveFailures),
+		zap.Int64("requests", ch.requests),
+		zap.Int64("failures", ch.failures),
+		zap.Duration("latency_ewma", ch.latencyEWMA),
+		zap.Duration("channel_age", time.Since(ch.dialTime)),
+	)
+	ch.mu.RUnlock()
+
+	client, err := p.dial(context.Background())
+
+	ch.mu.Lock()
+	ch.redialing = false
+	ch.mu.Unlock()
+	if err != nil {
+		logger.Error("Failed to re-dial gRPC channel",
+			zap.Int("channel_id", ch.id),
+			zap.Error(err))
+		return
+	}
+	if p.replaceClient(ch, client) {
+		p.redials.Add(1)
+	}
+}
+
+// replaceClient replaces the connection of ch with client and resets its
+// health statistics. The retired connection is closed after a grace period to
+// let in-flight streams finish. Returns false, closing client, if the channel
+// was removed from the pool.
+func (p *channelPool) replaceClient(ch *grpcChannel, client *vkit.Client) bool {
+	ch.mu.Lock()
+	if ch.retired {
+		ch.mu.Unlock()
+		client.Close()
+		return false
+	}
+	retired := ch.client
+	ch.client = client
+	ch.dialTime = time.Now()
+	ch.consecutiveFailures = 0
+	ch.requests = 0
+	ch.failures = 0
+	ch.latencyEWMA = 0
+	ch.mu.Unlock()
+
+	if retired != nil {
+		time.AfterFunc(retiredChannelCloseDelay, func() { retired.Close() })
+	}
+	return true
+}
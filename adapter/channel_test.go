@@ -0,0 +1,234 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestChannelPoolPickRoundRobin(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{NumGrpcChannels: 3},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+
+	var ids []int
+	for i := 0; i < 6; i++ {
+		ids = append(ids, pool.pick().id)
+	}
+	assert.Equal(t, []int{0, 1, 2, 0, 1, 2}, ids)
+}
+
+func TestChannelOf(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{NumGrpcChannels: 2},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+	cl := &AdapterClient{channels: pool}
+
+	// Calls are sent on the channel acquired by the executor.
+	ch := pool.channels[1]
+	for i := 0; i < 3; i++ {
+		assert.Same(t, ch, channelOf(withChannel(context.Background(), ch), cl))
+	}
+	// Other calls are sent on any channel of the pool.
+	assert.Contains(t, pool.channels, channelOf(context.Background(), cl))
+}
+
+func TestIsChannelFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"non grpc error", errors.New("broken pipe"), false},
+		{"unavailable", status.Error(codes.Unavailable, "unavailable"), true},
+		{"deadline", status.Error(codes.DeadlineExceeded, "deadline"), true},
+		{
+			"rst stream",
+			status.Error(codes.Internal, "stream terminated by RST_STREAM"),
+			true,
+		},
+		{"other internal", status.Error(codes.Internal, "internal"), false},
+		{"invalid argument", status.Error(codes.InvalidArgument, "bad"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isChannelFailure(tt.err))
+		})
+	}
+}
+
+func TestChannelPoolRedialsUnhealthyChannel(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{NumGrpcChannels: 2, UnhealthyChannelThreshold: 3},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+
+	ch := pool.channels[0]
+	original := ch.gapicClient()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	// Successful requests reset the failure streak.
+	pool.recordResult(ch, time.Millisecond, unavailable)
+	pool.recordResult(ch, time.Millisecond, unavailable)
+	pool.recordResult(ch, time.Millisecond, nil)
+	pool.recordResult(ch, time.Millisecond, unavailable)
+	assert.Equal(t, int64(0), pool.redials.Load())
+
+	pool.recordResult(ch, time.Millisecond, unavailable)
+	pool.recordResult(ch, time.Millisecond, unavailable)
+	assert.Eventually(t, func() bool {
+		return pool.redials.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.NotSame(t, original, ch.gapicClient())
+	assert.Equal(t, 0, ch.consecutiveFailures)
+}
+
+func TestChannelPoolRotationDisabled(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{NumGrpcChannels: 1, UnhealthyChannelThreshold: -1},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+
+	ch := pool.channels[0]
+	for i := 0; i < 10; i++ {
+		pool.recordResult(ch, time.Millisecond, status.Error(codes.Unavailable, ""))
+	}
+	assert.Equal(t, int64(0), pool.redials.Load())
+	assert.Equal(t, 10, ch.consecutiveFailures)
+}
+
+func TestChannelPoolAutoscale(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{NumGrpcChannels: 1, MinGrpcChannels: 1, MaxGrpcChannels: 3},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+	require.Equal(t, 1, pool.size())
+
+	var acquired []*grpcChannel
+	for i := 0; i < 120; i++ {
+		acquired = append(acquired, pool.acquire())
+	}
+	pool.scale(context.Background())
+	assert.Equal(t, 3, pool.size())
+
+	for _, ch := range acquired {
+		pool.recordResult(ch, time.Millisecond, nil)
+	}
+	assert.Equal(t, int64(0), pool.inFlight.Load())
+	// The peak of the previous interval is still reported once.
+	pool.scale(context.Background())
+	assert.Equal(t, 3, pool.size())
+
+	for i := 0; i < channelShrinkIntervals; i++ {
+		assert.Equal(t, 3, pool.size())
+		pool.scale(context.Background())
+	}
+	assert.Equal(t, 2, pool.size())
+}
+
+func TestChannelPoolAutoscaleBounds(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{NumGrpcChannels: 8, MinGrpcChannels: 2, MaxGrpcChannels: 4},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+
+	assert.Equal(t, 4, pool.size())
+	assert.Equal(t, 2, pool.desiredSize(0))
+	assert.Equal(t, 4, pool.desiredSize(1000))
+}
+
+func TestChannelPoolPickAffine(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{NumGrpcChannels: 3, UnhealthyChannelThreshold: -1},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+
+	// Requests of a connection are pinned to the same channel.
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 1, pool.pickAffine(4).id)
+		assert.Equal(t, 2, pool.pickAffine(5).id)
+	}
+
+	// Requests fall back to round-robin while the pinned channel fails.
+	pool.recordResult(pool.channels[1], time.Millisecond, status.Error(codes.Unavailable, ""))
+	var ids []int
+	for i := 0; i < 3; i++ {
+		ids = append(ids, pool.pickAffine(4).id)
+	}
+	assert.ElementsMatch(t, []int{0, 1, 2}, ids)
+
+	pool.recordResult(pool.channels[1], time.Millisecond, nil)
+	assert.Equal(t, 1, pool.acquireAffine(4).id)
+	assert.Equal(t, int64(1), pool.inFlight.Load())
+}
+
+func TestDedicatedChannelPools(t *testing.T) {
+	ctx := context.Background()
+	shared, err := newChannelPool(ctx, Options{NumGrpcChannels: 2}, SkipAuthOpts)
+	require.NoError(t, err)
+	reads, err := newDedicatedChannelPool(ctx, Options{}, SkipAuthOpts, 3, "")
+	require.NoError(t, err)
+	cl := &AdapterClient{channels: shared, readChannels: reads}
+	defer cl.close()
+
+	assert.Same(t, reads, cl.poolFor(false))
+	assert.Equal(t, 3, cl.poolFor(false).size())
+	// Writes use the shared pool without a dedicated one.
+	assert.Same(t, shared, cl.poolFor(true))
+
+	// Results are recorded in the pool the channel was acquired from.
+	ch := cl.poolFor(false).acquire()
+	assert.Equal(t, int64(1), reads.inFlight.Load())
+	cl.recordResult(ch, time.Millisecond, nil)
+	assert.Equal(t, int64(0), reads.inFlight.Load())
+	assert.Equal(t, int64(0), shared.inFlight.Load())
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/adapter/frameutil"
+	"google.golang.org/api/option"
+)
+
+var (
+	// QueryCassandraTypeColumns returns the number of columns with a
+	// cassandra_type option of each of the given tables.
+	QueryCassandraTypeColumns = func(
+		ctx context.Context,
+		databaseUri string,
+		tables []string,
+		clientOpts []option.ClientOption,
+	) (map[string]int, error) {
+		client, err := spanner.NewClientWithConfig(
+			ctx,
+			databaseUri,
+			spanner.ClientConfig{
+				SessionPoolConfig: spanner.SessionPoolConfig{MinOpened: 1},
+			},
+			clientOpts...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		stmt := spanner.Statement{
+			SQL: `SELECT TABLE_NAME, COUNT(*)
+				FROM INFORMATION_SCHEMA.COLUMN_OPTIONS
+				WHERE TABLE_SCHEMA = '' AND OPTION_NAME = 'cassandra_type'
+				AND TABLE_NAME IN UNNEST(@tables)
+				GROUP BY TABLE_NAME`,
+			Params: map[string]interface{}{"tables": tables},
+		}
+		columns := make(map[string]int)
+		err = client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			var table string
+			var count int64
+			if err := row.Columns(&table, &count); err != nil {
+				return err
+			}
+			columns[table] = int(count)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return columns, nil
+	}
+)
+
+// CheckResult is the outcome of one step of a startup self-check.
+type CheckResult struct {
+	// Name of the step (ie: credentials).
+	Name string
+	// Description of what was verified, or why the step failed.
+	Detail string
+	// Failure of the step, nil if it passed or was skipped.
+	Err error
+	// Whether the step was skipped because an earlier step failed.
+	Skipped bool
+}
+
+// CheckReport holds the results of the steps of a startup self-check, in the
+// order they were run.
+type CheckReport struct {
+	Results []CheckResult
+}
+
+// Passed reports whether all steps of the check passed.
+func (r CheckReport) Passed() bool {
+	for _, result := range r.Results {
+		if result.Err != nil || result.Skipped {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the report with one line per step.
+func (r CheckReport) String() string {
+	var sb strings.Builder
+	for _, result := range r.Results {
+		status := "PASS"
+		if result.Skipped {
+			status = "SKIP"
+		} else if result.Err != nil {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&sb, "%s %s: %s", status, result.Name, result.Detail)
+		if result.Err != nil {
+			fmt.Fprintf(&sb, ": %v", result.Err)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// checker runs the steps of a startup self-check and records their results.
+type checker struct {
+	report CheckReport
+	failed bool
+}
+
+// run runs step unless an earlier step failed.
+func (c *checker) run(name string, step func() (string, error)) {
+	if c.failed {
+		c.report.Results = append(c.report.Results, CheckResult{
+			Name:    name,
+			Detail:  "not run after an earlier failure",
+			Skipped: true,
+		})
+		return
+	}
+	detail, err := step()
+	c.failed = err != nil
+	c.report.Results = append(c.report.Results, CheckResult{
+		Name:   name,
+		Detail: detail,
+		Err:    err,
+	})
+}
+
+// Check verifies that a proxy started with opts could serve requests, without
+// listening for driver connections. It validates the credentials, the
+// existence of the database, the cassandra_type column options of the given
+// tables (ie: keyspace.table) and that Spanner answers AdaptMessage calls.
+// Steps after a failed one are skipped.
+func Check(ctx context.Context, opts Options, tables []string) CheckReport {
+	c := &checker{}
+	var cl *AdapterClient
+	c.run("credentials", func() (string, error) {
+		var err error
+		cl, err = newAdapterClient(ctx, opts)
+		if err != nil {
+			return "failed to create Spanner client", err
+		}
+		return "created Spanner client", nil
+	})
+	if cl != nil {
+		defer cl.channels.close()
+	}
+	c.run("database", func() (string, error) {
+		if err := cl.createSession(ctx, opts); err != nil {
+			return fmt.Sprintf("failed to create a session on %s", opts.DatabaseUri), err
+		}
+		return fmt.Sprintf("created session on %s", opts.DatabaseUri), nil
+	})
+	c.run("schema", func() (string, error) {
+		return checkSchema(ctx, opts, tables)
+	})
+	c.run("adapt_message", func() (string, error) {
+		return checkAdaptMessage(ctx, cl)
+	})
+	return c.report
+}
+
+// checkSchema verifies that each of tables has at least one column with a
+// cassandra_type option.
+func checkSchema(
+	ctx context.Context,
+	opts Options,
+	tables []string,
+) (string, error) {
+	if len(tables) == 0 {
+		return "no tables to check", nil
+	}
+	_, _, database, err := parseDatabaseName(opts.DatabaseUri)
+	if err != nil {
+		return "invalid database", err
+	}
+	var names []string
+	for _, table := range tables {
+		keyspace, name, found := strings.Cut(table, ".")
+		if !found {
+			name = keyspace
+		} else if keyspace != database {
+			return "invalid table", fmt.Errorf(
+				"table %s is not in keyspace %s",
+				table,
+				database,
+			)
+		}
+		names = append(names, name)
+	}
+	clientOpts, err := getAllClientOpts(opts)
+	if err != nil {
+		return "failed to create Spanner client", err
+	}
+	columns, err := QueryCassandraTypeColumns(
+		ctx,
+		opts.DatabaseUri,
+		names,
+		clientOpts,
+	)
+	if err != nil {
+		return "failed to query column options", err
+	}
+	var missing []string
+	for _, name := range names {
+		if columns[name] == 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return "missing cassandra_type column options", fmt.Errorf(
+			"tables without cassandra_type column options: %s",
+			strings.Join(missing, ", "),
+		)
+	}
+	return fmt.Sprintf("%d tables have cassandra_type column options", len(names)), nil
+}
+
+// checkAdaptMessage sends an OPTIONS request to Spanner and verifies that it
+// is answered with a SUPPORTED response.
+func checkAdaptMessage(ctx context.Context, cl *AdapterClient) (string, error) {
+	frm := frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Options{})
+	buf := bytes.NewBuffer(nil)
+	if err := frame.NewCodec().EncodeFrame(frm, buf); err != nil {
+		return "failed to encode OPTIONS request", err
+	}
+	req := &adapterpb.AdaptMessageRequest{
+		Name:     cl.getSession().name,
+		Protocol: "cassandra",
+		Payload:  buf.Bytes(),
+	}
+	ctxWithMd := contextWithOutgoingMetadata(ctx, cl.getMetadata(), false)
+	pbCli, err := AdaptMessageGrpc(ctxWithMd, req, cl)
+	if err != nil {
+		return "failed to send OPTIONS request", err
+	}
+	var payloads [][]byte
+	for {
+		resp, err := pbCli.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "failed to receive OPTIONS response", err
+		}
+		if resp.Payload != nil {
+			payloads = append(payloads, resp.Payload)
+		}
+	}
+	response, err := frame.NewCodec().DecodeFrame(
+		bytes.NewReader(frameutil.MergeChunkedPayloads(payloads)),
+	)
+	if err != nil {
+		return "failed to decode OPTIONS response", err
+	}
+	if _, ok := response.Body.Message.(*message.Supported); !ok {
+		return "unexpected OPTIONS response", fmt.Errorf(
+			"expected SUPPORTED, got %v",
+			response.Body.Message,
+		)
+	}
+	return "OPTIONS request answered", nil
+}
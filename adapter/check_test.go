@@ -0,0 +1,143 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func mockCassandraTypeColumns(t *testing.T, columns map[string]int) *[]string {
+	orig := QueryCassandraTypeColumns
+	t.Cleanup(func() { QueryCassandraTypeColumns = orig })
+	var queried []string
+	QueryCassandraTypeColumns = func(
+		ctx context.Context,
+		databaseUri string,
+		tables []string,
+		clientOpts []option.ClientOption,
+	) (map[string]int, error) {
+		queried = tables
+		return columns, nil
+	}
+	return &queried
+}
+
+func checkOptions() Options {
+	return Options{
+		DatabaseUri:     "projects/test/instances/test/databases/ks",
+		NumGrpcChannels: 1,
+		GoogleApiOpts:   SkipAuthOpts,
+	}
+}
+
+func checkStatuses(report CheckReport) map[string]string {
+	statuses := make(map[string]string)
+	for _, result := range report.Results {
+		switch {
+		case result.Skipped:
+			statuses[result.Name] = "skip"
+		case result.Err != nil:
+			statuses[result.Name] = "fail"
+		default:
+			statuses[result.Name] = "pass"
+		}
+	}
+	return statuses
+}
+
+func TestCheckPasses(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	MockAdaptMessageGrpc(false)
+	queried := mockCassandraTypeColumns(t, map[string]int{"users": 3})
+
+	report := Check(context.Background(), checkOptions(), []string{"ks.users"})
+
+	assert.True(t, report.Passed(), report.String())
+	assert.Equal(t, map[string]string{
+		"credentials":   "pass",
+		"database":      "pass",
+		"schema":        "pass",
+		"adapt_message": "pass",
+	}, checkStatuses(report))
+	assert.Equal(t, []string{"users"}, *queried)
+}
+
+func TestCheckMissingCassandraTypes(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	MockAdaptMessageGrpc(false)
+	mockCassandraTypeColumns(t, map[string]int{"users": 3})
+
+	report := Check(
+		context.Background(),
+		checkOptions(),
+		[]string{"ks.users", "orders", "ks.items"},
+	)
+
+	assert.False(t, report.Passed())
+	assert.Equal(t, "fail", checkStatuses(report)["schema"])
+	assert.Equal(t, "skip", checkStatuses(report)["adapt_message"])
+	assert.Contains(t, report.String(), "items, orders")
+}
+
+func TestCheckRejectsTableOfOtherKeyspace(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	MockAdaptMessageGrpc(false)
+	queried := mockCassandraTypeColumns(t, nil)
+
+	report := Check(context.Background(), checkOptions(), []string{"other.users"})
+
+	assert.Equal(t, "fail", checkStatuses(report)["schema"])
+	assert.Nil(t, *queried)
+}
+
+func TestCheckSkipsStepsAfterFailure(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	CreateSessionGrpc = func(
+		ctx context.Context,
+		req *adapterpb.CreateSessionRequest,
+		cl *AdapterClient,
+	) (*adapterpb.Session, error) {
+		return nil, status.Error(codes.NotFound, "database not found")
+	}
+	MockAdaptMessageGrpc(false)
+	mockCassandraTypeColumns(t, nil)
+
+	report := Check(context.Background(), checkOptions(), nil)
+
+	assert.False(t, report.Passed())
+	assert.Equal(t, map[string]string{
+		"credentials":   "pass",
+		"database":      "fail",
+		"schema":        "skip",
+		"adapt_message": "skip",
+	}, checkStatuses(report))
+	assert.Contains(t, report.String(), "FAIL database")
+	assert.Contains(t, report.String(), "database not found")
+}
@@ -22,21 +22,27 @@ import (
 	"crypto/x509"
 	"fmt"
 	"math"
+	"net"
 	"os"
 	"regexp"
-	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	vkit "cloud.google.com/go/spanner/adapter/apiv1"
 	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
 	"github.com/googleapis/gax-go/v2"
+	"github.com/googleapis/gax-go/v2/callctx"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
 	"google.golang.org/api/option"
 	"google.golang.org/api/option/internaloption"
 	"google.golang.org/grpc"
 
+	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/metadata"
 	_ "google.golang.org/grpc/xds/googledirectpath"
 
@@ -56,6 +62,14 @@ const (
 	// batch/execute/query message need to route to leader.
 	routeToLeaderHeader       = "x-goog-spanner-route-to-leader"
 	requestsCompressionHeader = "x-response-encoding"
+	// clientUIDHeader and clientHashHeader identify the client sending a
+	// request, with the values of the client_uid and client_hash attributes of
+	// built-in metrics.
+	clientUIDHeader  = "x-goog-spanner-client-uid"
+	clientHashHeader = "x-goog-spanner-client-hash"
+	// apiClientHeader is the name of the metadata header carrying the name and
+	// version of the client library.
+	apiClientHeader = "x-goog-api-client"
 )
 
 var (
@@ -65,7 +79,7 @@ var (
 	SessionRefreshTimeInterval = 6 * 24 * time.Hour
 	CreateSessionGrpc          = func(ctx context.Context, req *adapterpb.CreateSessionRequest, cl *AdapterClient) (*adapterpb.Session, error) {
 		var md metadata.MD
-		resp, err := cl.gapicClient.CreateSession(
+		resp, err := cl.channels.pick().gapicClient().CreateSession(
 			ctx,
 			req,
 			gax.WithGRPCOptions(grpc.Header(&md)),
@@ -83,12 +97,42 @@ var (
 // The adapterClient encapsulates the gRPC connection / adapter stub creation.
 // It is also responsible for refreshing the multiplexed session.
 type AdapterClient struct {
-	opts        Options
-	gapicClient *vkit.Client
-	md          metadata.MD
+	opts     Options
+	channels *channelPool
+	// Dedicated pools of the reads and the writes, nil unless
+	// Options.ReadChannels or Options.WriteChannels is set.
+	readChannels  *channelPool
+	writeChannels *channelPool
+	md            metadata.MD
 
 	mu      sync.RWMutex
 	session session
+
+	// Number of consecutive failures to create a session.
+	sessionFailures atomic.Int64
+	// Called once MaxSessionFailures consecutive session failures occurred.
+	onSessionFailures func(err error)
+	// Request counters of the proxy.
+	stats *proxyStats
+	// Trace sessions of requests sent with the tracing flag.
+	traces *traceStore
+	// Unique identifier of the client, sent with every request.
+	clientUID string
+	// Built-in metrics of AdaptMessage calls, nil unless
+	// Options.EnableBuiltInMetrics is set.
+	metrics *builtinMetricsTracerFactory
+	// Identifier of the client in the ids of its requests.
+	clientID uint32
+	// Number of requests sent by the client, used to number request ids.
+	requestCount atomic.Uint64
+	// Whether the AdaptMessage calls of the client are served over DirectPath,
+	// as last observed with Options.EnableDirectAccess.
+	directPath atomic.Int32
+	// Whether a driver connection of the client completed its handshake.
+	firstHandshake atomic.Bool
+	// Limiter of the requests sent concurrently, nil unless
+	// Options.AdaptiveConcurrency is set.
+	limiter *concurrencyLimiter
 }
 
 type session struct {
@@ -108,9 +152,21 @@ func contextWithOutgoingMetadata(
 	if enableRouteToLeader {
 		md = metadata.Join(md, metadata.Pairs(routeToLeaderHeader, "true"))
 	}
+	// Merged by the generated client into its own x-goog-api-client header.
+	ctx = callctx.SetHeaders(ctx, apiClientHeader, gax.XGoogHeader("gccl", version))
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
+// clientMetadata returns the metadata headers sent with every request of a
+// client.
+func clientMetadata(databaseUri, clientUID string) metadata.MD {
+	return metadata.Pairs(
+		resourcePrefixHeader, databaseUri,
+		clientUIDHeader, clientUID,
+		clientHashHeader, generateClientHash(clientUID),
+	)
+}
+
 func parseDatabaseName(
 	db string,
 ) (project, instance, database string, err error) {
@@ -130,9 +186,15 @@ func newAdapterClient(
 	opts Options,
 ) (*AdapterClient, error) {
 	// Create a client.
+	clientUID := newClientUID()
 	cl := &AdapterClient{
-		opts: opts,
-		md:   metadata.Pairs(resourcePrefixHeader, opts.DatabaseUri),
+		opts:      opts,
+		md:        clientMetadata(opts.DatabaseUri, clientUID),
+		stats:     newProxyStats(),
+		traces:    newTraceStore(maxTraceSessions),
+		clientUID: clientUID,
+		clientID:  clientCount.Add(1),
+		limiter:   newConcurrencyLimiter(opts),
 	}
 
 	var err error
@@ -141,15 +203,84 @@ func newAdapterClient(
 	if err != nil {
 		return nil, err
 	}
+	dialOpts = append(dialOpts, option.WithGRPCDialOption(
+		grpc.WithStatsHandler(&payloadCounter{stats: cl.stats}),
+	))
 
-	// Create a default gapic client.
-	cl.gapicClient, err = vkit.NewClient(ctx, dialOpts...)
+	// Create the pool of gapic clients.
+	cl.channels, err = newChannelPool(ctx, opts, dialOpts)
 	if err != nil {
 		return nil, err
 	}
+	if opts.ReadChannels > 0 {
+		cl.readChannels, err = newDedicatedChannelPool(
+			ctx, opts, dialOpts, opts.ReadChannels, opts.ReadEndpoint)
+		if err != nil {
+			cl.close()
+			return nil, err
+		}
+	}
+	if opts.WriteChannels > 0 {
+		cl.writeChannels, err = newDedicatedChannelPool(
+			ctx, opts, dialOpts, opts.WriteChannels, opts.WriteEndpoint)
+		if err != nil {
+			cl.close()
+			return nil, err
+		}
+	}
 	return cl, nil
 }
 
+// newDedicatedChannelPool creates a pool of size channels dedicated to the
+// reads or the writes, dialed to endpoint if set. The pool has a fixed size,
+// and only fails over to Options.FailoverEndpoints without endpoint.
+func newDedicatedChannelPool(
+	ctx context.Context,
+	opts Options,
+	clientOpts []option.ClientOption,
+	size int,
+	endpoint string,
+) (*channelPool, error) {
+	opts.NumGrpcChannels = size
+	opts.MaxGrpcChannels = 0
+	if endpoint != "" {
+		opts.SpannerEndpoint = endpoint
+		opts.FailoverEndpoints = nil
+		clientOpts = append(
+			clientOpts[:len(clientOpts):len(clientOpts)],
+			option.WithEndpoint(endpoint),
+		)
+	}
+	return newChannelPool(ctx, opts, clientOpts)
+}
+
+// poolFor returns the pool of the requests routed to the leader (writes) if
+// write is set, or of the other requests (reads) otherwise: the dedicated pool
+// of the kind of request if any, or the shared one.
+func (cl *AdapterClient) poolFor(write bool) *channelPool {
+	if write && cl.writeChannels != nil {
+		return cl.writeChannels
+	}
+	if !write && cl.readChannels != nil {
+		return cl.readChannels
+	}
+	return cl.channels
+}
+
+// recordResult records the result of an AdaptMessage call on ch in the pool
+// the channel was acquired from.
+func (cl *AdapterClient) recordResult(
+	ch *grpcChannel,
+	latency time.Duration,
+	err error,
+) {
+	pool := ch.pool
+	if pool == nil {
+		pool = cl.channels
+	}
+	pool.recordResult(ch, latency, err)
+}
+
 // TODO: Export a generated client opts function from
 // google-cloud-go/spanner/adapter rather than manually constructing here
 func generatedGRPCClientOptions() []option.ClientOption {
@@ -225,9 +356,12 @@ func getAllClientOpts(
 		),
 		option.WithGRPCConnectionPool(opts.NumGrpcChannels),
 		internaloption.AllowNonDefaultServiceAccount(true),
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(markUnsentStreams)),
+		option.WithGRPCDialOption(grpc.WithDefaultCallOptions(
+			grpc.MaxCallSendMsgSize(maxSendMsgSize(opts)))),
 	}
 
-	if enableDirectPathXds, _ := strconv.ParseBool(os.Getenv("GOOGLE_SPANNER_ENABLE_DIRECT_ACCESS")); enableDirectPathXds {
+	if directAccessEnabled(opts) {
 		clientDefaultOpts = append(
 			clientDefaultOpts,
 			internaloption.EnableDirectPath(true),
@@ -244,6 +378,45 @@ func getAllClientOpts(
 			clientDefaultOpts = append(clientDefaultOpts, credOpts)
 		}
 	}
+	if opts.GrpcKeepaliveTime > 0 {
+		clientDefaultOpts = append(
+			clientDefaultOpts,
+			option.WithGRPCDialOption(grpc.WithKeepaliveParams(keepalive.ClientParameters{
+				Time:                opts.GrpcKeepaliveTime,
+				Timeout:             opts.GrpcKeepaliveTimeout,
+				PermitWithoutStream: true,
+			})),
+		)
+	}
+	if opts.GrpcMinConnectTimeout > 0 {
+		clientDefaultOpts = append(
+			clientDefaultOpts,
+			option.WithGRPCDialOption(grpc.WithConnectParams(grpc.ConnectParams{
+				Backoff:           backoff.DefaultConfig,
+				MinConnectTimeout: opts.GrpcMinConnectTimeout,
+			})),
+		)
+	}
+	switch opts.GrpcDialNetwork {
+	case "", "tcp":
+	case "tcp4", "tcp6":
+		clientDefaultOpts = append(
+			clientDefaultOpts,
+			option.WithGRPCDialOption(grpc.WithContextDialer(
+				networkDialer(opts.GrpcDialNetwork),
+			)),
+		)
+	default:
+		return nil, fmt.Errorf(
+			"invalid grpc dial network %q, expected tcp, tcp4 or tcp6",
+			opts.GrpcDialNetwork,
+		)
+	}
+	compression, err := compressionOpts(opts)
+	if err != nil {
+		return nil, err
+	}
+	clientDefaultOpts = append(clientDefaultOpts, compression...)
 	if opts.UsePlainText {
 		clientDefaultOpts = append(
 			clientDefaultOpts,
@@ -256,6 +429,31 @@ func getAllClientOpts(
 	return append(allDefaultOpts, opts.GoogleApiOpts...), nil
 }
 
+// networkDialer returns a dialer of the grpc channels connecting to the
+// addresses of network only, ie: "tcp6" to only connect to the IPv6 addresses
+// of the Spanner endpoint.
+func networkDialer(
+	network string,
+) func(ctx context.Context, addr string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// close shuts the built-in metrics exporter down and closes the channels of
+// the client.
+func (cl *AdapterClient) close() {
+	if cl.metrics != nil {
+		cl.metrics.shutdown(context.Background())
+	}
+	for _, pool := range []*channelPool{cl.channels, cl.readChannels, cl.writeChannels} {
+		if pool != nil {
+			pool.close()
+		}
+	}
+}
+
 func (cl *AdapterClient) getMetadata() metadata.MD {
 	return cl.md
 }
@@ -304,6 +502,24 @@ func (cl *AdapterClient) createSession(ctx context.Context,
 	return nil
 }
 
+// recordSessionResult tracks consecutive session refresh failures, and calls
+// onSessionFailures once MaxSessionFailures is reached.
+func (cl *AdapterClient) recordSessionResult(err error) {
+	if err == nil {
+		cl.sessionFailures.Store(0)
+		return
+	}
+	failures := cl.sessionFailures.Add(1)
+	logger.Error("Failed to refresh Spanner session",
+		zap.Int64("consecutive_failures", failures),
+		zap.Error(err))
+	if cl.opts.MaxSessionFailures > 0 &&
+		failures == int64(cl.opts.MaxSessionFailures) &&
+		cl.onSessionFailures != nil {
+		cl.onSessionFailures(err)
+	}
+}
+
 // Gets the current Adapter session that should be used for all requests.
 // Refresh the session if the current session is about to expire.
 func (cl *AdapterClient) getOrRefreshSession(
@@ -313,7 +529,9 @@ func (cl *AdapterClient) getOrRefreshSession(
 
 	if time.Now().
 		After(currentSession.createTime.Add(SessionRefreshTimeInterval)) {
-		if err := cl.createSession(ctx, cl.opts); err != nil {
+		err := cl.createSession(ctx, cl.opts)
+		cl.recordSessionResult(err)
+		if err != nil {
 			return session{}, err
 		}
 		return cl.getSession(), nil
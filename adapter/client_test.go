@@ -21,12 +21,16 @@ package adapter
 
 import (
 	"context"
+	"net"
 	"os"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/googleapis/gax-go/v2/callctx"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestGetOrRefreshSession(t *testing.T) {
@@ -105,6 +109,73 @@ func TestGetAllClientOpts(t *testing.T) {
 	clientOpts, err = getAllClientOpts(opts)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, clientOpts)
+
+	opts.ExperimentalHost = false
+	defaultOpts, err := getAllClientOpts(opts)
+	assert.NoError(t, err)
+	opts.GrpcKeepaliveTime = 30 * time.Second
+	opts.GrpcMinConnectTimeout = 5 * time.Second
+	clientOpts, err = getAllClientOpts(opts)
+	assert.NoError(t, err)
+	assert.Len(t, clientOpts, len(defaultOpts)+2)
+
+	opts.GrpcDialNetwork = "tcp6"
+	clientOpts, err = getAllClientOpts(opts)
+	assert.NoError(t, err)
+	assert.Len(t, clientOpts, len(defaultOpts)+3)
+	opts.GrpcDialNetwork = "udp"
+	_, err = getAllClientOpts(opts)
+	assert.Error(t, err)
+
+	opts.GrpcDialNetwork = ""
+	opts.GrpcCompression = "gzip"
+	clientOpts, err = getAllClientOpts(opts)
+	assert.NoError(t, err)
+	assert.Len(t, clientOpts, len(defaultOpts)+3)
+	opts.GrpcCompression = "zstd"
+	_, err = getAllClientOpts(opts)
+	assert.Error(t, err)
+}
+
+func TestNetworkDialer(t *testing.T) {
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	ctx := context.Background()
+
+	conn, err := networkDialer("tcp4")(ctx, lis.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+	// IPv4 addresses can not be dialed on tcp6.
+	_, err = networkDialer("tcp6")(ctx, lis.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestClientMetadata(t *testing.T) {
+	md := clientMetadata("projects/p/instances/i/databases/d", "uid@1@host")
+	assert.Equal(t, []string{"projects/p/instances/i/databases/d"}, md.Get(resourcePrefixHeader))
+	assert.Equal(t, []string{"uid@1@host"}, md.Get(clientUIDHeader))
+	assert.Equal(t, []string{generateClientHash("uid@1@host")}, md.Get(clientHashHeader))
+
+	ctx := contextWithOutgoingMetadata(context.Background(), md, true)
+	outgoing, ok := metadata.FromOutgoingContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"uid@1@host"}, outgoing.Get(clientUIDHeader))
+	assert.Equal(t, []string{"true"}, outgoing.Get(routeToLeaderHeader))
+	assert.Equal(
+		t,
+		[]string{"gccl/" + version},
+		callctx.HeadersFromContext(ctx)[apiClientHeader],
+	)
 }
 
 func TestCreateExperimentalHostNoCredentials(t *testing.T) {
@@ -0,0 +1,530 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"sort"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Spanner stores sets as ARRAYs, in the order their elements were written and
+// with duplicates, and maps as JSON objects, whose keys are ordered as
+// strings. Cassandra returns the elements of sets and the entries of maps
+// ordered by the comparator of their type, without duplicates, which the
+// proxy restores in the rows returned to the driver.
+
+// collectionStatements remembers the result columns of prepared statements
+// returning sets or maps, as the responses of their executions usually skip
+// the metadata.
+type collectionStatements struct {
+	cache *lru.Cache
+}
+
+func newCollectionStatements(size int) (*collectionStatements, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &collectionStatements{cache: cache}, nil
+}
+
+func (cs *collectionStatements) remember(
+	id []byte,
+	columns []*message.ColumnMetadata,
+) {
+	if cs != nil {
+		cs.cache.Add(string(id), columns)
+	}
+}
+
+func (cs *collectionStatements) lookup(id []byte) []*message.ColumnMetadata {
+	if cs == nil {
+		return nil
+	}
+	if columns, ok := cs.cache.Get(string(id)); ok {
+		return columns.([]*message.ColumnMetadata)
+	}
+	return nil
+}
+
+// isUnordered reports whether values of type t contain a set or a map.
+func isUnordered(t datatype.DataType) bool {
+	switch t := t.(type) {
+	case *datatype.Set, *datatype.Map:
+		return true
+	case *datatype.List:
+		return isUnordered(t.ElementType)
+	}
+	return false
+}
+
+func hasUnorderedColumns(columns []*message.ColumnMetadata) bool {
+	for _, column := range columns {
+		if isUnordered(column.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// rememberCollectionStatement records the result columns of the statement
+// prepared by req, if it returns sets or maps.
+func (dc *driverConnection) rememberCollectionStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	if dc.executor.collections == nil {
+		return
+	}
+	if _, ok := req.frame.Body.Message.(*message.Prepare); !ok {
+		return
+	}
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return
+	}
+	prepared, ok := frm.Body.Message.(*message.PreparedResult)
+	if !ok || prepared.ResultMetadata == nil ||
+		!hasUnorderedColumns(prepared.ResultMetadata.Columns) {
+		return
+	}
+	dc.executor.collections.remember(
+		prepared.PreparedQueryId,
+		prepared.ResultMetadata.Columns,
+	)
+}
+
+// orderCollections orders the elements of the sets and the entries of the
+// maps of the rows of the encoded response to req, as Cassandra does, and
+// removes duplicate set elements. Responses that can not be decoded, ie:
+// compressed responses, are returned unchanged.
+func (dc *driverConnection) orderCollections(
+	req *requestState,
+	encoded []byte,
+) []byte {
+	if dc.executor.collections == nil ||
+		req.frame.Header.Version < primitive.ProtocolVersion3 {
+		return encoded
+	}
+	var columns []*message.ColumnMetadata
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Execute:
+		columns = dc.executor.collections.lookup(msg.QueryId)
+		if columns == nil {
+			return encoded
+		}
+	case *message.Query:
+		if !isCQLRead(msg.Query) {
+			return encoded
+		}
+	default:
+		return encoded
+	}
+	// Most responses hold no sets or maps, which their metadata tells without
+	// decoding and encoding their rows again.
+	described, unordered, ok := peekRowsColumns(encoded)
+	if ok && ((described && !unordered) || (!described && columns == nil)) {
+		return encoded
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		rows, ok := frm.Body.Message.(*message.RowsResult)
+		if !ok {
+			return
+		}
+		if rows.Metadata != nil && len(rows.Metadata.Columns) > 0 {
+			columns = rows.Metadata.Columns
+		}
+		if !hasUnorderedColumns(columns) {
+			return
+		}
+		for _, row := range rows.Data {
+			for i, column := range columns {
+				if i < len(row) && isUnordered(column.Type) {
+					row[i] = orderCollection(column.Type, row[i])
+				}
+			}
+		}
+	})
+}
+
+// Flags of the frame header and of the metadata of ROWS results.
+const (
+	headerFlagCompression   = 0x01
+	headerFlagTracing       = 0x02
+	headerFlagCustomPayload = 0x04
+	headerFlagWarning       = 0x08
+
+	rowsFlagGlobalTablesSpec = 0x01
+	rowsFlagHasMorePages     = 0x02
+	rowsFlagNoMetadata       = 0x04
+	rowsFlagMetadataChanged  = 0x08
+)
+
+// bodyReader reads the primitives of an encoded frame body. Reads past the end
+// of the body set err and return zero values.
+type bodyReader struct {
+	b   []byte
+	err bool
+}
+
+func (r *bodyReader) next(n int) []byte {
+	if r.err || n < 0 || n > len(r.b) {
+		r.err = true
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *bodyReader) short() int {
+	if v := r.next(2); v != nil {
+		return int(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (r *bodyReader) int() int {
+	if v := r.next(4); v != nil {
+		return int(int32(binary.BigEndian.Uint32(v)))
+	}
+	return 0
+}
+
+func (r *bodyReader) skipString() {
+	r.next(r.short())
+}
+
+func (r *bodyReader) skipBytes() {
+	if n := r.int(); n > 0 {
+		r.next(n)
+	}
+}
+
+// skipTypeOption skips a type option, and reports whether values of the type
+// contain a set or a map, like isUnordered.
+func (r *bodyReader) skipTypeOption() bool {
+	switch primitive.DataTypeCode(r.short()) {
+	case primitive.DataTypeCodeCustom:
+		r.skipString()
+	case primitive.DataTypeCodeList:
+		return r.skipTypeOption()
+	case primitive.DataTypeCodeSet:
+		r.skipTypeOption()
+		return true
+	case primitive.DataTypeCodeMap:
+		r.skipTypeOption()
+		r.skipTypeOption()
+		return true
+	case primitive.DataTypeCodeUdt:
+		r.skipString()
+		r.skipString()
+		for n := r.short(); n > 0 && !r.err; n-- {
+			r.skipString()
+			r.skipTypeOption()
+		}
+	case primitive.DataTypeCodeTuple:
+		for n := r.short(); n > 0 && !r.err; n-- {
+			r.skipTypeOption()
+		}
+	}
+	return false
+}
+
+// peekRowsColumns reads the column metadata of an encoded response without
+// decoding its rows. Returns whether the response is a ROWS result describing
+// its columns, and whether any of them holds sets or maps. ok is false if the
+// response can not be read this way, ie: compressed or truncated responses.
+func peekRowsColumns(encoded []byte) (described, unordered, ok bool) {
+	if len(encoded) < 9 || encoded[0]&0x7f < byte(primitive.ProtocolVersion3) {
+		return false, false, false
+	}
+	if uint32(len(encoded)-9) < binary.BigEndian.Uint32(encoded[5:9]) {
+		return false, false, false
+	}
+	flags := encoded[1]
+	// The order of warnings and custom payloads is left to the codec.
+	if flags&headerFlagCompression != 0 ||
+		flags&(headerFlagWarning|headerFlagCustomPayload) ==
+			headerFlagWarning|headerFlagCustomPayload {
+		return false, false, false
+	}
+	if primitive.OpCode(encoded[4]) != primitive.OpCodeResult {
+		return false, false, true
+	}
+	r := &bodyReader{b: encoded[9:]}
+	if flags&headerFlagTracing != 0 {
+		r.next(16)
+	}
+	if flags&headerFlagWarning != 0 {
+		for n := r.short(); n > 0 && !r.err; n-- {
+			r.skipString()
+		}
+	}
+	if flags&headerFlagCustomPayload != 0 {
+		for n := r.short(); n > 0 && !r.err; n-- {
+			r.skipString()
+			r.skipBytes()
+		}
+	}
+	if primitive.ResultType(r.int()) != primitive.ResultTypeRows {
+		return false, false, !r.err
+	}
+	metadataFlags := r.int()
+	columns := r.int()
+	if metadataFlags&rowsFlagHasMorePages != 0 {
+		r.skipBytes()
+	}
+	if metadataFlags&rowsFlagMetadataChanged != 0 {
+		r.next(r.short())
+	}
+	if metadataFlags&rowsFlagNoMetadata != 0 {
+		return false, false, !r.err
+	}
+	if metadataFlags&rowsFlagGlobalTablesSpec != 0 {
+		r.skipString()
+		r.skipString()
+	}
+	for i := 0; i < columns && !r.err; i++ {
+		if metadataFlags&rowsFlagGlobalTablesSpec == 0 {
+			r.skipString()
+			r.skipString()
+		}
+		r.skipString()
+		if r.skipTypeOption() {
+			return true, true, !r.err
+		}
+	}
+	return true, false, !r.err
+}
+
+// orderCollection returns the encoded value of type t with its sets and maps
+// ordered. Values that can not be decoded are returned unchanged.
+func orderCollection(t datatype.DataType, value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	switch t := t.(type) {
+	case *datatype.List:
+		elements, ok := decodeCollection(value, 1)
+		if !ok {
+			return value
+		}
+		for i := range elements {
+			elements[i] = orderCollection(t.ElementType, elements[i])
+		}
+		return encodeCollection(elements, 1)
+	case *datatype.Set:
+		elements, ok := decodeCollection(value, 1)
+		if !ok {
+			return value
+		}
+		for i := range elements {
+			elements[i] = orderCollection(t.ElementType, elements[i])
+		}
+		sort.SliceStable(elements, func(i, j int) bool {
+			return compareValues(t.ElementType, elements[i], elements[j]) < 0
+		})
+		unique := elements[:0]
+		for i, element := range elements {
+			if i == 0 || compareValues(t.ElementType, unique[len(unique)-1], element) != 0 {
+				unique = append(unique, element)
+			}
+		}
+		return encodeCollection(unique, 1)
+	case *datatype.Map:
+		entries, ok := decodeCollection(value, 2)
+		if !ok {
+			return value
+		}
+		pairs := make([][2][]byte, 0, len(entries)/2)
+		for i := 0; i < len(entries); i += 2 {
+			pairs = append(pairs, [2][]byte{
+				orderCollection(t.KeyType, entries[i]),
+				orderCollection(t.ValueType, entries[i+1]),
+			})
+		}
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return compareValues(t.KeyType, pairs[i][0], pairs[j][0]) < 0
+		})
+		entries = entries[:0]
+		for _, pair := range pairs {
+			entries = append(entries, pair[0], pair[1])
+		}
+		return encodeCollection(entries, 2)
+	}
+	return value
+}
+
+// decodeCollection decodes the elements of an encoded list or set (arity 1)
+// or the alternating keys and values of an encoded map (arity 2).
+func decodeCollection(value []byte, arity int) ([][]byte, bool) {
+	if len(value) < 4 {
+		return nil, false
+	}
+	n := int32(binary.BigEndian.Uint32(value))
+	if n < 0 || int64(n)*int64(arity)*4 > int64(len(value)-4) {
+		return nil, false
+	}
+	elements := make([][]byte, 0, int(n)*arity)
+	rest := value[4:]
+	for i := 0; i < int(n)*arity; i++ {
+		if len(rest) < 4 {
+			return nil, false
+		}
+		size := int32(binary.BigEndian.Uint32(rest))
+		rest = rest[4:]
+		if size < 0 {
+			elements = append(elements, nil)
+			continue
+		}
+		if int(size) > len(rest) {
+			return nil, false
+		}
+		elements = append(elements, rest[:size:size])
+		rest = rest[size:]
+	}
+	if len(rest) > 0 {
+		return nil, false
+	}
+	return elements, true
+}
+
+func encodeCollection(elements [][]byte, arity int) []byte {
+	size := 4
+	for _, element := range elements {
+		size += 4 + len(element)
+	}
+	buf := make([]byte, 4, size)
+	binary.BigEndian.PutUint32(buf, uint32(len(elements)/arity))
+	for _, element := range elements {
+		if element == nil {
+			buf = binary.BigEndian.AppendUint32(buf, math.MaxUint32)
+			continue
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(element)))
+		buf = append(buf, element...)
+	}
+	return buf
+}
+
+// compareValues compares two encoded values of type t in the order of the
+// Cassandra comparator of t. Empty values come first. Types without a
+// specific order are compared as bytes.
+func compareValues(t datatype.DataType, a, b []byte) int {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) - len(b)
+	}
+	switch t.Code() {
+	case primitive.DataTypeCodeInt, primitive.DataTypeCodeBigint,
+		primitive.DataTypeCodeSmallint, primitive.DataTypeCodeTinyint,
+		primitive.DataTypeCodeCounter, primitive.DataTypeCodeTimestamp,
+		primitive.DataTypeCodeTime, primitive.DataTypeCodeVarint:
+		return decodeVarint(a).Cmp(decodeVarint(b))
+	case primitive.DataTypeCodeFloat:
+		if len(a) == 4 && len(b) == 4 {
+			return compareFloats(
+				float64(math.Float32frombits(binary.BigEndian.Uint32(a))),
+				float64(math.Float32frombits(binary.BigEndian.Uint32(b))),
+			)
+		}
+	case primitive.DataTypeCodeDouble:
+		if len(a) == 8 && len(b) == 8 {
+			return compareFloats(
+				math.Float64frombits(binary.BigEndian.Uint64(a)),
+				math.Float64frombits(binary.BigEndian.Uint64(b)),
+			)
+		}
+	case primitive.DataTypeCodeDecimal:
+		if x, ok := decodeDecimal(a); ok {
+			if y, ok := decodeDecimal(b); ok {
+				return x.Cmp(y)
+			}
+		}
+	case primitive.DataTypeCodeTimeuuid, primitive.DataTypeCodeUuid:
+		if c, ok := compareTimeUUIDs(a, b); ok {
+			return c
+		}
+	}
+	return bytes.Compare(a, b)
+}
+
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// decodeVarint decodes a big-endian two's complement integer.
+func decodeVarint(b []byte) *big.Int {
+	v := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return v
+}
+
+// decodeDecimal decodes a decimal, a 4 bytes scale followed by its unscaled
+// varint value.
+func decodeDecimal(b []byte) (*big.Rat, bool) {
+	if len(b) < 5 {
+		return nil, false
+	}
+	scale := int64(int32(binary.BigEndian.Uint32(b)))
+	v := new(big.Rat).SetInt(decodeVarint(b[4:]))
+	if scale >= 0 {
+		exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)
+		return v.Quo(v, new(big.Rat).SetInt(exp)), true
+	}
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(-scale), nil)
+	return v.Mul(v, new(big.Rat).SetInt(exp)), true
+}
+
+// compareTimeUUIDs compares two version 1 UUIDs by their timestamp first.
+// Returns false if either UUID is not a version 1 UUID.
+func compareTimeUUIDs(a, b []byte) (int, bool) {
+	if len(a) != 16 || len(b) != 16 || a[6]>>4 != 1 || b[6]>>4 != 1 {
+		return 0, false
+	}
+	ta, tb := uuidTimestamp(a), uuidTimestamp(b)
+	switch {
+	case ta < tb:
+		return -1, true
+	case ta > tb:
+		return 1, true
+	}
+	return bytes.Compare(a[8:], b[8:]), true
+}
+
+func uuidTimestamp(u []byte) uint64 {
+	return uint64(binary.BigEndian.Uint16(u[6:])&0x0fff)<<48 |
+		uint64(binary.BigEndian.Uint16(u[4:]))<<32 |
+		uint64(binary.BigEndian.Uint32(u[0:]))
+}
@@ -0,0 +1,259 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderCollection(t *testing.T) {
+	// Sets are ordered and deduplicated.
+	set := encodeCollection([][]byte{encodeInt(10), encodeInt(-1), encodeInt(2), encodeInt(10)}, 1)
+	assert.Equal(t,
+		encodeCollection([][]byte{encodeInt(-1), encodeInt(2), encodeInt(10)}, 1),
+		orderCollection(datatype.NewSet(datatype.Int), set),
+	)
+
+	// Maps are ordered by key.
+	m := encodeCollection([][]byte{encodeInt(10), []byte("a"), encodeInt(2), []byte("b")}, 2)
+	assert.Equal(t,
+		encodeCollection([][]byte{encodeInt(2), []byte("b"), encodeInt(10), []byte("a")}, 2),
+		orderCollection(datatype.NewMap(datatype.Int, datatype.Varchar), m),
+	)
+
+	// Lists keep their order, the sets they contain are ordered.
+	list := encodeCollection([][]byte{
+		encodeCollection([][]byte{[]byte("b"), []byte("a")}, 1),
+		encodeCollection([][]byte{[]byte("c")}, 1),
+	}, 1)
+	assert.Equal(t,
+		encodeCollection([][]byte{
+			encodeCollection([][]byte{[]byte("a"), []byte("b")}, 1),
+			encodeCollection([][]byte{[]byte("c")}, 1),
+		}, 1),
+		orderCollection(datatype.NewList(datatype.NewSet(datatype.Varchar)), list),
+	)
+
+	// Null and malformed values are returned unchanged.
+	assert.Nil(t, orderCollection(datatype.NewSet(datatype.Int), nil))
+	malformed := []byte{0, 0, 0, 5, 0}
+	assert.Equal(t, malformed, orderCollection(datatype.NewSet(datatype.Int), malformed))
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		name string
+		typ  datatype.DataType
+		a, b []byte
+	}{
+		{"bigint", datatype.Bigint, encodeBigint(-1), encodeBigint(1)},
+		{"double", datatype.Double, []byte{0xbf, 0xf0, 0, 0, 0, 0, 0, 0}, []byte{0x40, 0, 0, 0, 0, 0, 0, 0}},
+		{"decimal", datatype.Decimal, []byte{0, 0, 0, 1, 15}, []byte{0, 0, 0, 0, 2}},
+		{"varint", datatype.Varint, []byte{0xff}, []byte{0x01, 0x00}},
+		{"varchar", datatype.Varchar, []byte("a"), []byte("b")},
+		{
+			"timeuuid",
+			datatype.Timeuuid,
+			[]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+			[]byte{0, 0, 0, 0, 0, 1, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+		{"empty", datatype.Int, []byte{}, encodeInt(-5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Negative(t, compareValues(tt.typ, tt.a, tt.b))
+			assert.Positive(t, compareValues(tt.typ, tt.b, tt.a))
+			assert.Zero(t, compareValues(tt.typ, tt.a, tt.a))
+		})
+	}
+}
+
+func TestPeekRowsColumns(t *testing.T) {
+	encode := func(frm *frame.Frame) []byte {
+		frm.Header.IsResponse = true
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(frm, buf))
+		return buf.Bytes()
+	}
+	rows := func(columns ...*message.ColumnMetadata) *frame.Frame {
+		return frame.NewFrame(primitive.ProtocolVersion4, 1, &message.RowsResult{
+			Metadata: &message.RowsMetadata{
+				ColumnCount: int32(len(columns)),
+				Columns:     columns,
+				PagingState: []byte("page"),
+			},
+		})
+	}
+	column := func(name string, t datatype.DataType) *message.ColumnMetadata {
+		return &message.ColumnMetadata{Keyspace: "ks", Table: "t", Name: name, Type: t}
+	}
+
+	tests := []struct {
+		name                 string
+		encoded              []byte
+		described, unordered bool
+	}{
+		{
+			name: "scalars",
+			encoded: encode(rows(
+				column("a", datatype.Int),
+				column("b", datatype.NewList(datatype.Varchar)),
+				column("c", datatype.NewTuple(datatype.Int, datatype.NewSet(datatype.Int))),
+			)),
+			described: true,
+		},
+		{
+			name:      "set",
+			encoded:   encode(rows(column("a", datatype.Int), column("s", datatype.NewSet(datatype.Int)))),
+			described: true,
+			unordered: true,
+		},
+		{
+			name: "list of maps",
+			encoded: encode(rows(
+				column("l", datatype.NewList(datatype.NewMap(datatype.Varchar, datatype.Int))),
+			)),
+			described: true,
+			unordered: true,
+		},
+		{
+			name:    "no metadata",
+			encoded: encode(frame.NewFrame(primitive.ProtocolVersion4, 1, &message.RowsResult{Metadata: &message.RowsMetadata{ColumnCount: 1}})),
+		},
+		{
+			name:    "void",
+			encoded: encode(frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{})),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			described, unordered, ok := peekRowsColumns(tt.encoded)
+			assert.True(t, ok)
+			assert.Equal(t, tt.described, described)
+			assert.Equal(t, tt.unordered, unordered)
+		})
+	}
+
+	// Tracing ids and custom payloads are skipped.
+	frm := rows(column("s", datatype.NewSet(datatype.Int)))
+	frm.SetTracingId(&primitive.UUID{1})
+	frm.SetCustomPayload(map[string][]byte{"k": []byte("v")})
+	described, unordered, ok := peekRowsColumns(encode(frm))
+	assert.True(t, ok)
+	assert.True(t, described)
+	assert.True(t, unordered)
+
+	// Truncated responses can not be read.
+	encoded := encode(rows(column("a", datatype.Int)))
+	_, _, ok = peekRowsColumns(encoded[:len(encoded)-3])
+	assert.False(t, ok)
+}
+
+func TestOrderCollections(t *testing.T) {
+	collections, err := newCollectionStatements(10)
+	require.NoError(t, err)
+	dc := &driverConnection{
+		executor: &requestExecutor{collections: collections},
+		codec:    codec,
+	}
+	columns := []*message.ColumnMetadata{
+		{Keyspace: "ks", Table: "t", Name: "id", Type: datatype.Int},
+		{Keyspace: "ks", Table: "t", Name: "s", Type: datatype.NewSet(datatype.Int)},
+	}
+	unordered := encodeCollection([][]byte{encodeInt(2), encodeInt(1), encodeInt(2)}, 1)
+	ordered := encodeCollection([][]byte{encodeInt(1), encodeInt(2)}, 1)
+	encode := func(metadata *message.RowsMetadata) []byte {
+		response := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.RowsResult{
+			Metadata: metadata,
+			Data:     message.RowSet{{encodeInt(1), unordered}},
+		})
+		response.Header.IsResponse = true
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(response, buf))
+		return buf.Bytes()
+	}
+	data := func(encoded []byte) message.RowSet {
+		frm, err := codec.DecodeFrame(bytes.NewBuffer(encoded))
+		require.NoError(t, err)
+		return frm.Body.Message.(*message.RowsResult).Data
+	}
+
+	// Queries are ordered from the metadata of their response.
+	req := &requestState{frame: *frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Query{Query: "SELECT * FROM t"},
+	)}
+	withMetadata := encode(&message.RowsMetadata{ColumnCount: 2, Columns: columns})
+	assert.Equal(t, ordered, data(dc.orderCollections(req, withMetadata))[0][1])
+
+	// Executions are ordered from the columns remembered when the statement
+	// was prepared.
+	req = &requestState{frame: *frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Prepare{Query: "SELECT * FROM t"},
+	)}
+	prepared := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.PreparedResult{
+		PreparedQueryId:   []byte("id"),
+		VariablesMetadata: &message.VariablesMetadata{},
+		ResultMetadata:    &message.RowsMetadata{ColumnCount: 2, Columns: columns},
+	})
+	prepared.Header.IsResponse = true
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(prepared, buf))
+	dc.rememberCollectionStatement(req, buf.Bytes())
+
+	withoutMetadata := encode(&message.RowsMetadata{ColumnCount: 2})
+	req = &requestState{frame: *frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Execute{QueryId: []byte("id")},
+	)}
+	assert.Equal(t, ordered, data(dc.orderCollections(req, withoutMetadata))[0][1])
+
+	// Unknown statements are returned unchanged.
+	req = &requestState{frame: *frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Execute{QueryId: []byte("other")},
+	)}
+	assert.Equal(t, withoutMetadata, dc.orderCollections(req, withoutMetadata))
+
+	// Responses without sets or maps are returned unchanged, without being
+	// encoded again.
+	req = &requestState{frame: *frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Query{Query: "SELECT * FROM t"},
+	)}
+	scalar := encode(&message.RowsMetadata{ColumnCount: 2, Columns: []*message.ColumnMetadata{
+		{Keyspace: "ks", Table: "t", Name: "id", Type: datatype.Int},
+		{Keyspace: "ks", Table: "t", Name: "l", Type: datatype.NewList(datatype.Varchar)},
+	}})
+	got := dc.orderCollections(req, scalar)
+	assert.Equal(t, scalar, got)
+	assert.Same(t, &scalar[0], &got[0])
+
+	// Responses are returned unchanged with DisableCollectionOrdering.
+	dc.executor.collections = nil
+	req = &requestState{frame: *frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Query{Query: "SELECT * FROM t"},
+	)}
+	assert.Equal(t, withMetadata, dc.orderCollections(req, withMetadata))
+}
@@ -0,0 +1,444 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// ColumnRef identifies a column, with its names lower cased.
+type ColumnRef struct {
+	Keyspace string
+	Table    string
+	Name     string
+}
+
+// ColumnCodec transforms the values of the columns it is configured for in
+// Options.ColumnCodecs, ie: to encrypt them or to convert a legacy packed
+// format. Values are in the encoding of the CQL type of the column, and null
+// values are not transformed. Implementations must be safe for concurrent
+// use.
+type ColumnCodec interface {
+	// Encode transforms a value bound to column by a driver before it is sent
+	// to Spanner.
+	Encode(column ColumnRef, value []byte) ([]byte, error)
+	// Decode transforms a value of column read from Spanner before it is
+	// returned to the driver.
+	Decode(column ColumnRef, value []byte) ([]byte, error)
+}
+
+var (
+	columnCodecsMu   sync.RWMutex
+	registeredCodecs = make(map[string]ColumnCodec)
+)
+
+// Error message of the unprepared statements writing columns with codecs.
+const unpreparedCodecWrite = "Statements writing the columns of Options.ColumnCodecs must be prepared"
+
+// RegisterColumnCodec makes a column codec available by name through
+// Options.ColumnCodecs. RegisterColumnCodec panics if codec is nil or if a
+// codec with the same name is already registered, it is typically called from
+// the init function of the package implementing the codec.
+func RegisterColumnCodec(name string, codec ColumnCodec) {
+	if codec == nil {
+		panic("adapter: RegisterColumnCodec codec is nil")
+	}
+	columnCodecsMu.Lock()
+	defer columnCodecsMu.Unlock()
+	if _, dup := registeredCodecs[name]; dup {
+		panic("adapter: RegisterColumnCodec called twice for codec " + name)
+	}
+	registeredCodecs[name] = codec
+}
+
+// LookupColumnCodec returns the column codec registered under name.
+func LookupColumnCodec(name string) (ColumnCodec, bool) {
+	columnCodecsMu.RLock()
+	defer columnCodecsMu.RUnlock()
+	codec, ok := registeredCodecs[name]
+	return codec, ok
+}
+
+// ColumnCodecs returns the sorted names of the registered column codecs.
+func ColumnCodecs() []string {
+	columnCodecsMu.RLock()
+	defer columnCodecsMu.RUnlock()
+	names := make([]string, 0, len(registeredCodecs))
+	for name := range registeredCodecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// boundCodec is the codec of a column of a statement.
+type boundCodec struct {
+	codec  ColumnCodec
+	column ColumnRef
+}
+
+// codecStatement holds the codecs of the variables and result columns of a
+// prepared statement, nil for the columns without codec.
+type codecStatement struct {
+	variables []*boundCodec
+	names     []string
+	results   []*boundCodec
+}
+
+// columnCodecs holds the codecs of the columns of Options.ColumnCodecs, and
+// the codecs of the columns of every prepared statement.
+type columnCodecs struct {
+	// Codecs by lower cased "keyspace.table.column" or "table.column".
+	codecs map[string]ColumnCodec
+	// Lower cased tables of the columns, ie: "keyspace.table" or "table".
+	tables     []string
+	statements *lru.Cache
+}
+
+func newColumnCodecs(columns map[string]string, size int) (*columnCodecs, error) {
+	cc := &columnCodecs{codecs: make(map[string]ColumnCodec, len(columns))}
+	for column, name := range columns {
+		column = strings.ToLower(column)
+		i := strings.LastIndex(column, ".")
+		if i <= 0 || strings.Count(column, ".") > 2 {
+			return nil, fmt.Errorf(
+				"invalid codec column %q, expected keyspace.table.column or table.column",
+				column,
+			)
+		}
+		codec, ok := LookupColumnCodec(name)
+		if !ok {
+			return nil, fmt.Errorf(
+				"unknown codec %q of column %s, registered codecs: %v",
+				name,
+				column,
+				ColumnCodecs(),
+			)
+		}
+		cc.codecs[column] = codec
+		cc.tables = append(cc.tables, column[:i])
+	}
+	var err error
+	if cc.statements, err = lru.New(size); err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// bind returns the codecs of columns, or nil if none of them has a codec.
+func (cc *columnCodecs) bind(columns []*message.ColumnMetadata) []*boundCodec {
+	var codecs []*boundCodec
+	for i, column := range columns {
+		ref := ColumnRef{
+			Keyspace: strings.ToLower(column.Keyspace),
+			Table:    strings.ToLower(column.Table),
+			Name:     strings.ToLower(column.Name),
+		}
+		codec, ok := cc.codecs[ref.Keyspace+"."+ref.Table+"."+ref.Name]
+		if !ok {
+			codec, ok = cc.codecs[ref.Table+"."+ref.Name]
+		}
+		if !ok {
+			continue
+		}
+		if codecs == nil {
+			codecs = make([]*boundCodec, len(columns))
+		}
+		codecs[i] = &boundCodec{codec: codec, column: ref}
+	}
+	return codecs
+}
+
+// hasTable reports whether table, qualified by its keyspace if known, has
+// columns with codecs. Unqualified tables match the tables of any keyspace.
+func (cc *columnCodecs) hasTable(table string) bool {
+	name := table[strings.LastIndex(table, ".")+1:]
+	for _, t := range cc.tables {
+		if t == table {
+			return true
+		}
+		if t[strings.LastIndex(t, ".")+1:] == name &&
+			(!strings.Contains(t, ".") || !strings.Contains(table, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayRead reports whether a query may read a column with a codec, to skip
+// decoding the responses of the others.
+func (cc *columnCodecs) mayRead(query string) bool {
+	if !isCQLRead(query) {
+		return false
+	}
+	query = strings.ToLower(query)
+	for _, table := range cc.tables {
+		if strings.Contains(query, table[strings.LastIndex(table, ".")+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// writesUnprepared reports whether an unprepared statement writes a table
+// with codecs, whose values can not be encoded without the metadata of a
+// prepared statement.
+func (cc *columnCodecs) writesUnprepared(query, keyspace string) bool {
+	kind, table, ok := parseDMLTarget(query)
+	if !ok || kind == "delete" {
+		return false
+	}
+	if !strings.Contains(table, ".") && keyspace != "" {
+		table = strings.ToLower(keyspace) + "." + table
+	}
+	return cc.hasTable(table)
+}
+
+func (cc *columnCodecs) lookup(id []byte) (*codecStatement, bool) {
+	stmt, ok := cc.statements.Get(string(id))
+	if !ok {
+		return nil, false
+	}
+	return stmt.(*codecStatement), true
+}
+
+// tryEncodeColumns encodes the values bound by req to the columns of
+// Options.ColumnCodecs. Returns an Invalid error message if a value can not be
+// encoded or if an unprepared statement writes a table with codecs, and an
+// Unprepared error message for the statements prepared before the codecs of
+// their columns were known.
+func (dc *driverConnection) tryEncodeColumns(req *requestState) message.Message {
+	cc := dc.executor.columnCodecs
+	if cc == nil {
+		return nil
+	}
+	var translated message.Message
+	var err error
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Query:
+		if cc.writesUnprepared(msg.Query, dc.keyspace) {
+			return &message.Invalid{ErrorMessage: unpreparedCodecWrite}
+		}
+	case *message.Execute:
+		stmt, ok := cc.lookup(msg.QueryId)
+		if !ok {
+			return &message.Unprepared{
+				ErrorMessage: "Unknown column codecs of prepared query in client side cache",
+				Id:           msg.QueryId,
+			}
+		}
+		if stmt.variables == nil || msg.Options == nil {
+			return nil
+		}
+		var options *message.QueryOptions
+		if options, err = stmt.encode(msg.Options); err == nil {
+			execute := *msg
+			execute.Options = options
+			translated = &execute
+		}
+	case *message.Batch:
+		var children []*message.BatchChild
+		for i, child := range msg.Children {
+			if child.Query != "" {
+				if cc.writesUnprepared(child.Query, dc.keyspace) {
+					return &message.Invalid{ErrorMessage: unpreparedCodecWrite}
+				}
+				continue
+			}
+			stmt, ok := cc.lookup(child.Id)
+			if !ok {
+				return &message.Unprepared{
+					ErrorMessage: "Unknown column codecs of prepared query in client side cache",
+					Id:           child.Id,
+				}
+			}
+			if stmt.variables == nil {
+				continue
+			}
+			var values []*primitive.Value
+			if values, err = stmt.encodeValues(child.Values); err != nil {
+				break
+			}
+			if children == nil {
+				children = append([]*message.BatchChild(nil), msg.Children...)
+			}
+			children[i] = &message.BatchChild{Id: child.Id, Values: values}
+		}
+		if err == nil && children != nil {
+			batch := *msg
+			batch.Children = children
+			translated = &batch
+		}
+	}
+	if err != nil {
+		return &message.Invalid{ErrorMessage: err.Error()}
+	}
+	if translated == nil {
+		return nil
+	}
+	return dc.replaceMessage(req, translated)
+}
+
+// encode returns a copy of options with the values bound to columns with
+// codecs encoded.
+func (stmt *codecStatement) encode(
+	options *message.QueryOptions,
+) (*message.QueryOptions, error) {
+	encoded := *options
+	if len(options.NamedValues) > 0 {
+		encoded.NamedValues = make(map[string]*primitive.Value, len(options.NamedValues))
+		for name, value := range options.NamedValues {
+			encoded.NamedValues[name] = value
+			for i, variable := range stmt.names {
+				if stmt.variables[i] == nil || !strings.EqualFold(variable, name) {
+					continue
+				}
+				v, err := stmt.variables[i].encode(value)
+				if err != nil {
+					return nil, err
+				}
+				encoded.NamedValues[name] = v
+				break
+			}
+		}
+		return &encoded, nil
+	}
+	values, err := stmt.encodeValues(options.PositionalValues)
+	if err != nil {
+		return nil, err
+	}
+	encoded.PositionalValues = values
+	return &encoded, nil
+}
+
+// encodeValues returns a copy of the positional values with the values bound
+// to columns with codecs encoded.
+func (stmt *codecStatement) encodeValues(
+	values []*primitive.Value,
+) ([]*primitive.Value, error) {
+	encoded := append([]*primitive.Value(nil), values...)
+	for i, value := range values {
+		if i >= len(stmt.variables) || stmt.variables[i] == nil {
+			continue
+		}
+		v, err := stmt.variables[i].encode(value)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = v
+	}
+	return encoded, nil
+}
+
+func (bc *boundCodec) encode(value *primitive.Value) (*primitive.Value, error) {
+	if value == nil || value.Type != primitive.ValueTypeRegular {
+		return value, nil
+	}
+	encoded, err := bc.codec.Encode(bc.column, value.Contents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the value of %s: %w", bc.column.Name, err)
+	}
+	return primitive.NewValue(encoded), nil
+}
+
+// decodeColumns remembers the codecs of the columns of the statements
+// prepared by req, and decodes the values of the columns with codecs of the
+// rows of the encoded response to req. Rows failing to decode are answered
+// with a server error instead.
+func (dc *driverConnection) decodeColumns(
+	req *requestState,
+	encoded []byte,
+) []byte {
+	cc := dc.executor.columnCodecs
+	if cc == nil {
+		return encoded
+	}
+	var results []*boundCodec
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Prepare:
+		frm, err := dc.decodeFrame(encoded)
+		if err != nil {
+			return encoded
+		}
+		prepared, ok := frm.Body.Message.(*message.PreparedResult)
+		if !ok {
+			return encoded
+		}
+		stmt := &codecStatement{}
+		if prepared.VariablesMetadata != nil {
+			columns := prepared.VariablesMetadata.Columns
+			if stmt.variables = cc.bind(columns); stmt.variables != nil {
+				stmt.names = make([]string, len(columns))
+				for i, column := range columns {
+					stmt.names[i] = column.Name
+				}
+			}
+		}
+		if prepared.ResultMetadata != nil {
+			stmt.results = cc.bind(prepared.ResultMetadata.Columns)
+		}
+		cc.statements.Add(string(prepared.PreparedQueryId), stmt)
+		return encoded
+	case *message.Execute:
+		stmt, ok := cc.lookup(msg.QueryId)
+		if !ok || stmt.results == nil {
+			return encoded
+		}
+		results = stmt.results
+	case *message.Query:
+		if !cc.mayRead(msg.Query) {
+			return encoded
+		}
+	default:
+		return encoded
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		rows, ok := frm.Body.Message.(*message.RowsResult)
+		if !ok {
+			return
+		}
+		if rows.Metadata != nil && len(rows.Metadata.Columns) > 0 {
+			results = cc.bind(rows.Metadata.Columns)
+		}
+		for _, row := range rows.Data {
+			for i, bc := range results {
+				if bc == nil || i >= len(row) || row[i] == nil {
+					continue
+				}
+				value, err := bc.codec.Decode(bc.column, row[i])
+				if err != nil {
+					frm.Header.OpCode = primitive.OpCodeError
+					frm.Body.Message = &message.ServerError{ErrorMessage: fmt.Sprintf(
+						"failed to decode the value of %s: %v",
+						bc.column.Name,
+						err,
+					)}
+					return
+				}
+				row[i] = value
+			}
+		}
+	})
+}
@@ -0,0 +1,174 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// prefixCodec prefixes the values it encodes with the name of their column,
+// and fails to decode values without prefix.
+type prefixCodec struct{}
+
+func (c *prefixCodec) Encode(column ColumnRef, value []byte) ([]byte, error) {
+	return append([]byte(column.Name+":"), value...), nil
+}
+
+func (c *prefixCodec) Decode(column ColumnRef, value []byte) ([]byte, error) {
+	prefix := []byte(column.Name + ":")
+	if !bytes.HasPrefix(value, prefix) {
+		return nil, errors.New("missing prefix")
+	}
+	return value[len(prefix):], nil
+}
+
+func init() {
+	RegisterColumnCodec("test-prefix", &prefixCodec{})
+}
+
+func TestRegisterColumnCodec(t *testing.T) {
+	codec, ok := LookupColumnCodec("test-prefix")
+	assert.True(t, ok)
+	assert.IsType(t, &prefixCodec{}, codec)
+	assert.Contains(t, ColumnCodecs(), "test-prefix")
+
+	assert.Panics(t, func() { RegisterColumnCodec("test-prefix", &prefixCodec{}) })
+	assert.Panics(t, func() { RegisterColumnCodec("test-nil", nil) })
+
+	_, err := newColumnCodecs(map[string]string{"ks.t.c": "unknown"}, 10)
+	assert.ErrorContains(t, err, "test-prefix")
+	_, err = newColumnCodecs(map[string]string{"c": "test-prefix"}, 10)
+	assert.Error(t, err)
+}
+
+func TestColumnCodecs(t *testing.T) {
+	cc, err := newColumnCodecs(map[string]string{"ks.users.secret": "test-prefix"}, 10)
+	require.NoError(t, err)
+	dc := &driverConnection{
+		executor: &requestExecutor{columnCodecs: cc},
+		codec:    codec,
+		keyspace: "ks",
+	}
+	columns := []*message.ColumnMetadata{
+		{Keyspace: "ks", Table: "users", Name: "id", Type: datatype.Int},
+		{Keyspace: "ks", Table: "users", Name: "secret", Type: datatype.Varchar},
+	}
+	encode := func(msg message.Message) []byte {
+		response := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+		response.Header.IsResponse = true
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(response, buf))
+		return buf.Bytes()
+	}
+	decode := func(encoded []byte) message.Message {
+		frm, err := codec.DecodeFrame(bytes.NewBuffer(encoded))
+		require.NoError(t, err)
+		return frm.Body.Message
+	}
+	newRequest := func(msg message.Message) *requestState {
+		frm := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(frm, buf))
+		return &requestState{
+			pb:    &adapterpb.AdaptMessageRequest{Payload: buf.Bytes()},
+			frame: *frm,
+		}
+	}
+
+	// Executions of statements not prepared through the proxy are answered
+	// with an Unprepared error, so that the driver prepares them again.
+	req := newRequest(&message.Execute{QueryId: []byte("id"), Options: &message.QueryOptions{}})
+	assert.IsType(t, &message.Unprepared{}, dc.tryEncodeColumns(req))
+
+	// The codecs of prepared statements are remembered.
+	req = newRequest(&message.Prepare{Query: "INSERT INTO users (id, secret) VALUES (?, ?)"})
+	prepared := encode(&message.PreparedResult{
+		PreparedQueryId:   []byte("id"),
+		VariablesMetadata: &message.VariablesMetadata{Columns: columns},
+		ResultMetadata:    &message.RowsMetadata{},
+	})
+	assert.Equal(t, prepared, dc.decodeColumns(req, prepared))
+
+	// Their bound values are encoded, including in batches.
+	req = newRequest(&message.Execute{
+		QueryId: []byte("id"),
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{
+			primitive.NewValue(encodeInt(1)),
+			primitive.NewValue([]byte("a")),
+		}},
+	})
+	require.Nil(t, dc.tryEncodeColumns(req))
+	execute := decode(req.pb.Payload).(*message.Execute)
+	assert.Equal(t, encodeInt(1), execute.Options.PositionalValues[0].Contents)
+	assert.Equal(t, []byte("secret:a"), execute.Options.PositionalValues[1].Contents)
+
+	req = newRequest(&message.Batch{Children: []*message.BatchChild{
+		{Id: []byte("id"), Values: []*primitive.Value{
+			primitive.NewValue(encodeInt(1)),
+			primitive.NewNullValue(),
+		}},
+	}})
+	require.Nil(t, dc.tryEncodeColumns(req))
+	batch := decode(req.pb.Payload).(*message.Batch)
+	assert.Equal(t, primitive.ValueTypeNull, batch.Children[0].Values[1].Type)
+
+	// Unprepared writes of the table are rejected, reads and deletes are not.
+	for query, rejected := range map[string]bool{
+		"INSERT INTO users (id, secret) VALUES (1, 'a')": true,
+		"UPDATE ks.users SET secret = 'a' WHERE id = 1":  true,
+		"DELETE FROM users WHERE id = 1":                 false,
+		"INSERT INTO other.users (id) VALUES (1)":        false,
+		"SELECT * FROM users":                            false,
+	} {
+		errMsg := dc.tryEncodeColumns(newRequest(&message.Query{Query: query}))
+		if rejected {
+			assert.IsType(t, &message.Invalid{}, errMsg, query)
+		} else {
+			assert.Nil(t, errMsg, query)
+		}
+	}
+
+	// Rows are decoded, and rows failing to decode are answered with a server
+	// error.
+	req = newRequest(&message.Query{Query: "SELECT * FROM users"})
+	rows := decode(dc.decodeColumns(req, encode(&message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 2, Columns: columns},
+		Data:     message.RowSet{{encodeInt(1), []byte("secret:a")}, {encodeInt(2), nil}},
+	}))).(*message.RowsResult)
+	assert.Equal(t, []byte("a"), rows.Data[0][1])
+	assert.Nil(t, rows.Data[1][1])
+
+	errMsg := decode(dc.decodeColumns(req, encode(&message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 2, Columns: columns},
+		Data:     message.RowSet{{encodeInt(1), []byte("a")}},
+	})))
+	require.IsType(t, &message.ServerError{}, errMsg)
+	assert.Contains(t, errMsg.(*message.ServerError).ErrorMessage, "missing prefix")
+}
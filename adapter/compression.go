@@ -0,0 +1,80 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding/gzip"
+	grpcstats "google.golang.org/grpc/stats"
+)
+
+// compressionOpts returns the client options compressing the messages of the
+// gRPC calls with Options.GrpcCompression.
+func compressionOpts(opts Options) ([]option.ClientOption, error) {
+	switch opts.GrpcCompression {
+	case "", "none":
+		return nil, nil
+	case gzip.Name:
+		return []option.ClientOption{
+			option.WithGRPCDialOption(grpc.WithDefaultCallOptions(
+				grpc.UseCompressor(gzip.Name),
+			)),
+		}, nil
+	default:
+		return nil, fmt.Errorf(
+			"invalid grpc compression %q, expected none or gzip",
+			opts.GrpcCompression,
+		)
+	}
+}
+
+// payloadCounter counts the bytes of the messages of the gRPC calls of a
+// client, before and after compression, to evaluate GrpcCompression.
+type payloadCounter struct {
+	stats *proxyStats
+}
+
+func (pc *payloadCounter) TagRPC(
+	ctx context.Context,
+	_ *grpcstats.RPCTagInfo,
+) context.Context {
+	return ctx
+}
+
+func (pc *payloadCounter) HandleRPC(_ context.Context, s grpcstats.RPCStats) {
+	switch s := s.(type) {
+	case *grpcstats.OutPayload:
+		pc.stats.grpcBytesSent.Add(int64(s.Length))
+		pc.stats.grpcCompressedBytesSent.Add(int64(s.CompressedLength))
+	case *grpcstats.InPayload:
+		pc.stats.grpcBytesReceived.Add(int64(s.Length))
+		pc.stats.grpcCompressedBytesReceived.Add(int64(s.CompressedLength))
+	}
+}
+
+func (pc *payloadCounter) TagConn(
+	ctx context.Context,
+	_ *grpcstats.ConnTagInfo,
+) context.Context {
+	return ctx
+}
+
+func (pc *payloadCounter) HandleConn(context.Context, grpcstats.ConnStats) {}
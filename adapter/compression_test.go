@@ -0,0 +1,44 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	grpcstats "google.golang.org/grpc/stats"
+)
+
+func TestPayloadCounter(t *testing.T) {
+	stats := newProxyStats()
+	pc := &payloadCounter{stats: stats}
+	ctx := context.Background()
+	pc.HandleRPC(ctx, &grpcstats.OutPayload{Length: 1000, CompressedLength: 100})
+	pc.HandleRPC(ctx, &grpcstats.OutPayload{Length: 10, CompressedLength: 10})
+	pc.HandleRPC(ctx, &grpcstats.InPayload{Length: 500, CompressedLength: 50})
+	pc.HandleRPC(ctx, &grpcstats.Begin{})
+
+	snapshot := stats.snapshot()
+	assert.Equal(t, int64(1010), snapshot.GrpcBytesSent)
+	assert.Equal(t, int64(110), snapshot.GrpcCompressedBytesSent)
+	assert.Equal(t, int64(500), snapshot.GrpcBytesReceived)
+	assert.Equal(t, int64(50), snapshot.GrpcCompressedBytesReceived)
+}
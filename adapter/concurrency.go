@@ -0,0 +1,155 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Initial and minimum limits of the adaptive concurrency limiter.
+	initialConcurrencyLimit = 20
+	minConcurrencyLimit     = 4
+	// Default Options.MaxConcurrencyLimit.
+	defaultMaxConcurrencyLimit = 1000
+	// Number of latency samples averaged into the long term latency.
+	concurrencyLongWindow = 600
+	// Ratio of the long term latency the latency of a request may reach
+	// before the limit decreases.
+	concurrencyTolerance = 1.5
+	// Weight of a new limit in the limit.
+	concurrencySmoothing = 0.2
+)
+
+// concurrencyLimiter bounds the requests sent concurrently to Spanner by a
+// client with a gradient algorithm: the limit shrinks as the latencies of
+// requests rise above their long term average, and grows while they stay
+// close to it. Requests over the limit are answered with Overloaded errors,
+// so that drivers back off or try another host in place of queueing behind
+// slow requests.
+type concurrencyLimiter struct {
+	mu       sync.Mutex
+	limit    float64
+	max      float64
+	inflight int
+	// Exponential moving average of the latencies of requests, in
+	// nanoseconds.
+	longRTT float64
+}
+
+func newConcurrencyLimiter(opts Options) *concurrencyLimiter {
+	if !opts.AdaptiveConcurrency {
+		return nil
+	}
+	limit := opts.MaxConcurrencyLimit
+	if limit <= 0 {
+		limit = defaultMaxConcurrencyLimit
+	}
+	return &concurrencyLimiter{
+		limit: min(initialConcurrencyLimit, float64(limit)),
+		max:   float64(max(limit, minConcurrencyLimit)),
+	}
+}
+
+// acquire admits a request, unless the requests in flight reached the limit.
+func (l *concurrencyLimiter) acquire() bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight >= int(l.limit) {
+		return false
+	}
+	l.inflight++
+	return true
+}
+
+// release records the completion of an admitted request, which took rtt and
+// failed with err, and updates the limit.
+func (l *concurrencyLimiter) release(rtt time.Duration, err error) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	inflight := l.inflight
+	l.inflight--
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.DeadlineExceeded, codes.Unavailable:
+		// Spanner is overloaded: back off right away.
+		l.update(l.limit / 2)
+		return
+	}
+	if err != nil || rtt <= 0 {
+		return
+	}
+	sample := float64(rtt)
+	if l.longRTT == 0 {
+		l.longRTT = sample
+	} else {
+		l.longRTT += (sample - l.longRTT) / concurrencyLongWindow
+	}
+	// Let the long term latency follow a latency drop, ie: once an overload
+	// ends, faster than its window, so that it isn't left inflated by the
+	// overload and hiding the next one.
+	if l.longRTT > 2*sample {
+		l.longRTT *= 0.95
+	}
+	// The limit only grows while it is used, ie: not while a few connections
+	// are served.
+	if float64(inflight) < l.limit/2 && sample <= l.longRTT {
+		return
+	}
+	gradient := max(0.5, min(1, concurrencyTolerance*l.longRTT/sample))
+	l.update(l.limit*gradient + math.Sqrt(l.limit))
+}
+
+func (l *concurrencyLimiter) update(limit float64) {
+	limit = l.limit*(1-concurrencySmoothing) + limit*concurrencySmoothing
+	l.limit = max(minConcurrencyLimit, min(l.max, limit))
+}
+
+// currentLimit returns the current limit, or 0 if l is nil.
+func (l *concurrencyLimiter) currentLimit() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// admitRequest admits a request to Spanner with Options.AdaptiveConcurrency.
+// Returns the function recording its completion, or an Overloaded error if
+// it is over the limit.
+func (dc *driverConnection) admitRequest() (func(time.Duration, error), message.Message) {
+	limiter := dc.adapterClient.limiter
+	if !limiter.acquire() {
+		dc.adapterClient.stats.recordConcurrencyLimited()
+		return nil, &message.Overloaded{
+			ErrorMessage: "Too many concurrent requests to Spanner, retry later",
+		}
+	}
+	return limiter.release, nil
+}
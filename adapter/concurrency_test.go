@@ -0,0 +1,97 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	assert.Nil(t, newConcurrencyLimiter(Options{}))
+	l := newConcurrencyLimiter(Options{AdaptiveConcurrency: true, MaxConcurrencyLimit: 100})
+	require.NotNil(t, l)
+	assert.Equal(t, initialConcurrencyLimit, l.currentLimit())
+
+	// Requests over the limit are rejected.
+	for i := 0; i < initialConcurrencyLimit; i++ {
+		require.True(t, l.acquire())
+	}
+	assert.False(t, l.acquire())
+
+	// The limit grows while the latencies are stable and the limit is used.
+	for i := 0; i < 50; i++ {
+		l.release(10*time.Millisecond, nil)
+		require.True(t, l.acquire())
+	}
+	grown := l.currentLimit()
+	assert.Greater(t, grown, initialConcurrencyLimit)
+	assert.LessOrEqual(t, grown, 100)
+
+	// The limit shrinks as the latencies rise, while the requests in flight
+	// complete.
+	for i := 0; i < 20; i++ {
+		l.release(100*time.Millisecond, nil)
+	}
+	assert.Less(t, l.currentLimit(), grown)
+
+	// The long term latency follows a latency drop faster than its window.
+	longRTT := l.longRTT
+	require.True(t, l.acquire())
+	l.release(time.Millisecond, nil)
+	assert.Less(t, l.longRTT, longRTT-(longRTT-float64(time.Millisecond))/concurrencyLongWindow)
+
+	// Overload errors halve the limit, down to the minimum limit.
+	for i := 0; i < 50; i++ {
+		l.release(0, status.Error(codes.ResourceExhausted, "overloaded"))
+		l.acquire()
+	}
+	assert.Equal(t, minConcurrencyLimit, l.currentLimit())
+}
+
+func TestAdmitRequest(t *testing.T) {
+	dc := &driverConnection{adapterClient: &AdapterClient{
+		stats: newProxyStats(),
+		limiter: &concurrencyLimiter{
+			limit: minConcurrencyLimit,
+			max:   minConcurrencyLimit,
+		},
+	}}
+	for i := 0; i < minConcurrencyLimit; i++ {
+		release, errMsg := dc.admitRequest()
+		require.Nil(t, errMsg)
+		require.NotNil(t, release)
+	}
+	_, errMsg := dc.admitRequest()
+	assert.IsType(t, &message.Overloaded{}, errMsg)
+	assert.Equal(t, int64(1), dc.adapterClient.stats.concurrencyLimited.Load())
+
+	// Connections are admitted without a limiter.
+	dc.adapterClient.limiter = nil
+	release, errMsg := dc.admitRequest()
+	assert.Nil(t, errMsg)
+	release(time.Millisecond, nil)
+}
@@ -20,14 +20,19 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/googleapis/go-spanner-cassandra/adapter/frameutil"
 	"github.com/googleapis/go-spanner-cassandra/logger"
 
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/metadata"
 )
@@ -42,45 +47,87 @@ type driverConnection struct {
 	globalState   *globalState
 	md            metadata.MD
 	codec         frame.Codec
-	rawCodec      frame.RawCodec
+	// Keyspace selected by the last USE statement on this connection.
+	keyspace string
+	// Labels forwarded as metadata headers with every request of this
+	// connection.
+	labels map[string]string
+	// Time the response of the last DML request of this connection was
+	// written, only tracked with Options.ReadYourWrites.
+	lastWrite time.Time
+	// Explicit transaction in progress, nil outside of BEGIN TRANSACTION and
+	// COMMIT.
+	transaction *transaction
+	// Whether the driver set the THROW_ON_OVERLOAD STARTUP option, to be
+	// answered with Overloaded errors instead of waiting for Spanner to accept
+	// its requests.
+	throwOnOverload bool
+	// Retry budget of the connection, nil unless Options.RetryBudgetRatio is
+	// set.
+	retryBudget *retryBudget
+	// Timer closing the connection unless it completes its handshake within
+	// Options.HandshakeTimeout, nil once stopped.
+	handshakeMu    sync.Mutex
+	handshakeTimer *time.Timer
+	// Time the connection was accepted, zero once its handshake completed.
+	handshakeStart time.Time
+	// Function releasing the handshake slot of the connection with
+	// Options.MaxConcurrentHandshakes, nil once released.
+	releaseHandshake func()
+	// State of the connection reported by TCPProxy.ConnectionReport.
+	activity *connectionActivity
 }
 
-func (dc *driverConnection) constructPayload() (*[]byte, *frame.Header, error) {
-	// Decode cassandra frame to Header + raw body.
-	rawFrame, err := dc.rawCodec.DecodeRawFrame(dc.driverConn)
-	if err != nil {
-		return nil, nil, err
+// firstReadTimer records when the first bytes are read from a reader, which
+// excludes the time a connection is idle from the time spent reading a frame.
+type firstReadTimer struct {
+	io.Reader
+	first time.Time
+}
+
+func (r *firstReadTimer) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if n > 0 && r.first.IsZero() {
+		r.first = time.Now()
 	}
+	return n, err
+}
 
-	rawHeader := bytes.NewBuffer(nil)
-	if err := dc.rawCodec.EncodeHeader(rawFrame.Header, rawHeader); err != nil {
-		return nil, nil, err
+// constructPayload reads the next frame from the driver connection. Returns
+// the time its first bytes were read along with the frame.
+func (dc *driverConnection) constructPayload() (*[]byte, *frame.Header, time.Time, error) {
+	reader := &firstReadTimer{Reader: dc.driverConn}
+	// Decode cassandra frame to Header + raw body.
+	header, payload, err := frameutil.DecodeRawFrameWithLimit(
+		reader,
+		dc.executor.opts.MaxFrameSize,
+	)
+	if err != nil {
+		return nil, nil, time.Time{}, err
 	}
+	return &payload, header, reader.first, nil
+}
 
-	// Assemble payload.
-	body := rawFrame.Body
-	payload := append(rawHeader.Bytes(), body...)
-	return &payload, rawFrame.Header, nil
+// decodeFrame decodes an encoded frame. Malformed frames are reported as
+// errors, including those the codec fails on with a panic.
+func (dc *driverConnection) decodeFrame(encoded []byte) (frm *frame.Frame, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			frm, err = nil, fmt.Errorf("malformed frame: %v", r)
+		}
+	}()
+	return dc.codec.DecodeFrame(bytes.NewBuffer(encoded))
 }
 
 func (dc *driverConnection) writeMessageBackToTcp(
 	header *frame.Header,
 	msg message.Message,
 ) error {
-	header.IsResponse = true
-	header.OpCode = msg.GetOpCode()
-	// Clear all flags in manually constructed error response
-	header.Flags = 0
-	frm := &frame.Frame{
-		Header: header,
-		Body:   &frame.Body{Message: msg},
-	}
-	buf := bytes.NewBuffer(nil)
-	err := dc.codec.EncodeFrame(frm, buf)
+	encoded, err := frameutil.BuildErrorFrame(header, msg)
 	if err != nil {
 		return err
 	}
-	_, err = dc.driverConn.Write(buf.Bytes())
+	_, err = dc.driverConn.Write(encoded)
 	if err != nil {
 		logger.Error("Error writing message back to tcp ",
 			zap.Int("connectionID", dc.connectionID),
@@ -90,12 +137,22 @@ func (dc *driverConnection) writeMessageBackToTcp(
 	return nil
 }
 
-func (dc *driverConnection) writeGrpcResponseToTcp(
+// receiveGrpcResponse reads all AdaptMessageResponses of a request, applies
+// their state updates and returns the merged payload. Returns a nil payload if
+// no payload was received.
+//
+// The response can not be streamed to the driver as it is received: Spanner
+// sends the frame header, with the length of the frame, in the last chunk. The
+// buffered response is bounded by MaxResponseSize, past which the stream is
+// abandoned with a responseTooLargeError.
+func (dc *driverConnection) receiveGrpcResponse(
 	pbCli adapterpb.Adapter_AdaptMessageClient,
-) error {
+	req *requestState,
+) ([]byte, error) {
 	var err error
 	var resp *adapterpb.AdaptMessageResponse
 	var payloads [][]byte
+	size := 0
 
 	for err == nil {
 		resp, err = pbCli.Recv()
@@ -107,41 +164,72 @@ func (dc *driverConnection) writeGrpcResponseToTcp(
 				"Error reading AdaptMessageResponse. ",
 				zap.Error(err),
 			)
-			return err
+			return nil, err
+		}
+		if len(payloads) == 0 && resp.Payload != nil && req != nil {
+			req.trace.event("Received first response chunk from Spanner")
+			dc.recordStage(req.frame.Header.OpCode, stageFirstResponse, req.sent, time.Now())
 		}
 		if resp.GetStateUpdates() != nil {
 			for k, v := range resp.GetStateUpdates() {
+				if recordRowCount(req, k, v) {
+					continue
+				}
 				dc.globalState.Store(k, v)
 			}
 		}
 		if resp.Payload != nil {
+			size += len(resp.Payload)
+			if err := dc.checkResponseSize(size); err != nil {
+				return nil, err
+			}
 			payloads = append(payloads, resp.Payload)
 		}
 	}
-	payloadsLen := len(payloads)
-	var payloadToWrite []byte
-	if payloadsLen == 0 {
+	return frameutil.MergeChunkedPayloads(payloads), nil
+}
+
+func (dc *driverConnection) writeGrpcResponseToTcp(
+	ctx context.Context,
+	pbCli adapterpb.Adapter_AdaptMessageClient,
+	req *requestState,
+) error {
+	payloadToWrite, err := dc.receiveGrpcResponse(pbCli, req)
+	if err != nil {
+		return err
+	}
+	payloadToWrite, err = dc.readRemainingPages(ctx, req, payloadToWrite)
+	if err != nil {
+		return err
+	}
+	dc.recordStage(req.frame.Header.OpCode, stageLastResponse, req.sent, time.Now())
+	if payloadToWrite == nil {
+		dc.completeBatchToken(req, nil)
 		return nil // No payload received, nothing to write.
 	}
+	payloadToWrite = dc.decodeColumns(req, payloadToWrite)
+	payloadToWrite = dc.translateJSONColumns(req, payloadToWrite)
+	// PREPARE results are cached before any amendment specific to req, ie:
+	// its latencies.
+	response := payloadToWrite
+	payloadToWrite = dc.translatePreparedTTL(req, payloadToWrite)
+	payloadToWrite = dc.orderCollections(req, payloadToWrite)
+	payloadToWrite = dc.overrideSystemColumns(req, payloadToWrite)
+	payloadToWrite = dc.attachWarnings(req, payloadToWrite)
+	payloadToWrite = dc.attachRowCount(req, payloadToWrite)
+	dc.storeReadCache(req, payloadToWrite)
+	payloadToWrite = dc.pageResponse(req, payloadToWrite)
+	payloadToWrite = dc.attachLatencies(req, pbCli, payloadToWrite)
+	dc.completeBatchToken(req, payloadToWrite)
 
-	// If there is only one response, it consists a complete message frame and we
-	// can directly wirte it back.
-	if payloadsLen == 1 {
-		payloadToWrite = payloads[0]
-	} else {
-		// Merge payloads (last + first...second last) since last payload is always
-		// the header when there are more than one responses received.
-		lastPayload := payloads[payloadsLen-1]
-		mergedPayload := bytes.Buffer{}
-		mergedPayload.Write(lastPayload)
-
-		for i := range payloads[:payloadsLen-1] {
-			mergedPayload.Write(payloads[i])
-		}
-		payloadToWrite = mergedPayload.Bytes()
+	if opCode, ok := responseOpCode(payloadToWrite); ok {
+		req.errorResponse = opCode == primitive.OpCodeError
 	}
-
-	_, err = dc.driverConn.Write(payloadToWrite)
+	dc.adapterClient.stats.recordResponseSize(len(payloadToWrite))
+	writeStart := time.Now()
+	dc.activity.set(ConnectionWrite)
+	_, err = dc.driverConn.Write(dc.finishTrace(req.trace, payloadToWrite))
+	dc.recordStage(req.frame.Header.OpCode, stageTCPWrite, writeStart, time.Now())
 	if err != nil {
 		logger.Debug("Error writing merged payload to connection",
 			zap.Int("connectionID", dc.connectionID),
@@ -149,26 +237,104 @@ func (dc *driverConnection) writeGrpcResponseToTcp(
 		)
 		return err
 	}
+	dc.stopHandshakeTimerOnReady(req, payloadToWrite)
+	dc.cachePreparedResult(req, response)
+	dc.rememberPreparedStatement(req, payloadToWrite)
+	dc.rememberMutationStatement(req, payloadToWrite)
+	dc.rememberCachedStatement(req, payloadToWrite)
+	dc.rememberFullScanStatement(req, payloadToWrite)
+	dc.rememberCollectionStatement(req, payloadToWrite)
+	dc.rememberBindMarkers(req, payloadToWrite)
+	dc.rememberRoutedStatement(req, payloadToWrite)
+	dc.rememberCapturedStatement(req, payloadToWrite)
+	dc.rememberBindVariables(req, payloadToWrite)
+	dc.rememberPartitionKey(req, payloadToWrite)
+	dc.rememberPolicyStatement(req, payloadToWrite)
+	dc.rememberStatementFingerprint(req, payloadToWrite)
 
 	return nil
 }
 
+// recordStage records the latency of a stage of a request, from start to
+// end.
+func (dc *driverConnection) recordStage(
+	opCode primitive.OpCode,
+	stage requestStage,
+	start, end time.Time,
+) {
+	dc.adapterClient.stats.recordStage(opCode, stage, end.Sub(start))
+}
+
+// isDML reports whether a request is routed to the leader, using the
+// classification of the protocol if it provides one.
+func (dc *driverConnection) isDML(req *requestState) bool {
+	if classifier, ok := dc.protocol.(DMLClassifier); ok {
+		return classifier.IsDML(req.pb.Payload)
+	}
+	return isDML(&req.frame)
+}
+
+// routeToLeader reports whether a request must be served by the leader, which
+// is the case for DML and SERIAL reads.
+func (dc *driverConnection) routeToLeader(req *requestState) bool {
+	return dc.isDML(req) || isSerialRead(&req.frame)
+}
+
+// recoverPanic tears the connection down after a panic while serving it, so
+// that a single poisoned connection doesn't crash the process.
+func (dc *driverConnection) recoverPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	dc.adapterClient.stats.recordConnectionPanic()
+	logger.Error("Closing connection after a panic while serving it",
+		zap.Int("connectionID", dc.connectionID),
+		zap.String("remote_addr", dc.driverConn.RemoteAddr().String()),
+		zap.Any("panic", r),
+		zap.Stack("stack"))
+	dc.driverConn.Close()
+}
+
 func (dc *driverConnection) handleConnection(ctx context.Context) {
+	defer dc.recoverPanic()
+	defer dc.adapterClient.stats.connectionClosed()
+	if protocol, ok := dc.protocol.(StreamProtocol); ok {
+		dc.handleStreamConnection(ctx, protocol)
+		return
+	}
 	defer func() {
 		logger.Debug(
 			"Exiting recv loop",
 			zap.Int("connection id", dc.connectionID),
 		)
+		dc.stopHandshakeTimer()
 		dc.driverConn.Close()
 	}()
 	for {
-		payload, header, err := dc.constructPayload()
+		dc.activity.set(ConnectionIdle)
+		payload, header, readStart, err := dc.constructPayload()
+		var tooLarge *frameutil.FrameTooLargeError
+		if errors.As(err, &tooLarge) {
+			logger.Error("Closing connection sending an oversized frame ",
+				zap.Int("connectionID", dc.connectionID),
+				zap.Error(err))
+			// The connection can not be used anymore, as its frame body is not
+			// buffered.
+			_ = dc.writeMessageBackToTcp(
+				tooLarge.Header,
+				&message.ProtocolError{ErrorMessage: err.Error()},
+			)
+			discardBody(dc.driverConn, tooLarge.Header)
+			break
+		}
 		if err != nil {
 			// Only EOF error is expected if the peer closes the connection
 			// gracefully.
 			if !errors.Is(err, io.EOF) {
 				logger.Error("Error constructing AdaptMessagePayload ",
 					zap.Int("connectionID", dc.connectionID),
+					zap.String("remote_addr", dc.driverConn.RemoteAddr().String()),
 					zap.Error(err))
 			}
 			// Break whenever there is a non-retriable error(ie: when peer force
@@ -177,7 +343,17 @@ func (dc *driverConnection) handleConnection(ctx context.Context) {
 			break
 		}
 
-		frame, err := dc.codec.DecodeFrame(bytes.NewBuffer(*payload))
+		// Reject compressed STARTUP bodies, which can not be decoded.
+		if msg := compressedStartupError(header); msg != nil {
+			logger.Warn("Rejecting compressed STARTUP request",
+				zap.Int("connectionID", dc.connectionID),
+				zap.String("remote_addr", dc.driverConn.RemoteAddr().String()))
+			_ = dc.writeMessageBackToTcp(header, msg)
+			continue
+		}
+
+		received := time.Now()
+		frame, err := dc.decodeFrame(*payload)
 		if err != nil {
 			logger.Error("Error decoding frame from payload ",
 				zap.Int("connectionID", dc.connectionID),
@@ -191,7 +367,75 @@ func (dc *driverConnection) handleConnection(ctx context.Context) {
 			continue
 		}
 
-		session, err := dc.adapterClient.getOrRefreshSession(ctx)
+		dc.adapterClient.stats.recordRequest(frame.Header.OpCode.String())
+		dc.adapterClient.stats.recordRequestSize(len(*payload))
+		dc.recordStage(frame.Header.OpCode, stageTCPRead, readStart, received)
+		dc.recordStage(frame.Header.OpCode, stageDecode, received, time.Now())
+		trace := dc.startTrace(frame, *payload, received)
+
+		if query, ok := frame.Body.Message.(*message.Query); ok {
+			if keyspace, ok := parseUseKeyspace(query.Query); ok {
+				dc.keyspace = keyspace
+			}
+		}
+
+		// Apply STARTUP options.
+		if msg := dc.tryApplyStartupOptions(frame); msg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, msg)
+			continue
+		}
+
+		// Record connection labels.
+		if msg := dc.trySetLabels(frame); msg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, msg)
+			continue
+		}
+
+		// Buffer the statements of explicit transactions until they are
+		// committed.
+		msg, commit := dc.tryTransaction(frame, payload)
+		if msg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, msg)
+			continue
+		}
+
+		// Answer queries on change streams from the change stream reader.
+		if msg := dc.tryServeChangeStream(ctx, frame); msg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, msg)
+			continue
+		}
+
+		// Answer queries on proxy emulated tables locally.
+		if msg := dc.tryServeVirtualTable(frame); msg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, msg)
+			continue
+		}
+
+		// Answer the requests for the next pages of results paged locally.
+		if msg := dc.tryServeNextPage(frame); msg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, msg)
+			continue
+		}
+
+		// Answer repeated PREPARE requests locally.
+		if dc.tryServePreparedLocally(frame) {
+			continue
+		}
+
+		// Answer repeated reads of cached tables locally.
+		cached := dc.lookupReadCache(frame, *payload, trace != nil)
+		if dc.tryServeReadCache(frame, cached) {
+			continue
+		}
+
+		// Pick the database serving the tables of the request.
+		client, errMsg := dc.routeRequest(frame)
+		if errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+
+		session, err := client.getOrRefreshSession(ctx)
 		if err != nil {
 			logger.Error("Error getting or refreshing session ",
 				zap.Int("connectionID", dc.connectionID),
@@ -200,7 +444,7 @@ func (dc *driverConnection) handleConnection(ctx context.Context) {
 			// recreation is failed.
 			_ = dc.writeMessageBackToTcp(
 				frame.Header,
-				&message.ServerError{ErrorMessage: err.Error()},
+				dc.serverErrorMessage(err),
 			)
 			continue
 		}
@@ -211,7 +455,64 @@ func (dc *driverConnection) handleConnection(ctx context.Context) {
 				Protocol: dc.protocol.Name(),
 				Payload:  *payload,
 			},
-			frame: *frame,
+			frame:           *frame,
+			trace:           trace,
+			received:        received,
+			affinityKey:     dc.connectionID,
+			commit:          commit,
+			throwOnOverload: dc.throwOnOverload,
+			readCache:       cached,
+			client:          client,
+			retryBudget:     dc.retryBudget,
+		}
+
+		// Record a sample of the requests for offline replay.
+		dc.captureRequest(frame, received)
+
+		// Prepare again the evicted statements of a batch.
+		dc.reprepareBatch(ctx, client, session.name, frame)
+
+		// Validate the values bound by the driver before translating them.
+		translateStart := time.Now()
+		if errMsg := dc.tryValidateBindValues(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+		// Apply the atomicity of LOGGED and UNLOGGED batches.
+		if errMsg := dc.tryCheckBatchAtomicity(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+		// Translate USING TTL clauses to expiration columns.
+		if errMsg := dc.tryTranslateTTL(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+		// Encode the values bound to tuple and UDT columns as JSON.
+		if errMsg := dc.tryBindJSONColumns(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+		// Transform the values bound to the columns of Options.ColumnCodecs.
+		if errMsg := dc.tryEncodeColumns(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+		// Evaluate the CQL functions Spanner does not support.
+		if errMsg := dc.tryEmulateFunctions(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+
+		// Page the results of paged requests locally.
+		if errMsg := dc.tryUnpageRequest(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+		// Read the results of unpaged reads from Spanner a page at a time.
+		if errMsg := dc.tryPageUnpagedRead(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
 		}
 
 		// Pass attachments, send back any error messages to the driver and skips
@@ -223,34 +524,86 @@ func (dc *driverConnection) handleConnection(ctx context.Context) {
 			// server.
 			continue
 		}
+		// Reject requests Spanner would not accept before sending them.
+		if errMsg := dc.checkRequestSize(req); errMsg != nil {
+			_ = dc.writeMessageBackToTcp(frame.Header, errMsg)
+			continue
+		}
+		// Collect the warnings returned with the response.
+		req.warnings = dc.executor.requestWarnings(frame)
+		dc.applyReadYourWrites(req, time.Now())
+
+		// Answer the replays of batches with an idempotency token.
+		if dc.tryServeDuplicateBatch(ctx, req) {
+			continue
+		}
+		// Send the parts of large unlogged batches concurrently.
+		if dc.trySplitBatch(ctx, req) {
+			continue
+		}
+
+		if logger.DebugEnabled() {
+			_ = logger.DumpRequest(req.pb)
+		}
+
+		release, overloaded := dc.admitRequest()
+		if overloaded != nil {
+			dc.completeBatchToken(req, nil)
+			_ = dc.writeMessageBackToTcp(frame.Header, overloaded)
+			continue
+		}
 
 		// Send the grpc request.
 		var pbCli adapterpb.Adapter_AdaptMessageClient
-		pbCli, err = dc.executor.submit(ctx, req, isDML(&req.frame))
-		if err != nil {
+		var ch *grpcChannel
+		start := time.Now()
+		dc.recordStage(frame.Header.OpCode, stageAttachments, translateStart, start)
+		dc.activity.set(ConnectionRecv)
+		for {
+			// Streams abandoned before their end, ie: responses over
+			// MaxResponseSize, are canceled once the request completes.
+			reqCtx, cancel := context.WithCancel(dc.labelContext(ctx))
+			pbCli, ch, err = dc.executor.submit(
+				reqCtx,
+				req,
+				dc.routeToLeader(req),
+			)
+			req.sent = time.Now()
+			dc.recordStage(frame.Header.OpCode, stageGrpcSend, start, req.sent)
+			if err == nil {
+				trace.event("Sent AdaptMessage request to Spanner")
+				// Read grpc response and write back to local tcp connection.
+				err = dc.writeGrpcResponseToTcp(ctx, pbCli, req)
+				dc.adapterClient.recordResult(ch, time.Since(start), err)
+			}
+			recordCompletion(req.metrics, err)
+			cancel()
+			// Committed transactions are sent again if Spanner aborts them.
+			if !dc.replayAbortedTransaction(ctx, req, err) {
+				break
+			}
+			start = time.Now()
+		}
+		release(time.Since(start), err)
+		dc.recordStatement(req, time.Since(received), err)
+		if pbCli == nil {
 			logger.Error("Error sending AdaptMessageRequest to server",
-				zap.Int("connectionID", int(dc.connectionID)),
-				zap.Error(err),
+				append(dc.requestLogFields(req), zap.Error(err))...,
 			)
 			// If requests was not successfully sent to server, return a server error
 			// and skip reading responses
 			// from the server.
-			_ = dc.writeMessageBackToTcp(
-				frame.Header,
-				&message.ServerError{ErrorMessage: err.Error()},
-			)
+			_ = dc.writeRequestError(ctx, req, err)
 			continue
 		}
-		// Read grpc response and write back to local tcp connection.
-		if err = dc.writeGrpcResponseToTcp(pbCli); err != nil {
+		// The write may have been committed even if the response failed.
+		dc.recordWrite(req, time.Now())
+		dc.invalidateReadCache(req.readCache)
+		if err != nil {
 			logger.Error("Error writing grpc response back to tcp",
-				zap.Int("connectionID", int(dc.connectionID)),
-				zap.Error(err),
-			)
-			_ = dc.writeMessageBackToTcp(
-				frame.Header,
-				&message.ServerError{ErrorMessage: err.Error()},
+				append(dc.requestLogFields(req), zap.Error(err))...,
 			)
+			_ = dc.writeRequestError(ctx, req, err)
 		}
 	}
 }
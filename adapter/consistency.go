@@ -0,0 +1,247 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// consistencyGuarantee describes how Spanner serves a Cassandra consistency
+// level.
+type consistencyGuarantee int
+
+const (
+	// The level is honored, Spanner reads and writes are strongly consistent.
+	consistencyStrong consistencyGuarantee = iota
+	// The level is weaker than what Spanner provides, the statement is served
+	// with strong consistency.
+	consistencyUpgraded
+	// The level is not valid for the statement.
+	consistencyInvalid
+)
+
+// Consistency levels that were already reported, keyed by consistencyReport.
+var reportedConsistency sync.Map
+
+// Margin added to the time a write was acknowledged to the driver, to cover
+// the skew between the local clock and Spanner commit timestamps.
+var readYourWritesClockMargin = 50 * time.Millisecond
+
+type consistencyReport struct {
+	level primitive.ConsistencyLevel
+	dml   bool
+}
+
+// mapConsistency returns how Spanner serves a statement with the given
+// consistency level.
+func mapConsistency(
+	level primitive.ConsistencyLevel,
+	dml bool,
+) consistencyGuarantee {
+	switch level {
+	case primitive.ConsistencyLevelQuorum,
+		primitive.ConsistencyLevelLocalQuorum,
+		primitive.ConsistencyLevelAll:
+		return consistencyStrong
+	case primitive.ConsistencyLevelEachQuorum:
+		// EACH_QUORUM is only supported for writes.
+		if dml {
+			return consistencyStrong
+		}
+		return consistencyInvalid
+	case primitive.ConsistencyLevelSerial, primitive.ConsistencyLevelLocalSerial:
+		// SERIAL levels are only valid for reads and as the serial consistency of
+		// conditional updates.
+		if dml {
+			return consistencyInvalid
+		}
+		return consistencyStrong
+	case primitive.ConsistencyLevelAny:
+		// ANY is only supported for writes.
+		if dml {
+			return consistencyUpgraded
+		}
+		return consistencyInvalid
+	case primitive.ConsistencyLevelOne,
+		primitive.ConsistencyLevelTwo,
+		primitive.ConsistencyLevelThree,
+		primitive.ConsistencyLevelLocalOne:
+		return consistencyUpgraded
+	default:
+		return consistencyInvalid
+	}
+}
+
+// isSerialRead reports whether a request is a read with the SERIAL or
+// LOCAL_SERIAL consistency level. Serial reads, typically issued ahead of
+// lightweight transactions, are strong reads routed to the leader.
+func isSerialRead(frm *frame.Frame) bool {
+	level, ok := consistencyOf(frm)
+	if !ok || isDML(frm) {
+		return false
+	}
+	return level == primitive.ConsistencyLevelSerial ||
+		level == primitive.ConsistencyLevelLocalSerial
+}
+
+// consistencyOf returns the consistency level of a QUERY, EXECUTE or BATCH
+// request.
+func consistencyOf(frm *frame.Frame) (primitive.ConsistencyLevel, bool) {
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		if msg.Options == nil {
+			return 0, false
+		}
+		return msg.Options.Consistency, true
+	case *message.Execute:
+		if msg.Options == nil {
+			return 0, false
+		}
+		return msg.Options.Consistency, true
+	case *message.Batch:
+		return msg.Consistency, true
+	default:
+		return 0, false
+	}
+}
+
+func statementKind(dml bool) string {
+	if dml {
+		return "write"
+	}
+	return "read"
+}
+
+// reportConsistencyOnce logs how a consistency level is served the first time
+// it is used.
+func reportConsistencyOnce(
+	level primitive.ConsistencyLevel,
+	dml bool,
+	log func(string, ...zap.Field),
+	msg string,
+) {
+	if _, reported := reportedConsistency.LoadOrStore(
+		consistencyReport{level, dml},
+		true,
+	); reported {
+		return
+	}
+	log(msg,
+		zap.String("consistency", level.String()),
+		zap.String("statement", statementKind(dml)))
+}
+
+// tryApplyConsistency validates the consistency level of a request, and
+// translates weak read consistency levels into stale reads if
+// Options.WeakConsistencyStaleness is set. Returns an error message if the
+// level is not valid for the request and Options.StrictConsistency is set.
+func (re *requestExecutor) tryApplyConsistency(
+	frm *frame.Frame, attachments map[string]string,
+) message.Message {
+	level, ok := consistencyOf(frm)
+	if !ok {
+		return nil
+	}
+	dml := isDML(frm)
+	if isSerialRead(frm) {
+		_, hasTs := attachments[readTimestamp]
+		_, hasStaleness := attachments[exactStaleness]
+		if hasTs || hasStaleness {
+			return &message.Invalid{
+				ErrorMessage: fmt.Sprintf(
+					"Timestamp bounds are not supported for %v reads",
+					level,
+				),
+			}
+		}
+	}
+	switch mapConsistency(level, dml) {
+	case consistencyInvalid:
+		if re.opts.StrictConsistency {
+			return &message.Invalid{
+				ErrorMessage: fmt.Sprintf(
+					"Consistency level %v is not supported for %s statements",
+					level,
+					statementKind(dml),
+				),
+			}
+		}
+		reportConsistencyOnce(
+			level, dml, logger.Error,
+			"Unsupported consistency level, statement is served with strong consistency",
+		)
+	case consistencyUpgraded:
+		if !dml && re.opts.WeakConsistencyStaleness > 0 &&
+			(level == primitive.ConsistencyLevelOne ||
+				level == primitive.ConsistencyLevelLocalOne) {
+			_, hasTs := attachments[readTimestamp]
+			_, hasStaleness := attachments[exactStaleness]
+			if !hasTs && !hasStaleness {
+				attachments[exactStaleness] = re.opts.WeakConsistencyStaleness.String()
+			}
+			return nil
+		}
+		reportConsistencyOnce(
+			level, dml, logger.Info,
+			"Consistency level is served with strong consistency",
+		)
+	}
+	return nil
+}
+
+// applyReadYourWrites bounds the exact staleness reads of a connection with
+// Options.ReadYourWrites set, so that they observe the last write of the
+// connection. Reads whose timestamp would precede the write are read at the
+// time the write was acknowledged instead, or with strong consistency if that
+// time is too recent.
+func (dc *driverConnection) applyReadYourWrites(req *requestState, now time.Time) {
+	if !dc.executor.opts.ReadYourWrites || dc.lastWrite.IsZero() {
+		return
+	}
+	staleness, ok := req.pb.Attachments[exactStaleness]
+	if !ok {
+		return
+	}
+	d, err := time.ParseDuration(staleness)
+	if err != nil {
+		return
+	}
+	minReadTimestamp := dc.lastWrite.Add(readYourWritesClockMargin)
+	if !now.Add(-d).Before(minReadTimestamp) {
+		return
+	}
+	delete(req.pb.Attachments, exactStaleness)
+	if !minReadTimestamp.After(now) {
+		req.pb.Attachments[readTimestamp] = minReadTimestamp.UTC().Format(time.RFC3339Nano)
+	}
+}
+
+// recordWrite records the time the response of a DML request was written,
+// if Options.ReadYourWrites is set.
+func (dc *driverConnection) recordWrite(req *requestState, at time.Time) {
+	if dc.executor.opts.ReadYourWrites && dc.isDML(req) {
+		dc.lastWrite = at
+	}
+}
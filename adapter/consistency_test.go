@@ -0,0 +1,221 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMapConsistency(t *testing.T) {
+	testCases := []struct {
+		level primitive.ConsistencyLevel
+		dml   bool
+		want  consistencyGuarantee
+	}{
+		{primitive.ConsistencyLevelQuorum, false, consistencyStrong},
+		{primitive.ConsistencyLevelLocalQuorum, true, consistencyStrong},
+		{primitive.ConsistencyLevelEachQuorum, true, consistencyStrong},
+		{primitive.ConsistencyLevelEachQuorum, false, consistencyInvalid},
+		{primitive.ConsistencyLevelSerial, false, consistencyStrong},
+		{primitive.ConsistencyLevelLocalSerial, true, consistencyInvalid},
+		{primitive.ConsistencyLevelAny, true, consistencyUpgraded},
+		{primitive.ConsistencyLevelAny, false, consistencyInvalid},
+		{primitive.ConsistencyLevelLocalOne, false, consistencyUpgraded},
+		{primitive.ConsistencyLevelTwo, true, consistencyUpgraded},
+	}
+	for _, tc := range testCases {
+		assert.Equal(
+			t,
+			tc.want,
+			mapConsistency(tc.level, tc.dml),
+			"%v dml=%v", tc.level, tc.dml,
+		)
+	}
+}
+
+func TestApplyConsistency(t *testing.T) {
+	query := func(q string, level primitive.ConsistencyLevel) *requestState {
+		frm := newMessageFrame(&message.Query{
+			Query:   q,
+			Options: &message.QueryOptions{Consistency: level},
+		})
+		return &requestState{frame: *frm}
+	}
+	executor := func(opts *Options) *requestExecutor {
+		return &requestExecutor{opts: opts}
+	}
+
+	// Invalid levels are only rejected in strict mode.
+	anyRead := query("SELECT * FROM t", primitive.ConsistencyLevelAny)
+	attachments := map[string]string{}
+	assert.Nil(t, executor(&Options{}).tryApplyConsistency(&anyRead.frame, attachments))
+	assert.IsType(
+		t,
+		&message.Invalid{},
+		executor(&Options{StrictConsistency: true}).
+			tryApplyConsistency(&anyRead.frame, attachments),
+	)
+	serialWrite := query("UPDATE t SET a = 1 WHERE id = 1", primitive.ConsistencyLevelSerial)
+	assert.IsType(
+		t,
+		&message.Invalid{},
+		executor(&Options{StrictConsistency: true}).
+			tryApplyConsistency(&serialWrite.frame, attachments),
+	)
+
+	// Weak reads become stale reads if configured.
+	oneRead := query("SELECT * FROM t", primitive.ConsistencyLevelLocalOne)
+	assert.Nil(t, executor(&Options{StrictConsistency: true}).
+		tryApplyConsistency(&oneRead.frame, attachments))
+	assert.Empty(t, attachments)
+	staleOpts := &Options{WeakConsistencyStaleness: 10 * time.Second}
+	assert.Nil(t, executor(staleOpts).tryApplyConsistency(&oneRead.frame, attachments))
+	assert.Equal(t, "10s", attachments[exactStaleness])
+
+	// Explicit timestamp bounds take precedence.
+	attachments = map[string]string{readTimestamp: "2025-01-01T00:00:00Z"}
+	assert.Nil(t, executor(staleOpts).tryApplyConsistency(&oneRead.frame, attachments))
+	assert.NotContains(t, attachments, exactStaleness)
+
+	// Writes are never stale.
+	attachments = map[string]string{}
+	oneWrite := query("INSERT INTO t (a) VALUES (1)", primitive.ConsistencyLevelOne)
+	assert.Nil(t, executor(staleOpts).tryApplyConsistency(&oneWrite.frame, attachments))
+	assert.Empty(t, attachments)
+}
+
+func TestSerialReadsRouteToLeader(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	var routedToLeader []string
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		routedToLeader = md.Get(routeToLeaderHeader)
+		return &Mock_Payload_AdaptMessageClient{}, nil
+	}
+	pool, err := newChannelPool(context.Background(), Options{}, SkipAuthOpts)
+	require.NoError(t, err)
+	defer pool.close()
+	client := &AdapterClient{channels: pool}
+	dc := &driverConnection{
+		executor: &requestExecutor{client: client, opts: &Options{}},
+	}
+
+	testCases := []struct {
+		level primitive.ConsistencyLevel
+		want  []string
+	}{
+		{primitive.ConsistencyLevelSerial, []string{"true"}},
+		{primitive.ConsistencyLevelLocalSerial, []string{"true"}},
+		{primitive.ConsistencyLevelQuorum, nil},
+	}
+	for _, tc := range testCases {
+		req := &requestState{
+			pb: &adapterpb.AdaptMessageRequest{},
+			frame: *newMessageFrame(&message.Query{
+				Query:   "SELECT * FROM t WHERE id = 1",
+				Options: &message.QueryOptions{Consistency: tc.level},
+			}),
+		}
+		_, _, err := dc.executor.submit(
+			context.Background(),
+			req,
+			dc.routeToLeader(req),
+		)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, routedToLeader, tc.level.String())
+	}
+
+	// Serial reads are always strong.
+	serialRead := newMessageFrame(&message.Query{
+		Query:   "SELECT * FROM t WHERE id = 1",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelSerial},
+	})
+	assert.IsType(
+		t,
+		&message.Invalid{},
+		dc.executor.tryApplyConsistency(
+			serialRead,
+			map[string]string{exactStaleness: "10s"},
+		),
+	)
+}
+
+func TestReadYourWrites(t *testing.T) {
+	dc := &driverConnection{
+		executor: &requestExecutor{opts: &Options{ReadYourWrites: true}},
+	}
+	newRead := func(staleness string) *requestState {
+		return &requestState{
+			pb: &adapterpb.AdaptMessageRequest{
+				Attachments: map[string]string{exactStaleness: staleness},
+			},
+			frame: *newMessageFrame(&message.Query{Query: "SELECT * FROM t"}),
+		}
+	}
+	now := time.Now()
+
+	// Reads are not bounded before the first write.
+	read := newRead("10s")
+	dc.applyReadYourWrites(read, now)
+	assert.Equal(t, map[string]string{exactStaleness: "10s"}, read.pb.Attachments)
+
+	// Reads are not affected by other reads.
+	dc.recordWrite(newRead("10s"), now.Add(-time.Minute))
+	assert.True(t, dc.lastWrite.IsZero())
+
+	dc.recordWrite(&requestState{
+		pb:    &adapterpb.AdaptMessageRequest{},
+		frame: *newMessageFrame(&message.Query{Query: "INSERT INTO t (id) VALUES (1)"}),
+	}, now.Add(-time.Minute))
+	assert.Equal(t, now.Add(-time.Minute), dc.lastWrite)
+
+	// Reads after the write are left stale.
+	read = newRead("10s")
+	dc.applyReadYourWrites(read, now)
+	assert.Equal(t, map[string]string{exactStaleness: "10s"}, read.pb.Attachments)
+
+	// Reads reaching before the write are read at the time of the write.
+	read = newRead("2m")
+	dc.applyReadYourWrites(read, now)
+	assert.Equal(t, map[string]string{
+		readTimestamp: now.Add(-time.Minute).
+			Add(readYourWritesClockMargin).
+			UTC().
+			Format(time.RFC3339Nano),
+	}, read.pb.Attachments)
+
+	// Reads right after the write are strong.
+	read = newRead("10s")
+	dc.applyReadYourWrites(read, now.Add(-time.Minute))
+	assert.Empty(t, read.pb.Attachments)
+}
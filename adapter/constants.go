@@ -17,13 +17,77 @@ limitations under the License.
 package adapter
 
 const (
-	// Maximum size of local singleton state maintained across all requests.
-	// ~100mb
+	// Default maximum number of entries of local singleton state maintained
+	// across all requests. ~100mb
 	maxGlobalStateSize = 1e8 / 256
 	// Prefix for prepared query id state updates.
 	preparedQueryIdAttachmentPrefix = "pqid/"
+	// Prefix for cached PREPARE responses keyed by query text.
+	preparedResultStatePrefix = "prep/"
 	// Prefix for Message.QueryId if this query id belongs to a DML statement.
 	writeActionQueryIdPrefix = "W"
+	// Prefix for prepared query ids of statements on proxy emulated tables.
+	virtualQueryIdPrefix = "vt:"
+	// Prefix for paging states of results paged by the proxy.
+	proxyPagingStatePrefix = "pg:"
+	// Attachment keys are keys of AdaptMessageRequest.attachments and state
+	// update keys are keys of AdaptMessageResponse.state_updates, both defined
+	// by google/spanner/adapter/v1/adapter.proto as opaque maps interpreted by
+	// the Adapter backend. The proto does not list the keys: apart from
+	// max_commit_delay, the keys below are only honored by backends supporting
+	// them, and a backend ignoring a key serves the request as if it was not
+	// set. Requests are therefore only sent with the keys that change their
+	// results or effects (optInAttachments) once they are listed in
+	// Options.SupportedAttachments, and fail otherwise.
+
 	// Attachment key for max commit delay.
 	maxCommitDelay = "max_commit_delay"
+	// Attachment key for the exact read timestamp of a read-only query.
+	readTimestamp = "read_timestamp"
+	// Attachment key for the exact staleness of a read-only query.
+	exactStaleness = "exact_staleness"
+	// Attachment key requesting Partitioned DML execution of a statement.
+	partitionedDML = "partitioned_dml"
+	// Attachment key requesting a statement to be applied as mutations.
+	insertMutation = "insert_mutation"
+	// Attachment key for the priority of a request (ie: PRIORITY_LOW).
+	requestPriority = "request_priority"
+	// Attachment key for the DirectedReadOptions of a read, encoded as JSON.
+	directedReadOptions = "directed_read_options"
+	// Attachment key requesting the number of rows affected by a DML
+	// statement.
+	returnRowCount = "return_row_count"
+	// State update key carrying the number of rows affected by a DML
+	// statement. It is not stored in the global state.
+	rowCountStateKey = "row_count"
+
+	// Custom payload key carrying an RFC 3339 timestamp to read data at.
+	ReadTimestampPayloadKey = "spanner_read_timestamp"
+	// Custom payload key carrying a duration (ie: "15s") to read data that
+	// many seconds in the past.
+	ExactStalenessPayloadKey = "spanner_exact_staleness"
+	// Custom payload key requesting a DML statement to be executed as
+	// Partitioned DML. Any non-empty value enables it.
+	PartitionedDMLPayloadKey = "spanner_partitioned_dml"
+	// Custom payload key requesting a simple INSERT statement, or a batch of
+	// them, to be applied as Spanner mutations. Any non-empty value enables it.
+	MutationPayloadKey = "spanner_mutation"
+	// Custom payload key carrying a client supplied idempotency token of a
+	// batch, with Options.BatchDedupCacheSize. Replays of a batch with the
+	// same token are answered with the response of the applied batch.
+	IdempotencyTokenPayloadKey = "spanner_idempotency_token"
+	// Response custom payload key carrying the time (ie: "12.5ms") from the
+	// proxy reading a request to writing its response, with
+	// Options.EnableLatencyPayload.
+	RequestLatencyPayloadKey = "spanner_request_latency"
+	// Response custom payload key carrying the time (ie: "10ms") Spanner spent
+	// serving a request, as reported by its server-timing header, with
+	// Options.EnableLatencyPayload.
+	BackendLatencyPayloadKey = "spanner_backend_latency"
+	// Response custom payload key carrying the number of rows affected by a
+	// DML statement (ie: "3"), with Options.EnableRowCountPayload.
+	RowCountPayloadKey = "spanner_row_count"
+	// Response custom payload key carrying the delay (ie: "1.5s") suggested
+	// by Spanner before retrying a request it rejected as overloaded.
+	RetryAfterPayloadKey = "spanner_retry_after"
 )
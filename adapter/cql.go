@@ -0,0 +1,242 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"sort"
+	"strings"
+)
+
+// cqlTokenKind is the kind of a CQL token.
+type cqlTokenKind int
+
+const (
+	cqlIdentifier cqlTokenKind = iota
+	cqlQuotedIdentifier
+	cqlString
+	cqlNumber
+	cqlSymbol
+)
+
+// cqlToken is a lexical token of a CQL statement. The text of quoted
+// identifiers and strings is unquoted.
+type cqlToken struct {
+	kind cqlTokenKind
+	text string
+	// Offsets of the first byte of the token in the statement, and of the byte
+	// following it.
+	start, end int
+}
+
+// is reports whether the token is the given unquoted keyword or symbol, case
+// insensitively.
+func (t cqlToken) is(text string) bool {
+	return (t.kind == cqlIdentifier || t.kind == cqlSymbol) &&
+		strings.EqualFold(t.text, text)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || ('0' <= c && c <= '9')
+}
+
+// readQuoted returns the unquoted text of the quoted token starting at
+// query[i], where doubled quotes stand for a quote, and the index following
+// it. Unterminated tokens extend to the end of the query.
+func readQuoted(query string, i int) (string, int) {
+	quote := query[i]
+	var text strings.Builder
+	for i++; i < len(query); i++ {
+		if query[i] == quote {
+			if i+1 < len(query) && query[i+1] == quote {
+				text.WriteByte(quote)
+				i++
+				continue
+			}
+			return text.String(), i + 1
+		}
+		text.WriteByte(query[i])
+	}
+	return text.String(), i
+}
+
+// tokenizeCQL splits a CQL statement into tokens, skipping whitespace and
+// comments.
+func tokenizeCQL(query string) []cqlToken {
+	var tokens []cqlToken
+	for i := 0; i < len(query); {
+		c := query[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(query[i:], "--") || strings.HasPrefix(query[i:], "//"):
+			if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(query)
+			}
+		case strings.HasPrefix(query[i:], "/*"):
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(query)
+			}
+		case c == '\'':
+			var text string
+			text, i = readQuoted(query, i)
+			tokens = append(tokens, cqlToken{cqlString, text, start, i})
+		case strings.HasPrefix(query[i:], "$$"):
+			end := strings.Index(query[i+2:], "$$")
+			if end < 0 {
+				i = len(query)
+				tokens = append(tokens, cqlToken{cqlString, query[start+2:], start, i})
+			} else {
+				i += end + 4
+				tokens = append(tokens, cqlToken{cqlString, query[start+2 : i-2], start, i})
+			}
+		case c == '"':
+			var text string
+			text, i = readQuoted(query, i)
+			tokens = append(tokens, cqlToken{cqlQuotedIdentifier, text, start, i})
+		case isIdentStart(c):
+			i++
+			for i < len(query) && isIdentPart(query[i]) {
+				i++
+			}
+			tokens = append(tokens, cqlToken{cqlIdentifier, query[start:i], start, i})
+		case '0' <= c && c <= '9':
+			i++
+			for i < len(query) && (isIdentPart(query[i]) || query[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, cqlToken{cqlNumber, query[start:i], start, i})
+		default:
+			i++
+			tokens = append(tokens, cqlToken{cqlSymbol, query[start:i], start, i})
+		}
+	}
+	return tokens
+}
+
+// queryEdit replaces query[start:end] with text.
+type queryEdit struct {
+	start, end int
+	text       string
+}
+
+// applyEdits returns query with the given non overlapping edits applied.
+func applyEdits(query string, edits []queryEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+	var rewritten strings.Builder
+	last := 0
+	for _, edit := range edits {
+		rewritten.WriteString(query[last:edit.start])
+		rewritten.WriteString(edit.text)
+		last = edit.end
+	}
+	rewritten.WriteString(query[last:])
+	return rewritten.String()
+}
+
+// cqlStatementKind returns the lower cased first keyword of a CQL statement,
+// ie: select, insert or begin.
+func cqlStatementKind(query string) string {
+	tokens := tokenizeCQL(query)
+	if len(tokens) == 0 || tokens[0].kind != cqlIdentifier {
+		return ""
+	}
+	return strings.ToLower(tokens[0].text)
+}
+
+// isCQLRead reports whether a CQL statement only reads data. All other
+// statements, including DDL, are routed to the leader.
+func isCQLRead(query string) bool {
+	switch cqlStatementKind(query) {
+	case "select", "use":
+		return true
+	default:
+		return false
+	}
+}
+
+// parseTableName parses a possibly keyspace qualified table name starting at
+// tokens[i], and returns it lower cased.
+func parseTableName(tokens []cqlToken, i int) (string, bool) {
+	isName := func(i int) bool {
+		return i < len(tokens) &&
+			(tokens[i].kind == cqlIdentifier || tokens[i].kind == cqlQuotedIdentifier)
+	}
+	if !isName(i) {
+		return "", false
+	}
+	table := tokens[i].text
+	if i+2 < len(tokens) && tokens[i+1].is(".") && isName(i+2) {
+		table += "." + tokens[i+2].text
+	}
+	return strings.ToLower(table), true
+}
+
+// parseDMLTarget returns the lower cased statement kind (insert, update or
+// delete) and the table targeted by a CQL DML statement.
+func parseDMLTarget(query string) (kind, table string, ok bool) {
+	tokens := tokenizeCQL(query)
+	if len(tokens) < 2 || tokens[0].kind != cqlIdentifier {
+		return "", "", false
+	}
+	kind = strings.ToLower(tokens[0].text)
+	switch kind {
+	case "update":
+		table, ok = parseTableName(tokens, 1)
+	case "insert":
+		if tokens[1].is("into") {
+			table, ok = parseTableName(tokens, 2)
+		}
+	case "delete":
+		for i := 1; i < len(tokens); i++ {
+			if tokens[i].is("from") {
+				table, ok = parseTableName(tokens, i+1)
+				break
+			}
+		}
+	}
+	if !ok {
+		return "", "", false
+	}
+	return kind, table, true
+}
+
+// matchesTable reports whether table, optionally qualified by its keyspace,
+// is contained in tables. Unqualified entries match the table in any keyspace.
+func matchesTable(tables []string, table string) bool {
+	name := table
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		name = table[i+1:]
+	}
+	for _, t := range tables {
+		t = strings.ToLower(t)
+		if t == table || (!strings.Contains(t, ".") && t == name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDMLTarget(t *testing.T) {
+	testCases := []struct {
+		query     string
+		wantKind  string
+		wantTable string
+		wantOk    bool
+	}{
+		{"UPDATE ks.users SET a = 1", "update", "ks.users", true},
+		{"  update Users set a = 1", "update", "users", true},
+		{"DELETE FROM ks.users WHERE id = 1", "delete", "ks.users", true},
+		{"DELETE a, b FROM users WHERE id = 1", "delete", "users", true},
+		{`INSERT INTO "ks"."Users" (id) VALUES (1)`, "insert", "ks.users", true},
+		{"/* audit */ DELETE FROM users WHERE id = 1", "delete", "users", true},
+		{"-- note\nUPDATE ks.users SET a = 1", "update", "ks.users", true},
+		{"SELECT * FROM users", "", "", false},
+		{"INSERT users (id) VALUES (1)", "", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			kind, table, ok := parseDMLTarget(tc.query)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantKind, kind)
+			assert.Equal(t, tc.wantTable, table)
+		})
+	}
+}
+
+func TestTokenizeCQL(t *testing.T) {
+	tokens := tokenizeCQL(
+		"SELECT \"Col\" -- comment\nFROM ks.t /* block */ WHERE a = 'it''s' AND b = $$x$$ AND c = 1.5;",
+	)
+	var texts []string
+	for _, token := range tokens {
+		texts = append(texts, token.text)
+	}
+	assert.Equal(t, []string{
+		"SELECT", "Col", "FROM", "ks", ".", "t", "WHERE", "a", "=", "it's",
+		"AND", "b", "=", "x", "AND", "c", "=", "1.5", ";",
+	}, texts)
+	assert.Equal(t, cqlQuotedIdentifier, tokens[1].kind)
+	assert.Equal(t, cqlString, tokens[9].kind)
+	assert.Equal(t, cqlNumber, tokens[17].kind)
+
+	// Offsets delimit the tokens in the statement.
+	query := "SELECT 'it''s', $$x$$ FROM t"
+	tokens = tokenizeCQL(query)
+	assert.Equal(t, "'it''s'", query[tokens[1].start:tokens[1].end])
+	assert.Equal(t, "$$x$$", query[tokens[3].start:tokens[3].end])
+
+	// Unterminated tokens extend to the end of the statement.
+	assert.Len(t, tokenizeCQL("SELECT 'abc"), 2)
+	assert.Empty(t, tokenizeCQL("/* only a comment"))
+}
+
+func TestIsCQLRead(t *testing.T) {
+	testCases := []struct {
+		query string
+		want  bool
+	}{
+		{"SELECT * FROM t", true},
+		{"  \n\tselect * FROM t", true},
+		{"/* hint */ SELECT * FROM t", true},
+		{"// comment\nSELECT * FROM t", true},
+		{"USE ks", true},
+		{"INSERT INTO t (a) VALUES (1)", false},
+		{"BEGIN BATCH INSERT INTO t (a) VALUES (1); APPLY BATCH", false},
+		{"-- SELECT\nDELETE FROM t WHERE a = 1", false},
+		{"CREATE TABLE t (a int PRIMARY KEY)", false},
+		{"", false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, isCQLRead(tc.query), tc.query)
+	}
+}
+
+func TestMatchesTable(t *testing.T) {
+	tables := []string{"KS.events", "audit"}
+	assert.True(t, matchesTable(tables, "ks.events"))
+	assert.False(t, matchesTable(tables, "other.events"))
+	assert.False(t, matchesTable(tables, "events"))
+	assert.True(t, matchesTable(tables, "audit"))
+	assert.True(t, matchesTable(tables, "ks.audit"))
+	assert.False(t, matchesTable(nil, "audit"))
+}
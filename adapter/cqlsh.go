@@ -0,0 +1,89 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+)
+
+// Release version reported in system.local with Options.CqlshCompatibility.
+// cqlsh describes the schemas of Cassandra releases before 4.0 from the
+// driver metadata, in place of sending DESCRIBE statements.
+const cqlshReleaseVersion = "3.11.17"
+
+var textList = datatype.NewList(datatype.Varchar)
+
+// cqlshTables lists the system_schema tables of the objects Spanner does not
+// have, emulated without rows with Options.CqlshCompatibility.
+var cqlshTables = []*virtualTable{
+	emptySchemaTable("functions", []columnDef{
+		{"keyspace_name", datatype.Varchar},
+		{"function_name", datatype.Varchar},
+		{"argument_types", textList},
+		{"argument_names", textList},
+		{"body", datatype.Varchar},
+		{"called_on_null_input", datatype.Boolean},
+		{"language", datatype.Varchar},
+		{"return_type", datatype.Varchar},
+	}),
+	emptySchemaTable("aggregates", []columnDef{
+		{"keyspace_name", datatype.Varchar},
+		{"aggregate_name", datatype.Varchar},
+		{"argument_types", textList},
+		{"final_func", datatype.Varchar},
+		{"initcond", datatype.Varchar},
+		{"return_type", datatype.Varchar},
+		{"state_func", datatype.Varchar},
+		{"state_type", datatype.Varchar},
+	}),
+	emptySchemaTable("triggers", []columnDef{
+		{"keyspace_name", datatype.Varchar},
+		{"table_name", datatype.Varchar},
+		{"trigger_name", datatype.Varchar},
+		{"options", datatype.NewMap(datatype.Varchar, datatype.Varchar)},
+	}),
+	emptySchemaTable("views", []columnDef{
+		{"keyspace_name", datatype.Varchar},
+		{"view_name", datatype.Varchar},
+		{"base_table_id", datatype.Uuid},
+		{"base_table_name", datatype.Varchar},
+		{"id", datatype.Uuid},
+		{"include_all_columns", datatype.Boolean},
+		{"where_clause", datatype.Varchar},
+	}),
+	emptySchemaTable("dropped_columns", []columnDef{
+		{"keyspace_name", datatype.Varchar},
+		{"table_name", datatype.Varchar},
+		{"column_name", datatype.Varchar},
+		{"dropped_time", datatype.Timestamp},
+		{"kind", datatype.Varchar},
+		{"type", datatype.Varchar},
+	}),
+}
+
+func emptySchemaTable(name string, defs []columnDef) *virtualTable {
+	return &virtualTable{
+		keyspace:  "system_schema",
+		name:      name,
+		columns:   virtualColumns("system_schema", name, defs),
+		anyFilter: true,
+		rows: func(*driverConnection, string) message.RowSet {
+			return nil
+		},
+	}
+}
@@ -0,0 +1,156 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCqlshConnection(t *testing.T) *driverConnection {
+	pages, err := newPagedResults(maxPagedResults)
+	require.NoError(t, err)
+	return &driverConnection{
+		executor: &requestExecutor{
+			opts:  &Options{CqlshCompatibility: true},
+			pages: pages,
+		},
+		codec: codec,
+	}
+}
+
+func encodeResponse(t *testing.T, msg message.Message) []byte {
+	frm := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+	frm.Header.IsResponse = true
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(frm, buf))
+	return buf.Bytes()
+}
+
+func decodeRows(t *testing.T, encoded []byte) *message.RowsResult {
+	frm, err := codec.DecodeFrame(bytes.NewBuffer(encoded))
+	require.NoError(t, err)
+	require.IsType(t, &message.RowsResult{}, frm.Body.Message)
+	return frm.Body.Message.(*message.RowsResult)
+}
+
+func TestCqlshPaging(t *testing.T) {
+	dc := newCqlshConnection(t)
+	query := "SELECT id FROM ks.users"
+	req := &requestState{
+		pb: &adapterpb.AdaptMessageRequest{},
+		frame: *frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{
+			Query:   query,
+			Options: &message.QueryOptions{PageSize: 2},
+		}),
+	}
+	require.Nil(t, dc.tryUnpageRequest(req))
+	assert.Equal(t, 2, req.pageSize)
+	assert.Zero(t, req.frame.Body.Message.(*message.Query).Options.PageSize)
+
+	metadata := &message.RowsMetadata{
+		ColumnCount: 1,
+		Columns: []*message.ColumnMetadata{
+			{Keyspace: "ks", Table: "users", Name: "id", Type: datatype.Int},
+		},
+	}
+	var data message.RowSet
+	for i := int32(0); i < 5; i++ {
+		data = append(data, message.Row{encodeInt(i)})
+	}
+	rows := decodeRows(t, dc.pageResponse(
+		req,
+		encodeResponse(t, &message.RowsResult{Metadata: metadata, Data: data}),
+	))
+	assert.Equal(t, data[:2], rows.Data)
+	state := rows.Metadata.PagingState
+	require.NotNil(t, state)
+
+	nextPage := func(state []byte) message.Message {
+		return dc.tryServeNextPage(frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{
+			Query:   query,
+			Options: &message.QueryOptions{PageSize: 2, PagingState: state},
+		}))
+	}
+	page := nextPage(state)
+	require.IsType(t, &message.RowsResult{}, page)
+	assert.Equal(t, data[2:4], page.(*message.RowsResult).Data)
+	assert.Equal(t, metadata.Columns, page.(*message.RowsResult).Metadata.Columns)
+	last := nextPage(page.(*message.RowsResult).Metadata.PagingState)
+	require.IsType(t, &message.RowsResult{}, last)
+	assert.Equal(t, data[4:], last.(*message.RowsResult).Data)
+	assert.Nil(t, last.(*message.RowsResult).Metadata.PagingState)
+
+	// Pages are served once.
+	assert.IsType(t, &message.Invalid{}, nextPage(state))
+	// Paging states of Spanner are not served locally.
+	assert.Nil(t, nextPage([]byte("spanner")))
+}
+
+func TestCqlshSystemColumns(t *testing.T) {
+	dc := newCqlshConnection(t)
+	req := &requestState{frame: *frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{
+		Query: "SELECT release_version, tokens, cluster_name FROM system.local WHERE key='local'",
+	})}
+	encoded := encodeResponse(t, &message.RowsResult{
+		Metadata: &message.RowsMetadata{
+			ColumnCount: 3,
+			Columns: virtualColumns("system", "local", []columnDef{
+				{"release_version", datatype.Varchar},
+				{"tokens", datatype.NewSet(datatype.Varchar)},
+				{"cluster_name", datatype.Varchar},
+			}),
+		},
+		Data: message.RowSet{{[]byte("4.0.0"), []byte{0, 0, 0, 0}, []byte("spanner")}},
+	})
+	rows := decodeRows(t, dc.overrideSystemColumns(req, encoded))
+	assert.Equal(t, message.RowSet{{[]byte(cqlshReleaseVersion), nil, []byte("spanner")}}, rows.Data)
+
+	dc.executor.opts.CqlshCompatibility = false
+	assert.Equal(t, encoded, dc.overrideSystemColumns(req, encoded))
+}
+
+func TestCqlshSchemaTables(t *testing.T) {
+	dc := newCqlshConnection(t)
+	query := "SELECT * FROM system_schema.triggers WHERE keyspace_name = 'ks' AND table_name = 'users'"
+	msg := dc.tryServeVirtualTable(frame.NewFrame(
+		primitive.ProtocolVersion4,
+		1,
+		&message.Query{Query: query},
+	))
+	require.IsType(t, &message.RowsResult{}, msg)
+	assert.Empty(t, msg.(*message.RowsResult).Data)
+	assert.Len(t, msg.(*message.RowsResult).Metadata.Columns, 4)
+
+	dc.executor.opts.CqlshCompatibility = false
+	assert.Nil(t, dc.tryServeVirtualTable(frame.NewFrame(
+		primitive.ProtocolVersion4,
+		1,
+		&message.Query{Query: query},
+	)))
+}
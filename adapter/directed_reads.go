@@ -0,0 +1,109 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ParseDirectedReadOptions parses comma separated replicas of the form
+// location[:type] (ie: "us-east1:READ_ONLY,us-west1"), where type is READ_ONLY
+// or READ_WRITE, into the DirectedReadOptions including them, or excluding them
+// if exclude is set. Reads fail over to other replicas when the included
+// replicas are unavailable. Returns nil for empty replicas.
+func ParseDirectedReadOptions(
+	replicas string,
+	exclude bool,
+) (*spannerpb.DirectedReadOptions, error) {
+	if replicas == "" {
+		return nil, nil
+	}
+	var selections []*spannerpb.DirectedReadOptions_ReplicaSelection
+	for _, replica := range strings.Split(replicas, ",") {
+		location, replicaType, _ := strings.Cut(strings.TrimSpace(replica), ":")
+		if location == "" && replicaType == "" {
+			return nil, fmt.Errorf("invalid replica '%s', expected location[:type]", replica)
+		}
+		selection := &spannerpb.DirectedReadOptions_ReplicaSelection{Location: location}
+		if replicaType != "" {
+			types := spannerpb.DirectedReadOptions_ReplicaSelection_Type_value
+			value, ok := types[strings.ToUpper(replicaType)]
+			if !ok || value == 0 {
+				return nil, fmt.Errorf(
+					"invalid replica type '%s' of %s, expected READ_ONLY or READ_WRITE",
+					replicaType,
+					location,
+				)
+			}
+			selection.Type = spannerpb.DirectedReadOptions_ReplicaSelection_Type(value)
+		}
+		selections = append(selections, selection)
+	}
+	if exclude {
+		return &spannerpb.DirectedReadOptions{
+			Replicas: &spannerpb.DirectedReadOptions_ExcludeReplicas_{
+				ExcludeReplicas: &spannerpb.DirectedReadOptions_ExcludeReplicas{
+					ReplicaSelections: selections,
+				},
+			},
+		}, nil
+	}
+	return &spannerpb.DirectedReadOptions{
+		Replicas: &spannerpb.DirectedReadOptions_IncludeReplicas_{
+			IncludeReplicas: &spannerpb.DirectedReadOptions_IncludeReplicas{
+				ReplicaSelections: selections,
+			},
+		},
+	}, nil
+}
+
+// directedReadAttachment returns the value of the directed_read_options
+// attachment of Options.DirectedReadOptions, the options encoded as JSON, or
+// an empty string if unset.
+func directedReadAttachment(opts Options) (string, error) {
+	if opts.DirectedReadOptions == nil {
+		return "", nil
+	}
+	encoded, err := protojson.Marshal(opts.DirectedReadOptions)
+	if err != nil {
+		return "", fmt.Errorf("invalid DirectedReadOptions: %w", err)
+	}
+	return string(encoded), nil
+}
+
+// tryInsertDirectedReads attaches Options.DirectedReadOptions to reads, ie:
+// the QUERY requests of SELECT statements and the EXECUTE requests of prepared
+// SELECT statements. DML statements and SERIAL reads, which Spanner serves
+// from the leader, are sent unchanged.
+func (re *requestExecutor) tryInsertDirectedReads(
+	frm *frame.Frame,
+	attachments map[string]string,
+) {
+	if re.directedReads == "" || isDML(frm) || isSerialRead(frm) {
+		return
+	}
+	switch frm.Body.Message.(type) {
+	case *message.Query, *message.Execute:
+		attachments[directedReadOptions] = re.directedReads
+	}
+}
@@ -0,0 +1,112 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestParseDirectedReadOptions(t *testing.T) {
+	opts, err := ParseDirectedReadOptions("", false)
+	require.NoError(t, err)
+	assert.Nil(t, opts)
+
+	opts, err = ParseDirectedReadOptions("us-east1:read_only, us-west1", false)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(&spannerpb.DirectedReadOptions{
+		Replicas: &spannerpb.DirectedReadOptions_IncludeReplicas_{
+			IncludeReplicas: &spannerpb.DirectedReadOptions_IncludeReplicas{
+				ReplicaSelections: []*spannerpb.DirectedReadOptions_ReplicaSelection{
+					{
+						Location: "us-east1",
+						Type:     spannerpb.DirectedReadOptions_ReplicaSelection_READ_ONLY,
+					},
+					{Location: "us-west1"},
+				},
+			},
+		},
+	}, opts))
+
+	opts, err = ParseDirectedReadOptions(":READ_WRITE", true)
+	require.NoError(t, err)
+	assert.Equal(t, spannerpb.DirectedReadOptions_ReplicaSelection_READ_WRITE,
+		opts.GetExcludeReplicas().GetReplicaSelections()[0].GetType())
+
+	_, err = ParseDirectedReadOptions("us-east1:WITNESS", false)
+	assert.ErrorContains(t, err, "invalid replica type")
+	_, err = ParseDirectedReadOptions("us-east1,,us-west1", false)
+	assert.ErrorContains(t, err, "invalid replica")
+}
+
+func TestInsertDirectedReads(t *testing.T) {
+	directed, err := ParseDirectedReadOptions("us-east1:READ_ONLY", false)
+	require.NoError(t, err)
+	attachment, err := directedReadAttachment(Options{DirectedReadOptions: directed})
+	require.NoError(t, err)
+	decoded := &spannerpb.DirectedReadOptions{}
+	require.NoError(t, protojson.Unmarshal([]byte(attachment), decoded))
+	assert.True(t, proto.Equal(directed, decoded))
+
+	re := &requestExecutor{directedReads: attachment}
+	attachments := func(msg message.Message) map[string]string {
+		attachments := make(map[string]string)
+		re.tryInsertDirectedReads(
+			frame.NewFrame(primitive.ProtocolVersion4, 0, msg),
+			attachments,
+		)
+		return attachments
+	}
+	one := &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}
+	serial := &message.QueryOptions{Consistency: primitive.ConsistencyLevelSerial}
+
+	assert.Equal(t, attachment, attachments(&message.Query{
+		Query:   "SELECT * FROM t",
+		Options: one,
+	})[directedReadOptions])
+	assert.Equal(t, attachment, attachments(&message.Execute{
+		QueryId: []byte("read"),
+		Options: one,
+	})[directedReadOptions])
+	assert.Empty(t, attachments(&message.Query{
+		Query:   "INSERT INTO t (id) VALUES (1)",
+		Options: one,
+	}))
+	assert.Empty(t, attachments(&message.Execute{
+		QueryId: []byte(writeActionQueryIdPrefix + "write"),
+		Options: one,
+	}))
+	assert.Empty(t, attachments(&message.Query{
+		Query:   "SELECT * FROM t",
+		Options: serial,
+	}))
+
+	// Unset options are not attached.
+	re.directedReads = ""
+	assert.Empty(t, attachments(&message.Query{Query: "SELECT * FROM t", Options: one}))
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// Environment variable enabling DirectPath, like Options.EnableDirectAccess.
+	directAccessEnv = "GOOGLE_SPANNER_ENABLE_DIRECT_ACCESS"
+	// Address prefixes of the Spanner backends reached over DirectPath.
+	directPathIPv4Prefix = "34.126."
+	directPathIPv6Prefix = "[2001:4860:8040"
+)
+
+// Whether the AdaptMessage calls of a client are served over DirectPath, as
+// last observed.
+const (
+	directPathUnknown int32 = iota
+	directPathUsed
+	directPathNotUsed
+)
+
+// directAccessEnabled reports whether DirectPath is enabled by
+// Options.EnableDirectAccess or by the GOOGLE_SPANNER_ENABLE_DIRECT_ACCESS
+// environment variable.
+func directAccessEnabled(opts Options) bool {
+	if opts.EnableDirectAccess {
+		return true
+	}
+	enabled, _ := strconv.ParseBool(os.Getenv(directAccessEnv))
+	return enabled
+}
+
+// validateDirectAccess returns an error if Options.EnableDirectAccess is set
+// along with options DirectPath can not be used with.
+func validateDirectAccess(opts Options) error {
+	if !opts.EnableDirectAccess {
+		return nil
+	}
+	if opts.UsePlainText {
+		return fmt.Errorf("EnableDirectAccess can not be used with plain-text connections")
+	}
+	if opts.ExperimentalHost {
+		return fmt.Errorf("EnableDirectAccess can not be used with experimental hosts")
+	}
+	return nil
+}
+
+// servedOverDirectPath reports whether the stream of an AdaptMessage call is
+// connected to Spanner over DirectPath, given the address of its peer.
+func servedOverDirectPath(stream grpc.ClientStream) bool {
+	p, ok := peer.FromContext(stream.Context())
+	if !ok || p.Addr == nil {
+		return false
+	}
+	addr := p.Addr.String()
+	return strings.HasPrefix(addr, directPathIPv4Prefix) ||
+		strings.HasPrefix(addr, directPathIPv6Prefix)
+}
+
+// recordDirectPath records whether an AdaptMessage call was served over
+// DirectPath, and logs whenever the calls of the client switch between
+// DirectPath and CloudPath.
+func (c *AdapterClient) recordDirectPath(used bool) {
+	c.stats.recordDirectPath(used)
+	state := directPathNotUsed
+	if used {
+		state = directPathUsed
+	}
+	if c.directPath.Swap(state) == state {
+		return
+	}
+	if used {
+		logger.Info("Spanner proxy is connected to Spanner over DirectPath",
+			zap.String("database_uri", c.opts.DatabaseUri))
+		return
+	}
+	logger.Warn("DirectPath is enabled but the Spanner proxy is connected to Spanner over CloudPath",
+		zap.String("database_uri", c.opts.DatabaseUri))
+}
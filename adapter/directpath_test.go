@@ -0,0 +1,82 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/peer"
+)
+
+// peerAdaptMessageClient is a response stream connected to addr.
+type peerAdaptMessageClient struct {
+	Mock_Cassandra_AdaptMessageClient
+	addr net.Addr
+}
+
+func (mc *peerAdaptMessageClient) Context() context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: mc.addr})
+}
+
+func TestDirectAccessEnabled(t *testing.T) {
+	t.Setenv(directAccessEnv, "")
+	assert.False(t, directAccessEnabled(Options{}))
+	assert.True(t, directAccessEnabled(Options{EnableDirectAccess: true}))
+
+	t.Setenv(directAccessEnv, "true")
+	assert.True(t, directAccessEnabled(Options{}))
+}
+
+func TestValidateDirectAccess(t *testing.T) {
+	assert.NoError(t, validateDirectAccess(Options{EnableDirectAccess: true}))
+	assert.NoError(t, validateDirectAccess(Options{UsePlainText: true}))
+	assert.Error(t, validateDirectAccess(Options{EnableDirectAccess: true, UsePlainText: true}))
+	assert.Error(t, validateDirectAccess(Options{EnableDirectAccess: true, ExperimentalHost: true}))
+}
+
+func TestServedOverDirectPath(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"34.126.10.1:443":         true,
+		"[2001:4860:8040::1]:443": true,
+		"142.250.1.1:443":         false,
+		"[2607:f8b0:4004::a]:443": false,
+	} {
+		tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+		assert.NoError(t, err)
+		stream := &peerAdaptMessageClient{addr: tcpAddr}
+		assert.Equal(t, want, servedOverDirectPath(stream), addr)
+	}
+	assert.False(t, servedOverDirectPath(&Mock_Cassandra_AdaptMessageClient{}))
+}
+
+func TestRecordDirectPath(t *testing.T) {
+	client := &AdapterClient{stats: newProxyStats()}
+	client.recordDirectPath(true)
+	client.recordDirectPath(true)
+	client.recordDirectPath(false)
+
+	assert.Equal(t, directPathNotUsed, client.directPath.Load())
+	stats := client.stats.snapshot()
+	assert.Equal(t, int64(2), stats.DirectPathCalls)
+	assert.Equal(t, int64(1), stats.CloudPathCalls)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import "time"
+
+// Default keepalive timeout and minimum connect timeout of grpc channels.
+const defaultGrpcTimeout = 20 * time.Second
+
+// EffectiveOptions returns the options the proxy runs with: the options it was
+// created with, with the defaults of unset options and the environment
+// variables (ie: GOOGLE_SPANNER_ENABLE_DIRECT_ACCESS) applied, and with the
+// address the proxy listens on as TCPEndpoint. The maps and slices of the
+// options are shared with the proxy and must not be modified.
+func (proxy *TCPProxy) EffectiveOptions() Options {
+	opts := proxy.opts
+	opts.TCPEndpoint = proxy.Addr().String()
+	if opts.SpannerEndpoint == "" {
+		opts.SpannerEndpoint = defaultSpannerEndpoint
+	}
+	if opts.MaxGrpcChannels > 0 {
+		opts.MinGrpcChannels = max(opts.MinGrpcChannels, 1)
+		opts.MaxGrpcChannels = max(opts.MaxGrpcChannels, opts.MinGrpcChannels)
+	}
+	if opts.UnhealthyChannelThreshold == 0 {
+		opts.UnhealthyChannelThreshold = defaultUnhealthyChannelThreshold
+	}
+	if opts.GrpcKeepaliveTime > 0 && opts.GrpcKeepaliveTimeout <= 0 {
+		opts.GrpcKeepaliveTimeout = defaultGrpcTimeout
+	}
+	if opts.GrpcDialNetwork == "" {
+		opts.GrpcDialNetwork = "tcp"
+	}
+	if opts.GrpcCompression == "" {
+		opts.GrpcCompression = "none"
+	}
+	if opts.GrpcMinConnectTimeout <= 0 {
+		opts.GrpcMinConnectTimeout = defaultGrpcTimeout
+	}
+	if opts.MaxTransactionRetries == 0 {
+		opts.MaxTransactionRetries = defaultMaxTransactionRetries
+	}
+	if opts.RetryBackoff.Initial == 0 {
+		opts.RetryBackoff = DefaultRetryBackoff
+	}
+	if opts.RetryBudgetRatio > 0 && opts.RetryBudgetBurst <= 0 {
+		opts.RetryBudgetBurst = defaultRetryBudgetBurst
+	}
+	if opts.EnableChangeStreams && opts.ChangeStreamReadTimeout <= 0 {
+		opts.ChangeStreamReadTimeout = defaultChangeStreamReadTimeout
+	}
+	if len(opts.ReadCacheTTLs) > 0 && opts.ReadCacheSize <= 0 {
+		opts.ReadCacheSize = defaultReadCacheSize
+	}
+	return opts
+}
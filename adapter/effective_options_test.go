@@ -0,0 +1,53 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEffectiveOptions(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{
+		MaxGrpcChannels:   8,
+		GrpcKeepaliveTime: time.Minute,
+		RetryBudgetRatio:  0.1,
+	})
+
+	opts := proxy.EffectiveOptions()
+	assert.Equal(t, proxy.Addr().String(), opts.TCPEndpoint)
+	assert.Equal(t, defaultSpannerEndpoint, opts.SpannerEndpoint)
+	assert.Equal(t, "tcp", opts.ListenNetwork)
+	assert.Equal(t, "tcp", opts.GrpcDialNetwork)
+	assert.Equal(t, "none", opts.GrpcCompression)
+	assert.Equal(t, 4, opts.NumGrpcChannels)
+	assert.Equal(t, 1, opts.MinGrpcChannels)
+	assert.Equal(t, 8, opts.MaxGrpcChannels)
+	assert.Equal(t, defaultUnhealthyChannelThreshold, opts.UnhealthyChannelThreshold)
+	assert.Equal(t, defaultGrpcTimeout, opts.GrpcKeepaliveTimeout)
+	assert.Equal(t, defaultGrpcTimeout, opts.GrpcMinConnectTimeout)
+	assert.Equal(t, defaultMaxTransactionRetries, opts.MaxTransactionRetries)
+	assert.Equal(t, DefaultRetryBackoff, opts.RetryBackoff)
+	assert.Equal(t, defaultRetryBudgetBurst, opts.RetryBudgetBurst)
+	assert.Equal(t, defaultMaxFrameSize, opts.MaxFrameSize)
+	assert.Equal(t, int(maxGlobalStateSize), opts.PreparedCacheSize)
+}
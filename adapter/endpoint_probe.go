@@ -0,0 +1,149 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	vkit "cloud.google.com/go/spanner/adapter/apiv1"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+)
+
+// Number of connections dialed to each candidate endpoint at startup, the
+// fastest of which gives the latency of the endpoint.
+const endpointProbeSamples = 3
+
+// measureEndpointLatency returns the time it takes to establish a connection
+// to endpoint, overwritten in tests.
+var measureEndpointLatency = func(
+	ctx context.Context,
+	opts Options,
+	endpoint string,
+) (time.Duration, error) {
+	opts.SpannerEndpoint = endpoint
+	clientOpts, err := getAllClientOpts(opts)
+	if err != nil {
+		return 0, err
+	}
+	client, err := vkit.NewClient(ctx, append(
+		clientOpts,
+		option.WithGRPCConnectionPool(1),
+	)...)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	start := time.Now()
+	if err := waitForReady(ctx, client.Connection(), endpoint); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// endpointProbe is the outcome of the startup probe of a candidate endpoint.
+type endpointProbe struct {
+	endpoint string
+	latency  time.Duration
+	err      error
+}
+
+// probeEndpointLatency returns the lowest latency of endpointProbeSamples
+// connections to endpoint, each of which has endpointHealthCheckTimeout to
+// become ready.
+func probeEndpointLatency(opts Options, endpoint string) endpointProbe {
+	probe := endpointProbe{endpoint: endpoint}
+	for i := 0; i < endpointProbeSamples; i++ {
+		ctx, cancel := context.WithTimeout(
+			context.Background(),
+			endpointHealthCheckTimeout,
+		)
+		latency, err := measureEndpointLatency(ctx, opts, endpoint)
+		cancel()
+		if err != nil {
+			probe.err = err
+			return probe
+		}
+		if i == 0 || latency < probe.latency {
+			probe.latency = latency
+		}
+	}
+	return probe
+}
+
+// selectSpannerEndpoint probes SpannerEndpoint and Options.CandidateEndpoints
+// concurrently, and returns opts with SpannerEndpoint set to the healthy
+// endpoint of lowest latency. SpannerEndpoint is kept if it is the fastest or
+// if no endpoint passes the probe, which is logged as a warning since the
+// proxy may then fail to serve requests.
+func selectSpannerEndpoint(opts Options) Options {
+	if len(opts.CandidateEndpoints) == 0 {
+		return opts
+	}
+	configured := opts.SpannerEndpoint
+	if configured == "" {
+		configured = defaultSpannerEndpoint
+	}
+	endpoints := []string{configured}
+	for _, endpoint := range opts.CandidateEndpoints {
+		if endpoint != configured {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	probes := make([]endpointProbe, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probes[i] = probeEndpointLatency(opts, endpoint)
+		}()
+	}
+	wg.Wait()
+
+	var best *endpointProbe
+	for i := range probes {
+		probe := &probes[i]
+		if probe.err != nil {
+			logger.Warn("Spanner endpoint failed startup probe",
+				zap.String("endpoint", probe.endpoint),
+				zap.Error(probe.err))
+			continue
+		}
+		logger.Info("Probed Spanner endpoint",
+			zap.String("endpoint", probe.endpoint),
+			zap.Duration("latency", probe.latency))
+		if best == nil || probe.latency < best.latency {
+			best = probe
+		}
+	}
+	if best == nil {
+		logger.Warn("No Spanner endpoint passed the startup probe, keeping the configured endpoint",
+			zap.String("endpoint", configured))
+		return opts
+	}
+	logger.Info("Selected Spanner endpoint",
+		zap.String("endpoint", best.endpoint),
+		zap.String("configured_endpoint", configured),
+		zap.Duration("latency", best.latency))
+	opts.SpannerEndpoint = best.endpoint
+	return opts
+}
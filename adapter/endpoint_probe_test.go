@@ -0,0 +1,106 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLatencies replaces measureEndpointLatency with the given latencies of
+// each endpoint, endpoints without latency failing the probe, and returns the
+// number of probes of each endpoint.
+func fakeLatencies(t *testing.T, latencies map[string]time.Duration) map[string]int {
+	t.Helper()
+	var mu sync.Mutex
+	probes := make(map[string]int)
+	original := measureEndpointLatency
+	t.Cleanup(func() { measureEndpointLatency = original })
+	measureEndpointLatency = func(
+		ctx context.Context,
+		opts Options,
+		endpoint string,
+	) (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		probes[endpoint]++
+		latency, ok := latencies[endpoint]
+		if !ok {
+			return 0, errors.New("unreachable")
+		}
+		// Later samples are faster once the connection is warmed up.
+		return latency - time.Duration(probes[endpoint]), nil
+	}
+	return probes
+}
+
+func TestSelectSpannerEndpoint(t *testing.T) {
+	probes := fakeLatencies(t, map[string]time.Duration{
+		defaultSpannerEndpoint:                    30 * time.Millisecond,
+		"us-east1-spanner.googleapis.com:443":     5 * time.Millisecond,
+		"europe-west1-spanner.googleapis.com:443": 80 * time.Millisecond,
+	})
+	opts := selectSpannerEndpoint(Options{
+		CandidateEndpoints: []string{
+			"europe-west1-spanner.googleapis.com:443",
+			"us-east1-spanner.googleapis.com:443",
+			"unreachable:443",
+		},
+	})
+	assert.Equal(t, "us-east1-spanner.googleapis.com:443", opts.SpannerEndpoint)
+	assert.Equal(t, map[string]int{
+		defaultSpannerEndpoint:                    endpointProbeSamples,
+		"us-east1-spanner.googleapis.com:443":     endpointProbeSamples,
+		"europe-west1-spanner.googleapis.com:443": endpointProbeSamples,
+		"unreachable:443":                         1,
+	}, probes)
+}
+
+func TestSelectSpannerEndpointKeepsConfigured(t *testing.T) {
+	probes := fakeLatencies(t, map[string]time.Duration{
+		"primary:443": 5 * time.Millisecond,
+		"other:443":   10 * time.Millisecond,
+	})
+
+	// No candidates: nothing is probed.
+	opts := selectSpannerEndpoint(Options{SpannerEndpoint: "primary:443"})
+	assert.Equal(t, "primary:443", opts.SpannerEndpoint)
+	assert.Empty(t, probes)
+
+	// The configured endpoint is the fastest.
+	opts = selectSpannerEndpoint(Options{
+		SpannerEndpoint:    "primary:443",
+		CandidateEndpoints: []string{"primary:443", "other:443"},
+	})
+	assert.Equal(t, "primary:443", opts.SpannerEndpoint)
+	assert.Equal(t, endpointProbeSamples, probes["primary:443"])
+
+	// No endpoint passes the probe.
+	opts = selectSpannerEndpoint(Options{
+		SpannerEndpoint:    "down:443",
+		CandidateEndpoints: []string{"unreachable:443"},
+	})
+	assert.Equal(t, "down:443", opts.SpannerEndpoint)
+}
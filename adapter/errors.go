@@ -0,0 +1,213 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Marker preceding the serialized SpannerError in CQL error messages.
+const spannerErrorMarker = " spanner_error="
+
+// SpannerError describes a failure returned by Spanner, or encountered while
+// communicating with Spanner, on behalf of a driver request.
+//
+// SpannerErrors are sent to the driver as CQL server errors whose message
+// embeds the serialized error, use ParseSpannerError to recover it.
+type SpannerError struct {
+	// gRPC status code of the failure.
+	Code codes.Code `json:"code"`
+	// Error message returned by Spanner.
+	Message string `json:"message"`
+	// Whether the request may succeed if retried.
+	Retryable bool `json:"retryable"`
+	// Delay suggested by Spanner before retrying, if any.
+	RetryDelay time.Duration `json:"retry_delay,omitempty"`
+	// Name of the resource the failure relates to. Defaults to the database.
+	ResourceName string `json:"resource_name,omitempty"`
+	// Reason reported in the error details, if any.
+	Reason string `json:"reason,omitempty"`
+	// Id of the failed AdaptMessage attempt, to quote when contacting Google
+	// support.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *SpannerError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf(
+			"spanner: code = %q, desc = %q, request_id = %q",
+			e.Code,
+			e.Message,
+			e.RequestID,
+		)
+	}
+	return fmt.Sprintf("spanner: code = %q, desc = %q", e.Code, e.Message)
+}
+
+// newSpannerError converts err into a SpannerError, using resourceName if the
+// error does not carry resource information itself.
+func newSpannerError(err error, resourceName string) *SpannerError {
+	s, ok := status.FromError(err)
+	if !ok {
+		s = status.FromContextError(err)
+	}
+	// Use a fresh retryer so that the backoff state of requests is unaffected.
+	_, retryable := onCodes(
+		DefaultRetryBackoff,
+		codes.ResourceExhausted,
+		codes.Internal,
+		codes.Unavailable,
+	).Retry(s.Err())
+	spannerErr := &SpannerError{
+		Code:         s.Code(),
+		Message:      s.Message(),
+		Retryable:    retryable,
+		ResourceName: resourceName,
+	}
+	if delay, ok := ExtractRetryDelay(s.Err()); ok {
+		spannerErr.RetryDelay = delay
+	}
+	for _, detail := range s.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ResourceInfo:
+			spannerErr.ResourceName = d.GetResourceName()
+		case *errdetails.ErrorInfo:
+			spannerErr.Reason = d.GetReason()
+		}
+	}
+	return spannerErr
+}
+
+// cqlMessage returns the CQL error message embedding the serialized error.
+func (e *SpannerError) cqlMessage() string {
+	serialized, err := json.Marshal(e)
+	if err != nil {
+		return e.Error()
+	}
+	return e.Error() + spannerErrorMarker + string(serialized)
+}
+
+// ParseSpannerError recovers the SpannerError embedded in a CQL error message.
+func ParseSpannerError(cqlMessage string) (*SpannerError, bool) {
+	i := strings.LastIndex(cqlMessage, spannerErrorMarker)
+	if i < 0 {
+		return nil, false
+	}
+	spannerErr := &SpannerError{}
+	if err := json.Unmarshal(
+		[]byte(cqlMessage[i+len(spannerErrorMarker):]),
+		spannerErr,
+	); err != nil {
+		return nil, false
+	}
+	return spannerErr, true
+}
+
+// serverErrorMessage returns the CQL server error sent to the driver for a
+// failure encountered while serving a request.
+func (dc *driverConnection) serverErrorMessage(err error) message.Message {
+	return &message.ServerError{
+		ErrorMessage: newSpannerError(
+			err,
+			dc.adapterClient.opts.DatabaseUri,
+		).cqlMessage(),
+	}
+}
+
+// requestErrorMessage returns the CQL error sent to the driver for a failure
+// of a request sent to Spanner, carrying the id of its last attempt.
+// Connections that set the THROW_ON_OVERLOAD STARTUP option get an Overloaded
+// error when Spanner's resources are exhausted.
+func (dc *driverConnection) requestErrorMessage(
+	req *requestState,
+	err error,
+) message.Message {
+	if msg := responseSizeMessage(err); msg != nil {
+		return msg
+	}
+	spannerErr := newSpannerError(err, dc.adapterClient.opts.DatabaseUri)
+	spannerErr.RequestID = req.requestID
+	if req.commit {
+		describeTransactionFailure(spannerErr, req)
+	}
+	if dc.throwOnOverload && status.Code(err) == codes.ResourceExhausted {
+		return &message.Overloaded{ErrorMessage: spannerErr.cqlMessage()}
+	}
+	return &message.ServerError{ErrorMessage: spannerErr.cqlMessage()}
+}
+
+// writeRequestError answers req with the CQL error of err. The errors of
+// requests Spanner rejected as overloaded carry the retry delay it suggested,
+// in the RetryAfterPayloadKey custom payload and in a warning, and are
+// delayed by it, up to Options.MaxOverloadErrorDelay, so that the retry
+// policies of drivers do not send the request again right away.
+func (dc *driverConnection) writeRequestError(
+	ctx context.Context,
+	req *requestState,
+	err error,
+) error {
+	dc.completeBatchToken(req, nil)
+	msg := dc.requestErrorMessage(req, err)
+	delay, ok := ExtractRetryDelay(err)
+	if !ok || status.Code(err) != codes.ResourceExhausted {
+		return dc.writeMessageBackToTcp(req.frame.Header, msg)
+	}
+	frm := frame.NewFrame(req.frame.Header.Version, req.frame.Header.StreamId, msg)
+	frm.Header.IsResponse = true
+	if req.frame.Header.Version >= primitive.ProtocolVersion4 {
+		frm.SetCustomPayload(map[string][]byte{
+			RetryAfterPayloadKey: []byte(delay.String()),
+		})
+		frm.SetWarnings([]string{
+			fmt.Sprintf("Spanner is overloaded, retry after %v", delay),
+		})
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(frm, buf); err != nil {
+		return err
+	}
+	if wait := min(delay, dc.executor.opts.MaxOverloadErrorDelay); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	if _, err := dc.driverConn.Write(buf.Bytes()); err != nil {
+		logger.Error("Error writing message back to tcp ",
+			zap.Int("connectionID", dc.connectionID),
+			zap.Error(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestNewSpannerError(t *testing.T) {
+	db := "projects/p/instances/i/databases/d"
+	st, err := status.New(codes.ResourceExhausted, "quota exceeded").
+		WithDetails(
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(2 * time.Second)},
+			&errdetails.ErrorInfo{Reason: "RATE_LIMIT_EXCEEDED"},
+		)
+	require.NoError(t, err)
+	notFound, err := status.New(codes.NotFound, "table not found").
+		WithDetails(&errdetails.ResourceInfo{ResourceName: "users"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		err  error
+		want *SpannerError
+	}{
+		{
+			name: "Retryable with details",
+			err:  st.Err(),
+			want: &SpannerError{
+				Code:         codes.ResourceExhausted,
+				Message:      "quota exceeded",
+				Retryable:    true,
+				RetryDelay:   2 * time.Second,
+				ResourceName: db,
+				Reason:       "RATE_LIMIT_EXCEEDED",
+			},
+		},
+		{
+			name: "Resource info",
+			err:  notFound.Err(),
+			want: &SpannerError{
+				Code:         codes.NotFound,
+				Message:      "table not found",
+				ResourceName: "users",
+			},
+		},
+		{
+			name: "Context error",
+			err:  context.DeadlineExceeded,
+			want: &SpannerError{
+				Code:         codes.DeadlineExceeded,
+				Message:      context.DeadlineExceeded.Error(),
+				ResourceName: db,
+			},
+		},
+		{
+			name: "Non grpc error",
+			err:  errors.New("connection reset"),
+			want: &SpannerError{
+				Code:         codes.Unknown,
+				Message:      "connection reset",
+				ResourceName: db,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, newSpannerError(tc.err, db))
+		})
+	}
+}
+
+func TestParseSpannerError(t *testing.T) {
+	want := &SpannerError{
+		Code:         codes.Unavailable,
+		Message:      `connection "refused"`,
+		Retryable:    true,
+		ResourceName: "projects/p/instances/i/databases/d",
+	}
+	got, ok := ParseSpannerError(want.cqlMessage())
+	require.True(t, ok)
+	assert.Equal(t, want, got)
+
+	_, ok = ParseSpannerError("Unknown prepared query in client side cache")
+	assert.False(t, ok)
+	_, ok = ParseSpannerError("spanner: broken" + spannerErrorMarker + "{")
+	assert.False(t, ok)
+}
+
+func TestWriteRequestErrorRetryAfter(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{opts: Options{DatabaseUri: "db"}},
+		executor: &requestExecutor{opts: &Options{
+			MaxOverloadErrorDelay: 50 * time.Millisecond,
+		}},
+		driverConn: conn,
+		codec:      codec,
+	}
+	st, err := status.New(codes.ResourceExhausted, "quota exceeded").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Second)})
+	require.NoError(t, err)
+	req := &requestState{
+		frame: *frame.NewFrame(primitive.ProtocolVersion4, 7, &message.Query{Query: "SELECT 1"}),
+	}
+
+	start := time.Now()
+	go dc.writeRequestError(context.Background(), req, st.Err())
+	buf := make([]byte, 4096)
+	n, err := peer.Read(buf)
+	require.NoError(t, err)
+	// The error is delayed by the retry delay, up to MaxOverloadErrorDelay.
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	frm, err := codec.DecodeFrame(bytes.NewBuffer(buf[:n]))
+	require.NoError(t, err)
+	assert.Equal(t, int16(7), frm.Header.StreamId)
+	assert.IsType(t, &message.ServerError{}, frm.Body.Message)
+	assert.Equal(t, []byte("1s"), frm.Body.CustomPayload[RetryAfterPayloadKey])
+	assert.Len(t, frm.Body.Warnings, 1)
+}
@@ -18,14 +18,17 @@ package adapter
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/googleapis/gax-go/v2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -36,7 +39,7 @@ var (
 		cl *AdapterClient,
 	) (adapterpb.Adapter_AdaptMessageClient, error) {
 		var md metadata.MD
-		request, err := cl.gapicClient.AdaptMessage(
+		request, err := channelOf(ctx, cl).gapicClient().AdaptMessage(
 			ctx,
 			req,
 			gax.WithGRPCOptions(grpc.Header(&md)),
@@ -58,8 +61,8 @@ func isDML(frame *frame.Frame) bool {
 		// Batch messsage is always DML
 		return true
 	case *message.Query:
-		// Query message is DML if query string does not start with "select"
-		return !strings.HasPrefix(strings.ToLower(msg.Query), "select")
+		// Query message is DML unless it is a read-only statement.
+		return !isCQLRead(msg.Query)
 	default:
 		return false
 	}
@@ -70,6 +73,57 @@ type requestExecutor struct {
 	client      *AdapterClient
 	globalState *globalState
 	opts        *Options
+	// Statements of prepared query ids, nil unless EnableBatchReprepare is set.
+	statements *preparedStatements
+	// Rewrites of prepared statements setting a TTL, nil unless TTLColumns is
+	// set.
+	ttlStatements *ttlStatements
+	// Warnings of prepared query ids.
+	warnings *preparedWarnings
+	// Names of the bind markers of prepared query ids with named markers.
+	markers *preparedMarkers
+	// Tables of prepared query ids whose statements can be applied as
+	// mutations.
+	mutations *mutationStatements
+	// Compiled Options.StatementPolicy, nil if unset.
+	policy *statementPolicy
+	// Cached responses of reads, nil unless ReadCacheTTLs is set.
+	readCache *readCache
+	// Prepared query ids whose statements scan a whole table, nil unless
+	// FullScanPolicy is FullScanLowPriority.
+	fullScans *fullScanStatements
+	// Databases of the tables of Options.TableRouting, nil if unset.
+	router *tableRouter
+	// Writes declared idempotent.
+	idempotent *idempotentStatements
+	// Result columns of prepared query ids returning sets or maps, nil if
+	// DisableCollectionOrdering is set.
+	collections *collectionStatements
+	// Reader of the change streams queried through the cdc keyspace, nil
+	// unless EnableChangeStreams is set.
+	changeStreams *changeStreamReader
+	// CQL types of the columns of Options.JSONColumns, nil if unset.
+	jsonColumns *jsonColumns
+	// Codecs of the columns of Options.ColumnCodecs, nil if unset.
+	columnCodecs *columnCodecs
+	// Sampler of the requests recorded to Options.CaptureFile, nil if unset.
+	capture *requestCapture
+	// Bind variables of prepared query ids, nil if DisableBindValidation is
+	// set.
+	variables *preparedVariables
+	// Remaining rows of the results paged by the proxy, nil unless
+	// CqlshCompatibility is set.
+	pages *pagedResults
+	// Metrics of the statements by fingerprint, nil unless
+	// Options.EnableStatementMetrics is set.
+	fingerprints *statementMetrics
+	// Attachment of Options.DirectedReadOptions, empty if unset.
+	directedReads string
+	// Partition keys of prepared query ids, nil unless a batch atomicity
+	// other than BatchAtomic is set.
+	partitionKeys *partitionKeys
+	// Batches of idempotency tokens, nil unless BatchDedupCacheSize is set.
+	batchTokens *batchTokens
 }
 
 func (re *requestExecutor) tryInsertAttachment(
@@ -88,9 +142,129 @@ func (re *requestExecutor) tryInsertAttachment(
 	}
 }
 
+// tryInsertTimestampBound translates the timestamp bound custom payloads of a
+// read into attachments. Returns an error message if the payloads are invalid
+// or attached to a DML statement.
+func (re *requestExecutor) tryInsertTimestampBound(
+	frame *frame.Frame, attachments map[string]string,
+) message.Message {
+	ts, hasTs := frame.Body.CustomPayload[ReadTimestampPayloadKey]
+	staleness, hasStaleness := frame.Body.CustomPayload[ExactStalenessPayloadKey]
+	if !hasTs && !hasStaleness {
+		return nil
+	}
+	if hasTs && hasStaleness {
+		return &message.Invalid{
+			ErrorMessage: fmt.Sprintf(
+				"Only one of %s and %s can be set",
+				ReadTimestampPayloadKey,
+				ExactStalenessPayloadKey,
+			),
+		}
+	}
+	if isDML(frame) {
+		return &message.Invalid{
+			ErrorMessage: "Timestamp bounds are only supported for read-only queries",
+		}
+	}
+	if hasTs {
+		t, err := time.Parse(time.RFC3339Nano, string(ts))
+		if err != nil {
+			return &message.Invalid{
+				ErrorMessage: fmt.Sprintf(
+					"Invalid %s: %v",
+					ReadTimestampPayloadKey,
+					err,
+				),
+			}
+		}
+		attachments[readTimestamp] = t.UTC().Format(time.RFC3339Nano)
+		return nil
+	}
+	d, err := time.ParseDuration(string(staleness))
+	if err != nil || d < 0 {
+		return &message.Invalid{
+			ErrorMessage: fmt.Sprintf(
+				"Invalid %s: %q is not a non-negative duration",
+				ExactStalenessPayloadKey,
+				staleness,
+			),
+		}
+	}
+	attachments[exactStaleness] = d.String()
+	return nil
+}
+
+// tryInsertPartitionedDML marks qualifying DML statements for Partitioned DML
+// execution. A statement qualifies if the driver requested it through a custom
+// payload, or if it is an UPDATE or DELETE query on a table configured in
+// Options.EnablePartitionedDMLFor.
+func (re *requestExecutor) tryInsertPartitionedDML(
+	frame *frame.Frame, attachments map[string]string,
+) message.Message {
+	requested := len(frame.Body.CustomPayload[PartitionedDMLPayloadKey]) > 0
+	if requested && !isDML(frame) {
+		return &message.Invalid{
+			ErrorMessage: "Partitioned DML is only supported for UPDATE and DELETE statements",
+		}
+	}
+	if query, ok := frame.Body.Message.(*message.Query); ok {
+		kind, table, ok := parseDMLTarget(query.Query)
+		if requested && (!ok || kind == "insert") {
+			return &message.Invalid{
+				ErrorMessage: "Partitioned DML is only supported for UPDATE and DELETE statements",
+			}
+		}
+		if ok && kind != "insert" &&
+			matchesTable(re.opts.EnablePartitionedDMLFor, table) {
+			requested = true
+		}
+	}
+	if requested {
+		attachments[partitionedDML] = "true"
+	}
+	return nil
+}
+
 func (re *requestExecutor) prepareCassandraAttachments(
 	frame *frame.Frame, req *requestState) message.Message {
+	if err := re.tryCheckPolicy(frame); err != nil {
+		return err
+	}
 	switch msg := frame.Body.Message.(type) {
+	case *message.Query:
+		req.pb.Attachments = make(map[string]string)
+		if err := re.tryInsertTimestampBound(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryApplyConsistency(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryInsertPartitionedDML(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryInsertMutation(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		re.tryRequestRowCount(frame, req.pb.Attachments)
+		re.tryInsertDirectedReads(frame, req.pb.Attachments)
+		if err := re.tryCheckWriteTimestamp(frame); err != nil {
+			return err
+		}
+		if err := re.tryCheckFullScan(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryInsertProvidedAttachments(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+	case *message.Prepare:
+		// Executions of the statement are only checked when it is prepared.
+		if err := re.tryCheckWriteTimestamp(frame); err != nil {
+			return err
+		}
+		if err := re.tryCheckFullScan(frame, nil); err != nil {
+			return err
+		}
 	case *message.Execute:
 		req.pb.Attachments = make(map[string]string)
 		if re.opts.MaxCommitDelay > 0 && isDML(frame) {
@@ -100,8 +274,43 @@ func (re *requestExecutor) prepareCassandraAttachments(
 		if err != nil {
 			return err
 		}
+		if err := re.tryInsertTimestampBound(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryApplyConsistency(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryInsertPartitionedDML(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryInsertMutation(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		re.tryRequestRowCount(frame, req.pb.Attachments)
+		re.tryInsertDirectedReads(frame, req.pb.Attachments)
+		if err := re.tryCheckFullScan(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryInsertProvidedAttachments(frame, req.pb.Attachments); err != nil {
+			return err
+		}
 	case *message.Batch:
+		if len(frame.Body.CustomPayload[PartitionedDMLPayloadKey]) > 0 {
+			return &message.Invalid{
+				ErrorMessage: "Partitioned DML is not supported for batches",
+			}
+		}
 		req.pb.Attachments = make(map[string]string)
+		if err := re.tryApplyConsistency(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		if err := re.tryCheckWriteTimestamp(frame); err != nil {
+			return err
+		}
+		if err := re.tryInsertMutation(frame, req.pb.Attachments); err != nil {
+			return err
+		}
+		re.tryRequestRowCount(frame, req.pb.Attachments)
 		// Batch is always DML.
 		if re.opts.MaxCommitDelay > 0 {
 			req.pb.Attachments[maxCommitDelay] = strconv.Itoa(re.opts.MaxCommitDelay)
@@ -111,46 +320,119 @@ func (re *requestExecutor) prepareCassandraAttachments(
 			// batch.
 			if child.Query == "" {
 				// Reject entire batch and return an unprepared error back to driver on
-				// first seen local prepared query cache miss.
+				// first seen local prepared query cache miss. With
+				// EnableBatchReprepare, known statements were already prepared again
+				// by reprepareBatch.
 				err := re.tryInsertAttachment(child.Id, req.pb.Attachments)
 				if err != nil {
 					return err
 				}
 			}
 		}
+		if err := re.tryInsertProvidedAttachments(frame, req.pb.Attachments); err != nil {
+			return err
+		}
 	default:
 		return nil
 	}
-	return nil
+	return re.tryCheckSupportedAttachments(req.pb.Attachments)
 }
 
+// submit sends the request to the server and returns the response stream
+// together with the channel it was sent on.
 func (re *requestExecutor) submit(
 	ctx context.Context,
 	req *requestState,
 	enableRouteToLeader bool,
-) (adapterpb.Adapter_AdaptMessageClient, error) {
+) (adapterpb.Adapter_AdaptMessageClient, *grpcChannel, error) {
+	client := re.client
+	if req.client != nil {
+		client = req.client
+	}
 	ctxWithMd := contextWithOutgoingMetadata(
 		ctx,
-		re.client.getMetadata(),
+		client.getMetadata(),
 		enableRouteToLeader,
 	)
-	pbCli, err := RunAdaptMessageWithRetry(
+	var ch *grpcChannel
+	pool := client.poolFor(enableRouteToLeader)
+	attempts := 0
+	request := client.requestCount.Add(1)
+	mt := client.metrics.createBuiltinMetricsTracer(ctx)
+	mt.method = adaptMessageMethod
+	req.metrics = &mt
+	// Connections that asked to be told about overload don't wait for Spanner
+	// to accept their requests.
+	retryCodes := []codes.Code{codes.ResourceExhausted, codes.Internal, codes.Unavailable}
+	if req.throwOnOverload {
+		retryCodes = retryCodes[1:]
+	}
+	// Requests of protocols other than Cassandra are idempotent unless they
+	// write.
+	idempotent := !enableRouteToLeader
+	if req.frame.Body != nil {
+		idempotent = re.idempotent.isIdempotent(&req.frame)
+	}
+	backoff := re.opts.RetryBackoff
+	if backoff.Initial == 0 {
+		backoff = DefaultRetryBackoff
+	}
+	req.retryBudget.deposit()
+	pbCli, err := runAdaptMessageWithRetry(
 		ctx,
-		re.client.opts.DisableAdaptMessageRetry,
+		adaptMessageRetry{
+			disabled:   client.opts.DisableAdaptMessageRetry,
+			codes:      retryCodes,
+			idempotent: idempotent,
+			backoff:    backoff,
+			jitter:     re.opts.RetryJitter,
+			budget:     req.retryBudget,
+			stats:      client.stats,
+		},
 		func(ctx context.Context) (adapterpb.Adapter_AdaptMessageClient, error) {
-			return AdaptMessageGrpc(
-				ctxWithMd,
+			// Retries are sent on any channel.
+			if re.opts.ChannelAffinity && attempts == 0 {
+				ch = pool.acquireAffine(req.affinityKey)
+			} else {
+				ch = pool.acquire()
+			}
+			if attempts++; attempts > 1 {
+				client.stats.recordRetry()
+			}
+			start := time.Now()
+			mt.startAttempt()
+			req.requestID = requestID(client.clientID, ch.id, request, attempts)
+			req.attempts = attempts
+			pbCli, err := AdaptMessageGrpc(
+				withChannel(metadata.AppendToOutgoingContext(
+					ctxWithMd,
+					requestIDHeader,
+					req.requestID,
+				), ch),
 				req.pb,
-				re.client,
+				client,
 			)
+			if err != nil {
+				client.recordResult(ch, time.Since(start), err)
+				recordAttemptCompletion(&mt, err)
+			}
+			return pbCli, err
 		},
 	)
 	if err != nil {
-		return nil, err
+		return nil, ch, err
 	}
 	if err := pbCli.CloseSend(); err != nil {
-		return nil, err
+		client.recordResult(ch, 0, err)
+		return nil, ch, err
+	}
+	if client.opts.EnableDirectAccess {
+		directPath := servedOverDirectPath(pbCli)
+		if mt.currOp != nil {
+			mt.currOp.directPathUsed = directPath
+		}
+		client.recordDirectPath(directPath)
 	}
 
-	return pbCli, nil
+	return pbCli, ch, nil
 }
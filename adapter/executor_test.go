@@ -22,9 +22,11 @@ package adapter
 import (
 	"testing"
 
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestIsDML(t *testing.T) {
@@ -106,6 +108,21 @@ func TestIsDML(t *testing.T) {
 			expected: true,
 		},
 
+		{
+			name: "Query message with leading whitespace and comment",
+			frame: newFrameWithMessage(&message.Query{
+				Query: "\n  /* dashboard */ SELECT * from users",
+			}),
+			expected: false,
+		},
+		{
+			name: "Query message with BEGIN BATCH",
+			frame: newFrameWithMessage(&message.Query{
+				Query: "BEGIN BATCH UPDATE users SET a = 1 WHERE id = 1; APPLY BATCH",
+			}),
+			expected: true,
+		},
+
 		// Other messages
 		{
 			name:     "Default message type - Options",
@@ -138,3 +155,177 @@ func TestIsDML(t *testing.T) {
 		})
 	}
 }
+
+func TestPrepareTimestampBoundAttachments(t *testing.T) {
+	re := &requestExecutor{opts: &Options{
+		SupportedAttachments: []string{readTimestamp, exactStaleness},
+	}}
+	newFrame := func(msg message.Message, payload map[string][]byte) *frame.Frame {
+		return &frame.Frame{
+			Header: &frame.Header{
+				Version: primitive.ProtocolVersion4,
+				OpCode:  msg.GetOpCode(),
+			},
+			Body: &frame.Body{Message: msg, CustomPayload: payload},
+		}
+	}
+	selectQuery := &message.Query{Query: "SELECT * FROM t"}
+
+	testCases := []struct {
+		name            string
+		frame           *frame.Frame
+		wantAttachments map[string]string
+		wantErr         bool
+	}{
+		{
+			name:            "No payload",
+			frame:           newFrame(selectQuery, nil),
+			wantAttachments: map[string]string{},
+		},
+		{
+			name: "Read timestamp",
+			frame: newFrame(selectQuery, map[string][]byte{
+				ReadTimestampPayloadKey: []byte("2025-01-02T03:04:05.5+01:00"),
+			}),
+			wantAttachments: map[string]string{
+				readTimestamp: "2025-01-02T02:04:05.5Z",
+			},
+		},
+		{
+			name: "Exact staleness",
+			frame: newFrame(selectQuery, map[string][]byte{
+				ExactStalenessPayloadKey: []byte("15s"),
+			}),
+			wantAttachments: map[string]string{exactStaleness: "15s"},
+		},
+		{
+			name: "Invalid read timestamp",
+			frame: newFrame(selectQuery, map[string][]byte{
+				ReadTimestampPayloadKey: []byte("yesterday"),
+			}),
+			wantErr: true,
+		},
+		{
+			name: "Negative staleness",
+			frame: newFrame(selectQuery, map[string][]byte{
+				ExactStalenessPayloadKey: []byte("-1s"),
+			}),
+			wantErr: true,
+		},
+		{
+			name: "Both bounds",
+			frame: newFrame(selectQuery, map[string][]byte{
+				ReadTimestampPayloadKey:  []byte("2025-01-02T03:04:05Z"),
+				ExactStalenessPayloadKey: []byte("15s"),
+			}),
+			wantErr: true,
+		},
+		{
+			name: "DML",
+			frame: newFrame(
+				&message.Query{Query: "DELETE FROM t WHERE id = 1"},
+				map[string][]byte{ExactStalenessPayloadKey: []byte("15s")},
+			),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &requestState{pb: &adapterpb.AdaptMessageRequest{}}
+			errMsg := re.prepareCassandraAttachments(tc.frame, req)
+			if tc.wantErr {
+				if _, ok := errMsg.(*message.Invalid); !ok {
+					t.Fatalf("got %v, want Invalid error", errMsg)
+				}
+				return
+			}
+			if errMsg != nil {
+				t.Fatalf("unexpected error: %v", errMsg)
+			}
+			assert.Equal(t, tc.wantAttachments, req.pb.Attachments)
+		})
+	}
+
+	// Timestamp bounds fail unless the backend supports them.
+	re.opts.SupportedAttachments = []string{exactStaleness}
+	req := &requestState{pb: &adapterpb.AdaptMessageRequest{}}
+	errMsg := re.prepareCassandraAttachments(newFrame(selectQuery, map[string][]byte{
+		ReadTimestampPayloadKey: []byte("2025-01-02T03:04:05Z"),
+	}), req)
+	assert.IsType(t, &message.Invalid{}, errMsg)
+}
+
+func TestPreparePartitionedDMLAttachments(t *testing.T) {
+	re := &requestExecutor{
+		opts: &Options{
+			EnablePartitionedDMLFor: []string{"ks.events"},
+			SupportedAttachments:    []string{partitionedDML},
+		},
+	}
+	hint := map[string][]byte{PartitionedDMLPayloadKey: []byte("true")}
+	newFrame := func(msg message.Message, payload map[string][]byte) *frame.Frame {
+		return &frame.Frame{
+			Header: &frame.Header{
+				Version: primitive.ProtocolVersion4,
+				OpCode:  msg.GetOpCode(),
+			},
+			Body: &frame.Body{Message: msg, CustomPayload: payload},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		frame    *frame.Frame
+		wantPDML bool
+		wantErr  bool
+	}{
+		{
+			name:     "Configured table",
+			frame:    newFrame(&message.Query{Query: "DELETE FROM ks.events WHERE ts < 5"}, nil),
+			wantPDML: true,
+		},
+		{
+			name:  "Insert into configured table",
+			frame: newFrame(&message.Query{Query: "INSERT INTO ks.events (id) VALUES (1)"}, nil),
+		},
+		{
+			name:  "Other table",
+			frame: newFrame(&message.Query{Query: "UPDATE ks.users SET a = 1"}, nil),
+		},
+		{
+			name:     "Hinted query",
+			frame:    newFrame(&message.Query{Query: "UPDATE ks.users SET a = 1"}, hint),
+			wantPDML: true,
+		},
+		{
+			name:    "Hinted insert",
+			frame:   newFrame(&message.Query{Query: "INSERT INTO ks.users (id) VALUES (1)"}, hint),
+			wantErr: true,
+		},
+		{
+			name:    "Hinted select",
+			frame:   newFrame(&message.Query{Query: "SELECT * FROM ks.users"}, hint),
+			wantErr: true,
+		},
+		{
+			name:    "Hinted batch",
+			frame:   newFrame(&message.Batch{Type: primitive.BatchTypeLogged}, hint),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &requestState{pb: &adapterpb.AdaptMessageRequest{}}
+			errMsg := re.prepareCassandraAttachments(tc.frame, req)
+			if tc.wantErr {
+				if _, ok := errMsg.(*message.Invalid); !ok {
+					t.Fatalf("got %v, want Invalid error", errMsg)
+				}
+				return
+			}
+			assert.Nil(t, errMsg)
+			_, gotPDML := req.pb.Attachments[partitionedDML]
+			assert.Equal(t, tc.wantPDML, gotPDML)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// These are effectively const, but for testing purposes they are mutable
+var (
+	// Interval between two health checks of the endpoints preferred over the
+	// active endpoint of a channel pool.
+	endpointHealthCheckInterval = 30 * time.Second
+	// Time an endpoint has to become ready in a health check.
+	endpointHealthCheckTimeout = 5 * time.Second
+)
+
+// activeEndpoint returns the Spanner endpoint the channels of the pool are
+// dialed to.
+func (p *channelPool) activeEndpoint() string {
+	return p.endpoints[p.active.Load()]
+}
+
+// probeEndpoint dials a dedicated connection to the i-th endpoint of the pool
+// and waits until it is ready.
+func (p *channelPool) probeEndpoint(ctx context.Context, i int) error {
+	client, err := p.dialEndpoint(ctx, i)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	// The pool of the client has a single connection.
+	return waitForReady(ctx, client.Connection(), p.endpoints[i])
+}
+
+// waitForReady connects conn to endpoint and waits until it is ready.
+func waitForReady(ctx context.Context, conn *grpc.ClientConn, endpoint string) error {
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return fmt.Errorf("endpoint %s is in state %v", endpoint, state)
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+}
+
+// checkEndpoint reports whether the i-th endpoint of the pool is healthy.
+func (p *channelPool) checkEndpoint(i int) bool {
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		endpointHealthCheckTimeout,
+	)
+	defer cancel()
+	if err := p.probe(ctx, i); err != nil {
+		logger.Warn("Spanner endpoint failed health check",
+			zap.String("endpoint", p.endpoints[i]),
+			zap.Error(err))
+		return false
+	}
+	return true
+}
+
+// shouldFailover reports whether the pool must fail over to another endpoint
+// after streak consecutive transport failures, which is the case once every
+// channel of the pool could have been re-dialed. Only one failover runs at a
+// time.
+func (p *channelPool) shouldFailover(streak int64) bool {
+	return len(p.endpoints) > 1 &&
+		p.threshold > 0 &&
+		streak >= int64(p.threshold*p.size()) &&
+		p.failingOver.CompareAndSwap(false, true)
+}
+
+// failover switches the pool to the most preferred healthy endpoint other
+// than the active one.
+func (p *channelPool) failover() {
+	defer p.failingOver.Store(false)
+	active := int(p.active.Load())
+	for i := range p.endpoints {
+		if i != active && p.checkEndpoint(i) {
+			p.switchEndpoint(context.Background(), i)
+			return
+		}
+	}
+	logger.Error("No healthy Spanner endpoint to fail over to",
+		zap.String("endpoint", p.endpoints[active]))
+	// Try again after another streak of failures.
+	p.failureStreak.Store(0)
+}
+
+// checkEndpoints periodically fails back to the endpoints preferred over the
+// active one once they are healthy again, until the pool is closed.
+func (p *channelPool) checkEndpoints(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.failBack()
+		}
+	}
+}
+
+// failBack switches the pool to the most preferred healthy endpoint, if it is
+// preferred over the active one.
+func (p *channelPool) failBack() {
+	active := int(p.active.Load())
+	for i := 0; i < active; i++ {
+		if p.checkEndpoint(i) {
+			p.switchEndpoint(context.Background(), i)
+			return
+		}
+	}
+}
+
+// switchEndpoint makes i the active endpoint of the pool and re-dials every
+// channel to it. Channels that can not be re-dialed keep their connection
+// until they are re-dialed for failing.
+func (p *channelPool) switchEndpoint(ctx context.Context, i int) {
+	p.switchMu.Lock()
+	defer p.switchMu.Unlock()
+	from := int(p.active.Swap(int64(i)))
+	if from == i {
+		return
+	}
+	logger.Warn("Switching Spanner endpoint",
+		zap.String("from", p.endpoints[from]),
+		zap.String("to", p.endpoints[i]))
+
+	p.mu.RLock()
+	channels := append([]*grpcChannel(nil), p.channels...)
+	p.mu.RUnlock()
+	for _, ch := range channels {
+		client, err := p.dial(ctx)
+		if err != nil {
+			logger.Error("Failed to re-dial gRPC channel to new endpoint",
+				zap.Int("channel_id", ch.id),
+				zap.String("endpoint", p.endpoints[i]),
+				zap.Error(err))
+			continue
+		}
+		p.replaceClient(ch, client)
+	}
+	p.failovers.Add(1)
+	p.failureStreak.Store(0)
+}
@@ -0,0 +1,126 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeProbe reports the health of the endpoints of a pool by index.
+type fakeProbe struct {
+	mu      sync.Mutex
+	healthy map[int]bool
+}
+
+func (f *fakeProbe) setHealthy(i int, healthy bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.healthy[i] = healthy
+}
+
+func (f *fakeProbe) probe(ctx context.Context, i int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.healthy[i] {
+		return errors.New("unhealthy")
+	}
+	return nil
+}
+
+func TestChannelPoolFailover(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{
+			SpannerEndpoint:           "primary:443",
+			FailoverEndpoints:         []string{"secondary:443", "tertiary:443"},
+			NumGrpcChannels:           2,
+			UnhealthyChannelThreshold: 2,
+		},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+	probe := &fakeProbe{healthy: map[int]bool{2: true}}
+	pool.probe = probe.probe
+	assert.Equal(t, "primary:443", pool.activeEndpoint())
+
+	original := pool.channels[1].gapicClient()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	// Failures of a single channel only re-dial it.
+	for i := 0; i < 3; i++ {
+		pool.recordResult(pool.channels[0], time.Millisecond, unavailable)
+	}
+	pool.recordResult(pool.channels[0], time.Millisecond, nil)
+	assert.Equal(t, int64(0), pool.failovers.Load())
+
+	// Once the whole pool keeps failing, the channels fail over to the most
+	// preferred healthy endpoint.
+	for i := 0; i < 2; i++ {
+		pool.recordResult(pool.channels[0], time.Millisecond, unavailable)
+		pool.recordResult(pool.channels[1], time.Millisecond, unavailable)
+	}
+	assert.Eventually(t, func() bool {
+		return pool.failovers.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "tertiary:443", pool.activeEndpoint())
+	assert.NotSame(t, original, pool.channels[1].gapicClient())
+
+	// Unhealthy preferred endpoints are kept out of rotation.
+	pool.failBack()
+	assert.Equal(t, "tertiary:443", pool.activeEndpoint())
+
+	probe.setHealthy(0, true)
+	probe.setHealthy(1, true)
+	pool.failBack()
+	assert.Equal(t, "primary:443", pool.activeEndpoint())
+	assert.Equal(t, int64(2), pool.failovers.Load())
+}
+
+func TestChannelPoolFailoverWithoutHealthyEndpoint(t *testing.T) {
+	pool, err := newChannelPool(
+		context.Background(),
+		Options{
+			FailoverEndpoints:         []string{"secondary:443"},
+			NumGrpcChannels:           1,
+			UnhealthyChannelThreshold: 1,
+		},
+		SkipAuthOpts,
+	)
+	require.NoError(t, err)
+	defer pool.close()
+	probe := &fakeProbe{healthy: map[int]bool{}}
+	pool.probe = probe.probe
+
+	pool.recordResult(pool.channels[0], time.Millisecond, status.Error(codes.Unavailable, ""))
+	assert.Eventually(t, func() bool {
+		return !pool.failingOver.Load()
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, defaultSpannerEndpoint, pool.activeEndpoint())
+	assert.Equal(t, int64(0), pool.failovers.Load())
+}
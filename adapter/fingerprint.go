@@ -0,0 +1,253 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Default maximum number of fingerprints tracked with
+// Options.EnableStatementMetrics.
+const defaultMaxStatementFingerprints = 1000
+
+// Fingerprint the statements are counted under once
+// Options.MaxStatementFingerprints fingerprints are tracked.
+const otherFingerprint = "<other>"
+
+var (
+	// Literals and bind markers, replaced by ? in fingerprints.
+	fingerprintLiteralPattern = regexp.MustCompile(
+		`'(?:[^']|'')*'|\$\$.*?\$\$|\b0[xX][0-9a-fA-F]*\b|` +
+			`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b|` +
+			`-?\b\d+(?:\.\d+)?(?:[eE][+-]?\d+)?\b|\btrue\b|\bfalse\b|:\w+|\?`,
+	)
+	// IN lists of ? (ie: IN (?, ?, ?)), collapsed to a single ?.
+	fingerprintListPattern = regexp.MustCompile(`(?i)\bIN\s*\(\s*\?(?:\s*,\s*\?)+\s*\)`)
+)
+
+// fingerprint returns the normalized form of a statement, with its literals
+// and bind markers replaced by ?, its IN lists collapsed and its
+// whitespace normalized, so that the executions of a statement with different
+// values share a fingerprint.
+func fingerprint(query string) string {
+	normalized := fingerprintLiteralPattern.ReplaceAllString(query, "?")
+	normalized = fingerprintListPattern.ReplaceAllString(normalized, "IN (?)")
+	normalized = strings.Join(strings.Fields(normalized), " ")
+	return strings.TrimSuffix(normalized, ";")
+}
+
+// StatementStats holds the metrics of the statements sharing a fingerprint.
+type StatementStats struct {
+	// Statement with its literals and bind markers replaced by ?.
+	Fingerprint string
+	// Number of executions.
+	Count int64
+	// Number of executions answered with an error.
+	Errors int64
+	// Latencies of the executions, from their read from the driver to their
+	// response.
+	Latency LatencyHistogram
+}
+
+// statementStats holds the metrics of a fingerprint.
+type statementStats struct {
+	count   atomic.Int64
+	errors  atomic.Int64
+	latency latencyHistogram
+}
+
+// statementMetrics collects the metrics of the statements by fingerprint with
+// Options.EnableStatementMetrics.
+type statementMetrics struct {
+	max int
+
+	mu           sync.RWMutex
+	fingerprints map[string]*statementStats
+
+	// Fingerprints of the prepared query ids, by id.
+	prepared *lru.Cache
+}
+
+func newStatementMetrics(opts Options) (*statementMetrics, error) {
+	if !opts.EnableStatementMetrics {
+		return nil, nil
+	}
+	prepared, err := lru.New(opts.PreparedCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	max := opts.MaxStatementFingerprints
+	if max <= 0 {
+		max = defaultMaxStatementFingerprints
+	}
+	return &statementMetrics{
+		max:          max,
+		fingerprints: make(map[string]*statementStats),
+		prepared:     prepared,
+	}, nil
+}
+
+// stats returns the metrics of fingerprint, or of otherFingerprint once the
+// maximum number of fingerprints is tracked.
+func (sm *statementMetrics) stats(fingerprint string) *statementStats {
+	sm.mu.RLock()
+	stats, ok := sm.fingerprints[fingerprint]
+	sm.mu.RUnlock()
+	if ok {
+		return stats
+	}
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if stats, ok := sm.fingerprints[fingerprint]; ok {
+		return stats
+	}
+	if len(sm.fingerprints) >= sm.max {
+		fingerprint = otherFingerprint
+		if stats, ok := sm.fingerprints[fingerprint]; ok {
+			return stats
+		}
+	}
+	stats = &statementStats{}
+	sm.fingerprints[fingerprint] = stats
+	return stats
+}
+
+// fingerprintOf returns the fingerprint of the statement of msg, if known:
+// the statement of QUERY and PREPARE requests, the statement prepared through
+// the proxy of EXECUTE requests, or the fingerprints of the statements of
+// BATCH requests.
+func (sm *statementMetrics) fingerprintOf(msg message.Message) (string, bool) {
+	switch msg := msg.(type) {
+	case *message.Query:
+		return fingerprint(msg.Query), true
+	case *message.Prepare:
+		return "PREPARE " + fingerprint(msg.Query), true
+	case *message.Execute:
+		return sm.preparedFingerprint(msg.QueryId)
+	case *message.Batch:
+		seen := make(map[string]bool)
+		var children []string
+		for _, child := range msg.Children {
+			fp, ok := sm.preparedFingerprint(child.Id)
+			if child.Query != "" {
+				fp, ok = fingerprint(child.Query), true
+			}
+			if ok && !seen[fp] {
+				seen[fp] = true
+				children = append(children, fp)
+			}
+		}
+		sort.Strings(children)
+		return "BATCH " + strings.Join(children, "; "), true
+	}
+	return "", false
+}
+
+func (sm *statementMetrics) preparedFingerprint(id []byte) (string, bool) {
+	fp, ok := sm.prepared.Get(string(id))
+	if !ok {
+		return "", false
+	}
+	return fp.(string), true
+}
+
+// top returns the metrics of the n fingerprints with the highest total
+// latency, which approximates the Spanner resources they use, or of all
+// fingerprints if n <= 0.
+func (sm *statementMetrics) top(n int) []StatementStats {
+	if sm == nil {
+		return nil
+	}
+	sm.mu.RLock()
+	all := make([]StatementStats, 0, len(sm.fingerprints))
+	for fp, stats := range sm.fingerprints {
+		all = append(all, StatementStats{
+			Fingerprint: fp,
+			Count:       stats.count.Load(),
+			Errors:      stats.errors.Load(),
+			Latency:     stats.latency.snapshot(),
+		})
+	}
+	sm.mu.RUnlock()
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Latency.Sum != all[j].Latency.Sum {
+			return all[i].Latency.Sum > all[j].Latency.Sum
+		}
+		return all[i].Fingerprint < all[j].Fingerprint
+	})
+	if n > 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// recordStatement records the execution of req, answered after latency, in
+// the metrics of its fingerprint. err is the error of the request, if any.
+func (dc *driverConnection) recordStatement(
+	req *requestState,
+	latency time.Duration,
+	err error,
+) {
+	sm := dc.executor.fingerprints
+	if sm == nil || req.frame.Body == nil {
+		return
+	}
+	fp, ok := sm.fingerprintOf(req.frame.Body.Message)
+	if !ok {
+		return
+	}
+	stats := sm.stats(fp)
+	stats.count.Add(1)
+	if err != nil || req.errorResponse {
+		stats.errors.Add(1)
+	}
+	stats.latency.record(latency)
+}
+
+// rememberStatementFingerprint records the fingerprint of the prepared query
+// id returned for a PREPARE request.
+func (dc *driverConnection) rememberStatementFingerprint(
+	req *requestState,
+	encoded []byte,
+) {
+	sm := dc.executor.fingerprints
+	if sm == nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		sm.prepared.Add(string(id), fingerprint(prepare.Query))
+	}
+}
+
+// TopStatements returns the metrics of the n statement fingerprints with the
+// highest total latency, or of all fingerprints if n <= 0, with
+// Options.EnableStatementMetrics.
+func (proxy *TCPProxy) TopStatements(n int) []StatementStats {
+	return proxy.fingerprints.top(n)
+}
@@ -0,0 +1,101 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			"SELECT * FROM ks.users WHERE id = 42 AND name = 'it''s'",
+			"SELECT * FROM ks.users WHERE id = ? AND name = ?",
+		},
+		{
+			"SELECT * FROM users WHERE id IN (1, 2,  3);",
+			"SELECT * FROM users WHERE id IN (?)",
+		},
+		{
+			"INSERT INTO users (id, active)\n VALUES (:id, true)",
+			"INSERT INTO users (id, active) VALUES (?, ?)",
+		},
+		{
+			"UPDATE t1 SET v = 0x0a WHERE id = 123e4567-e89b-12d3-a456-426614174000",
+			"UPDATE t1 SET v = ? WHERE id = ?",
+		},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, fingerprint(tt.query), tt.query)
+	}
+}
+
+func TestStatementMetrics(t *testing.T) {
+	sm, err := newStatementMetrics(Options{
+		EnableStatementMetrics:   true,
+		MaxStatementFingerprints: 2,
+		PreparedCacheSize:        10,
+	})
+	require.NoError(t, err)
+	dc := &driverConnection{executor: &requestExecutor{fingerprints: sm}}
+	query := func(q string) *requestState {
+		return &requestState{frame: *frame.NewFrame(
+			primitive.ProtocolVersion4, 0, &message.Query{Query: q},
+		)}
+	}
+
+	dc.recordStatement(query("SELECT * FROM t WHERE id = 1"), time.Second, nil)
+	dc.recordStatement(query("SELECT * FROM t WHERE id = 2"), time.Second, errors.New("failed"))
+	failed := query("INSERT INTO t (id) VALUES (1)")
+	failed.errorResponse = true
+	dc.recordStatement(failed, time.Millisecond, nil)
+	// Fingerprints over the limit are counted together.
+	dc.recordStatement(query("DELETE FROM t WHERE id = 1"), time.Millisecond, nil)
+
+	// Executions are fingerprinted by the statement of their prepared id.
+	sm.prepared.Add("id", fingerprint("DELETE FROM t WHERE id = ?"))
+	dc.recordStatement(&requestState{frame: *frame.NewFrame(
+		primitive.ProtocolVersion4, 0, &message.Execute{QueryId: []byte("id")},
+	)}, time.Millisecond, nil)
+
+	top := sm.top(0)
+	require.Len(t, top, 3)
+	assert.Equal(t, "SELECT * FROM t WHERE id = ?", top[0].Fingerprint)
+	assert.Equal(t, int64(2), top[0].Count)
+	assert.Equal(t, int64(1), top[0].Errors)
+	assert.Equal(t, 2*time.Second, top[0].Latency.Sum)
+	assert.Equal(t, otherFingerprint, top[1].Fingerprint)
+	assert.Equal(t, int64(2), top[1].Count)
+	assert.Equal(t, "INSERT INTO t (id) VALUES (?)", top[2].Fingerprint)
+	assert.Equal(t, int64(1), top[2].Errors)
+
+	assert.Len(t, sm.top(1), 1)
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package frameutil implements the Cassandra framing used by the proxy, so
+// that tools such as traffic replayers and integration harnesses can frame
+// requests and responses exactly like the proxy does.
+package frameutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+)
+
+var (
+	codec    = frame.NewCodec()
+	rawCodec = frame.NewRawCodec()
+)
+
+// FrameTooLargeError is returned by DecodeRawFrameWithLimit for frames whose
+// body exceeds the limit. The body of the frame is left unread.
+type FrameTooLargeError struct {
+	// Header of the frame.
+	Header *frame.Header
+	// Maximum body length.
+	Limit int
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"request is too big: length %d exceeds maximum allowed length %d",
+		e.Header.BodyLength,
+		e.Limit,
+	)
+}
+
+// DecodeRawFrame reads a frame from source without decoding its body. Returns
+// the frame header along with the encoded frame (header and body), which is
+// the payload the proxy sends to Spanner.
+func DecodeRawFrame(source io.Reader) (*frame.Header, []byte, error) {
+	return DecodeRawFrameWithLimit(source, 0)
+}
+
+// DecodeRawFrameWithLimit is like DecodeRawFrame, but returns a
+// *FrameTooLargeError without reading the body if it is longer than
+// maxBodyLength bytes. A non-positive maxBodyLength disables the limit.
+func DecodeRawFrameWithLimit(
+	source io.Reader,
+	maxBodyLength int,
+) (*frame.Header, []byte, error) {
+	header, err := rawCodec.DecodeHeader(source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot decode frame header: %w", err)
+	}
+	if maxBodyLength > 0 && int(header.BodyLength) > maxBodyLength {
+		return nil, nil, &FrameTooLargeError{Header: header, Limit: maxBodyLength}
+	}
+	body, err := rawCodec.DecodeRawBody(header, source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read frame body: %w", err)
+	}
+
+	rawHeader := bytes.NewBuffer(nil)
+	if err := rawCodec.EncodeHeader(header, rawHeader); err != nil {
+		return nil, nil, err
+	}
+	return header, append(rawHeader.Bytes(), body...), nil
+}
+
+// MergeChunkedPayloads merges the payloads of the AdaptMessageResponses of a
+// request into an encoded frame. A single payload is a complete frame. When
+// the response is chunked, the last payload is the frame header and the
+// previous ones are the chunks of its body. Returns nil if there is no
+// payload.
+func MergeChunkedPayloads(payloads [][]byte) []byte {
+	switch len(payloads) {
+	case 0:
+		return nil
+	case 1:
+		return payloads[0]
+	}
+	merged := bytes.Buffer{}
+	merged.Write(payloads[len(payloads)-1])
+	for _, payload := range payloads[:len(payloads)-1] {
+		merged.Write(payload)
+	}
+	return merged.Bytes()
+}
+
+// BuildErrorFrame encodes msg as the response to the request with the given
+// header, ie: an error or a result computed by the proxy rather than by
+// Spanner. The flags of the request are not carried over to the response.
+func BuildErrorFrame(header *frame.Header, msg message.Message) ([]byte, error) {
+	frm := &frame.Frame{
+		Header: &frame.Header{
+			IsResponse: true,
+			Version:    header.Version,
+			StreamId:   header.StreamId,
+			OpCode:     msg.GetOpCode(),
+		},
+		Body: &frame.Body{Message: msg},
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := codec.EncodeFrame(frm, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,132 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package frameutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encode(t testing.TB, frm *frame.Frame) []byte {
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(frm, buf))
+	return buf.Bytes()
+}
+
+func TestDecodeRawFrame(t *testing.T) {
+	query := frame.NewFrame(
+		primitive.ProtocolVersion4,
+		7,
+		&message.Query{Query: "SELECT * FROM ks.t", Options: &message.QueryOptions{}},
+	)
+	encoded := encode(t, query)
+
+	// Trailing bytes belong to the next frame.
+	source := bytes.NewBuffer(append(append([]byte(nil), encoded...), 0x04))
+	header, payload, err := DecodeRawFrame(source)
+	require.NoError(t, err)
+	assert.Equal(t, int16(7), header.StreamId)
+	assert.Equal(t, primitive.OpCodeQuery, header.OpCode)
+	assert.Equal(t, encoded, payload)
+	assert.Equal(t, 1, source.Len())
+
+	_, _, err = DecodeRawFrame(bytes.NewBuffer(encoded[:len(encoded)-1]))
+	assert.Error(t, err)
+}
+
+func TestMergeChunkedPayloads(t *testing.T) {
+	assert.Nil(t, MergeChunkedPayloads(nil))
+	assert.Equal(t, []byte("frame"), MergeChunkedPayloads([][]byte{[]byte("frame")}))
+	assert.Equal(
+		t,
+		[]byte("headerbody1body2"),
+		MergeChunkedPayloads([][]byte{
+			[]byte("body1"),
+			[]byte("body2"),
+			[]byte("header"),
+		}),
+	)
+}
+
+func TestBuildErrorFrame(t *testing.T) {
+	header := &frame.Header{
+		Version:  primitive.ProtocolVersion4,
+		Flags:    primitive.HeaderFlagTracing,
+		StreamId: 3,
+		OpCode:   primitive.OpCodeQuery,
+	}
+	encoded, err := BuildErrorFrame(header, &message.Invalid{ErrorMessage: "bad"})
+	require.NoError(t, err)
+
+	got, err := codec.DecodeFrame(bytes.NewBuffer(encoded))
+	require.NoError(t, err)
+	assert.True(t, got.Header.IsResponse)
+	assert.Equal(t, int16(3), got.Header.StreamId)
+	assert.Equal(t, primitive.HeaderFlag(0), got.Header.Flags)
+	assert.Equal(t, &message.Invalid{ErrorMessage: "bad"}, got.Body.Message)
+	// The request header is left unchanged.
+	assert.Equal(t, primitive.OpCodeQuery, header.OpCode)
+}
+
+func FuzzDecodeRawFrame(f *testing.F) {
+	f.Add(encode(f, frame.NewFrame(
+		primitive.ProtocolVersion4,
+		1,
+		&message.Query{Query: "SELECT 1", Options: &message.QueryOptions{}},
+	)))
+	f.Add(encode(f, frame.NewFrame(primitive.ProtocolVersion4, 2, &message.Options{})))
+	f.Add([]byte{0x04, 0x00, 0x00, 0x01, 0x07, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, payload, err := DecodeRawFrame(bytes.NewBuffer(data))
+		if err != nil {
+			return
+		}
+		assert.Equal(t, int(header.BodyLength), len(payload)-header.Version.FrameHeaderLengthInBytes())
+		assert.Equal(t, data[:len(payload)], payload)
+	})
+}
+
+func FuzzMergeChunkedPayloads(f *testing.F) {
+	f.Add([]byte("header"), []byte("body"), 2)
+	f.Fuzz(func(t *testing.T, header, body []byte, chunks int) {
+		if chunks <= 0 || chunks > 16 {
+			return
+		}
+		var payloads [][]byte
+		size := (len(body) + chunks - 1) / chunks
+		for start := 0; start < len(body); start += size {
+			end := min(start+size, len(body))
+			payloads = append(payloads, body[start:end])
+		}
+		payloads = append(payloads, header)
+		merged := MergeChunkedPayloads(payloads)
+		if len(payloads) == 1 {
+			assert.Equal(t, header, merged)
+			return
+		}
+		assert.Equal(t, append(append([]byte(nil), header...), body...), merged)
+	})
+}
@@ -0,0 +1,228 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+)
+
+// FullScanPolicy controls how SELECT statements that scan a whole table are
+// served.
+type FullScanPolicy int
+
+const (
+	// FullScanAllow serves full scans like any other statement.
+	FullScanAllow FullScanPolicy = iota
+	// FullScanLowPriority sends full scans to Spanner with a low priority.
+	FullScanLowPriority
+	// FullScanWarn logs full scans and returns a warning to the driver.
+	FullScanWarn
+	// FullScanReject rejects full scans with an Invalid error.
+	FullScanReject
+)
+
+// Reasons a statement scans a whole table.
+const (
+	fullScanNoWhere        = "no WHERE clause"
+	fullScanAllowFiltering = "ALLOW FILTERING clause"
+	fullScanTokenRange     = "token range restriction"
+)
+
+// Attachment value requesting a low priority for a request.
+const lowPriority = "PRIORITY_LOW"
+
+// ParseFullScanPolicy parses a full scan policy name (allow, low-priority,
+// warn or reject).
+func ParseFullScanPolicy(policy string) (FullScanPolicy, error) {
+	switch strings.ToLower(policy) {
+	case "", "allow":
+		return FullScanAllow, nil
+	case "low-priority":
+		return FullScanLowPriority, nil
+	case "warn":
+		return FullScanWarn, nil
+	case "reject":
+		return FullScanReject, nil
+	default:
+		return FullScanAllow, fmt.Errorf("invalid full scan policy '%s'", policy)
+	}
+}
+
+// fullScanTable returns the table a SELECT statement scans in full, and why.
+// Statements scan a whole table if they have no WHERE clause, an ALLOW
+// FILTERING clause, or a range restriction on the token of the partition key.
+// Reads of the system keyspaces are not reported.
+func fullScanTable(query string) (table, reason string, ok bool) {
+	tokens := tokenizeCQL(query)
+	if len(tokens) == 0 || !tokens[0].is("select") {
+		return "", "", false
+	}
+	from := -1
+	depth := 0
+	for i := 1; i < len(tokens) && from < 0; i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			depth--
+		case depth == 0 && tokens[i].is("from"):
+			from = i
+		}
+	}
+	if from < 0 {
+		return "", "", false
+	}
+	if table, ok = parseTableName(tokens, from+1); !ok ||
+		strings.HasPrefix(table, "system") && strings.Contains(table, ".") {
+		return "", "", false
+	}
+	where := false
+	for i := from + 1; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("where"):
+			where = true
+		case tokens[i].is("allow") && i+1 < len(tokens) &&
+			tokens[i+1].is("filtering"):
+			return table, fullScanAllowFiltering, true
+		case where && tokens[i].is("token") && i+1 < len(tokens) &&
+			tokens[i+1].is("("):
+			// Find the comparison following the closing parenthesis.
+			depth := 0
+			for i++; i < len(tokens); i++ {
+				if tokens[i].is("(") {
+					depth++
+				} else if tokens[i].is(")") {
+					if depth--; depth == 0 {
+						break
+					}
+				}
+			}
+			if i+1 < len(tokens) && (tokens[i+1].is(">") || tokens[i+1].is("<")) {
+				return table, fullScanTokenRange, true
+			}
+		}
+	}
+	if !where {
+		return table, fullScanNoWhere, true
+	}
+	return "", "", false
+}
+
+// fullScanWarning returns the warning of a full scan.
+func fullScanWarning(table, reason string) string {
+	return fmt.Sprintf(
+		"SELECT on table %s scans the whole table (%s), restrict its primary key to read fewer rows",
+		table,
+		reason,
+	)
+}
+
+// fullScanStatements remembers the prepared query ids whose statements scan a
+// whole table.
+type fullScanStatements struct {
+	cache *lru.Cache
+}
+
+func newFullScanStatements(size int) (*fullScanStatements, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &fullScanStatements{cache: cache}, nil
+}
+
+func (fs *fullScanStatements) remember(id []byte, table string) {
+	if fs != nil {
+		fs.cache.Add(string(id), table)
+	}
+}
+
+func (fs *fullScanStatements) contains(id []byte) bool {
+	return fs != nil && fs.cache.Contains(string(id))
+}
+
+// rememberFullScanStatement records the prepared query id returned by the
+// server for req, if its statement scans a whole table.
+func (dc *driverConnection) rememberFullScanStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	if dc.executor.fullScans == nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	table, _, ok := fullScanTable(prepare.Query)
+	if !ok {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		dc.executor.fullScans.remember(id, table)
+	}
+}
+
+// tryCheckFullScan applies Options.FullScanPolicy to a QUERY, PREPARE or
+// EXECUTE request. Full scans are rejected when they are queried or prepared,
+// and the executions of prepared full scans are sent with a low priority.
+// Warnings are added by requestWarnings.
+func (re *requestExecutor) tryCheckFullScan(
+	frm *frame.Frame, attachments map[string]string,
+) message.Message {
+	if re.opts.FullScanPolicy == FullScanAllow {
+		return nil
+	}
+	if execute, ok := frm.Body.Message.(*message.Execute); ok {
+		if re.opts.FullScanPolicy == FullScanLowPriority &&
+			re.fullScans.contains(execute.QueryId) {
+			attachments[requestPriority] = lowPriority
+		}
+		return nil
+	}
+	for _, query := range queriesOf(frm) {
+		table, reason, ok := fullScanTable(query)
+		if !ok {
+			continue
+		}
+		switch re.opts.FullScanPolicy {
+		case FullScanReject:
+			return &message.Invalid{
+				ErrorMessage: fmt.Sprintf(
+					"Full scans are rejected by the proxy: %s",
+					fullScanWarning(table, reason),
+				),
+			}
+		case FullScanLowPriority:
+			if attachments != nil {
+				attachments[requestPriority] = lowPriority
+			}
+		case FullScanWarn:
+			logger.Warn("Full table scan",
+				zap.String("table", table),
+				zap.String("reason", reason))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFullScanTable(t *testing.T) {
+	testCases := []struct {
+		query  string
+		table  string
+		reason string
+	}{
+		{"SELECT * FROM ks.users", "ks.users", fullScanNoWhere},
+		{"select count(*) from users limit 10", "users", fullScanNoWhere},
+		{"SELECT * FROM users WHERE age > 30 ALLOW FILTERING", "users", fullScanAllowFiltering},
+		{"SELECT * FROM users WHERE token(id) > ? AND token(id) <= ?", "users", fullScanTokenRange},
+		{"SELECT * FROM users WHERE id = 1", "", ""},
+		{"SELECT * FROM users WHERE token(id) = token(1)", "", ""},
+		{"SELECT * FROM system.local", "", ""},
+		{"SELECT * FROM system_schema.tables", "", ""},
+		{"INSERT INTO users (id) VALUES (1)", "", ""},
+		{"SELECT * FROM users WHERE name = 'allow filtering'", "", ""},
+	}
+	for _, tc := range testCases {
+		table, reason, ok := fullScanTable(tc.query)
+		assert.Equal(t, tc.table != "", ok, tc.query)
+		assert.Equal(t, tc.table, table, tc.query)
+		assert.Equal(t, tc.reason, reason, tc.query)
+	}
+}
+
+func TestParseFullScanPolicy(t *testing.T) {
+	for name, want := range map[string]FullScanPolicy{
+		"":             FullScanAllow,
+		"allow":        FullScanAllow,
+		"low-priority": FullScanLowPriority,
+		"WARN":         FullScanWarn,
+		"reject":       FullScanReject,
+	} {
+		policy, err := ParseFullScanPolicy(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, policy, name)
+	}
+	_, err := ParseFullScanPolicy("deny")
+	assert.Error(t, err)
+}
+
+func TestCheckFullScan(t *testing.T) {
+	fullScans, err := newFullScanStatements(10)
+	require.NoError(t, err)
+	fullScans.remember([]byte("scan"), "ks.events")
+	check := func(
+		policy FullScanPolicy,
+		msg message.Message,
+	) (map[string]string, message.Message) {
+		re := &requestExecutor{
+			opts:      &Options{FullScanPolicy: policy},
+			fullScans: fullScans,
+		}
+		attachments := map[string]string{}
+		errMsg := re.tryCheckFullScan(
+			frame.NewFrame(primitive.ProtocolVersion4, 1, msg),
+			attachments,
+		)
+		return attachments, errMsg
+	}
+	scan := &message.Query{Query: "SELECT * FROM ks.events"}
+	lookup := &message.Query{Query: "SELECT * FROM ks.events WHERE id = 1"}
+	prepare := &message.Prepare{Query: "SELECT * FROM ks.events"}
+	execute := &message.Execute{QueryId: []byte("scan")}
+
+	for _, msg := range []message.Message{scan, lookup, prepare, execute} {
+		attachments, errMsg := check(FullScanAllow, msg)
+		assert.Nil(t, errMsg)
+		assert.Empty(t, attachments)
+	}
+
+	attachments, errMsg := check(FullScanLowPriority, scan)
+	assert.Nil(t, errMsg)
+	assert.Equal(t, map[string]string{requestPriority: lowPriority}, attachments)
+	attachments, errMsg = check(FullScanLowPriority, execute)
+	assert.Nil(t, errMsg)
+	assert.Equal(t, map[string]string{requestPriority: lowPriority}, attachments)
+	attachments, _ = check(FullScanLowPriority, lookup)
+	assert.Empty(t, attachments)
+	attachments, _ = check(FullScanLowPriority, &message.Execute{QueryId: []byte("other")})
+	assert.Empty(t, attachments)
+
+	attachments, errMsg = check(FullScanWarn, scan)
+	assert.Nil(t, errMsg)
+	assert.Empty(t, attachments)
+
+	_, errMsg = check(FullScanReject, scan)
+	assert.IsType(t, &message.Invalid{}, errMsg)
+	_, errMsg = check(FullScanReject, prepare)
+	assert.IsType(t, &message.Invalid{}, errMsg)
+	_, errMsg = check(FullScanReject, lookup)
+	assert.Nil(t, errMsg)
+}
+
+func TestFullScanWarnings(t *testing.T) {
+	re := &requestExecutor{opts: &Options{FullScanPolicy: FullScanWarn}}
+	newFrame := func(msg message.Message) *frame.Frame {
+		return frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+	}
+	assert.Equal(
+		t,
+		[]string{fullScanWarning("ks.events", fullScanNoWhere)},
+		re.requestWarnings(newFrame(&message.Prepare{Query: "SELECT * FROM ks.events"})),
+	)
+	assert.Empty(t, re.requestWarnings(newFrame(&message.Query{
+		Query: "SELECT * FROM ks.events WHERE id = 1",
+	})))
+
+	re.opts.FullScanPolicy = FullScanReject
+	assert.Empty(t, re.requestWarnings(newFrame(&message.Query{
+		Query: "SELECT * FROM ks.events",
+	})))
+}
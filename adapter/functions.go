@@ -0,0 +1,240 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/google/uuid"
+)
+
+// functionCall is a call of a CQL function without arguments, or with a
+// single argument that is itself such a call (ie: toTimestamp(now())).
+type functionCall struct {
+	name string
+	// Name of the function called in the argument, if any.
+	arg string
+	// Index of the token following the call.
+	end int
+}
+
+// parseFunctionCall parses a call of a function starting at tokens[i].
+func parseFunctionCall(tokens []cqlToken, i int) (functionCall, bool) {
+	if tokens[i].kind != cqlIdentifier || i+2 >= len(tokens) ||
+		!tokens[i+1].is("(") {
+		return functionCall{}, false
+	}
+	call := functionCall{name: strings.ToLower(tokens[i].text)}
+	if tokens[i+2].is(")") {
+		call.end = i + 3
+		return call, true
+	}
+	if i+5 < len(tokens) && tokens[i+2].kind != cqlString &&
+		tokens[i+3].is("(") && tokens[i+4].is(")") && tokens[i+5].is(")") {
+		call.arg = strings.ToLower(tokens[i+2].text)
+		call.end = i + 6
+		return call, true
+	}
+	if i+3 < len(tokens) && tokens[i+3].is(")") {
+		call.arg = strings.ToLower(tokens[i+2].text)
+		call.end = i + 4
+		return call, true
+	}
+	return functionCall{}, false
+}
+
+// functionLiteral returns the literal a call of a non-deterministic function
+// evaluates to at now, ie: a new timeuuid for now().
+func functionLiteral(call functionCall, now time.Time) (string, bool, error) {
+	switch {
+	case call.arg == "" && (call.name == "now" || call.name == "currenttimeuuid"):
+		id, err := uuid.NewUUID()
+		if err != nil {
+			return "", false, err
+		}
+		return id.String(), true, nil
+	case call.arg == "" && call.name == "uuid":
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return "", false, err
+		}
+		return id.String(), true, nil
+	case call.arg == "" && call.name == "currenttimestamp",
+		call.arg == "now" && call.name == "totimestamp",
+		call.arg == "now" && call.name == "tounixtimestamp":
+		return strconv.FormatInt(now.UnixMilli(), 10), true, nil
+	case call.arg == "" && call.name == "currentdate",
+		call.arg == "now" && call.name == "todate":
+		return "'" + now.UTC().Format(time.DateOnly) + "'", true, nil
+	}
+	return "", false, nil
+}
+
+// mayUseFunctions reports whether a statement may call an emulated function,
+// to skip tokenizing the others.
+func mayUseFunctions(query string) bool {
+	lower := strings.ToLower(query)
+	return strings.Contains(lower, "now") || strings.Contains(lower, "uuid") ||
+		strings.Contains(lower, "current") || strings.Contains(lower, "writetime")
+}
+
+// rewriteFunctions rewrites the calls of CQL functions of a statement that
+// Spanner does not evaluate:
+//   - with literals, calls of now(), uuid(), currentTimeUUID(),
+//     currentTimestamp(), currentDate(), toTimestamp(now()),
+//     toUnixTimestamp(now()) and toDate(now()) in DML statements and in the
+//     WHERE clause of SELECT statements, evaluated at now,
+//   - with the column configured in writeTimeColumns for the table, calls of
+//     writetime(column) in SELECT statements.
+//
+// Returns the statement unchanged if it calls none of them, and an error if a
+// writetime() call can not be rewritten.
+func rewriteFunctions(
+	query string,
+	writeTimeColumns map[string]string,
+	literals bool,
+	now time.Time,
+) (string, error) {
+	tokens := tokenizeCQL(query)
+	if len(tokens) == 0 {
+		return query, nil
+	}
+	kind := strings.ToLower(tokens[0].text)
+	selects := tokens[0].is("select")
+	evaluate := literals &&
+		(kind == "insert" || kind == "update" || kind == "delete")
+	var edits []queryEdit
+	for i := 0; i < len(tokens); i++ {
+		if selects && tokens[i].is("where") {
+			evaluate = literals
+			continue
+		}
+		call, ok := parseFunctionCall(tokens, i)
+		if !ok {
+			continue
+		}
+		if selects && call.name == "writetime" && call.arg != "" {
+			table, _ := selectTable(query)
+			column, ok := lookupTTLColumn(writeTimeColumns, table)
+			if !ok {
+				return "", fmt.Errorf(
+					"writetime() is not supported on table %s: Spanner does not keep the write time of cells, configure a commit timestamp column of the table with Options.WriteTimeColumns",
+					table,
+				)
+			}
+			text := column
+			if call.end >= len(tokens) || !tokens[call.end].is("as") {
+				text += fmt.Sprintf(` AS "writetime(%s)"`, tokens[i+2].text)
+			}
+			edits = append(edits, queryEdit{tokens[i].start, tokens[call.end-1].end, text})
+			i = call.end - 1
+			continue
+		}
+		if !evaluate {
+			continue
+		}
+		literal, ok, err := functionLiteral(call, now)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			edits = append(edits, queryEdit{tokens[i].start, tokens[call.end-1].end, literal})
+			i = call.end - 1
+		}
+	}
+	if len(edits) == 0 {
+		return query, nil
+	}
+	return applyEdits(query, edits), nil
+}
+
+// tryEmulateFunctions rewrites the calls of CQL functions that Spanner does
+// not evaluate in the statements of req, with Options.EmulateFunctions.
+// Prepared statements are only rewritten for writetime() calls, as their
+// other calls must be evaluated by every execution. Returns an Invalid error
+// message if a call can not be rewritten.
+func (dc *driverConnection) tryEmulateFunctions(req *requestState) message.Message {
+	opts := dc.executor.opts
+	if !opts.EmulateFunctions {
+		return nil
+	}
+	now := time.Now()
+	rewrite := func(query string, literals bool) (string, bool, error) {
+		if !mayUseFunctions(query) {
+			return query, false, nil
+		}
+		rewritten, err := rewriteFunctions(query, opts.WriteTimeColumns, literals, now)
+		return rewritten, err == nil && rewritten != query, err
+	}
+	var translated message.Message
+	var err error
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Query:
+		var query string
+		var changed bool
+		if query, changed, err = rewrite(msg.Query, true); changed {
+			rewritten := *msg
+			rewritten.Query = query
+			translated = &rewritten
+		}
+	case *message.Prepare:
+		var query string
+		var changed bool
+		if query, changed, err = rewrite(msg.Query, false); changed {
+			rewritten := *msg
+			rewritten.Query = query
+			translated = &rewritten
+		}
+	case *message.Batch:
+		var children []*message.BatchChild
+		for i, child := range msg.Children {
+			if child.Query == "" {
+				continue
+			}
+			query, changed, rewriteErr := rewrite(child.Query, true)
+			if rewriteErr != nil {
+				err = rewriteErr
+				break
+			}
+			if !changed {
+				continue
+			}
+			if children == nil {
+				children = append([]*message.BatchChild(nil), msg.Children...)
+			}
+			rewritten := *child
+			rewritten.Query = query
+			children[i] = &rewritten
+		}
+		if err == nil && children != nil {
+			batch := *msg
+			batch.Children = children
+			translated = &batch
+		}
+	}
+	if err != nil {
+		return &message.Invalid{ErrorMessage: err.Error()}
+	}
+	if translated == nil {
+		return nil
+	}
+	return dc.replaceMessage(req, translated)
+}
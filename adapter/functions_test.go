@@ -0,0 +1,113 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteFunctionLiterals(t *testing.T) {
+	now := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	uuidPattern := `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{
+			"INSERT INTO ks.t (id, created) VALUES (now(), toTimestamp(now()))",
+			`^INSERT INTO ks.t \(id, created\) VALUES \(` + uuidPattern + `, 1741064767000\)$`,
+		},
+		{
+			"UPDATE t SET day = currentDate(), ts = currentTimestamp() WHERE id = uuid()",
+			`^UPDATE t SET day = '2025-03-04', ts = 1741064767000 WHERE id = ` + uuidPattern + `$`,
+		},
+		{
+			"DELETE FROM t WHERE bucket = toDate(now()) AND id = ?",
+			`^DELETE FROM t WHERE bucket = '2025-03-04' AND id = \?$`,
+		},
+		{
+			"SELECT now() FROM t WHERE ts < toUnixTimestamp(now())",
+			`^SELECT now\(\) FROM t WHERE ts < 1741064767000$`,
+		},
+		{
+			"INSERT INTO t (id, name) VALUES (1, 'now()')",
+			`^INSERT INTO t \(id, name\) VALUES \(1, 'now\(\)'\)$`,
+		},
+		{
+			"CREATE TABLE t (id uuid PRIMARY KEY, now timestamp)",
+			`^CREATE TABLE t \(id uuid PRIMARY KEY, now timestamp\)$`,
+		},
+	}
+	for _, tc := range testCases {
+		rewritten, err := rewriteFunctions(tc.query, nil, true, now)
+		require.NoError(t, err, tc.query)
+		assert.Regexp(t, regexp.MustCompile(tc.want), rewritten, tc.query)
+	}
+
+	// Every call gets a new timeuuid.
+	rewritten, err := rewriteFunctions("INSERT INTO t (a, b) VALUES (now(), now())", nil, true, now)
+	require.NoError(t, err)
+	ids := regexp.MustCompile(uuidPattern).FindAllString(rewritten, -1)
+	require.Len(t, ids, 2)
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.Equal(t, uuid.Version(1), uuid.MustParse(ids[0]).Version())
+
+	// Literals are not evaluated in prepared statements.
+	query := "INSERT INTO t (id) VALUES (now())"
+	rewritten, err = rewriteFunctions(query, nil, false, now)
+	require.NoError(t, err)
+	assert.Equal(t, query, rewritten)
+}
+
+func TestRewriteWriteTime(t *testing.T) {
+	columns := map[string]string{"ks.users": "updated_at"}
+	now := time.Now()
+
+	rewritten, err := rewriteFunctions(
+		"SELECT name, WRITETIME(Name) FROM ks.users WHERE id = ?",
+		columns,
+		false,
+		now,
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		`SELECT name, updated_at AS "writetime(Name)" FROM ks.users WHERE id = ?`,
+		rewritten,
+	)
+
+	rewritten, err = rewriteFunctions(
+		"SELECT writetime(name) AS wt FROM ks.users",
+		columns,
+		true,
+		now,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT updated_at AS wt FROM ks.users", rewritten)
+
+	_, err = rewriteFunctions("SELECT writetime(name) FROM ks.accounts", columns, true, now)
+	assert.ErrorContains(t, err, "ks.accounts")
+}
@@ -0,0 +1,138 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/adapter/frameutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with: go test -tags=unit -fuzz=FuzzInboundFrame ./adapter
+
+func fuzzSeedFrames(f *testing.F) {
+	for _, msg := range []message.Message{
+		&message.Startup{Options: map[string]string{"CQL_VERSION": "3.0.0"}},
+		&message.Query{
+			Query:   "SELECT * FROM system_traces.events WHERE session_id = ?",
+			Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelSerial},
+		},
+		&message.Prepare{Query: "INSERT INTO ks.t (a) VALUES (?)"},
+		&message.Execute{
+			QueryId: []byte("Wid"),
+			Options: &message.QueryOptions{
+				PositionalValues: []*primitive.Value{primitive.NewValue([]byte("a"))},
+			},
+		},
+		&message.Batch{Children: []*message.BatchChild{
+			{Id: []byte("id")},
+			{Query: "UPDATE t SET a = 1 WHERE b = 2"},
+		}},
+	} {
+		frm := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+		frm.RequestTracingId(true)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(f, frame.NewCodec().EncodeFrame(frm, buf))
+		f.Add(buf.Bytes())
+	}
+	// Truncated header, negative and oversized body lengths.
+	f.Add([]byte{0x04, 0x00})
+	f.Add([]byte{0x04, 0x00, 0x00, 0x01, 0x0a, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{0x04, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x00, 0x04, 0xff, 0xff, 0x00, 0x00})
+}
+
+// FuzzInboundFrame feeds arbitrary bytes through the decoding and
+// classification steps applied to every inbound frame, none of which may
+// panic.
+func FuzzInboundFrame(f *testing.F) {
+	fuzzSeedFrames(f)
+	client, server := net.Pipe()
+	f.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	dc := &driverConnection{
+		driverConn:    server,
+		adapterClient: &AdapterClient{traces: newTraceStore(10)},
+		codec:         frame.NewCodec(),
+	}
+	re := &requestExecutor{opts: &Options{}}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, payload, err := frameutil.DecodeRawFrame(bytes.NewBuffer(data))
+		if err != nil {
+			return
+		}
+		frm, err := dc.decodeFrame(payload)
+		if err != nil {
+			// Malformed frames are answered with a SyntaxError.
+			_, err := frameutil.BuildErrorFrame(
+				header,
+				&message.SyntaxError{ErrorMessage: err.Error()},
+			)
+			assert.NoError(t, err)
+			return
+		}
+		isDML(frm)
+		isSerialRead(frm)
+		consistencyOf(frm)
+		re.tryApplyConsistency(frm, map[string]string{})
+		dc.startTrace(frm, payload, time.Now())
+		if query, ok := frm.Body.Message.(*message.Query); ok {
+			parseUseKeyspace(query.Query)
+			parseDMLTarget(query.Query)
+			if vt, _, errMsg := lookupVirtualTable(query.Query); vt != nil && errMsg == nil {
+				vt.keyFilter(query.Query)
+			}
+		}
+	})
+}
+
+func FuzzTokenizeCQL(f *testing.F) {
+	f.Add("SELECT * FROM ks.t WHERE a = 'it''s' -- comment")
+	f.Add(`INSERT INTO "Ks"."T" (a) VALUES ($$body$$) /* unterminated`)
+	f.Add("DELETE FROM t WHERE a = 0x1f AND b = 1.5e3;")
+	f.Add("'")
+	f.Fuzz(func(t *testing.T, query string) {
+		for _, token := range tokenizeCQL(query) {
+			if token.kind == cqlIdentifier || token.kind == cqlNumber {
+				assert.NotEmpty(t, token.text)
+			}
+		}
+		isCQLRead(query)
+		parseDMLTarget(query)
+	})
+}
+
+func TestDecodeFrameMalformed(t *testing.T) {
+	dc := &driverConnection{codec: frame.NewCodec()}
+	// EXECUTE frame whose query id is longer than its body.
+	_, err := dc.decodeFrame(
+		[]byte{0x04, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x00, 0x04, 0xff, 0xff, 0x00, 0x00},
+	)
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// idempotentStatements holds the writes declared idempotent through
+// RegisterIdempotentStatements, which are retried like reads.
+type idempotentStatements struct {
+	// Prepared query ids of the declared statements.
+	ids sync.Map
+	// Declared statements, by query.
+	queries sync.Map
+}
+
+// declare records query, prepared with id, as idempotent.
+func (is *idempotentStatements) declare(query string, id []byte) {
+	is.queries.Store(query, struct{}{})
+	is.ids.Store(string(id), struct{}{})
+}
+
+// isIdempotent reports whether executing the statements of frm twice has the
+// same outcome as executing them once. Reads are always idempotent, writes
+// only when declared so.
+func (is *idempotentStatements) isIdempotent(frm *frame.Frame) bool {
+	if !isDML(frm) {
+		return true
+	}
+	if is == nil {
+		return false
+	}
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		_, ok := is.queries.Load(msg.Query)
+		return ok
+	case *message.Execute:
+		_, ok := is.ids.Load(string(msg.QueryId))
+		return ok
+	case *message.Batch:
+		for _, child := range msg.Children {
+			var ok bool
+			if child.Query != "" {
+				_, ok = is.queries.Load(child.Query)
+			} else {
+				_, ok = is.ids.Load(string(child.Id))
+			}
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// unsentError is the error of an AdaptMessage call whose stream could not be
+// created, ie: as its channel could not connect. The request of such calls was
+// not sent to Spanner.
+type unsentError struct {
+	err error
+}
+
+func (e *unsentError) Error() string { return e.err.Error() }
+
+func (e *unsentError) Unwrap() error { return e.err }
+
+// GRPCStatus returns the status of the wrapped error, so that unsent calls are
+// retried on the same codes as other calls.
+func (e *unsentError) GRPCStatus() *status.Status { return status.Convert(e.err) }
+
+// markUnsentStreams is a stream interceptor of the gRPC channels returning an
+// unsentError for the streams that could not be created. Requests are only
+// sent once their stream is created.
+func markUnsentStreams(
+	ctx context.Context,
+	desc *grpc.StreamDesc,
+	cc *grpc.ClientConn,
+	method string,
+	streamer grpc.Streamer,
+	opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, &unsentError{err: err}
+	}
+	return stream, nil
+}
+
+// failedBeforeExecution reports whether err guarantees that Spanner did not
+// execute the request, as it was rejected with RESOURCE_EXHAUSTED or could not
+// be sent.
+func failedBeforeExecution(err error) bool {
+	var unsent *unsentError
+	return errors.As(err, &unsent) ||
+		status.Code(err) == codes.ResourceExhausted
+}
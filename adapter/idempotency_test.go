@@ -0,0 +1,130 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestIsIdempotent(t *testing.T) {
+	insert := "INSERT INTO ks.t (id, v) VALUES (?, ?)"
+	is := &idempotentStatements{}
+	is.declare(insert, []byte("Winsert"))
+
+	for msg, want := range map[message.Message]bool{
+		&message.Query{Query: "SELECT * FROM ks.t"}:                     true,
+		&message.Query{Query: insert}:                                   true,
+		&message.Query{Query: "UPDATE ks.t SET v = v + 1 WHERE id = 1"}: false,
+		&message.Execute{QueryId: []byte("Rselect")}:                    true,
+		&message.Execute{QueryId: []byte("Winsert")}:                    true,
+		&message.Execute{QueryId: []byte("Wupdate")}:                    false,
+		&message.Batch{
+			Type: primitive.BatchTypeLogged,
+			Children: []*message.BatchChild{
+				{Query: insert},
+				{Id: []byte("Winsert")},
+			},
+		}: true,
+		&message.Batch{
+			Type: primitive.BatchTypeLogged,
+			Children: []*message.BatchChild{
+				{Id: []byte("Winsert")},
+				{Id: []byte("Wupdate")},
+			},
+		}: false,
+	} {
+		assert.Equal(t, want, is.isIdempotent(newMessageFrame(msg)), msg)
+	}
+
+	// Without declared statements, only reads are idempotent.
+	var none *idempotentStatements
+	assert.True(t, none.isIdempotent(newMessageFrame(&message.Query{Query: "SELECT * FROM ks.t"})))
+	assert.False(t, none.isIdempotent(newMessageFrame(&message.Query{Query: insert})))
+}
+
+func TestRetryNonIdempotentRequests(t *testing.T) {
+	for _, tc := range []struct {
+		err        error
+		idempotent bool
+		attempts   int
+	}{
+		{status.Error(codes.Unavailable, "error reading from server: EOF"), true, 2},
+		{status.Error(codes.Unavailable, "error reading from server: EOF"), false, 1},
+		{status.Error(codes.Unavailable, "connection error: desc = \"transport: Error while dialing\""), false, 1},
+		{&unsentError{status.Error(codes.Unavailable, "connection error: desc = \"transport: Error while dialing\"")}, false, 2},
+		{status.Error(codes.ResourceExhausted, "too many requests"), false, 2},
+		{status.Error(codes.Internal, "stream terminated by RST_STREAM"), false, 1},
+	} {
+		attempts := 0
+		_, err := runAdaptMessageWithRetry(
+			context.Background(),
+			adaptMessageRetry{
+				codes:      []codes.Code{codes.ResourceExhausted, codes.Internal, codes.Unavailable},
+				idempotent: tc.idempotent,
+				backoff:    gax.Backoff{Initial: time.Millisecond},
+			},
+			func(ctx context.Context) (adapterpb.Adapter_AdaptMessageClient, error) {
+				if attempts++; attempts == 1 {
+					return nil, tc.err
+				}
+				return &Mock_Payload_AdaptMessageClient{}, nil
+			},
+		)
+		assert.Equal(t, tc.attempts, attempts, tc.err)
+		assert.Equal(t, tc.attempts == 2, err == nil, tc.err)
+	}
+}
+
+func TestMarkUnsentStreams(t *testing.T) {
+	dialErr := status.Error(codes.Unavailable, "connection error")
+	_, err := markUnsentStreams(
+		context.Background(),
+		&grpc.StreamDesc{ServerStreams: true},
+		nil,
+		"/google.spanner.adapter.v1.Adapter/AdaptMessage",
+		func(
+			ctx context.Context,
+			desc *grpc.StreamDesc,
+			cc *grpc.ClientConn,
+			method string,
+			opts ...grpc.CallOption,
+		) (grpc.ClientStream, error) {
+			return nil, dialErr
+		},
+	)
+	assert.True(t, failedBeforeExecution(err))
+	assert.ErrorIs(t, err, dialErr)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// Errors of streams that were created are not known to be unsent.
+	assert.False(t, failedBeforeExecution(dialErr))
+	assert.True(t, failedBeforeExecution(status.Error(codes.ResourceExhausted, "quota")))
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// labelHeaderPrefix is the prefix of the metadata headers carrying
+	// connection labels.
+	labelHeaderPrefix = "x-goog-spanner-label-"
+	// StartupLabelOptionPrefix is the prefix of STARTUP options setting a
+	// connection label, ie: SPANNER_LABEL_END_USER_ID.
+	StartupLabelOptionPrefix = "SPANNER_LABEL_"
+	// Maximum length of a label value.
+	maxLabelValueLength = 256
+)
+
+var (
+	labelKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,62}$`)
+	// Matches SET SPANNER_LABEL key = 'value' statements.
+	setLabelPattern = regexp.MustCompile(
+		`(?is)^\s*set\s+spanner_label\s+(\w[\w-]*)\s*=\s*'((?:[^']|'')*)'\s*;?\s*$`,
+	)
+)
+
+// validateLabel returns an error if key or value can not be sent as a
+// metadata header.
+func validateLabel(key, value string) error {
+	if !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf(
+			"invalid label key %q, keys must match %s",
+			key,
+			labelKeyPattern.String(),
+		)
+	}
+	if len(value) > maxLabelValueLength {
+		return fmt.Errorf(
+			"value of label %q exceeds %d characters",
+			key,
+			maxLabelValueLength,
+		)
+	}
+	for _, r := range value {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf(
+				"value of label %q must only contain printable ASCII characters",
+				key,
+			)
+		}
+	}
+	return nil
+}
+
+// validateLabels validates the connection labels configured in the options.
+func validateLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if err := validateLabel(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	copied := make(map[string]string, len(labels))
+	for key, value := range labels {
+		copied[key] = value
+	}
+	return copied
+}
+
+// setLabel sets a label of the connection. An empty value removes the label.
+func (dc *driverConnection) setLabel(key, value string) error {
+	key = strings.ToLower(key)
+	if err := validateLabel(key, value); err != nil {
+		return err
+	}
+	if dc.labels == nil {
+		dc.labels = make(map[string]string)
+	}
+	if value == "" {
+		delete(dc.labels, key)
+		return nil
+	}
+	dc.labels[key] = value
+	return nil
+}
+
+// labelContext returns ctx with the connection labels appended to the outgoing
+// metadata.
+func (dc *driverConnection) labelContext(ctx context.Context) context.Context {
+	if len(dc.labels) == 0 {
+		return ctx
+	}
+	kv := make([]string, 0, 2*len(dc.labels))
+	for key, value := range dc.labels {
+		kv = append(kv, labelHeaderPrefix+key, value)
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// trySetLabels applies the labels carried by STARTUP options and SET
+// SPANNER_LABEL statements. STARTUP requests are still forwarded to Spanner,
+// SET SPANNER_LABEL statements are answered locally. Returns nil if the
+// request must be forwarded.
+func (dc *driverConnection) trySetLabels(frm *frame.Frame) message.Message {
+	switch msg := frm.Body.Message.(type) {
+	case *message.Startup:
+		for option, value := range msg.Options {
+			if !strings.HasPrefix(strings.ToUpper(option), StartupLabelOptionPrefix) {
+				continue
+			}
+			key := option[len(StartupLabelOptionPrefix):]
+			if err := dc.setLabel(key, value); err != nil {
+				return &message.ProtocolError{ErrorMessage: err.Error()}
+			}
+		}
+		return nil
+	case *message.Query:
+		m := setLabelPattern.FindStringSubmatch(msg.Query)
+		if m == nil {
+			return nil
+		}
+		if err := dc.setLabel(m[1], strings.ReplaceAll(m[2], "''", "'")); err != nil {
+			return &message.Invalid{ErrorMessage: err.Error()}
+		}
+		return &message.VoidResult{}
+	default:
+		return nil
+	}
+}
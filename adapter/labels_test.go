@@ -0,0 +1,88 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func newMessageFrame(msg message.Message) *frame.Frame {
+	return &frame.Frame{
+		Header: &frame.Header{OpCode: msg.GetOpCode()},
+		Body:   &frame.Body{Message: msg},
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, validateLabels(map[string]string{"end_user_id": "alice"}))
+	assert.Error(t, validateLabels(map[string]string{"EndUser": "alice"}))
+	assert.Error(t, validateLabels(map[string]string{"tenant": "café"}))
+}
+
+func TestSetLabels(t *testing.T) {
+	dc := &driverConnection{labels: copyLabels(map[string]string{"app": "billing"})}
+
+	// STARTUP requests are forwarded after recording their labels.
+	assert.Nil(t, dc.trySetLabels(newMessageFrame(&message.Startup{
+		Options: map[string]string{
+			"CQL_VERSION":               "3.0.0",
+			"SPANNER_LABEL_END_USER_ID": "alice",
+		},
+	})))
+	assert.Equal(t, "alice", dc.labels["end_user_id"])
+
+	// SET SPANNER_LABEL statements are answered locally.
+	assert.IsType(
+		t,
+		&message.VoidResult{},
+		dc.trySetLabels(newMessageFrame(&message.Query{
+			Query: "SET SPANNER_LABEL tenant_id = 'o''brien';",
+		})),
+	)
+	assert.Equal(t, "o'brien", dc.labels["tenant_id"])
+
+	// Empty values remove the label.
+	dc.trySetLabels(newMessageFrame(&message.Query{
+		Query: "set spanner_label app = ''",
+	}))
+	assert.NotContains(t, dc.labels, "app")
+
+	assert.IsType(
+		t,
+		&message.Invalid{},
+		dc.trySetLabels(newMessageFrame(&message.Query{
+			Query: "SET SPANNER_LABEL tenant_id = 'line\nbreak'",
+		})),
+	)
+	assert.Nil(t, dc.trySetLabels(newMessageFrame(&message.Query{
+		Query: "SELECT * FROM ks.t",
+	})))
+
+	md, ok := metadata.FromOutgoingContext(dc.labelContext(context.Background()))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"alice"}, md.Get(labelHeaderPrefix+"end_user_id"))
+	assert.Equal(t, []string{"o'brien"}, md.Get(labelHeaderPrefix+"tenant_id"))
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// Response metadata header carrying the server timings of Spanner.
+	serverTimingHeader = "server-timing"
+	// Server timing metric of the time spent in the Google front end and the
+	// Spanner backend.
+	gfeServerTiming = "gfet4t7"
+)
+
+// parseServerTiming returns the backend latency reported by the server-timing
+// header of a response, ie: "gfet4t7; dur=12.5".
+func parseServerTiming(md metadata.MD) (time.Duration, bool) {
+	for _, value := range md.Get(serverTimingHeader) {
+		for _, metric := range strings.Split(value, ",") {
+			params := strings.Split(metric, ";")
+			if strings.TrimSpace(params[0]) != gfeServerTiming {
+				continue
+			}
+			for _, param := range params[1:] {
+				dur, ok := strings.CutPrefix(strings.TrimSpace(param), "dur=")
+				if !ok {
+					continue
+				}
+				ms, err := strconv.ParseFloat(dur, 64)
+				if err != nil {
+					return 0, false
+				}
+				return time.Duration(ms * float64(time.Millisecond)), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// amendResponse decodes an encoded response, applies amend to it and returns
+// it encoded again. Responses that can not be decoded, ie: compressed
+// responses, are returned unchanged.
+func (dc *driverConnection) amendResponse(
+	encoded []byte,
+	amend func(*frame.Frame),
+) []byte {
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return encoded
+	}
+	amend(frm)
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(frm, buf); err != nil {
+		return encoded
+	}
+	return buf.Bytes()
+}
+
+// attachLatencies adds the latency of req in the proxy and in Spanner to the
+// custom payload of the encoded response, if Options.EnableLatencyPayload is
+// set.
+func (dc *driverConnection) attachLatencies(
+	req *requestState,
+	pbCli adapterpb.Adapter_AdaptMessageClient,
+	encoded []byte,
+) []byte {
+	if !dc.executor.opts.EnableLatencyPayload ||
+		req.frame.Header.Version < primitive.ProtocolVersion4 {
+		return encoded
+	}
+	payload := map[string][]byte{
+		RequestLatencyPayloadKey: []byte(time.Since(req.received).String()),
+	}
+	// The headers were received with the first response.
+	if md, err := pbCli.Header(); err == nil {
+		if backend, ok := parseServerTiming(md); ok {
+			payload[BackendLatencyPayloadKey] = []byte(backend.String())
+		}
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		for k, v := range frm.Body.CustomPayload {
+			if _, ok := payload[k]; !ok {
+				payload[k] = v
+			}
+		}
+		frm.SetCustomPayload(payload)
+	})
+}
@@ -0,0 +1,93 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestParseServerTiming(t *testing.T) {
+	testCases := []struct {
+		name   string
+		md     metadata.MD
+		want   time.Duration
+		wantOk bool
+	}{
+		{"Missing", metadata.MD{}, 0, false},
+		{"Gfe", metadata.Pairs("server-timing", "gfet4t7; dur=12.5"), 12500 * time.Microsecond, true},
+		{"SeveralMetrics", metadata.Pairs("server-timing", "other;dur=1, gfet4t7;dur=3"), 3 * time.Millisecond, true},
+		{"NoDuration", metadata.Pairs("server-timing", "gfet4t7"), 0, false},
+		{"InvalidDuration", metadata.Pairs("server-timing", "gfet4t7; dur=abc"), 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseServerTiming(tc.md)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestAttachLatencies(t *testing.T) {
+	dc := &driverConnection{
+		executor: &requestExecutor{opts: &Options{EnableLatencyPayload: true}},
+		codec:    codec,
+	}
+	response := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{})
+	response.Header.IsResponse = true
+	response.SetCustomPayload(map[string][]byte{"key": []byte("value")})
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(response, buf))
+	encoded := buf.Bytes()
+
+	req := &requestState{
+		frame: *frame.NewFrame(
+			primitive.ProtocolVersion4,
+			1,
+			&message.Query{Query: "SELECT * FROM t"},
+		),
+		received: time.Now().Add(-time.Second),
+	}
+	pbCli := &Mock_Cassandra_AdaptMessageClient{
+		header: metadata.Pairs("server-timing", "gfet4t7; dur=12.5"),
+	}
+	got, err := codec.DecodeFrame(bytes.NewBuffer(dc.attachLatencies(req, pbCli, encoded)))
+	require.NoError(t, err)
+	request, err := time.ParseDuration(
+		string(got.Body.CustomPayload[RequestLatencyPayloadKey]),
+	)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, request, time.Second)
+	assert.Equal(t, "12.5ms", string(got.Body.CustomPayload[BackendLatencyPayloadKey]))
+	assert.Equal(t, "value", string(got.Body.CustomPayload["key"]))
+
+	// Latencies are only added when enabled.
+	dc.executor.opts.EnableLatencyPayload = false
+	assert.Equal(t, encoded, dc.attachLatencies(req, pbCli, encoded))
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// Default time after which a connection waiting on Spanner or on its driver
+// is reported as stuck.
+const defaultStuckConnectionThreshold = time.Minute
+
+// pprof label of the goroutines serving a driver connection, which identifies
+// them in the goroutine stacks of ConnectionReport.
+const connectionIDLabel = "spanner_connection_id"
+
+// Connection states of ConnectionActivity.
+const (
+	// Waiting for the next request of the driver.
+	ConnectionIdle = "idle"
+	// Waiting for the response of Spanner (gRPC Recv).
+	ConnectionRecv = "recv"
+	// Writing a response to the driver.
+	ConnectionWrite = "write"
+)
+
+// ConnectionActivity describes what a driver connection is doing.
+type ConnectionActivity struct {
+	ConnectionID int
+	RemoteAddr   string
+	// Time since the connection was accepted.
+	Age time.Duration
+	// ConnectionIdle, ConnectionRecv or ConnectionWrite.
+	State string
+	// Time since the connection entered State.
+	StateDuration time.Duration
+}
+
+// ConnectionReport lists the driver connections stuck waiting on Spanner or on
+// their driver.
+type ConnectionReport struct {
+	// Number of open driver connections.
+	OpenConnections int
+	// Connections in the ConnectionRecv or ConnectionWrite state for longer
+	// than the threshold of the report, longest first.
+	Stuck []ConnectionActivity
+	// Stacks of all goroutines in the format of the pprof goroutine profile
+	// with debug=1, if requested. The goroutines of a connection have the
+	// spanner_connection_id label.
+	Stacks string
+}
+
+// connectionActivity tracks the state of a driver connection.
+type connectionActivity struct {
+	accepted   time.Time
+	remoteAddr string
+
+	mu    sync.Mutex
+	state string
+	since time.Time
+}
+
+func newConnectionActivity(remoteAddr string) *connectionActivity {
+	now := time.Now()
+	return &connectionActivity{
+		accepted:   now,
+		remoteAddr: remoteAddr,
+		state:      ConnectionIdle,
+		since:      now,
+	}
+}
+
+// set moves the connection to state. Does nothing on nil activities, ie: for
+// connections created by tests.
+func (a *connectionActivity) set(state string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.state != state {
+		a.state = state
+		a.since = time.Now()
+	}
+}
+
+// snapshot returns the activity of the connection of id at now.
+func (a *connectionActivity) snapshot(id int, now time.Time) ConnectionActivity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return ConnectionActivity{
+		ConnectionID:  id,
+		RemoteAddr:    a.remoteAddr,
+		Age:           now.Sub(a.accepted),
+		State:         a.state,
+		StateDuration: now.Sub(a.since),
+	}
+}
+
+// withConnectionLabel runs serve with the pprof label of the connection of id
+// set on the goroutine, and inherited by the goroutines it starts.
+func withConnectionLabel(ctx context.Context, id int, serve func(context.Context)) {
+	pprof.Do(ctx, pprof.Labels(connectionIDLabel, strconv.Itoa(id)), serve)
+}
+
+// ConnectionReport returns the driver connections of the proxy in the
+// ConnectionRecv or ConnectionWrite state for longer than threshold, or
+// Options.StuckConnectionThreshold if threshold is 0, along with the stacks of
+// all goroutines if stacks is set.
+func (proxy *TCPProxy) ConnectionReport(threshold time.Duration, stacks bool) ConnectionReport {
+	if threshold <= 0 {
+		threshold = proxy.stuckConnectionThreshold()
+	}
+	var report ConnectionReport
+	now := time.Now()
+	proxy.activities.Range(func(id, activity any) bool {
+		report.OpenConnections++
+		snapshot := activity.(*connectionActivity).snapshot(id.(int), now)
+		if snapshot.State != ConnectionIdle && snapshot.StateDuration >= threshold {
+			report.Stuck = append(report.Stuck, snapshot)
+		}
+		return true
+	})
+	sort.Slice(report.Stuck, func(i, j int) bool {
+		return report.Stuck[i].StateDuration > report.Stuck[j].StateDuration
+	})
+	if stacks {
+		buf := bytes.NewBuffer(nil)
+		if err := pprof.Lookup("goroutine").WriteTo(buf, 1); err == nil {
+			report.Stacks = buf.String()
+		}
+	}
+	return report
+}
+
+func (proxy *TCPProxy) stuckConnectionThreshold() time.Duration {
+	if proxy.opts.StuckConnectionThreshold > 0 {
+		return proxy.opts.StuckConnectionThreshold
+	}
+	return defaultStuckConnectionThreshold
+}
+
+// connectionReporter logs the stuck connections of a proxy every
+// Options.ConnectionReportInterval.
+type connectionReporter struct {
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// startConnectionReporter starts the periodic report of the stuck connections
+// of proxy. Returns nil unless Options.ConnectionReportInterval is set.
+func startConnectionReporter(proxy *TCPProxy) *connectionReporter {
+	interval := proxy.opts.ConnectionReportInterval
+	if interval <= 0 {
+		return nil
+	}
+	r := &connectionReporter{done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+			}
+			logConnectionReport(proxy.ConnectionReport(0, false))
+		}
+	}()
+	return r
+}
+
+// logConnectionReport logs every stuck connection of report at warning level,
+// and the number of open connections at debug level.
+func logConnectionReport(report ConnectionReport) {
+	logger.Debug("Driver connection report",
+		zap.Int("open_connections", report.OpenConnections),
+		zap.Int("stuck_connections", len(report.Stuck)))
+	for _, stuck := range report.Stuck {
+		logger.Warn("Driver connection stuck waiting on "+stuckOn(stuck.State),
+			zap.Int("connectionID", stuck.ConnectionID),
+			zap.String("remote_addr", stuck.RemoteAddr),
+			zap.String("state", stuck.State),
+			zap.Duration("state_duration", stuck.StateDuration),
+			zap.Duration("age", stuck.Age))
+	}
+}
+
+func stuckOn(state string) string {
+	if state == ConnectionWrite {
+		return "its driver"
+	}
+	return "Spanner"
+}
+
+// close stops the periodic report.
+func (r *connectionReporter) close() {
+	if r != nil {
+		r.closeOnce.Do(func() { close(r.done) })
+	}
+}
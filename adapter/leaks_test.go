@@ -0,0 +1,77 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionReport(t *testing.T) {
+	proxy := &TCPProxy{opts: Options{StuckConnectionThreshold: time.Hour}}
+	idle := newConnectionActivity("10.0.0.1:1234")
+	recv := newConnectionActivity("10.0.0.2:1234")
+	recv.set(ConnectionRecv)
+	recv.since = time.Now().Add(-2 * time.Minute)
+	write := newConnectionActivity("10.0.0.3:1234")
+	write.set(ConnectionWrite)
+	write.since = time.Now().Add(-time.Minute)
+	idle.since = time.Now().Add(-time.Hour)
+	proxy.activities.Store(1, idle)
+	proxy.activities.Store(2, recv)
+	proxy.activities.Store(3, write)
+
+	// Connections are not stuck before the threshold.
+	report := proxy.ConnectionReport(0, false)
+	assert.Equal(t, 3, report.OpenConnections)
+	assert.Empty(t, report.Stuck)
+
+	// Idle connections are never stuck.
+	report = proxy.ConnectionReport(30*time.Second, false)
+	require.Len(t, report.Stuck, 2)
+	assert.Equal(t, 2, report.Stuck[0].ConnectionID)
+	assert.Equal(t, ConnectionRecv, report.Stuck[0].State)
+	assert.Equal(t, "10.0.0.2:1234", report.Stuck[0].RemoteAddr)
+	assert.Equal(t, 3, report.Stuck[1].ConnectionID)
+	assert.Equal(t, ConnectionWrite, report.Stuck[1].State)
+	assert.Empty(t, report.Stacks)
+}
+
+func TestConnectionReportStacks(t *testing.T) {
+	proxy := &TCPProxy{}
+	proxy.activities.Store(7, newConnectionActivity("10.0.0.1:1234"))
+	var report ConnectionReport
+	withConnectionLabel(context.Background(), 7, func(context.Context) {
+		report = proxy.ConnectionReport(0, true)
+	})
+	assert.Contains(t, report.Stacks, `"spanner_connection_id":"7"`)
+}
+
+func TestConnectionReporterClose(t *testing.T) {
+	assert.Nil(t, startConnectionReporter(&TCPProxy{}))
+	r := startConnectionReporter(&TCPProxy{opts: Options{ConnectionReportInterval: time.Hour}})
+	require.NotNil(t, r)
+	r.close()
+	assert.NotPanics(t, r.close)
+}
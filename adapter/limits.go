@@ -0,0 +1,171 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/googleapis/go-spanner-cassandra/adapter/frameutil"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// Default maximum body length of inbound Cassandra frames, which is the
+	// default native_transport_max_frame_size of Cassandra 3.x.
+	defaultMaxFrameSize = 256 << 20
+	// Default maximum size of the requests sent to Spanner, which is the
+	// maximum commit size of Spanner.
+	defaultMaxRequestSize = 100 << 20
+	// Default maximum size of the responses buffered by a connection, which is
+	// the default maximum frame size.
+	defaultMaxResponseSize = defaultMaxFrameSize
+	// Room left in the gRPC messages of AdaptMessage calls for the fields of
+	// the request other than its payload, ie: its attachments.
+	requestEnvelopeSize = 1 << 20
+	// Time given to a rejected connection to send its first request.
+	rejectedConnectionTimeout = 5 * time.Second
+)
+
+// acceptsConnection reports whether a new connection stays within
+// MaxConnections.
+func (proxy *TCPProxy) acceptsConnection() bool {
+	maxConnections := proxy.opts.MaxConnections
+	return maxConnections <= 0 ||
+		proxy.client.stats.activeConnections.Load() < int64(maxConnections)
+}
+
+// rejectConnection answers the first request of a connection accepted over
+// MaxConnections with an Overloaded error, and closes the connection.
+// Connections of other protocols are closed right away.
+func (proxy *TCPProxy) rejectConnection(conn net.Conn) {
+	defer conn.Close()
+	proxy.client.stats.connectionRejected()
+	logger.Debug(
+		"Spanner proxy rejected a connection over MaxConnections",
+		zap.String("remote_addr", conn.RemoteAddr().String()),
+		zap.Int("max_connections", proxy.opts.MaxConnections),
+	)
+	if _, ok := proxy.opts.Protocol.(StreamProtocol); ok {
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(rejectedConnectionTimeout))
+	header, err := frame.NewRawCodec().DecodeHeader(conn)
+	if err != nil {
+		return
+	}
+	discardBody(conn, header)
+	encoded, err := frameutil.BuildErrorFrame(header, &message.Overloaded{
+		ErrorMessage: fmt.Sprintf(
+			"Too many connections, the proxy accepts at most %d",
+			proxy.opts.MaxConnections,
+		),
+	})
+	if err != nil {
+		return
+	}
+	_, _ = conn.Write(encoded)
+}
+
+// discardBody reads and drops the body of a frame from a connection about to
+// be closed, so that the error written back is not lost to a connection reset.
+// The body is read for at most rejectedConnectionTimeout.
+func discardBody(conn net.Conn, header *frame.Header) {
+	_ = conn.SetReadDeadline(time.Now().Add(rejectedConnectionTimeout))
+	_, _ = io.CopyN(io.Discard, conn, int64(header.BodyLength))
+}
+
+// maxSendMsgSize returns the maximum size of the gRPC messages sent by
+// AdaptMessage calls, which fits requests of MaxRequestSize.
+func maxSendMsgSize(opts Options) int {
+	size := opts.MaxRequestSize
+	if size <= 0 {
+		size = defaultMaxRequestSize
+	}
+	return size + requestEnvelopeSize
+}
+
+// checkRequestSize returns an Invalid error message if the request sent to
+// Spanner for req exceeds MaxRequestSize, naming its size and the limit,
+// instead of sending a request gRPC or Spanner would fail. Spanner receives a
+// request in a single message, so the values of larger requests must be split
+// by the application.
+func (dc *driverConnection) checkRequestSize(req *requestState) message.Message {
+	limit := dc.executor.opts.MaxRequestSize
+	size := proto.Size(req.pb)
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+	logger.Debug("Spanner proxy rejected a request over MaxRequestSize",
+		zap.Int("connectionID", dc.connectionID),
+		zap.Int("size", size),
+		zap.Int("max_request_size", limit),
+	)
+	return &message.Invalid{
+		ErrorMessage: fmt.Sprintf(
+			"Request of %d bytes exceeds the maximum request size of %d bytes "+
+				"(MaxRequestSize); split large values across rows, ie: chunks of "+
+				"a blob, as Spanner limits the size of a commit to 100MiB",
+			size,
+			limit,
+		),
+	}
+}
+
+// responseTooLargeError is returned when the response to a request grows over
+// MaxResponseSize while it is buffered.
+type responseTooLargeError struct {
+	size  int
+	limit int
+}
+
+func (e *responseTooLargeError) Error() string {
+	return fmt.Sprintf(
+		"Response of more than %d bytes exceeds the maximum response size of "+
+			"%d bytes (MaxResponseSize); page the read with a smaller page size, "+
+			"or restrict it with a LIMIT",
+		e.size,
+		e.limit,
+	)
+}
+
+// checkResponseSize returns a responseTooLargeError if size bytes of the
+// response to a request exceed MaxResponseSize.
+func (dc *driverConnection) checkResponseSize(size int) error {
+	limit := dc.executor.opts.MaxResponseSize
+	if limit <= 0 || size <= limit {
+		return nil
+	}
+	return &responseTooLargeError{size: size, limit: limit}
+}
+
+// responseSizeMessage returns the Invalid error message answering a request
+// whose response exceeded MaxResponseSize, or nil for other errors.
+func responseSizeMessage(err error) message.Message {
+	var tooLarge *responseTooLargeError
+	if !errors.As(err, &tooLarge) {
+		return nil
+	}
+	return &message.Invalid{ErrorMessage: tooLarge.Error()}
+}
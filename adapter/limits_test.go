@@ -0,0 +1,181 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cqlTestProtocol is a Cassandra protocol, served by the Cassandra specific
+// connection handling.
+type cqlTestProtocol struct{}
+
+func (p *cqlTestProtocol) Name() string {
+	return "cassandra"
+}
+
+func newLimitedProxy(t *testing.T, opts Options) *TCPProxy {
+	t.Helper()
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	opts.DatabaseUri = "projects/test/instances/test/databases/test"
+	opts.TCPEndpoint = "localhost:0"
+	opts.Protocol = &cqlTestProtocol{}
+	opts.GoogleApiOpts = SkipAuthOpts
+	proxy, err := NewTCPProxy(opts)
+	require.NoError(t, err)
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func writeFrame(t *testing.T, conn net.Conn, streamId int16, msg message.Message) {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(
+		frame.NewFrame(primitive.ProtocolVersion4, streamId, msg),
+		buf,
+	))
+	_, err := conn.Write(buf.Bytes())
+	require.NoError(t, err)
+}
+
+func TestMaxConnections(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{MaxConnections: 1})
+
+	accepted, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer accepted.Close()
+	require.Eventually(t, func() bool {
+		return proxy.Stats().ActiveConnections == 1
+	}, time.Second, 10*time.Millisecond)
+
+	rejected, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer rejected.Close()
+	writeFrame(t, rejected, 5, &message.Options{})
+	got, err := codec.DecodeFrame(rejected)
+	require.NoError(t, err)
+	assert.Equal(t, int16(5), got.Header.StreamId)
+	assert.IsType(t, &message.Overloaded{}, got.Body.Message)
+	_, err = rejected.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	stats := proxy.Stats()
+	assert.Equal(t, int64(1), stats.RejectedConnections)
+	assert.Equal(t, int64(1), stats.TotalConnections)
+}
+
+func TestMaxFrameSize(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{MaxFrameSize: 16})
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	writeFrame(t, conn, 3, &message.Query{
+		Query:   "SELECT * FROM ks.a_table_with_a_long_name",
+		Options: &message.QueryOptions{},
+	})
+	got, err := codec.DecodeFrame(conn)
+	require.NoError(t, err)
+	assert.Equal(t, int16(3), got.Header.StreamId)
+	require.IsType(t, &message.ProtocolError{}, got.Body.Message)
+	assert.Contains(
+		t,
+		got.Body.Message.(*message.ProtocolError).ErrorMessage,
+		"exceeds maximum allowed length 16",
+	)
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestCheckRequestSize(t *testing.T) {
+	dc := &driverConnection{
+		executor: &requestExecutor{opts: &Options{MaxRequestSize: 64}},
+	}
+	req := &requestState{pb: &adapterpb.AdaptMessageRequest{Payload: make([]byte, 16)}}
+	assert.Nil(t, dc.checkRequestSize(req))
+
+	req.pb.Payload = make([]byte, 64)
+	errMsg := dc.checkRequestSize(req)
+	require.IsType(t, &message.Invalid{}, errMsg)
+	assert.Contains(
+		t,
+		errMsg.(*message.Invalid).ErrorMessage,
+		"exceeds the maximum request size of 64 bytes",
+	)
+
+	assert.Equal(t, defaultMaxRequestSize+requestEnvelopeSize, maxSendMsgSize(Options{}))
+	assert.Equal(t, 64+requestEnvelopeSize, maxSendMsgSize(Options{MaxRequestSize: 64}))
+}
+
+func TestCheckResponseSize(t *testing.T) {
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{},
+		executor:      &requestExecutor{opts: &Options{MaxResponseSize: 16}},
+	}
+	req := &requestState{frame: *newPrepareFrame(1, "SELECT * FROM t")}
+	payload, err := dc.receiveGrpcResponse(
+		&Mock_Payload_AdaptMessageClient{payload: make([]byte, 16)},
+		req,
+	)
+	require.NoError(t, err)
+	assert.Len(t, payload, 16)
+
+	_, err = dc.receiveGrpcResponse(
+		&Mock_Payload_AdaptMessageClient{payload: make([]byte, 32)},
+		req,
+	)
+	var tooLarge *responseTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	errMsg := dc.requestErrorMessage(req, err)
+	require.IsType(t, &message.Invalid{}, errMsg)
+	assert.Contains(
+		t,
+		errMsg.(*message.Invalid).ErrorMessage,
+		"exceeds the maximum response size of 16 bytes",
+	)
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{HandshakeTimeout: 50 * time.Millisecond})
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	require.Eventually(t, func() bool {
+		return proxy.Stats().ActiveConnections == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), proxy.Stats().HandshakeTimeouts)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Time given to a connection to complete its TLS handshake.
+var tlsHandshakeTimeout = 10 * time.Second
+
+// ListenerConfig configures an additional listener of the proxy, whose
+// connections are served by their own database or with their own labels.
+type ListenerConfig struct {
+	// Local endpoint to listen on (ie: "localhost:9043").
+	TCPEndpoint string
+	// Optional Spanner database of the connections of the listener, in the
+	// format of Options.DatabaseUri. Defaults to Options.DatabaseUri.
+	DatabaseUri string
+	// Optional labels of the connections of the listener, added to
+	// Options.ConnectionLabels. Defaults to empty.
+	ConnectionLabels map[string]string
+	// Optional TLS configuration of the listener, whose connections are served
+	// over TLS if set. Defaults to nil (plain text).
+	TLSConfig *tls.Config
+	// Optional databases and labels of the connections requesting a server
+	// name (SNI) in their TLS handshake, by server name. Connections
+	// requesting other server names are served like the other connections of
+	// the listener. Requires TLSConfig. Defaults to empty.
+	ServerNames map[string]ServerNameConfig
+}
+
+// ServerNameConfig configures the connections requesting a server name in
+// their TLS handshake.
+type ServerNameConfig struct {
+	// Optional Spanner database of the connections, in the format of
+	// Options.DatabaseUri. Defaults to the database of the listener.
+	DatabaseUri string
+	// Optional labels of the connections, added to the labels of the
+	// listener. Defaults to empty.
+	ConnectionLabels map[string]string
+}
+
+// listenerRoute is the database and labels of the connections of a listener.
+type listenerRoute struct {
+	client *AdapterClient
+	labels map[string]string
+	// TLS configuration of the listener, nil for plain text listeners.
+	tlsConfig *tls.Config
+	// Routes of the connections requesting server names, by lower cased
+	// server name.
+	serverNames map[string]*listenerRoute
+}
+
+// newListenerRoute returns the route of the connections of a listener serving
+// databaseUri, with labels added to the labels of parent.
+func newListenerRoute(
+	ctx context.Context,
+	dbs *databaseClients,
+	parent *listenerRoute,
+	databaseUri string,
+	labels map[string]string,
+) (*listenerRoute, error) {
+	if err := validateLabels(labels); err != nil {
+		return nil, err
+	}
+	route := &listenerRoute{
+		client: parent.client,
+		labels: copyLabels(parent.labels),
+	}
+	for key, value := range labels {
+		route.labels[key] = value
+	}
+	if databaseUri != "" {
+		var err error
+		if route.client, err = dbs.get(ctx, databaseUri); err != nil {
+			return nil, err
+		}
+	}
+	return route, nil
+}
+
+// newListenerRoutes returns the routes of the connections of opts.Listeners,
+// in order.
+func newListenerRoutes(
+	ctx context.Context,
+	dbs *databaseClients,
+	opts Options,
+) ([]*listenerRoute, error) {
+	base := &listenerRoute{client: dbs.client, labels: opts.ConnectionLabels}
+	routes := make([]*listenerRoute, 0, len(opts.Listeners))
+	for _, cfg := range opts.Listeners {
+		if cfg.TCPEndpoint == "" {
+			return nil, fmt.Errorf("listener without TCPEndpoint")
+		}
+		if len(cfg.ServerNames) > 0 && cfg.TLSConfig == nil {
+			return nil, fmt.Errorf(
+				"listener %s has ServerNames but no TLSConfig",
+				cfg.TCPEndpoint,
+			)
+		}
+		route, err := newListenerRoute(ctx, dbs, base, cfg.DatabaseUri, cfg.ConnectionLabels)
+		if err != nil {
+			return nil, err
+		}
+		route.tlsConfig = cfg.TLSConfig
+		if len(cfg.ServerNames) > 0 {
+			route.serverNames = make(map[string]*listenerRoute, len(cfg.ServerNames))
+		}
+		for name, sn := range cfg.ServerNames {
+			snRoute, err := newListenerRoute(ctx, dbs, route, sn.DatabaseUri, sn.ConnectionLabels)
+			if err != nil {
+				return nil, err
+			}
+			route.serverNames[strings.ToLower(name)] = snRoute
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// accept completes the TLS handshake of a connection of a TLS listener, and
+// returns the route of the server name it requested.
+func (route *listenerRoute) accept(
+	ctx context.Context,
+	conn net.Conn,
+) (net.Conn, *listenerRoute, error) {
+	if route.tlsConfig == nil {
+		return conn, route, nil
+	}
+	tlsConn := tls.Server(conn, route.tlsConfig)
+	ctx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	serverName := strings.ToLower(tlsConn.ConnectionState().ServerName)
+	if snRoute, ok := route.serverNames[serverName]; ok {
+		return tlsConn, snRoute, nil
+	}
+	return tlsConn, route, nil
+}
@@ -0,0 +1,185 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+// newTestTLSConfig returns the TLS configuration of a server with a self
+// signed certificate for names, and the pool of clients trusting it.
+func newTestTLSConfig(t *testing.T, names ...string) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}, pool
+}
+
+func TestNewListenerRoutes(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	cl := &AdapterClient{opts: Options{DatabaseUri: testDefaultDatabase}}
+	dbs := newDatabaseClients(cl)
+
+	routes, err := newListenerRoutes(context.Background(), dbs, Options{
+		ConnectionLabels: map[string]string{"app": "shop"},
+		Listeners: []ListenerConfig{
+			{TCPEndpoint: "localhost:0", ConnectionLabels: map[string]string{"tier": "batch"}},
+			{
+				TCPEndpoint: "localhost:0",
+				DatabaseUri: testOrdersDatabase,
+				TLSConfig:   &tls.Config{},
+				ServerNames: map[string]ServerNameConfig{
+					"Tenant-A.example.com": {ConnectionLabels: map[string]string{"tenant": "a"}},
+					"legacy.example.com":   {DatabaseUri: testDefaultDatabase},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, routes, 2)
+
+	assert.Same(t, cl, routes[0].client)
+	assert.Equal(t, map[string]string{"app": "shop", "tier": "batch"}, routes[0].labels)
+	assert.Nil(t, routes[0].tlsConfig)
+
+	orders, err := dbs.get(context.Background(), testOrdersDatabase)
+	require.NoError(t, err)
+	assert.Equal(t, testOrdersDatabase, orders.opts.DatabaseUri)
+	assert.Same(t, orders, routes[1].client)
+	assert.Len(t, dbs.all(), 2)
+
+	tenant := routes[1].serverNames["tenant-a.example.com"]
+	require.NotNil(t, tenant)
+	assert.Same(t, orders, tenant.client)
+	assert.Equal(t, map[string]string{"app": "shop", "tenant": "a"}, tenant.labels)
+	assert.Same(t, cl, routes[1].serverNames["legacy.example.com"].client)
+
+	for _, cfg := range []ListenerConfig{
+		{},
+		{TCPEndpoint: "localhost:0", ConnectionLabels: map[string]string{"Tier": "batch"}},
+		{TCPEndpoint: "localhost:0", DatabaseUri: "orders"},
+		{
+			TCPEndpoint: "localhost:0",
+			ServerNames: map[string]ServerNameConfig{"a.example.com": {}},
+		},
+	} {
+		_, err := newListenerRoutes(context.Background(), dbs, Options{
+			Listeners: []ListenerConfig{cfg},
+		})
+		assert.Error(t, err)
+	}
+}
+
+func TestListenersServeTheirDatabase(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		database := md.Get(resourcePrefixHeader)[0]
+		return &Mock_Payload_AdaptMessageClient{payload: []byte(database + "\n")}, nil
+	}
+	tlsConfig, pool := newTestTLSConfig(t, "proxy.example.com", "orders.example.com")
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:   testDefaultDatabase,
+		TCPEndpoint:   "localhost:0",
+		Protocol:      &lineProtocol{},
+		GoogleApiOpts: SkipAuthOpts,
+		Listeners: []ListenerConfig{
+			{TCPEndpoint: "localhost:0", DatabaseUri: testOrdersDatabase},
+			{
+				TCPEndpoint: "localhost:0",
+				TLSConfig:   tlsConfig,
+				ServerNames: map[string]ServerNameConfig{
+					"orders.example.com": {DatabaseUri: testOrdersDatabase},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+	addrs := proxy.ListenerAddrs()
+	require.Len(t, addrs, 2)
+
+	query := func(conn net.Conn) string {
+		defer conn.Close()
+		_, err := conn.Write([]byte("hello\n"))
+		require.NoError(t, err)
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err)
+		return line
+	}
+	dialTLS := func(serverName string) net.Conn {
+		conn, err := tls.Dial("tcp", addrs[1].String(), &tls.Config{
+			ServerName: serverName,
+			RootCAs:    pool,
+		})
+		require.NoError(t, err)
+		return conn
+	}
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	assert.Equal(t, testDefaultDatabase+"\n", query(conn))
+
+	conn, err = net.Dial("tcp", addrs[0].String())
+	require.NoError(t, err)
+	assert.Equal(t, testOrdersDatabase+"\n", query(conn))
+
+	assert.Equal(t, testOrdersDatabase+"\n", query(dialTLS("orders.example.com")))
+	assert.Equal(t, testDefaultDatabase+"\n", query(dialTLS("proxy.example.com")))
+}
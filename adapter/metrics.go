@@ -23,10 +23,14 @@ import (
 	"hash/fnv"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/google/uuid"
 	"go.opentelemetry.io/contrib/detectors/gcp"
 	"go.opentelemetry.io/otel/attribute"
@@ -66,6 +70,11 @@ const (
 	// Metric names
 	metricNameOperationLatencies = "operation_latencies"
 	metricNameOperationCount     = "operation_count"
+	metricNameAttemptLatencies   = "attempt_latencies"
+	metricNameAttemptCount       = "attempt_count"
+
+	// Method name of AdaptMessage calls in the method metric label.
+	adaptMessageMethod = "/google.spanner.adapter.v1.Adapter/AdaptMessage"
 
 	// Metric units
 	metricUnitMS    = "ms"
@@ -147,6 +156,18 @@ var (
 			},
 			recordedPerAttempt: false,
 		},
+		metricNameAttemptCount: {
+			additionalAttrs: []string{
+				metricLabelKeyStatus,
+			},
+			recordedPerAttempt: true,
+		},
+		metricNameAttemptLatencies: {
+			additionalAttrs: []string{
+				metricLabelKeyStatus,
+			},
+			recordedPerAttempt: true,
+		},
 	}
 
 	// Generates unique client ID in the format go-<random UUID>@<hostname>
@@ -248,15 +269,14 @@ type builtinMetricsTracerFactory struct {
 	// Metrics instruments
 	operationLatencies metric.Float64Histogram // Histogram for operation latencies.
 	operationCount     metric.Int64Counter     // Counter for the number of operations.
+	attemptLatencies   metric.Float64Histogram // Histogram for attempt latencies.
+	attemptCount       metric.Int64Counter     // Counter for the number of attempts.
 }
 
-func newBuiltinMetricsTracerFactory(
-	ctx context.Context,
-	dbpath, compression string,
-	isEnableGRPCBuiltInMetrics bool,
-	metricsProvider metric.MeterProvider,
-	opts ...option.ClientOption,
-) (*builtinMetricsTracerFactory, error) {
+// newClientUID returns the unique identifier of a client, sent with its
+// requests and as the client_uid attribute of its built-in metrics. Returns an
+// empty string if it can not be generated.
+func newClientUID() string {
 	clientUID, err := generateClientUID()
 	if err != nil {
 		log.Printf(
@@ -265,6 +285,16 @@ func newBuiltinMetricsTracerFactory(
 			metricLabelKeyClientUID,
 		)
 	}
+	return clientUID
+}
+
+func newBuiltinMetricsTracerFactory(
+	ctx context.Context,
+	dbpath, clientUID, compression string,
+	isEnableGRPCBuiltInMetrics bool,
+	metricsProvider metric.MeterProvider,
+	opts ...option.ClientOption,
+) (*builtinMetricsTracerFactory, error) {
 	project, instance, database, err := parseDatabaseName(dbpath)
 	if err != nil {
 		return nil, err
@@ -393,6 +423,26 @@ func (tf *builtinMetricsTracerFactory) createInstruments(
 	if err != nil {
 		return err
 	}
+
+	// Create attempt_latencies
+	tf.attemptLatencies, err = meter.Float64Histogram(
+		nativeMetricsPrefix+metricNameAttemptLatencies,
+		metric.WithDescription(
+			"Time an individual attempt took.",
+		),
+		metric.WithUnit(metricUnitMS),
+		metric.WithExplicitBucketBoundaries(bucketBounds...),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Create attempt_count
+	tf.attemptCount, err = meter.Int64Counter(
+		nativeMetricsPrefix+metricNameAttemptCount,
+		metric.WithDescription("The number of attempts made for the operation, including the initial attempt."),
+		metric.WithUnit(metricUnitCount),
+	)
 	return err
 }
 
@@ -410,10 +460,24 @@ type builtinMetricsTracer struct {
 	// Metrics instruments
 	instrumentOperationLatencies metric.Float64Histogram // Histogram for operation latencies.
 	instrumentOperationCount     metric.Int64Counter     // Counter for the number of operations.
+	instrumentAttemptLatencies   metric.Float64Histogram // Histogram for attempt latencies.
+	instrumentAttemptCount       metric.Int64Counter     // Counter for the number of attempts.
 
 	method string // The method being traced.
 
-	currOp *opTracer // The current operation tracer.
+	currOp      *opTracer      // The current operation tracer.
+	currAttempt *attemptTracer // The current attempt tracer.
+}
+
+// attemptTracer is used to record metrics for a single attempt (RPC) of an
+// operation.
+type attemptTracer struct {
+	startTime time.Time // The start time of the attempt.
+
+	// status is the gRPC status code of the attempt.
+	status string
+
+	done bool // Whether the completion of the attempt was recorded.
 }
 
 // opTracer is used to record metrics for the entire operation, including
@@ -450,6 +514,9 @@ func (o *opTracer) setDirectPathEnabled(enabled bool) {
 func (tf *builtinMetricsTracerFactory) createBuiltinMetricsTracer(
 	ctx context.Context,
 ) builtinMetricsTracer {
+	if tf == nil {
+		return builtinMetricsTracer{ctx: ctx}
+	}
 	// Operation has started but not the attempt.
 	// So, create only operation tracer and not attempt tracer
 	currOpTracer := opTracer{}
@@ -464,9 +531,16 @@ func (tf *builtinMetricsTracerFactory) createBuiltinMetricsTracer(
 
 		instrumentOperationLatencies: tf.operationLatencies,
 		instrumentOperationCount:     tf.operationCount,
+		instrumentAttemptLatencies:   tf.attemptLatencies,
+		instrumentAttemptCount:       tf.attemptCount,
 	}
 }
 
+// startAttempt starts tracing a new attempt of the operation.
+func (mt *builtinMetricsTracer) startAttempt() {
+	mt.currAttempt = &attemptTracer{startTime: time.Now()}
+}
+
 // toOtelMetricAttrs: converts metric attributes values captured throughout the
 // operation/attempt to OpenTelemetry attributes format, combines these with
 // common client attributes and returns.
@@ -480,19 +554,32 @@ func (mt *builtinMetricsTracer) toOtelMetricAttrs(
 		)
 	}
 	// Get metric details
-	_, found := metricsDetails[metricName]
+	details, found := metricsDetails[metricName]
 	if !found {
 		return nil, fmt.Errorf(
 			"unable to create attributes list for unknown metric: %v",
 			metricName,
 		)
 	}
+	status := mt.currOp.status
+	if details.recordedPerAttempt {
+		if mt.currAttempt == nil {
+			return nil, fmt.Errorf(
+				"unable to create attributes list for metric %v without attempt",
+				metricName,
+			)
+		}
+		status = mt.currAttempt.status
+	}
 
 	return []attribute.KeyValue{
 		attribute.String(
 			metricLabelKeyMethod,
 			strings.ReplaceAll(
-				strings.TrimPrefix(mt.method, "/google.spanner.v1."),
+				strings.TrimPrefix(
+					strings.TrimPrefix(mt.method, "/google.spanner.adapter.v1."),
+					"/google.spanner.v1.",
+				),
 				"/",
 				".",
 			),
@@ -505,7 +592,7 @@ func (mt *builtinMetricsTracer) toOtelMetricAttrs(
 			metricLabelKeyDirectPathUsed,
 			strconv.FormatBool(mt.currOp.directPathUsed),
 		),
-		attribute.String(metricLabelKeyStatus, mt.currOp.status),
+		attribute.String(metricLabelKeyStatus, status),
 	}, nil
 }
 
@@ -562,6 +649,315 @@ func recordOperationCompletion(mt *builtinMetricsTracer) {
 	)
 }
 
+// recordAttemptCompletion records the attempt specific metrics of the current
+// attempt once, with the outcome of the attempt.
+func recordAttemptCompletion(mt *builtinMetricsTracer, err error) {
+	if !mt.builtInEnabled || mt.currAttempt == nil || mt.currAttempt.done {
+		return
+	}
+	mt.currAttempt.done = true
+	mt.currOp.attemptCount++
+	code, _ := convertToGrpcStatusErr(err)
+	mt.currAttempt.status = code.String()
+
+	// Record attempt_count
+	attemptCntAttrs, err := mt.toOtelMetricAttrs(metricNameAttemptCount)
+	if err != nil {
+		return
+	}
+	mt.instrumentAttemptCount.Add(
+		mt.ctx,
+		1,
+		metric.WithAttributes(attemptCntAttrs...),
+	)
+
+	// Record attempt_latencies
+	attemptLatAttrs, err := mt.toOtelMetricAttrs(metricNameAttemptLatencies)
+	if err != nil {
+		return
+	}
+	mt.instrumentAttemptLatencies.Record(
+		mt.ctx,
+		convertToMs(time.Since(mt.currAttempt.startTime)),
+		metric.WithAttributes(attemptLatAttrs...),
+	)
+}
+
+// recordCompletion records the completion of the current attempt and of the
+// operation with the final outcome of the operation.
+func recordCompletion(mt *builtinMetricsTracer, err error) {
+	if mt == nil || !mt.builtInEnabled {
+		return
+	}
+	recordAttemptCompletion(mt, err)
+	code, _ := convertToGrpcStatusErr(err)
+	mt.currOp.setStatus(code.String())
+	recordOperationCompletion(mt)
+}
+
 func convertToMs(d time.Duration) float64 {
 	return float64(d.Nanoseconds()) / float64(time.Millisecond)
 }
+
+// requestStage is a step of the handling of a request by the proxy.
+type requestStage int
+
+const (
+	// Reading the frame from the driver connection, from its first bytes.
+	stageTCPRead requestStage = iota
+	// Decoding the frame.
+	stageDecode
+	// Translating the request and adding its attachments.
+	stageAttachments
+	// Sending the AdaptMessage request, including retries.
+	stageGrpcSend
+	// Waiting for the first response chunk, from the end of the send.
+	stageFirstResponse
+	// Waiting for the last response chunk, from the end of the send.
+	stageLastResponse
+	// Writing the response to the driver connection.
+	stageTCPWrite
+	numRequestStages
+)
+
+var requestStageNames = [numRequestStages]string{
+	"tcp_read",
+	"decode",
+	"attachments",
+	"grpc_send",
+	"first_response",
+	"last_response",
+	"tcp_write",
+}
+
+func (s requestStage) String() string {
+	return requestStageNames[s]
+}
+
+// Upper bounds of the buckets of the request stage histograms. Latencies
+// above the last bound are counted in an overflow bucket.
+var stageBucketBounds = [...]time.Duration{
+	10 * time.Microsecond,
+	25 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyHistogram is the distribution of the latencies of a request stage.
+type LatencyHistogram struct {
+	// Upper bounds of the buckets.
+	Bounds []time.Duration
+	// Number of latencies in each bucket. The last count, past the last
+	// bound, is the number of latencies above all bounds.
+	Counts []int64
+	// Number of recorded latencies.
+	Count int64
+	// Sum of the recorded latencies.
+	Sum time.Duration
+}
+
+// Mean returns the mean of the recorded latencies.
+func (h LatencyHistogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket of the q quantile (ie: 0.99)
+// of the recorded latencies. Returns the last bound if the quantile is above
+// all bounds.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := int64(q * float64(h.Count))
+	var seen int64
+	for i, bound := range h.Bounds {
+		seen += h.Counts[i]
+		if seen > rank {
+			return bound
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// latencyHistogram counts latencies in the buckets of stageBucketBounds
+// without locking.
+type latencyHistogram struct {
+	counts [len(stageBucketBounds) + 1]atomic.Int64
+	count  atomic.Int64
+	sum    atomic.Int64
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	i := sort.Search(len(stageBucketBounds), func(i int) bool {
+		return d <= stageBucketBounds[i]
+	})
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	snapshot := LatencyHistogram{
+		Bounds: stageBucketBounds[:],
+		Counts: make([]int64, len(h.counts)),
+		Count:  h.count.Load(),
+		Sum:    time.Duration(h.sum.Load()),
+	}
+	for i := range h.counts {
+		snapshot.Counts[i] = h.counts[i].Load()
+	}
+	return snapshot
+}
+
+// Upper bounds of the buckets of the payload size histograms, powers of two
+// from 64B to 256MB. Sizes above the last bound are counted in an overflow
+// bucket.
+var sizeBucketBounds = func() (bounds [23]int64) {
+	for i := range bounds {
+		bounds[i] = 64 << i
+	}
+	return bounds
+}()
+
+// SizeHistogram is the distribution of the sizes in bytes of payloads.
+type SizeHistogram struct {
+	// Upper bounds of the buckets.
+	Bounds []int64
+	// Number of sizes in each bucket. The last count, past the last bound, is
+	// the number of sizes above all bounds.
+	Counts []int64
+	// Number of recorded sizes.
+	Count int64
+	// Sum of the recorded sizes.
+	Sum int64
+}
+
+// Mean returns the mean of the recorded sizes.
+func (h SizeHistogram) Mean() int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / h.Count
+}
+
+// Quantile returns the upper bound of the bucket of the q quantile (ie: 0.99)
+// of the recorded sizes. Returns the last bound if the quantile is above all
+// bounds.
+func (h SizeHistogram) Quantile(q float64) int64 {
+	if h.Count == 0 || len(h.Bounds) == 0 {
+		return 0
+	}
+	rank := int64(q * float64(h.Count))
+	var seen int64
+	for i, bound := range h.Bounds {
+		seen += h.Counts[i]
+		if seen > rank {
+			return bound
+		}
+	}
+	return h.Bounds[len(h.Bounds)-1]
+}
+
+// since returns the distribution of the sizes recorded after previous, an
+// earlier snapshot of the same histogram.
+func (h SizeHistogram) since(previous SizeHistogram) SizeHistogram {
+	delta := SizeHistogram{
+		Bounds: h.Bounds,
+		Counts: append([]int64(nil), h.Counts...),
+		Count:  h.Count - previous.Count,
+		Sum:    h.Sum - previous.Sum,
+	}
+	for i := range previous.Counts {
+		if i < len(delta.Counts) {
+			delta.Counts[i] -= previous.Counts[i]
+		}
+	}
+	return delta
+}
+
+// sizeHistogram counts sizes in the buckets of sizeBucketBounds without
+// locking.
+type sizeHistogram struct {
+	counts [len(sizeBucketBounds) + 1]atomic.Int64
+	count  atomic.Int64
+	sum    atomic.Int64
+}
+
+func (h *sizeHistogram) record(size int) {
+	i := sort.Search(len(sizeBucketBounds), func(i int) bool {
+		return int64(size) <= sizeBucketBounds[i]
+	})
+	h.counts[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(size))
+}
+
+func (h *sizeHistogram) snapshot() SizeHistogram {
+	snapshot := SizeHistogram{
+		Bounds: sizeBucketBounds[:],
+		Counts: make([]int64, len(h.counts)),
+		Count:  h.count.Load(),
+		Sum:    h.sum.Load(),
+	}
+	for i := range h.counts {
+		snapshot.Counts[i] = h.counts[i].Load()
+	}
+	return snapshot
+}
+
+// stageLatencies holds the latency histograms of the request stages by
+// opcode.
+type stageLatencies struct {
+	// primitive.OpCode -> *[numRequestStages]latencyHistogram
+	byOpCode sync.Map
+}
+
+func (s *stageLatencies) record(
+	opCode primitive.OpCode,
+	stage requestStage,
+	d time.Duration,
+) {
+	histograms, ok := s.byOpCode.Load(opCode)
+	if !ok {
+		histograms, _ = s.byOpCode.LoadOrStore(
+			opCode,
+			new([numRequestStages]latencyHistogram),
+		)
+	}
+	histograms.(*[numRequestStages]latencyHistogram)[stage].record(d)
+}
+
+// snapshot returns the histograms of the stages recorded by opcode.
+func (s *stageLatencies) snapshot() map[string]map[string]LatencyHistogram {
+	snapshot := make(map[string]map[string]LatencyHistogram)
+	s.byOpCode.Range(func(key, value any) bool {
+		histograms := value.(*[numRequestStages]latencyHistogram)
+		stages := make(map[string]LatencyHistogram)
+		for stage := range histograms {
+			if histograms[stage].count.Load() > 0 {
+				stages[requestStage(stage).String()] = histograms[stage].snapshot()
+			}
+		}
+		snapshot[key.(primitive.OpCode).String()] = stages
+		return true
+	})
+	return snapshot
+}
@@ -20,12 +20,98 @@ limitations under the License.
 package adapter
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-// TODO: Add unit tests to verify metric population affter integration with
-// AdaptMessage is done.
+// newTestMetricsTracerFactory returns a tracer factory recording to reader.
+func newTestMetricsTracerFactory(
+	t *testing.T,
+	reader sdkmetric.Reader,
+) *builtinMetricsTracerFactory {
+	tf := &builtinMetricsTracerFactory{
+		enabled:  true,
+		shutdown: func(ctx context.Context) {},
+	}
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	require.NoError(t, tf.createInstruments(provider.Meter(builtInMetricsMeterName)))
+	return tf
+}
+
+// collectCounts returns the values of a counter by status label.
+func collectCounts(
+	t *testing.T,
+	reader *sdkmetric.ManualReader,
+	name string,
+) map[string]int64 {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != nativeMetricsPrefix+name {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				method, _ := dp.Attributes.Value(attribute.Key(metricLabelKeyMethod))
+				assert.Equal(t, "Adapter.AdaptMessage", method.AsString())
+				status, _ := dp.Attributes.Value(attribute.Key(metricLabelKeyStatus))
+				counts[status.AsString()] += dp.Value
+			}
+		}
+	}
+	return counts
+}
+
+func TestRecordCompletion(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	tf := newTestMetricsTracerFactory(t, reader)
+
+	mt := tf.createBuiltinMetricsTracer(context.Background())
+	mt.method = adaptMessageMethod
+	mt.startAttempt()
+	recordAttemptCompletion(&mt, status.Error(codes.Unavailable, "unavailable"))
+	mt.startAttempt()
+	recordCompletion(&mt, nil)
+	// The completion of an attempt is only recorded once.
+	recordAttemptCompletion(&mt, nil)
+
+	failed := tf.createBuiltinMetricsTracer(context.Background())
+	failed.method = adaptMessageMethod
+	failed.startAttempt()
+	recordCompletion(&failed, errors.New("failed"))
+
+	assert.Equal(
+		t,
+		map[string]int64{"OK": 1, "Unavailable": 1, "Unknown": 1},
+		collectCounts(t, reader, metricNameAttemptCount),
+	)
+	assert.Equal(
+		t,
+		map[string]int64{"OK": 1, "Unknown": 1},
+		collectCounts(t, reader, metricNameOperationCount),
+	)
+	assert.Equal(t, int64(2), mt.currOp.attemptCount)
+}
+
+func TestRecordCompletionDisabled(t *testing.T) {
+	var tf *builtinMetricsTracerFactory
+	mt := tf.createBuiltinMetricsTracer(context.Background())
+	mt.startAttempt()
+	recordAttemptCompletion(&mt, nil)
+	recordCompletion(&mt, nil)
+	recordCompletion(nil, nil)
+}
 
 // TestGenerateClientHash tests the generateClientHash function.
 func TestGenerateClientHash(t *testing.T) {
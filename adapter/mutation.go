@@ -0,0 +1,162 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const mutationUnsupported = "Mutations are only supported for INSERT statements without IF NOT EXISTS, USING or JSON clauses"
+
+// simpleInsertTable returns the table of an INSERT statement that can be
+// applied as a Spanner mutation: one with a column list and a VALUES clause,
+// and without IF NOT EXISTS, USING or JSON clauses.
+func simpleInsertTable(query string) (string, bool) {
+	kind, table, ok := parseDMLTarget(query)
+	if !ok || kind != "insert" {
+		return "", false
+	}
+	values := false
+	for _, token := range tokenizeCQL(query) {
+		switch {
+		case token.is("values"):
+			values = true
+		case token.is("if"), token.is("using"), token.is("json"):
+			return "", false
+		}
+	}
+	if !values {
+		return "", false
+	}
+	return table, true
+}
+
+// mutationStatements remembers the tables of prepared query ids whose
+// statements can be applied as Spanner mutations.
+type mutationStatements struct {
+	cache *lru.Cache
+}
+
+func newMutationStatements(size int) (*mutationStatements, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &mutationStatements{cache: cache}, nil
+}
+
+func (ms *mutationStatements) remember(id []byte, table string) {
+	if ms != nil {
+		ms.cache.Add(string(id), table)
+	}
+}
+
+func (ms *mutationStatements) lookup(id []byte) (string, bool) {
+	if ms == nil {
+		return "", false
+	}
+	table, ok := ms.cache.Get(string(id))
+	if !ok {
+		return "", false
+	}
+	return table.(string), true
+}
+
+// rememberMutationStatement records the table of the prepared query id
+// returned by the server for req, if its statement can be applied as a
+// mutation.
+func (dc *driverConnection) rememberMutationStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	if dc.executor.mutations == nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	table, ok := simpleInsertTable(prepare.Query)
+	if !ok {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		dc.executor.mutations.remember(id, table)
+	}
+}
+
+// mutationTables returns the tables of the statements of a QUERY, EXECUTE or
+// BATCH request, if all of them can be applied as mutations.
+func (re *requestExecutor) mutationTables(frm *frame.Frame) ([]string, bool) {
+	var tables []string
+	add := func(query string, id []byte) bool {
+		var table string
+		var ok bool
+		if query != "" {
+			table, ok = simpleInsertTable(query)
+		} else {
+			table, ok = re.mutations.lookup(id)
+		}
+		tables = append(tables, table)
+		return ok
+	}
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		return tables, add(msg.Query, nil)
+	case *message.Execute:
+		return tables, add("", msg.QueryId)
+	case *message.Batch:
+		for _, child := range msg.Children {
+			if !add(child.Query, child.Id) {
+				return nil, false
+			}
+		}
+		return tables, len(tables) > 0
+	}
+	return nil, false
+}
+
+// tryInsertMutation marks requests whose statements are all simple INSERT
+// statements to be applied as Spanner mutations. A request qualifies if the
+// driver requested it through a custom payload, or if all its statements
+// insert into tables configured in Options.EnableMutationsFor.
+func (re *requestExecutor) tryInsertMutation(
+	frame *frame.Frame, attachments map[string]string,
+) message.Message {
+	requested := len(frame.Body.CustomPayload[MutationPayloadKey]) > 0
+	if !requested && len(re.opts.EnableMutationsFor) == 0 {
+		return nil
+	}
+	tables, ok := re.mutationTables(frame)
+	if !ok {
+		if requested {
+			return &message.Invalid{ErrorMessage: mutationUnsupported}
+		}
+		return nil
+	}
+	if !requested {
+		for _, table := range tables {
+			if !matchesTable(re.opts.EnableMutationsFor, table) {
+				return nil
+			}
+		}
+	}
+	attachments[insertMutation] = "true"
+	return nil
+}
@@ -0,0 +1,149 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleInsertTable(t *testing.T) {
+	table, ok := simpleInsertTable("INSERT INTO ks.events (id, a) VALUES (?, ?)")
+	assert.True(t, ok)
+	assert.Equal(t, "ks.events", table)
+
+	for _, query := range []string{
+		"INSERT INTO ks.events (id) VALUES (1) IF NOT EXISTS",
+		"INSERT INTO ks.events (id) VALUES (1) USING TTL 10",
+		"INSERT INTO ks.events JSON '{\"id\": 1}'",
+		"UPDATE ks.events SET a = 1 WHERE id = 1",
+		"SELECT * FROM ks.events",
+	} {
+		_, ok := simpleInsertTable(query)
+		assert.False(t, ok, query)
+	}
+}
+
+func TestPrepareMutationAttachments(t *testing.T) {
+	mutations, err := newMutationStatements(10)
+	require.NoError(t, err)
+	mutations.remember([]byte("insert"), "ks.events")
+	re := &requestExecutor{
+		opts:      &Options{EnableMutationsFor: []string{"events"}},
+		mutations: mutations,
+	}
+	hint := map[string][]byte{MutationPayloadKey: []byte("true")}
+	newFrame := func(msg message.Message, payload map[string][]byte) *frame.Frame {
+		return &frame.Frame{
+			Header: &frame.Header{
+				Version: primitive.ProtocolVersion4,
+				OpCode:  msg.GetOpCode(),
+			},
+			Body: &frame.Body{Message: msg, CustomPayload: payload},
+		}
+	}
+	batch := func(children ...*message.BatchChild) *message.Batch {
+		return &message.Batch{Type: primitive.BatchTypeLogged, Children: children}
+	}
+
+	testCases := []struct {
+		name         string
+		frame        *frame.Frame
+		wantMutation bool
+		wantErr      bool
+	}{
+		{
+			name:         "Configured table",
+			frame:        newFrame(&message.Query{Query: "INSERT INTO ks.events (id) VALUES (1)"}, nil),
+			wantMutation: true,
+		},
+		{
+			name:  "Conditional insert into configured table",
+			frame: newFrame(&message.Query{Query: "INSERT INTO ks.events (id) VALUES (1) IF NOT EXISTS"}, nil),
+		},
+		{
+			name:  "Other table",
+			frame: newFrame(&message.Query{Query: "INSERT INTO ks.users (id) VALUES (1)"}, nil),
+		},
+		{
+			name:         "Hinted query",
+			frame:        newFrame(&message.Query{Query: "INSERT INTO ks.users (id) VALUES (1)"}, hint),
+			wantMutation: true,
+		},
+		{
+			name:    "Hinted update",
+			frame:   newFrame(&message.Query{Query: "UPDATE ks.users SET a = 1 WHERE id = 1"}, hint),
+			wantErr: true,
+		},
+		{
+			name:         "Prepared insert",
+			frame:        newFrame(&message.Execute{QueryId: []byte("insert")}, nil),
+			wantMutation: true,
+		},
+		{
+			name:    "Hinted unknown prepared statement",
+			frame:   newFrame(&message.Execute{QueryId: []byte("update")}, hint),
+			wantErr: true,
+		},
+		{
+			name: "Batch of inserts",
+			frame: newFrame(batch(
+				&message.BatchChild{Query: "INSERT INTO events (id) VALUES (1)"},
+				&message.BatchChild{Query: "INSERT INTO ks.events (id) VALUES (2)"},
+			), nil),
+			wantMutation: true,
+		},
+		{
+			name: "Batch with an update",
+			frame: newFrame(batch(
+				&message.BatchChild{Query: "INSERT INTO events (id) VALUES (1)"},
+				&message.BatchChild{Query: "UPDATE events SET a = 1 WHERE id = 2"},
+			), nil),
+		},
+		{
+			name: "Hinted batch with an update",
+			frame: newFrame(batch(
+				&message.BatchChild{Query: "INSERT INTO events (id) VALUES (1)"},
+				&message.BatchChild{Query: "UPDATE events SET a = 1 WHERE id = 2"},
+			), hint),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attachments := make(map[string]string)
+			errMsg := re.tryInsertMutation(tc.frame, attachments)
+			if tc.wantErr {
+				if _, ok := errMsg.(*message.Invalid); !ok {
+					t.Fatalf("got %v, want Invalid error", errMsg)
+				}
+				return
+			}
+			assert.Nil(t, errMsg)
+			_, gotMutation := attachments[insertMutation]
+			assert.Equal(t, tc.wantMutation, gotMutation)
+		})
+	}
+}
@@ -16,7 +16,14 @@ limitations under the License.
 
 package adapter
 
-import "google.golang.org/api/option"
+import (
+	"net"
+	"time"
+
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+)
 
 // Options for configuring the adapter.
 type Options struct {
@@ -24,17 +31,481 @@ type Options struct {
 	DatabaseUri string
 	// Optional Spanner service endpoint. Defaults to spanner.googleapis.com:443
 	SpannerEndpoint string
+	// Optional Spanner endpoints (ie: regional endpoints) in order of
+	// preference, which the grpc channels fail over to once every channel keeps
+	// failing on SpannerEndpoint with transport errors. The channels fail back
+	// to the preferred endpoints once they pass health checks again. Defaults
+	// to empty.
+	FailoverEndpoints []string
+	// Optional Spanner endpoints (ie: regional endpoints) probed at startup
+	// along with SpannerEndpoint: the proxy connects to the healthy endpoint
+	// with the lowest connection latency, and logs the latency of each
+	// endpoint. Set to SpannerEndpoint alone to only validate it. Defaults to
+	// empty, which skips the probe.
+	CandidateEndpoints []string
+	// Optional Spanner databases of keyspace qualified tables (ie:
+	// "keyspace.table"), in the format of DatabaseUri, to serve tables that
+	// moved to another database of the same endpoint. Statements on other
+	// tables are served by DatabaseUri. Batches and transactions must only
+	// refer to tables of a single database. Defaults to empty.
+	TableRouting map[string]string
 	// Protocol type (ie: cassandra).
 	Protocol Protocol
+	// Optional name of a protocol registered with RegisterProtocol, used if
+	// Protocol is nil.
+	ProtocolName string
 	// Number of channels when dial grpc connection. Defaults to 4.
 	NumGrpcChannels int
+	// Optional lower bound of the number of grpc channels when autoscaling is
+	// enabled. Defaults to 1.
+	MinGrpcChannels int
+	// Optional upper bound of the number of grpc channels. If set, the pool
+	// starts with NumGrpcChannels channels and grows or shrinks with the number
+	// of in-flight requests within [MinGrpcChannels, MaxGrpcChannels]. Defaults
+	// to 0, which keeps the pool at NumGrpcChannels.
+	MaxGrpcChannels int
+	// Optional number of grpc channels of a pool dedicated to the reads, so
+	// that large scans don't delay the writes. Requests routed to the leader
+	// (DML, serial reads) are writes. Defaults to 0, which sends the reads on
+	// the shared pool.
+	ReadChannels int
+	// Optional number of grpc channels of a pool dedicated to the writes.
+	// Defaults to 0, which sends the writes on the shared pool.
+	WriteChannels int
+	// Optional Spanner endpoint of the pool of ReadChannels. Defaults to
+	// SpannerEndpoint.
+	ReadEndpoint string
+	// Optional Spanner endpoint of the pool of WriteChannels. Defaults to
+	// SpannerEndpoint.
+	WriteEndpoint string
+	// Optional number of consecutive transport failures (ie: UNAVAILABLE,
+	// DEADLINE_EXCEEDED, RST_STREAM) after which a grpc channel is re-dialed.
+	// Defaults to 5. A negative value disables channel rotation.
+	UnhealthyChannelThreshold int
+	// Optional boolean to send all the requests of a driver connection on the
+	// same grpc channel, falling back to round-robin while the channel fails
+	// or for retries. Defaults to false (round-robin).
+	ChannelAffinity bool
+	// Optional interval of the keepalive pings sent on the grpc channels, so
+	// that connections silently dropped by NATs or load balancers are detected
+	// before requests are sent on them. Values under 10s are raised to 10s.
+	// Defaults to 0 (no keepalive pings).
+	GrpcKeepaliveTime time.Duration
+	// Optional time to wait for the acknowledgement of a keepalive ping
+	// before closing the grpc connection. Defaults to 20s.
+	GrpcKeepaliveTimeout time.Duration
+	// Optional minimum time given to the grpc channels to connect to Spanner.
+	// Defaults to 20s.
+	GrpcMinConnectTimeout time.Duration
+	// Optional network the grpc channels connect to Spanner on: "tcp4" or
+	// "tcp6" to only connect to the IPv4 or IPv6 addresses of the Spanner
+	// endpoint, ie: on dual-stack hosts without a route for one of them.
+	// Defaults to "tcp" (both).
+	GrpcDialNetwork string
+	// Optional compression of the messages of the gRPC calls to Spanner, "none"
+	// or "gzip", ie: for bandwidth-constrained links to Google Cloud. The bytes
+	// sent and received before and after compression are reported by Stats.
+	// Defaults to "none".
+	GrpcCompression string
+	// Optional number of times the statements of an explicit transaction are
+	// sent again when Spanner aborts the transaction on COMMIT. Defaults to
+	// 10. A negative value disables replays.
+	MaxTransactionRetries int
 	// Optional Endpoint to start TCP server. Defaults to localhost:9042
 	TCPEndpoint string
+	// Optional inclusive range of fallback ports (ie: "9043-9050") tried on the
+	// host of TCPEndpoint if TCPEndpoint is in use. Defaults to empty.
+	TCPPortRange string
+	// Optional boolean indicate whether to listen on an ephemeral port if
+	// TCPEndpoint and TCPPortRange are in use. Defaults to false.
+	FallbackToEphemeralPort bool
+	// Optional network of the listeners of the proxy: "tcp4" or "tcp6" to only
+	// listen on IPv4 or IPv6 addresses, ie: on dual-stack hosts where the host
+	// of TCPEndpoint resolves to both. Defaults to "tcp" (both).
+	ListenNetwork string
+	// Optional name of a network interface (ie: "eth0") whose address the
+	// listener of TCPEndpoint binds to, in place of the host of TCPEndpoint.
+	// The first address of the interface matching ListenNetwork is used.
+	// Defaults to empty (the host of TCPEndpoint).
+	ListenInterface string
+	// Optional boolean indicate whether to listen with SO_REUSEPORT, so that
+	// another proxy process, ie: the new version of the proxy during an in
+	// place upgrade, can listen on the same endpoints while this one still
+	// serves its connections. Only supported on Linux and macOS. Defaults to
+	// false.
+	ReusePort bool
+	// Optional listener accepting the driver connections in place of a
+	// listener on TCPEndpoint, ie: a listener inherited with systemd socket
+	// activation. The proxy closes it on Close. Defaults to nil.
+	Listener net.Listener
+	// Optional number of listeners opened on TCPEndpoint with SO_REUSEPORT,
+	// each with its own accept loop, so that the kernel spreads the
+	// connections over them, ie: on large machines serving thousands of
+	// driver connections. Can not be set with Listener. Defaults to 1.
+	ListenerShards int
+	// Optional number of consecutive failures to refresh the Spanner session
+	// after which the proxy stops accepting new connections. Defaults to 0
+	// (never stop accepting connections).
+	MaxSessionFailures int
+	// Optional maximum number of open driver connections. Connections accepted
+	// over the limit are answered with an Overloaded error and closed.
+	// Defaults to 0 (unlimited).
+	MaxConnections int
+	// Optional time given to driver connections to complete their handshake:
+	// STARTUP answered with READY, or authentication answered with
+	// AUTH_SUCCESS. Connections that do not complete the handshake in time are
+	// closed and counted in Stats.HandshakeTimeouts. Only applies to the
+	// Cassandra protocol. Defaults to 0 (no timeout).
+	HandshakeTimeout time.Duration
+	// Optional maximum number of driver connections accepted per second, ie:
+	// to smooth the connection storms of a fleet of applications restarting
+	// at once. Connections over the rate wait in the accept queue of the
+	// listener and are counted in Stats.PacedConnections. Defaults to 0
+	// (unlimited).
+	AcceptRate float64
+	// Optional number of connections accepted without delay after the proxy
+	// was idle, with AcceptRate. Defaults to one second of AcceptRate.
+	AcceptBurst int
+	// Optional maximum number of driver connections completing their
+	// handshake concurrently. Connections wait for a slot before their first
+	// request is read, and release it once their handshake completes or they
+	// are closed. Defaults to 0 (unlimited).
+	MaxConcurrentHandshakes int
+	// Optional boolean to read the PROXY protocol v2 header sent by L4 load
+	// balancers (ie: HAProxy, NLB) at the start of driver connections, and to
+	// report the address of the client it carries instead of the address of
+	// the load balancer. Connections without header are closed. Defaults to
+	// false.
+	AcceptProxyProtocol bool
+	// Optional maximum body length in bytes of the Cassandra frames sent by
+	// drivers. Connections sending larger frames are answered with a protocol
+	// error and closed. Defaults to 256MiB.
+	MaxFrameSize int
+	// Optional maximum size in bytes of the requests sent to Spanner, which
+	// also sets the maximum size of the gRPC messages sent. Larger requests,
+	// ie: writes of large blobs, are answered with an Invalid error naming
+	// their size and the limit, without being sent. Defaults to 100MiB, the
+	// maximum commit size of Spanner.
+	MaxRequestSize int
+	// Optional maximum size in bytes of the response to a request buffered by
+	// a connection before it is written to the driver, which bounds the memory
+	// used by each connection. Responses are buffered as Spanner sends the
+	// length of the frame in its last chunk; larger responses, ie: unpaged
+	// reads of large tables, are abandoned and answered with an Invalid error
+	// naming the limit. Defaults to 256MiB.
+	MaxResponseSize int
+	// Optional function called once the proxy stopped accepting connections
+	// after MaxSessionFailures consecutive session failures, ie: to terminate
+	// the process. With DrainTimeout, it is called once the open connections
+	// are closed, with an ErrDrainTimeout error if some were still open after
+	// DrainTimeout. Defaults to nil.
+	OnDrain func(err error)
+	// Optional time given to the open connections to close once the proxy
+	// stopped accepting connections after MaxSessionFailures session failures.
+	// Connections still open after it are closed. Defaults to 0 (connections
+	// are left open and OnDrain is called right away).
+	DrainTimeout time.Duration
+	// Optional interval at which the driver connections waiting on Spanner or
+	// on their driver for longer than StuckConnectionThreshold are logged at
+	// warning level, ie: to diagnose connection leaks of long running
+	// sidecars. TCPProxy.ConnectionReport returns the same report on demand,
+	// with the goroutine stacks. Defaults to 0 (no periodic report).
+	ConnectionReportInterval time.Duration
+	// Optional time after which a connection waiting on Spanner or on its
+	// driver is reported as stuck. Defaults to 1m.
+	StuckConnectionThreshold time.Duration
+	// Optional interval at which recommendations to tune the proxy for the
+	// sizes of the requests and responses of the interval are logged, ie: to
+	// page large reads or to enable GrpcCompression. The sizes are reported
+	// by Stats.RequestSizes and Stats.ResponseSizes. Defaults to 0 (no
+	// recommendations).
+	PayloadAdvisorInterval time.Duration
+	// Optional boolean to adapt the number of requests sent concurrently to
+	// Spanner to its latencies: the limit shrinks as latencies rise above
+	// their long term average, and grows while they stay close to it.
+	// Requests over the limit are answered with Overloaded errors, and
+	// counted in Stats.ConcurrencyLimited. Defaults to false.
+	AdaptiveConcurrency bool
+	// Optional maximum limit of the requests sent concurrently to Spanner
+	// with AdaptiveConcurrency. Defaults to 1000.
+	MaxConcurrencyLimit int
+	// Optional maximum time the errors of the requests Spanner rejected as
+	// overloaded are delayed by, following the retry delay suggested by
+	// Spanner, so that the retry policies of drivers back off. The delay is
+	// also reported in the spanner_retry_after custom payload and in a
+	// warning of the errors. Defaults to 0 (errors are not delayed).
+	MaxOverloadErrorDelay time.Duration
 	// Optional boolean indicate whether to disable automatic grpc retry for
 	// AdaptMessage API. Defauls to false.
 	DisableAdaptMessageRetry bool
+	// Optional backoff between the retries of AdaptMessage calls. Every retry
+	// waits a random delay between zero and the current backoff (full jitter),
+	// unless Spanner returned a retry delay. Defaults to DefaultRetryBackoff.
+	RetryBackoff gax.Backoff
+	// Optional fraction of the retry delays returned by Spanner added as
+	// random jitter, so that the requests rejected together by an overloaded
+	// Spanner are not retried together. Defaults to 0.
+	RetryJitter float64
+	// Optional number of retries each driver connection earns per request,
+	// ie: 0.1 allows one retry every ten requests. Once a connection spent its
+	// budget, failed requests are returned to the driver instead of being
+	// retried, so that retries can't amplify a Spanner brownout. Defaults to 0
+	// (unlimited retries).
+	RetryBudgetRatio float64
+	// Optional number of retries a driver connection can make beyond
+	// RetryBudgetRatio, ie: right after connecting. Defaults to 10.
+	RetryBudgetBurst int
+	// Optional maximum number of entries (prepared query ids, cached prepared
+	// results and warnings of prepared statements) kept in the global state
+	// cache. Defaults to 390625.
+	PreparedCacheSize int
+	// Optional store of the global state (prepared query ids and cached
+	// prepared results) in place of the default LRU cache of PreparedCacheSize
+	// entries, ie: an instrumented or size-aware implementation. Defaults to
+	// nil (default cache).
+	StateStore GlobalState
+	// Optional address (host:port) of a Redis or Memorystore instance the
+	// global state is shared through, so that prepared query ids are known to
+	// every proxy of the database and survive restarts. The local cache is
+	// still used first, and entries stay available locally when Redis can not
+	// be reached. Defaults to empty (not shared).
+	StateRedisAddress string
+	// Optional password of the Redis instance of StateRedisAddress (Redis AUTH).
+	// Defaults to empty.
+	StateRedisPassword string
+	// Optional boolean indicate whether to disable answering repeated PREPARE
+	// requests from the local prepared result cache. Defaults to false.
+	DisablePreparedResultCache bool
+	// Optional boolean indicate whether to return the elements of sets and the
+	// entries of maps in the order Spanner stores them, instead of ordering
+	// them as Cassandra does and removing duplicate set elements. Defaults to
+	// false.
+	DisableCollectionOrdering bool
+	// Optional boolean indicate whether to disable validating the number of
+	// values bound by requests, and the size of the values bound to fixed size
+	// types, against the bind variables of their prepared statements before
+	// sending them. Invalid requests are answered with an Invalid error naming
+	// the column of the invalid value. Defaults to false.
+	DisableBindValidation bool
+	// Optional boolean indicate whether to prepare again the unknown prepared
+	// statements of a batch (ie: evicted from the global state cache) before
+	// executing it, instead of rejecting the batch with an Unprepared error.
+	// Only statements prepared through this proxy can be prepared again.
+	// Defaults to false.
+	EnableBatchReprepare bool
+	// Optional boolean indicate whether to reject statements whose consistency
+	// level is not valid for them (ie: ANY or EACH_QUORUM reads, SERIAL
+	// writes) instead of logging them. Spanner serves all other levels with
+	// strong consistency. Defaults to false.
+	StrictConsistency bool
+	// Optional boolean to reject statements with a USING TIMESTAMP clause
+	// instead of logging them. Spanner orders writes by their commit
+	// timestamp and does not apply client supplied timestamps. Defaults to
+	// false.
+	StrictWriteTimestamps bool
+	// Optional staleness of reads sent with the ONE or LOCAL_ONE consistency
+	// levels. Such reads are served with strong consistency if zero. Defaults
+	// to 0.
+	WeakConsistencyStaleness time.Duration
+	// Optional boolean to make the exact staleness reads of a connection,
+	// either from WeakConsistencyStaleness or from ExactStalenessPayloadKey
+	// custom payloads, observe the previous writes of the connection.
+	// Defaults to false.
+	ReadYourWrites bool
+	// Optional boolean to add the latency of each request in the proxy and in
+	// Spanner to the custom payload of its response, under the
+	// RequestLatencyPayloadKey and BackendLatencyPayloadKey keys. Requires
+	// protocol v4 or later. Defaults to false.
+	EnableLatencyPayload bool
+	// Optional boolean to add the number of rows affected by each DML
+	// statement, or by all statements of a batch, to the custom payload of
+	// its response under the RowCountPayloadKey key. Requires protocol v4 or
+	// later. Defaults to false.
+	EnableRowCountPayload bool
+	// Optional boolean to export the latencies and counts of the operations
+	// and attempts of AdaptMessage calls to Cloud Monitoring, as the built-in
+	// client-side metrics of the Spanner client libraries. Defaults to false.
+	EnableBuiltInMetrics bool
+	// Optional boolean to count the executions, errors and latencies of the
+	// statements by fingerprint, the statement with its literals and bind
+	// markers replaced by ?, reported by TCPProxy.TopStatements. Defaults to
+	// false.
+	EnableStatementMetrics bool
+	// Optional maximum number of fingerprints tracked with
+	// EnableStatementMetrics. The statements of other fingerprints are counted
+	// under the "<other>" fingerprint. Defaults to 1000.
+	MaxStatementFingerprints int
 	// The maximum delay in milliseconds. Default is 0 (disabled).
 	MaxCommitDelay int
+	// Optional list of tables (ie: "keyspace.table" or "table") whose UPDATE and
+	// DELETE query statements are executed as Partitioned DML. Defaults to
+	// empty.
+	EnablePartitionedDMLFor []string
+	// Optional list of tables (ie: "keyspace.table" or "table") whose simple
+	// INSERT statements, without IF NOT EXISTS, USING or JSON clauses, are
+	// applied as Spanner mutations instead of DML. Mutations blindly insert or
+	// update rows. Defaults to empty.
+	EnableMutationsFor []string
+	// Optional policy restricting the statements served by the proxy, ie: to
+	// serve read-only traffic during a migration freeze. Defaults to nil (all
+	// statements are served).
+	StatementPolicy *StatementPolicy
+	// Optional attachment keys defined by the proxy that the Adapter backend
+	// honors, among read_timestamp, exact_staleness, partitioned_dml,
+	// insert_mutation, request_priority and directed_read_options. Requests
+	// relying on other keys, ie: sent with ReadTimestampPayloadKey, fail with
+	// an Invalid error, and options relying on them fail NewTCPProxy, rather
+	// than being served as if the key was not set. Defaults to empty.
+	SupportedAttachments []string
+	// Optional function computing custom attachments of QUERY, EXECUTE and
+	// BATCH requests, ie: request priorities or tags. Attachments set by the
+	// proxy itself are reserved and fail the request. Defaults to nil.
+	AttachmentProvider AttachmentProvider
+	// Optional policy for SELECT statements scanning a whole table (without a
+	// WHERE clause, with ALLOW FILTERING or with a token range restriction):
+	// FullScanAllow, FullScanLowPriority, FullScanWarn or FullScanReject.
+	// Defaults to FullScanAllow.
+	FullScanPolicy FullScanPolicy
+	// Optional replicas the reads are served by (include or exclude replica
+	// locations and types, and whether to fail over to other replicas), ie: to
+	// pin the analytics reads of multi-region databases to read-only replicas.
+	// Applied to the SELECT requests, prepared or not, except SERIAL reads,
+	// while DML statements are always served by the leader. Defaults to nil
+	// (replicas chosen by Spanner).
+	DirectedReadOptions *spannerpb.DirectedReadOptions
+	// Optional time to live of the cached responses of SELECT statements on
+	// tables (ie: "keyspace.table" or "table"), for small and hot lookup
+	// tables. Requests identical to a previous one, including their bound
+	// values, are answered locally for up to the time to live. Writes through
+	// the proxy drop the cached responses of their table. Defaults to empty
+	// (no caching).
+	ReadCacheTTLs map[string]time.Duration
+	// Optional maximum number of cached responses per table of ReadCacheTTLs.
+	// Defaults to 1000.
+	ReadCacheSize int
+	// Optional expiration columns of tables (ie: "keyspace.table" or "table"),
+	// which USING TTL clauses of INSERT and UPDATE statements are translated
+	// to. The columns must be TIMESTAMP columns used by a row deletion policy.
+	// Statements setting a TTL on other tables are rejected. Defaults to empty.
+	TTLColumns map[string]string
+	// Optional CQL types of the tuple and user-defined type columns stored as
+	// JSON, by column (ie: "keyspace.table.column" or "table.column"), ie:
+	// "frozen<tuple<int, text>>" or "frozen<address>". Tuples are stored as
+	// JSON arrays and UDTs as JSON objects keyed by field name. Defaults to
+	// empty.
+	JSONColumns map[string]string
+	// Optional fields of the user-defined types of JSONColumns, by type (ie:
+	// "keyspace.address" or "address"), as a comma separated list of field
+	// names and types, ie: "street text, zip int". Defaults to empty.
+	UserTypes map[string]string
+	// Optional codecs registered with RegisterColumnCodec transforming the
+	// values of columns (ie: "keyspace.table.column" or "table.column"), by
+	// column. Values bound to prepared statements are encoded before they are
+	// sent to Spanner and values read are decoded before they are returned.
+	// Unprepared statements writing the tables of these columns are rejected.
+	// Defaults to empty.
+	ColumnCodecs map[string]string
+	// Optional file a sample of the QUERY, EXECUTE and BATCH requests is
+	// appended to, with their statements and bound values, to replay them
+	// against another database with cmd/replay. Defaults to empty (disabled).
+	CaptureFile string
+	// Optional fraction of the requests recorded to CaptureFile, between 0 and
+	// 1. Defaults to 0.01.
+	CaptureSampleRate float64
+	// Optional boolean to evaluate calls of now(), uuid(), currentTimestamp()
+	// and similar functions in the proxy, and to rewrite writetime() calls to
+	// read the columns of WriteTimeColumns. Defaults to false.
+	EmulateFunctions bool
+	// Optional boolean to serve queries on the tables of the cdc keyspace from
+	// the Spanner change streams of the same name:
+	// SELECT * FROM cdc.<stream> WHERE start_time = <timestamp>
+	// [AND end_time = <timestamp>] returns a row per row modification
+	// committed between start_time and end_time, or now. Defaults to false.
+	EnableChangeStreams bool
+	// Optional maximum time spent reading a page of a change stream query with
+	// EnableChangeStreams, which should not exceed the request timeout of the
+	// drivers. Defaults to 10s.
+	ChangeStreamReadTimeout time.Duration
+	// Optional commit timestamp columns of tables (ie: "keyspace.table" or
+	// "table") read by writetime() calls with EmulateFunctions. The columns
+	// must be TIMESTAMP columns set to the commit timestamp of every write.
+	// writetime() calls on other tables are rejected. Defaults to empty.
+	WriteTimeColumns map[string]string
+	// Optional boolean to emulate the Cassandra behaviors cqlsh relies on:
+	// the proxy pages SELECT results itself, answers the system_schema tables
+	// of the objects Spanner does not have (functions, aggregates, triggers,
+	// views and dropped columns) with no rows, and reports a Cassandra 3.11
+	// release version without tokens in system.local and system.peers, so
+	// that cqlsh describes schemas and exports tables from the driver
+	// metadata. Defaults to false.
+	CqlshCompatibility bool
+	// Optional page size the proxy reads the results of unpaged SELECT
+	// requests (page size 0) with from Spanner, ie: full scans of drivers
+	// with paging disabled. The proxy reads the pages one after the other and
+	// answers the driver with a single result holding all of their rows, as
+	// requested. Defaults to 0 (unpaged reads are sent unpaged).
+	UnpagedReadPageSize int
+	// Optional maximum number of statements of the UNLOGGED batches of plain
+	// INSERT statements sent to Spanner in a single request. Larger batches,
+	// ie: those of cqlsh COPY FROM with a large MAXBATCHSIZE, are split into
+	// batches of at most MaxBatchStatements statements sent concurrently, as
+	// Cassandra does not apply unlogged batches atomically. Only applies while
+	// UnloggedBatchAtomicity is BatchAtomic. Defaults to 0 (batches are not
+	// split).
+	MaxBatchStatements int
+	// Optional maximum number of parts of a batch split with
+	// MaxBatchStatements sent concurrently. Defaults to 4.
+	BatchSplitParallelism int
+	// Optional atomicity of LOGGED batches: BatchAtomic,
+	// BatchPerPartition or BatchSinglePartition. The partitions of the
+	// statements of a batch are those of their prepared statements, other
+	// statements being applied together. Defaults to BatchAtomic, Spanner
+	// applying the batch in a single transaction.
+	LoggedBatchAtomicity BatchAtomicity
+	// Optional atomicity of UNLOGGED batches, as LoggedBatchAtomicity.
+	// Cassandra only applies them atomically within a partition. Defaults to
+	// BatchAtomic.
+	UnloggedBatchAtomicity BatchAtomicity
+	// Optional number of idempotency tokens of batches, sent with
+	// IdempotencyTokenPayloadKey, remembered by the proxy. Replays of a batch
+	// in flight wait for it to complete, and replays of an applied batch are
+	// answered with its response rather than applied again, ie: after the
+	// response was lost to a network failure. Tokens are local to the proxy.
+	// Defaults to 0, which ignores the tokens.
+	BatchDedupCacheSize int
+	// Optional time the responses of the batches of idempotency tokens are
+	// replayed for. Defaults to 10m.
+	BatchDedupWindow time.Duration
+	// Optional cluster name, data center, rack, host id and release version
+	// reported in system.local and system.peers in place of those of
+	// Spanner, ie: for the DC-aware load balancing policies of applications.
+	// Defaults to nil (the values of Spanner are reported).
+	TopologyOverride *TopologyOverride
+	// Optional number of virtual peers reported in system.peers and
+	// system.peers_v2, so that drivers sizing their connection pools per host
+	// (ie: gocql NumConns) open more connections to the proxy. Drivers must
+	// translate the addresses of the peers, 127.0.1.1 and onwards, to the
+	// address of the proxy, as the gocql clusters of the proxy do. At most
+	// 254. Defaults to 0 (the peers of Spanner are reported).
+	SyntheticPeerCount int
+	// Optional additional listeners of the proxy, serving their connections
+	// with their own database or labels, ie: to front several databases from
+	// a single sidecar. Defaults to empty.
+	Listeners []ListenerConfig
+	// Optional labels (ie: end user or tenant id) forwarded as
+	// x-goog-spanner-label-<key> metadata headers with every request. Keys must
+	// be lower case. Connections can add labels through SPANNER_LABEL_<KEY>
+	// STARTUP options or SET SPANNER_LABEL <key> = '<value>' statements.
+	// Defaults to empty.
+	ConnectionLabels map[string]string
+	// Optional boolean to connect to Spanner over DirectPath, bypassing the
+	// Google front ends, when running on Google Cloud. Whether calls are
+	// actually served over DirectPath is logged, and counted in
+	// Stats.DirectPathCalls and Stats.CloudPathCalls. Can not be used with
+	// UsePlainText or ExperimentalHost. Also enabled by the
+	// GOOGLE_SPANNER_ENABLE_DIRECT_ACCESS environment variable. Defaults to
+	// false.
+	EnableDirectAccess bool
 	// Optional google api opts. Default to empty.
 	GoogleApiOpts []option.ClientOption
 	// Optional boolean indicate whether to use plain-text connection.
@@ -48,4 +519,8 @@ type Options struct {
 	ClientCertificate string
 	// Optional string client key file path for establishing mTLS connection
 	ClientKey string
+	// Optional Secret Manager secrets the credentials, TLS certificates and
+	// statement policy are read from, and periodically refreshed. Defaults to
+	// nil.
+	SecretRefs *SecretRefs
 }
@@ -0,0 +1,167 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"crypto/rand"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Maximum number of results whose remaining pages are held by the proxy. The
+// least recently paged results are dropped first.
+const maxPagedResults = 1000
+
+// pagedResults holds the remaining rows of the results paged by the proxy, by
+// paging state. Spanner returns every row of a result at once; the proxy
+// returns them to the driver a page at a time.
+type pagedResults struct {
+	cache *lru.Cache
+}
+
+// pagedResult is the remainder of a paged result.
+type pagedResult struct {
+	metadata *message.RowsMetadata
+	rows     message.RowSet
+}
+
+func newPagedResults(size int) (*pagedResults, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &pagedResults{cache: cache}, nil
+}
+
+// page truncates result to its first pageSize rows, and holds the remaining
+// ones, described by metadata, under the paging state set in its metadata.
+func (pr *pagedResults) page(
+	result *message.RowsResult,
+	metadata *message.RowsMetadata,
+	pageSize int,
+) {
+	if pageSize <= 0 || len(result.Data) <= pageSize {
+		return
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	state := append([]byte(proxyPagingStatePrefix), id...)
+	pr.cache.Add(string(state), &pagedResult{
+		metadata: metadata,
+		rows:     result.Data[pageSize:],
+	})
+	paged := &message.RowsMetadata{}
+	if result.Metadata != nil {
+		*paged = *result.Metadata
+	}
+	paged.PagingState = state
+	result.Metadata = paged
+	result.Data = result.Data[:pageSize]
+}
+
+// take returns and drops the remainder of the result of a paging state.
+func (pr *pagedResults) take(state []byte) (*pagedResult, bool) {
+	key := string(state)
+	result, ok := pr.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	pr.cache.Remove(key)
+	return result.(*pagedResult), true
+}
+
+// pagingOptions returns the query options of a QUERY or EXECUTE request.
+func pagingOptions(frm *frame.Frame) *message.QueryOptions {
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		if isCQLRead(msg.Query) {
+			return msg.Options
+		}
+	case *message.Execute:
+		return msg.Options
+	}
+	return nil
+}
+
+// tryServeNextPage answers the requests for the next page of a result paged
+// by the proxy. Returns nil for other requests.
+func (dc *driverConnection) tryServeNextPage(frm *frame.Frame) message.Message {
+	pages := dc.executor.pages
+	options := pagingOptions(frm)
+	if pages == nil || options == nil ||
+		!bytes.HasPrefix(options.PagingState, []byte(proxyPagingStatePrefix)) {
+		return nil
+	}
+	remaining, ok := pages.take(options.PagingState)
+	if !ok {
+		return &message.Invalid{
+			ErrorMessage: "Paging state expired, run the query again",
+		}
+	}
+	metadata := *remaining.metadata
+	if options.SkipMetadata {
+		metadata.Columns = nil
+	}
+	result := &message.RowsResult{Metadata: &metadata, Data: remaining.rows}
+	pages.page(result, remaining.metadata, int(options.PageSize))
+	return result
+}
+
+// tryUnpageRequest sends the paged QUERY and EXECUTE requests of req without
+// paging, the proxy paging their results itself.
+func (dc *driverConnection) tryUnpageRequest(req *requestState) message.Message {
+	if dc.executor.pages == nil {
+		return nil
+	}
+	options := pagingOptions(&req.frame)
+	if options == nil || options.PageSize <= 0 {
+		return nil
+	}
+	req.pageSize = int(options.PageSize)
+	unpaged := *options
+	unpaged.PageSize = 0
+	unpaged.PagingState = nil
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Query:
+		query := *msg
+		query.Options = &unpaged
+		return dc.replaceMessage(req, &query)
+	case *message.Execute:
+		execute := *msg
+		execute.Options = &unpaged
+		return dc.replaceMessage(req, &execute)
+	}
+	return nil
+}
+
+// pageResponse returns the first page of the rows of the encoded response of
+// a request sent by tryUnpageRequest, and holds the remaining ones.
+func (dc *driverConnection) pageResponse(req *requestState, encoded []byte) []byte {
+	if req.pageSize <= 0 {
+		return encoded
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		rows, ok := frm.Body.Message.(*message.RowsResult)
+		if !ok || rows.Metadata == nil {
+			return
+		}
+		dc.executor.pages.page(rows, rows.Metadata, req.pageSize)
+	})
+}
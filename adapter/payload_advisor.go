@@ -0,0 +1,162 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// Minimum number of responses of an interval the payload advisor bases
+	// recommendations on.
+	advisorMinResponses = 100
+	// Response size above which unpaged reads are worth paging by the proxy.
+	advisorLargeResponseSize = 1 << 20
+	// Mean response size above which gRPC compression is worth enabling.
+	advisorCompressibleSize = 16 << 10
+	// Ratio of compressed to uncompressed gRPC bytes above which compression
+	// saves too little to be worth its CPU.
+	advisorIncompressibleRatio = 0.9
+	// Response size above which drivers with default settings reject frames,
+	// half of the 256MB default maximum frame size of Cassandra.
+	advisorOversizedResponseSize = 128 << 20
+)
+
+// payloadRecommendation is a recommendation of the payload advisor to tune an
+// option.
+type payloadRecommendation struct {
+	option string
+	text   string
+}
+
+// payloadRecommendations returns the recommendations to tune opts for the
+// payloads exchanged between the previous and the current stats of a proxy.
+// Intervals with fewer than advisorMinResponses responses are ignored.
+func payloadRecommendations(
+	opts Options,
+	current, previous Stats,
+) []payloadRecommendation {
+	requests := current.RequestSizes.since(previous.RequestSizes)
+	responses := current.ResponseSizes.since(previous.ResponseSizes)
+	if responses.Count < advisorMinResponses {
+		return nil
+	}
+	var recommendations []payloadRecommendation
+	if p99 := responses.Quantile(0.99); p99 >= advisorOversizedResponseSize {
+		recommendations = append(recommendations, payloadRecommendation{
+			option: "UnpagedReadPageSize",
+			text: fmt.Sprintf(
+				"the 99th percentile of the response sizes is %dMB, which drivers may reject: page the reads in the driver, or set UnpagedReadPageSize and restrict the reads",
+				p99>>20,
+			),
+		})
+	} else if p99 >= advisorLargeResponseSize && opts.UnpagedReadPageSize <= 0 {
+		recommendations = append(recommendations, payloadRecommendation{
+			option: "UnpagedReadPageSize",
+			text: fmt.Sprintf(
+				"the 99th percentile of the response sizes is %dKB: page the reads in the driver, or set UnpagedReadPageSize so that Spanner returns unpaged reads a page at a time",
+				p99>>10,
+			),
+		})
+	}
+	if limit := int64(maxSendMsgSize(opts) - requestEnvelopeSize); requests.Quantile(0.99) > limit/2 {
+		recommendations = append(recommendations, payloadRecommendation{
+			option: "MaxBatchStatements",
+			text: fmt.Sprintf(
+				"the 99th percentile of the request sizes is above half of MaxRequestSize (%dMB): split large batches with MaxBatchStatements, or raise MaxRequestSize",
+				limit>>20,
+			),
+		})
+	}
+	received := current.GrpcBytesReceived - previous.GrpcBytesReceived
+	compressed := current.GrpcCompressedBytesReceived - previous.GrpcCompressedBytesReceived
+	switch opts.GrpcCompression {
+	case "", "none":
+		if responses.Mean() >= advisorCompressibleSize {
+			recommendations = append(recommendations, payloadRecommendation{
+				option: "GrpcCompression",
+				text: fmt.Sprintf(
+					"the mean response is %dKB: set GrpcCompression to gzip to reduce the bandwidth used with Spanner",
+					responses.Mean()>>10,
+				),
+			})
+		}
+	default:
+		if received > 0 && float64(compressed)/float64(received) > advisorIncompressibleRatio {
+			recommendations = append(recommendations, payloadRecommendation{
+				option: "GrpcCompression",
+				text: fmt.Sprintf(
+					"compression only saves %.0f%% of the bytes received from Spanner: unset GrpcCompression to save its CPU",
+					100*(1-float64(compressed)/float64(received)),
+				),
+			})
+		}
+	}
+	return recommendations
+}
+
+// payloadAdvisor logs recommendations to tune the proxy for the sizes of its
+// payloads every Options.PayloadAdvisorInterval.
+type payloadAdvisor struct {
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// startPayloadAdvisor starts the periodic recommendations of proxy. Returns
+// nil unless Options.PayloadAdvisorInterval is set.
+func startPayloadAdvisor(proxy *TCPProxy) *payloadAdvisor {
+	interval := proxy.opts.PayloadAdvisorInterval
+	if interval <= 0 {
+		return nil
+	}
+	a := &payloadAdvisor{done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		previous := proxy.Stats()
+		for {
+			select {
+			case <-a.done:
+				return
+			case <-ticker.C:
+			}
+			current := proxy.Stats()
+			requests := current.RequestSizes.since(previous.RequestSizes)
+			responses := current.ResponseSizes.since(previous.ResponseSizes)
+			for _, r := range payloadRecommendations(proxy.opts, current, previous) {
+				logger.Info("Payload size recommendation: "+r.text,
+					zap.String("option", r.option),
+					zap.Int64("request_size_p99", requests.Quantile(0.99)),
+					zap.Int64("response_size_p99", responses.Quantile(0.99)))
+			}
+			previous = current
+		}
+	}()
+	return a
+}
+
+// close stops the periodic recommendations.
+func (a *payloadAdvisor) close() {
+	if a != nil {
+		a.closeOnce.Do(func() { close(a.done) })
+	}
+}
@@ -0,0 +1,113 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeHistogram(t *testing.T) {
+	var h sizeHistogram
+	h.record(10)
+	h.record(64)
+	h.record(65)
+	h.record(1 << 30)
+	snapshot := h.snapshot()
+	assert.Equal(t, int64(4), snapshot.Count)
+	assert.Equal(t, int64(2), snapshot.Counts[0])
+	assert.Equal(t, int64(1), snapshot.Counts[1])
+	assert.Equal(t, int64(1), snapshot.Counts[len(snapshot.Counts)-1])
+	assert.Equal(t, int64(64), snapshot.Quantile(0.25))
+	assert.Equal(t, int64(128), snapshot.Quantile(0.5))
+	assert.Equal(t, int64(256<<20), snapshot.Quantile(0.99))
+	assert.Equal(t, (10+64+65+int64(1<<30))/4, snapshot.Mean())
+
+	h.record(100)
+	delta := h.snapshot().since(snapshot)
+	assert.Equal(t, int64(1), delta.Count)
+	assert.Equal(t, int64(100), delta.Sum)
+	assert.Equal(t, int64(128), delta.Quantile(0.5))
+}
+
+// payloadStats returns the stats of n requests and responses of the given
+// sizes.
+func payloadStats(n int, requestSize, responseSize int) Stats {
+	var requests, responses sizeHistogram
+	for i := 0; i < n; i++ {
+		requests.record(requestSize)
+		responses.record(responseSize)
+	}
+	return Stats{
+		RequestSizes:  requests.snapshot(),
+		ResponseSizes: responses.snapshot(),
+	}
+}
+
+func recommendedOptions(recommendations []payloadRecommendation) []string {
+	var options []string
+	for _, r := range recommendations {
+		options = append(options, r.option)
+	}
+	return options
+}
+
+func TestPayloadRecommendations(t *testing.T) {
+	var none Stats
+
+	// Too few responses.
+	assert.Empty(t, payloadRecommendations(Options{}, payloadStats(10, 1<<10, 4<<20), none))
+
+	// Small payloads.
+	assert.Empty(t, payloadRecommendations(Options{}, payloadStats(200, 1<<10, 1<<10), none))
+
+	// Large responses.
+	assert.Equal(t,
+		[]string{"UnpagedReadPageSize", "GrpcCompression"},
+		recommendedOptions(payloadRecommendations(Options{}, payloadStats(200, 1<<10, 4<<20), none)))
+	assert.Equal(t,
+		[]string{"GrpcCompression"},
+		recommendedOptions(payloadRecommendations(
+			Options{UnpagedReadPageSize: 1000},
+			payloadStats(200, 1<<10, 4<<20),
+			none,
+		)))
+
+	// Large requests.
+	assert.Equal(t,
+		[]string{"MaxBatchStatements"},
+		recommendedOptions(payloadRecommendations(
+			Options{MaxRequestSize: 1 << 20},
+			payloadStats(200, 1<<20, 1<<10),
+			none,
+		)))
+
+	// Incompressible responses.
+	stats := payloadStats(200, 1<<10, 1<<10)
+	stats.GrpcBytesReceived = 1000
+	stats.GrpcCompressedBytesReceived = 950
+	assert.Equal(t,
+		[]string{"GrpcCompression"},
+		recommendedOptions(payloadRecommendations(Options{GrpcCompression: "gzip"}, stats, none)))
+
+	// Only the payloads of the interval are considered.
+	assert.Empty(t, payloadRecommendations(Options{}, stats, stats))
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/google/uuid"
+)
+
+const (
+	// Maximum Options.SyntheticPeerCount, the synthetic peers having the
+	// addresses 127.0.1.1 to 127.0.1.254.
+	maxSyntheticPeers = 254
+	// Data center, rack and release version of the synthetic peers, unless
+	// overridden by Options.TopologyOverride.
+	defaultDataCenter     = "datacenter1"
+	defaultRack           = "rack1"
+	defaultReleaseVersion = "4.0.0"
+	// Port of the synthetic peers in system.peers_v2.
+	syntheticPeerPort = 9042
+)
+
+// Namespace of the host ids of the synthetic peers.
+var syntheticPeerNamespace = uuid.MustParse("0ad5e2b1-93c4-4f0e-9b0c-5f3e0cba3a11")
+
+var peerColumns = []columnDef{
+	{"peer", datatype.Inet},
+	{"data_center", datatype.Varchar},
+	{"host_id", datatype.Uuid},
+	{"preferred_ip", datatype.Inet},
+	{"rack", datatype.Varchar},
+	{"release_version", datatype.Varchar},
+	{"rpc_address", datatype.Inet},
+	{"schema_version", datatype.Uuid},
+	{"tokens", datatype.NewSet(datatype.Varchar)},
+}
+
+var peerV2Columns = []columnDef{
+	{"peer", datatype.Inet},
+	{"peer_port", datatype.Int},
+	{"data_center", datatype.Varchar},
+	{"host_id", datatype.Uuid},
+	{"native_address", datatype.Inet},
+	{"native_port", datatype.Int},
+	{"preferred_ip", datatype.Inet},
+	{"preferred_port", datatype.Int},
+	{"rack", datatype.Varchar},
+	{"release_version", datatype.Varchar},
+	{"schema_version", datatype.Uuid},
+	{"tokens", datatype.NewSet(datatype.Varchar)},
+}
+
+// peerTables emulates system.peers and system.peers_v2 with
+// Options.SyntheticPeerCount virtual peers, so that drivers sizing their
+// connection pools per host open more connections to the proxy. Drivers must
+// translate the addresses of the peers to the address of the proxy, as the
+// gocql clusters of the proxy do.
+var peerTables = []*virtualTable{
+	{
+		keyspace: "system",
+		name:     "peers",
+		columns:  virtualColumns("system", "peers", peerColumns),
+		rows: func(dc *driverConnection, _ string) message.RowSet {
+			var rows message.RowSet
+			for _, peer := range dc.syntheticPeers() {
+				rows = append(rows, message.Row{
+					peer.address, peer.dataCenter, peer.hostID, peer.address, peer.rack,
+					peer.releaseVersion, peer.address, nil, peer.tokens,
+				})
+			}
+			return rows
+		},
+	},
+	{
+		keyspace: "system",
+		name:     "peers_v2",
+		columns:  virtualColumns("system", "peers_v2", peerV2Columns),
+		rows: func(dc *driverConnection, _ string) message.RowSet {
+			port := encodeInt(syntheticPeerPort)
+			var rows message.RowSet
+			for _, peer := range dc.syntheticPeers() {
+				rows = append(rows, message.Row{
+					peer.address, port, peer.dataCenter, peer.hostID, peer.address, port,
+					peer.address, port, peer.rack, peer.releaseVersion, nil, peer.tokens,
+				})
+			}
+			return rows
+		},
+	},
+}
+
+// syntheticPeer holds the encoded columns of a synthetic peer.
+type syntheticPeer struct {
+	address        []byte
+	dataCenter     []byte
+	rack           []byte
+	hostID         []byte
+	releaseVersion []byte
+	tokens         []byte
+}
+
+// validateSyntheticPeerCount checks Options.SyntheticPeerCount.
+func validateSyntheticPeerCount(count int) error {
+	if count < 0 || count > maxSyntheticPeers {
+		return fmt.Errorf("SyntheticPeerCount must be between 0 and %d, got %d",
+			maxSyntheticPeers, count)
+	}
+	return nil
+}
+
+// syntheticPeers returns the Options.SyntheticPeerCount peers reported to the
+// driver. Each peer owns a single token, the tokens being evenly spread over
+// the Murmur3 ring. The peers have no schema version, so that drivers do not
+// wait for them to agree on schema changes, and no tokens with
+// Options.CqlshCompatibility, like system.local.
+func (dc *driverConnection) syntheticPeers() []syntheticPeer {
+	opts := dc.executor.opts
+	dataCenter, rack, releaseVersion := defaultDataCenter, defaultRack, defaultReleaseVersion
+	if opts.CqlshCompatibility {
+		releaseVersion = cqlshReleaseVersion
+	}
+	if topology := opts.TopologyOverride; topology != nil {
+		if topology.DataCenter != "" {
+			dataCenter = topology.DataCenter
+		}
+		if topology.Rack != "" {
+			rack = topology.Rack
+		}
+		if topology.ReleaseVersion != "" {
+			releaseVersion = topology.ReleaseVersion
+		}
+	}
+	count := opts.SyntheticPeerCount
+	step := math.MaxUint64 / uint64(count+1)
+	peers := make([]syntheticPeer, 0, count)
+	for i := 0; i < count; i++ {
+		hostID := uuid.NewSHA1(syntheticPeerNamespace, []byte(strconv.Itoa(i+1)))
+		peer := syntheticPeer{
+			address:        encodeInet(net.IPv4(127, 0, 1, byte(i+1))),
+			dataCenter:     []byte(dataCenter),
+			rack:           []byte(rack),
+			hostID:         hostID[:],
+			releaseVersion: []byte(releaseVersion),
+		}
+		if !opts.CqlshCompatibility {
+			token := int64(uint64(i+1)*step) + math.MinInt64
+			peer.tokens = encodeCollection([][]byte{[]byte(strconv.FormatInt(token, 10))}, 1)
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
@@ -0,0 +1,77 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyntheticPeers(t *testing.T) {
+	dc := &driverConnection{
+		executor: &requestExecutor{opts: &Options{
+			SyntheticPeerCount: 3,
+			TopologyOverride:   &TopologyOverride{DataCenter: "us-east1"},
+		}},
+	}
+	query := func(query string) message.Message {
+		return dc.tryServeVirtualTable(frame.NewFrame(
+			primitive.ProtocolVersion4,
+			1,
+			&message.Query{Query: query},
+		))
+	}
+
+	msg := query("SELECT peer, data_center, rack, host_id, tokens FROM system.peers")
+	require.IsType(t, &message.RowsResult{}, msg)
+	rows := msg.(*message.RowsResult).Data
+	require.Len(t, rows, 3)
+	hostIDs := make(map[string]bool)
+	for i, row := range rows {
+		assert.Equal(t, []byte{127, 0, 1, byte(i + 1)}, row[0])
+		assert.Equal(t, []byte("us-east1"), row[1])
+		assert.Equal(t, []byte(defaultRack), row[2])
+		assert.Len(t, row[3], 16)
+		hostIDs[string(row[3])] = true
+		elements, ok := decodeCollection(row[4], 1)
+		require.True(t, ok)
+		assert.Len(t, elements, 1)
+	}
+	assert.Len(t, hostIDs, 3)
+
+	msg = query("SELECT * FROM system.peers_v2")
+	require.IsType(t, &message.RowsResult{}, msg)
+	assert.Len(t, msg.(*message.RowsResult).Data, 3)
+
+	dc.executor.opts.SyntheticPeerCount = 0
+	assert.Nil(t, query("SELECT * FROM system.peers"))
+}
+
+func TestValidateSyntheticPeerCount(t *testing.T) {
+	assert.NoError(t, validateSyntheticPeerCount(0))
+	assert.NoError(t, validateSyntheticPeerCount(maxSyntheticPeers))
+	assert.Error(t, validateSyntheticPeerCount(-1))
+	assert.Error(t, validateSyntheticPeerCount(maxSyntheticPeers+1))
+}
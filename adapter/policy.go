@@ -0,0 +1,262 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// StatementPolicy restricts the statements served by the proxy. Rejected
+// statements fail with an Unauthorized error. Prepared statements are checked
+// when they are prepared, and also when they are executed if the policy is
+// refreshed from SecretRefs.StatementPolicy.
+type StatementPolicy struct {
+	// Optional regular expressions, of which statements must match at least
+	// one. Defaults to empty (all statements are allowed).
+	Allow []string
+	// Optional regular expressions, of which statements must match none.
+	// Defaults to empty.
+	Deny []string
+	// Optional boolean to reject all statements but SELECT and USE statements.
+	// Defaults to false.
+	ReadOnly bool
+	// Optional list of tables (ie: "keyspace.table" or "table") on which DML
+	// statements, TRUNCATE and table DDL statements are rejected. Defaults to
+	// empty.
+	ReadOnlyTables []string
+}
+
+// statementPolicy is a StatementPolicy with compiled regular expressions.
+// It is updated in place when refreshed from SecretRefs.StatementPolicy.
+type statementPolicy struct {
+	mu             sync.RWMutex
+	allow          []*regexp.Regexp
+	deny           []*regexp.Regexp
+	readOnly       bool
+	readOnlyTables []string
+	// Statements of prepared query ids, so that their executions are checked
+	// against the updates of the policy. Nil unless the policy is refreshed.
+	statements *lru.Cache
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid statement policy pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// newStatementPolicy compiles a policy. Returns nil if policy is nil.
+func newStatementPolicy(policy *StatementPolicy) (*statementPolicy, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	allow, err := compilePatterns(policy.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := compilePatterns(policy.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &statementPolicy{
+		allow:          allow,
+		deny:           deny,
+		readOnly:       policy.ReadOnly,
+		readOnlyTables: policy.ReadOnlyTables,
+	}, nil
+}
+
+// update replaces the rules of the policy with those of other.
+func (sp *statementPolicy) update(other *statementPolicy) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.allow = other.allow
+	sp.deny = other.deny
+	sp.readOnly = other.readOnly
+	sp.readOnlyTables = other.readOnlyTables
+}
+
+// trackStatements makes the policy remember the statements of up to size
+// prepared query ids, so that their executions are checked against the
+// updates of the policy rather than only when they were prepared.
+func (sp *statementPolicy) trackStatements(size int) error {
+	cache, err := lru.New(size)
+	if err != nil {
+		return err
+	}
+	sp.statements = cache
+	return nil
+}
+
+// rememberStatement records the statement of a prepared query id the policy
+// accepted.
+func (sp *statementPolicy) rememberStatement(id []byte, query string) {
+	if sp != nil && sp.statements != nil {
+		sp.statements.Add(string(id), query)
+	}
+}
+
+// checkPrepared checks the statement of a prepared query id against the
+// policy. Returns an Unprepared error message for unknown ids, so that the
+// driver prepares the statement again, which checks it.
+func (sp *statementPolicy) checkPrepared(id []byte) message.Message {
+	if sp == nil || sp.statements == nil {
+		return nil
+	}
+	query, ok := sp.statements.Get(string(id))
+	if !ok {
+		return &message.Unprepared{
+			ErrorMessage: "Unknown prepared query in the statement policy cache",
+			Id:           id,
+		}
+	}
+	return sp.check(query.(string))
+}
+
+// rememberPolicyStatement records the statement of the prepared query id
+// returned by the server for req, once the policy accepted it.
+func (dc *driverConnection) rememberPolicyStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	policy := dc.executor.policy
+	if policy == nil || policy.statements == nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		policy.rememberStatement(id, prepare.Query)
+	}
+}
+
+// writeTarget returns the table a DML, TRUNCATE or table DDL statement
+// modifies.
+func writeTarget(query string) (string, bool) {
+	if _, table, ok := parseDMLTarget(query); ok {
+		return table, true
+	}
+	tokens := tokenizeCQL(query)
+	if len(tokens) < 2 {
+		return "", false
+	}
+	i := 1
+	switch {
+	case tokens[0].is("truncate"):
+		if tokens[1].is("table") {
+			i++
+		}
+	case tokens[0].is("alter") || tokens[0].is("drop") || tokens[0].is("create"):
+		if !tokens[1].is("table") {
+			return "", false
+		}
+		i++
+		if i < len(tokens) && tokens[i].is("if") {
+			for i < len(tokens) && !tokens[i].is("exists") {
+				i++
+			}
+			i++
+		}
+	default:
+		return "", false
+	}
+	return parseTableName(tokens, i)
+}
+
+// check returns an Unauthorized error message if the policy rejects query.
+func (sp *statementPolicy) check(query string) message.Message {
+	if sp == nil {
+		return nil
+	}
+	sp.mu.RLock()
+	defer sp.mu.RUnlock()
+	reject := func(reason string) message.Message {
+		return &message.Unauthorized{
+			ErrorMessage: "Statement rejected by the proxy statement policy: " + reason,
+		}
+	}
+	if len(sp.allow) > 0 {
+		allowed := false
+		for _, re := range sp.allow {
+			if re.MatchString(query) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return reject("it matches no allowed pattern")
+		}
+	}
+	for _, re := range sp.deny {
+		if re.MatchString(query) {
+			return reject(fmt.Sprintf("it matches denied pattern %q", re.String()))
+		}
+	}
+	if sp.readOnly && !isCQLRead(query) {
+		return reject("the proxy is read-only")
+	}
+	if len(sp.readOnlyTables) > 0 {
+		if table, ok := writeTarget(query); ok &&
+			matchesTable(sp.readOnlyTables, table) {
+			return reject(fmt.Sprintf("table %s is read-only", table))
+		}
+	}
+	return nil
+}
+
+// tryCheckPolicy checks the statements of a QUERY, PREPARE or BATCH request
+// against Options.StatementPolicy, along with the prepared statements of
+// EXECUTE and BATCH requests if the policy is refreshed, and returns an
+// Unauthorized error message for the first rejected statement.
+func (re *requestExecutor) tryCheckPolicy(frm *frame.Frame) message.Message {
+	if re.policy == nil {
+		return nil
+	}
+	for _, query := range queriesOf(frm) {
+		if err := re.policy.check(query); err != nil {
+			return err
+		}
+	}
+	switch msg := frm.Body.Message.(type) {
+	case *message.Execute:
+		return re.policy.checkPrepared(msg.QueryId)
+	case *message.Batch:
+		for _, child := range msg.Children {
+			if child.Query != "" {
+				continue
+			}
+			if err := re.policy.checkPrepared(child.Id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
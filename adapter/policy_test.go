@@ -0,0 +1,133 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteTarget(t *testing.T) {
+	testCases := []struct {
+		query string
+		table string
+		ok    bool
+	}{
+		{"INSERT INTO ks.t (id) VALUES (1)", "ks.t", true},
+		{"DELETE FROM t WHERE id = 1", "t", true},
+		{"TRUNCATE ks.t", "ks.t", true},
+		{"TRUNCATE TABLE t", "t", true},
+		{"DROP TABLE IF EXISTS ks.t", "ks.t", true},
+		{"CREATE TABLE IF NOT EXISTS t (id int PRIMARY KEY)", "t", true},
+		{"ALTER TABLE t ADD a int", "t", true},
+		{"DROP KEYSPACE ks", "", false},
+		{"SELECT * FROM t", "", false},
+	}
+	for _, tc := range testCases {
+		table, ok := writeTarget(tc.query)
+		assert.Equal(t, tc.ok, ok, tc.query)
+		assert.Equal(t, tc.table, table, tc.query)
+	}
+}
+
+func TestStatementPolicy(t *testing.T) {
+	_, err := newStatementPolicy(&StatementPolicy{Deny: []string{"("}})
+	assert.Error(t, err)
+
+	policy, err := newStatementPolicy(nil)
+	require.NoError(t, err)
+	assert.Nil(t, policy.check("DROP TABLE t"))
+
+	policy, err = newStatementPolicy(&StatementPolicy{
+		Allow:          []string{`(?i)^\s*(select|insert|update)\s`},
+		Deny:           []string{`(?i)secrets`},
+		ReadOnlyTables: []string{"ks.accounts"},
+	})
+	require.NoError(t, err)
+	assert.Nil(t, policy.check("SELECT * FROM ks.accounts"))
+	assert.Nil(t, policy.check("INSERT INTO ks.users (id) VALUES (1)"))
+	assert.IsType(t, &message.Unauthorized{}, policy.check("DELETE FROM ks.users WHERE id = 1"))
+	assert.IsType(t, &message.Unauthorized{}, policy.check("SELECT * FROM ks.secrets"))
+	assert.IsType(t, &message.Unauthorized{}, policy.check("UPDATE ks.accounts SET a = 1 WHERE id = 1"))
+
+	policy, err = newStatementPolicy(&StatementPolicy{ReadOnly: true})
+	require.NoError(t, err)
+	assert.Nil(t, policy.check("SELECT * FROM t"))
+	assert.IsType(t, &message.Unauthorized{}, policy.check("INSERT INTO t (id) VALUES (1)"))
+}
+
+func TestPreparePolicyAttachments(t *testing.T) {
+	policy, err := newStatementPolicy(&StatementPolicy{ReadOnlyTables: []string{"t"}})
+	require.NoError(t, err)
+	re := &requestExecutor{opts: &Options{}, policy: policy}
+	newFrame := func(msg message.Message) *frame.Frame {
+		return frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+	}
+
+	batch := newFrame(&message.Batch{
+		Type: primitive.BatchTypeLogged,
+		Children: []*message.BatchChild{
+			{Query: "INSERT INTO other (id) VALUES (1)"},
+			{Query: "INSERT INTO t (id) VALUES (1)"},
+		},
+	})
+	assert.IsType(t, &message.Unauthorized{},
+		re.prepareCassandraAttachments(batch, &requestState{}))
+	prepare := newFrame(&message.Prepare{Query: "UPDATE t SET a = ? WHERE id = ?"})
+	assert.IsType(t, &message.Unauthorized{},
+		re.prepareCassandraAttachments(prepare, &requestState{}))
+}
+
+func TestRefreshedPolicyChecksPreparedStatements(t *testing.T) {
+	policy, err := newStatementPolicy(&StatementPolicy{})
+	require.NoError(t, err)
+	require.NoError(t, policy.trackStatements(10))
+	re := &requestExecutor{opts: &Options{}, policy: policy}
+	newFrame := func(msg message.Message) *frame.Frame {
+		return frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+	}
+	execute := newFrame(&message.Execute{QueryId: []byte("delete")})
+	batch := newFrame(&message.Batch{
+		Type:     primitive.BatchTypeLogged,
+		Children: []*message.BatchChild{{Id: []byte("delete")}},
+	})
+
+	// Executions of unknown prepared query ids are prepared again.
+	assert.IsType(t, &message.Unprepared{}, re.tryCheckPolicy(execute))
+	policy.rememberStatement([]byte("delete"), "DELETE FROM t WHERE id = ?")
+	assert.Nil(t, re.tryCheckPolicy(execute))
+	assert.Nil(t, re.tryCheckPolicy(batch))
+
+	// Statements prepared before the policy tightened are rejected.
+	updated, err := newStatementPolicy(&StatementPolicy{ReadOnly: true})
+	require.NoError(t, err)
+	policy.update(updated)
+	assert.IsType(t, &message.Unauthorized{}, re.tryCheckPolicy(execute))
+	assert.IsType(t, &message.Unauthorized{}, re.tryCheckPolicy(batch))
+
+	// Policies that are not refreshed check statements when they are prepared.
+	re.policy = updated
+	assert.Nil(t, re.tryCheckPolicy(execute))
+}
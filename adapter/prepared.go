@@ -0,0 +1,169 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// Offset of the stream id within an encoded v3+ frame header.
+const streamIdOffset = 2
+
+// Removes the quotes of quoted CQL identifiers.
+var identifierQuotes = strings.NewReplacer(`"`, "")
+
+// preparedResultKey returns the global state key under which the encoded
+// PREPARE response of query in database is cached.
+func preparedResultKey(
+	version primitive.ProtocolVersion,
+	database, keyspace, query string,
+) string {
+	var key strings.Builder
+	key.WriteString(preparedResultStatePrefix)
+	key.WriteString(strconv.Itoa(int(version)))
+	key.WriteString("/")
+	key.WriteString(database)
+	key.WriteString("/")
+	key.WriteString(keyspace)
+	key.WriteString("/")
+	key.WriteString(query)
+	return key.String()
+}
+
+// parseUseKeyspace returns the keyspace selected by a USE statement.
+func parseUseKeyspace(query string) (string, bool) {
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "use") {
+		return "", false
+	}
+	return identifierQuotes.Replace(fields[1]), true
+}
+
+// withStreamId returns a copy of the encoded frame with its stream id
+// replaced.
+func withStreamId(encoded []byte, streamId int16) []byte {
+	out := append([]byte(nil), encoded...)
+	binary.BigEndian.PutUint16(out[streamIdOffset:], uint16(streamId))
+	return out
+}
+
+// database returns the Spanner database serving the connection, which depends
+// on the listener it was accepted by.
+func (dc *driverConnection) database() string {
+	if dc.adapterClient == nil {
+		return ""
+	}
+	return dc.adapterClient.opts.DatabaseUri
+}
+
+// preparedQueryId decodes an encoded PREPARE response and returns the
+// prepared query id it carries.
+func (dc *driverConnection) preparedQueryId(encoded []byte) ([]byte, bool) {
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return nil, false
+	}
+	prepared, ok := frm.Body.Message.(*message.PreparedResult)
+	if !ok {
+		return nil, false
+	}
+	return prepared.PreparedQueryId, true
+}
+
+// tryServePreparedLocally answers a PREPARE request from the prepared result
+// cache. A cached result is only used while the prepared query id it refers to
+// is still known to the global state, so that subsequent EXECUTE requests
+// don't fail with an Unprepared error.
+func (dc *driverConnection) tryServePreparedLocally(frm *frame.Frame) bool {
+	if dc.executor.opts.DisablePreparedResultCache {
+		return false
+	}
+	prepare, ok := frm.Body.Message.(*message.Prepare)
+	if !ok {
+		return false
+	}
+	// Statements the current policy rejects are answered with its error.
+	if dc.executor.policy.check(prepare.Query) != nil {
+		return false
+	}
+	keyspace := prepare.Keyspace
+	if keyspace == "" {
+		keyspace = dc.keyspace
+	}
+	cached, found := dc.globalState.Load(
+		preparedResultKey(frm.Header.Version, dc.database(), keyspace, prepare.Query),
+	)
+	if !found {
+		return false
+	}
+	encoded := []byte(cached)
+	id, ok := dc.preparedQueryId(encoded)
+	if !ok {
+		return false
+	}
+	if _, found := dc.globalState.Load(
+		preparedQueryIdAttachmentPrefix + string(id),
+	); !found {
+		return false
+	}
+	// The codecs of the columns of a statement are remembered from the
+	// responses of Spanner.
+	if cc := dc.executor.columnCodecs; cc != nil {
+		if _, found := cc.lookup(id); !found {
+			return false
+		}
+	}
+	if _, err := dc.driverConn.Write(withStreamId(encoded, frm.Header.StreamId)); err != nil {
+		return false
+	}
+	dc.executor.policy.rememberStatement(id, prepare.Query)
+	return true
+}
+
+// cachePreparedResult stores the encoded PREPARE response returned by the
+// server for req.
+func (dc *driverConnection) cachePreparedResult(
+	req *requestState,
+	encoded []byte,
+) {
+	// Statements setting a TTL are not cached, as req holds their rewritten
+	// query which drivers never prepare.
+	if dc.executor.opts.DisablePreparedResultCache || req.ttl != nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	if _, ok := dc.preparedQueryId(encoded); !ok {
+		return
+	}
+	keyspace := prepare.Keyspace
+	if keyspace == "" {
+		keyspace = dc.keyspace
+	}
+	dc.globalState.Store(
+		preparedResultKey(req.frame.Header.Version, dc.database(), keyspace, prepare.Query),
+		string(encoded),
+	)
+}
@@ -0,0 +1,187 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUseKeyspace(t *testing.T) {
+	testCases := []struct {
+		query  string
+		want   string
+		wantOk bool
+	}{
+		{"USE ks", "ks", true},
+		{"  use \"MyKs\";  ", "MyKs", true},
+		{"SELECT * FROM ks.t", "", false},
+		{"USE", "", false},
+	}
+	for _, tc := range testCases {
+		got, ok := parseUseKeyspace(tc.query)
+		assert.Equal(t, tc.wantOk, ok, tc.query)
+		assert.Equal(t, tc.want, got, tc.query)
+	}
+}
+
+func newPrepareFrame(streamId int16, query string) *frame.Frame {
+	return &frame.Frame{
+		Header: &frame.Header{
+			Version:  primitive.ProtocolVersion4,
+			StreamId: streamId,
+			OpCode:   primitive.OpCodePrepare,
+		},
+		Body: &frame.Body{Message: &message.Prepare{Query: query}},
+	}
+}
+
+func encodePreparedResult(t *testing.T, streamId int16, id []byte) []byte {
+	t.Helper()
+	frm := &frame.Frame{
+		Header: &frame.Header{
+			IsResponse: true,
+			Version:    primitive.ProtocolVersion4,
+			StreamId:   streamId,
+			OpCode:     primitive.OpCodeResult,
+		},
+		Body: &frame.Body{Message: &message.PreparedResult{
+			PreparedQueryId:   id,
+			VariablesMetadata: &message.VariablesMetadata{},
+			ResultMetadata:    &message.RowsMetadata{},
+		}},
+	}
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(frm, buf))
+	return buf.Bytes()
+}
+
+func TestServePreparedLocally(t *testing.T) {
+	state, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	dc := &driverConnection{
+		driverConn:  serverConn,
+		globalState: state,
+		executor:    &requestExecutor{opts: &Options{}},
+		codec:       codec,
+		keyspace:    "ks",
+	}
+	query := "SELECT * FROM t WHERE id = ?"
+
+	// Nothing is cached yet.
+	assert.False(t, dc.tryServePreparedLocally(newPrepareFrame(1, query)))
+
+	dc.cachePreparedResult(
+		&requestState{
+			pb:    &adapterpb.AdaptMessageRequest{},
+			frame: *newPrepareFrame(1, query),
+		},
+		encodePreparedResult(t, 1, []byte("id1")),
+	)
+
+	// The prepared query id is unknown, the request must reach the server.
+	assert.False(t, dc.tryServePreparedLocally(newPrepareFrame(2, query)))
+
+	state.Store(preparedQueryIdAttachmentPrefix+"id1", "hashed_query")
+	go func() {
+		assert.True(t, dc.tryServePreparedLocally(newPrepareFrame(3, query)))
+	}()
+	got, err := codec.DecodeFrame(clientConn)
+	require.NoError(t, err)
+	assert.Equal(t, int16(3), got.Header.StreamId)
+	assert.Equal(
+		t,
+		[]byte("id1"),
+		got.Body.Message.(*message.PreparedResult).PreparedQueryId,
+	)
+
+	// Results are cached per keyspace.
+	dc.keyspace = "other"
+	assert.False(t, dc.tryServePreparedLocally(newPrepareFrame(4, query)))
+
+	// Statements the current policy rejects are not served locally.
+	dc.keyspace = "ks"
+	dc.executor.policy, err = newStatementPolicy(&StatementPolicy{Deny: []string{"FROM t"}})
+	require.NoError(t, err)
+	assert.False(t, dc.tryServePreparedLocally(newPrepareFrame(5, query)))
+}
+
+func TestCachedPreparedResultIsNotAmended(t *testing.T) {
+	state, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	dc := &driverConnection{
+		driverConn:    serverConn,
+		adapterClient: &AdapterClient{},
+		globalState:   state,
+		executor: &requestExecutor{
+			opts: &Options{EnableLatencyPayload: true},
+		},
+		codec: codec,
+	}
+	query := "SELECT * FROM t WHERE id = ?"
+
+	// The first PREPARE is served by Spanner, its response carries latencies.
+	req := &requestState{
+		pb:       &adapterpb.AdaptMessageRequest{},
+		frame:    *newPrepareFrame(1, query),
+		received: time.Now(),
+	}
+	pbCli := &Mock_Payload_AdaptMessageClient{
+		payload:      encodePreparedResult(t, 1, []byte("id1")),
+		stateUpdates: map[string]string{preparedQueryIdAttachmentPrefix + "id1": query},
+	}
+	done := make(chan error, 1)
+	go func() { done <- dc.writeGrpcResponseToTcp(context.Background(), pbCli, req) }()
+	got, err := codec.DecodeFrame(clientConn)
+	require.NoError(t, err)
+	require.NoError(t, <-done)
+	assert.Contains(t, got.Body.CustomPayload, RequestLatencyPayloadKey)
+
+	// The second PREPARE is served from the cache, without the latencies of
+	// the first one.
+	go func() {
+		assert.True(t, dc.tryServePreparedLocally(newPrepareFrame(2, query)))
+	}()
+	got, err = codec.DecodeFrame(clientConn)
+	require.NoError(t, err)
+	assert.Equal(t, int16(2), got.Header.StreamId)
+	assert.NotContains(t, got.Body.CustomPayload, RequestLatencyPayloadKey)
+	assert.Equal(
+		t,
+		[]byte("id1"),
+		got.Body.Message.(*message.PreparedResult).PreparedQueryId,
+	)
+}
@@ -16,9 +16,49 @@ limitations under the License.
 
 package adapter
 
-// Protocol is the interface that all protocols must implement.
+import "bufio"
+
+// Protocol is the interface that all protocols must implement. Protocols
+// other than Cassandra must also implement StreamProtocol.
 type Protocol interface {
 
 	// Returns the protocol identifier.
 	Name() string
 }
+
+// DMLClassifier is implemented by protocols that classify their requests.
+// Cassandra protocols that don't implement it use the built-in CQL
+// classification.
+type DMLClassifier interface {
+	// IsDML reports whether the request modifies data, in which case it is
+	// routed to the leader.
+	IsDML(request []byte) bool
+}
+
+// StreamProtocol is implemented by protocols whose requests are forwarded to
+// Spanner as opaque payloads, without the Cassandra specific handling of
+// prepared statements and attachments. Each request read from a driver
+// connection is sent as one AdaptMessage call, and the response payload is
+// written back as is.
+type StreamProtocol interface {
+	Protocol
+
+	DMLClassifier
+
+	// ReadRequest reads the next complete request from a driver connection.
+	ReadRequest(r *bufio.Reader) ([]byte, error)
+	// ErrorResponse encodes a failure to serve a request as a protocol error
+	// reply.
+	ErrorResponse(request []byte, err error) []byte
+	// Attachments returns the attachments sent to Spanner along with the
+	// request, if any. state holds the state updates returned by Spanner for
+	// earlier requests. A returned error is sent back as an error reply
+	// without calling Spanner.
+	Attachments(request []byte, state StateReader) (map[string]string, error)
+}
+
+// StateReader gives read access to the state updates returned by Spanner.
+type StateReader interface {
+	// Load returns the value stored for key, if any.
+	Load(key string) (string, bool)
+}
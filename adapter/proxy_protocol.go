@@ -0,0 +1,133 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// Signature starting PROXY protocol v2 headers.
+var proxyProtocolSignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+const (
+	// Length of the fixed part of PROXY protocol v2 headers.
+	proxyHeaderLength = 16
+	// Commands of PROXY protocol v2 headers.
+	proxyCommandLocal = 0x0
+	proxyCommandProxy = 0x1
+	// Address families of PROXY protocol v2 headers.
+	proxyFamilyInet  = 0x1
+	proxyFamilyInet6 = 0x2
+)
+
+// Time given to a connection to send its PROXY protocol header.
+var proxyHeaderTimeout = 5 * time.Second
+
+// readProxyHeader reads the PROXY protocol v2 header starting a connection,
+// and returns the address of the client it carries. Returns a nil address for
+// headers of health checks (LOCAL command) and of unsupported address
+// families, for which the address of the connection is kept.
+func readProxyHeader(r io.Reader) (net.Addr, error) {
+	var header [proxyHeaderLength]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+	}
+	if !bytes.Equal(header[:12], proxyProtocolSignature) {
+		return nil, fmt.Errorf("connection did not start with a PROXY protocol v2 header")
+	}
+	if version := header[12] >> 4; version != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", version)
+	}
+	body := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY protocol header: %w", err)
+	}
+	switch command := header[12] & 0xf; command {
+	case proxyCommandLocal:
+		return nil, nil
+	case proxyCommandProxy:
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol command %d", command)
+	}
+	// Source and destination addresses are followed by their ports.
+	var size int
+	switch header[13] >> 4 {
+	case proxyFamilyInet:
+		size = net.IPv4len
+	case proxyFamilyInet6:
+		size = net.IPv6len
+	default:
+		return nil, nil
+	}
+	if len(body) < 2*size+4 {
+		return nil, fmt.Errorf("truncated PROXY protocol addresses")
+	}
+	return &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), body[:size]...)),
+		Port: int(binary.BigEndian.Uint16(body[2*size:])),
+	}, nil
+}
+
+// proxyProtocolConn is a driver connection accepted behind a load balancer
+// sending PROXY protocol v2 headers, whose remote address is the address of
+// the client carried by its header. The header is read on first use, so that
+// slow connections do not hold up the accept loop.
+type proxyProtocolConn struct {
+	net.Conn
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.err = readProxyHeader(c.Conn)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			logger.Warn("Spanner proxy rejected a connection without valid PROXY protocol header",
+				zap.String("remote_addr", c.Conn.RemoteAddr().String()),
+				zap.Error(c.err))
+		}
+	})
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.Conn.Read(b)
+}
+
+// RemoteAddr returns the address of the client behind the load balancer.
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
@@ -0,0 +1,123 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// proxyHeader encodes a PROXY protocol v2 header of a TCP connection from
+// src to dst, followed by tlvs.
+func proxyHeader(command byte, src, dst *net.TCPAddr, tlvs []byte) []byte {
+	family := byte(proxyFamilyInet)
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP == nil {
+		family = proxyFamilyInet6
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+	var body bytes.Buffer
+	body.Write(srcIP)
+	body.Write(dstIP)
+	_ = binary.Write(&body, binary.BigEndian, uint16(src.Port))
+	_ = binary.Write(&body, binary.BigEndian, uint16(dst.Port))
+	body.Write(tlvs)
+
+	header := append([]byte(nil), proxyProtocolSignature...)
+	header = append(header, 0x20|command, family<<4|0x1)
+	header = binary.BigEndian.AppendUint16(header, uint16(body.Len()))
+	return append(header, body.Bytes()...)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 9042}
+	for _, src := range []*net.TCPAddr{
+		{IP: net.ParseIP("192.168.1.7").To4(), Port: 51234},
+		{IP: net.ParseIP("2001:db8::7"), Port: 443},
+	} {
+		r := bytes.NewReader(append(
+			proxyHeader(proxyCommandProxy, src, dst, []byte{0x04, 0x00, 0x01, 0xff}),
+			"payload"...,
+		))
+		addr, err := readProxyHeader(r)
+		require.NoError(t, err)
+		assert.Equal(t, src.String(), addr.String())
+		// The connection is left at the end of the header.
+		rest, _ := io.ReadAll(r)
+		assert.Equal(t, "payload", string(rest))
+	}
+
+	// Health checks keep the address of the connection.
+	addr, err := readProxyHeader(bytes.NewReader(
+		proxyHeader(proxyCommandLocal, dst, dst, nil),
+	))
+	require.NoError(t, err)
+	assert.Nil(t, addr)
+
+	for _, header := range [][]byte{
+		[]byte("PROXY TCP4 192.168.1.7 10.0.0.1 51234 9042\r\n"),
+		proxyHeader(proxyCommandProxy, dst, dst, nil)[:20],
+		append(append([]byte(nil), proxyProtocolSignature...), 0x11, 0x11, 0, 0),
+	} {
+		_, err := readProxyHeader(bytes.NewReader(header))
+		assert.Error(t, err)
+	}
+}
+
+func TestProxyProtocolConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := &proxyProtocolConn{Conn: server}
+	defer conn.Close()
+
+	src := &net.TCPAddr{IP: net.ParseIP("192.168.1.7").To4(), Port: 51234}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.1").To4(), Port: 9042}
+	go func() {
+		_, _ = client.Write(proxyHeader(proxyCommandProxy, src, dst, nil))
+		_, _ = client.Write([]byte("frame"))
+	}()
+
+	b := make([]byte, 5)
+	_, err := io.ReadFull(conn, b)
+	require.NoError(t, err)
+	assert.Equal(t, "frame", string(b))
+	assert.Equal(t, src, conn.RemoteAddr())
+}
+
+func TestProxyProtocolConnWithoutHeader(t *testing.T) {
+	defer func(timeout time.Duration) { proxyHeaderTimeout = timeout }(proxyHeaderTimeout)
+	proxyHeaderTimeout = 10 * time.Millisecond
+
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := &proxyProtocolConn{Conn: server}
+	defer conn.Close()
+
+	_, err := conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.Equal(t, server.RemoteAddr(), conn.RemoteAddr())
+}
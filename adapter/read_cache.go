@@ -0,0 +1,351 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Default maximum number of cached responses per table of
+// Options.ReadCacheTTLs.
+const defaultReadCacheSize = 1000
+
+// selectTable returns the table a SELECT statement reads from.
+func selectTable(query string) (string, bool) {
+	tokens := tokenizeCQL(query)
+	if len(tokens) == 0 || !tokens[0].is("select") {
+		return "", false
+	}
+	depth := 0
+	for i := 1; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			depth--
+		case depth == 0 && tokens[i].is("from"):
+			return parseTableName(tokens, i+1)
+		}
+	}
+	return "", false
+}
+
+// qualifyTable prefixes an unqualified table name with keyspace.
+func qualifyTable(table, keyspace string) string {
+	if keyspace == "" || strings.Contains(table, ".") {
+		return table
+	}
+	return strings.ToLower(keyspace) + "." + table
+}
+
+// readCacheEntry is a cached encoded response.
+type readCacheEntry struct {
+	encoded []byte
+	expires time.Time
+}
+
+// tableReadCache holds the cached responses of the reads of a table.
+type tableReadCache struct {
+	ttl     time.Duration
+	entries *lru.Cache
+	// Incremented by every invalidation, so that reads sent before an
+	// invalidation are not cached after it.
+	generation atomic.Int64
+}
+
+func (tc *tableReadCache) invalidate() {
+	tc.generation.Add(1)
+	tc.entries.Purge()
+}
+
+// cachedStatement is the table of a prepared statement on a cached table.
+type cachedStatement struct {
+	table *tableReadCache
+	read  bool
+}
+
+// readCache answers the SELECT statements of the tables of
+// Options.ReadCacheTTLs from the responses of previous identical requests.
+type readCache struct {
+	// Caches by table name, as configured.
+	tables map[string]*tableReadCache
+	// Cached statements of prepared query ids.
+	statements *lru.Cache
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+// newReadCache returns the read cache of the given tables, nil if there are
+// none.
+func newReadCache(
+	ttls map[string]time.Duration,
+	size, preparedSize int,
+) (*readCache, error) {
+	if len(ttls) == 0 {
+		return nil, nil
+	}
+	if size <= 0 {
+		size = defaultReadCacheSize
+	}
+	statements, err := lru.New(preparedSize)
+	if err != nil {
+		return nil, err
+	}
+	rc := &readCache{
+		tables:     make(map[string]*tableReadCache, len(ttls)),
+		statements: statements,
+	}
+	for table, ttl := range ttls {
+		entries, err := lru.New(size)
+		if err != nil {
+			return nil, err
+		}
+		rc.tables[strings.ToLower(table)] = &tableReadCache{
+			ttl:     ttl,
+			entries: entries,
+		}
+	}
+	return rc, nil
+}
+
+// table returns the cache of a possibly keyspace qualified table, nil if the
+// table is not cached. Unqualified configured tables match the table in any
+// keyspace.
+func (rc *readCache) table(name string) *tableReadCache {
+	if rc == nil {
+		return nil
+	}
+	name = strings.ToLower(name)
+	if tc, ok := rc.tables[name]; ok {
+		return tc
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return rc.tables[name[i+1:]]
+	}
+	return nil
+}
+
+// statement returns the cached table a statement reads from or writes to.
+func (rc *readCache) statement(query, keyspace string) (cachedStatement, bool) {
+	table, read := selectTable(query)
+	if !read {
+		var ok bool
+		if _, table, ok = parseDMLTarget(query); !ok {
+			return cachedStatement{}, false
+		}
+	}
+	tc := rc.table(qualifyTable(table, keyspace))
+	return cachedStatement{table: tc, read: read}, tc != nil
+}
+
+// invalidate drops the cached responses of a table, or of all tables if table
+// is empty. Returns false if the table is not cached.
+func (rc *readCache) invalidate(table string) bool {
+	if rc == nil {
+		return false
+	}
+	if table == "" {
+		for _, tc := range rc.tables {
+			tc.invalidate()
+		}
+		rc.invalidations.Add(1)
+		return true
+	}
+	tc := rc.table(table)
+	if tc == nil {
+		return false
+	}
+	tc.invalidate()
+	rc.invalidations.Add(1)
+	return true
+}
+
+// readCacheLookup is the read cache state of a request.
+type readCacheLookup struct {
+	// Cache of the table read by the request, nil unless its response can be
+	// cached.
+	table      *tableReadCache
+	key        string
+	generation int64
+	// Caches of the tables written by the request.
+	writes []*tableReadCache
+}
+
+// lookupReadCache returns the read cache state of a request. The caches of
+// the tables it writes are invalidated. Traced requests are always sent to
+// Spanner.
+func (dc *driverConnection) lookupReadCache(
+	frm *frame.Frame,
+	payload []byte,
+	traced bool,
+) readCacheLookup {
+	rc := dc.executor.readCache
+	var lookup readCacheLookup
+	if rc == nil {
+		return lookup
+	}
+	var stmt cachedStatement
+	var ok bool
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		keyspace := dc.keyspace
+		if msg.Options != nil && msg.Options.Keyspace != "" {
+			keyspace = msg.Options.Keyspace
+		}
+		stmt, ok = rc.statement(msg.Query, keyspace)
+	case *message.Execute:
+		var cached interface{}
+		if cached, ok = rc.statements.Get(string(msg.QueryId)); ok {
+			stmt = cached.(cachedStatement)
+		}
+	case *message.Batch:
+		for _, child := range msg.Children {
+			if child.Query != "" {
+				stmt, ok = rc.statement(child.Query, dc.keyspace)
+			} else if cached, found := rc.statements.Get(string(child.Id)); found {
+				stmt, ok = cached.(cachedStatement), true
+			} else {
+				ok = false
+			}
+			if ok && !stmt.read {
+				lookup.writes = append(lookup.writes, stmt.table)
+			}
+		}
+		dc.invalidateReadCache(lookup)
+		return lookup
+	}
+	if !ok {
+		return lookup
+	}
+	if !stmt.read {
+		lookup.writes = append(lookup.writes, stmt.table)
+		dc.invalidateReadCache(lookup)
+		return lookup
+	}
+	if traced || len(payload) < cqlHeaderLength {
+		return lookup
+	}
+	var key strings.Builder
+	key.WriteByte(payload[0])
+	key.WriteString(dc.keyspace)
+	key.WriteByte(0)
+	key.Write(payload[cqlHeaderLength:])
+	lookup.table = stmt.table
+	lookup.key = key.String()
+	lookup.generation = stmt.table.generation.Load()
+	return lookup
+}
+
+// tryServeReadCache answers a request from the read cache. Returns false if
+// the request must be sent to Spanner.
+func (dc *driverConnection) tryServeReadCache(
+	frm *frame.Frame,
+	lookup readCacheLookup,
+) bool {
+	if lookup.table == nil {
+		return false
+	}
+	// Reads the current policy rejects are answered with its error.
+	if dc.executor.tryCheckPolicy(frm) != nil {
+		return false
+	}
+	rc := dc.executor.readCache
+	cached, ok := lookup.table.entries.Get(lookup.key)
+	if !ok || time.Now().After(cached.(readCacheEntry).expires) {
+		rc.misses.Add(1)
+		return false
+	}
+	encoded := withStreamId(cached.(readCacheEntry).encoded, frm.Header.StreamId)
+	if _, err := dc.driverConn.Write(encoded); err != nil {
+		return false
+	}
+	rc.hits.Add(1)
+	return true
+}
+
+// storeReadCache caches the encoded response of a read of a cached table,
+// unless the table was invalidated since the request was sent. Only ROWS
+// results are cached.
+func (dc *driverConnection) storeReadCache(req *requestState, encoded []byte) {
+	lookup := req.readCache
+	if lookup.table == nil ||
+		lookup.table.generation.Load() != lookup.generation {
+		return
+	}
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return
+	}
+	if _, ok := frm.Body.Message.(*message.RowsResult); !ok {
+		return
+	}
+	lookup.table.entries.Add(lookup.key, readCacheEntry{
+		encoded: append([]byte(nil), encoded...),
+		expires: time.Now().Add(lookup.table.ttl),
+	})
+}
+
+// invalidateReadCache drops the cached responses of the tables written by a
+// request.
+func (dc *driverConnection) invalidateReadCache(lookup readCacheLookup) {
+	for _, tc := range lookup.writes {
+		tc.invalidate()
+		dc.executor.readCache.invalidations.Add(1)
+	}
+}
+
+// rememberCachedStatement records the cached table of the prepared query id
+// returned by the server for req.
+func (dc *driverConnection) rememberCachedStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	rc := dc.executor.readCache
+	if rc == nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	keyspace := prepare.Keyspace
+	if keyspace == "" {
+		keyspace = dc.keyspace
+	}
+	stmt, ok := rc.statement(prepare.Query, keyspace)
+	if !ok {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		rc.statements.Add(string(id), stmt)
+	}
+}
+
+// InvalidateReadCache drops the cached responses of a table of
+// Options.ReadCacheTTLs, as configured, or of all tables if table is empty.
+// Returns false if the table is not cached.
+func (proxy *TCPProxy) InvalidateReadCache(table string) bool {
+	return proxy.readCache.invalidate(table)
+}
@@ -0,0 +1,162 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectTable(t *testing.T) {
+	testCases := []struct {
+		query string
+		table string
+		ok    bool
+	}{
+		{"SELECT * FROM ks.flags WHERE id = 1", "ks.flags", true},
+		{"select count(*) from flags", "flags", true},
+		{`SELECT "from" FROM "Flags"`, "flags", true},
+		{"UPDATE flags SET a = 1 WHERE id = 1", "", false},
+	}
+	for _, tc := range testCases {
+		table, ok := selectTable(tc.query)
+		assert.Equal(t, tc.ok, ok, tc.query)
+		assert.Equal(t, tc.table, table, tc.query)
+	}
+}
+
+func TestReadCacheTables(t *testing.T) {
+	rc, err := newReadCache(nil, 0, 10)
+	require.NoError(t, err)
+	assert.Nil(t, rc)
+	assert.False(t, rc.invalidate(""))
+
+	rc, err = newReadCache(
+		map[string]time.Duration{"ks.flags": time.Minute, "settings": time.Second},
+		0,
+		10,
+	)
+	require.NoError(t, err)
+	assert.NotNil(t, rc.table("ks.flags"))
+	assert.Nil(t, rc.table("other.flags"))
+	assert.NotNil(t, rc.table("other.settings"))
+
+	stmt, ok := rc.statement("SELECT * FROM flags", "ks")
+	assert.True(t, ok)
+	assert.True(t, stmt.read)
+	stmt, ok = rc.statement("DELETE FROM ks.flags WHERE id = 1", "")
+	assert.True(t, ok)
+	assert.False(t, stmt.read)
+	_, ok = rc.statement("SELECT * FROM flags", "")
+	assert.False(t, ok)
+
+	assert.True(t, rc.invalidate("ks.flags"))
+	assert.False(t, rc.invalidate("users"))
+}
+
+func encodeFrame(t *testing.T, frm *frame.Frame) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(frm, buf))
+	return buf.Bytes()
+}
+
+func TestServeReadCache(t *testing.T) {
+	rc, err := newReadCache(map[string]time.Duration{"flags": time.Minute}, 0, 10)
+	require.NoError(t, err)
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+	dc := &driverConnection{
+		driverConn: serverConn,
+		executor:   &requestExecutor{opts: &Options{}, readCache: rc},
+		codec:      codec,
+		keyspace:   "ks",
+	}
+	query := func(streamId int16, query string) (*frame.Frame, []byte) {
+		frm := frame.NewFrame(primitive.ProtocolVersion4, streamId, &message.Query{
+			Query:   query,
+			Options: &message.QueryOptions{},
+		})
+		return frm, encodeFrame(t, frm)
+	}
+	rows := encodeFrame(t, &frame.Frame{
+		Header: &frame.Header{
+			IsResponse: true,
+			Version:    primitive.ProtocolVersion4,
+			StreamId:   1,
+			OpCode:     primitive.OpCodeResult,
+		},
+		Body: &frame.Body{Message: &message.RowsResult{
+			Metadata: &message.RowsMetadata{},
+			Data:     message.RowSet{},
+		}},
+	})
+	read := "SELECT * FROM flags WHERE id = 1"
+
+	// Traced requests are not cached.
+	frm, payload := query(1, read)
+	assert.Nil(t, dc.lookupReadCache(frm, payload, true).table)
+
+	lookup := dc.lookupReadCache(frm, payload, false)
+	require.NotNil(t, lookup.table)
+	assert.False(t, dc.tryServeReadCache(frm, lookup))
+	dc.storeReadCache(&requestState{readCache: lookup}, rows)
+
+	frm, payload = query(2, read)
+	lookup = dc.lookupReadCache(frm, payload, false)
+	served := make(chan bool)
+	go func() {
+		served <- dc.tryServeReadCache(frm, lookup)
+	}()
+	got, err := codec.DecodeFrame(clientConn)
+	require.NoError(t, err)
+	assert.True(t, <-served)
+	assert.Equal(t, int16(2), got.Header.StreamId)
+	assert.IsType(t, &message.RowsResult{}, got.Body.Message)
+
+	// Other bound values miss.
+	frm, payload = query(3, "SELECT * FROM flags WHERE id = 2")
+	assert.False(t, dc.tryServeReadCache(frm, dc.lookupReadCache(frm, payload, false)))
+
+	// Reads sent before a write are not cached, and writes drop the cached
+	// responses.
+	frm, payload = query(4, read)
+	stale := dc.lookupReadCache(frm, payload, false)
+	frm, payload = query(5, "UPDATE flags SET enabled = true WHERE id = 1")
+	write := dc.lookupReadCache(frm, payload, false)
+	assert.Nil(t, write.table)
+	assert.Len(t, write.writes, 1)
+	dc.storeReadCache(&requestState{readCache: stale}, rows)
+	frm, payload = query(6, read)
+	assert.False(t, dc.tryServeReadCache(frm, dc.lookupReadCache(frm, payload, false)))
+
+	assert.Equal(t, int64(1), rc.hits.Load())
+	assert.Equal(t, int64(3), rc.misses.Load())
+	assert.Equal(t, int64(1), rc.invalidations.Load())
+}
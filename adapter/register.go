@@ -0,0 +1,111 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// RegisterPreparedStatements prepares queries on the database of
+// Options.DatabaseUri, as drivers connected to keyspace would, and pins their
+// prepared query ids and PREPARE responses in the global state. Pinned
+// entries are exempt from eviction, so that the drivers preparing these
+// queries are answered by the proxy and never receive Unprepared errors for
+// them.
+func (proxy *TCPProxy) RegisterPreparedStatements(
+	ctx context.Context,
+	keyspace string,
+	queries []string,
+) error {
+	return proxy.registerStatements(ctx, keyspace, queries, false)
+}
+
+// RegisterIdempotentStatements registers queries like
+// RegisterPreparedStatements, and declares them idempotent: their executions
+// are retried on any retryable error, like reads. Writes not declared
+// idempotent are only retried when the error guarantees they were not
+// executed.
+func (proxy *TCPProxy) RegisterIdempotentStatements(
+	ctx context.Context,
+	keyspace string,
+	queries []string,
+) error {
+	return proxy.registerStatements(ctx, keyspace, queries, true)
+}
+
+func (proxy *TCPProxy) registerStatements(
+	ctx context.Context,
+	keyspace string,
+	queries []string,
+	idempotent bool,
+) error {
+	if _, ok := proxy.opts.Protocol.(StreamProtocol); ok {
+		return fmt.Errorf("prepared statements are not supported by the protocol of the proxy")
+	}
+	client, server := net.Pipe()
+	defer client.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = client.SetDeadline(deadline)
+	}
+
+	connectionID := int(proxy.nextConnectionID.Add(1) - 1)
+	proxy.client.stats.connectionOpened()
+	dc := proxy.newDriverConnection(server, connectionID, &listenerRoute{
+		client: proxy.client,
+		labels: proxy.opts.ConnectionLabels,
+	})
+	dc.keyspace = keyspace
+	go dc.handleConnection(ctx)
+
+	codec := frame.NewCodec()
+	for i, query := range queries {
+		req := frame.NewFrame(
+			primitive.ProtocolVersion4,
+			int16(i%0x7fff),
+			&message.Prepare{Query: query},
+		)
+		if err := codec.EncodeFrame(req, client); err != nil {
+			return fmt.Errorf("failed to send PREPARE of %q: %w", query, err)
+		}
+		resp, err := codec.DecodeFrame(client)
+		if err != nil {
+			return fmt.Errorf("failed to receive PREPARE response of %q: %w", query, err)
+		}
+		prepared, ok := resp.Body.Message.(*message.PreparedResult)
+		if !ok {
+			return fmt.Errorf("failed to prepare %q: %v", query, resp.Body.Message)
+		}
+		if !proxy.globalState.pin(preparedQueryIdAttachmentPrefix + string(prepared.PreparedQueryId)) {
+			return fmt.Errorf("prepared query id of %q is unknown to the global state", query)
+		}
+		if idempotent {
+			proxy.idempotent.declare(query, prepared.PreparedQueryId)
+		}
+		// Statements whose PREPARE response is not cached, like the ones
+		// setting a TTL, are still answered by the server.
+		proxy.globalState.pin(
+			preparedResultKey(req.Header.Version, dc.database(), keyspace, query),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterPreparedStatements(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{PreparedCacheSize: 4})
+	var prepares int
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		frm, err := codec.DecodeFrame(bytes.NewReader(req.Payload))
+		require.NoError(t, err)
+		prepare, ok := frm.Body.Message.(*message.Prepare)
+		if !ok {
+			buf := bytes.NewBuffer(nil)
+			require.NoError(t, codec.EncodeFrame(frame.NewFrame(
+				frm.Header.Version,
+				frm.Header.StreamId,
+				&message.SetKeyspaceResult{Keyspace: "ks"},
+			), buf))
+			return &Mock_Payload_AdaptMessageClient{payload: buf.Bytes()}, nil
+		}
+		prepares++
+		query := prepare.Query
+		return &Mock_Payload_AdaptMessageClient{
+			payload: encodePreparedResult(t, frm.Header.StreamId, []byte(query)),
+			stateUpdates: map[string]string{
+				preparedQueryIdAttachmentPrefix + query: "hashed",
+			},
+		}, nil
+	}
+
+	queries := []string{"SELECT * FROM t WHERE id = ?", "DELETE FROM t WHERE id = ?"}
+	require.NoError(t, proxy.RegisterPreparedStatements(context.Background(), "ks", queries))
+	assert.Equal(t, 2, prepares)
+
+	// Pinned statements survive the eviction of every other entry.
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		proxy.globalState.Store(key, key)
+	}
+	_, found := proxy.globalState.Load(preparedQueryIdAttachmentPrefix + queries[0])
+	assert.True(t, found)
+
+	// Drivers preparing them are answered by the proxy.
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	writeFrame(t, conn, 7, &message.Query{Query: "USE ks", Options: &message.QueryOptions{}})
+	_, err = codec.DecodeFrame(conn)
+	require.NoError(t, err)
+	writeFrame(t, conn, 8, &message.Prepare{Query: queries[1]})
+	got, err := codec.DecodeFrame(conn)
+	require.NoError(t, err)
+	assert.Equal(t, int16(8), got.Header.StreamId)
+	require.IsType(t, &message.PreparedResult{}, got.Body.Message)
+	assert.Equal(t, []byte(queries[1]), got.Body.Message.(*message.PreparedResult).PreparedQueryId)
+	assert.Equal(t, 2, prepares)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	protocolsMu sync.RWMutex
+	protocols   = make(map[string]Protocol)
+)
+
+// RegisterProtocol makes a protocol available by name through
+// Options.ProtocolName. Protocols implemented outside this module must
+// implement StreamProtocol. RegisterProtocol panics if p is nil or if a
+// protocol with the same name is already registered, it is typically called
+// from the init function of the package implementing the protocol.
+func RegisterProtocol(p Protocol) {
+	if p == nil {
+		panic("adapter: RegisterProtocol protocol is nil")
+	}
+	protocolsMu.Lock()
+	defer protocolsMu.Unlock()
+	if _, dup := protocols[p.Name()]; dup {
+		panic("adapter: RegisterProtocol called twice for protocol " + p.Name())
+	}
+	protocols[p.Name()] = p
+}
+
+// LookupProtocol returns the protocol registered under name.
+func LookupProtocol(name string) (Protocol, bool) {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+	p, ok := protocols[name]
+	return p, ok
+}
+
+// Protocols returns the sorted names of the registered protocols.
+func Protocols() []string {
+	protocolsMu.RLock()
+	defer protocolsMu.RUnlock()
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,109 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lineProtocol is a StreamProtocol whose requests and replies are single
+// lines.
+type lineProtocol struct{}
+
+func (p *lineProtocol) Name() string {
+	return "test-line"
+}
+
+func (p *lineProtocol) ReadRequest(r *bufio.Reader) ([]byte, error) {
+	return r.ReadBytes('\n')
+}
+
+func (p *lineProtocol) IsDML(request []byte) bool {
+	return false
+}
+
+func (p *lineProtocol) ErrorResponse(_ []byte, err error) []byte {
+	return []byte("error\n")
+}
+
+func (p *lineProtocol) Attachments(
+	request []byte,
+	state StateReader,
+) (map[string]string, error) {
+	if string(request) == "reject\n" {
+		return nil, errors.New("rejected")
+	}
+	return nil, nil
+}
+
+func TestRegisterProtocol(t *testing.T) {
+	RegisterProtocol(&lineProtocol{})
+	p, ok := LookupProtocol("test-line")
+	assert.True(t, ok)
+	assert.IsType(t, &lineProtocol{}, p)
+	assert.Contains(t, Protocols(), "test-line")
+	assert.Panics(t, func() { RegisterProtocol(&lineProtocol{}) })
+	assert.Panics(t, func() { RegisterProtocol(nil) })
+
+	_, err := NewTCPProxy(Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		ProtocolName:  "unknown",
+		GoogleApiOpts: SkipAuthOpts,
+	})
+	assert.ErrorContains(t, err, "unknown protocol")
+
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	MockPayloadAdaptMessageGrpc("test-line", func(payload []byte) ([]byte, error) {
+		return payload, nil
+	})
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		TCPEndpoint:   "localhost:0",
+		ProtocolName:  "test-line",
+		GoogleApiOpts: SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	_, err = conn.Write([]byte("hello\n"))
+	require.NoError(t, err)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+
+	// Requests rejected by the protocol never reach Spanner.
+	_, err = conn.Write([]byte("reject\n"))
+	require.NoError(t, err)
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "error\n", line)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+)
+
+// preparedStatement is the statement a prepared query id was issued for.
+type preparedStatement struct {
+	keyspace string
+	query    string
+}
+
+// preparedStatements remembers the statements of prepared query ids, so that
+// they can be prepared again once their ids are evicted from the global state.
+type preparedStatements struct {
+	cache *lru.Cache
+}
+
+func newPreparedStatements(size int) (*preparedStatements, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &preparedStatements{cache: cache}, nil
+}
+
+func (ps *preparedStatements) remember(id []byte, stmt preparedStatement) {
+	if ps != nil {
+		ps.cache.Add(string(id), stmt)
+	}
+}
+
+func (ps *preparedStatements) lookup(id []byte) (preparedStatement, bool) {
+	if ps == nil {
+		return preparedStatement{}, false
+	}
+	stmt, ok := ps.cache.Get(string(id))
+	if !ok {
+		return preparedStatement{}, false
+	}
+	return stmt.(preparedStatement), true
+}
+
+// rememberPreparedStatement records the statement of the prepared query id
+// returned by the server for req.
+func (dc *driverConnection) rememberPreparedStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	if dc.executor.statements == nil {
+		return
+	}
+	prepare, ok := req.frame.Body.Message.(*message.Prepare)
+	if !ok {
+		return
+	}
+	id, ok := dc.preparedQueryId(encoded)
+	if !ok {
+		return
+	}
+	keyspace := prepare.Keyspace
+	if keyspace == "" {
+		keyspace = dc.keyspace
+	}
+	dc.executor.statements.remember(
+		id,
+		preparedStatement{keyspace: keyspace, query: prepare.Query},
+	)
+}
+
+// unknownBatchIds returns the distinct prepared query ids of a batch that are
+// not known to the global state.
+func (re *requestExecutor) unknownBatchIds(batch *message.Batch) [][]byte {
+	var unknown [][]byte
+	seen := make(map[string]bool)
+	for _, child := range batch.Children {
+		if child.Query != "" || seen[string(child.Id)] {
+			continue
+		}
+		seen[string(child.Id)] = true
+		if _, found := re.globalState.Load(
+			preparedQueryIdAttachmentPrefix + string(child.Id),
+		); !found {
+			unknown = append(unknown, child.Id)
+		}
+	}
+	return unknown
+}
+
+// reprepareBatch prepares again all the unknown prepared statements of a
+// batch whose statement is remembered, so that the batch is not rejected for
+// ids evicted from the global state. Statements that can not be prepared again
+// are left for prepareCassandraAttachments to report as Unprepared.
+func (dc *driverConnection) reprepareBatch(
+	ctx context.Context,
+	client *AdapterClient,
+	sessionName string,
+	frm *frame.Frame,
+) {
+	batch, ok := frm.Body.Message.(*message.Batch)
+	if !ok || dc.executor.statements == nil {
+		return
+	}
+	for _, id := range dc.executor.unknownBatchIds(batch) {
+		stmt, found := dc.executor.statements.lookup(id)
+		if !found {
+			continue
+		}
+		if err := dc.reprepare(ctx, client, sessionName, frm.Header, id, stmt); err != nil {
+			logger.Warn("Failed to prepare batch statement again",
+				zap.Int("connectionID", dc.connectionID),
+				zap.Error(err))
+		}
+	}
+}
+
+// reprepare sends a PREPARE request for stmt, whose prepared query id is
+// expected to be id, and applies the state updates of its response.
+func (dc *driverConnection) reprepare(
+	ctx context.Context,
+	client *AdapterClient,
+	sessionName string,
+	header *frame.Header,
+	id []byte,
+	stmt preparedStatement,
+) error {
+	prepare := &message.Prepare{Query: stmt.query}
+	if header.Version >= primitive.ProtocolVersion5 {
+		prepare.Keyspace = stmt.keyspace
+	} else if stmt.keyspace != dc.keyspace {
+		return fmt.Errorf(
+			"statement was prepared in keyspace %q, connection uses %q",
+			stmt.keyspace,
+			dc.keyspace,
+		)
+	}
+	frm := frame.NewFrame(header.Version, header.StreamId, prepare)
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(frm, buf); err != nil {
+		return err
+	}
+	req := &requestState{
+		pb: &adapterpb.AdaptMessageRequest{
+			Name:     sessionName,
+			Protocol: dc.protocol.Name(),
+			Payload:  buf.Bytes(),
+		},
+		frame:       *frm,
+		affinityKey: dc.connectionID,
+		client:      client,
+	}
+	start := time.Now()
+	pbCli, ch, err := dc.executor.submit(dc.labelContext(ctx), req, false)
+	if err != nil {
+		recordCompletion(req.metrics, err)
+		return err
+	}
+	payload, err := dc.receiveGrpcResponse(pbCli, nil)
+	dc.adapterClient.recordResult(ch, time.Since(start), err)
+	recordCompletion(req.metrics, err)
+	if err != nil {
+		return err
+	}
+	got, ok := dc.preparedQueryId(payload)
+	if !ok {
+		return fmt.Errorf("unexpected response to PREPARE of %x", id)
+	}
+	dc.rememberPreparedStatement(req, payload)
+	if !bytes.Equal(got, id) {
+		return fmt.Errorf("statement of %x was prepared as %x", id, got)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBatchFrame(ids ...string) *frame.Frame {
+	batch := &message.Batch{Type: primitive.BatchTypeLogged}
+	for _, id := range ids {
+		batch.Children = append(batch.Children, &message.BatchChild{Id: []byte(id)})
+	}
+	return frame.NewFrame(primitive.ProtocolVersion4, 1, batch)
+}
+
+func TestUnknownBatchIds(t *testing.T) {
+	state, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	state.Store(preparedQueryIdAttachmentPrefix+"known", "hashed_query")
+	re := &requestExecutor{globalState: state}
+
+	batch := newBatchFrame("a", "known", "b", "a").Body.Message.(*message.Batch)
+	batch.Children = append(batch.Children, &message.BatchChild{Query: "INSERT"})
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, re.unknownBatchIds(batch))
+}
+
+func TestReprepareBatch(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	var prepared []string
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		frm, err := codec.DecodeFrame(bytes.NewBuffer(req.Payload))
+		require.NoError(t, err)
+		query := frm.Body.Message.(*message.Prepare).Query
+		prepared = append(prepared, query)
+		return &Mock_Payload_AdaptMessageClient{
+			payload: encodePreparedResult(t, frm.Header.StreamId, []byte(query)),
+			stateUpdates: map[string]string{
+				preparedQueryIdAttachmentPrefix + query: "hashed_query",
+			},
+		}, nil
+	}
+	pool, err := newChannelPool(context.Background(), Options{}, SkipAuthOpts)
+	require.NoError(t, err)
+	defer pool.close()
+	state, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	statements, err := newPreparedStatements(10)
+	require.NoError(t, err)
+	client := &AdapterClient{channels: pool}
+	dc := &driverConnection{
+		protocol:      &lineProtocol{},
+		adapterClient: client,
+		globalState:   state,
+		codec:         codec,
+		keyspace:      "ks",
+		executor: &requestExecutor{
+			client:      client,
+			globalState: state,
+			opts:        &Options{},
+			statements:  statements,
+		},
+	}
+
+	// Statements prepared through the proxy are remembered.
+	dc.rememberPreparedStatement(
+		&requestState{frame: *newPrepareFrame(1, "INSERT a")},
+		encodePreparedResult(t, 1, []byte("INSERT a")),
+	)
+	statements.remember([]byte("INSERT b"), preparedStatement{keyspace: "other", query: "INSERT b"})
+
+	frm := newBatchFrame("INSERT a", "INSERT b", "INSERT c")
+	dc.reprepareBatch(context.Background(), nil, "session", frm)
+
+	// Only the statement prepared in the keyspace of the connection could be
+	// prepared again.
+	assert.Equal(t, []string{"INSERT a"}, prepared)
+	req := &requestState{pb: &adapterpb.AdaptMessageRequest{}}
+	errMsg := dc.executor.prepareCassandraAttachments(frm, req)
+	require.IsType(t, &message.Unprepared{}, errMsg)
+	assert.Equal(t, []byte("INSERT b"), errMsg.(*message.Unprepared).Id)
+	assert.Contains(t, req.pb.Attachments, preparedQueryIdAttachmentPrefix+"INSERT a")
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// requestIDHeader is the name of the metadata header identifying an
+	// AdaptMessage attempt, so that failures can be correlated with the logs
+	// of Spanner.
+	requestIDHeader = "x-goog-spanner-request-id"
+	// Version of the format of request ids.
+	requestIDVersion = 1
+)
+
+var (
+	// Random identifier of the process, shared by all its clients.
+	processID = newProcessID()
+	// Number of clients created by the process.
+	clientCount atomic.Uint32
+)
+
+func newProcessID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%016x", 0)
+	}
+	return fmt.Sprintf("%016x", binary.BigEndian.Uint64(b[:]))
+}
+
+// requestID returns the id of an attempt of a request, in the format of the
+// Spanner client libraries:
+// <version>.<process id>.<client id>.<channel id>.<request number>.<attempt>
+func requestID(clientID uint32, channelID int, request uint64, attempt int) string {
+	return fmt.Sprintf(
+		"%d.%s.%d.%d.%d.%d",
+		requestIDVersion,
+		processID,
+		clientID,
+		channelID,
+		request,
+		attempt,
+	)
+}
@@ -0,0 +1,104 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequestID(t *testing.T) {
+	id := requestID(3, 1, 42, 2)
+	parts := strings.Split(id, ".")
+	require.Len(t, parts, 6)
+	assert.Equal(t, "1", parts[0])
+	assert.Equal(t, processID, parts[1])
+	assert.Len(t, parts[1], 16)
+	assert.Equal(t, []string{"3", "1", "42", "2"}, parts[2:])
+}
+
+func TestSubmitSendsRequestID(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	var sent []string
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		sent = append(sent, md.Get(requestIDHeader)...)
+		if len(sent) == 1 {
+			return nil, status.Error(codes.Unavailable, "unavailable")
+		}
+		return &Mock_Payload_AdaptMessageClient{}, nil
+	}
+	pool, err := newChannelPool(context.Background(), Options{}, SkipAuthOpts)
+	require.NoError(t, err)
+	defer pool.close()
+	client := &AdapterClient{channels: pool, clientID: 7}
+	executor := &requestExecutor{client: client, opts: &Options{}}
+
+	req := &requestState{
+		pb:    &adapterpb.AdaptMessageRequest{},
+		frame: *newMessageFrame(&message.Query{Query: "SELECT * FROM t"}),
+	}
+	_, _, err = executor.submit(context.Background(), req, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		requestID(7, 0, 1, 1),
+		requestID(7, 0, 1, 2),
+	}, sent)
+	assert.Equal(t, sent[1], req.requestID)
+
+	// The next request gets the next number.
+	_, _, err = executor.submit(context.Background(), req, false)
+	require.NoError(t, err)
+	assert.Equal(t, requestID(7, 0, 2, 1), req.requestID)
+}
+
+func TestRequestErrorMessageCarriesRequestID(t *testing.T) {
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{opts: Options{DatabaseUri: "db"}},
+	}
+	req := &requestState{requestID: requestID(1, 0, 5, 1)}
+	msg, ok := dc.requestErrorMessage(
+		req,
+		status.Error(codes.Internal, "internal"),
+	).(*message.ServerError)
+	require.True(t, ok)
+	assert.Contains(
+		t,
+		msg.ErrorMessage,
+		fmt.Sprintf("request_id = %q", req.requestID),
+	)
+	spannerErr, ok := ParseSpannerError(msg.ErrorMessage)
+	require.True(t, ok)
+	assert.Equal(t, req.requestID, spannerErr.RequestID)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// statementHash returns the hex encoded 64 bits FNV-1a hash of a statement.
+func statementHash(query string) string {
+	h := fnv.New64a()
+	h.Write([]byte(query))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// statementTable returns the table a statement reads or writes, as named by
+// the statement.
+func statementTable(query string) (string, bool) {
+	if table, ok := selectTable(query); ok {
+		return table, true
+	}
+	return writeTarget(query)
+}
+
+// requestStatement returns the statement of req, if known: the query of QUERY
+// and PREPARE requests, or the statement prepared through the proxy of
+// EXECUTE requests with EnableBatchReprepare.
+func (re *requestExecutor) requestStatement(req *requestState) string {
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Query:
+		return msg.Query
+	case *message.Prepare:
+		return msg.Query
+	case *message.Execute:
+		if stmt, ok := re.statements.lookup(msg.QueryId); ok {
+			return stmt.query
+		}
+	}
+	return ""
+}
+
+// requestLogFields returns the fields describing req in the errors logged
+// for it. The text of the statement, whose literals may carry user data, is
+// only added with debug logging, redacted according to the log redaction
+// policy.
+func (dc *driverConnection) requestLogFields(req *requestState) []zap.Field {
+	fields := []zap.Field{
+		zap.Int("connectionID", dc.connectionID),
+		zap.String("requestID", req.requestID),
+		zap.Int("attempts", req.attempts),
+	}
+	frm := &req.frame
+	if frm.Header == nil || frm.Body == nil {
+		return fields
+	}
+	fields = append(fields,
+		zap.String("opcode", frm.Header.OpCode.String()),
+		zap.Int16("stream_id", frm.Header.StreamId),
+	)
+	switch msg := frm.Body.Message.(type) {
+	case *message.Execute:
+		fields = append(fields, zap.String("pqid", hex.EncodeToString(msg.QueryId)))
+	case *message.Batch:
+		fields = append(fields, zap.Int("batch_statements", len(msg.Children)))
+	}
+	query := dc.executor.requestStatement(req)
+	if query == "" {
+		return fields
+	}
+	fields = append(fields, zap.String("statement_hash", statementHash(query)))
+	if table, ok := statementTable(query); ok {
+		fields = append(fields, zap.String("table", table))
+	}
+	if !logger.DebugEnabled() {
+		return fields
+	}
+	return append(fields, zap.String("statement", logger.RedactStatement(query)))
+}
@@ -0,0 +1,91 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// logFields returns the encoded values of fields, by key.
+func logFields(fields []zap.Field) map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(enc)
+	}
+	return enc.Fields
+}
+
+func TestRequestLogFields(t *testing.T) {
+	query := "UPDATE ks.users SET name = 'alice' WHERE id = 1"
+	statements, err := newPreparedStatements(10)
+	require.NoError(t, err)
+	statements.remember([]byte("Wid"), preparedStatement{query: query})
+	dc := &driverConnection{
+		connectionID: 3,
+		executor:     &requestExecutor{opts: &Options{}, statements: statements},
+	}
+	frm := newMessageFrame(&message.Query{Query: query})
+	frm.Header.StreamId = 12
+	req := &requestState{frame: *frm, requestID: "1.2.3.4.5.2", attempts: 2}
+
+	fields := logFields(dc.requestLogFields(req))
+	assert.Equal(t, int64(3), fields["connectionID"])
+	assert.Equal(t, "1.2.3.4.5.2", fields["requestID"])
+	assert.Equal(t, int64(2), fields["attempts"])
+	assert.Equal(t, primitive.OpCodeQuery.String(), fields["opcode"])
+	assert.Equal(t, int16(12), fields["stream_id"])
+	assert.Equal(t, statementHash(query), fields["statement_hash"])
+	assert.Equal(t, "ks.users", fields["table"])
+
+	// The statement text is only logged with debug logging.
+	require.NoError(t, logger.SetupGlobalLogger("info"))
+	assert.NotContains(t, logFields(dc.requestLogFields(req)), "statement")
+	require.NoError(t, logger.SetupGlobalLogger("debug"))
+	defer logger.SetupGlobalLogger("info")
+	fields = logFields(dc.requestLogFields(req))
+	assert.Equal(t, query, fields["statement"])
+
+	// Literals are redacted according to the log redaction policy.
+	defer logger.SetRedactionPolicy(logger.RedactionPolicy{})
+	logger.SetRedactionPolicy(logger.RedactionPolicy{Mode: logger.RedactDrop})
+	fields = logFields(dc.requestLogFields(req))
+	assert.NotContains(t, fields["statement"], "alice")
+	assert.Equal(t, statementHash(query), fields["statement_hash"])
+
+	// The statements of prepared query ids are known with EnableBatchReprepare.
+	req.frame = *newMessageFrame(&message.Execute{QueryId: []byte("Wid")})
+	fields = logFields(dc.requestLogFields(req))
+	assert.Equal(t, "576964", fields["pqid"])
+	assert.Equal(t, "ks.users", fields["table"])
+
+	// Statements of other prepared query ids are unknown.
+	req.frame = *newMessageFrame(&message.Execute{QueryId: []byte("Wother")})
+	fields = logFields(dc.requestLogFields(req))
+	assert.NotContains(t, fields, "statement_hash")
+	assert.NotContains(t, fields, "statement")
+}
@@ -18,7 +18,9 @@ package adapter
 
 import (
 	"context"
+	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
@@ -37,10 +39,17 @@ var DefaultRetryBackoff = gax.Backoff{
 	Multiplier: 1.3,
 }
 
+// Default number of retries a driver connection can make beyond
+// Options.RetryBudgetRatio.
+const defaultRetryBudgetBurst = 10
+
 // spannerRetryer extends the generic gax Retryer, but also checks for any
 // retry info returned by Cloud Spanner and uses that if present.
 type adapterRetryer struct {
 	gax.Retryer
+	// Fraction of the retry delays returned by Cloud Spanner added as random
+	// jitter.
+	jitter float64
 }
 
 // onCodes returns a adapterRetryer that will retry on the specified error
@@ -88,7 +97,7 @@ func (r *adapterRetryer) Retry(err error) (time.Duration, bool) {
 		return 0, false
 	}
 	if serverDelay, hasServerDelay := ExtractRetryDelay(err); hasServerDelay {
-		delay = serverDelay
+		delay = serverDelay + time.Duration(rand.Float64()*r.jitter*float64(serverDelay))
 	}
 	return delay, true
 }
@@ -129,6 +138,70 @@ func RunCreateAdapterSessionWithRetry(
 	return funcWithRetry(ctx)
 }
 
+// retryBudget is a token bucket limiting the retries of a driver connection:
+// every request deposits ratio tokens and every retry withdraws one, so that
+// retries can't amplify the load of Spanner when most requests fail.
+type retryBudget struct {
+	mu     sync.Mutex
+	tokens float64
+	ratio  float64
+	// Maximum number of tokens, which the bucket starts with.
+	burst float64
+}
+
+// newRetryBudget returns the retry budget of a connection, nil if ratio is
+// not positive.
+func newRetryBudget(ratio float64, burst int) *retryBudget {
+	if ratio <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = defaultRetryBudgetBurst
+	}
+	return &retryBudget{tokens: float64(burst), ratio: ratio, burst: float64(burst)}
+}
+
+// deposit adds the tokens of a request. No-op on a nil budget.
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.tokens+b.ratio, b.burst)
+}
+
+// withdraw takes the token of a retry. Returns false if the budget is
+// exhausted. Always succeeds on a nil budget.
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// adaptMessageRetry configures the retries of an AdaptMessage call.
+type adaptMessageRetry struct {
+	disabled bool
+	codes    []codes.Code
+	// Whether the request may be retried after errors that don't guarantee
+	// it was not executed.
+	idempotent bool
+	backoff    gax.Backoff
+	// Fraction of the retry delays returned by Cloud Spanner added as random
+	// jitter.
+	jitter float64
+	// Retry budget of the connection, nil if unlimited.
+	budget *retryBudget
+	stats  *proxyStats
+}
+
 // RunAdaptMessageWithRetry executes the provided function with a retry
 // mechanism based
 // on the given policy.
@@ -137,12 +210,26 @@ func RunAdaptMessageWithRetry(
 	disableRetry bool,
 	f func(ctx context.Context) (adapterpb.Adapter_AdaptMessageClient, error),
 ) (adapterpb.Adapter_AdaptMessageClient, error) {
-	retryer := onCodes(
-		DefaultRetryBackoff,
-		codes.ResourceExhausted,
-		codes.Internal,
-		codes.Unavailable,
-	)
+	return runAdaptMessageWithRetry(ctx, adaptMessageRetry{
+		disabled:   disableRetry,
+		codes:      []codes.Code{codes.ResourceExhausted, codes.Internal, codes.Unavailable},
+		idempotent: true,
+		backoff:    DefaultRetryBackoff,
+	}, f)
+}
+
+// runAdaptMessageWithRetry is RunAdaptMessageWithRetry retrying as configured
+// by policy. Requests that are not idempotent are only retried on errors
+// guaranteeing they were not executed.
+func runAdaptMessageWithRetry(
+	ctx context.Context,
+	policy adaptMessageRetry,
+	f func(ctx context.Context) (adapterpb.Adapter_AdaptMessageClient, error),
+) (adapterpb.Adapter_AdaptMessageClient, error) {
+	retryer := &adapterRetryer{
+		Retryer: gax.OnCodes(policy.codes, policy.backoff),
+		jitter:  policy.jitter,
+	}
 	funcWithRetry := func(ctx context.Context) (adapterpb.Adapter_AdaptMessageClient, error) {
 		for {
 			resp, err := f(ctx)
@@ -151,13 +238,20 @@ func RunAdaptMessageWithRetry(
 			}
 			_, ok := status.FromError(err)
 			// Only retry on valid grpc status errors
-			if !ok || disableRetry {
+			if !ok || policy.disabled {
+				return nil, err
+			}
+			if !policy.idempotent && !failedBeforeExecution(err) {
 				return nil, err
 			}
 			delay, shouldRetry := retryer.Retry(err)
 			if !shouldRetry {
 				return nil, err
 			}
+			if !policy.budget.withdraw() {
+				policy.stats.recordRetryBudgetExhausted()
+				return nil, err
+			}
 			if err := gax.Sleep(ctx, delay); err != nil {
 				return nil, err
 			}
@@ -0,0 +1,97 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestRetryBudget(t *testing.T) {
+	assert.Nil(t, newRetryBudget(0, 5))
+	assert.True(t, (*retryBudget)(nil).withdraw())
+
+	budget := newRetryBudget(0.5, 2)
+	assert.True(t, budget.withdraw())
+	assert.True(t, budget.withdraw())
+	assert.False(t, budget.withdraw())
+
+	// Two requests earn one retry.
+	budget.deposit()
+	assert.False(t, budget.withdraw())
+	budget.deposit()
+	assert.True(t, budget.withdraw())
+
+	// Deposits are capped by the burst.
+	for i := 0; i < 10; i++ {
+		budget.deposit()
+	}
+	assert.True(t, budget.withdraw())
+	assert.True(t, budget.withdraw())
+	assert.False(t, budget.withdraw())
+}
+
+func TestRetryBudgetExhausted(t *testing.T) {
+	stats := newProxyStats()
+	attempts := 0
+	_, err := runAdaptMessageWithRetry(
+		context.Background(),
+		adaptMessageRetry{
+			codes:      []codes.Code{codes.Unavailable},
+			idempotent: true,
+			backoff:    gax.Backoff{Initial: time.Millisecond},
+			budget:     newRetryBudget(0.1, 2),
+			stats:      stats,
+		},
+		func(ctx context.Context) (adapterpb.Adapter_AdaptMessageClient, error) {
+			attempts++
+			return nil, status.Error(codes.Unavailable, "unavailable")
+		},
+	)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, int64(1), stats.snapshot().RetryBudgetExhausted)
+}
+
+func TestRetryJitter(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "unavailable").WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(100 * time.Millisecond)},
+	)
+	assert.NoError(t, err)
+	for _, jitter := range []float64{0, 0.5} {
+		retryer := &adapterRetryer{
+			Retryer: gax.OnCodes([]codes.Code{codes.Unavailable}, gax.Backoff{}),
+			jitter:  jitter,
+		}
+		delay, ok := retryer.Retry(st.Err())
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, time.Duration((1+jitter)*float64(100*time.Millisecond)))
+	}
+}
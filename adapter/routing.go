@@ -0,0 +1,254 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// tableRouter sends the statements on the tables of Options.TableRouting to
+// the clients of their databases.
+type tableRouter struct {
+	// Clients of the routed tables, by lower cased keyspace qualified name.
+	tables map[string]*AdapterClient
+	// Clients of the databases prepared query ids were prepared on.
+	statements *lru.Cache
+}
+
+// withDatabase returns a client of another database of the same endpoint,
+// sharing the channels and counters of cl, with a session of its own.
+func (cl *AdapterClient) withDatabase(
+	ctx context.Context,
+	databaseUri string,
+) (*AdapterClient, error) {
+	opts := cl.opts
+	opts.DatabaseUri = databaseUri
+	routed := &AdapterClient{
+		opts:      opts,
+		channels:  cl.channels,
+		md:        clientMetadata(databaseUri, cl.clientUID),
+		stats:     cl.stats,
+		traces:    cl.traces,
+		clientUID: cl.clientUID,
+		metrics:   cl.metrics,
+		clientID:  clientCount.Add(1),
+	}
+	if err := routed.createSession(ctx, opts); err != nil {
+		return nil, fmt.Errorf(
+			"failed to create session of database %s: %w",
+			databaseUri,
+			err,
+		)
+	}
+	return routed, nil
+}
+
+// databaseClients holds the clients of the databases served by a proxy, which
+// share the channels of the client of Options.DatabaseUri.
+type databaseClients struct {
+	client *AdapterClient
+	byUri  map[string]*AdapterClient
+}
+
+func newDatabaseClients(cl *AdapterClient) *databaseClients {
+	return &databaseClients{
+		client: cl,
+		byUri:  map[string]*AdapterClient{cl.opts.DatabaseUri: cl},
+	}
+}
+
+// get returns the client of a database, creating its session if needed. An
+// empty uri stands for Options.DatabaseUri.
+func (dbs *databaseClients) get(
+	ctx context.Context,
+	databaseUri string,
+) (*AdapterClient, error) {
+	if databaseUri == "" {
+		return dbs.client, nil
+	}
+	if cl, ok := dbs.byUri[databaseUri]; ok {
+		return cl, nil
+	}
+	if _, _, _, err := parseDatabaseName(databaseUri); err != nil {
+		return nil, err
+	}
+	cl, err := dbs.client.withDatabase(ctx, databaseUri)
+	if err != nil {
+		return nil, err
+	}
+	dbs.byUri[databaseUri] = cl
+	return cl, nil
+}
+
+// all returns the clients of every database.
+func (dbs *databaseClients) all() []*AdapterClient {
+	clients := make([]*AdapterClient, 0, len(dbs.byUri))
+	for _, cl := range dbs.byUri {
+		clients = append(clients, cl)
+	}
+	return clients
+}
+
+// newTableRouter creates a session of every database of opts.TableRouting.
+// Returns nil if opts.TableRouting is empty.
+func newTableRouter(
+	ctx context.Context,
+	dbs *databaseClients,
+	opts Options,
+) (*tableRouter, error) {
+	if len(opts.TableRouting) == 0 {
+		return nil, nil
+	}
+	statements, err := lru.New(opts.PreparedCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	router := &tableRouter{
+		tables:     make(map[string]*AdapterClient, len(opts.TableRouting)),
+		statements: statements,
+	}
+	for table, databaseUri := range opts.TableRouting {
+		if !strings.Contains(table, ".") {
+			return nil, fmt.Errorf(
+				"table %q of TableRouting is not qualified by its keyspace",
+				table,
+			)
+		}
+		routed, err := dbs.get(ctx, databaseUri)
+		if err != nil {
+			return nil, err
+		}
+		router.tables[strings.ToLower(table)] = routed
+	}
+	return router, nil
+}
+
+// statementClient returns the client of the database of the table a
+// statement reads or writes, qualified by keyspace if the statement does not
+// name it, or fallback if the table is not routed.
+func (tr *tableRouter) statementClient(
+	query, keyspace string,
+	fallback *AdapterClient,
+) *AdapterClient {
+	table, ok := selectTable(query)
+	if !ok {
+		table, ok = writeTarget(query)
+	}
+	if !ok {
+		return fallback
+	}
+	if cl, ok := tr.tables[qualifyTable(table, keyspace)]; ok {
+		return cl
+	}
+	return fallback
+}
+
+// preparedClient returns the client of the database a prepared query id was
+// prepared on.
+func (tr *tableRouter) preparedClient(id []byte) (*AdapterClient, bool) {
+	cl, ok := tr.statements.Get(string(id))
+	if !ok {
+		return nil, false
+	}
+	return cl.(*AdapterClient), true
+}
+
+// routeRequest returns the client of the database serving the statements of
+// frm, the client of the connection unless Options.TableRouting routes their
+// tables to another database. Returns an Unprepared error message for
+// prepared query ids of unknown database, and an Invalid error message for
+// batches spanning several databases.
+func (dc *driverConnection) routeRequest(
+	frm *frame.Frame,
+) (*AdapterClient, message.Message) {
+	tr := dc.executor.router
+	if tr == nil {
+		return dc.adapterClient, nil
+	}
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		return tr.statementClient(msg.Query, dc.keyspace, dc.adapterClient), nil
+	case *message.Prepare:
+		keyspace := msg.Keyspace
+		if keyspace == "" {
+			keyspace = dc.keyspace
+		}
+		return tr.statementClient(msg.Query, keyspace, dc.adapterClient), nil
+	case *message.Execute:
+		cl, ok := tr.preparedClient(msg.QueryId)
+		if !ok {
+			return nil, &message.Unprepared{
+				ErrorMessage: "Unknown database of prepared query in client side cache",
+				Id:           msg.QueryId,
+			}
+		}
+		return cl, nil
+	case *message.Batch:
+		var routed *AdapterClient
+		for _, child := range msg.Children {
+			cl := dc.adapterClient
+			if child.Query != "" {
+				cl = tr.statementClient(child.Query, dc.keyspace, dc.adapterClient)
+			} else if prepared, ok := tr.preparedClient(child.Id); ok {
+				cl = prepared
+			} else {
+				return nil, &message.Unprepared{
+					ErrorMessage: "Unknown database of prepared query in client side cache",
+					Id:           child.Id,
+				}
+			}
+			if routed != nil && cl != routed {
+				return nil, &message.Invalid{
+					ErrorMessage: fmt.Sprintf(
+						"Batch refers to tables of databases %s and %s, batches must only refer to tables of a single database",
+						routed.opts.DatabaseUri,
+						cl.opts.DatabaseUri,
+					),
+				}
+			}
+			routed = cl
+		}
+		if routed != nil {
+			return routed, nil
+		}
+	}
+	return dc.adapterClient, nil
+}
+
+// rememberRoutedStatement records the database of the prepared query id
+// returned by the server for req.
+func (dc *driverConnection) rememberRoutedStatement(
+	req *requestState,
+	encoded []byte,
+) {
+	tr := dc.executor.router
+	if tr == nil || req.client == nil {
+		return
+	}
+	if _, ok := req.frame.Body.Message.(*message.Prepare); !ok {
+		return
+	}
+	if id, ok := dc.preparedQueryId(encoded); ok {
+		tr.statements.Add(string(id), req.client)
+	}
+}
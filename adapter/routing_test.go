@@ -0,0 +1,194 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	testDefaultDatabase = "projects/p/instances/i/databases/legacy"
+	testOrdersDatabase  = "projects/p/instances/i/databases/orders"
+)
+
+func newTestRouter(t *testing.T) (*tableRouter, *AdapterClient, *AdapterClient) {
+	t.Helper()
+	statements, err := lru.New(10)
+	require.NoError(t, err)
+	legacy := &AdapterClient{
+		opts: Options{DatabaseUri: testDefaultDatabase},
+		md:   clientMetadata(testDefaultDatabase, "uid"),
+	}
+	orders := &AdapterClient{
+		opts: Options{DatabaseUri: testOrdersDatabase},
+		md:   clientMetadata(testOrdersDatabase, "uid"),
+	}
+	return &tableRouter{
+		tables:     map[string]*AdapterClient{"shop.orders": orders},
+		statements: statements,
+	}, legacy, orders
+}
+
+func TestRouteQuery(t *testing.T) {
+	router, legacy, orders := newTestRouter(t)
+	dc := &driverConnection{
+		adapterClient: legacy,
+		executor:      &requestExecutor{router: router},
+	}
+
+	for query, want := range map[string]*AdapterClient{
+		"SELECT * FROM shop.orders WHERE id = 1":       orders,
+		"INSERT INTO shop.ORDERS (id) VALUES (1)":      orders,
+		"SELECT * FROM orders WHERE id = 1":            legacy,
+		"SELECT * FROM shop.users WHERE id = 1":        legacy,
+		"CREATE KEYSPACE shop WITH replication = {}":   legacy,
+		"SELECT * FROM system.local WHERE key='local'": legacy,
+	} {
+		got, errMsg := dc.routeRequest(newMessageFrame(&message.Query{Query: query}))
+		require.Nil(t, errMsg, query)
+		assert.Same(t, want, got, query)
+	}
+
+	// Unqualified tables are qualified by the keyspace of the connection.
+	dc.keyspace = "shop"
+	got, errMsg := dc.routeRequest(
+		newMessageFrame(&message.Query{Query: "SELECT * FROM orders WHERE id = 1"}),
+	)
+	require.Nil(t, errMsg)
+	assert.Same(t, orders, got)
+}
+
+func TestRoutePreparedStatements(t *testing.T) {
+	router, legacy, orders := newTestRouter(t)
+	dc := &driverConnection{
+		adapterClient: legacy,
+		executor:      &requestExecutor{router: router},
+		codec:         frame.NewCodec(),
+	}
+
+	prepare := newPrepareFrame(1, "SELECT * FROM shop.orders WHERE id = ?")
+	client, errMsg := dc.routeRequest(prepare)
+	require.Nil(t, errMsg)
+	assert.Same(t, orders, client)
+	dc.rememberRoutedStatement(
+		&requestState{frame: *prepare, client: client},
+		encodePreparedResult(t, 1, []byte("orders")),
+	)
+	dc.rememberRoutedStatement(
+		&requestState{frame: *newPrepareFrame(1, "SELECT * FROM users"), client: legacy},
+		encodePreparedResult(t, 1, []byte("users")),
+	)
+
+	got, errMsg := dc.routeRequest(newMessageFrame(&message.Execute{QueryId: []byte("orders")}))
+	require.Nil(t, errMsg)
+	assert.Same(t, orders, got)
+
+	// Ids of unknown database must be prepared again.
+	_, errMsg = dc.routeRequest(newMessageFrame(&message.Execute{QueryId: []byte("other")}))
+	require.IsType(t, &message.Unprepared{}, errMsg)
+	assert.Equal(t, []byte("other"), errMsg.(*message.Unprepared).Id)
+
+	batch := &message.Batch{
+		Type: primitive.BatchTypeLogged,
+		Children: []*message.BatchChild{
+			{Id: []byte("orders")},
+			{Query: "UPDATE shop.orders SET total = 1 WHERE id = 2"},
+		},
+	}
+	got, errMsg = dc.routeRequest(newMessageFrame(batch))
+	require.Nil(t, errMsg)
+	assert.Same(t, orders, got)
+
+	// Batches can not span databases.
+	batch.Children = append(batch.Children, &message.BatchChild{Id: []byte("users")})
+	_, errMsg = dc.routeRequest(newMessageFrame(batch))
+	require.IsType(t, &message.Invalid{}, errMsg)
+	assert.Contains(t, errMsg.(*message.Invalid).ErrorMessage, testDefaultDatabase)
+}
+
+func TestRouteWithoutTableRouting(t *testing.T) {
+	client := &AdapterClient{}
+	dc := &driverConnection{adapterClient: client, executor: &requestExecutor{}}
+	got, errMsg := dc.routeRequest(newMessageFrame(&message.Execute{QueryId: []byte("id")}))
+	require.Nil(t, errMsg)
+	assert.Same(t, client, got)
+}
+
+func TestNewTableRouterValidatesTables(t *testing.T) {
+	cl := &AdapterClient{opts: Options{DatabaseUri: testDefaultDatabase}}
+
+	router, err := newTableRouter(context.Background(), newDatabaseClients(cl), Options{})
+	require.NoError(t, err)
+	assert.Nil(t, router)
+
+	_, err = newTableRouter(context.Background(), newDatabaseClients(cl), Options{
+		PreparedCacheSize: 10,
+		TableRouting:      map[string]string{"orders": testOrdersDatabase},
+	})
+	assert.ErrorContains(t, err, "not qualified")
+
+	_, err = newTableRouter(context.Background(), newDatabaseClients(cl), Options{
+		PreparedCacheSize: 10,
+		TableRouting:      map[string]string{"shop.orders": "orders"},
+	})
+	assert.Error(t, err)
+}
+
+func TestSubmitSendsRoutedDatabase(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	var prefixes []string
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		prefixes = append(prefixes, md.Get(resourcePrefixHeader)...)
+		return &Mock_Payload_AdaptMessageClient{}, nil
+	}
+	pool, err := newChannelPool(context.Background(), Options{}, SkipAuthOpts)
+	require.NoError(t, err)
+	defer pool.close()
+	router, legacy, orders := newTestRouter(t)
+	legacy.channels, legacy.stats = pool, newProxyStats()
+	orders.channels, orders.stats = pool, newProxyStats()
+	executor := &requestExecutor{client: legacy, opts: &Options{}, router: router}
+
+	for _, client := range []*AdapterClient{nil, orders} {
+		req := &requestState{
+			pb:     &adapterpb.AdaptMessageRequest{},
+			frame:  *newMessageFrame(&message.Query{Query: "SELECT * FROM t"}),
+			client: client,
+		}
+		_, _, err = executor.submit(context.Background(), req, false)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{testDefaultDatabase, testOrdersDatabase}, prefixes)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"strconv"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// tryRequestRowCount asks Spanner to report the number of rows affected by DML
+// requests with Options.EnableRowCountPayload.
+func (re *requestExecutor) tryRequestRowCount(
+	frame *frame.Frame, attachments map[string]string,
+) {
+	if !re.opts.EnableRowCountPayload ||
+		frame.Header.Version < primitive.ProtocolVersion4 ||
+		!isDML(frame) {
+		return
+	}
+	attachments[returnRowCount] = "true"
+}
+
+// recordRowCount records the number of affected rows reported by a state
+// update of the response of req. Returns false if the state update does not
+// carry a row count.
+func recordRowCount(req *requestState, key, value string) bool {
+	if key != rowCountStateKey {
+		return false
+	}
+	if req == nil {
+		return true
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		req.rowCount = value
+	}
+	return true
+}
+
+// attachRowCount adds the number of rows affected by req to the custom payload
+// of the encoded response, if Spanner reported it.
+func (dc *driverConnection) attachRowCount(
+	req *requestState,
+	encoded []byte,
+) []byte {
+	if req.rowCount == "" {
+		return encoded
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		payload := map[string][]byte{RowCountPayloadKey: []byte(req.rowCount)}
+		for k, v := range frm.Body.CustomPayload {
+			if _, ok := payload[k]; !ok {
+				payload[k] = v
+			}
+		}
+		frm.SetCustomPayload(payload)
+	})
+}
@@ -0,0 +1,117 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTryRequestRowCount(t *testing.T) {
+	re := &requestExecutor{opts: &Options{EnableRowCountPayload: true}}
+	tests := []struct {
+		name    string
+		version primitive.ProtocolVersion
+		msg     message.Message
+		want    bool
+	}{
+		{
+			name:    "update",
+			version: primitive.ProtocolVersion4,
+			msg:     &message.Query{Query: "UPDATE t SET v = 1 WHERE k = 1"},
+			want:    true,
+		},
+		{
+			name:    "batch",
+			version: primitive.ProtocolVersion4,
+			msg:     &message.Batch{},
+			want:    true,
+		},
+		{
+			name:    "prepared dml",
+			version: primitive.ProtocolVersion4,
+			msg:     &message.Execute{QueryId: []byte("Wid")},
+			want:    true,
+		},
+		{
+			name:    "select",
+			version: primitive.ProtocolVersion4,
+			msg:     &message.Query{Query: "SELECT * FROM t"},
+		},
+		{
+			name:    "protocol v3",
+			version: primitive.ProtocolVersion3,
+			msg:     &message.Query{Query: "DELETE FROM t WHERE k = 1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attachments := map[string]string{}
+			re.tryRequestRowCount(frame.NewFrame(tt.version, 0, tt.msg), attachments)
+			_, found := attachments[returnRowCount]
+			assert.Equal(t, tt.want, found)
+		})
+	}
+
+	re.opts.EnableRowCountPayload = false
+	attachments := map[string]string{}
+	re.tryRequestRowCount(
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Batch{}),
+		attachments,
+	)
+	assert.Empty(t, attachments)
+}
+
+func TestRecordRowCount(t *testing.T) {
+	req := &requestState{}
+	assert.False(t, recordRowCount(req, "pqid/id", "SELECT 1"))
+	assert.True(t, recordRowCount(req, rowCountStateKey, "invalid"))
+	assert.Empty(t, req.rowCount)
+	assert.True(t, recordRowCount(req, rowCountStateKey, "3"))
+	assert.Equal(t, "3", req.rowCount)
+	assert.True(t, recordRowCount(nil, rowCountStateKey, "3"))
+}
+
+func TestAttachRowCount(t *testing.T) {
+	dc := &driverConnection{codec: codec}
+	response := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{})
+	response.Header.IsResponse = true
+	response.SetCustomPayload(map[string][]byte{"key": []byte("value")})
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(response, buf))
+	encoded := buf.Bytes()
+
+	// Responses are unchanged unless Spanner reported a row count.
+	req := &requestState{}
+	assert.Equal(t, encoded, dc.attachRowCount(req, encoded))
+
+	req.rowCount = "2"
+	got, err := codec.DecodeFrame(bytes.NewBuffer(dc.attachRowCount(req, encoded)))
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(got.Body.CustomPayload[RowCountPayloadKey]))
+	assert.Equal(t, "value", string(got.Body.CustomPayload["key"]))
+	assert.IsType(t, &message.VoidResult{}, got.Body.Message)
+}
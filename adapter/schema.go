@@ -0,0 +1,280 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/api/option"
+)
+
+var (
+	// QuerySchemaColumns returns the columns of the tables of the default
+	// schema of a database, ordered by table and position.
+	QuerySchemaColumns = func(
+		ctx context.Context,
+		databaseUri string,
+		clientOpts []option.ClientOption,
+	) ([]SchemaColumn, error) {
+		client, err := spanner.NewClientWithConfig(
+			ctx,
+			databaseUri,
+			spanner.ClientConfig{
+				SessionPoolConfig: spanner.SessionPoolConfig{MinOpened: 1},
+			},
+			clientOpts...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		stmt := spanner.Statement{
+			SQL: `SELECT c.TABLE_NAME, c.COLUMN_NAME, c.SPANNER_TYPE, o.OPTION_VALUE
+				FROM INFORMATION_SCHEMA.COLUMNS c
+				LEFT JOIN INFORMATION_SCHEMA.COLUMN_OPTIONS o
+				ON o.TABLE_SCHEMA = c.TABLE_SCHEMA
+				AND o.TABLE_NAME = c.TABLE_NAME
+				AND o.COLUMN_NAME = c.COLUMN_NAME
+				AND o.OPTION_NAME = 'cassandra_type'
+				WHERE c.TABLE_SCHEMA = ''
+				ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`,
+		}
+		var columns []SchemaColumn
+		err = client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+			var column SchemaColumn
+			var cassandraType spanner.NullString
+			err := row.Columns(
+				&column.Table,
+				&column.Column,
+				&column.SpannerType,
+				&cassandraType,
+			)
+			if err != nil {
+				return err
+			}
+			column.CassandraType = strings.Trim(cassandraType.StringVal, `"'`)
+			columns = append(columns, column)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return columns, nil
+	}
+)
+
+// Spanner types each CQL type can be stored as, without length.
+var cassandraTypeMappings = map[string][]string{
+	"ascii":     {"STRING"},
+	"bigint":    {"INT64"},
+	"blob":      {"BYTES"},
+	"boolean":   {"BOOL"},
+	"counter":   {"INT64"},
+	"date":      {"DATE"},
+	"decimal":   {"NUMERIC"},
+	"double":    {"FLOAT64"},
+	"float":     {"FLOAT32", "FLOAT64"},
+	"inet":      {"STRING"},
+	"int":       {"INT64"},
+	"smallint":  {"INT64"},
+	"text":      {"STRING"},
+	"time":      {"INT64"},
+	"timestamp": {"TIMESTAMP"},
+	"timeuuid":  {"STRING"},
+	"tinyint":   {"INT64"},
+	"uuid":      {"STRING"},
+	"varchar":   {"STRING"},
+	"varint":    {"NUMERIC"},
+}
+
+// SchemaColumn is a column of a Spanner table together with its cassandra_type
+// option, if any.
+type SchemaColumn struct {
+	Table       string
+	Column      string
+	SpannerType string
+	// Value of the cassandra_type option, empty if the option is not set.
+	CassandraType string
+}
+
+// SchemaIssue describes a column that can not be used through CQL.
+type SchemaIssue struct {
+	SchemaColumn
+	// Description of the problem.
+	Problem string
+}
+
+// SchemaReport holds the outcome of ValidateSchema.
+type SchemaReport struct {
+	Keyspace string
+	// Number of tables validated.
+	Tables int
+	// Number of columns validated.
+	Columns int
+	// Columns that can not be used through CQL, ordered by table and position.
+	Issues []SchemaIssue
+}
+
+// Valid reports whether all columns can be used through CQL.
+func (r SchemaReport) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+// String formats the report with one line per issue.
+func (r SchemaReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(
+		&sb,
+		"keyspace %s: %d tables, %d columns, %d issues\n",
+		r.Keyspace,
+		r.Tables,
+		r.Columns,
+		len(r.Issues),
+	)
+	for _, issue := range r.Issues {
+		fmt.Fprintf(
+			&sb,
+			"%s.%s (%s): %s\n",
+			issue.Table,
+			issue.Column,
+			issue.SpannerType,
+			issue.Problem,
+		)
+	}
+	return sb.String()
+}
+
+// ValidateSchema reads the schema of the keyspace of opts.DatabaseUri and
+// reports the columns that are missing a cassandra_type option, or whose
+// cassandra_type can not be stored in their Spanner type.
+func ValidateSchema(
+	ctx context.Context,
+	opts Options,
+	keyspace string,
+) (SchemaReport, error) {
+	_, _, database, err := parseDatabaseName(opts.DatabaseUri)
+	if err != nil {
+		return SchemaReport{}, err
+	}
+	if keyspace != database {
+		return SchemaReport{}, fmt.Errorf(
+			"keyspace %q does not match database %q",
+			keyspace,
+			database,
+		)
+	}
+	clientOpts, err := getAllClientOpts(opts)
+	if err != nil {
+		return SchemaReport{}, err
+	}
+	columns, err := QuerySchemaColumns(ctx, opts.DatabaseUri, clientOpts)
+	if err != nil {
+		return SchemaReport{}, err
+	}
+	return validateColumns(keyspace, columns), nil
+}
+
+// validateColumns checks the cassandra_type option of each column.
+func validateColumns(keyspace string, columns []SchemaColumn) SchemaReport {
+	report := SchemaReport{Keyspace: keyspace, Columns: len(columns)}
+	tables := make(map[string]bool)
+	for _, column := range columns {
+		tables[column.Table] = true
+		problem := ""
+		if column.CassandraType == "" {
+			problem = "missing cassandra_type option"
+		} else {
+			problem = checkTypeMapping(column.CassandraType, column.SpannerType)
+		}
+		if problem != "" {
+			report.Issues = append(report.Issues, SchemaIssue{
+				SchemaColumn: column,
+				Problem:      problem,
+			})
+		}
+	}
+	report.Tables = len(tables)
+	return report
+}
+
+// checkTypeMapping returns a description of why values of cassandraType can
+// not be stored in spannerType, or an empty string if they can.
+func checkTypeMapping(cassandraType, spannerType string) string {
+	cqlType := strings.ToLower(strings.TrimSpace(cassandraType))
+	if inner, ok := cutGeneric(cqlType, "frozen"); ok {
+		cqlType = inner
+	}
+	spannerBase := spannerBaseType(spannerType)
+	if inner, ok := cutGeneric(cqlType, "list"); ok {
+		return checkCollectionMapping(cassandraType, inner, spannerType)
+	}
+	if inner, ok := cutGeneric(cqlType, "set"); ok {
+		return checkCollectionMapping(cassandraType, inner, spannerType)
+	}
+	if _, ok := cutGeneric(cqlType, "map"); ok {
+		if spannerBase != "JSON" {
+			return fmt.Sprintf("cassandra_type %s requires JSON", cassandraType)
+		}
+		return ""
+	}
+	allowed, ok := cassandraTypeMappings[cqlType]
+	if !ok {
+		return fmt.Sprintf("unsupported cassandra_type %s", cassandraType)
+	}
+	for _, t := range allowed {
+		if t == spannerBase {
+			return ""
+		}
+	}
+	return fmt.Sprintf(
+		"cassandra_type %s requires %s",
+		cassandraType,
+		strings.Join(allowed, " or "),
+	)
+}
+
+// checkCollectionMapping checks a list or set of element type elem, which is
+// stored as an ARRAY.
+func checkCollectionMapping(cassandraType, elem, spannerType string) string {
+	element, ok := cutGeneric(strings.TrimSpace(spannerType), "ARRAY")
+	if !ok {
+		return fmt.Sprintf("cassandra_type %s requires ARRAY", cassandraType)
+	}
+	if problem := checkTypeMapping(elem, element); problem != "" {
+		return fmt.Sprintf("element of %s", problem)
+	}
+	return ""
+}
+
+// cutGeneric returns the parameter of a type of the form name<parameter>.
+func cutGeneric(t, name string) (string, bool) {
+	rest, ok := strings.CutPrefix(t, name+"<")
+	if !ok || !strings.HasSuffix(rest, ">") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimSuffix(rest, ">")), true
+}
+
+// spannerBaseType returns a Spanner type without its length (ie: STRING for
+// STRING(MAX)).
+func spannerBaseType(spannerType string) string {
+	base, _, _ := strings.Cut(strings.TrimSpace(spannerType), "(")
+	return strings.ToUpper(base)
+}
@@ -0,0 +1,106 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestCheckTypeMapping(t *testing.T) {
+	tests := []struct {
+		cassandraType string
+		spannerType   string
+		valid         bool
+	}{
+		{"bigint", "INT64", true},
+		{"int", "INT64", true},
+		{"text", "STRING(MAX)", true},
+		{"varchar", "STRING(1024)", true},
+		{"uuid", "STRING(36)", true},
+		{"float", "FLOAT32", true},
+		{"double", "FLOAT64", true},
+		{"decimal", "NUMERIC", true},
+		{"timestamp", "TIMESTAMP", true},
+		{"blob", "BYTES(MAX)", true},
+		{"list<int>", "ARRAY<INT64>", true},
+		{"frozen<set<text>>", "ARRAY<STRING(MAX)>", true},
+		{"map<text, int>", "JSON", true},
+		{"int", "STRING(MAX)", false},
+		{"timestamp", "DATE", false},
+		{"list<int>", "INT64", false},
+		{"set<uuid>", "ARRAY<INT64>", false},
+		{"map<text, int>", "STRING(MAX)", false},
+		{"duration", "INT64", false},
+		{"tuple<int, text>", "STRING(MAX)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cassandraType+"/"+tt.spannerType, func(t *testing.T) {
+			problem := checkTypeMapping(tt.cassandraType, tt.spannerType)
+			assert.Equal(t, tt.valid, problem == "", problem)
+		})
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	orig := QuerySchemaColumns
+	t.Cleanup(func() { QuerySchemaColumns = orig })
+	QuerySchemaColumns = func(
+		ctx context.Context,
+		databaseUri string,
+		clientOpts []option.ClientOption,
+	) ([]SchemaColumn, error) {
+		return []SchemaColumn{
+			{"orders", "id", "STRING(MAX)", "uuid"},
+			{"orders", "total", "NUMERIC", "decimal"},
+			{"users", "id", "INT64", "bigint"},
+			{"users", "name", "STRING(MAX)", ""},
+			{"users", "tags", "ARRAY<STRING(MAX)>", "set<int>"},
+		}, nil
+	}
+	opts := Options{
+		DatabaseUri:   "projects/test/instances/test/databases/ks",
+		GoogleApiOpts: SkipAuthOpts,
+	}
+
+	report, err := ValidateSchema(context.Background(), opts, "ks")
+	require.NoError(t, err)
+
+	assert.False(t, report.Valid())
+	assert.Equal(t, 2, report.Tables)
+	assert.Equal(t, 5, report.Columns)
+	require.Len(t, report.Issues, 2)
+	assert.Equal(t, "name", report.Issues[0].Column)
+	assert.Equal(t, "missing cassandra_type option", report.Issues[0].Problem)
+	assert.Equal(t, "tags", report.Issues[1].Column)
+	assert.Equal(
+		t,
+		"element of cassandra_type int requires INT64",
+		report.Issues[1].Problem,
+	)
+	assert.Contains(t, report.String(), "users.name (STRING(MAX))")
+
+	_, err = ValidateSchema(context.Background(), opts, "other")
+	assert.Error(t, err)
+}
@@ -0,0 +1,310 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+	"google.golang.org/api/option/internaloption"
+	htransport "google.golang.org/api/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const (
+	// Default endpoint of the Secret Manager REST API.
+	secretManagerEndpoint = "https://secretmanager.googleapis.com/"
+	cloudPlatformScope    = "https://www.googleapis.com/auth/cloud-platform"
+	// Time given to every read of a secret.
+	secretAccessTimeout = 30 * time.Second
+)
+
+// SecretRefs names the Secret Manager secret versions (ie:
+// "projects/p/secrets/s/versions/latest") options are read from, so that
+// containerized proxies do not need credentials or certificates baked into
+// their images. Secrets are read with the application default credentials.
+type SecretRefs struct {
+	// Optional secret holding the JSON credentials (ie: a service account
+	// key) used to reach Spanner. Defaults to empty.
+	Credentials string
+	// Optional secrets holding the PEM CA certificate, client certificate and
+	// client key of the TLS connections to experimental hosts, in place of
+	// the files of CaCertificate, ClientCertificate and ClientKey. Defaults
+	// to empty.
+	CaCertificate     string
+	ClientCertificate string
+	ClientKey         string
+	// Optional secret holding the JSON StatementPolicy (ie: {"ReadOnly":
+	// true}), in place of Options.StatementPolicy. Defaults to empty.
+	StatementPolicy string
+	// Optional interval between the reads of the client certificate, client
+	// key and statement policy, so that their rotation applies without
+	// restarting the proxy. Secrets that can not be read keep their previous
+	// value. Defaults to 0 (read once).
+	RefreshInterval time.Duration
+}
+
+// secretStore holds the options read from Secret Manager.
+type secretStore struct {
+	refs      SecretRefs
+	access    func(ctx context.Context, name string) ([]byte, error)
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu         sync.RWMutex
+	clientCert *tls.Certificate
+}
+
+// loadSecrets reads the secrets of opts.SecretRefs, and applies them to opts.
+// Returns nil if SecretRefs is unset.
+func loadSecrets(ctx context.Context, opts *Options) (*secretStore, error) {
+	refs := opts.SecretRefs
+	if refs == nil {
+		return nil, nil
+	}
+	client, endpoint, err := htransport.NewClient(
+		ctx,
+		option.WithScopes(cloudPlatformScope),
+		internaloption.WithDefaultEndpoint(secretManagerEndpoint),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Secret Manager client: %w", err)
+	}
+	s := &secretStore{
+		refs: *refs,
+		access: func(ctx context.Context, name string) ([]byte, error) {
+			return accessSecretVersion(ctx, client, endpoint, name)
+		},
+		done: make(chan struct{}),
+	}
+	return s, s.apply(ctx, opts)
+}
+
+// apply reads the secrets of the store, and applies them to opts.
+func (s *secretStore) apply(ctx context.Context, opts *Options) error {
+	if (s.refs.ClientCertificate == "") != (s.refs.ClientKey == "") {
+		return errors.New("both client certificate and key secrets must be provided for mTLS")
+	}
+	if s.refs.ClientCertificate != "" && s.refs.CaCertificate == "" {
+		return errors.New("client certificate secret provided without CA certificate secret")
+	}
+	if s.refs.CaCertificate != "" && !opts.ExperimentalHost {
+		return errors.New("TLS secrets can only be used with experimental hosts")
+	}
+	if s.refs.Credentials != "" {
+		data, err := s.read(ctx, s.refs.Credentials)
+		if err != nil {
+			return err
+		}
+		opts.GoogleApiOpts = append(opts.GoogleApiOpts, option.WithCredentialsJSON(data))
+	}
+	if s.refs.StatementPolicy != "" {
+		policy, err := s.readPolicy(ctx)
+		if err != nil {
+			return err
+		}
+		opts.StatementPolicy = policy
+	}
+	if s.refs.CaCertificate != "" {
+		creds, err := s.transportCredentials(ctx)
+		if err != nil {
+			return err
+		}
+		// User provided options take precedence over the options of the CA
+		// certificate file.
+		opts.GoogleApiOpts = append(opts.GoogleApiOpts, creds)
+	}
+	return nil
+}
+
+// read reads the secret version name.
+func (s *secretStore) read(ctx context.Context, name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, secretAccessTimeout)
+	defer cancel()
+	data, err := s.access(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func (s *secretStore) readPolicy(ctx context.Context) (*StatementPolicy, error) {
+	data, err := s.read(ctx, s.refs.StatementPolicy)
+	if err != nil {
+		return nil, err
+	}
+	policy := &StatementPolicy{}
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("invalid statement policy secret %s: %w", s.refs.StatementPolicy, err)
+	}
+	return policy, nil
+}
+
+// readClientCertificate reads the client certificate and key secrets.
+func (s *secretStore) readClientCertificate(ctx context.Context) error {
+	cert, err := s.read(ctx, s.refs.ClientCertificate)
+	if err != nil {
+		return err
+	}
+	key, err := s.read(ctx, s.refs.ClientKey)
+	if err != nil {
+		return err
+	}
+	pair, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return fmt.Errorf("failed to load client certificate/key secrets: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clientCert = &pair
+	return nil
+}
+
+// transportCredentials returns the TLS credentials of the CA certificate and
+// client certificate secrets. Connections use the last client certificate
+// read.
+func (s *secretStore) transportCredentials(ctx context.Context) (option.ClientOption, error) {
+	ca, err := s.read(ctx, s.refs.CaCertificate)
+	if err != nil {
+		return nil, err
+	}
+	capool := x509.NewCertPool()
+	if !capool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("failed to append the CA certificate secret to CA pool")
+	}
+	config := &tls.Config{RootCAs: capool}
+	if s.refs.ClientCertificate != "" {
+		if err := s.readClientCertificate(ctx); err != nil {
+			return nil, err
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			return s.clientCert, nil
+		}
+	}
+	return option.WithGRPCDialOption(
+		grpc.WithTransportCredentials(credentials.NewTLS(config)),
+	), nil
+}
+
+// refreshesPolicy reports whether the statement policy is refreshed from
+// SecretRefs.StatementPolicy.
+func (s *secretStore) refreshesPolicy() bool {
+	return s != nil && s.refs.StatementPolicy != "" && s.refs.RefreshInterval > 0
+}
+
+// refresh reads the client certificate and statement policy secrets again,
+// updating policy in place.
+func (s *secretStore) refresh(ctx context.Context, policy *statementPolicy) error {
+	var errs []error
+	if s.refs.ClientCertificate != "" {
+		errs = append(errs, s.readClientCertificate(ctx))
+	}
+	if s.refs.StatementPolicy != "" && policy != nil {
+		updated, err := s.readPolicy(ctx)
+		if err == nil {
+			var compiled *statementPolicy
+			if compiled, err = newStatementPolicy(updated); err == nil {
+				policy.update(compiled)
+			}
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// start refreshes the secrets every RefreshInterval, until the store is
+// closed.
+func (s *secretStore) start(ctx context.Context, policy *statementPolicy) {
+	if s == nil || s.refs.RefreshInterval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.refs.RefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+			if err := s.refresh(ctx, policy); err != nil {
+				logger.Warn("Failed to refresh secrets, keeping their previous values",
+					zap.Error(err))
+			}
+		}
+	}()
+}
+
+// close stops the refresh of the secrets.
+func (s *secretStore) close() {
+	if s != nil {
+		s.closeOnce.Do(func() { close(s.done) })
+	}
+}
+
+// accessSecretVersion reads the payload of a secret version with the Secret
+// Manager REST API at endpoint.
+func accessSecretVersion(
+	ctx context.Context,
+	client *http.Client,
+	endpoint string,
+	name string,
+) ([]byte, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodGet,
+		strings.TrimSuffix(endpoint, "/")+"/v1/"+name+":access",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s", resp.Status, body)
+	}
+	var version struct {
+		Payload struct {
+			// Base64 encoded in JSON.
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return nil, err
+	}
+	return version.Payload.Data, nil
+}
@@ -0,0 +1,109 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecrets serves secret versions from memory.
+type fakeSecrets struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func (f *fakeSecrets) set(name, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.secrets[name] = value
+}
+
+func (f *fakeSecrets) access(_ context.Context, name string) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	value, ok := f.secrets[name]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", name)
+	}
+	return []byte(value), nil
+}
+
+func TestSecretStore(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSecrets{secrets: map[string]string{
+		"credentials": `{"type": "service_account"}`,
+		"policy":      `{"ReadOnly": true}`,
+	}}
+	store := &secretStore{
+		refs:   SecretRefs{Credentials: "credentials", StatementPolicy: "policy"},
+		access: fake.access,
+	}
+	opts := &Options{}
+	require.NoError(t, store.apply(ctx, opts))
+	assert.Len(t, opts.GoogleApiOpts, 1)
+	require.NotNil(t, opts.StatementPolicy)
+	assert.True(t, opts.StatementPolicy.ReadOnly)
+
+	policy, err := newStatementPolicy(opts.StatementPolicy)
+	require.NoError(t, err)
+	assert.IsType(t, &message.Unauthorized{}, policy.check("DELETE FROM ks.users WHERE id = 1"))
+
+	// Refreshed policies apply in place.
+	fake.set("policy", `{"ReadOnlyTables": ["ks.accounts"]}`)
+	require.NoError(t, store.refresh(ctx, policy))
+	assert.Nil(t, policy.check("DELETE FROM ks.users WHERE id = 1"))
+	assert.IsType(t, &message.Unauthorized{}, policy.check("DELETE FROM ks.accounts WHERE id = 1"))
+
+	// Invalid policies keep the previous one.
+	fake.set("policy", `{"Deny": ["("]}`)
+	assert.Error(t, store.refresh(ctx, policy))
+	assert.IsType(t, &message.Unauthorized{}, policy.check("DELETE FROM ks.accounts WHERE id = 1"))
+
+	// Missing secrets fail the startup.
+	store.refs.Credentials = "missing"
+	assert.Error(t, store.apply(ctx, &Options{}))
+
+	// Stores can be closed more than once.
+	store.done = make(chan struct{})
+	store.close()
+	assert.NotPanics(t, store.close)
+}
+
+func TestSecretStoreValidation(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSecrets{secrets: map[string]string{}}
+	for _, refs := range []SecretRefs{
+		{ClientCertificate: "cert"},
+		{CaCertificate: "ca", ClientCertificate: "cert"},
+		{ClientCertificate: "cert", ClientKey: "key"},
+	} {
+		store := &secretStore{refs: refs, access: fake.access}
+		assert.Error(t, store.apply(ctx, &Options{ExperimentalHost: true}), "%+v", refs)
+	}
+	store := &secretStore{refs: SecretRefs{CaCertificate: "ca"}, access: fake.access}
+	assert.ErrorContains(t, store.apply(ctx, &Options{}), "experimental hosts")
+}
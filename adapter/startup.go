@@ -0,0 +1,194 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// STARTUP option asking for Overloaded errors instead of backpressure.
+	startupThrowOnOverload = "THROW_ON_OVERLOAD"
+	// STARTUP option hiding the internal columns of compact storage tables,
+	// which Spanner tables don't have.
+	startupNoCompact = "NO_COMPACT"
+	// Prefix of the STARTUP options of the proxy. Unknown options with this
+	// prefix are rejected, as they are most likely misspelled.
+	startupSpannerOptionPrefix = "SPANNER_"
+)
+
+// knownStartupOptions are the STARTUP options sent by drivers that need no
+// handling by the proxy.
+var knownStartupOptions = map[string]bool{
+	"CQL_VERSION":         true,
+	"COMPRESSION":         true,
+	"DRIVER_NAME":         true,
+	"DRIVER_VERSION":      true,
+	"CLIENT_ID":           true,
+	"APPLICATION_NAME":    true,
+	"APPLICATION_VERSION": true,
+}
+
+// tryApplyStartupOptions records the options of a STARTUP request that change
+// how the proxy serves the connection. Returns a ProtocolError if an option
+// can not be honored, and nil otherwise. STARTUP requests are still forwarded
+// to Spanner.
+func (dc *driverConnection) tryApplyStartupOptions(frm *frame.Frame) message.Message {
+	startup, ok := frm.Body.Message.(*message.Startup)
+	if !ok {
+		return nil
+	}
+	for option, value := range startup.Options {
+		name := strings.ToUpper(option)
+		switch {
+		case name == startupThrowOnOverload:
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return invalidStartupOption(option, value)
+			}
+			dc.throwOnOverload = enabled
+		case name == startupNoCompact:
+			if _, err := strconv.ParseBool(value); err != nil {
+				return invalidStartupOption(option, value)
+			}
+		case knownStartupOptions[name],
+			strings.HasPrefix(name, StartupLabelOptionPrefix):
+		case strings.HasPrefix(name, startupSpannerOptionPrefix):
+			return &message.ProtocolError{
+				ErrorMessage: fmt.Sprintf("Unsupported STARTUP option %s", option),
+			}
+		default:
+			logger.Debug("Ignoring unknown STARTUP option",
+				zap.Int("connectionID", dc.connectionID),
+				zap.String("option", option),
+			)
+		}
+	}
+	return nil
+}
+
+// compressedStartupError returns the ProtocolError answering a STARTUP request
+// with a compressed body, and nil for other requests. STARTUP negotiates the
+// COMPRESSION option and is never compressed, so its compression algorithm is
+// unknown, but some legacy clients configured with compression compress it
+// anyway. Their frames would otherwise fail to decode with a SyntaxError.
+func compressedStartupError(header *frame.Header) message.Message {
+	if header.OpCode != primitive.OpCodeStartup ||
+		!header.Flags.Contains(primitive.HeaderFlagCompressed) {
+		return nil
+	}
+	return &message.ProtocolError{
+		ErrorMessage: "Unsupported compressed STARTUP body: STARTUP negotiates " +
+			"the COMPRESSION option and must not be compressed, disable the " +
+			"compression of the STARTUP message in the driver",
+	}
+}
+
+// startHandshakeTimer closes the connection unless it completes its handshake
+// within Options.HandshakeTimeout, so that connections left idle by clients do
+// not hold on to the resources of the proxy.
+func (dc *driverConnection) startHandshakeTimer() {
+	timeout := dc.executor.opts.HandshakeTimeout
+	if _, ok := dc.protocol.(StreamProtocol); ok || timeout <= 0 {
+		return
+	}
+	dc.handshakeMu.Lock()
+	defer dc.handshakeMu.Unlock()
+	dc.handshakeTimer = time.AfterFunc(timeout, func() {
+		dc.adapterClient.stats.recordHandshakeTimeout()
+		logger.Warn("Closing connection not completing its handshake within HandshakeTimeout",
+			zap.Int("connectionID", dc.connectionID),
+			zap.String("remote_addr", dc.driverConn.RemoteAddr().String()),
+			zap.Duration("handshake_timeout", timeout),
+		)
+		dc.driverConn.Close()
+	})
+}
+
+// stopHandshakeTimerOnReady stops the handshake timer once Spanner answers
+// STARTUP with READY, or AUTH_RESPONSE with AUTH_SUCCESS, which completes the
+// handshake. Connections answered with AUTHENTICATE or errors keep the timer
+// running.
+func (dc *driverConnection) stopHandshakeTimerOnReady(
+	req *requestState,
+	response []byte,
+) {
+	switch req.frame.Header.OpCode {
+	case primitive.OpCodeStartup, primitive.OpCodeAuthResponse:
+	default:
+		return
+	}
+	opCode, ok := responseOpCode(response)
+	if !ok {
+		return
+	}
+	if opCode == primitive.OpCodeReady || opCode == primitive.OpCodeAuthSuccess {
+		dc.handshakeMu.Lock()
+		if !dc.handshakeStart.IsZero() {
+			dc.adapterClient.stats.recordHandshake(time.Since(dc.handshakeStart))
+			dc.handshakeStart = time.Time{}
+		}
+		dc.handshakeMu.Unlock()
+		dc.stopHandshakeTimer()
+		dc.logFirstHandshake(req.frame.Header.Version)
+	}
+}
+
+// responseOpCode returns the opcode of an encoded response.
+func responseOpCode(response []byte) (primitive.OpCode, bool) {
+	if len(response) < 9 {
+		return 0, false
+	}
+	if response[0]&0x7f < byte(primitive.ProtocolVersion3) {
+		return primitive.OpCode(response[3]), true
+	}
+	return primitive.OpCode(response[4]), true
+}
+
+// stopHandshakeTimer stops the handshake timer of the connection, if any, and
+// releases its handshake slot.
+func (dc *driverConnection) stopHandshakeTimer() {
+	dc.handshakeMu.Lock()
+	defer dc.handshakeMu.Unlock()
+	if dc.handshakeTimer != nil {
+		dc.handshakeTimer.Stop()
+		dc.handshakeTimer = nil
+	}
+	if dc.releaseHandshake != nil {
+		dc.releaseHandshake()
+		dc.releaseHandshake = nil
+	}
+}
+
+func invalidStartupOption(option, value string) message.Message {
+	return &message.ProtocolError{
+		ErrorMessage: fmt.Sprintf(
+			"Invalid value %q of STARTUP option %s, expected true or false",
+			value,
+			option,
+		),
+	}
+}
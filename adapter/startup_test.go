@@ -0,0 +1,192 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTryApplyStartupOptions(t *testing.T) {
+	startup := func(options map[string]string) *frame.Frame {
+		return frame.NewFrame(
+			primitive.ProtocolVersion5,
+			0,
+			&message.Startup{Options: options},
+		)
+	}
+	dc := &driverConnection{}
+
+	assert.Nil(t, dc.tryApplyStartupOptions(startup(map[string]string{
+		"CQL_VERSION":             "3.0.0",
+		"DRIVER_NAME":             "gocql",
+		"NO_COMPACT":              "true",
+		"SPANNER_LABEL_END_USER":  "alice",
+		"SOME_FUTURE_DRIVER_FLAG": "1",
+	})))
+	assert.False(t, dc.throwOnOverload)
+
+	assert.Nil(t, dc.tryApplyStartupOptions(startup(map[string]string{
+		"THROW_ON_OVERLOAD": "true",
+	})))
+	assert.True(t, dc.throwOnOverload)
+
+	assert.IsType(t, &message.ProtocolError{},
+		dc.tryApplyStartupOptions(startup(map[string]string{
+			"THROW_ON_OVERLOAD": "yes please",
+		})))
+	assert.IsType(t, &message.ProtocolError{},
+		dc.tryApplyStartupOptions(startup(map[string]string{
+			"SPANNER_LABLE_END_USER": "alice",
+		})))
+
+	// Other requests are ignored.
+	assert.Nil(t, dc.tryApplyStartupOptions(
+		frame.NewFrame(primitive.ProtocolVersion5, 0, &message.Options{}),
+	))
+}
+
+func TestHandshakeTimerStopsOnReady(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+	dc := &driverConnection{
+		protocol:      &cqlTestProtocol{},
+		executor:      &requestExecutor{opts: &Options{HandshakeTimeout: time.Hour}},
+		adapterClient: &AdapterClient{channels: &channelPool{}},
+		driverConn:    conn,
+	}
+	dc.startHandshakeTimer()
+	require.NotNil(t, dc.handshakeTimer)
+	response := func(msg message.Message) []byte {
+		buf := &bytes.Buffer{}
+		require.NoError(t, codec.EncodeFrame(
+			frame.NewFrame(primitive.ProtocolVersion4, 0, msg),
+			buf,
+		))
+		return buf.Bytes()
+	}
+	request := func(msg message.Message) *requestState {
+		return &requestState{
+			frame: *frame.NewFrame(primitive.ProtocolVersion4, 0, msg),
+		}
+	}
+	startup := &message.Startup{Options: map[string]string{"CQL_VERSION": "3.0.0"}}
+
+	// STARTUP alone does not complete the handshake.
+	assert.Nil(t, dc.tryApplyStartupOptions(
+		frame.NewFrame(primitive.ProtocolVersion4, 0, startup),
+	))
+	assert.NotNil(t, dc.handshakeTimer)
+	dc.stopHandshakeTimerOnReady(
+		request(startup),
+		response(&message.Authenticate{Authenticator: "PasswordAuthenticator"}),
+	)
+	assert.NotNil(t, dc.handshakeTimer)
+	dc.stopHandshakeTimerOnReady(
+		request(&message.AuthResponse{}),
+		response(&message.AuthenticationError{ErrorMessage: "bad credentials"}),
+	)
+	assert.NotNil(t, dc.handshakeTimer)
+	// Responses to other requests leave the timer running.
+	dc.stopHandshakeTimerOnReady(request(&message.Options{}), response(&message.Ready{}))
+	assert.NotNil(t, dc.handshakeTimer)
+
+	dc.stopHandshakeTimerOnReady(
+		request(&message.AuthResponse{}),
+		response(&message.AuthSuccess{}),
+	)
+	assert.Nil(t, dc.handshakeTimer)
+	assert.True(t, dc.adapterClient.firstHandshake.Load())
+
+	dc.startHandshakeTimer()
+	dc.stopHandshakeTimerOnReady(request(startup), response(&message.Ready{}))
+	assert.Nil(t, dc.handshakeTimer)
+}
+
+func TestRequestErrorMessage(t *testing.T) {
+	exhausted := status.Error(codes.ResourceExhausted, "too many requests")
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{opts: Options{DatabaseUri: "db"}},
+	}
+	assert.IsType(t, &message.ServerError{}, dc.requestErrorMessage(&requestState{}, exhausted))
+
+	dc.throwOnOverload = true
+	overloaded, ok := dc.requestErrorMessage(&requestState{}, exhausted).(*message.Overloaded)
+	if assert.True(t, ok) {
+		spannerErr, ok := ParseSpannerError(overloaded.ErrorMessage)
+		assert.True(t, ok)
+		assert.Equal(t, codes.ResourceExhausted, spannerErr.Code)
+	}
+	assert.IsType(t, &message.ServerError{},
+		dc.requestErrorMessage(&requestState{}, status.Error(codes.Unavailable, "unavailable")))
+}
+
+func TestCompressedStartupRejected(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{})
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(
+		frame.NewFrame(primitive.ProtocolVersion4, 7, &message.Startup{
+			Options: map[string]string{"CQL_VERSION": "3.0.0", "COMPRESSION": "snappy"},
+		}),
+		buf,
+	))
+	encoded := buf.Bytes()
+	// Flag the body as compressed, like legacy clients compressing it.
+	encoded[1] |= byte(primitive.HeaderFlagCompressed)
+	_, err = conn.Write(encoded)
+	require.NoError(t, err)
+
+	got, err := codec.DecodeFrame(conn)
+	require.NoError(t, err)
+	assert.Equal(t, int16(7), got.Header.StreamId)
+	require.IsType(t, &message.ProtocolError{}, got.Body.Message)
+	assert.Contains(t,
+		got.Body.Message.(*message.ProtocolError).ErrorMessage,
+		"Unsupported compressed STARTUP body")
+	assert.Contains(t,
+		got.Body.Message.(*message.ProtocolError).ErrorMessage,
+		"COMPRESSION")
+
+	// Other compressed requests are left to the codec.
+	header := &frame.Header{
+		Version: primitive.ProtocolVersion4,
+		Flags:   primitive.HeaderFlagCompressed,
+		OpCode:  primitive.OpCodeQuery,
+	}
+	assert.Nil(t, compressedStartupError(header))
+	header.Flags = 0
+	header.OpCode = primitive.OpCodeStartup
+	assert.Nil(t, compressedStartupError(header))
+}
@@ -17,7 +17,14 @@ limitations under the License.
 package adapter
 
 import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
 
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	lru "github.com/hashicorp/golang-lru"
@@ -27,31 +34,347 @@ import (
 type requestState struct {
 	pb    *adapterpb.AdaptMessageRequest
 	frame frame.Frame
+	// Trace session of the request, nil unless the driver requested tracing.
+	trace *traceSession
+	// Rewrite of a prepared statement setting a TTL, nil for other requests.
+	ttl *ttlRewrite
+	// Native protocol warnings returned with the response.
+	warnings []string
+	// Time the request was read from the driver.
+	received time.Time
+	// Time the AdaptMessage request was sent.
+	sent time.Time
+	// Key of the channel the request is sent on with Options.ChannelAffinity,
+	// the id of its driver connection.
+	affinityKey int
+	// Whether the request commits an explicit transaction.
+	commit bool
+	// Number of times the request was sent again after Spanner aborted it.
+	replays int
+	// Whether the driver connection set the THROW_ON_OVERLOAD STARTUP option.
+	throwOnOverload bool
+	// Read cache state of the request.
+	readCache readCacheLookup
+	// Built-in metrics tracer of the last AdaptMessage operation of the
+	// request.
+	metrics *builtinMetricsTracer
+	// Id of the last AdaptMessage attempt of the request, sent in the
+	// x-goog-spanner-request-id header.
+	requestID string
+	// Number of AdaptMessage attempts made for the request.
+	attempts int
+	// Client of the database the request is sent to, the client of the
+	// executor if nil.
+	client *AdapterClient
+	// Retry budget of the connection of the request, nil if unlimited.
+	retryBudget *retryBudget
+	// Number of rows affected by the request as reported by Spanner, empty
+	// unless reported.
+	rowCount string
+	// Page size requested by the driver for results paged by the proxy with
+	// Options.CqlshCompatibility, zero otherwise.
+	pageSize int
+	// Whether the response written to the driver is an error.
+	errorResponse bool
+	// Page size the proxy reads the result of an unpaged read with from
+	// Spanner with Options.UnpagedReadPageSize, zero otherwise.
+	readPageSize int32
+	// Indices of the statements of each partition of a batch applied per
+	// partition, nil for other requests.
+	batchPartitions [][]int
+	// Idempotency token claimed by a batch sent with
+	// IdempotencyTokenPayloadKey, nil for other requests and once completed.
+	batchToken *batchToken
 }
 
-// globalStateEntry is a thread safe states cache maintained across all
-// requests.
+// Minimum interval between two warnings about evicted prepared query ids.
+var evictionWarningInterval = time.Minute
+
+// GlobalState stores the state updates returned by Spanner (ie: prepared query
+// ids) for all the driver connections of a proxy. Implementations must be safe
+// for concurrent use, and may evict entries: evicted prepared query ids are
+// answered with an Unprepared error, and prepared again by the driver.
+type GlobalState interface {
+	StateReader
+	// Store stores val for key.
+	Store(key string, val string)
+	// Delete removes the entry of key, if any.
+	Delete(key string)
+	// Len returns the number of entries stored.
+	Len() int
+}
+
+// globalState is a thread safe states cache maintained across all requests.
 type globalState struct {
+	// LRU cache of the typed entries, and of the other entries unless store
+	// is set.
 	cache *lru.Cache
+	// Store supplied with Options.StateStore, nil if unset.
+	store GlobalState
+
+	// Entries exempt from eviction, by key.
+	pinnedMu sync.RWMutex
+	pinned   map[string]string
+	// Keys being removed from the cache, which are not evictions.
+	removing sync.Map
+
+	// Number of entries evicted to make room for new ones.
+	evictions atomic.Int64
+	// Number of lookups of keys that were not found.
+	misses atomic.Int64
+	// Unix nanos of the last eviction warning.
+	lastEvictionWarning atomic.Int64
+
+	// Store shared with the other proxies of the database, nil unless
+	// Options.StateRedisAddress is set.
+	backend stateBackend
+	// Number of failed reads and writes of the backend.
+	backendErrors atomic.Int64
 }
 
 // NewDefaultGlobalState creates a new default prepared cache capping the max
-// item capacity to `size`.
+// item capacity to `size`. It implements GlobalState.
 func NewDefaultGlobalState(size int) (*globalState, error) {
-	cache, err := lru.New(size)
+	d := &globalState{}
+	cache, err := lru.NewWithEvict(size, d.onEvicted)
 	if err != nil {
 		return nil, err
 	}
-	return &globalState{cache}, nil
+	d.cache = cache
+	return d, nil
+}
+
+// newGlobalState returns the global state of a proxy: store if set, or the
+// default cache of Options.PreparedCacheSize entries.
+func newGlobalState(opts Options) (*globalState, error) {
+	d, err := NewDefaultGlobalState(opts.PreparedCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	d.store = opts.StateStore
+	return d, nil
+}
+
+func (d *globalState) onEvicted(key interface{}, val interface{}) {
+	k, _ := key.(string)
+	if _, removing := d.removing.Load(k); removing {
+		return
+	}
+	d.pinnedMu.RLock()
+	_, pinned := d.pinned[k]
+	d.pinnedMu.RUnlock()
+	if pinned {
+		// Removed from the cache when pinned.
+		return
+	}
+	if entry, ok := val.(*typedEntry); ok && entry.expired(time.Now()) {
+		return
+	}
+	d.evictions.Add(1)
+	if !strings.HasPrefix(k, preparedQueryIdAttachmentPrefix) {
+		return
+	}
+	// Evicted prepared query ids surface as Unprepared errors to the driver,
+	// warn about it without flooding the logs.
+	now := time.Now().UnixNano()
+	last := d.lastEvictionWarning.Load()
+	if now-last < int64(evictionWarningInterval) ||
+		!d.lastEvictionWarning.CompareAndSwap(last, now) {
+		return
+	}
+	logger.Info(
+		"Prepared query ids are being evicted from the global state cache, "+
+			"consider increasing PreparedCacheSize",
+		zap.Int("cache_size", d.cache.Len()),
+		zap.Int64("total_evictions", d.evictions.Load()),
+		zap.Int64("total_misses", d.misses.Load()),
+	)
+}
+
+func (d *globalState) Store(key string, val string) {
+	d.pinnedMu.Lock()
+	if _, ok := d.pinned[key]; ok {
+		d.pinned[key] = val
+		d.pinnedMu.Unlock()
+		return
+	}
+	d.pinnedMu.Unlock()
+	d.add(key, val)
+	if d.backend != nil {
+		if err := d.backend.set(key, val); err != nil {
+			d.backendError("write", err)
+		}
+	}
+}
+
+// pin exempts the entry of key from eviction. Returns false if key is not
+// stored.
+func (d *globalState) pin(key string) bool {
+	d.pinnedMu.Lock()
+	if _, ok := d.pinned[key]; ok {
+		d.pinnedMu.Unlock()
+		return true
+	}
+	val, ok := d.peek(key)
+	if !ok {
+		d.pinnedMu.Unlock()
+		return false
+	}
+	if d.pinned == nil {
+		d.pinned = make(map[string]string)
+	}
+	d.pinned[key] = val
+	d.pinnedMu.Unlock()
+	d.remove(key)
+	return true
+}
+
+func (d *globalState) Load(key string) (val string, ok bool) {
+	d.pinnedMu.RLock()
+	val, ok = d.pinned[key]
+	d.pinnedMu.RUnlock()
+	if ok {
+		return val, true
+	}
+	if val, ok := d.get(key); ok {
+		return val, true
+	}
+	if d.backend != nil {
+		// Stored by another proxy, or before a restart.
+		val, ok, err := d.backend.get(key)
+		if err != nil {
+			d.backendError("read", err)
+		}
+		if ok {
+			d.add(key, val)
+			return val, true
+		}
+	}
+	d.misses.Add(1)
+	return "", false
 }
 
-func (d globalState) Store(key string, val string) {
+// Delete removes the entry of key, pinned, typed or not. Entries shared
+// through Options.StateRedisAddress are kept in Redis.
+func (d *globalState) Delete(key string) {
+	d.pinnedMu.Lock()
+	delete(d.pinned, key)
+	d.pinnedMu.Unlock()
+	d.remove(key)
+	if d.store != nil {
+		d.removeCached(key)
+	}
+}
+
+// Len returns the number of entries stored, pinned, typed or not.
+func (d *globalState) Len() int {
+	d.pinnedMu.RLock()
+	n := len(d.pinned)
+	d.pinnedMu.RUnlock()
+	if d.store != nil {
+		n += d.store.Len()
+	}
+	return n + d.cache.Len()
+}
+
+// typedEntry is an entry of the global state stored with storeValue.
+type typedEntry struct {
+	val any
+	// Time the entry expires, zero if it does not expire.
+	expires time.Time
+}
+
+func (e *typedEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// storeValue stores val for key, expiring after ttl unless ttl is 0, ie:
+// metadata of prepared statements or cached system query results. Typed entries
+// share the cache and its PreparedCacheSize capacity with the other entries,
+// but are neither stored in Options.StateStore nor shared through
+// Options.StateRedisAddress, and are not returned by Load.
+func (d *globalState) storeValue(key string, val any, ttl time.Duration) {
+	entry := &typedEntry{val: val}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	d.cache.Add(key, entry)
+}
+
+// loadValue returns the value stored for key with storeValue, unless it
+// expired.
+func (d *globalState) loadValue(key string) (any, bool) {
+	val, ok := d.cache.Get(key)
+	entry, typed := val.(*typedEntry)
+	if ok && typed {
+		if !entry.expired(time.Now()) {
+			return entry.val, true
+		}
+		d.removeCached(key)
+	}
+	return nil, false
+}
+
+func (d *globalState) add(key string, val string) {
+	if d.store != nil {
+		d.store.Store(key, val)
+		return
+	}
 	d.cache.Add(key, val)
 }
 
-func (d globalState) Load(key string) (val string, ok bool) {
-	if val, ok := d.cache.Get(key); ok {
-		return val.(string), true
+func (d *globalState) get(key string) (string, bool) {
+	if d.store != nil {
+		return d.store.Load(key)
+	}
+	val, ok := d.cache.Get(key)
+	if !ok {
+		return "", false
+	}
+	// Typed entries are not returned.
+	s, ok := val.(string)
+	return s, ok
+}
+
+// peek returns the value of key without updating its recency.
+func (d *globalState) peek(key string) (string, bool) {
+	if d.store != nil {
+		return d.store.Load(key)
+	}
+	val, ok := d.cache.Peek(key)
+	if !ok {
+		return "", false
+	}
+	s, ok := val.(string)
+	return s, ok
+}
+
+func (d *globalState) remove(key string) {
+	if d.store != nil {
+		d.store.Delete(key)
+		return
+	}
+	d.removeCached(key)
+}
+
+// removeCached removes the entry of key from the cache, without counting it
+// as an eviction.
+func (d *globalState) removeCached(key string) {
+	d.removing.Store(key, struct{}{})
+	d.cache.Remove(key)
+	d.removing.Delete(key)
+}
+
+// backendError records a failed read or write of the backend. The entries
+// stay available locally, so failures are only logged at debug level.
+func (d *globalState) backendError(op string, err error) {
+	d.backendErrors.Add(1)
+	logger.Debug("Global state backend "+op+" failed", zap.Error(err))
+}
+
+// close closes the connections to the backend.
+func (d *globalState) close() {
+	if d.backend != nil {
+		d.backend.close()
 	}
-	return "nil", false
 }
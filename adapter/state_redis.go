@@ -0,0 +1,194 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// Time given to a command of the state backend, including dialing.
+	stateBackendTimeout = time.Second
+	// Maximum number of idle connections to the state backend.
+	maxIdleStateConns = 8
+)
+
+// stateBackend is a remote store of the global state shared by the proxies of
+// a fleet, ie: Redis, so that prepared query ids survive restarts and are
+// known to every proxy.
+type stateBackend interface {
+	// get returns the value of key, and false if key is not stored.
+	get(key string) (string, bool, error)
+	// set stores val for key.
+	set(key string, val string) error
+	close()
+}
+
+// redisStateBackend stores the global state in Redis or Memorystore, under
+// keys prefixed by the database of the proxy.
+type redisStateBackend struct {
+	addr     string
+	password string
+	prefix   string
+	idle     chan *redisConn
+}
+
+// redisConn is a connection to Redis.
+type redisConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func newRedisStateBackend(opts Options) *redisStateBackend {
+	if opts.StateRedisAddress == "" {
+		return nil
+	}
+	return &redisStateBackend{
+		addr:     opts.StateRedisAddress,
+		password: opts.StateRedisPassword,
+		prefix:   "spanner-cassandra/" + opts.DatabaseUri + "/",
+		idle:     make(chan *redisConn, maxIdleStateConns),
+	}
+}
+
+func (b *redisStateBackend) get(key string) (string, bool, error) {
+	reply, err := b.do("GET", b.prefix+key)
+	if err != nil || reply == nil {
+		return "", false, err
+	}
+	return string(reply), true, nil
+}
+
+func (b *redisStateBackend) set(key string, val string) error {
+	_, err := b.do("SET", b.prefix+key, val)
+	return err
+}
+
+// close closes the idle connections to Redis.
+func (b *redisStateBackend) close() {
+	for {
+		select {
+		case conn := <-b.idle:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// do sends a command to Redis and returns its bulk string reply, nil for nil
+// replies and simple string replies.
+func (b *redisStateBackend) do(args ...string) ([]byte, error) {
+	conn, err := b.conn()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(args...)
+	if err != nil && !isRedisError(err) {
+		// The connection is in an unknown state.
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case b.idle <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection to Redis, or dials a new one.
+func (b *redisStateBackend) conn() (*redisConn, error) {
+	select {
+	case conn := <-b.idle:
+		return conn, nil
+	default:
+	}
+	nc, err := net.DialTimeout("tcp", b.addr, stateBackendTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, reader: bufio.NewReader(nc)}
+	if b.password != "" {
+		if _, err := conn.do("AUTH", b.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisError is an error reply of Redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func isRedisError(err error) bool {
+	var re redisError
+	return errors.As(err, &re)
+}
+
+// do sends a command on the connection and reads its reply.
+func (c *redisConn) do(args ...string) ([]byte, error) {
+	c.SetDeadline(time.Now().Add(stateBackendTimeout))
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(buf, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := c.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	line, err := c.reader.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return nil, nil
+	case '-':
+		return nil, redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		reply := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, reply); err != nil {
+			return nil, err
+		}
+		return reply[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
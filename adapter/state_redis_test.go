@@ -0,0 +1,175 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves the GET, SET and AUTH commands of the Redis protocol.
+type fakeRedis struct {
+	password string
+
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	r := &fakeRedis{password: password, values: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r, listener.Addr().String()
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := r.password == ""
+	for {
+		args, err := readRedisCommand(reader)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		switch {
+		case args[0] == "AUTH" && args[1] == r.password:
+			authenticated = true
+			fmt.Fprint(conn, "+OK\r\n")
+		case args[0] == "AUTH":
+			fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+		case !authenticated:
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+		case args[0] == "SET":
+			r.values[args[1]] = args[2]
+			fmt.Fprint(conn, "+OK\r\n")
+		case args[0] == "GET":
+			if val, ok := r.values[args[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(val), val)
+			} else {
+				fmt.Fprint(conn, "$-1\r\n")
+			}
+		}
+		r.mu.Unlock()
+	}
+}
+
+func readRedisCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func newSharedGlobalState(t *testing.T, opts Options) *globalState {
+	t.Helper()
+	state, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	state.backend = newRedisStateBackend(opts)
+	t.Cleanup(state.close)
+	return state
+}
+
+func TestGlobalStateSharedThroughRedis(t *testing.T) {
+	redis, addr := startFakeRedis(t, "secret")
+	opts := Options{
+		DatabaseUri:        "projects/p/instances/i/databases/d",
+		StateRedisAddress:  addr,
+		StateRedisPassword: "secret",
+	}
+	first := newSharedGlobalState(t, opts)
+	second := newSharedGlobalState(t, opts)
+
+	first.Store("pqid/abc", "SELECT * FROM t")
+	val, ok := second.Load("pqid/abc")
+	require.True(t, ok)
+	assert.Equal(t, "SELECT * FROM t", val)
+	assert.Zero(t, second.misses.Load())
+
+	redis.mu.Lock()
+	assert.Equal(t, "SELECT * FROM t",
+		redis.values["spanner-cassandra/projects/p/instances/i/databases/d/pqid/abc"])
+	redis.mu.Unlock()
+
+	_, ok = second.Load("pqid/unknown")
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), second.misses.Load())
+	assert.Zero(t, first.backendErrors.Load())
+	assert.Zero(t, second.backendErrors.Load())
+}
+
+func TestGlobalStateRedisUnavailable(t *testing.T) {
+	_, addr := startFakeRedis(t, "secret")
+	// Wrong password: every command fails.
+	state := newSharedGlobalState(t, Options{
+		StateRedisAddress:  addr,
+		StateRedisPassword: "wrong",
+	})
+
+	state.Store("key", "val")
+	val, ok := state.Load("key")
+	require.True(t, ok)
+	assert.Equal(t, "val", val)
+
+	_, ok = state.Load("other")
+	assert.False(t, ok)
+	assert.Equal(t, int64(2), state.backendErrors.Load())
+}
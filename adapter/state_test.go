@@ -19,7 +19,11 @@ limitations under the License.
 
 package adapter
 
-import "testing"
+import (
+	"sync"
+	"testing"
+	"time"
+)
 
 func TestGlobalState_StoreAndLoad(t *testing.T) {
 	cache, _ := NewDefaultGlobalState(maxGlobalStateSize)
@@ -68,3 +72,168 @@ func TestGlobalState_LRUEviction(t *testing.T) {
 		}
 	})
 }
+
+func TestGlobalState_EvictionAndMissCounters(t *testing.T) {
+	cache, err := NewDefaultGlobalState(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Store(preparedQueryIdAttachmentPrefix+"id1", "val1")
+	cache.Store(preparedQueryIdAttachmentPrefix+"id2", "val2") // Evicts id1
+	if _, ok := cache.Load(preparedQueryIdAttachmentPrefix + "id1"); ok {
+		t.Fatal("Expected id1 to be evicted")
+	}
+
+	if got := cache.evictions.Load(); got != 1 {
+		t.Errorf("Expected 1 eviction, got %v", got)
+	}
+	if got := cache.misses.Load(); got != 1 {
+		t.Errorf("Expected 1 miss, got %v", got)
+	}
+	if cache.lastEvictionWarning.Load() == 0 {
+		t.Error("Expected an eviction warning to be logged")
+	}
+}
+
+func TestGlobalState_PinnedEntries(t *testing.T) {
+	cache, err := NewDefaultGlobalState(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.pin("key1") {
+		t.Fatal("Expected unknown key1 not to be pinned")
+	}
+	cache.Store("key1", "val1")
+	if !cache.pin("key1") {
+		t.Fatal("Expected key1 to be pinned")
+	}
+	cache.Store("key2", "val2")
+	cache.Store("key3", "val3") // Evicts key2, not key1
+	cache.Store("key1", "val4")
+
+	if val, ok := cache.Load("key1"); !ok || val != "val4" {
+		t.Errorf("Expected val4, got %v", val)
+	}
+	if _, ok := cache.Load("key2"); ok {
+		t.Fatal("Expected key2 to be evicted")
+	}
+	if got := cache.evictions.Load(); got != 1 {
+		t.Errorf("Expected 1 eviction, got %v", got)
+	}
+}
+
+func TestGlobalState_DeleteAndLen(t *testing.T) {
+	cache, err := NewDefaultGlobalState(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Store("key1", "val1")
+	cache.Store("key2", "val2")
+	cache.pin("key1")
+	if got := cache.Len(); got != 2 {
+		t.Errorf("Expected 2 entries, got %v", got)
+	}
+
+	cache.Delete("key1")
+	cache.Delete("key2")
+	if val, ok := cache.Load("key1"); ok || val != "" {
+		t.Errorf("Expected key1 to be deleted, got %q", val)
+	}
+	if got := cache.Len(); got != 0 {
+		t.Errorf("Expected no entries, got %v", got)
+	}
+}
+
+// mapState is a GlobalState counting its lookups.
+type mapState struct {
+	mu      sync.Mutex
+	values  map[string]string
+	lookups int
+}
+
+func (m *mapState) Load(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookups++
+	val, ok := m.values[key]
+	return val, ok
+}
+
+func (m *mapState) Store(key string, val string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = val
+}
+
+func (m *mapState) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, key)
+}
+
+func (m *mapState) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.values)
+}
+
+func TestGlobalState_CustomStore(t *testing.T) {
+	store := &mapState{values: make(map[string]string)}
+	cache, err := newGlobalState(Options{StateStore: store, PreparedCacheSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Store("key1", "val1")
+	if val, ok := cache.Load("key1"); !ok || val != "val1" {
+		t.Errorf("Expected val1, got %v", val)
+	}
+	if _, ok := cache.Load("key2"); ok {
+		t.Fatal("Expected key2 not to be found")
+	}
+	if store.lookups != 2 {
+		t.Errorf("Expected 2 lookups of the store, got %v", store.lookups)
+	}
+	if got := cache.misses.Load(); got != 1 {
+		t.Errorf("Expected 1 miss, got %v", got)
+	}
+
+	// Pinned entries move out of the store.
+	if !cache.pin("key1") {
+		t.Fatal("Expected key1 to be pinned")
+	}
+	if store.Len() != 0 || cache.Len() != 1 {
+		t.Errorf("Expected key1 to be pinned out of the store")
+	}
+}
+
+func TestGlobalState_TypedEntries(t *testing.T) {
+	cache, err := NewDefaultGlobalState(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.storeValue("typed", []string{"warning"}, 0)
+	cache.storeValue("expiring", 42, time.Millisecond)
+
+	val, ok := cache.loadValue("typed")
+	if !ok || val.([]string)[0] != "warning" {
+		t.Errorf("Expected the typed entry, got %v", val)
+	}
+	if _, ok := cache.Load("typed"); ok {
+		t.Error("Expected typed entries not to be returned by Load")
+	}
+	if val, ok := cache.loadValue("expiring"); !ok || val.(int) != 42 {
+		t.Errorf("Expected 42, got %v", val)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.loadValue("expiring"); ok {
+		t.Error("Expected the expired entry not to be found")
+	}
+	if got := cache.Len(); got != 1 {
+		t.Errorf("Expected the expired entry to be removed, got %v entries", got)
+	}
+	cache.Delete("typed")
+	if got := cache.evictions.Load(); got != 0 {
+		t.Errorf("Expected no evictions, got %v", got)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// Stats holds cumulative counters of a proxy since it was started.
+type Stats struct {
+	// Number of requests received from drivers, by opcode (ie: QUERY,
+	// EXECUTE) or by protocol name for protocols other than Cassandra.
+	RequestsByOpCode map[string]int64
+	// Number of bytes read from driver connections.
+	BytesIn int64
+	// Number of bytes written to driver connections.
+	BytesOut int64
+	// Number of bytes of the gRPC messages sent to Spanner, before and after
+	// compression with GrpcCompression.
+	GrpcBytesSent           int64
+	GrpcCompressedBytesSent int64
+	// Number of bytes of the gRPC messages received from Spanner, after and
+	// before decompression.
+	GrpcBytesReceived           int64
+	GrpcCompressedBytesReceived int64
+	// Number of AdaptMessage calls retried.
+	Retries int64
+	// Number of AdaptMessage calls served over DirectPath, only counted with
+	// EnableDirectAccess.
+	DirectPathCalls int64
+	// Number of AdaptMessage calls not served over DirectPath despite
+	// EnableDirectAccess.
+	CloudPathCalls int64
+	// Number of AdaptMessage calls not retried as the retry budget of their
+	// connection was exhausted.
+	RetryBudgetExhausted int64
+	// Number of driver connections currently open.
+	ActiveConnections int64
+	// Number of driver connections accepted.
+	TotalConnections int64
+	// Number of driver connections rejected over MaxConnections.
+	RejectedConnections int64
+	// Number of driver connections closed after a panic while serving them.
+	ConnectionPanics int64
+	// Number of driver connections closed as they did not complete their
+	// handshake within HandshakeTimeout.
+	HandshakeTimeouts int64
+	// Number of driver connections whose accept was delayed by AcceptRate.
+	PacedConnections int64
+	// Number of driver connections currently waiting for a handshake slot
+	// with MaxConcurrentHandshakes.
+	HandshakeQueueDepth int64
+	// Latency histogram of the handshakes of driver connections, from their
+	// accept to their STARTUP or authentication answered with READY or
+	// AUTH_SUCCESS, including the time waiting for a handshake slot.
+	HandshakeLatency LatencyHistogram
+	// Number of requests answered with an Overloaded error as the requests
+	// sent concurrently to Spanner reached the limit of AdaptiveConcurrency.
+	ConcurrencyLimited int64
+	// Current limit of the requests sent concurrently to Spanner with
+	// AdaptiveConcurrency, 0 if unset.
+	ConcurrencyLimit int
+	// Number of entries evicted from the global state cache.
+	CacheEvictions int64
+	// Number of global state cache lookups that missed.
+	CacheMisses int64
+	// Number of failed reads and writes of the global state backend set with
+	// Options.StateRedisAddress.
+	StateBackendErrors int64
+	// Number of gRPC channels currently in the pool.
+	GrpcChannels int
+	// Spanner endpoint the gRPC channels are currently dialed to.
+	SpannerEndpoint string
+	// Number of times the gRPC channels switched to another Spanner endpoint.
+	EndpointFailovers int64
+	// Number of reads answered from the read cache.
+	ReadCacheHits int64
+	// Number of reads of cached tables sent to Spanner.
+	ReadCacheMisses int64
+	// Number of times the cached responses of a table were dropped.
+	ReadCacheInvalidations int64
+	// Number of replayed batches answered with the response of the batch of
+	// their idempotency token.
+	DuplicateBatches int64
+	// Latency histograms of the stages of Cassandra requests (ie: tcp_read,
+	// decode, attachments, grpc_send, first_response, last_response,
+	// tcp_write), by opcode and by stage.
+	StageLatencies map[string]map[string]LatencyHistogram
+	// Size histograms of the Cassandra frames read from drivers, and of the
+	// responses of Spanner written to them.
+	RequestSizes  SizeHistogram
+	ResponseSizes SizeHistogram
+}
+
+// proxyStats collects the counters reported by Stats.
+type proxyStats struct {
+	mu               sync.Mutex
+	requestsByOpCode map[string]int64
+
+	bytesIn                     atomic.Int64
+	bytesOut                    atomic.Int64
+	grpcBytesSent               atomic.Int64
+	grpcCompressedBytesSent     atomic.Int64
+	grpcBytesReceived           atomic.Int64
+	grpcCompressedBytesReceived atomic.Int64
+	retries                     atomic.Int64
+	directPathCalls             atomic.Int64
+	cloudPathCalls              atomic.Int64
+	retryBudgetExhausted        atomic.Int64
+	activeConnections           atomic.Int64
+	totalConnections            atomic.Int64
+	rejectedConnections         atomic.Int64
+	connectionPanics            atomic.Int64
+	handshakeTimeouts           atomic.Int64
+	pacedConnections            atomic.Int64
+	handshakeQueueDepth         atomic.Int64
+	concurrencyLimited          atomic.Int64
+
+	stages           stageLatencies
+	handshakeLatency latencyHistogram
+	requestSizes     sizeHistogram
+	responseSizes    sizeHistogram
+}
+
+func newProxyStats() *proxyStats {
+	return &proxyStats{requestsByOpCode: make(map[string]int64)}
+}
+
+// The record methods are no-ops on a nil proxyStats, which is the case for
+// clients not created by NewAdapterClient.
+
+func (s *proxyStats) recordRequest(opCode string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requestsByOpCode[opCode]++
+}
+
+func (s *proxyStats) recordStage(
+	opCode primitive.OpCode,
+	stage requestStage,
+	d time.Duration,
+) {
+	if s != nil {
+		s.stages.record(opCode, stage, d)
+	}
+}
+
+func (s *proxyStats) recordRequestSize(size int) {
+	if s != nil {
+		s.requestSizes.record(size)
+	}
+}
+
+func (s *proxyStats) recordResponseSize(size int) {
+	if s != nil {
+		s.responseSizes.record(size)
+	}
+}
+
+func (s *proxyStats) recordRetry() {
+	if s != nil {
+		s.retries.Add(1)
+	}
+}
+
+func (s *proxyStats) recordDirectPath(used bool) {
+	switch {
+	case s == nil:
+	case used:
+		s.directPathCalls.Add(1)
+	default:
+		s.cloudPathCalls.Add(1)
+	}
+}
+
+func (s *proxyStats) recordRetryBudgetExhausted() {
+	if s != nil {
+		s.retryBudgetExhausted.Add(1)
+	}
+}
+
+func (s *proxyStats) connectionOpened() {
+	if s != nil {
+		s.totalConnections.Add(1)
+		s.activeConnections.Add(1)
+	}
+}
+
+func (s *proxyStats) connectionRejected() {
+	if s != nil {
+		s.rejectedConnections.Add(1)
+	}
+}
+
+func (s *proxyStats) recordConnectionPanic() {
+	if s != nil {
+		s.connectionPanics.Add(1)
+	}
+}
+
+func (s *proxyStats) recordHandshakeTimeout() {
+	if s != nil {
+		s.handshakeTimeouts.Add(1)
+	}
+}
+
+func (s *proxyStats) recordPacedConnection() {
+	if s != nil {
+		s.pacedConnections.Add(1)
+	}
+}
+
+func (s *proxyStats) handshakeQueued(delta int64) {
+	if s != nil {
+		s.handshakeQueueDepth.Add(delta)
+	}
+}
+
+func (s *proxyStats) recordHandshake(d time.Duration) {
+	if s != nil {
+		s.handshakeLatency.record(d)
+	}
+}
+
+func (s *proxyStats) recordConcurrencyLimited() {
+	if s != nil {
+		s.concurrencyLimited.Add(1)
+	}
+}
+
+func (s *proxyStats) connectionClosed() {
+	if s != nil {
+		s.activeConnections.Add(-1)
+	}
+}
+
+func (s *proxyStats) snapshot() Stats {
+	s.mu.Lock()
+	requests := make(map[string]int64, len(s.requestsByOpCode))
+	for opCode, n := range s.requestsByOpCode {
+		requests[opCode] = n
+	}
+	s.mu.Unlock()
+	return Stats{
+		RequestsByOpCode:            requests,
+		BytesIn:                     s.bytesIn.Load(),
+		BytesOut:                    s.bytesOut.Load(),
+		GrpcBytesSent:               s.grpcBytesSent.Load(),
+		GrpcCompressedBytesSent:     s.grpcCompressedBytesSent.Load(),
+		GrpcBytesReceived:           s.grpcBytesReceived.Load(),
+		GrpcCompressedBytesReceived: s.grpcCompressedBytesReceived.Load(),
+		Retries:                     s.retries.Load(),
+		DirectPathCalls:             s.directPathCalls.Load(),
+		CloudPathCalls:              s.cloudPathCalls.Load(),
+		RetryBudgetExhausted:        s.retryBudgetExhausted.Load(),
+		ActiveConnections:           s.activeConnections.Load(),
+		TotalConnections:            s.totalConnections.Load(),
+		RejectedConnections:         s.rejectedConnections.Load(),
+		ConnectionPanics:            s.connectionPanics.Load(),
+		HandshakeTimeouts:           s.handshakeTimeouts.Load(),
+		PacedConnections:            s.pacedConnections.Load(),
+		HandshakeQueueDepth:         s.handshakeQueueDepth.Load(),
+		HandshakeLatency:            s.handshakeLatency.snapshot(),
+		ConcurrencyLimited:          s.concurrencyLimited.Load(),
+		StageLatencies:              s.stages.snapshot(),
+		RequestSizes:                s.requestSizes.snapshot(),
+		ResponseSizes:               s.responseSizes.snapshot(),
+	}
+}
+
+// countingConn counts the bytes read from and written to a driver
+// connection.
+type countingConn struct {
+	net.Conn
+	stats *proxyStats
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.stats.bytesIn.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.stats.bytesOut.Add(int64(n))
+	return n, err
+}
+
+// Stats returns the counters of the proxy.
+func (proxy *TCPProxy) Stats() Stats {
+	stats := proxy.client.stats.snapshot()
+	stats.CacheEvictions = proxy.globalState.evictions.Load()
+	stats.CacheMisses = proxy.globalState.misses.Load()
+	stats.StateBackendErrors = proxy.globalState.backendErrors.Load()
+	stats.ConcurrencyLimit = proxy.client.limiter.currentLimit()
+	stats.GrpcChannels = proxy.client.channels.size()
+	stats.SpannerEndpoint = proxy.client.channels.activeEndpoint()
+	stats.EndpointFailovers = proxy.client.channels.failovers.Load()
+	if rc := proxy.readCache; rc != nil {
+		stats.ReadCacheHits = rc.hits.Load()
+		stats.ReadCacheMisses = rc.misses.Load()
+		stats.ReadCacheInvalidations = rc.invalidations.Load()
+	}
+	if bt := proxy.batchTokens; bt != nil {
+		stats.DuplicateBatches = bt.duplicates.Load()
+	}
+	return stats
+}
@@ -0,0 +1,121 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyStatsSnapshot(t *testing.T) {
+	stats := newProxyStats()
+	stats.recordRequest("QUERY")
+	stats.recordRequest("QUERY")
+	stats.recordRequest("EXECUTE")
+	stats.recordRetry()
+	stats.connectionOpened()
+	stats.connectionOpened()
+	stats.connectionClosed()
+
+	snapshot := stats.snapshot()
+	assert.Equal(t, map[string]int64{"QUERY": 2, "EXECUTE": 1}, snapshot.RequestsByOpCode)
+	assert.Equal(t, int64(1), snapshot.Retries)
+	assert.Equal(t, int64(1), snapshot.ActiveConnections)
+	assert.Equal(t, int64(2), snapshot.TotalConnections)
+
+	// Snapshots are not affected by later requests.
+	stats.recordRequest("QUERY")
+	assert.Equal(t, int64(2), snapshot.RequestsByOpCode["QUERY"])
+}
+
+func TestNilProxyStats(t *testing.T) {
+	var stats *proxyStats
+	assert.NotPanics(t, func() {
+		stats.recordRequest("QUERY")
+		stats.recordRetry()
+		stats.recordStage(primitive.OpCodeQuery, stageDecode, time.Millisecond)
+		stats.connectionOpened()
+		stats.connectionClosed()
+	})
+}
+
+func TestCountingConn(t *testing.T) {
+	stats := newProxyStats()
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := &countingConn{Conn: server, stats: stats}
+	defer conn.Close()
+
+	go func() {
+		_, _ = client.Write([]byte("hello"))
+		_, _ = io.ReadFull(client, make([]byte, 3))
+	}()
+
+	buf := make([]byte, 5)
+	_, err := io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("bye"))
+	require.NoError(t, err)
+
+	snapshot := stats.snapshot()
+	assert.Equal(t, int64(5), snapshot.BytesIn)
+	assert.Equal(t, int64(3), snapshot.BytesOut)
+}
+
+func TestStageLatencies(t *testing.T) {
+	stats := newProxyStats()
+	stats.recordStage(primitive.OpCodeQuery, stageDecode, 5*time.Microsecond)
+	stats.recordStage(primitive.OpCodeQuery, stageDecode, 3*time.Millisecond)
+	stats.recordStage(primitive.OpCodeQuery, stageLastResponse, time.Minute)
+	stats.recordStage(primitive.OpCodeExecute, stageTCPWrite, time.Millisecond)
+
+	snapshot := stats.snapshot().StageLatencies
+	require.Len(t, snapshot, 2)
+	query := snapshot[primitive.OpCodeQuery.String()]
+	require.Len(t, query, 2)
+
+	decode := query["decode"]
+	assert.Equal(t, int64(2), decode.Count)
+	assert.Equal(t, 3005*time.Microsecond, decode.Sum)
+	assert.Equal(t, 1502500*time.Nanosecond, decode.Mean())
+	assert.Len(t, decode.Counts, len(decode.Bounds)+1)
+	assert.Equal(t, int64(1), decode.Counts[0])
+	assert.Equal(t, 10*time.Microsecond, decode.Quantile(0.25))
+	assert.Equal(t, 5*time.Millisecond, decode.Quantile(0.99))
+
+	// Latencies above all bounds are counted in the last bucket.
+	lastResponse := query["last_response"]
+	assert.Equal(t, int64(1), lastResponse.Counts[len(lastResponse.Bounds)])
+	assert.Equal(t, 10*time.Second, lastResponse.Quantile(0.5))
+
+	assert.Equal(
+		t,
+		int64(1),
+		snapshot[primitive.OpCodeExecute.String()]["tcp_write"].Count,
+	)
+	assert.Zero(t, LatencyHistogram{}.Mean())
+	assert.Zero(t, LatencyHistogram{}.Quantile(0.5))
+}
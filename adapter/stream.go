@@ -0,0 +1,119 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// handleStreamConnection serves a driver connection of a StreamProtocol.
+func (dc *driverConnection) handleStreamConnection(
+	ctx context.Context,
+	protocol StreamProtocol,
+) {
+	defer func() {
+		logger.Debug(
+			"Exiting recv loop",
+			zap.Int("connection id", dc.connectionID),
+		)
+		dc.driverConn.Close()
+	}()
+	reader := bufio.NewReader(dc.driverConn)
+	for {
+		dc.activity.set(ConnectionIdle)
+		payload, err := protocol.ReadRequest(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				logger.Error("Error reading request ",
+					zap.Int("connectionID", dc.connectionID),
+					zap.String("protocol", protocol.Name()),
+					zap.Error(err))
+			}
+			break
+		}
+
+		dc.adapterClient.stats.recordRequest(protocol.Name())
+		dc.activity.set(ConnectionRecv)
+		response, err := dc.serveStreamRequest(ctx, protocol, payload)
+		if err != nil {
+			logger.Error("Error serving request ",
+				zap.Int("connectionID", dc.connectionID),
+				zap.String("protocol", protocol.Name()),
+				zap.Error(err))
+			response = protocol.ErrorResponse(
+				payload,
+				newSpannerError(err, dc.adapterClient.opts.DatabaseUri),
+			)
+		}
+		dc.activity.set(ConnectionWrite)
+		if _, err := dc.driverConn.Write(response); err != nil {
+			logger.Error("Error writing response back to tcp ",
+				zap.Int("connectionID", dc.connectionID),
+				zap.Error(err))
+			break
+		}
+	}
+}
+
+// serveStreamRequest sends a request to Spanner and returns the response
+// payload.
+func (dc *driverConnection) serveStreamRequest(
+	ctx context.Context,
+	protocol StreamProtocol,
+	payload []byte,
+) ([]byte, error) {
+	session, err := dc.adapterClient.getOrRefreshSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := protocol.Attachments(payload, dc.globalState)
+	if err != nil {
+		return protocol.ErrorResponse(payload, err), nil
+	}
+	req := &requestState{
+		pb: &adapterpb.AdaptMessageRequest{
+			Name:        session.name,
+			Protocol:    protocol.Name(),
+			Payload:     payload,
+			Attachments: attachments,
+		},
+		affinityKey: dc.connectionID,
+		retryBudget: dc.retryBudget,
+	}
+	start := time.Now()
+	pbCli, ch, err := dc.executor.submit(
+		dc.labelContext(ctx),
+		req,
+		protocol.IsDML(payload),
+	)
+	if err != nil {
+		recordCompletion(req.metrics, err)
+		return nil, err
+	}
+	response, err := dc.receiveGrpcResponse(pbCli, nil)
+	dc.adapterClient.recordResult(ch, time.Since(start), err)
+	recordCompletion(req.metrics, err)
+	return response, err
+}
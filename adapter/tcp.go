@@ -18,120 +18,610 @@ package adapter
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/googleapis/go-spanner-cassandra/logger"
 
 	"go.uber.org/zap"
 )
 
+const (
+	// Number of attempts to listen on a busy TCP endpoint.
+	listenAttempts = 3
+	// Interval at which a draining proxy checks whether its connections are
+	// closed.
+	drainPollInterval = 100 * time.Millisecond
+	// Time Close waits for the driver connections to exit once their requests
+	// are canceled, and interval at which it checks whether they did.
+	closeTimeout      = 5 * time.Second
+	closePollInterval = 10 * time.Millisecond
+)
+
+// ErrDrainTimeout is returned to Options.OnDrain when connections were still
+// open after Options.DrainTimeout, and were closed.
+var ErrDrainTimeout = errors.New("connections still open after DrainTimeout were closed")
+
+// listenRetryBackoff is the backoff between attempts to listen on a busy TCP
+// endpoint.
+var listenRetryBackoff = gax.Backoff{
+	Initial:    100 * time.Millisecond,
+	Max:        time.Second,
+	Multiplier: 2,
+}
+
 // TCPProxy encapsulates a Spanner Adapter proxy.
 type TCPProxy struct {
 	opts             Options
 	listener         net.Listener
 	client           *AdapterClient
-	nextConnectionID int
+	nextConnectionID atomic.Int64
 	globalState      *globalState
+	// Listeners of Options.Listeners, in order.
+	listeners []net.Listener
+	// Additional listeners on the address of listener with
+	// Options.ListenerShards.
+	shards []net.Listener
+	// Statements of prepared query ids, nil unless EnableBatchReprepare is set.
+	statements *preparedStatements
+	// Rewrites of prepared statements setting a TTL, nil unless TTLColumns is
+	// set.
+	ttlStatements *ttlStatements
+	// Warnings of prepared query ids.
+	warnings *preparedWarnings
+	// Names of the bind markers of prepared query ids with named markers.
+	markers *preparedMarkers
+	// Tables of prepared query ids whose statements can be applied as
+	// mutations.
+	mutations *mutationStatements
+	// Compiled Options.StatementPolicy, nil if unset.
+	policy *statementPolicy
+	// Options read from Secret Manager, nil unless SecretRefs is set.
+	secrets *secretStore
+	// Cached responses of reads, nil unless ReadCacheTTLs is set.
+	readCache *readCache
+	// Prepared query ids whose statements scan a whole table, nil unless
+	// FullScanPolicy is FullScanLowPriority.
+	fullScans *fullScanStatements
+	// Databases of the tables of Options.TableRouting, nil if unset.
+	router *tableRouter
+	// Writes declared idempotent through RegisterIdempotentStatements.
+	idempotent *idempotentStatements
+	// Result columns of prepared query ids returning sets or maps, nil if
+	// DisableCollectionOrdering is set.
+	collections *collectionStatements
+	// Reader of the change streams queried through the cdc keyspace, nil
+	// unless EnableChangeStreams is set.
+	changeStreams *changeStreamReader
+	// CQL types of the columns of Options.JSONColumns, nil if unset.
+	jsonColumns *jsonColumns
+	// Codecs of the columns of Options.ColumnCodecs, nil if unset.
+	columnCodecs *columnCodecs
+	// Sampler of the requests recorded to Options.CaptureFile, nil if unset.
+	capture *requestCapture
+	// Bind variables of prepared query ids, nil if DisableBindValidation is
+	// set.
+	variables *preparedVariables
+	// Remaining rows of the results paged by the proxy, nil unless
+	// CqlshCompatibility is set.
+	pages *pagedResults
+	// Metrics of the statements by fingerprint, nil unless
+	// EnableStatementMetrics is set.
+	fingerprints *statementMetrics
+	// Attachment of Options.DirectedReadOptions, empty if unset.
+	directedReads string
+	// Partition keys of prepared query ids, nil unless LoggedBatchAtomicity
+	// or UnloggedBatchAtomicity is set.
+	partitionKeys *partitionKeys
+	// Batches of idempotency tokens, nil unless BatchDedupCacheSize is set.
+	batchTokens *batchTokens
+	// Pacer of the accepted connections, nil unless AcceptRate is set.
+	pacer *acceptPacer
+	// Limiter of the concurrent handshakes, nil unless
+	// MaxConcurrentHandshakes is set.
+	handshakes *handshakeLimiter
+	// Whether the proxy stopped accepting connections after persistent
+	// session failures.
+	draining atomic.Bool
+	// Open driver connections, by connection id.
+	conns sync.Map
+	// Cancels the context of the accept loops, the driver connections and
+	// their requests.
+	cancel context.CancelFunc
+	// Activities of the open driver connections, by connection id.
+	activities sync.Map
+	// Periodic report of the stuck connections, nil unless
+	// ConnectionReportInterval is set.
+	reporter *connectionReporter
+	// Recommendations of the payload sizes, nil unless
+	// PayloadAdvisorInterval is set.
+	advisor *payloadAdvisor
 }
 
 // NewTCPProxy returns a new Spanner Adapter proxy.
 func NewTCPProxy(opts Options) (*TCPProxy, error) {
-	ctx := context.Background()
+	return NewTCPProxyWithContext(context.Background(), opts)
+}
+
+// NewTCPProxyWithContext returns a new Spanner Adapter proxy, whose startup is
+// bounded by ctx: it fails once ctx is done, ie: while resolving the
+// credentials or reaching the Spanner endpoint to create its first session,
+// with an error wrapping the error of ctx. Canceling ctx once the proxy
+// started has no effect on it.
+func NewTCPProxyWithContext(ctx context.Context, opts Options) (_ *TCPProxy, err error) {
+	// The channels, exporters and accept loops of the proxy outlive its
+	// startup.
+	background := context.WithoutCancel(ctx)
+	if opts.Protocol == nil && opts.ProtocolName != "" {
+		p, ok := LookupProtocol(opts.ProtocolName)
+		if !ok {
+			return nil, fmt.Errorf(
+				"unknown protocol %q provided to spanner TCPProxy, registered protocols: %v",
+				opts.ProtocolName,
+				Protocols(),
+			)
+		}
+		opts.Protocol = p
+	}
 	if opts.Protocol == nil {
 		return nil, fmt.Errorf("nil protocol adapter provided to spanner TCPProxy")
 	}
 	if opts.NumGrpcChannels <= 0 {
 		opts.NumGrpcChannels = 4
 	}
+	if err := validateLabels(opts.ConnectionLabels); err != nil {
+		return nil, err
+	}
+	network, err := listenNetwork(opts.ListenNetwork)
+	if err != nil {
+		return nil, err
+	}
+	opts.ListenNetwork = network
+	if opts.Listener != nil && opts.ListenerShards > 1 {
+		return nil, errors.New("ListenerShards can not be set with Listener")
+	}
+	if err := validateDirectAccess(opts); err != nil {
+		return nil, err
+	}
+	if err := validateSupportedAttachments(opts); err != nil {
+		return nil, err
+	}
+	secrets, err := loadSecrets(ctx, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTopologyOverride(opts.TopologyOverride); err != nil {
+		return nil, err
+	}
+	if err := validateSyntheticPeerCount(opts.SyntheticPeerCount); err != nil {
+		return nil, err
+	}
+	opts.EnableDirectAccess = directAccessEnabled(opts)
+	opts = selectSpannerEndpoint(opts)
 
 	// Create spanner adapter client.
-	cl, err := newAdapterClient(ctx, opts)
+	cl, err := newAdapterClient(background, opts)
 	if err != nil {
 		return nil, err
 	}
+	// Release the channels and exporters of the client if the proxy fails to
+	// start.
+	defer func() {
+		if err != nil {
+			cl.close()
+		}
+	}()
+	if opts.EnableBuiltInMetrics {
+		cl.metrics, err = newBuiltinMetricsTracerFactory(
+			background,
+			opts.DatabaseUri,
+			cl.clientUID,
+			"",
+			false,
+			nil,
+			opts.GoogleApiOpts...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		cl.metrics.isDirectPathEnabled = opts.EnableDirectAccess
+	}
 
 	// Create initial session
 	err = cl.createSession(ctx, opts)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("spanner proxy startup interrupted: %w", ctx.Err())
+		}
 		return nil, err
 	}
 
+	if opts.MaxFrameSize <= 0 {
+		opts.MaxFrameSize = defaultMaxFrameSize
+	}
+	if opts.MaxRequestSize <= 0 {
+		opts.MaxRequestSize = defaultMaxRequestSize
+	}
+	if opts.MaxResponseSize <= 0 {
+		opts.MaxResponseSize = defaultMaxResponseSize
+	}
+
+	if opts.PreparedCacheSize <= 0 {
+		opts.PreparedCacheSize = maxGlobalStateSize
+	}
+
 	// Get or create global state cache.
-	globalState, err := NewDefaultGlobalState(maxGlobalStateSize)
+	globalState, err := newGlobalState(opts)
 	if err != nil {
 		return nil, err
 	}
+	if backend := newRedisStateBackend(opts); backend != nil {
+		globalState.backend = backend
+	}
 
 	// Create TCP proxy.
 	proxy := &TCPProxy{
 		opts:        opts,
 		client:      cl,
 		globalState: globalState,
+		idempotent:  &idempotentStatements{},
+		pacer:       newAcceptPacer(opts),
+		handshakes:  newHandshakeLimiter(opts),
+	}
+	if opts.EnableBatchReprepare {
+		proxy.statements, err = newPreparedStatements(opts.PreparedCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	proxy.warnings = newPreparedWarnings(globalState)
+	proxy.directedReads, err = directedReadAttachment(opts)
+	if err != nil {
+		return nil, err
+	}
+	proxy.markers, err = newPreparedMarkers(opts.PreparedCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	proxy.mutations, err = newMutationStatements(opts.PreparedCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	if !opts.DisableBindValidation {
+		proxy.variables, err = newPreparedVariables(opts.PreparedCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.CqlshCompatibility {
+		proxy.pages, err = newPagedResults(maxPagedResults)
+		if err != nil {
+			return nil, err
+		}
+	}
+	proxy.fingerprints, err = newStatementMetrics(opts)
+	if err != nil {
+		return nil, err
+	}
+	proxy.policy, err = newStatementPolicy(opts.StatementPolicy)
+	if err != nil {
+		return nil, err
+	}
+	if proxy.policy != nil && secrets.refreshesPolicy() {
+		if err := proxy.policy.trackStatements(opts.PreparedCacheSize); err != nil {
+			return nil, err
+		}
+	}
+	proxy.secrets = secrets
+	proxy.readCache, err = newReadCache(
+		opts.ReadCacheTTLs,
+		opts.ReadCacheSize,
+		opts.PreparedCacheSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if opts.LoggedBatchAtomicity != BatchAtomic ||
+		opts.UnloggedBatchAtomicity != BatchAtomic {
+		proxy.partitionKeys, err = newPartitionKeys(opts.PreparedCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	proxy.batchTokens, err = newBatchTokens(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.FullScanPolicy == FullScanLowPriority {
+		proxy.fullScans, err = newFullScanStatements(opts.PreparedCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !opts.DisableCollectionOrdering {
+		proxy.collections, err = newCollectionStatements(opts.PreparedCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.TTLColumns) > 0 {
+		proxy.ttlStatements, err = newTTLStatements(opts.PreparedCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.JSONColumns) > 0 {
+		proxy.jsonColumns, err = newJSONColumns(
+			opts.JSONColumns,
+			opts.UserTypes,
+			opts.PreparedCacheSize,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.ColumnCodecs) > 0 {
+		proxy.columnCodecs, err = newColumnCodecs(opts.ColumnCodecs, opts.PreparedCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.CaptureFile != "" {
+		proxy.capture, err = newRequestCapture(opts)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				proxy.capture.close()
+			}
+		}()
+	}
+	if opts.EnableChangeStreams {
+		proxy.changeStreams, err = newChangeStreamReader(opts)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				proxy.changeStreams.close()
+			}
+		}()
+	}
+
+	dbs := newDatabaseClients(cl)
+	proxy.router, err = newTableRouter(ctx, dbs, opts)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := newListenerRoutes(ctx, dbs, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, client := range dbs.all() {
+		client.onSessionFailures = proxy.drain
 	}
 
 	// Start local listener.
 	if opts.TCPEndpoint == "" {
 		opts.TCPEndpoint = "localhost:9042"
 	}
-	proxy.listener, err = net.Listen("tcp", opts.TCPEndpoint)
+	proxy.listener = opts.Listener
+	if proxy.listener == nil {
+		proxy.listener, err = listen(opts)
+	}
 	if err != nil {
 		return nil, fmt.Errorf(
 			"spanner proxy failed to listen on local port: %w",
 			err,
 		)
 	}
-	logger.Info(
-		"Spanner proxy listening on ",
-		zap.String("tcp_port", proxy.listener.Addr().String()),
-	)
+	// Shards listen on the address of the listener, which may differ from
+	// TCPEndpoint with a fallback port.
+	for i := 1; i < opts.ListenerShards; i++ {
+		lis, err := listenConfig(opts).Listen(
+			context.Background(),
+			proxy.listener.Addr().Network(),
+			proxy.listener.Addr().String(),
+		)
+		if err != nil {
+			proxy.closeListeners()
+			return nil, fmt.Errorf("spanner proxy failed to listen on shard %d: %w", i, err)
+		}
+		proxy.shards = append(proxy.shards, lis)
+	}
+	for i, route := range routes {
+		lis, err := listenWithRetry(
+			listenConfig(opts),
+			opts.ListenNetwork,
+			opts.Listeners[i].TCPEndpoint,
+		)
+		if err != nil {
+			proxy.closeListeners()
+			return nil, fmt.Errorf(
+				"spanner proxy failed to listen on %s: %w",
+				opts.Listeners[i].TCPEndpoint,
+				err,
+			)
+		}
+		logger.Info(
+			"Spanner proxy listening on ",
+			zap.String("tcp_port", lis.Addr().String()),
+			zap.String("database_uri", route.client.opts.DatabaseUri),
+		)
+		proxy.listeners = append(proxy.listeners, lis)
+	}
 
-	// Start accept loop.
-	go func() {
-		for {
-			// Wait for a connection.
-			conn, err := proxy.listener.Accept()
+	logger.Info("Spanner proxy started", proxy.startupFields()...)
 
-			if err != nil {
-				if errors.Is(err, net.ErrClosed) {
-					break
-				} else {
-					logger.Error("Spanner proxy failed to accept connection", zap.Error(err))
-					break
-				}
+	proxy.secrets.start(background, proxy.policy)
+	proxy.reporter = startConnectionReporter(proxy)
+	proxy.advisor = startPayloadAdvisor(proxy)
+
+	// Start accept loops, whose connections and requests are canceled on
+	// Close.
+	serving, cancel := context.WithCancel(background)
+	proxy.cancel = cancel
+	route := &listenerRoute{
+		client: cl,
+		labels: opts.ConnectionLabels,
+	}
+	go proxy.acceptConnections(serving, proxy.listener, route)
+	for _, lis := range proxy.shards {
+		go proxy.acceptConnections(serving, lis, route)
+	}
+	for i, route := range routes {
+		go proxy.acceptConnections(serving, proxy.listeners[i], route)
+	}
+
+	return proxy, nil
+}
+
+// acceptConnections serves the connections of a listener with its route,
+// until the listener is closed.
+func (proxy *TCPProxy) acceptConnections(
+	ctx context.Context,
+	lis net.Listener,
+	route *listenerRoute,
+) {
+	for {
+		// Wait for a connection.
+		conn, err := lis.Accept()
+
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || proxy.Draining() {
+				break
+			} else {
+				logger.Error("Spanner proxy failed to accept connection", zap.Error(err))
+				break
 			}
-			logger.Debug(
-				"Spanner proxy received a connection, assigning ID",
-				zap.Int("connection_id", proxy.nextConnectionID),
-			) // Prepare to accept next connection.
-
-			dc := &driverConnection{
-				connectionID:  proxy.nextConnectionID,
-				protocol:      opts.Protocol,
-				adapterClient: proxy.client,
-				executor: &requestExecutor{
-					protocol:    opts.Protocol,
-					client:      proxy.client,
-					globalState: proxy.globalState,
-					opts:        &proxy.opts,
-				},
-				driverConn:  conn,
-				globalState: proxy.globalState,
-				md:          cl.md,
-				codec:       frame.NewCodec(),
-				rawCodec:    frame.NewRawCodec(),
+		}
+		delayed, ok := proxy.pacer.wait(ctx)
+		if delayed {
+			proxy.client.stats.recordPacedConnection()
+		}
+		if !ok {
+			conn.Close()
+			break
+		}
+		if proxy.opts.AcceptProxyProtocol {
+			conn = &proxyProtocolConn{Conn: conn}
+		}
+		if !proxy.acceptsConnection() {
+			if route.tlsConfig != nil {
+				conn = tls.Server(conn, route.tlsConfig)
 			}
-
-			go dc.handleConnection(ctx)
-			proxy.nextConnectionID++
+			go proxy.rejectConnection(conn)
+			continue
 		}
+		connectionID := int(proxy.nextConnectionID.Add(1) - 1)
+		logger.Debug(
+			"Spanner proxy received a connection, assigning ID",
+			zap.Int("connection_id", connectionID),
+		)
+		proxy.client.stats.connectionOpened()
+		go proxy.serveConnection(ctx, conn, connectionID, route)
+	}
 
-		logger.Debug("Spanner proxy accept loop exited")
-	}()
+	logger.Debug("Spanner proxy accept loop exited")
+}
 
-	return proxy, nil
+// serveConnection serves a driver connection accepted by a listener with
+// route.
+func (proxy *TCPProxy) serveConnection(
+	ctx context.Context,
+	conn net.Conn,
+	connectionID int,
+	route *listenerRoute,
+) {
+	start := time.Now()
+	accepted, route, err := route.accept(ctx, conn)
+	if err != nil {
+		logger.Warn("Spanner proxy failed to complete TLS handshake",
+			zap.Int("connection_id", connectionID),
+			zap.Error(err))
+		conn.Close()
+		proxy.client.stats.connectionClosed()
+		return
+	}
+	proxy.conns.Store(connectionID, accepted)
+	defer proxy.conns.Delete(connectionID)
+	// Connections accepted while the proxy closes are not served.
+	if ctx.Err() != nil {
+		accepted.Close()
+		proxy.client.stats.connectionClosed()
+		return
+	}
+	dc := proxy.newDriverConnection(accepted, connectionID, route)
+	dc.handshakeStart = start
+	dc.activity = newConnectionActivity(accepted.RemoteAddr().String())
+	proxy.activities.Store(connectionID, dc.activity)
+	defer proxy.activities.Delete(connectionID)
+	if !dc.admitHandshake(ctx, proxy.handshakes) {
+		accepted.Close()
+		proxy.client.stats.connectionClosed()
+		return
+	}
+	dc.startHandshakeTimer()
+	withConnectionLabel(ctx, connectionID, dc.handleConnection)
+}
+
+// newDriverConnection returns the state of a driver connection served with
+// the database and labels of route.
+func (proxy *TCPProxy) newDriverConnection(
+	conn net.Conn,
+	connectionID int,
+	route *listenerRoute,
+) *driverConnection {
+	opts := proxy.opts
+	return &driverConnection{
+		connectionID:  connectionID,
+		protocol:      opts.Protocol,
+		adapterClient: route.client,
+		executor: &requestExecutor{
+			protocol:      opts.Protocol,
+			client:        route.client,
+			globalState:   proxy.globalState,
+			opts:          &proxy.opts,
+			statements:    proxy.statements,
+			ttlStatements: proxy.ttlStatements,
+			warnings:      proxy.warnings,
+			markers:       proxy.markers,
+			mutations:     proxy.mutations,
+			policy:        proxy.policy,
+			readCache:     proxy.readCache,
+			fullScans:     proxy.fullScans,
+			router:        proxy.router,
+			idempotent:    proxy.idempotent,
+			collections:   proxy.collections,
+			changeStreams: proxy.changeStreams,
+			jsonColumns:   proxy.jsonColumns,
+			columnCodecs:  proxy.columnCodecs,
+			capture:       proxy.capture,
+			variables:     proxy.variables,
+			pages:         proxy.pages,
+			fingerprints:  proxy.fingerprints,
+			directedReads: proxy.directedReads,
+			partitionKeys: proxy.partitionKeys,
+			batchTokens:   proxy.batchTokens,
+		},
+		driverConn:  &countingConn{Conn: conn, stats: proxy.client.stats},
+		globalState: proxy.globalState,
+		labels:      copyLabels(route.labels),
+		md:          route.client.md,
+		codec:       frame.NewCodec(),
+		retryBudget: newRetryBudget(opts.RetryBudgetRatio, opts.RetryBudgetBurst),
+	}
 }
 
 // Addr returns the address of the proxy.
@@ -139,7 +629,298 @@ func (proxy *TCPProxy) Addr() net.Addr {
 	return proxy.listener.Addr()
 }
 
-// Close closes the proxy.
+// ListenerAddrs returns the addresses of the listeners of Options.Listeners,
+// in order.
+func (proxy *TCPProxy) ListenerAddrs() []net.Addr {
+	addrs := make([]net.Addr, len(proxy.listeners))
+	for i, lis := range proxy.listeners {
+		addrs[i] = lis.Addr()
+	}
+	return addrs
+}
+
+// closeListeners closes every listener of the proxy.
+func (proxy *TCPProxy) closeListeners() {
+	if proxy.listener != nil {
+		proxy.listener.Close()
+	}
+	for _, lis := range proxy.shards {
+		lis.Close()
+	}
+	for _, lis := range proxy.listeners {
+		lis.Close()
+	}
+}
+
+// closeConnections cancels the outstanding requests of the driver connections
+// and closes them, so that their goroutines exit, and waits up to closeTimeout
+// for them to.
+func (proxy *TCPProxy) closeConnections() {
+	if proxy.cancel != nil {
+		proxy.cancel()
+	}
+	proxy.conns.Range(func(_, conn any) bool {
+		conn.(net.Conn).Close()
+		return true
+	})
+	deadline := time.Now().Add(closeTimeout)
+	for proxy.openConnections() > 0 && time.Now().Before(deadline) {
+		time.Sleep(closePollInterval)
+	}
+}
+
+// Close closes the proxy, its listeners, its driver connections and its gRPC
+// channels, and stops its background goroutines. The outstanding requests of
+// the driver connections are canceled, along with their retries.
 func (proxy *TCPProxy) Close() {
-	proxy.listener.Close()
+	proxy.closeListeners()
+	proxy.closeConnections()
+	proxy.changeStreams.close()
+	proxy.capture.close()
+	proxy.secrets.close()
+	proxy.reporter.close()
+	proxy.advisor.close()
+	proxy.globalState.close()
+	proxy.client.close()
+}
+
+// Shutdown stops accepting driver connections and waits for the open ones to
+// close until ctx is done, ie: to hand the endpoints of the proxy over to a new
+// proxy process listening with ReusePort during an in place upgrade.
+// Connections still open once ctx is done are closed, their outstanding
+// requests are canceled, and ctx.Err() is returned. Call Close afterwards to
+// close the gRPC channels.
+func (proxy *TCPProxy) Shutdown(ctx context.Context) error {
+	proxy.closeListeners()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for proxy.openConnections() > 0 {
+		select {
+		case <-ctx.Done():
+			proxy.closeConnections()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Draining reports whether the proxy stopped accepting connections because
+// the Spanner session could not be refreshed MaxSessionFailures consecutive
+// times.
+func (proxy *TCPProxy) Draining() bool {
+	return proxy.draining.Load()
+}
+
+// drain stops accepting new connections after persistent session failures.
+// Existing connections keep being served, for up to DrainTimeout if set.
+func (proxy *TCPProxy) drain(err error) {
+	if !proxy.draining.CompareAndSwap(false, true) {
+		return
+	}
+	logger.Error(
+		"Spanner session can not be refreshed, spanner proxy stopped accepting connections",
+		zap.Int("max_session_failures", proxy.opts.MaxSessionFailures),
+		zap.Error(err),
+	)
+	proxy.closeListeners()
+	if proxy.opts.DrainTimeout > 0 {
+		go proxy.closeDrainedConnections(err)
+		return
+	}
+	if proxy.opts.OnDrain != nil {
+		proxy.opts.OnDrain(err)
+	}
+}
+
+// closeDrainedConnections waits up to DrainTimeout for the connections of a
+// draining proxy to close, closes the connections still open, and calls
+// OnDrain with an ErrDrainTimeout error if there were any.
+func (proxy *TCPProxy) closeDrainedConnections(err error) {
+	deadline := time.Now().Add(proxy.opts.DrainTimeout)
+	for proxy.openConnections() > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	closed := 0
+	proxy.conns.Range(func(_, conn any) bool {
+		conn.(net.Conn).Close()
+		closed++
+		return true
+	})
+	if closed > 0 {
+		logger.Error(
+			"Closing connections still open after DrainTimeout",
+			zap.Int("connections", closed),
+			zap.Duration("drain_timeout", proxy.opts.DrainTimeout),
+		)
+		err = fmt.Errorf("%w: %d connections: %w", ErrDrainTimeout, closed, err)
+	}
+	if proxy.opts.OnDrain != nil {
+		proxy.opts.OnDrain(err)
+	}
+}
+
+// openConnections returns the number of open driver connections.
+func (proxy *TCPProxy) openConnections() int {
+	n := 0
+	proxy.conns.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// parsePortRange parses an inclusive port range in the form "start-end".
+func parsePortRange(portRange string) ([]int, error) {
+	if portRange == "" {
+		return nil, nil
+	}
+	startStr, endStr, found := strings.Cut(portRange, "-")
+	start, startErr := strconv.Atoi(strings.TrimSpace(startStr))
+	end, endErr := strconv.Atoi(strings.TrimSpace(endStr))
+	if !found || startErr != nil || endErr != nil ||
+		start <= 0 || end > 65535 || start > end {
+		return nil, fmt.Errorf("invalid TCP port range %q", portRange)
+	}
+	ports := make([]int, 0, end-start+1)
+	for port := start; port <= end; port++ {
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// listenConfig returns the configuration of the listeners of the proxy.
+func listenConfig(opts Options) *net.ListenConfig {
+	lc := &net.ListenConfig{}
+	if opts.ReusePort || opts.ListenerShards > 1 {
+		lc.Control = reusePort
+	}
+	return lc
+}
+
+// listenWithRetry listens on endpoint, retrying with backoff while the address
+// is in use, ie: while a previous proxy instance is shutting down.
+func listenWithRetry(
+	lc *net.ListenConfig,
+	network, endpoint string,
+) (net.Listener, error) {
+	bo := listenRetryBackoff
+	var err error
+	for attempt := 0; attempt < listenAttempts; attempt++ {
+		var lis net.Listener
+		lis, err = lc.Listen(context.Background(), network, endpoint)
+		if err == nil || !errors.Is(err, syscall.EADDRINUSE) {
+			return lis, err
+		}
+		if attempt < listenAttempts-1 {
+			time.Sleep(bo.Pause())
+		}
+	}
+	return nil, err
+}
+
+// listen opens the local listener on opts.TCPEndpoint. If the endpoint stays in
+// use, the ports of opts.TCPPortRange are tried in order on the same host,
+// followed by an ephemeral port if opts.FallbackToEphemeralPort is set.
+func listen(opts Options) (net.Listener, error) {
+	ports, err := parsePortRange(opts.TCPPortRange)
+	if err != nil {
+		return nil, err
+	}
+	network, err := listenNetwork(opts.ListenNetwork)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := opts.TCPEndpoint
+	if opts.ListenInterface != "" {
+		endpoint, err = interfaceEndpoint(network, opts.ListenInterface, endpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lc := listenConfig(opts)
+	lis, err := listenWithRetry(lc, network, endpoint)
+	if err == nil || !errors.Is(err, syscall.EADDRINUSE) {
+		return lis, err
+	}
+	host, _, splitErr := net.SplitHostPort(endpoint)
+	if splitErr != nil {
+		return nil, err
+	}
+	for _, port := range ports {
+		lis, portErr := lc.Listen(
+			context.Background(),
+			network,
+			net.JoinHostPort(host, strconv.Itoa(port)),
+		)
+		if portErr == nil {
+			logger.Info("Spanner proxy endpoint in use, falling back to port range",
+				zap.String("tcp_endpoint", opts.TCPEndpoint),
+				zap.Int("port", port))
+			return lis, nil
+		}
+	}
+	if opts.FallbackToEphemeralPort {
+		logger.Info("Spanner proxy endpoint in use, falling back to ephemeral port",
+			zap.String("tcp_endpoint", opts.TCPEndpoint))
+		return lc.Listen(context.Background(), network, net.JoinHostPort(host, "0"))
+	}
+	return nil, err
+}
+
+// listenNetwork returns the network of the listeners of the proxy, given
+// Options.ListenNetwork.
+func listenNetwork(network string) (string, error) {
+	switch network {
+	case "":
+		return "tcp", nil
+	case "tcp", "tcp4", "tcp6":
+		return network, nil
+	default:
+		return "", fmt.Errorf(
+			"invalid listen network %q, expected tcp, tcp4 or tcp6",
+			network,
+		)
+	}
+}
+
+// interfaceEndpoint replaces the host of endpoint with the first address of
+// the network interface name usable on network. Link-local IPv6 addresses are
+// only used if the interface has no other address.
+func interfaceEndpoint(network, name, endpoint string) (string, error) {
+	_, port, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", err
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to find network interface %q: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("failed to list the addresses of network interface %q: %w", name, err)
+	}
+	var linkLocal string
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip := ipNet.IP
+		if isIPv4 := ip.To4() != nil; (network == "tcp4" && !isIPv4) ||
+			(network == "tcp6" && isIPv4) {
+			continue
+		}
+		if ip.To4() == nil && ip.IsLinkLocalUnicast() {
+			if linkLocal == "" {
+				linkLocal = ip.String() + "%" + iface.Name
+			}
+			continue
+		}
+		return net.JoinHostPort(ip.String(), port), nil
+	}
+	if linkLocal != "" {
+		return net.JoinHostPort(linkLocal, port), nil
+	}
+	return "", fmt.Errorf("network interface %q has no %s address", name, network)
 }
@@ -0,0 +1,540 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParsePortRange(t *testing.T) {
+	testCases := []struct {
+		portRange string
+		want      []int
+		wantErr   bool
+	}{
+		{portRange: "", want: nil},
+		{portRange: "9043-9045", want: []int{9043, 9044, 9045}},
+		{portRange: "9043 - 9043", want: []int{9043}},
+		{portRange: "9045-9043", wantErr: true},
+		{portRange: "9043", wantErr: true},
+		{portRange: "0-10", wantErr: true},
+		{portRange: "a-b", wantErr: true},
+		{portRange: "65535-65536", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.portRange, func(t *testing.T) {
+			got, err := parsePortRange(tc.portRange)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestListenFallback(t *testing.T) {
+	listenRetryBackoff.Initial = time.Millisecond
+	busy, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer busy.Close()
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+
+	t.Run("NoFallback", func(t *testing.T) {
+		_, err := listen(Options{TCPEndpoint: busy.Addr().String()})
+		assert.Error(t, err)
+	})
+
+	t.Run("PortRange", func(t *testing.T) {
+		// Find a free port to use as the fallback range.
+		free, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		freePort := free.Addr().(*net.TCPAddr).Port
+		free.Close()
+
+		portRange := strconv.Itoa(freePort) + "-" + strconv.Itoa(freePort)
+		lis, err := listen(Options{
+			TCPEndpoint:  busy.Addr().String(),
+			TCPPortRange: portRange,
+		})
+		require.NoError(t, err)
+		defer lis.Close()
+		assert.Equal(t, freePort, lis.Addr().(*net.TCPAddr).Port)
+	})
+
+	t.Run("EphemeralPort", func(t *testing.T) {
+		lis, err := listen(Options{
+			TCPEndpoint:             busy.Addr().String(),
+			TCPPortRange:            strconv.Itoa(busyPort) + "-" + strconv.Itoa(busyPort),
+			FallbackToEphemeralPort: true,
+		})
+		require.NoError(t, err)
+		defer lis.Close()
+		assert.NotEqual(t, busyPort, lis.Addr().(*net.TCPAddr).Port)
+	})
+
+	t.Run("InvalidPortRange", func(t *testing.T) {
+		_, err := listen(Options{
+			TCPEndpoint:  "localhost:0",
+			TCPPortRange: "invalid",
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestListenNetwork(t *testing.T) {
+	lis, err := listen(Options{TCPEndpoint: "localhost:0", ListenNetwork: "tcp4"})
+	require.NoError(t, err)
+	defer lis.Close()
+	assert.NotNil(t, lis.Addr().(*net.TCPAddr).IP.To4())
+
+	_, err = listen(Options{TCPEndpoint: "localhost:0", ListenNetwork: "udp"})
+	assert.Error(t, err)
+}
+
+func TestListenInterface(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	var loopback string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("no loopback interface")
+	}
+
+	lis, err := listen(Options{
+		TCPEndpoint:     ":0",
+		ListenNetwork:   "tcp4",
+		ListenInterface: loopback,
+	})
+	require.NoError(t, err)
+	defer lis.Close()
+	assert.True(t, lis.Addr().(*net.TCPAddr).IP.IsLoopback())
+
+	_, err = listen(Options{TCPEndpoint: ":0", ListenInterface: "no-such-interface"})
+	assert.Error(t, err)
+}
+
+func TestListenReusePort(t *testing.T) {
+	lis, err := listen(Options{TCPEndpoint: "localhost:0", ReusePort: true})
+	require.NoError(t, err)
+	defer lis.Close()
+
+	// Another proxy listens on the same endpoint during an upgrade.
+	other, err := listen(Options{TCPEndpoint: lis.Addr().String(), ReusePort: true})
+	require.NoError(t, err)
+	defer other.Close()
+	assert.Equal(t, lis.Addr().String(), other.Addr().String())
+}
+
+func TestListenerShards(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:    "projects/test/instances/test/databases/test",
+		TCPEndpoint:    "localhost:0",
+		ListenerShards: 3,
+		Protocol:       &lineProtocol{},
+		GoogleApiOpts:  SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+	require.Len(t, proxy.shards, 2)
+	for _, lis := range proxy.shards {
+		assert.Equal(t, proxy.Addr().String(), lis.Addr().String())
+	}
+
+	for i := 0; i < 10; i++ {
+		conn, err := net.Dial("tcp", proxy.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+	}
+	assert.Eventually(t, func() bool {
+		return proxy.openConnections() == 10
+	}, time.Second, 10*time.Millisecond)
+
+	inherited, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer inherited.Close()
+	_, err = NewTCPProxy(Options{
+		DatabaseUri:    "projects/test/instances/test/databases/test",
+		Listener:       inherited,
+		ListenerShards: 3,
+		Protocol:       &lineProtocol{},
+		GoogleApiOpts:  SkipAuthOpts,
+	})
+	assert.Error(t, err)
+}
+
+func TestShutdown(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	inherited, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		Listener:      inherited,
+		Protocol:      &lineProtocol{},
+		GoogleApiOpts: SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+	assert.Equal(t, inherited.Addr(), proxy.Addr())
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool {
+		return proxy.openConnections() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The connection left open is closed once ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, proxy.Shutdown(ctx), context.DeadlineExceeded)
+	_, err = net.DialTimeout("tcp", inherited.Addr().String(), time.Second)
+	assert.Error(t, err)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.NoError(t, proxy.Shutdown(context.Background()))
+}
+
+func TestDrainAfterSessionFailures(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	var drainErr error
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:        "projects/test/instances/test/databases/test",
+		TCPEndpoint:        "localhost:0",
+		Protocol:           &lineProtocol{},
+		GoogleApiOpts:      SkipAuthOpts,
+		MaxSessionFailures: 2,
+		OnDrain:            func(err error) { drainErr = err },
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+	addr := proxy.Addr().String()
+
+	CreateSessionGrpc = func(
+		ctx context.Context,
+		req *adapterpb.CreateSessionRequest,
+		cl *AdapterClient,
+	) (*adapterpb.Session, error) {
+		return nil, status.Error(codes.PermissionDenied, "revoked")
+	}
+	// Expire the session so that requests try to refresh it.
+	proxy.client.setSession(session{name: "expired"})
+
+	_, err = proxy.client.getOrRefreshSession(context.Background())
+	assert.Error(t, err)
+	assert.False(t, proxy.Draining())
+
+	_, err = proxy.client.getOrRefreshSession(context.Background())
+	assert.Error(t, err)
+	assert.True(t, proxy.Draining())
+	assert.Equal(t, codes.PermissionDenied, status.Code(drainErr))
+
+	_, err = net.DialTimeout("tcp", addr, time.Second)
+	assert.Error(t, err)
+}
+
+func TestDrainTimeoutClosesConnections(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	drained := make(chan error, 1)
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:        "projects/test/instances/test/databases/test",
+		TCPEndpoint:        "localhost:0",
+		Protocol:           &lineProtocol{},
+		GoogleApiOpts:      SkipAuthOpts,
+		MaxSessionFailures: 1,
+		DrainTimeout:       100 * time.Millisecond,
+		OnDrain:            func(err error) { drained <- err },
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool {
+		return proxy.openConnections() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	CreateSessionGrpc = func(
+		ctx context.Context,
+		req *adapterpb.CreateSessionRequest,
+		cl *AdapterClient,
+	) (*adapterpb.Session, error) {
+		return nil, status.Error(codes.PermissionDenied, "revoked")
+	}
+	proxy.client.setSession(session{name: "expired"})
+	_, err = proxy.client.getOrRefreshSession(context.Background())
+	assert.Error(t, err)
+	assert.True(t, proxy.Draining())
+
+	// The connection left open is closed once DrainTimeout expires.
+	select {
+	case err := <-drained:
+		assert.ErrorIs(t, err, ErrDrainTimeout)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDrain was not called")
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestDrainTimeoutWithoutConnections(t *testing.T) {
+	drained := make(chan error, 1)
+	proxy := &TCPProxy{opts: Options{
+		DrainTimeout: time.Hour,
+		OnDrain:      func(err error) { drained <- err },
+	}}
+	revoked := status.Error(codes.PermissionDenied, "revoked")
+	proxy.drain(revoked)
+	select {
+	case err := <-drained:
+		assert.Equal(t, revoked, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDrain was not called")
+	}
+}
+
+// waitForGoroutines waits for the number of goroutines to drop to n, and
+// returns the last observed number.
+func waitForGoroutines(n int) int {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		current := runtime.NumGoroutine()
+		if current <= n || time.Now().After(deadline) {
+			return current
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	before := runtime.NumGoroutine()
+	// Autoscaling and failover endpoints run background loops on the pool.
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:       "projects/test/instances/test/databases/test",
+		TCPEndpoint:       "localhost:0",
+		Protocol:          &lineProtocol{},
+		GoogleApiOpts:     SkipAuthOpts,
+		MinGrpcChannels:   1,
+		MaxGrpcChannels:   4,
+		FailoverEndpoints: []string{"localhost:444"},
+	})
+	require.NoError(t, err)
+	conn, err := net.DialTimeout("tcp", proxy.Addr().String(), time.Second)
+	require.NoError(t, err)
+	conn.Close()
+
+	proxy.Close()
+	assert.LessOrEqual(t, waitForGoroutines(before), before)
+}
+
+func TestCloseTwice(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:              "projects/test/instances/test/databases/test",
+		TCPEndpoint:              "localhost:0",
+		Protocol:                 &lineProtocol{},
+		GoogleApiOpts:            SkipAuthOpts,
+		ConnectionReportInterval: time.Hour,
+		PayloadAdvisorInterval:   time.Hour,
+	})
+	require.NoError(t, err)
+	proxy.Close()
+	assert.NotPanics(t, proxy.Close)
+}
+
+func TestCloseCancelsOutstandingRequests(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	started := make(chan struct{})
+	canceled := make(chan error, 1)
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		close(started)
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return nil, ctx.Err()
+	}
+	proxy, err := NewTCPProxy(Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		TCPEndpoint:   "localhost:0",
+		Protocol:      &cqlTestProtocol{},
+		GoogleApiOpts: SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	writeFrame(t, conn, 1, &message.Query{Query: "SELECT * FROM ks.t"})
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("request not sent")
+	}
+
+	// The request blocked on Spanner is canceled, and its connection closed.
+	proxy.Close()
+	select {
+	case err := <-canceled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("request not canceled")
+	}
+	assert.Equal(t, 0, proxy.openConnections())
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = io.ReadAll(conn)
+	assert.NoError(t, err)
+}
+
+func TestNewTCPProxyReleasesClientOnError(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	MockCreateSessionGrpc()
+	lis, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	before := runtime.NumGoroutine()
+	// The proxy fails to listen on an endpoint in use, after its client was
+	// created.
+	_, err = NewTCPProxy(Options{
+		DatabaseUri:       "projects/test/instances/test/databases/test",
+		TCPEndpoint:       lis.Addr().String(),
+		Protocol:          &lineProtocol{},
+		GoogleApiOpts:     SkipAuthOpts,
+		MinGrpcChannels:   1,
+		MaxGrpcChannels:   4,
+		FailoverEndpoints: []string{"localhost:444"},
+	})
+	require.Error(t, err)
+	assert.LessOrEqual(t, waitForGoroutines(before), before)
+}
+
+func TestNewTCPProxyWithContext(t *testing.T) {
+	t.Cleanup(ResetGrpcFuncs())
+	// Session creation hangs until its context is done, ie: on an unreachable
+	// endpoint.
+	CreateSessionGrpc = func(
+		ctx context.Context,
+		req *adapterpb.CreateSessionRequest,
+		cl *AdapterClient,
+	) (*adapterpb.Session, error) {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := NewTCPProxyWithContext(ctx, Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		TCPEndpoint:   "localhost:0",
+		Protocol:      &lineProtocol{},
+		GoogleApiOpts: SkipAuthOpts,
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Canceling the context once the proxy started has no effect on it.
+	MockCreateSessionGrpc()
+	ctx, cancel = context.WithCancel(context.Background())
+	proxy, err := NewTCPProxyWithContext(ctx, Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		TCPEndpoint:   "localhost:0",
+		Protocol:      &lineProtocol{},
+		GoogleApiOpts: SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+	cancel()
+	conn, err := net.DialTimeout("tcp", proxy.Addr().String(), time.Second)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestSessionFailuresResetOnSuccess(t *testing.T) {
+	cl := &AdapterClient{opts: Options{MaxSessionFailures: 2}}
+	drained := false
+	cl.onSessionFailures = func(error) { drained = true }
+	cl.recordSessionResult(errors.New("failed"))
+	cl.recordSessionResult(nil)
+	cl.recordSessionResult(errors.New("failed"))
+	assert.False(t, drained)
+	cl.recordSessionResult(errors.New("failed"))
+	assert.True(t, drained)
+}
+
+// panickingProtocol is a lineProtocol whose reads panic, like a broken codec.
+type panickingProtocol struct {
+	lineProtocol
+}
+
+func (p *panickingProtocol) ReadRequest(r *bufio.Reader) ([]byte, error) {
+	panic("poisoned request")
+}
+
+func TestHandleConnectionRecoversPanics(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	stats := newProxyStats()
+	stats.connectionOpened()
+	dc := &driverConnection{
+		protocol:      &panickingProtocol{},
+		adapterClient: &AdapterClient{stats: stats},
+		driverConn:    server,
+	}
+
+	assert.NotPanics(t, func() { dc.handleConnection(context.Background()) })
+	_, err := client.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+	snapshot := stats.snapshot()
+	assert.Equal(t, int64(1), snapshot.ConnectionPanics)
+	assert.Equal(t, int64(0), snapshot.ActiveConnections)
+}
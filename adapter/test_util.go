@@ -94,6 +94,7 @@ type Mock_Cassandra_AdaptMessageClient struct {
 	eof                     bool
 	returnResponsesInChunks bool
 	bodyResponsesReturned   bool
+	header                  metadata.MD
 }
 
 func (mc *Mock_Cassandra_AdaptMessageClient) CloseSend() error {
@@ -101,11 +102,11 @@ func (mc *Mock_Cassandra_AdaptMessageClient) CloseSend() error {
 }
 
 func (mc *Mock_Cassandra_AdaptMessageClient) Context() context.Context {
-	panic("unimplemented")
+	return context.Background()
 }
 
 func (mc *Mock_Cassandra_AdaptMessageClient) Header() (metadata.MD, error) {
-	panic("unimplemented")
+	return mc.header, nil
 }
 
 func (mc *Mock_Cassandra_AdaptMessageClient) RecvMsg(m any) error {
@@ -505,3 +506,44 @@ func MockAdaptMessageGrpc(returnResponsesInChunks bool) {
 		return request, nil
 	}
 }
+
+// Mock_Payload_AdaptMessageClient returns a single response carrying a fixed
+// payload.
+type Mock_Payload_AdaptMessageClient struct {
+	Mock_Cassandra_AdaptMessageClient
+	payload      []byte
+	stateUpdates map[string]string
+}
+
+func (mc *Mock_Payload_AdaptMessageClient) Recv() (*adapterpb.AdaptMessageResponse, error) {
+	if mc.eof {
+		return nil, io.EOF
+	}
+	mc.eof = true
+	return &adapterpb.AdaptMessageResponse{
+		Payload:      mc.payload,
+		StateUpdates: mc.stateUpdates,
+	}, nil
+}
+
+// MockPayloadAdaptMessageGrpc mocks AdaptMessage calls of the given protocol,
+// answering each request payload with the payload returned by respond.
+func MockPayloadAdaptMessageGrpc(
+	protocol string,
+	respond func(payload []byte) ([]byte, error),
+) {
+	AdaptMessageGrpc = func(
+		ctx context.Context,
+		req *adapterpb.AdaptMessageRequest,
+		cl *AdapterClient,
+	) (adapterpb.Adapter_AdaptMessageClient, error) {
+		if req.Protocol != protocol {
+			return nil, errors.New("unsupported protocol type")
+		}
+		payload, err := respond(req.Payload)
+		if err != nil {
+			return nil, err
+		}
+		return &Mock_Payload_AdaptMessageClient{payload: payload}, nil
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/google/uuid"
+)
+
+// TopologyOverride holds the values reported to drivers in the rows of
+// system.local and system.peers in place of those of Spanner, ie: the data
+// center expected by the DC-aware load balancing policies of applications.
+// Empty values are not overridden.
+type TopologyOverride struct {
+	// Cluster name reported in system.local.
+	ClusterName string
+	// Data center reported in system.local and system.peers.
+	DataCenter string
+	// Rack reported in system.local and system.peers.
+	Rack string
+	// Host id, a UUID, reported in system.local.
+	HostID string
+	// Cassandra release version reported in system.local and system.peers.
+	ReleaseVersion string
+}
+
+// validateTopologyOverride checks the host id of topology, if any.
+func validateTopologyOverride(topology *TopologyOverride) error {
+	if topology == nil || topology.HostID == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(topology.HostID); err != nil {
+		return fmt.Errorf("invalid TopologyOverride host id %q: %w", topology.HostID, err)
+	}
+	return nil
+}
+
+// Tables of the system keyspace describing the nodes of the cluster.
+var systemNodeTables = map[string]bool{
+	"local":    true,
+	"peers":    true,
+	"peers_v2": true,
+}
+
+// systemColumnOverrides returns the values replacing the columns of the rows
+// of the given system table, by column name, with Options.CqlshCompatibility
+// and Options.TopologyOverride. Nil values replace the columns with null.
+// Returns nil if no column is replaced.
+func (dc *driverConnection) systemColumnOverrides(table string) map[string][]byte {
+	overrides := make(map[string][]byte)
+	if dc.executor.opts.CqlshCompatibility {
+		overrides["release_version"] = []byte(cqlshReleaseVersion)
+		// Without tokens, cqlsh exports whole tables with paged queries, in
+		// place of token range queries.
+		overrides["tokens"] = nil
+	}
+	if topology := dc.executor.opts.TopologyOverride; topology != nil {
+		set := func(column, value string) {
+			if value != "" {
+				overrides[column] = []byte(value)
+			}
+		}
+		set("data_center", topology.DataCenter)
+		set("rack", topology.Rack)
+		set("release_version", topology.ReleaseVersion)
+		if table == "local" {
+			set("cluster_name", topology.ClusterName)
+			if id, err := uuid.Parse(topology.HostID); err == nil {
+				overrides["host_id"] = id[:]
+			}
+		}
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	return overrides
+}
+
+// overrideSystemColumns replaces the columns of systemColumnOverrides in the
+// encoded rows returned for queries on system.local and system.peers.
+func (dc *driverConnection) overrideSystemColumns(
+	req *requestState,
+	encoded []byte,
+) []byte {
+	if !dc.executor.opts.CqlshCompatibility && dc.executor.opts.TopologyOverride == nil {
+		return encoded
+	}
+	query, ok := req.frame.Body.Message.(*message.Query)
+	if !ok {
+		return encoded
+	}
+	m := selectFromPattern.FindStringSubmatch(query.Query)
+	if m == nil || !strings.EqualFold(m[2], "system") ||
+		!systemNodeTables[strings.ToLower(m[3])] {
+		return encoded
+	}
+	overrides := dc.systemColumnOverrides(strings.ToLower(m[3]))
+	if overrides == nil {
+		return encoded
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		rows, ok := frm.Body.Message.(*message.RowsResult)
+		if !ok || rows.Metadata == nil {
+			return
+		}
+		for i, column := range rows.Metadata.Columns {
+			value, ok := overrides[column.Name]
+			if !ok {
+				continue
+			}
+			for _, row := range rows.Data {
+				if i < len(row) {
+					row[i] = value
+				}
+			}
+		}
+	})
+}
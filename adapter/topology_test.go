@@ -0,0 +1,93 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopologyOverride(t *testing.T) {
+	hostID := "5b6962dd-3f90-4c93-8f61-eabfa4a803e2"
+	dc := &driverConnection{
+		executor: &requestExecutor{opts: &Options{
+			CqlshCompatibility: true,
+			TopologyOverride: &TopologyOverride{
+				ClusterName:    "prod",
+				DataCenter:     "us-east1",
+				HostID:         hostID,
+				ReleaseVersion: "4.1.0",
+			},
+		}},
+		codec: codec,
+	}
+	query := func(table string) *requestState {
+		return &requestState{frame: *frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{
+			Query: "SELECT * FROM system." + table,
+		})}
+	}
+	columns := func(table string) []*message.ColumnMetadata {
+		return virtualColumns("system", table, []columnDef{
+			{"cluster_name", datatype.Varchar},
+			{"data_center", datatype.Varchar},
+			{"rack", datatype.Varchar},
+			{"host_id", datatype.Uuid},
+			{"release_version", datatype.Varchar},
+		})
+	}
+	spannerRow := message.Row{
+		[]byte("spanner"), []byte("dc"), []byte("rack"), make([]byte, 16), []byte("4.0.0"),
+	}
+	id := uuid.MustParse(hostID)
+
+	encoded := encodeResponse(t, &message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 5, Columns: columns("local")},
+		Data:     message.RowSet{spannerRow},
+	})
+	rows := decodeRows(t, dc.overrideSystemColumns(query("local"), encoded))
+	assert.Equal(t, message.RowSet{{
+		[]byte("prod"), []byte("us-east1"), []byte("rack"), id[:], []byte("4.1.0"),
+	}}, rows.Data)
+
+	// The cluster name and host id are only reported in system.local.
+	encoded = encodeResponse(t, &message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 5, Columns: columns("peers")},
+		Data:     message.RowSet{spannerRow},
+	})
+	rows = decodeRows(t, dc.overrideSystemColumns(query("peers"), encoded))
+	assert.Equal(t, message.RowSet{{
+		[]byte("spanner"), []byte("us-east1"), []byte("rack"), make([]byte, 16), []byte("4.1.0"),
+	}}, rows.Data)
+
+	// Other tables are left as is.
+	assert.Equal(t, encoded, dc.overrideSystemColumns(query("size_estimates"), encoded))
+}
+
+func TestValidateTopologyOverride(t *testing.T) {
+	assert.NoError(t, validateTopologyOverride(nil))
+	assert.NoError(t, validateTopologyOverride(&TopologyOverride{DataCenter: "dc1"}))
+	assert.Error(t, validateTopologyOverride(&TopologyOverride{HostID: "host1"}))
+}
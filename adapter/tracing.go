@@ -0,0 +1,303 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/google/uuid"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Maximum number of trace sessions kept by the proxy.
+const maxTraceSessions = 1000
+
+// Length of the header of a v3+ Cassandra frame.
+const cqlHeaderLength = 9
+
+// traceEvent is a step of a traced request.
+type traceEvent struct {
+	id       uuid.UUID
+	activity string
+	elapsed  time.Duration
+}
+
+// traceSession is the proxy side timing breakdown of a request sent with the
+// tracing flag.
+type traceSession struct {
+	id          uuid.UUID
+	client      net.IP
+	coordinator net.IP
+	command     string
+	request     string
+	parameters  map[string]string
+	started     time.Time
+
+	mu       sync.Mutex
+	duration time.Duration
+	events   []traceEvent
+}
+
+// event records a step of the request. No-op on a nil session, which is the
+// case for requests not traced.
+func (ts *traceSession) event(activity string) {
+	if ts == nil {
+		return
+	}
+	id, err := uuid.NewUUID()
+	if err != nil {
+		id = uuid.New()
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.events = append(ts.events, traceEvent{
+		id:       id,
+		activity: activity,
+		elapsed:  time.Since(ts.started),
+	})
+}
+
+// traceStore keeps the most recent trace sessions, served through the
+// system_traces virtual tables.
+type traceStore struct {
+	cache *lru.Cache
+}
+
+func newTraceStore(size int) *traceStore {
+	cache, _ := lru.New(size)
+	return &traceStore{cache: cache}
+}
+
+func (s *traceStore) add(ts *traceSession) {
+	if s != nil {
+		s.cache.Add(ts.id, ts)
+	}
+}
+
+// sessions returns the stored sessions, oldest first.
+func (s *traceStore) sessions() []*traceSession {
+	if s == nil {
+		return nil
+	}
+	var sessions []*traceSession
+	for _, key := range s.cache.Keys() {
+		if ts, ok := s.cache.Peek(key); ok {
+			sessions = append(sessions, ts.(*traceSession))
+		}
+	}
+	return sessions
+}
+
+// tracedRequest is the command name Cassandra reports for a request opcode,
+// and the description of the request.
+type tracedRequest struct {
+	command string
+	request string
+}
+
+// tracedRequests describes the requests recorded in trace sessions.
+var tracedRequests = map[primitive.OpCode]tracedRequest{
+	primitive.OpCodeQuery:   {"QUERY", "Execute CQL3 query"},
+	primitive.OpCodePrepare: {"PREPARE", "Preparing CQL3 query"},
+	primitive.OpCodeExecute: {"EXECUTE", "Execute CQL3 prepared query"},
+	primitive.OpCodeBatch:   {"BATCH", "Execute batch of CQL3 queries"},
+}
+
+// startTrace returns a new trace session if the driver requested tracing of
+// frm, or nil otherwise. The tracing flag is cleared from the frame and its
+// payload, since tracing is answered by the proxy rather than by Spanner.
+func (dc *driverConnection) startTrace(
+	frm *frame.Frame,
+	payload []byte,
+	started time.Time,
+) *traceSession {
+	if !frm.Header.Flags.Contains(primitive.HeaderFlagTracing) {
+		return nil
+	}
+	frm.Header.Flags = frm.Header.Flags.Remove(primitive.HeaderFlagTracing)
+	payload[1] &^= byte(primitive.HeaderFlagTracing)
+	traced, ok := tracedRequests[frm.Header.OpCode]
+	if !ok || dc.adapterClient.traces == nil {
+		return nil
+	}
+	ts := &traceSession{
+		id:          uuid.New(),
+		client:      addrIP(dc.driverConn.RemoteAddr()),
+		coordinator: addrIP(dc.driverConn.LocalAddr()),
+		command:     traced.command,
+		request:     traced.request,
+		parameters:  traceParameters(frm.Body.Message),
+		started:     started,
+	}
+	ts.event("Decoded " + ts.command + " request")
+	return ts
+}
+
+// finishTrace stores a trace session once its response is about to be written
+// back, and sets its id in the response payload.
+func (dc *driverConnection) finishTrace(ts *traceSession, payload []byte) []byte {
+	if ts == nil {
+		return payload
+	}
+	ts.event("Writing response to driver")
+	ts.mu.Lock()
+	ts.duration = time.Since(ts.started)
+	ts.mu.Unlock()
+	dc.adapterClient.traces.add(ts)
+	return withTracingId(payload, ts.id)
+}
+
+// withTracingId inserts a tracing id in the body of a response payload and
+// sets its tracing flag. Compressed and already traced responses are returned
+// unchanged.
+func withTracingId(payload []byte, id uuid.UUID) []byte {
+	if len(payload) < cqlHeaderLength {
+		return payload
+	}
+	flags := primitive.HeaderFlag(payload[1])
+	if flags.Contains(primitive.HeaderFlagCompressed) ||
+		flags.Contains(primitive.HeaderFlagTracing) {
+		return payload
+	}
+	traced := make([]byte, 0, len(payload)+len(id))
+	traced = append(traced, payload[:cqlHeaderLength]...)
+	traced[1] |= byte(primitive.HeaderFlagTracing)
+	bodyLength := binary.BigEndian.Uint32(traced[5:cqlHeaderLength])
+	binary.BigEndian.PutUint32(traced[5:cqlHeaderLength], bodyLength+uint32(len(id)))
+	traced = append(traced, id[:]...)
+	return append(traced, payload[cqlHeaderLength:]...)
+}
+
+func traceParameters(msg message.Message) map[string]string {
+	parameters := make(map[string]string)
+	switch m := msg.(type) {
+	case *message.Query:
+		parameters["query"] = m.Query
+		if m.Options != nil {
+			parameters["consistency_level"] = m.Options.Consistency.String()
+		}
+	case *message.Prepare:
+		parameters["query"] = m.Query
+	case *message.Execute:
+		if m.Options != nil {
+			parameters["consistency_level"] = m.Options.Consistency.String()
+		}
+	case *message.Batch:
+		parameters["consistency_level"] = m.Consistency.String()
+	}
+	return parameters
+}
+
+func addrIP(addr net.Addr) net.IP {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+	return nil
+}
+
+func encodeInet(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+func encodeTextMap(m map[string]string) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(m)))
+	for k, v := range m {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(k)))
+		b = append(b, k...)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
+
+// traceSessionsTable emulates system_traces.sessions with the trace sessions
+// recorded by the proxy.
+var traceSessionsTable = &virtualTable{
+	keyspace: "system_traces",
+	name:     "sessions",
+	columns: virtualColumns("system_traces", "sessions", []columnDef{
+		{"session_id", datatype.Uuid},
+		{"client", datatype.Inet},
+		{"command", datatype.Varchar},
+		{"coordinator", datatype.Inet},
+		{"duration", datatype.Int},
+		{"parameters", datatype.NewMap(datatype.Varchar, datatype.Varchar)},
+		{"request", datatype.Varchar},
+		{"started_at", datatype.Timestamp},
+	}),
+	rows: func(dc *driverConnection, _ string) message.RowSet {
+		var rows message.RowSet
+		for _, ts := range dc.adapterClient.traces.sessions() {
+			ts.mu.Lock()
+			rows = append(rows, message.Row{
+				ts.id[:],
+				encodeInet(ts.client),
+				[]byte(ts.command),
+				encodeInet(ts.coordinator),
+				encodeInt(int32(ts.duration.Microseconds())),
+				encodeTextMap(ts.parameters),
+				[]byte(ts.request),
+				encodeBigint(ts.started.UnixMilli()),
+			})
+			ts.mu.Unlock()
+		}
+		return rows
+	},
+}
+
+// traceEventsTable emulates system_traces.events with the steps of the trace
+// sessions recorded by the proxy.
+var traceEventsTable = &virtualTable{
+	keyspace: "system_traces",
+	name:     "events",
+	columns: virtualColumns("system_traces", "events", []columnDef{
+		{"session_id", datatype.Uuid},
+		{"event_id", datatype.Timeuuid},
+		{"activity", datatype.Varchar},
+		{"source", datatype.Inet},
+		{"source_elapsed", datatype.Int},
+		{"thread", datatype.Varchar},
+	}),
+	rows: func(dc *driverConnection, _ string) message.RowSet {
+		var rows message.RowSet
+		for _, ts := range dc.adapterClient.traces.sessions() {
+			ts.mu.Lock()
+			for _, event := range ts.events {
+				rows = append(rows, message.Row{
+					ts.id[:],
+					event.id[:],
+					[]byte(event.activity),
+					encodeInet(ts.coordinator),
+					encodeInt(int32(event.elapsed.Microseconds())),
+					[]byte("spanner-proxy"),
+				})
+			}
+			ts.mu.Unlock()
+		}
+		return rows
+	},
+}
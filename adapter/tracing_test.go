@@ -0,0 +1,133 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestFrame(t *testing.T, frm *frame.Frame) []byte {
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, frame.NewCodec().EncodeFrame(frm, buf))
+	return buf.Bytes()
+}
+
+func TestWithTracingId(t *testing.T) {
+	response := frame.NewFrame(
+		primitive.ProtocolVersion4,
+		1,
+		&message.VoidResult{},
+	)
+	id := uuid.New()
+	traced := withTracingId(encodeTestFrame(t, response), id)
+
+	decoded, err := frame.NewCodec().DecodeFrame(bytes.NewBuffer(traced))
+	require.NoError(t, err)
+	assert.True(t, decoded.Header.Flags.Contains(primitive.HeaderFlagTracing))
+	require.NotNil(t, decoded.Body.TracingId)
+	assert.Equal(t, primitive.UUID(id), *decoded.Body.TracingId)
+	assert.IsType(t, &message.VoidResult{}, decoded.Body.Message)
+
+	// Already traced responses are left unchanged.
+	assert.Equal(t, traced, withTracingId(traced, uuid.New()))
+}
+
+func TestStartTrace(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	dc := &driverConnection{
+		driverConn:    server,
+		adapterClient: &AdapterClient{traces: newTraceStore(2)},
+	}
+
+	query := frame.NewFrame(
+		primitive.ProtocolVersion4,
+		1,
+		&message.Query{Query: "SELECT * FROM ks.t", Options: &message.QueryOptions{}},
+	)
+	assert.Nil(t, dc.startTrace(query, encodeTestFrame(t, query), time.Now()))
+
+	query.RequestTracingId(true)
+	payload := encodeTestFrame(t, query)
+	ts := dc.startTrace(query, payload, time.Now())
+	require.NotNil(t, ts)
+	assert.False(t, query.Header.Flags.Contains(primitive.HeaderFlagTracing))
+	assert.False(t, primitive.HeaderFlag(payload[1]).Contains(primitive.HeaderFlagTracing))
+	assert.Equal(t, "QUERY", ts.command)
+	assert.Equal(t, "SELECT * FROM ks.t", ts.parameters["query"])
+
+	ts.event("Sent AdaptMessage request to Spanner")
+	dc.finishTrace(ts, encodeTestFrame(t, frame.NewFrame(
+		primitive.ProtocolVersion4,
+		1,
+		&message.VoidResult{},
+	)))
+	sessions := dc.adapterClient.traces.sessions()
+	require.Len(t, sessions, 1)
+	assert.Len(t, sessions[0].events, 3)
+}
+
+func TestTraceTables(t *testing.T) {
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{traces: newTraceStore(2)},
+	}
+	first := &traceSession{id: uuid.New(), started: time.Now()}
+	first.event("Decoded QUERY request")
+	second := &traceSession{id: uuid.New(), started: time.Now()}
+	dc.adapterClient.traces.add(first)
+	dc.adapterClient.traces.add(second)
+
+	query := "SELECT session_id FROM system_traces.sessions WHERE session_id = " +
+		first.id.String()
+	vt, projection, errMsg := lookupVirtualTable(query)
+	require.Nil(t, errMsg)
+	result := vt.serve(dc, query, projection, nil).(*message.RowsResult)
+	assert.Equal(t, message.RowSet{{first.id[:]}}, result.Data)
+
+	query = "SELECT activity FROM system_traces.events WHERE session_id = ?"
+	vt, projection, errMsg = lookupVirtualTable(query)
+	require.Nil(t, errMsg)
+	result = vt.serve(dc, query, projection, &message.QueryOptions{
+		PositionalValues: []*primitive.Value{primitive.NewValue(first.id[:])},
+	}).(*message.RowsResult)
+	assert.Equal(t, message.RowSet{{[]byte("Decoded QUERY request")}}, result.Data)
+
+	assert.IsType(
+		t,
+		&message.Invalid{},
+		vt.serve(dc, query, projection, &message.QueryOptions{}),
+	)
+	assert.IsType(
+		t,
+		&message.Invalid{},
+		vt.serve(dc, "SELECT * FROM system_traces.events WHERE activity = 'a'", projection, nil),
+	)
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Maximum number of statements of an explicit transaction.
+	maxTransactionStatements = 1000
+	// Default number of times an aborted transaction is replayed.
+	defaultMaxTransactionRetries = 10
+)
+
+// transactionControl returns the transaction control statement of query:
+// "begin" for BEGIN|START TRANSACTION, "commit" for COMMIT [TRANSACTION] and
+// "rollback" for ROLLBACK [TRANSACTION]. Returns an empty string for other
+// statements.
+func transactionControl(query string) string {
+	tokens := tokenizeCQL(query)
+	if n := len(tokens); n > 0 && tokens[n-1].is(";") {
+		tokens = tokens[:n-1]
+	}
+	switch {
+	case len(tokens) == 2 && (tokens[0].is("begin") || tokens[0].is("start")) &&
+		tokens[1].is("transaction"):
+		return "begin"
+	case len(tokens) == 0 || len(tokens) > 2 ||
+		(len(tokens) == 2 && !tokens[1].is("transaction")):
+		return ""
+	case tokens[0].is("commit"):
+		return "commit"
+	case tokens[0].is("rollback"):
+		return "rollback"
+	}
+	return ""
+}
+
+// transaction holds the DML statements of an explicit transaction of a
+// driver connection until it is committed.
+type transaction struct {
+	statements []*message.BatchChild
+}
+
+// add buffers the DML statements of a QUERY, EXECUTE or BATCH request. Named
+// values are bound by position, as batches only support positional values.
+// Returns an error message if they can not be part of the transaction.
+func (tx *transaction) add(
+	frm *frame.Frame,
+	markers *preparedMarkers,
+) message.Message {
+	var statements []*message.BatchChild
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		child := &message.BatchChild{Query: msg.Query}
+		if msg.Options != nil && len(msg.Options.NamedValues) > 0 {
+			values, err := positionalValues(
+				bindMarkerNames(msg.Query),
+				msg.Options.NamedValues,
+			)
+			if err != nil {
+				return &message.Invalid{ErrorMessage: err.Error()}
+			}
+			child.Values = values
+		} else if msg.Options != nil {
+			child.Values = msg.Options.PositionalValues
+		}
+		statements = append(statements, child)
+	case *message.Execute:
+		child := &message.BatchChild{Id: msg.QueryId}
+		if msg.Options != nil && len(msg.Options.NamedValues) > 0 {
+			names, ok := markers.lookup(msg.QueryId)
+			if !ok {
+				return &message.Invalid{
+					ErrorMessage: "Named values are only supported in transactions for statements prepared through this proxy",
+				}
+			}
+			values, err := positionalValues(names, msg.Options.NamedValues)
+			if err != nil {
+				return &message.Invalid{ErrorMessage: err.Error()}
+			}
+			child.Values = values
+		} else if msg.Options != nil {
+			child.Values = msg.Options.PositionalValues
+		}
+		statements = append(statements, child)
+	case *message.Batch:
+		statements = append(statements, msg.Children...)
+	}
+	if len(tx.statements)+len(statements) > maxTransactionStatements {
+		return &message.Invalid{ErrorMessage: fmt.Sprintf(
+			"Transactions are limited to %d statements",
+			maxTransactionStatements,
+		)}
+	}
+	tx.statements = append(tx.statements, statements...)
+	return nil
+}
+
+// tryTransaction handles the explicit transactions of the connection, which
+// have the semantics of a logged batch rather than of an interactive
+// transaction:
+//
+//   - BEGIN TRANSACTION opens a transaction.
+//   - DML requests sent within the transaction are buffered and acknowledged
+//     locally, without being executed: their errors are only returned by
+//     COMMIT.
+//   - Reads sent within the transaction are rejected, as they could not
+//     observe the buffered writes.
+//   - COMMIT replaces frm and payload with a logged batch of the buffered
+//     statements, which Spanner applies atomically in a read-write
+//     transaction.
+//   - ROLLBACK discards the buffered statements.
+//
+// Returns the response of the requests answered locally, or nil if the
+// request must be forwarded, along with whether the forwarded request commits
+// a transaction.
+func (dc *driverConnection) tryTransaction(
+	frm *frame.Frame,
+	payload *[]byte,
+) (message.Message, bool) {
+	query, _ := frm.Body.Message.(*message.Query)
+	control := ""
+	if query != nil {
+		control = transactionControl(query.Query)
+	}
+	switch {
+	case control == "begin":
+		if dc.transaction != nil {
+			return &message.Invalid{ErrorMessage: "A transaction is already in progress"}, false
+		}
+		dc.transaction = &transaction{}
+		return &message.VoidResult{}, false
+	case control == "rollback":
+		if dc.transaction == nil {
+			return &message.Invalid{ErrorMessage: "No transaction in progress"}, false
+		}
+		dc.transaction = nil
+		return &message.VoidResult{}, false
+	case control == "commit":
+		if dc.transaction == nil {
+			return &message.Invalid{ErrorMessage: "No transaction in progress"}, false
+		}
+		tx := dc.transaction
+		dc.transaction = nil
+		if len(tx.statements) == 0 {
+			return &message.VoidResult{}, false
+		}
+		if msg := dc.commitTransaction(tx, frm, query, payload); msg != nil {
+			return msg, false
+		}
+		return nil, true
+	case dc.transaction != nil && isDML(frm):
+		if msg := dc.transaction.add(frm, dc.executor.markers); msg != nil {
+			return msg, false
+		}
+		return &message.VoidResult{}, false
+	case dc.transaction != nil && isTransactionRead(frm):
+		return &message.Invalid{
+			ErrorMessage: "Reads are not supported within a transaction, " +
+				"whose statements are only applied as a batch on COMMIT",
+		}, false
+	default:
+		return nil, false
+	}
+}
+
+// isTransactionRead reports whether frm is a read that can not be served
+// within a transaction: a read-only QUERY or EXECUTE request.
+func isTransactionRead(frm *frame.Frame) bool {
+	switch frm.Body.Message.(type) {
+	case *message.Query, *message.Execute:
+		return !isDML(frm)
+	}
+	return false
+}
+
+// commitTransaction replaces the COMMIT request frm and its payload with a
+// logged batch of the statements of tx.
+func (dc *driverConnection) commitTransaction(
+	tx *transaction,
+	frm *frame.Frame,
+	commit *message.Query,
+	payload *[]byte,
+) message.Message {
+	batch := &message.Batch{
+		Type:        primitive.BatchTypeLogged,
+		Children:    tx.statements,
+		Consistency: primitive.ConsistencyLevelLocalQuorum,
+	}
+	if options := commit.Options; options != nil {
+		batch.Consistency = options.Consistency
+		batch.SerialConsistency = options.SerialConsistency
+		batch.Keyspace = options.Keyspace
+	}
+	frm.Header.OpCode = primitive.OpCodeBatch
+	frm.Body.Message = batch
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(frm, buf); err != nil {
+		return &message.ServerError{ErrorMessage: err.Error()}
+	}
+	*payload = buf.Bytes()
+	return nil
+}
+
+// describeTransactionFailure amends the failure of the request committing a
+// transaction, whose statements were acknowledged to the driver before being
+// executed, so that it reports the fate of all of them. The COMMIT can not be
+// retried on its own, as the statements of the transaction are discarded.
+func describeTransactionFailure(spannerErr *SpannerError, req *requestState) {
+	statements := 0
+	if batch, ok := req.frame.Body.Message.(*message.Batch); ok {
+		statements = len(batch.Children)
+	}
+	outcome := "may not have been applied"
+	if spannerErr.Code == codes.Aborted {
+		outcome = "were not applied"
+	}
+	spannerErr.Message = fmt.Sprintf(
+		"transaction failed on COMMIT after %d replays, its %d statements %s: %s",
+		req.replays,
+		statements,
+		outcome,
+		spannerErr.Message,
+	)
+	spannerErr.Retryable = false
+}
+
+// replayDelay returns the delay before the replay-th replay of an aborted
+// transaction, the retry delay returned by Spanner if any.
+func replayDelay(err error, replay int) time.Duration {
+	if delay, ok := ExtractRetryDelay(err); ok {
+		return delay
+	}
+	delay := float64(DefaultRetryBackoff.Initial) *
+		math.Pow(DefaultRetryBackoff.Multiplier, float64(replay-1))
+	return min(time.Duration(delay), DefaultRetryBackoff.Max)
+}
+
+// replayAbortedTransaction reports whether the request committing an explicit
+// transaction must be sent again because Spanner aborted the transaction with
+// err, after waiting for the replay delay. As an aborted transaction applied
+// none of its statements, replaying the whole batch is safe. Transactions are
+// replayed up to Options.MaxTransactionRetries times, after which the failure
+// is reported on COMMIT for all the statements of the transaction.
+func (dc *driverConnection) replayAbortedTransaction(
+	ctx context.Context,
+	req *requestState,
+	err error,
+) bool {
+	if !req.commit || status.Code(err) != codes.Aborted {
+		return false
+	}
+	maxRetries := dc.executor.opts.MaxTransactionRetries
+	if maxRetries == 0 {
+		maxRetries = defaultMaxTransactionRetries
+	}
+	if req.replays >= maxRetries {
+		return false
+	}
+	req.replays++
+	delay := replayDelay(err, req.replays)
+	logger.Debug("Replaying aborted transaction",
+		zap.Int("connectionID", dc.connectionID),
+		zap.Int("replay", req.replays),
+		zap.Duration("delay", delay),
+		zap.Error(err))
+	if err := gax.Sleep(ctx, delay); err != nil {
+		return false
+	}
+	dc.adapterClient.stats.recordRetry()
+	return true
+}
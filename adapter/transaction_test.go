@@ -0,0 +1,220 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/gax-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTryTransaction(t *testing.T) {
+	dc := &driverConnection{codec: codec, executor: &requestExecutor{}}
+	var commit bool
+	send := func(msg message.Message) (*frame.Frame, []byte, message.Message) {
+		frm := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(frm, buf))
+		payload := buf.Bytes()
+		var response message.Message
+		response, commit = dc.tryTransaction(frm, &payload)
+		return frm, payload, response
+	}
+	value := &primitive.Value{Type: primitive.ValueTypeRegular, Contents: []byte{1}}
+
+	// Statements outside of transactions are forwarded.
+	_, _, response := send(&message.Query{Query: "INSERT INTO t (id) VALUES (1)"})
+	assert.Nil(t, response)
+	_, _, response = send(&message.Query{Query: "COMMIT"})
+	assert.IsType(t, &message.Invalid{}, response)
+
+	_, _, response = send(&message.Query{Query: "BEGIN TRANSACTION;"})
+	assert.IsType(t, &message.VoidResult{}, response)
+	_, _, response = send(&message.Query{Query: "begin transaction"})
+	assert.IsType(t, &message.Invalid{}, response)
+
+	// DML statements are acknowledged locally, reads are rejected.
+	_, _, response = send(&message.Query{
+		Query:   "INSERT INTO t (id) VALUES (?)",
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{value}},
+	})
+	assert.IsType(t, &message.VoidResult{}, response)
+	_, _, response = send(&message.Execute{
+		QueryId: []byte("W1"),
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{value}},
+	})
+	assert.IsType(t, &message.VoidResult{}, response)
+	_, _, response = send(&message.Query{Query: "SELECT * FROM t"})
+	assert.IsType(t, &message.Invalid{}, response)
+	_, _, response = send(&message.Execute{QueryId: []byte("R1")})
+	assert.IsType(t, &message.Invalid{}, response)
+	// Named values are bound by position.
+	_, _, response = send(&message.Query{
+		Query: "UPDATE t SET a = :a WHERE id = :id",
+		Options: &message.QueryOptions{
+			NamedValues: map[string]*primitive.Value{"id": value, "A": primitive.NewValue(nil)},
+		},
+	})
+	assert.IsType(t, &message.VoidResult{}, response)
+	_, _, response = send(&message.Query{
+		Query: "UPDATE t SET a = :a WHERE id = 1",
+		Options: &message.QueryOptions{
+			NamedValues: map[string]*primitive.Value{"b": value},
+		},
+	})
+	assert.IsType(t, &message.Invalid{}, response)
+	_, _, response = send(&message.Execute{
+		QueryId: []byte("W2"),
+		Options: &message.QueryOptions{
+			NamedValues: map[string]*primitive.Value{"a": value},
+		},
+	})
+	assert.IsType(t, &message.Invalid{}, response)
+
+	// COMMIT is replaced with a logged batch of the statements.
+	frm, payload, response := send(&message.Query{
+		Query:   "COMMIT TRANSACTION",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelQuorum},
+	})
+	require.Nil(t, response)
+	assert.True(t, commit)
+	assert.Equal(t, primitive.OpCodeBatch, frm.Header.OpCode)
+	decoded, err := codec.DecodeFrame(bytes.NewBuffer(payload))
+	require.NoError(t, err)
+	batch, ok := decoded.Body.Message.(*message.Batch)
+	require.True(t, ok)
+	assert.Equal(t, primitive.BatchTypeLogged, batch.Type)
+	assert.Equal(t, primitive.ConsistencyLevelQuorum, batch.Consistency)
+	require.Len(t, batch.Children, 3)
+	assert.Equal(t, "INSERT INTO t (id) VALUES (?)", batch.Children[0].Query)
+	assert.Equal(t, []byte("W1"), batch.Children[1].Id)
+	require.Len(t, batch.Children[2].Values, 2)
+	assert.Equal(t, primitive.ValueTypeNull, batch.Children[2].Values[0].Type)
+	assert.Equal(t, value, batch.Children[2].Values[1])
+	assert.Nil(t, dc.transaction)
+
+	// ROLLBACK discards the statements.
+	_, _, response = send(&message.Query{Query: "BEGIN TRANSACTION"})
+	assert.IsType(t, &message.VoidResult{}, response)
+	_, _, response = send(&message.Query{Query: "DELETE FROM t WHERE id = 1"})
+	assert.IsType(t, &message.VoidResult{}, response)
+	_, _, response = send(&message.Query{Query: "ROLLBACK"})
+	assert.IsType(t, &message.VoidResult{}, response)
+	assert.False(t, commit)
+	assert.Nil(t, dc.transaction)
+
+	// Empty transactions are committed locally.
+	send(&message.Query{Query: "BEGIN TRANSACTION"})
+	_, _, response = send(&message.Query{Query: "COMMIT"})
+	assert.IsType(t, &message.VoidResult{}, response)
+}
+
+func TestTransactionControl(t *testing.T) {
+	tests := map[string]string{
+		"BEGIN TRANSACTION":                    "begin",
+		"start transaction;":                   "begin",
+		"/* batch */ BEGIN\nTRANSACTION ;":     "begin",
+		"COMMIT":                               "commit",
+		"commit transaction -- done":           "commit",
+		"ROLLBACK TRANSACTION":                 "rollback",
+		"BEGIN":                                "",
+		"BEGIN BATCH":                          "",
+		"COMMIT WORK":                          "",
+		"-- COMMIT":                            "",
+		"'COMMIT'":                             "",
+		"SELECT * FROM t WHERE v = 'ROLLBACK'": "",
+		"INSERT INTO t (v) VALUES ('commit')":  "",
+	}
+	for query, want := range tests {
+		assert.Equal(t, want, transactionControl(query), query)
+	}
+}
+
+func TestReplayAbortedTransaction(t *testing.T) {
+	defer func(backoff gax.Backoff) { DefaultRetryBackoff = backoff }(DefaultRetryBackoff)
+	DefaultRetryBackoff = gax.Backoff{
+		Initial:    time.Millisecond,
+		Max:        2 * time.Millisecond,
+		Multiplier: 2,
+	}
+	dc := &driverConnection{
+		adapterClient: &AdapterClient{},
+		executor:      &requestExecutor{opts: &Options{MaxTransactionRetries: 2}},
+	}
+	aborted := status.Error(codes.Aborted, "transaction aborted")
+	ctx := context.Background()
+
+	// Only committed transactions are replayed, on ABORTED.
+	assert.False(t, dc.replayAbortedTransaction(ctx, &requestState{}, aborted))
+	req := &requestState{commit: true}
+	assert.False(t, dc.replayAbortedTransaction(ctx, req, nil))
+	assert.False(t, dc.replayAbortedTransaction(
+		ctx,
+		req,
+		status.Error(codes.InvalidArgument, "invalid"),
+	))
+
+	assert.True(t, dc.replayAbortedTransaction(ctx, req, aborted))
+	assert.True(t, dc.replayAbortedTransaction(ctx, req, aborted))
+	assert.False(t, dc.replayAbortedTransaction(ctx, req, aborted))
+	assert.Equal(t, 2, req.replays)
+
+	dc.executor.opts.MaxTransactionRetries = -1
+	assert.False(t, dc.replayAbortedTransaction(ctx, &requestState{commit: true}, aborted))
+
+	assert.Equal(t, time.Millisecond, replayDelay(aborted, 1))
+	assert.Equal(t, 2*time.Millisecond, replayDelay(aborted, 2))
+	assert.Equal(t, 2*time.Millisecond, replayDelay(aborted, 5))
+}
+
+func TestTransactionFailure(t *testing.T) {
+	dc := &driverConnection{adapterClient: &AdapterClient{}}
+	frm := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Batch{
+		Children: []*message.BatchChild{{Query: "INSERT"}, {Query: "UPDATE"}},
+	})
+	req := &requestState{frame: *frm, commit: true, replays: 2}
+
+	msg := dc.requestErrorMessage(req, status.Error(codes.Aborted, "conflict"))
+	serverErr, ok := msg.(*message.ServerError)
+	require.True(t, ok)
+	spannerErr, ok := ParseSpannerError(serverErr.ErrorMessage)
+	require.True(t, ok)
+	assert.Equal(t, codes.Aborted, spannerErr.Code)
+	assert.Contains(t, spannerErr.Message, "after 2 replays")
+	assert.Contains(t, spannerErr.Message, "its 2 statements were not applied: conflict")
+	assert.False(t, spannerErr.Retryable)
+
+	// The outcome of transactions failing otherwise is unknown.
+	msg = dc.requestErrorMessage(req, status.Error(codes.Unavailable, "unavailable"))
+	spannerErr, ok = ParseSpannerError(msg.(*message.ServerError).ErrorMessage)
+	require.True(t, ok)
+	assert.Contains(t, spannerErr.Message, "may not have been applied")
+	assert.False(t, spannerErr.Retryable)
+}
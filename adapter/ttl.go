@@ -0,0 +1,571 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Maximum TTL accepted by Cassandra, 20 years.
+const maxTTLSeconds = 20 * 365 * 24 * 60 * 60
+
+// Name of the bind marker of the expiration column in statements using named
+// bind markers.
+const expirationMarker = "spanner_expiration"
+
+// ttlRewrite is an INSERT or UPDATE statement whose USING TTL clause was
+// rewritten to set the expiration column of its table instead.
+type ttlRewrite struct {
+	query string
+	// Number of bind markers of the original statement.
+	originalMarkers int
+	// Names of the bind markers of the original statement, empty for
+	// positional markers.
+	names []string
+	// For each bind marker of the rewritten statement, the index of the bind
+	// marker of the original statement it is bound to, or -1 for the
+	// expiration column.
+	markers []int
+	// Index of the bind marker of the original statement holding the TTL, or
+	// -1 if the TTL is the literal ttl.
+	ttlMarker int
+	ttl       int64
+}
+
+// lookupTTLColumn returns the expiration column configured for table, either
+// qualified by its keyspace or not.
+func lookupTTLColumn(columns map[string]string, table string) (string, bool) {
+	for t, column := range columns {
+		if matchesTable([]string{t}, table) {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// isBindMarker reports whether tokens[i] starts a positional or named bind
+// marker.
+func isBindMarker(tokens []cqlToken, i int) bool {
+	if tokens[i].is("?") {
+		return true
+	}
+	// Named markers are a colon immediately followed by a name. Field
+	// separators of UDT and map literals are followed by a value instead:
+	// literals, constants and function calls.
+	if !tokens[i].is(":") || i+1 == len(tokens) {
+		return false
+	}
+	name := tokens[i+1]
+	if name.start != tokens[i].end {
+		return false
+	}
+	switch {
+	case name.kind == cqlQuotedIdentifier:
+		return true
+	case name.kind != cqlIdentifier:
+		return false
+	case name.is("true") || name.is("false") || name.is("null") ||
+		name.is("nan") || name.is("infinity"):
+		return false
+	}
+	return i+2 == len(tokens) || !tokens[i+2].is("(")
+}
+
+// closingParen returns the index of the parenthesis closing the one at
+// tokens[i], or -1.
+func closingParen(tokens []cqlToken, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch {
+		case tokens[i].is("("):
+			depth++
+		case tokens[i].is(")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// rewriteTTL rewrites an INSERT or UPDATE statement with a USING TTL clause
+// to set the expiration column of its table, configured in columns, instead.
+// Returns nil for other statements, and an error if the TTL can not be
+// translated.
+func rewriteTTL(query string, columns map[string]string) (*ttlRewrite, error) {
+	tokens := tokenizeCQL(query)
+	if len(tokens) < 2 {
+		return nil, nil
+	}
+	var tableStart int
+	switch {
+	case tokens[0].is("insert") && tokens[1].is("into"):
+		tableStart = 2
+	case tokens[0].is("update"):
+		tableStart = 1
+	default:
+		return nil, nil
+	}
+
+	// Find the TTL in the USING clause, either "USING TTL x [AND TIMESTAMP y]"
+	// or "USING TIMESTAMP y AND TTL x".
+	using, ttl := -1, -1
+	for i := tableStart; i+2 < len(tokens); i++ {
+		if !tokens[i].is("using") {
+			continue
+		}
+		using = i
+		for j := i + 1; j+1 < len(tokens); j++ {
+			if tokens[j].is("ttl") {
+				ttl = j
+			} else if !tokens[j].is("timestamp") {
+				break
+			}
+			j += 2
+			if tokens[j-1].is(":") {
+				j++
+			}
+			if j >= len(tokens) || !tokens[j].is("and") {
+				break
+			}
+		}
+		break
+	}
+	if ttl < 0 {
+		return nil, nil
+	}
+	table, ok := parseTableName(tokens, tableStart)
+	if !ok {
+		return nil, fmt.Errorf("USING TTL is not supported: can not parse the table of %q", query)
+	}
+	column, ok := lookupTTLColumn(columns, table)
+	if !ok {
+		return nil, fmt.Errorf(
+			"USING TTL is not supported on table %s: Spanner expires rows with row deletion policies, configure the expiration column of the table with Options.TTLColumns",
+			table,
+		)
+	}
+
+	rw := &ttlRewrite{ttlMarker: -1}
+	type marker struct {
+		offset, index int
+	}
+	var markers []marker
+	for i := range tokens {
+		if !isBindMarker(tokens, i) {
+			continue
+		}
+		rw.names = append(rw.names, markerName(tokens, i))
+		if i == ttl+1 {
+			rw.ttlMarker = rw.originalMarkers
+		} else {
+			markers = append(markers, marker{tokens[i].start, rw.originalMarkers})
+		}
+		rw.originalMarkers++
+	}
+	if rw.ttlMarker < 0 {
+		if tokens[ttl+1].kind != cqlNumber {
+			return nil, fmt.Errorf("invalid TTL %q", tokens[ttl+1].text)
+		}
+		seconds, err := strconv.ParseInt(tokens[ttl+1].text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TTL %q", tokens[ttl+1].text)
+		}
+		if err := validateTTL(seconds); err != nil {
+			return nil, err
+		}
+		rw.ttl = seconds
+	}
+	valueEnd := ttl + 2
+	if tokens[ttl+1].is(":") {
+		valueEnd++
+	}
+
+	// Remove the TTL from the USING clause, with the whitespace before it.
+	var edits []queryEdit
+	switch {
+	case ttl == using+1 && valueEnd+1 < len(tokens) && tokens[valueEnd].is("and"):
+		edits = append(edits, queryEdit{tokens[ttl].start, tokens[valueEnd+1].start, ""})
+	case ttl == using+1:
+		edits = append(edits, queryEdit{tokens[using-1].end, tokens[valueEnd-1].end, ""})
+	default:
+		edits = append(edits, queryEdit{tokens[ttl-2].end, tokens[valueEnd-1].end, ""})
+	}
+
+	// Set the expiration column, with a named bind marker if the statement
+	// uses named markers.
+	bindMarker := "?"
+	for _, name := range rw.names {
+		if name != "" {
+			bindMarker = ":" + expirationMarker
+			break
+		}
+	}
+	var expiration int
+	if tableStart == 2 {
+		open := tableStart + 1
+		if open < len(tokens) && tokens[open].is(".") {
+			open += 2
+		}
+		if open >= len(tokens) || !tokens[open].is("(") {
+			return nil, fmt.Errorf("USING TTL is only supported for INSERT statements with a column list")
+		}
+		closeColumns := closingParen(tokens, open)
+		if closeColumns < 0 || closeColumns+2 >= len(tokens) ||
+			!tokens[closeColumns+1].is("values") ||
+			!tokens[closeColumns+2].is("(") {
+			return nil, fmt.Errorf("USING TTL is only supported for INSERT statements with a column list")
+		}
+		closeValues := closingParen(tokens, closeColumns+2)
+		if closeValues < 0 {
+			return nil, fmt.Errorf("invalid INSERT statement %q", query)
+		}
+		expiration = tokens[closeValues].start
+		edits = append(edits,
+			queryEdit{tokens[closeColumns].start, tokens[closeColumns].start, ", " + column},
+			queryEdit{expiration, expiration, ", " + bindMarker},
+		)
+	} else {
+		set := -1
+		for i := using; i < len(tokens); i++ {
+			if tokens[i].is("set") {
+				set = i
+				break
+			}
+		}
+		if set < 0 {
+			return nil, fmt.Errorf("invalid UPDATE statement %q", query)
+		}
+		expiration = tokens[set].end
+		edits = append(edits, queryEdit{expiration, expiration, " " + column + " = " + bindMarker + ","})
+	}
+	markers = append(markers, marker{expiration, -1})
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].offset < markers[j].offset
+	})
+	for _, m := range markers {
+		rw.markers = append(rw.markers, m.index)
+	}
+
+	rw.query = applyEdits(query, edits)
+	return rw, nil
+}
+
+func validateTTL(seconds int64) error {
+	if seconds < 0 {
+		return fmt.Errorf("a TTL must be greater or equal to 0, got %d", seconds)
+	}
+	if seconds > maxTTLSeconds {
+		return fmt.Errorf("a TTL must be lower or equal to %d, got %d", maxTTLSeconds, seconds)
+	}
+	return nil
+}
+
+// bind returns the values of the rewritten statement from the values bound to
+// the original statement. The expiration column is set to now plus the TTL,
+// or to null for a TTL of 0.
+func (rw *ttlRewrite) bind(
+	values []*primitive.Value,
+	now time.Time,
+) ([]*primitive.Value, error) {
+	if len(values) != rw.originalMarkers {
+		return nil, fmt.Errorf(
+			"there were %d markers(?) in the statement but %d values provided",
+			rw.originalMarkers,
+			len(values),
+		)
+	}
+	ttl := rw.ttl
+	if rw.ttlMarker >= 0 {
+		value := values[rw.ttlMarker]
+		if value != nil && value.Type == primitive.ValueTypeRegular {
+			if len(value.Contents) != 4 {
+				return nil, fmt.Errorf("invalid TTL value of %d bytes", len(value.Contents))
+			}
+			ttl = int64(int32(binary.BigEndian.Uint32(value.Contents)))
+		}
+		if err := validateTTL(ttl); err != nil {
+			return nil, err
+		}
+	}
+	expiration := primitive.NewValue(nil)
+	if ttl > 0 {
+		contents := make([]byte, 8)
+		binary.BigEndian.PutUint64(
+			contents,
+			uint64(now.Add(time.Duration(ttl)*time.Second).UnixMilli()),
+		)
+		expiration = primitive.NewValue(contents)
+	}
+	bound := make([]*primitive.Value, len(rw.markers))
+	for i, index := range rw.markers {
+		if index < 0 {
+			bound[i] = expiration
+		} else {
+			bound[i] = values[index]
+		}
+	}
+	return bound, nil
+}
+
+// variables returns the bound variables of the original statement from the
+// bound variables of the rewritten statement returned by the server, so that
+// drivers bind values to the statement they prepared.
+func (rw *ttlRewrite) variables(
+	vars *message.VariablesMetadata,
+) *message.VariablesMetadata {
+	if vars == nil || len(vars.Columns) != len(rw.markers) {
+		return vars
+	}
+	columns := make([]*message.ColumnMetadata, rw.originalMarkers)
+	var expiration *message.ColumnMetadata
+	for i, index := range rw.markers {
+		if index < 0 {
+			expiration = vars.Columns[i]
+		} else {
+			columns[index] = vars.Columns[i]
+		}
+	}
+	if rw.ttlMarker >= 0 {
+		name := "[ttl]"
+		if rw.names[rw.ttlMarker] != "" {
+			name = rw.names[rw.ttlMarker]
+		}
+		columns[rw.ttlMarker] = &message.ColumnMetadata{
+			Keyspace: expiration.Keyspace,
+			Table:    expiration.Table,
+			Name:     name,
+			Type:     datatype.Int,
+		}
+	}
+	original := &message.VariablesMetadata{Columns: columns}
+	for _, pk := range vars.PkIndices {
+		if index := rw.markers[pk]; index >= 0 {
+			original.PkIndices = append(original.PkIndices, uint16(index))
+		}
+	}
+	return original
+}
+
+// ttlStatements remembers the rewrites of prepared query ids, so that the
+// values of EXECUTE requests can be bound to the rewritten statements.
+type ttlStatements struct {
+	cache *lru.Cache
+}
+
+func newTTLStatements(size int) (*ttlStatements, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &ttlStatements{cache: cache}, nil
+}
+
+func (ts *ttlStatements) remember(id []byte, rw *ttlRewrite) {
+	if ts != nil {
+		ts.cache.Add(string(id), rw)
+	}
+}
+
+func (ts *ttlStatements) lookup(id []byte) (*ttlRewrite, bool) {
+	if ts == nil {
+		return nil, false
+	}
+	rw, ok := ts.cache.Get(string(id))
+	if !ok {
+		return nil, false
+	}
+	return rw.(*ttlRewrite), true
+}
+
+// mayUseTTL reports whether a statement may have a USING TTL clause, to skip
+// tokenizing the others.
+func mayUseTTL(query string) bool {
+	return strings.Contains(strings.ToLower(query), "ttl")
+}
+
+// tryTranslateTTL rewrites the statements of req that set a TTL to set the
+// expiration column of their table instead, as configured by
+// Options.TTLColumns. Returns an Invalid error message if a TTL can not be
+// translated.
+func (dc *driverConnection) tryTranslateTTL(req *requestState) message.Message {
+	columns := dc.executor.opts.TTLColumns
+	statements := dc.executor.ttlStatements
+	now := time.Now()
+	var translated message.Message
+	var err error
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Query:
+		if !mayUseTTL(msg.Query) {
+			return nil
+		}
+		var rw *ttlRewrite
+		if rw, err = rewriteTTL(msg.Query, columns); rw != nil {
+			var options *message.QueryOptions
+			if options, err = bindTTLOptions(rw, msg.Options, now); err == nil {
+				query := *msg
+				query.Query = rw.query
+				query.Options = options
+				translated = &query
+			}
+		}
+	case *message.Prepare:
+		if !mayUseTTL(msg.Query) {
+			return nil
+		}
+		var rw *ttlRewrite
+		if rw, err = rewriteTTL(msg.Query, columns); rw != nil {
+			req.ttl = rw
+			prepare := *msg
+			prepare.Query = rw.query
+			translated = &prepare
+		}
+	case *message.Execute:
+		if rw, ok := statements.lookup(msg.QueryId); ok {
+			var options *message.QueryOptions
+			if options, err = bindTTLOptions(rw, msg.Options, now); err == nil {
+				execute := *msg
+				execute.Options = options
+				translated = &execute
+			}
+		}
+	case *message.Batch:
+		var children []*message.BatchChild
+		for i, child := range msg.Children {
+			var rw *ttlRewrite
+			if child.Query == "" {
+				rw, _ = statements.lookup(child.Id)
+			} else if mayUseTTL(child.Query) {
+				if rw, err = rewriteTTL(child.Query, columns); err != nil {
+					break
+				}
+			}
+			if rw == nil {
+				continue
+			}
+			values, bindErr := rw.bind(child.Values, now)
+			if bindErr != nil {
+				err = bindErr
+				break
+			}
+			if children == nil {
+				children = append([]*message.BatchChild(nil), msg.Children...)
+			}
+			children[i] = &message.BatchChild{Id: child.Id, Values: values}
+			if child.Query != "" {
+				children[i].Query = rw.query
+			}
+		}
+		if err == nil && children != nil {
+			batch := *msg
+			batch.Children = children
+			translated = &batch
+		}
+	}
+	if err != nil {
+		return &message.Invalid{ErrorMessage: err.Error()}
+	}
+	if translated == nil {
+		return nil
+	}
+	return dc.replaceMessage(req, translated)
+}
+
+// replaceMessage replaces the message of req with a rewritten message, and
+// encodes it again as the payload sent to Spanner.
+func (dc *driverConnection) replaceMessage(
+	req *requestState,
+	msg message.Message,
+) message.Message {
+	frm := req.frame.DeepCopy()
+	frm.Body.Message = msg
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(frm, buf); err != nil {
+		return &message.ServerError{ErrorMessage: err.Error()}
+	}
+	req.frame = *frm
+	req.pb.Payload = buf.Bytes()
+	return nil
+}
+
+// bindTTLOptions returns a copy of options with the positional or named values
+// bound to the rewritten statement by position.
+func bindTTLOptions(
+	rw *ttlRewrite,
+	options *message.QueryOptions,
+	now time.Time,
+) (*message.QueryOptions, error) {
+	bound := &message.QueryOptions{}
+	if options != nil {
+		copied := *options
+		bound = &copied
+	}
+	// The rewritten statement is bound by position.
+	if len(bound.NamedValues) > 0 {
+		values, err := positionalValues(rw.names, bound.NamedValues)
+		if err != nil {
+			return nil, err
+		}
+		bound.PositionalValues = values
+		bound.NamedValues = nil
+	}
+	values, err := rw.bind(bound.PositionalValues, now)
+	if err != nil {
+		return nil, err
+	}
+	bound.PositionalValues = values
+	return bound, nil
+}
+
+// translatePreparedTTL remembers the rewrite of a prepared statement under the
+// prepared query id returned by the server, and returns the encoded response
+// with the bound variables of the statement prepared by the driver.
+func (dc *driverConnection) translatePreparedTTL(
+	req *requestState,
+	encoded []byte,
+) []byte {
+	if req.ttl == nil {
+		return encoded
+	}
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return encoded
+	}
+	prepared, ok := frm.Body.Message.(*message.PreparedResult)
+	if !ok {
+		return encoded
+	}
+	dc.executor.ttlStatements.remember(prepared.PreparedQueryId, req.ttl)
+	prepared.VariablesMetadata = req.ttl.variables(prepared.VariablesMetadata)
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(frm, buf); err != nil {
+		return encoded
+	}
+	return buf.Bytes()
+}
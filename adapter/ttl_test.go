@@ -0,0 +1,295 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTTLColumns = map[string]string{"sessions": "expires_at"}
+
+func TestRewriteTTL(t *testing.T) {
+	testCases := []struct {
+		query       string
+		wantQuery   string
+		wantMarkers []int
+		wantTTL     int64
+		wantMarker  int
+	}{
+		{
+			query:       "INSERT INTO ks.sessions (id, data) VALUES (?, ?) USING TTL 60",
+			wantQuery:   "INSERT INTO ks.sessions (id, data, expires_at) VALUES (?, ?, ?)",
+			wantMarkers: []int{0, 1, -1},
+			wantTTL:     60,
+			wantMarker:  -1,
+		},
+		{
+			query:       "INSERT INTO sessions (id) VALUES (?) USING TTL ? AND TIMESTAMP ?",
+			wantQuery:   "INSERT INTO sessions (id, expires_at) VALUES (?, ?) USING TIMESTAMP ?",
+			wantMarkers: []int{0, -1, 2},
+			wantMarker:  1,
+		},
+		{
+			query:       "INSERT INTO sessions (id) VALUES (?) USING TIMESTAMP ? AND TTL ?",
+			wantQuery:   "INSERT INTO sessions (id, expires_at) VALUES (?, ?) USING TIMESTAMP ?",
+			wantMarkers: []int{0, -1, 1},
+			wantMarker:  2,
+		},
+		{
+			query:       "UPDATE ks.sessions USING TTL :ttl SET data = :data WHERE id = :id",
+			wantQuery:   "UPDATE ks.sessions SET expires_at = :spanner_expiration, data = :data WHERE id = :id",
+			wantMarkers: []int{-1, 1, 2},
+			wantMarker:  0,
+		},
+		{
+			query:       "update sessions using timestamp 5 and ttl 0 set data = 'x' where id = 1",
+			wantQuery:   "update sessions using timestamp 5 set expires_at = ?, data = 'x' where id = 1",
+			wantMarkers: []int{-1},
+			wantMarker:  -1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			rw, err := rewriteTTL(tc.query, testTTLColumns)
+			require.NoError(t, err)
+			require.NotNil(t, rw)
+			assert.Equal(t, tc.wantQuery, rw.query)
+			assert.Equal(t, tc.wantMarkers, rw.markers)
+			assert.Equal(t, tc.wantTTL, rw.ttl)
+			assert.Equal(t, tc.wantMarker, rw.ttlMarker)
+		})
+	}
+}
+
+func TestRewriteTTLErrors(t *testing.T) {
+	for _, query := range []string{
+		"INSERT INTO ks.users (id) VALUES (1) USING TTL 60",
+		"UPDATE users USING TTL 60 SET a = 1 WHERE id = 1",
+		"INSERT INTO sessions (id) VALUES (1) USING TTL -1",
+		"INSERT INTO sessions (id) VALUES (1) USING TTL 999999999",
+		"INSERT INTO sessions JSON '{}' USING TTL 60",
+	} {
+		_, err := rewriteTTL(query, testTTLColumns)
+		assert.Error(t, err, query)
+	}
+
+	// Statements without TTL are left as is.
+	for _, query := range []string{
+		"INSERT INTO ks.users (id) VALUES (1) USING TIMESTAMP 5",
+		"SELECT TTL(data) FROM sessions",
+		"DELETE FROM sessions USING TIMESTAMP 5 WHERE id = 1",
+	} {
+		rw, err := rewriteTTL(query, testTTLColumns)
+		assert.NoError(t, err, query)
+		assert.Nil(t, rw, query)
+	}
+}
+
+func int32Value(v int32) *primitive.Value {
+	contents := make([]byte, 4)
+	binary.BigEndian.PutUint32(contents, uint32(v))
+	return primitive.NewValue(contents)
+}
+
+func TestTTLBind(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	rw, err := rewriteTTL(
+		"INSERT INTO sessions (id) VALUES (?) USING TTL ? AND TIMESTAMP ?",
+		testTTLColumns,
+	)
+	require.NoError(t, err)
+	id, ts := primitive.NewValue([]byte("id")), primitive.NewValue([]byte("ts"))
+
+	values, err := rw.bind([]*primitive.Value{id, int32Value(60), ts}, now)
+	require.NoError(t, err)
+	require.Len(t, values, 3)
+	assert.Equal(t, id, values[0])
+	assert.Equal(
+		t,
+		uint64(now.Add(time.Minute).UnixMilli()),
+		binary.BigEndian.Uint64(values[1].Contents),
+	)
+	assert.Equal(t, ts, values[2])
+
+	// A TTL of 0, or no TTL, does not expire the row.
+	values, err = rw.bind([]*primitive.Value{id, int32Value(0), ts}, now)
+	require.NoError(t, err)
+	assert.Equal(t, primitive.ValueTypeNull, values[1].Type)
+	values, err = rw.bind([]*primitive.Value{id, primitive.NewValue(nil), ts}, now)
+	require.NoError(t, err)
+	assert.Equal(t, primitive.ValueTypeNull, values[1].Type)
+
+	_, err = rw.bind([]*primitive.Value{id, int32Value(-5), ts}, now)
+	assert.Error(t, err)
+	_, err = rw.bind([]*primitive.Value{id}, now)
+	assert.Error(t, err)
+}
+
+func TestTTLVariables(t *testing.T) {
+	rw, err := rewriteTTL(
+		"UPDATE sessions USING TIMESTAMP ? AND TTL ? SET data = ? WHERE id = ?",
+		testTTLColumns,
+	)
+	require.NoError(t, err)
+	column := func(name string, dt datatype.DataType) *message.ColumnMetadata {
+		return &message.ColumnMetadata{Keyspace: "ks", Table: "sessions", Name: name, Type: dt}
+	}
+	// Variables of "UPDATE sessions USING TIMESTAMP ? SET expires_at = ?,
+	// data = ? WHERE id = ?".
+	got := rw.variables(&message.VariablesMetadata{
+		PkIndices: []uint16{3},
+		Columns: []*message.ColumnMetadata{
+			column("[timestamp]", datatype.Bigint),
+			column("expires_at", datatype.Timestamp),
+			column("data", datatype.Varchar),
+			column("id", datatype.Varchar),
+		},
+	})
+	assert.Equal(t, &message.VariablesMetadata{
+		PkIndices: []uint16{3},
+		Columns: []*message.ColumnMetadata{
+			column("[timestamp]", datatype.Bigint),
+			column("[ttl]", datatype.Int),
+			column("data", datatype.Varchar),
+			column("id", datatype.Varchar),
+		},
+	}, got)
+}
+
+func TestTryTranslateTTL(t *testing.T) {
+	statements, err := newTTLStatements(10)
+	require.NoError(t, err)
+	dc := &driverConnection{
+		executor: &requestExecutor{
+			opts:          &Options{TTLColumns: testTTLColumns},
+			ttlStatements: statements,
+		},
+		codec: codec,
+	}
+	newRequest := func(msg message.Message) *requestState {
+		frm := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(frm, buf))
+		return &requestState{
+			pb:    &adapterpb.AdaptMessageRequest{Payload: buf.Bytes()},
+			frame: *frm,
+		}
+	}
+	decoded := func(req *requestState) message.Message {
+		frm, err := codec.DecodeFrame(bytes.NewBuffer(req.pb.Payload))
+		require.NoError(t, err)
+		return frm.Body.Message
+	}
+
+	// Queries are rewritten, with the expiration bound to a new marker.
+	req := newRequest(&message.Query{
+		Query: "INSERT INTO sessions (id) VALUES ('a') USING TTL 60",
+	})
+	require.Nil(t, dc.tryTranslateTTL(req))
+	query := decoded(req).(*message.Query)
+	assert.Equal(t, "INSERT INTO sessions (id, expires_at) VALUES ('a', ?)", query.Query)
+	assert.Len(t, query.Options.PositionalValues, 1)
+
+	// Tables without expiration column are rejected.
+	req = newRequest(&message.Query{
+		Query: "INSERT INTO users (id) VALUES ('a') USING TTL 60",
+	})
+	assert.IsType(t, &message.Invalid{}, dc.tryTranslateTTL(req))
+
+	// Prepared statements are rewritten and their response translated.
+	req = newRequest(&message.Prepare{
+		Query: "INSERT INTO sessions (id) VALUES (?) USING TTL ?",
+	})
+	require.Nil(t, dc.tryTranslateTTL(req))
+	assert.Equal(
+		t,
+		"INSERT INTO sessions (id, expires_at) VALUES (?, ?)",
+		decoded(req).(*message.Prepare).Query,
+	)
+	response := frame.NewFrame(
+		primitive.ProtocolVersion4,
+		1,
+		&message.PreparedResult{
+			PreparedQueryId: []byte("id1"),
+			VariablesMetadata: &message.VariablesMetadata{
+				Columns: []*message.ColumnMetadata{
+					{Keyspace: "ks", Table: "sessions", Name: "id", Type: datatype.Varchar},
+					{Keyspace: "ks", Table: "sessions", Name: "expires_at", Type: datatype.Timestamp},
+				},
+			},
+			ResultMetadata: &message.RowsMetadata{},
+		},
+	)
+	response.Header.IsResponse = true
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(response, buf))
+	translated, err := codec.DecodeFrame(
+		bytes.NewBuffer(dc.translatePreparedTTL(req, buf.Bytes())),
+	)
+	require.NoError(t, err)
+	columns := translated.Body.Message.(*message.PreparedResult).VariablesMetadata.Columns
+	require.Len(t, columns, 2)
+	assert.Equal(t, "[ttl]", columns[1].Name)
+
+	// Executions of the prepared statement bind the expiration.
+	req = newRequest(&message.Execute{
+		QueryId: []byte("id1"),
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{
+			primitive.NewValue([]byte("a")),
+			int32Value(60),
+		}},
+	})
+	require.Nil(t, dc.tryTranslateTTL(req))
+	values := decoded(req).(*message.Execute).Options.PositionalValues
+	require.Len(t, values, 2)
+	assert.Len(t, values[1].Contents, 8)
+
+	// So do batches.
+	req = newRequest(&message.Batch{
+		Type: primitive.BatchTypeUnlogged,
+		Children: []*message.BatchChild{
+			{Id: []byte("id1"), Values: []*primitive.Value{
+				primitive.NewValue([]byte("a")),
+				int32Value(0),
+			}},
+			{Query: "INSERT INTO sessions (id) VALUES ('b') USING TTL 5"},
+		},
+	})
+	require.Nil(t, dc.tryTranslateTTL(req))
+	children := decoded(req).(*message.Batch).Children
+	assert.Equal(t, primitive.ValueTypeNull, children[0].Values[1].Type)
+	assert.Equal(
+		t,
+		"INSERT INTO sessions (id, expires_at) VALUES ('b', ?)",
+		children[1].Query,
+	)
+	assert.Len(t, children[1].Values, 1)
+}
@@ -0,0 +1,834 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+)
+
+// Spanner has no tuple or user-defined types. The columns of
+// Options.JSONColumns store them as JSON documents: tuples as arrays and UDTs
+// as objects keyed by field name. The proxy reports the CQL type of these
+// columns in the metadata of prepared statements and rows, encodes their JSON
+// values in the rows returned to the driver, and the values bound to them by
+// prepared statements as JSON.
+
+// jsonColumns holds the CQL types of the columns of Options.JSONColumns, and
+// remembers the prepared statements binding or returning them.
+type jsonColumns struct {
+	// CQL types by lower cased "keyspace.table.column" or "table.column".
+	types map[string]datatype.DataType
+	// Lower cased names of the tables of the columns.
+	tables     []string
+	statements *lru.Cache
+}
+
+// jsonStatement holds the CQL types of the variables and result columns of a
+// prepared statement, nil for the columns not stored as JSON.
+type jsonStatement struct {
+	variables []datatype.DataType
+	names     []string
+	results   []datatype.DataType
+}
+
+func newJSONColumns(
+	columns map[string]string,
+	userTypes map[string]string,
+	size int,
+) (*jsonColumns, error) {
+	jc := &jsonColumns{types: make(map[string]datatype.DataType, len(columns))}
+	for column, cqlType := range columns {
+		column = strings.ToLower(column)
+		parts := strings.Split(column, ".")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf(
+				"invalid JSON column %q, expected keyspace.table.column or table.column",
+				column,
+			)
+		}
+		keyspace := ""
+		if len(parts) == 3 {
+			keyspace = parts[0]
+		}
+		t, err := parseCQLType(cqlType, keyspace, userTypes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type %q of JSON column %s: %w", cqlType, column, err)
+		}
+		switch t.(type) {
+		case *datatype.Tuple, *datatype.UserDefined:
+		default:
+			return nil, fmt.Errorf(
+				"invalid type %q of JSON column %s: expected a tuple or a user-defined type",
+				cqlType,
+				column,
+			)
+		}
+		jc.types[column] = t
+		jc.tables = append(jc.tables, parts[len(parts)-2])
+	}
+	var err error
+	if jc.statements, err = lru.New(size); err != nil {
+		return nil, err
+	}
+	return jc, nil
+}
+
+// columnType returns the CQL type of column, or nil if it is not stored as
+// JSON.
+func (jc *jsonColumns) columnType(column *message.ColumnMetadata) datatype.DataType {
+	name := strings.ToLower(column.Table + "." + column.Name)
+	if t, ok := jc.types[strings.ToLower(column.Keyspace)+"."+name]; ok {
+		return t
+	}
+	return jc.types[name]
+}
+
+// translate replaces the type of the columns stored as JSON with their CQL
+// type. Returns the CQL types by column index, or nil if no column is stored
+// as JSON.
+func (jc *jsonColumns) translate(columns []*message.ColumnMetadata) []datatype.DataType {
+	var types []datatype.DataType
+	for i, column := range columns {
+		t := jc.columnType(column)
+		if t == nil {
+			continue
+		}
+		if types == nil {
+			types = make([]datatype.DataType, len(columns))
+		}
+		types[i] = t
+		column.Type = t
+	}
+	return types
+}
+
+// mayRead reports whether a query may read a column stored as JSON, to skip
+// decoding the responses of the others.
+func (jc *jsonColumns) mayRead(query string) bool {
+	if !isCQLRead(query) {
+		return false
+	}
+	query = strings.ToLower(query)
+	for _, table := range jc.tables {
+		if strings.Contains(query, table) {
+			return true
+		}
+	}
+	return false
+}
+
+func (jc *jsonColumns) remember(id []byte, stmt *jsonStatement) {
+	jc.statements.Add(string(id), stmt)
+}
+
+func (jc *jsonColumns) lookup(id []byte) *jsonStatement {
+	if stmt, ok := jc.statements.Get(string(id)); ok {
+		return stmt.(*jsonStatement)
+	}
+	return nil
+}
+
+// translateJSONColumns reports the CQL type of the columns stored as JSON in
+// the encoded response to req, and encodes their values as values of that
+// type. Values that are not valid JSON of their type are returned unchanged.
+func (dc *driverConnection) translateJSONColumns(
+	req *requestState,
+	encoded []byte,
+) []byte {
+	jc := dc.executor.jsonColumns
+	if jc == nil || req.frame.Header.Version < primitive.ProtocolVersion3 {
+		return encoded
+	}
+	var results []datatype.DataType
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Prepare:
+		return dc.amendResponse(encoded, func(frm *frame.Frame) {
+			prepared, ok := frm.Body.Message.(*message.PreparedResult)
+			if !ok {
+				return
+			}
+			stmt := &jsonStatement{}
+			if prepared.VariablesMetadata != nil {
+				columns := prepared.VariablesMetadata.Columns
+				if stmt.variables = jc.translate(columns); stmt.variables != nil {
+					stmt.names = make([]string, len(columns))
+					for i, column := range columns {
+						stmt.names[i] = column.Name
+					}
+				}
+			}
+			if prepared.ResultMetadata != nil {
+				stmt.results = jc.translate(prepared.ResultMetadata.Columns)
+			}
+			if stmt.variables != nil || stmt.results != nil {
+				jc.remember(prepared.PreparedQueryId, stmt)
+			}
+		})
+	case *message.Execute:
+		stmt := jc.lookup(msg.QueryId)
+		if stmt == nil || stmt.results == nil {
+			return encoded
+		}
+		results = stmt.results
+	case *message.Query:
+		if !jc.mayRead(msg.Query) {
+			return encoded
+		}
+	default:
+		return encoded
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		rows, ok := frm.Body.Message.(*message.RowsResult)
+		if !ok {
+			return
+		}
+		if rows.Metadata != nil && len(rows.Metadata.Columns) > 0 {
+			results = jc.translate(rows.Metadata.Columns)
+		}
+		for _, row := range rows.Data {
+			for i, t := range results {
+				if t == nil || i >= len(row) || row[i] == nil {
+					continue
+				}
+				value, err := jsonToCQL(t, row[i])
+				if err != nil {
+					logger.Debug("Error encoding JSON column",
+						zap.Int("connectionID", dc.connectionID),
+						zap.Error(err),
+					)
+					continue
+				}
+				row[i] = value
+			}
+		}
+	})
+}
+
+// tryBindJSONColumns encodes as JSON the values bound by req to the columns
+// stored as JSON. Returns an Invalid error message if a value can not be
+// decoded.
+func (dc *driverConnection) tryBindJSONColumns(req *requestState) message.Message {
+	jc := dc.executor.jsonColumns
+	if jc == nil {
+		return nil
+	}
+	var translated message.Message
+	var err error
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Execute:
+		stmt := jc.lookup(msg.QueryId)
+		if stmt == nil || stmt.variables == nil || msg.Options == nil {
+			return nil
+		}
+		var options *message.QueryOptions
+		if options, err = stmt.bind(msg.Options); err == nil {
+			execute := *msg
+			execute.Options = options
+			translated = &execute
+		}
+	case *message.Batch:
+		var children []*message.BatchChild
+		for i, child := range msg.Children {
+			if child.Query != "" {
+				continue
+			}
+			stmt := jc.lookup(child.Id)
+			if stmt == nil || stmt.variables == nil {
+				continue
+			}
+			var values []*primitive.Value
+			if values, err = stmt.bindValues(child.Values); err != nil {
+				break
+			}
+			if children == nil {
+				children = append([]*message.BatchChild(nil), msg.Children...)
+			}
+			children[i] = &message.BatchChild{Id: child.Id, Values: values}
+		}
+		if err == nil && children != nil {
+			batch := *msg
+			batch.Children = children
+			translated = &batch
+		}
+	}
+	if err != nil {
+		return &message.Invalid{ErrorMessage: err.Error()}
+	}
+	if translated == nil {
+		return nil
+	}
+	return dc.replaceMessage(req, translated)
+}
+
+// bind returns a copy of options with the values bound to JSON columns
+// encoded as JSON.
+func (stmt *jsonStatement) bind(
+	options *message.QueryOptions,
+) (*message.QueryOptions, error) {
+	bound := *options
+	if len(options.NamedValues) > 0 {
+		bound.NamedValues = make(map[string]*primitive.Value, len(options.NamedValues))
+		for name, value := range options.NamedValues {
+			bound.NamedValues[name] = value
+			for i, variable := range stmt.names {
+				if stmt.variables[i] == nil || !strings.EqualFold(variable, name) {
+					continue
+				}
+				encoded, err := bindJSONValue(stmt.variables[i], variable, value)
+				if err != nil {
+					return nil, err
+				}
+				bound.NamedValues[name] = encoded
+				break
+			}
+		}
+		return &bound, nil
+	}
+	values, err := stmt.bindValues(options.PositionalValues)
+	if err != nil {
+		return nil, err
+	}
+	bound.PositionalValues = values
+	return &bound, nil
+}
+
+// bindValues returns a copy of the positional values with the values bound to
+// JSON columns encoded as JSON.
+func (stmt *jsonStatement) bindValues(
+	values []*primitive.Value,
+) ([]*primitive.Value, error) {
+	bound := append([]*primitive.Value(nil), values...)
+	for i, value := range values {
+		if i >= len(stmt.variables) || stmt.variables[i] == nil {
+			continue
+		}
+		encoded, err := bindJSONValue(stmt.variables[i], stmt.names[i], value)
+		if err != nil {
+			return nil, err
+		}
+		bound[i] = encoded
+	}
+	return bound, nil
+}
+
+func bindJSONValue(
+	t datatype.DataType,
+	name string,
+	value *primitive.Value,
+) (*primitive.Value, error) {
+	if value == nil || value.Type != primitive.ValueTypeRegular {
+		return value, nil
+	}
+	encoded, err := cqlToJSON(t, value.Contents)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value bound to %s: %w", name, err)
+	}
+	return primitive.NewValue(encoded), nil
+}
+
+// jsonToCQL encodes a JSON document as a value of type t.
+func jsonToCQL(t datatype.DataType, document []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return encodeJSONValue(t, v)
+}
+
+// cqlToJSON encodes a value of type t as a JSON document.
+func cqlToJSON(t datatype.DataType, value []byte) ([]byte, error) {
+	v, err := decodeJSONValue(t, value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// encodeFields encodes the fields of a tuple or a UDT value.
+func encodeFields(fields [][]byte) []byte {
+	var buf []byte
+	for _, field := range fields {
+		if field == nil {
+			buf = binary.BigEndian.AppendUint32(buf, math.MaxUint32)
+			continue
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+		buf = append(buf, field...)
+	}
+	return buf
+}
+
+// decodeFields decodes the fields of a tuple or a UDT value, which may omit
+// its last fields.
+func decodeFields(value []byte) ([][]byte, error) {
+	var fields [][]byte
+	for len(value) > 0 {
+		if len(value) < 4 {
+			return nil, fmt.Errorf("truncated field")
+		}
+		n := int32(binary.BigEndian.Uint32(value))
+		value = value[4:]
+		if n < 0 {
+			fields = append(fields, nil)
+			continue
+		}
+		if int(n) > len(value) {
+			return nil, fmt.Errorf("truncated field")
+		}
+		fields = append(fields, value[:n])
+		value = value[n:]
+	}
+	return fields, nil
+}
+
+// encodeJSONValue encodes a value decoded from JSON, with numbers decoded as
+// json.Number, as a value of type t.
+func encodeJSONValue(t datatype.DataType, v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *datatype.Tuple:
+		values, ok := v.([]interface{})
+		if !ok || len(values) > len(t.FieldTypes) {
+			return nil, fmt.Errorf("expected an array of %d values, got %v", len(t.FieldTypes), v)
+		}
+		fields := make([][]byte, len(t.FieldTypes))
+		for i, value := range values {
+			field, err := encodeJSONValue(t.FieldTypes[i], value)
+			if err != nil {
+				return nil, err
+			}
+			fields[i] = field
+		}
+		return encodeFields(fields), nil
+	case *datatype.UserDefined:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object of type %s, got %v", t.Name, v)
+		}
+		fields := make([][]byte, len(t.FieldNames))
+		for i, name := range t.FieldNames {
+			field, err := encodeJSONValue(t.FieldTypes[i], object[name])
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			fields[i] = field
+		}
+		return encodeFields(fields), nil
+	case *datatype.List:
+		return encodeJSONElements(t.ElementType, v)
+	case *datatype.Set:
+		return encodeJSONElements(t.ElementType, v)
+	}
+	switch t.Code() {
+	case primitive.DataTypeCodeAscii, primitive.DataTypeCodeVarchar:
+		if s, ok := v.(string); ok {
+			return []byte(s), nil
+		}
+	case primitive.DataTypeCodeBoolean:
+		if b, ok := v.(bool); ok {
+			if b {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		}
+	case primitive.DataTypeCodeInt:
+		n, err := jsonInteger(v, 32)
+		return binary.BigEndian.AppendUint32(nil, uint32(n)), err
+	case primitive.DataTypeCodeBigint, primitive.DataTypeCodeCounter:
+		n, err := jsonInteger(v, 64)
+		return encodeBigint(n), err
+	case primitive.DataTypeCodeSmallint:
+		n, err := jsonInteger(v, 16)
+		return binary.BigEndian.AppendUint16(nil, uint16(n)), err
+	case primitive.DataTypeCodeTinyint:
+		n, err := jsonInteger(v, 8)
+		return []byte{byte(n)}, err
+	case primitive.DataTypeCodeDouble, primitive.DataTypeCodeFloat:
+		n, ok := v.(json.Number)
+		if !ok {
+			break
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return nil, err
+		}
+		if t.Code() == primitive.DataTypeCodeFloat {
+			return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))), nil
+		}
+		return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)), nil
+	case primitive.DataTypeCodeTimestamp:
+		if s, ok := v.(string); ok {
+			ts, err := time.Parse(time.RFC3339Nano, s)
+			if err != nil {
+				return nil, err
+			}
+			return encodeBigint(ts.UnixMilli()), nil
+		}
+		n, err := jsonInteger(v, 64)
+		return encodeBigint(n), err
+	case primitive.DataTypeCodeUuid, primitive.DataTypeCodeTimeuuid:
+		if s, ok := v.(string); ok {
+			uuid, err := hex.DecodeString(strings.ReplaceAll(s, "-", ""))
+			if err != nil || len(uuid) != 16 {
+				return nil, fmt.Errorf("invalid uuid %q", s)
+			}
+			return uuid, nil
+		}
+	case primitive.DataTypeCodeBlob:
+		if s, ok := v.(string); ok {
+			return base64.StdEncoding.DecodeString(s)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %v", t.Code())
+	}
+	return nil, fmt.Errorf("invalid %v value %v", t.Code(), v)
+}
+
+func encodeJSONElements(t datatype.DataType, v interface{}) ([]byte, error) {
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %v", v)
+	}
+	elements := make([][]byte, len(values))
+	for i, value := range values {
+		element, err := encodeJSONValue(t, value)
+		if err != nil {
+			return nil, err
+		}
+		elements[i] = element
+	}
+	return encodeCollection(elements, 1), nil
+}
+
+// jsonInteger returns a JSON number as an integer of bits bits.
+func jsonInteger(v interface{}, bits int) (int64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("expected a number, got %v", v)
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return 0, err
+	}
+	if bits < 64 && (i < -1<<(bits-1) || i >= 1<<(bits-1)) {
+		return 0, fmt.Errorf("%d overflows a %d bits integer", i, bits)
+	}
+	return i, nil
+}
+
+// decodeJSONValue decodes a value of type t as a value encoded to JSON as
+// encodeJSONValue decodes it.
+func decodeJSONValue(t datatype.DataType, value []byte) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *datatype.Tuple:
+		fields, err := decodeFields(value)
+		if err != nil || len(fields) > len(t.FieldTypes) {
+			return nil, fmt.Errorf("invalid tuple of %d fields", len(t.FieldTypes))
+		}
+		values := make([]interface{}, len(t.FieldTypes))
+		for i, field := range fields {
+			if values[i], err = decodeJSONValue(t.FieldTypes[i], field); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	case *datatype.UserDefined:
+		fields, err := decodeFields(value)
+		if err != nil || len(fields) > len(t.FieldTypes) {
+			return nil, fmt.Errorf("invalid value of type %s", t.Name)
+		}
+		object := make(map[string]interface{}, len(t.FieldNames))
+		for i, name := range t.FieldNames {
+			var field []byte
+			if i < len(fields) {
+				field = fields[i]
+			}
+			if object[name], err = decodeJSONValue(t.FieldTypes[i], field); err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+		}
+		return object, nil
+	case *datatype.List:
+		return decodeJSONElements(t.ElementType, value)
+	case *datatype.Set:
+		return decodeJSONElements(t.ElementType, value)
+	}
+	size := fixedTypeSizes[t.Code()]
+	if size > 0 && len(value) != size {
+		return nil, fmt.Errorf("invalid %v value of %d bytes", t.Code(), len(value))
+	}
+	switch t.Code() {
+	case primitive.DataTypeCodeAscii, primitive.DataTypeCodeVarchar:
+		return string(value), nil
+	case primitive.DataTypeCodeBoolean:
+		return value[0] != 0, nil
+	case primitive.DataTypeCodeInt:
+		return int32(binary.BigEndian.Uint32(value)), nil
+	case primitive.DataTypeCodeBigint, primitive.DataTypeCodeCounter:
+		return int64(binary.BigEndian.Uint64(value)), nil
+	case primitive.DataTypeCodeSmallint:
+		return int16(binary.BigEndian.Uint16(value)), nil
+	case primitive.DataTypeCodeTinyint:
+		return int8(value[0]), nil
+	case primitive.DataTypeCodeDouble:
+		return math.Float64frombits(binary.BigEndian.Uint64(value)), nil
+	case primitive.DataTypeCodeFloat:
+		return math.Float32frombits(binary.BigEndian.Uint32(value)), nil
+	case primitive.DataTypeCodeTimestamp:
+		ms := int64(binary.BigEndian.Uint64(value))
+		return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano), nil
+	case primitive.DataTypeCodeUuid, primitive.DataTypeCodeTimeuuid:
+		h := hex.EncodeToString(value)
+		return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+	case primitive.DataTypeCodeBlob:
+		return base64.StdEncoding.EncodeToString(value), nil
+	}
+	return nil, fmt.Errorf("unsupported type %v", t.Code())
+}
+
+func decodeJSONElements(t datatype.DataType, value []byte) (interface{}, error) {
+	elements, ok := decodeCollection(value, 1)
+	if !ok {
+		return nil, fmt.Errorf("invalid collection")
+	}
+	values := make([]interface{}, len(elements))
+	for i, element := range elements {
+		var err error
+		if values[i], err = decodeJSONValue(t, element); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// jsonScalarTypes are the CQL types of the fields of the tuples and UDTs
+// stored as JSON, besides tuples, UDTs, lists and sets.
+var jsonScalarTypes = map[string]datatype.DataType{
+	"ascii":     datatype.Ascii,
+	"text":      datatype.Varchar,
+	"varchar":   datatype.Varchar,
+	"boolean":   datatype.Boolean,
+	"int":       datatype.Int,
+	"bigint":    datatype.Bigint,
+	"counter":   datatype.Counter,
+	"smallint":  datatype.Smallint,
+	"tinyint":   datatype.Tinyint,
+	"double":    datatype.Double,
+	"float":     datatype.Float,
+	"timestamp": datatype.Timestamp,
+	"uuid":      datatype.Uuid,
+	"timeuuid":  datatype.Timeuuid,
+	"blob":      datatype.Blob,
+}
+
+// cqlTypeParser parses CQL types, ie: frozen<tuple<int, frozen<address>>>,
+// whose user-defined types are defined by Options.UserTypes.
+type cqlTypeParser struct {
+	tokens    []string
+	keyspace  string
+	userTypes map[string]string
+	// Names of the user-defined types being parsed, to reject recursive
+	// definitions.
+	parsing map[string]bool
+}
+
+// parseCQLType parses a CQL type. Unqualified user-defined type names are
+// looked up in keyspace first.
+func parseCQLType(
+	s string,
+	keyspace string,
+	userTypes map[string]string,
+) (datatype.DataType, error) {
+	p := &cqlTypeParser{
+		tokens:    tokenizeCQLType(s),
+		keyspace:  keyspace,
+		userTypes: userTypes,
+		parsing:   make(map[string]bool),
+	}
+	t, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.tokens) > 0 {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[0])
+	}
+	return t, nil
+}
+
+func tokenizeCQLType(s string) []string {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n':
+			i++
+		case '<', '>', ',':
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n<>,", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens
+}
+
+func (p *cqlTypeParser) next() string {
+	if len(p.tokens) == 0 {
+		return ""
+	}
+	token := p.tokens[0]
+	p.tokens = p.tokens[1:]
+	return token
+}
+
+func (p *cqlTypeParser) expect(token string) error {
+	if next := p.next(); next != token {
+		return fmt.Errorf("expected %q, got %q", token, next)
+	}
+	return nil
+}
+
+func (p *cqlTypeParser) parse() (datatype.DataType, error) {
+	name := strings.ToLower(p.next())
+	switch name {
+	case "", "<", ">", ",":
+		return nil, fmt.Errorf("expected a type, got %q", name)
+	case "frozen", "list", "set":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		t, err := p.parse()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+		switch name {
+		case "list":
+			return &datatype.List{ElementType: t}, nil
+		case "set":
+			return &datatype.Set{ElementType: t}, nil
+		}
+		return t, nil
+	case "tuple":
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		tuple := &datatype.Tuple{}
+		for {
+			t, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			tuple.FieldTypes = append(tuple.FieldTypes, t)
+			switch sep := p.next(); sep {
+			case ",":
+			case ">":
+				return tuple, nil
+			default:
+				return nil, fmt.Errorf("expected \",\" or \">\", got %q", sep)
+			}
+		}
+	}
+	if t, ok := jsonScalarTypes[name]; ok {
+		return t, nil
+	}
+	return p.parseUserType(name)
+}
+
+// parseUserType parses the definition of a user-defined type, a comma
+// separated list of field names and types.
+func (p *cqlTypeParser) parseUserType(name string) (datatype.DataType, error) {
+	keyspace, typeName := p.keyspace, name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		keyspace, typeName = name[:i], name[i+1:]
+	}
+	definition, ok := "", false
+	for key, fields := range p.userTypes {
+		key = strings.ToLower(key)
+		if key == keyspace+"."+typeName || key == typeName {
+			definition, ok = fields, true
+			if strings.Contains(key, ".") {
+				break
+			}
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+	key := keyspace + "." + typeName
+	if p.parsing[key] {
+		return nil, fmt.Errorf("recursive type %s", name)
+	}
+	p.parsing[key] = true
+	defer delete(p.parsing, key)
+
+	fields := &cqlTypeParser{
+		tokens:    tokenizeCQLType(definition),
+		keyspace:  keyspace,
+		userTypes: p.userTypes,
+		parsing:   p.parsing,
+	}
+	udt := &datatype.UserDefined{Keyspace: keyspace, Name: typeName}
+	for {
+		field := strings.ToLower(fields.next())
+		if field == "" || strings.ContainsAny(field, "<>,") {
+			return nil, fmt.Errorf("invalid fields %q of type %s", definition, name)
+		}
+		t, err := fields.parse()
+		if err != nil {
+			return nil, fmt.Errorf("field %s of type %s: %w", field, name, err)
+		}
+		udt.FieldNames = append(udt.FieldNames, field)
+		udt.FieldTypes = append(udt.FieldTypes, t)
+		switch sep := fields.next(); sep {
+		case "":
+			return udt, nil
+		case ",":
+		default:
+			return nil, fmt.Errorf("invalid fields %q of type %s", definition, name)
+		}
+	}
+}
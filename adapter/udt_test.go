@@ -0,0 +1,293 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"cloud.google.com/go/spanner/adapter/apiv1/adapterpb"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testUserTypes = map[string]string{
+	"ks.address": "street text, zip int, tags set<text>",
+	"point":      "x double, y double",
+	"loop":       "next frozen<loop>",
+}
+
+var testAddress = &datatype.UserDefined{
+	Keyspace:   "ks",
+	Name:       "address",
+	FieldNames: []string{"street", "zip", "tags"},
+	FieldTypes: []datatype.DataType{
+		datatype.Varchar,
+		datatype.Int,
+		&datatype.Set{ElementType: datatype.Varchar},
+	},
+}
+
+func TestParseCQLType(t *testing.T) {
+	tests := []struct {
+		cqlType  string
+		keyspace string
+		want     datatype.DataType
+	}{
+		{
+			cqlType: "frozen<tuple<int, text>>",
+			want:    &datatype.Tuple{FieldTypes: []datatype.DataType{datatype.Int, datatype.Varchar}},
+		},
+		{cqlType: "frozen<address>", keyspace: "ks", want: testAddress},
+		{cqlType: "ks.address", want: testAddress},
+		{
+			cqlType:  "tuple<list<frozen<point>>, timestamp>",
+			keyspace: "ks",
+			want: &datatype.Tuple{FieldTypes: []datatype.DataType{
+				&datatype.List{ElementType: &datatype.UserDefined{
+					Keyspace:   "ks",
+					Name:       "point",
+					FieldNames: []string{"x", "y"},
+					FieldTypes: []datatype.DataType{datatype.Double, datatype.Double},
+				}},
+				datatype.Timestamp,
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cqlType, func(t *testing.T) {
+			got, err := parseCQLType(tt.cqlType, tt.keyspace, testUserTypes)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, cqlType := range []string{
+		"",
+		"tuple<int",
+		"tuple<int,>",
+		"frozen<int> text",
+		"map<int, int>",
+		"unknown",
+		"loop",
+	} {
+		_, err := parseCQLType(cqlType, "ks", testUserTypes)
+		assert.Error(t, err, cqlType)
+	}
+}
+
+func TestJSONValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      datatype.DataType
+		document string
+		value    []byte
+	}{
+		{
+			name:     "tuple",
+			typ:      &datatype.Tuple{FieldTypes: []datatype.DataType{datatype.Int, datatype.Varchar, datatype.Boolean}},
+			document: `[1,"a",null]`,
+			value:    encodeFields([][]byte{encodeInt(1), []byte("a"), nil}),
+		},
+		{
+			name:     "udt",
+			typ:      testAddress,
+			document: `{"street":"1 Main St","tags":["home"],"zip":12345}`,
+			value: encodeFields([][]byte{
+				[]byte("1 Main St"),
+				encodeInt(12345),
+				encodeCollection([][]byte{[]byte("home")}, 1),
+			}),
+		},
+		{
+			name: "scalars",
+			typ: &datatype.Tuple{FieldTypes: []datatype.DataType{
+				datatype.Bigint,
+				datatype.Timestamp,
+				datatype.Uuid,
+				datatype.Blob,
+			}},
+			document: `[-2,"1970-01-01T00:00:01Z","00000000-0000-0000-0000-000000000001","AQI="]`,
+			value: encodeFields([][]byte{
+				encodeBigint(-2),
+				encodeBigint(1000),
+				{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+				{1, 2},
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := jsonToCQL(tt.typ, []byte(tt.document))
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, value)
+
+			document, err := cqlToJSON(tt.typ, tt.value)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.document, string(document))
+		})
+	}
+
+	// Missing fields of UDTs are null.
+	value, err := jsonToCQL(testAddress, []byte(`{"zip":1}`))
+	require.NoError(t, err)
+	assert.Equal(t, encodeFields([][]byte{nil, encodeInt(1), nil}), value)
+
+	// Invalid values are rejected.
+	tuple := &datatype.Tuple{FieldTypes: []datatype.DataType{datatype.Tinyint}}
+	for _, document := range []string{`[300]`, `["a"]`, `[1, 2]`, `{}`, `[`} {
+		_, err := jsonToCQL(tuple, []byte(document))
+		assert.Error(t, err, document)
+	}
+	_, err = cqlToJSON(tuple, encodeFields([][]byte{encodeInt(1)}))
+	assert.Error(t, err)
+}
+
+func TestTranslateJSONColumns(t *testing.T) {
+	jc, err := newJSONColumns(
+		map[string]string{"ks.users.home": "frozen<address>"},
+		testUserTypes,
+		10,
+	)
+	require.NoError(t, err)
+	dc := &driverConnection{
+		executor: &requestExecutor{jsonColumns: jc},
+		codec:    codec,
+	}
+	columns := func() []*message.ColumnMetadata {
+		return []*message.ColumnMetadata{
+			{Keyspace: "ks", Table: "users", Name: "id", Type: datatype.Int},
+			{Keyspace: "ks", Table: "users", Name: "home", Type: datatype.Varchar},
+		}
+	}
+	encode := func(msg message.Message) []byte {
+		response := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+		response.Header.IsResponse = true
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(response, buf))
+		return buf.Bytes()
+	}
+	decode := func(encoded []byte) message.Message {
+		frm, err := codec.DecodeFrame(bytes.NewBuffer(encoded))
+		require.NoError(t, err)
+		return frm.Body.Message
+	}
+	newRequest := func(msg message.Message) *requestState {
+		frm := frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(t, codec.EncodeFrame(frm, buf))
+		return &requestState{
+			pb:    &adapterpb.AdaptMessageRequest{Payload: buf.Bytes()},
+			frame: *frm,
+		}
+	}
+	document := []byte(`{"street":"a","zip":1}`)
+	address := encodeFields([][]byte{[]byte("a"), encodeInt(1), nil})
+
+	// Prepared statements report the CQL type of their variables and results.
+	req := newRequest(&message.Prepare{Query: "SELECT * FROM users WHERE home = ?"})
+	prepared := decode(dc.translateJSONColumns(req, encode(&message.PreparedResult{
+		PreparedQueryId:   []byte("id"),
+		VariablesMetadata: &message.VariablesMetadata{Columns: columns()[1:]},
+		ResultMetadata:    &message.RowsMetadata{ColumnCount: 2, Columns: columns()},
+	}))).(*message.PreparedResult)
+	assert.Equal(t, testAddress, prepared.VariablesMetadata.Columns[0].Type)
+	assert.Equal(t, testAddress, prepared.ResultMetadata.Columns[1].Type)
+
+	// Their bound values are encoded as JSON.
+	req = newRequest(&message.Execute{
+		QueryId: []byte("id"),
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{primitive.NewValue(address)}},
+	})
+	require.Nil(t, dc.tryBindJSONColumns(req))
+	execute := decode(req.pb.Payload).(*message.Execute)
+	assert.JSONEq(t,
+		`{"street":"a","zip":1,"tags":null}`,
+		string(execute.Options.PositionalValues[0].Contents),
+	)
+
+	// Including the values bound by name and in batches.
+	req = newRequest(&message.Execute{
+		QueryId: []byte("id"),
+		Options: &message.QueryOptions{NamedValues: map[string]*primitive.Value{"home": primitive.NewValue(address)}},
+	})
+	require.Nil(t, dc.tryBindJSONColumns(req))
+	execute = decode(req.pb.Payload).(*message.Execute)
+	assert.JSONEq(t,
+		`{"street":"a","zip":1,"tags":null}`,
+		string(execute.Options.NamedValues["home"].Contents),
+	)
+	req = newRequest(&message.Batch{Children: []*message.BatchChild{
+		{Id: []byte("id"), Values: []*primitive.Value{primitive.NewValue(address)}},
+		{Query: "INSERT INTO t (id) VALUES (1)"},
+	}})
+	require.Nil(t, dc.tryBindJSONColumns(req))
+	batch := decode(req.pb.Payload).(*message.Batch)
+	assert.JSONEq(t, `{"street":"a","zip":1,"tags":null}`, string(batch.Children[0].Values[0].Contents))
+
+	// Invalid values are rejected.
+	req = newRequest(&message.Execute{
+		QueryId: []byte("id"),
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{primitive.NewValue([]byte{0, 0})}},
+	})
+	assert.IsType(t, &message.Invalid{}, dc.tryBindJSONColumns(req))
+
+	// Rows of executions without metadata are encoded from the remembered
+	// result types.
+	req = newRequest(&message.Execute{QueryId: []byte("id"), Options: &message.QueryOptions{}})
+	rows := decode(dc.translateJSONColumns(req, encode(&message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 2},
+		Data:     message.RowSet{{encodeInt(1), document}},
+	}))).(*message.RowsResult)
+	assert.Equal(t, address, rows.Data[0][1])
+
+	// Rows of queries are encoded from their metadata.
+	req = newRequest(&message.Query{Query: "SELECT * FROM ks.users"})
+	rows = decode(dc.translateJSONColumns(req, encode(&message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 2, Columns: columns()},
+		Data:     message.RowSet{{encodeInt(1), document}, {encodeInt(2), nil}},
+	}))).(*message.RowsResult)
+	assert.Equal(t, testAddress, rows.Metadata.Columns[1].Type)
+	assert.Equal(t, address, rows.Data[0][1])
+	assert.Nil(t, rows.Data[1][1])
+
+	// Queries on other tables are returned unchanged.
+	req = newRequest(&message.Query{Query: "SELECT * FROM ks.orders"})
+	other := encode(&message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 2, Columns: columns()},
+		Data:     message.RowSet{{encodeInt(1), document}},
+	})
+	assert.Equal(t, other, dc.translateJSONColumns(req, other))
+}
+
+func TestNewJSONColumnsErrors(t *testing.T) {
+	for _, columns := range []map[string]string{
+		{"home": "frozen<address>"},
+		{"ks.users.home": "int"},
+		{"ks.users.home": "frozen<unknown>"},
+	} {
+		_, err := newJSONColumns(columns, testUserTypes, 10)
+		assert.Error(t, err, columns)
+	}
+}
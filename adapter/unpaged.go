@@ -0,0 +1,152 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// tryPageUnpagedRead sends the unpaged reads of req (page size 0) with a page
+// size of Options.UnpagedReadPageSize, the proxy reading the next pages itself
+// in readRemainingPages.
+func (dc *driverConnection) tryPageUnpagedRead(req *requestState) message.Message {
+	pageSize := dc.executor.opts.UnpagedReadPageSize
+	if pageSize <= 0 || req.pageSize > 0 || req.commit || dc.isDML(req) {
+		return nil
+	}
+	options := pagingOptions(&req.frame)
+	if options == nil || options.PageSize > 0 {
+		return nil
+	}
+	req.readPageSize = int32(pageSize)
+	return dc.replaceMessage(req, withPaging(req.frame.Body.Message, req.readPageSize, nil))
+}
+
+// withPaging returns a copy of the QUERY or EXECUTE message msg reading the
+// page of pageSize rows of pagingState.
+func withPaging(
+	msg message.Message,
+	pageSize int32,
+	pagingState []byte,
+) message.Message {
+	page := func(options *message.QueryOptions) *message.QueryOptions {
+		paged := &message.QueryOptions{}
+		if options != nil {
+			*paged = *options
+		}
+		paged.PageSize = pageSize
+		paged.PagingState = pagingState
+		return paged
+	}
+	switch msg := msg.(type) {
+	case *message.Query:
+		query := *msg
+		query.Options = page(msg.Options)
+		return &query
+	case *message.Execute:
+		execute := *msg
+		execute.Options = page(msg.Options)
+		return &execute
+	}
+	return msg
+}
+
+// readRemainingPages reads the pages following the encoded first page of the
+// result of a request sent by tryPageUnpagedRead, and returns the encoded
+// result holding the rows of all pages, as expected by the driver. Errors
+// reading a page are returned, and error responses are returned in place of
+// the result.
+//
+// The rows are held until the last page is read: the frame header carries the
+// length of the result and its metadata the number of rows, so the result can
+// not be written before all of its rows are known. Each page is only held
+// until its rows are merged, so that the proxy holds the rows once rather
+// than every response chunk of a single large response.
+func (dc *driverConnection) readRemainingPages(
+	ctx context.Context,
+	req *requestState,
+	encoded []byte,
+) ([]byte, error) {
+	if req.readPageSize <= 0 {
+		return encoded, nil
+	}
+	first, ok := dc.decodeRowsPage(encoded)
+	if !ok {
+		return encoded, nil
+	}
+	result := first.Body.Message.(*message.RowsResult)
+	pagingState := result.Metadata.PagingState
+	if len(pagingState) == 0 {
+		// The result fits in a single page.
+		return encoded, nil
+	}
+	size := len(encoded)
+	for len(pagingState) > 0 {
+		msg := withPaging(req.frame.Body.Message, req.readPageSize, pagingState)
+		payload, err := dc.sendDerivedRequest(ctx, req, msg, dc.routeToLeader(req))
+		if err != nil {
+			return nil, err
+		}
+		if opCode, ok := responseOpCode(payload); !ok || opCode == primitive.OpCodeError {
+			return payload, nil
+		}
+		size += len(payload)
+		if err := dc.checkResponseSize(size); err != nil {
+			return nil, err
+		}
+		page, ok := dc.decodeRowsPage(payload)
+		if !ok {
+			return nil, fmt.Errorf("cannot decode a page of unpaged read after %d rows", len(result.Data))
+		}
+		rows := page.Body.Message.(*message.RowsResult)
+		result.Data = append(result.Data, rows.Data...)
+		pagingState = rows.Metadata.PagingState
+	}
+	metadata := *result.Metadata
+	metadata.PagingState = nil
+	result.Metadata = &metadata
+	buf := bytes.NewBuffer(nil)
+	if err := dc.codec.EncodeFrame(first, buf); err != nil {
+		logger.Error("Error encoding the merged pages of an unpaged read",
+			append(dc.requestLogFields(req), zap.Error(err))...,
+		)
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeRowsPage decodes the encoded response of a page, and returns false
+// unless it is a ROWS result with metadata, ie: compressed responses.
+func (dc *driverConnection) decodeRowsPage(encoded []byte) (*frame.Frame, bool) {
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return nil, false
+	}
+	rows, ok := frm.Body.Message.(*message.RowsResult)
+	if !ok || rows.Metadata == nil {
+		return nil, false
+	}
+	return frm, true
+}
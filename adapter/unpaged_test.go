@@ -0,0 +1,135 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedRows answers reads with the page of its rows they request, and records
+// the page sizes requested.
+type pagedRows struct {
+	rows message.RowSet
+
+	mu        sync.Mutex
+	pageSizes []int32
+}
+
+func (p *pagedRows) requestedPageSizes() []int32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sizes := p.pageSizes
+	p.pageSizes = nil
+	return sizes
+}
+
+func (p *pagedRows) respond(payload []byte) ([]byte, error) {
+	req, err := codec.DecodeFrame(bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	options := req.Body.Message.(*message.Query).Options
+	p.mu.Lock()
+	p.pageSizes = append(p.pageSizes, options.PageSize)
+	p.mu.Unlock()
+
+	start := 0
+	if len(options.PagingState) > 0 {
+		start, _ = strconv.Atoi(string(options.PagingState))
+	}
+	end := len(p.rows)
+	if options.PageSize > 0 {
+		end = min(start+int(options.PageSize), len(p.rows))
+	}
+	metadata := &message.RowsMetadata{
+		ColumnCount: 1,
+		Columns: []*message.ColumnMetadata{
+			{Keyspace: "ks", Table: "t", Name: "id", Type: datatype.Int},
+		},
+	}
+	if end < len(p.rows) {
+		metadata.PagingState = []byte(strconv.Itoa(end))
+	}
+	response := frame.NewFrame(req.Header.Version, req.Header.StreamId, &message.RowsResult{
+		Metadata: metadata,
+		Data:     p.rows[start:end],
+	})
+	response.Header.IsResponse = true
+	buf := bytes.NewBuffer(nil)
+	if err := codec.EncodeFrame(response, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func TestUnpagedReadPageSize(t *testing.T) {
+	proxy := newLimitedProxy(t, Options{UnpagedReadPageSize: 2})
+	spanner := &pagedRows{}
+	for i := 0; i < 5; i++ {
+		spanner.rows = append(spanner.rows, message.Row{{0, 0, 0, byte(i)}})
+	}
+	MockPayloadAdaptMessageGrpc("cassandra", spanner.respond)
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Unpaged reads are read from Spanner a page at a time, and answered at
+	// once.
+	writeFrame(t, conn, 1, &message.Query{
+		Query:   "SELECT id FROM ks.t",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	})
+	got, err := codec.DecodeFrame(conn)
+	require.NoError(t, err)
+	assert.Equal(t, int16(1), got.Header.StreamId)
+	rows, ok := got.Body.Message.(*message.RowsResult)
+	require.True(t, ok)
+	assert.Equal(t, spanner.rows, rows.Data)
+	assert.Empty(t, rows.Metadata.PagingState)
+	assert.Equal(t, []int32{2, 2, 2}, spanner.requestedPageSizes())
+
+	// Paged reads are sent unchanged.
+	writeFrame(t, conn, 2, &message.Query{
+		Query: "SELECT id FROM ks.t",
+		Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelOne,
+			PageSize:    3,
+		},
+	})
+	got, err = codec.DecodeFrame(conn)
+	require.NoError(t, err)
+	rows, ok = got.Body.Message.(*message.RowsResult)
+	require.True(t, ok)
+	assert.Len(t, rows.Data, 3)
+	assert.Equal(t, []byte("3"), rows.Metadata.PagingState)
+	assert.Equal(t, []int32{3}, spanner.requestedPageSizes())
+}
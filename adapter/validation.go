@@ -0,0 +1,224 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Sizes in bytes of the values of the fixed size CQL types.
+var fixedTypeSizes = map[primitive.DataTypeCode]int{
+	primitive.DataTypeCodeBoolean:   1,
+	primitive.DataTypeCodeInt:       4,
+	primitive.DataTypeCodeBigint:    8,
+	primitive.DataTypeCodeCounter:   8,
+	primitive.DataTypeCodeSmallint:  2,
+	primitive.DataTypeCodeTinyint:   1,
+	primitive.DataTypeCodeDouble:    8,
+	primitive.DataTypeCodeFloat:     4,
+	primitive.DataTypeCodeTimestamp: 8,
+	primitive.DataTypeCodeUuid:      16,
+	primitive.DataTypeCodeTimeuuid:  16,
+	primitive.DataTypeCodeDate:      4,
+	primitive.DataTypeCodeTime:      8,
+}
+
+// preparedVariables remembers the bind variables of the statements of
+// prepared query ids, as returned to the driver, to validate the values bound
+// by their executions.
+type preparedVariables struct {
+	cache *lru.Cache
+}
+
+func newPreparedVariables(size int) (*preparedVariables, error) {
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &preparedVariables{cache: cache}, nil
+}
+
+func (pv *preparedVariables) remember(id []byte, columns []*message.ColumnMetadata) {
+	if pv != nil {
+		pv.cache.Add(string(id), columns)
+	}
+}
+
+func (pv *preparedVariables) lookup(id []byte) ([]*message.ColumnMetadata, bool) {
+	if pv == nil {
+		return nil, false
+	}
+	columns, ok := pv.cache.Get(string(id))
+	if !ok {
+		return nil, false
+	}
+	return columns.([]*message.ColumnMetadata), true
+}
+
+// rememberBindVariables records the bind variables of the prepared query id
+// returned for req.
+func (dc *driverConnection) rememberBindVariables(
+	req *requestState,
+	encoded []byte,
+) {
+	if dc.executor.variables == nil {
+		return
+	}
+	if _, ok := req.frame.Body.Message.(*message.Prepare); !ok {
+		return
+	}
+	frm, err := dc.decodeFrame(encoded)
+	if err != nil {
+		return
+	}
+	prepared, ok := frm.Body.Message.(*message.PreparedResult)
+	if !ok || prepared.VariablesMetadata == nil {
+		return
+	}
+	dc.executor.variables.remember(
+		prepared.PreparedQueryId,
+		prepared.VariablesMetadata.Columns,
+	)
+}
+
+// tryValidateBindValues checks the number of values bound by req, and the
+// size of the values bound to fixed size types, against the bind variables
+// of its prepared statements or the bind markers of its unprepared ones.
+// Returns an Invalid error message naming the column of the first invalid
+// value, without sending req. Executions of unknown prepared query ids are
+// not validated.
+func (dc *driverConnection) tryValidateBindValues(req *requestState) message.Message {
+	pv := dc.executor.variables
+	if pv == nil {
+		return nil
+	}
+	var err error
+	switch msg := req.frame.Body.Message.(type) {
+	case *message.Query:
+		if msg.Options != nil && len(msg.Options.PositionalValues) > 0 {
+			err = checkValueCount(
+				len(bindMarkerNames(msg.Query)),
+				len(msg.Options.PositionalValues),
+			)
+		}
+	case *message.Execute:
+		if columns, ok := pv.lookup(msg.QueryId); ok && msg.Options != nil {
+			err = validateBindValues(columns, msg.Options)
+		}
+	case *message.Batch:
+		for i, child := range msg.Children {
+			if child.Query != "" {
+				if len(child.Values) > 0 {
+					err = checkValueCount(len(bindMarkerNames(child.Query)), len(child.Values))
+				}
+			} else if columns, ok := pv.lookup(child.Id); ok {
+				err = validateBindValues(
+					columns,
+					&message.QueryOptions{PositionalValues: child.Values},
+				)
+			}
+			if err != nil {
+				err = fmt.Errorf("statement %d of batch: %w", i, err)
+				break
+			}
+		}
+	}
+	if err != nil {
+		return &message.Invalid{ErrorMessage: err.Error()}
+	}
+	return nil
+}
+
+func checkValueCount(expected, got int) error {
+	if expected != got {
+		return fmt.Errorf(
+			"invalid amount of bind variables: expected %d but got %d",
+			expected,
+			got,
+		)
+	}
+	return nil
+}
+
+// validateBindValues validates the values of options against the bind
+// variables of a prepared statement.
+func validateBindValues(
+	columns []*message.ColumnMetadata,
+	options *message.QueryOptions,
+) error {
+	if len(options.NamedValues) > 0 {
+		for name, value := range options.NamedValues {
+			column := namedVariable(columns, name)
+			if column == nil {
+				return fmt.Errorf("unknown bind variable %s", name)
+			}
+			if err := validateBindValue(column, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := checkValueCount(len(columns), len(options.PositionalValues)); err != nil {
+		return err
+	}
+	for i, value := range options.PositionalValues {
+		if err := validateBindValue(columns[i], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namedVariable returns the bind variable of name. Unquoted names are case
+// insensitive.
+func namedVariable(columns []*message.ColumnMetadata, name string) *message.ColumnMetadata {
+	for _, column := range columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, name) {
+			return column
+		}
+	}
+	return nil
+}
+
+// validateBindValue checks the size of a value bound to a fixed size type.
+// Empty values are valid values of every type.
+func validateBindValue(column *message.ColumnMetadata, value *primitive.Value) error {
+	if value == nil || value.Type != primitive.ValueTypeRegular || column.Type == nil {
+		return nil
+	}
+	size, ok := fixedTypeSizes[column.Type.Code()]
+	if !ok || len(value.Contents) == 0 || len(value.Contents) == size {
+		return nil
+	}
+	return fmt.Errorf(
+		"invalid value for column %s of type %v: expected %d bytes but got %d",
+		column.Name,
+		column.Type,
+		size,
+		len(value.Contents),
+	)
+}
@@ -0,0 +1,142 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBindValues(t *testing.T) {
+	variables, err := newPreparedVariables(10)
+	require.NoError(t, err)
+	dc := &driverConnection{
+		executor: &requestExecutor{variables: variables},
+		codec:    codec,
+	}
+	newRequest := func(msg message.Message) *requestState {
+		return &requestState{frame: *frame.NewFrame(primitive.ProtocolVersion4, 1, msg)}
+	}
+
+	prepared := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.PreparedResult{
+		PreparedQueryId: []byte("id"),
+		VariablesMetadata: &message.VariablesMetadata{Columns: []*message.ColumnMetadata{
+			{Keyspace: "ks", Table: "users", Name: "id", Type: datatype.Int},
+			{Keyspace: "ks", Table: "users", Name: "name", Type: datatype.Varchar},
+		}},
+		ResultMetadata: &message.RowsMetadata{},
+	})
+	prepared.Header.IsResponse = true
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(prepared, buf))
+	dc.rememberBindVariables(
+		newRequest(&message.Prepare{Query: "UPDATE users SET name = ? WHERE id = ?"}),
+		buf.Bytes(),
+	)
+
+	execute := func(values ...*primitive.Value) *requestState {
+		return newRequest(&message.Execute{
+			QueryId: []byte("id"),
+			Options: &message.QueryOptions{PositionalValues: values},
+		})
+	}
+	for name, tc := range map[string]struct {
+		req     *requestState
+		invalid string
+	}{
+		"valid execution": {
+			req: execute(primitive.NewValue(encodeInt(1)), primitive.NewValue([]byte("a"))),
+		},
+		"null and empty values": {
+			req: execute(primitive.NewNullValue(), primitive.NewValue([]byte{})),
+		},
+		"too many values": {
+			req: execute(
+				primitive.NewValue(encodeInt(1)),
+				primitive.NewValue([]byte("a")),
+				primitive.NewValue([]byte("b")),
+			),
+			invalid: "invalid amount of bind variables: expected 2 but got 3",
+		},
+		"invalid size": {
+			req:     execute(primitive.NewValue(encodeBigint(1)), primitive.NewValue([]byte("a"))),
+			invalid: "expected 4 bytes but got 8",
+		},
+		"named values": {
+			req: newRequest(&message.Execute{
+				QueryId: []byte("id"),
+				Options: &message.QueryOptions{NamedValues: map[string]*primitive.Value{
+					"ID": primitive.NewValue(encodeInt(1)),
+				}},
+			}),
+		},
+		"unknown named value": {
+			req: newRequest(&message.Execute{
+				QueryId: []byte("id"),
+				Options: &message.QueryOptions{NamedValues: map[string]*primitive.Value{
+					"other": primitive.NewValue(encodeInt(1)),
+				}},
+			}),
+			invalid: "unknown bind variable other",
+		},
+		"unknown prepared id": {
+			req: newRequest(&message.Execute{
+				QueryId: []byte("unknown"),
+				Options: &message.QueryOptions{},
+			}),
+		},
+		"unprepared query": {
+			req: newRequest(&message.Query{
+				Query: "SELECT * FROM users WHERE id = ?",
+				Options: &message.QueryOptions{PositionalValues: []*primitive.Value{
+					primitive.NewValue(encodeInt(1)),
+					primitive.NewValue(encodeInt(2)),
+				}},
+			}),
+			invalid: "invalid amount of bind variables: expected 1 but got 2",
+		},
+		"batch": {
+			req: newRequest(&message.Batch{Children: []*message.BatchChild{
+				{Query: "DELETE FROM users WHERE id = 1"},
+				{Id: []byte("id"), Values: []*primitive.Value{primitive.NewValue(encodeInt(1))}},
+			}}),
+			invalid: "statement 1 of batch: invalid amount of bind variables: expected 2 but got 1",
+		},
+	} {
+		errMsg := dc.tryValidateBindValues(tc.req)
+		if tc.invalid == "" {
+			assert.Nil(t, errMsg, name)
+			continue
+		}
+		require.IsType(t, &message.Invalid{}, errMsg, name)
+		assert.Contains(t, errMsg.(*message.Invalid).ErrorMessage, tc.invalid, name)
+	}
+
+	// Validation is disabled with DisableBindValidation.
+	dc.executor.variables = nil
+	assert.Nil(t, dc.tryValidateBindValues(execute()))
+}
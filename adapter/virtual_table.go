@@ -0,0 +1,387 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+)
+
+var (
+	selectFromPattern = regexp.MustCompile(
+		`(?is)^\s*select\s+(.+?)\s+from\s+"?(\w+)"?\s*\.\s*"?(\w+)"?(?:\s|;|$)`,
+	)
+	// Matches the WHERE clause of a query on a virtual table.
+	whereKeyPattern = regexp.MustCompile(
+		`(?is)\swhere\s+"?(\w+)"?\s*=\s*(\?|'(?:[^']|'')*'|[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\s*;?\s*$`,
+	)
+	whereClausePattern = regexp.MustCompile(`(?is)\swhere\s`)
+)
+
+// virtualTable is a read-only table emulated by the proxy. Queries on virtual
+// tables are answered locally and never reach Spanner. The first column is the
+// partition key, queries may restrict it with WHERE <key> = <value>.
+type virtualTable struct {
+	keyspace string
+	name     string
+	columns  []*message.ColumnMetadata
+	rows     func(dc *driverConnection, query string) message.RowSet
+	// Whether queries may filter the table with any WHERE clause, ie: on
+	// tables without rows.
+	anyFilter bool
+}
+
+// virtualTables lists the tables emulated by the proxy.
+var virtualTables = []*virtualTable{
+	proxyInfoTable,
+	traceSessionsTable,
+	traceEventsTable,
+}
+
+// proxyInfoTable exposes the version and configuration of the proxy serving
+// the connection.
+var proxyInfoTable = &virtualTable{
+	keyspace: "system",
+	name:     "spanner_proxy_info",
+	columns: virtualColumns("system", "spanner_proxy_info", []columnDef{
+		{"key", datatype.Varchar},
+		{"version", datatype.Varchar},
+		{"protocol", datatype.Varchar},
+		{"features", datatype.Varchar},
+		{"database_uri_hash", datatype.Varchar},
+		{"session_age_seconds", datatype.Bigint},
+		{"connection_id", datatype.Int},
+	}),
+	rows: func(dc *driverConnection, _ string) message.RowSet {
+		uriHash := sha256.Sum256([]byte(dc.adapterClient.opts.DatabaseUri))
+		var sessionAge time.Duration
+		if created := dc.adapterClient.getSession().createTime; !created.IsZero() {
+			sessionAge = time.Since(created)
+		}
+		return message.RowSet{{
+			[]byte("local"),
+			[]byte(version),
+			[]byte(dc.protocol.Name()),
+			[]byte(strings.Join(enabledFeatures(dc.executor.opts), ",")),
+			[]byte(hex.EncodeToString(uriHash[:8])),
+			encodeBigint(int64(sessionAge.Seconds())),
+			encodeInt(int32(dc.connectionID)),
+		}}
+	},
+}
+
+// enabledFeatures returns the names of the optional proxy features enabled by
+// opts.
+func enabledFeatures(opts *Options) []string {
+	features := []string{
+		"timestamp_bound_reads",
+		"structured_errors",
+		"explicit_transactions",
+	}
+	if !opts.DisableAdaptMessageRetry {
+		features = append(features, "adapt_message_retry")
+	}
+	if opts.UnhealthyChannelThreshold >= 0 {
+		features = append(features, "channel_rotation")
+	}
+	if !opts.DisablePreparedResultCache {
+		features = append(features, "prepared_result_cache")
+	}
+	if opts.MaxCommitDelay > 0 {
+		features = append(features, "max_commit_delay")
+	}
+	if len(opts.EnablePartitionedDMLFor) > 0 {
+		features = append(features, "partitioned_dml")
+	}
+	if len(opts.EnableMutationsFor) > 0 {
+		features = append(features, "mutations")
+	}
+	if opts.EnableChangeStreams {
+		features = append(features, "change_streams")
+	}
+	if opts.CqlshCompatibility {
+		features = append(features, "cqlsh_compatibility")
+	}
+	return features
+}
+
+type columnDef struct {
+	name     string
+	dataType datatype.DataType
+}
+
+func virtualColumns(
+	keyspace, table string,
+	defs []columnDef,
+) []*message.ColumnMetadata {
+	columns := make([]*message.ColumnMetadata, 0, len(defs))
+	for _, def := range defs {
+		columns = append(columns, &message.ColumnMetadata{
+			Keyspace: keyspace,
+			Table:    table,
+			Name:     def.name,
+			Type:     def.dataType,
+		})
+	}
+	return columns
+}
+
+func encodeBigint(v int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(v))
+}
+
+func encodeInt(v int32) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(v))
+}
+
+// lookupVirtualTable returns the virtual table selected by query, along with
+// the indexes of the selected columns.
+func lookupVirtualTable(query string) (*virtualTable, []int, message.Message) {
+	return selectVirtualTable(query, virtualTables)
+}
+
+// servedVirtualTable looks up the virtual table selected by query among the
+// tables emulated for the connection.
+func (dc *driverConnection) servedVirtualTable(
+	query string,
+) (*virtualTable, []int, message.Message) {
+	vt, projection, errMsg := lookupVirtualTable(query)
+	if vt == nil && dc.executor.opts.SyntheticPeerCount > 0 {
+		vt, projection, errMsg = selectVirtualTable(query, peerTables)
+	}
+	if vt == nil && dc.executor.opts.CqlshCompatibility {
+		return selectVirtualTable(query, cqlshTables)
+	}
+	return vt, projection, errMsg
+}
+
+// selectVirtualTable returns the table of tables selected by query, along
+// with the indexes of the selected columns.
+func selectVirtualTable(
+	query string,
+	tables []*virtualTable,
+) (*virtualTable, []int, message.Message) {
+	m := selectFromPattern.FindStringSubmatch(query)
+	if m == nil {
+		return nil, nil, nil
+	}
+	keyspace, name := strings.ToLower(m[2]), strings.ToLower(m[3])
+	for _, vt := range tables {
+		if vt.keyspace != keyspace || vt.name != name {
+			continue
+		}
+		projection, errMsg := vt.projection(m[1])
+		return vt, projection, errMsg
+	}
+	return nil, nil, nil
+}
+
+// projection resolves the select list of a query into column indexes.
+func (vt *virtualTable) projection(selectList string) ([]int, message.Message) {
+	var indexes []int
+	if strings.TrimSpace(selectList) == "*" {
+		for i := range vt.columns {
+			indexes = append(indexes, i)
+		}
+		return indexes, nil
+	}
+	for _, col := range strings.Split(selectList, ",") {
+		col = strings.ToLower(identifierQuotes.Replace(strings.TrimSpace(col)))
+		found := false
+		for i, c := range vt.columns {
+			if c.Name == col {
+				indexes = append(indexes, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, &message.Invalid{
+				ErrorMessage: fmt.Sprintf(
+					"Undefined column name %s in table %s.%s",
+					col, vt.keyspace, vt.name,
+				),
+			}
+		}
+	}
+	return indexes, nil
+}
+
+// keyFilter parses the WHERE clause of query. Returns the partition key
+// literal of the query, if any, and whether the key is a bind marker.
+func (vt *virtualTable) keyFilter(query string) ([]byte, bool, message.Message) {
+	if vt.anyFilter || !whereClausePattern.MatchString(query) {
+		return nil, false, nil
+	}
+	m := whereKeyPattern.FindStringSubmatch(query)
+	if m == nil || strings.ToLower(m[1]) != vt.columns[0].Name {
+		return nil, false, &message.Invalid{
+			ErrorMessage: fmt.Sprintf(
+				"Only WHERE %s = <value> is supported on table %s.%s",
+				vt.columns[0].Name, vt.keyspace, vt.name,
+			),
+		}
+	}
+	switch literal := m[2]; {
+	case literal == "?":
+		return nil, true, nil
+	case strings.HasPrefix(literal, "'"):
+		return []byte(strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")), false, nil
+	default:
+		key, _ := hex.DecodeString(strings.ReplaceAll(literal, "-", ""))
+		return key, false, nil
+	}
+}
+
+// boundKey returns the partition key bound to the single bind marker of a
+// query, either by position or by name, or an error message if it is missing.
+func (vt *virtualTable) boundKey(options *message.QueryOptions) ([]byte, message.Message) {
+	if options != nil && len(options.NamedValues) == 1 {
+		for _, value := range options.NamedValues {
+			if value != nil {
+				return value.Contents, nil
+			}
+		}
+	}
+	if options == nil || len(options.PositionalValues) != 1 ||
+		options.PositionalValues[0] == nil {
+		return nil, &message.Invalid{
+			ErrorMessage: fmt.Sprintf("Expected a value for %s", vt.columns[0].Name),
+		}
+	}
+	return options.PositionalValues[0].Contents, nil
+}
+
+// serve answers a query on the virtual table, with options holding the values
+// of its bind markers.
+func (vt *virtualTable) serve(
+	dc *driverConnection,
+	query string,
+	projection []int,
+	options *message.QueryOptions,
+) message.Message {
+	key, bound, errMsg := vt.keyFilter(query)
+	if errMsg != nil {
+		return errMsg
+	}
+	if bound {
+		if key, errMsg = vt.boundKey(options); errMsg != nil {
+			return errMsg
+		}
+	}
+	return vt.result(dc, query, projection, key)
+}
+
+func (vt *virtualTable) metadata(projection []int) *message.RowsMetadata {
+	columns := make([]*message.ColumnMetadata, 0, len(projection))
+	for _, i := range projection {
+		columns = append(columns, vt.columns[i])
+	}
+	return &message.RowsMetadata{
+		ColumnCount: int32(len(columns)),
+		Columns:     columns,
+	}
+}
+
+func (vt *virtualTable) result(
+	dc *driverConnection,
+	query string,
+	projection []int,
+	key []byte,
+) *message.RowsResult {
+	var data message.RowSet
+	for _, row := range vt.rows(dc, query) {
+		if key != nil && !bytes.Equal(row[0], key) {
+			continue
+		}
+		projected := make(message.Row, 0, len(projection))
+		for _, i := range projection {
+			projected = append(projected, row[i])
+		}
+		data = append(data, projected)
+	}
+	return &message.RowsResult{Metadata: vt.metadata(projection), Data: data}
+}
+
+// virtualQueryId returns the prepared query id of a virtual table query. The
+// prefix keeps it distinct from the ids issued by Spanner.
+func virtualQueryId(query string) []byte {
+	sum := sha256.Sum256([]byte(query))
+	return []byte(virtualQueryIdPrefix + hex.EncodeToString(sum[:16]))
+}
+
+// tryServeVirtualTable answers QUERY, PREPARE and EXECUTE requests on virtual
+// tables. Returns nil if the request does not target a virtual table.
+func (dc *driverConnection) tryServeVirtualTable(frm *frame.Frame) message.Message {
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		vt, projection, errMsg := dc.servedVirtualTable(msg.Query)
+		if vt == nil || errMsg != nil {
+			return errMsg
+		}
+		return vt.serve(dc, msg.Query, projection, msg.Options)
+	case *message.Prepare:
+		vt, projection, errMsg := dc.servedVirtualTable(msg.Query)
+		if vt == nil || errMsg != nil {
+			return errMsg
+		}
+		_, bound, errMsg := vt.keyFilter(msg.Query)
+		if errMsg != nil {
+			return errMsg
+		}
+		variables := &message.VariablesMetadata{}
+		if bound {
+			variables.Columns = vt.columns[:1]
+			variables.PkIndices = []uint16{0}
+		}
+		id := virtualQueryId(msg.Query)
+		dc.globalState.Store(string(id), msg.Query)
+		return &message.PreparedResult{
+			PreparedQueryId:   id,
+			VariablesMetadata: variables,
+			ResultMetadata:    vt.metadata(projection),
+		}
+	case *message.Execute:
+		if !strings.HasPrefix(string(msg.QueryId), virtualQueryIdPrefix) {
+			return nil
+		}
+		query, found := dc.globalState.Load(string(msg.QueryId))
+		if !found {
+			return &message.Unprepared{
+				ErrorMessage: "Unknown prepared query in client side cache",
+				Id:           msg.QueryId,
+			}
+		}
+		vt, projection, errMsg := dc.servedVirtualTable(query)
+		if vt == nil || errMsg != nil {
+			return errMsg
+		}
+		return vt.serve(dc, query, projection, msg.Options)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupVirtualTable(t *testing.T) {
+	testCases := []struct {
+		query          string
+		wantTable      bool
+		wantProjection []int
+		wantErr        bool
+	}{
+		{
+			query:          "SELECT * FROM system.spanner_proxy_info",
+			wantTable:      true,
+			wantProjection: []int{0, 1, 2, 3, 4, 5, 6},
+		},
+		{
+			query:          `select Version, "protocol" from "system".spanner_proxy_info;`,
+			wantTable:      true,
+			wantProjection: []int{1, 2},
+		},
+		{
+			query:     "SELECT unknown FROM system.spanner_proxy_info",
+			wantTable: true,
+			wantErr:   true,
+		},
+		{query: "SELECT * FROM system.local"},
+		{query: "SELECT * FROM ks.spanner_proxy_info"},
+		{query: "INSERT INTO system.spanner_proxy_info (key) VALUES ('a')"},
+	}
+	for _, tc := range testCases {
+		vt, projection, errMsg := lookupVirtualTable(tc.query)
+		assert.Equal(t, tc.wantTable, vt != nil, tc.query)
+		if tc.wantErr {
+			assert.IsType(t, &message.Invalid{}, errMsg, tc.query)
+			continue
+		}
+		assert.Nil(t, errMsg, tc.query)
+		assert.Equal(t, tc.wantProjection, projection, tc.query)
+	}
+}
+
+func TestEnabledFeatures(t *testing.T) {
+	features := enabledFeatures(&Options{
+		DisablePreparedResultCache: true,
+		UnhealthyChannelThreshold:  -1,
+		EnablePartitionedDMLFor:    []string{"t"},
+	})
+	assert.Contains(t, features, "partitioned_dml")
+	assert.Contains(t, features, "adapt_message_retry")
+	assert.NotContains(t, features, "prepared_result_cache")
+	assert.NotContains(t, features, "channel_rotation")
+}
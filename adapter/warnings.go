@@ -0,0 +1,183 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// Prefix of the global state keys of the warnings of prepared query ids.
+const preparedWarningsPrefix = "warnings/"
+
+// preparedWarnings remembers the warnings of the statements of prepared query
+// ids in the global state, so that they are returned with every execution of
+// the statements.
+type preparedWarnings struct {
+	state *globalState
+}
+
+func newPreparedWarnings(state *globalState) *preparedWarnings {
+	return &preparedWarnings{state: state}
+}
+
+func (pw *preparedWarnings) remember(id []byte, warnings []string) {
+	if pw != nil && len(warnings) > 0 {
+		pw.state.storeValue(preparedWarningsPrefix+string(id), warnings, 0)
+	}
+}
+
+func (pw *preparedWarnings) lookup(id []byte) []string {
+	if pw == nil {
+		return nil
+	}
+	warnings, ok := pw.state.loadValue(preparedWarningsPrefix + string(id))
+	if !ok {
+		return nil
+	}
+	return warnings.([]string)
+}
+
+// queriesOf returns the statements of a QUERY, PREPARE or BATCH request.
+func queriesOf(frm *frame.Frame) []string {
+	var queries []string
+	switch msg := frm.Body.Message.(type) {
+	case *message.Query:
+		queries = append(queries, msg.Query)
+	case *message.Prepare:
+		queries = append(queries, msg.Query)
+	case *message.Batch:
+		for _, child := range msg.Children {
+			if child.Query != "" {
+				queries = append(queries, child.Query)
+			}
+		}
+	}
+	return queries
+}
+
+// hasAllowFiltering reports whether a statement has an ALLOW FILTERING
+// clause.
+func hasAllowFiltering(query string) bool {
+	if !strings.Contains(strings.ToLower(query), "filtering") {
+		return false
+	}
+	tokens := tokenizeCQL(query)
+	for i := 0; i+1 < len(tokens); i++ {
+		if tokens[i].is("allow") && tokens[i+1].is("filtering") {
+			return true
+		}
+	}
+	return false
+}
+
+// statementWarnings returns the warnings about the clauses of a statement
+// that are ignored.
+func statementWarnings(query string) []string {
+	var warnings []string
+	if hasAllowFiltering(query) {
+		warnings = append(warnings,
+			"ALLOW FILTERING is ignored, Spanner executes queries filtering on any column")
+	}
+	if table, ok := writeTimestampTable(query); ok {
+		warnings = append(warnings, fmt.Sprintf(
+			"USING TIMESTAMP is ignored on table %s, Spanner orders writes by commit timestamp",
+			table,
+		))
+	}
+	return warnings
+}
+
+// appendWarnings appends the warnings that are not in warnings yet.
+func appendWarnings(warnings []string, more ...string) []string {
+	for _, warning := range more {
+		found := false
+		for _, w := range warnings {
+			if w == warning {
+				found = true
+				break
+			}
+		}
+		if !found {
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings
+}
+
+// requestWarnings returns the warnings of a request about the clauses and
+// options that are not honored. For prepared statements, these are the
+// warnings of their statement recorded when they were prepared.
+func (re *requestExecutor) requestWarnings(frm *frame.Frame) []string {
+	var warnings []string
+	for _, query := range queriesOf(frm) {
+		warnings = appendWarnings(warnings, statementWarnings(query)...)
+		if re.opts.FullScanPolicy != FullScanWarn {
+			continue
+		}
+		if table, reason, ok := fullScanTable(query); ok {
+			warnings = appendWarnings(warnings, fullScanWarning(table, reason))
+		}
+	}
+	switch msg := frm.Body.Message.(type) {
+	case *message.Execute:
+		warnings = appendWarnings(warnings, re.warnings.lookup(msg.QueryId)...)
+	case *message.Batch:
+		for _, child := range msg.Children {
+			if child.Query == "" {
+				warnings = appendWarnings(warnings, re.warnings.lookup(child.Id)...)
+			}
+		}
+	}
+	if level, ok := consistencyOf(frm); ok {
+		dml := isDML(frm)
+		if mapConsistency(level, dml) == consistencyInvalid {
+			warnings = append(warnings, fmt.Sprintf(
+				"Consistency level %v is not supported for %s statements, the statement is served with strong consistency",
+				level,
+				statementKind(dml),
+			))
+		}
+	}
+	return warnings
+}
+
+// attachWarnings adds the warnings of req to the encoded response. The
+// warnings of PREPARE requests are remembered for the executions of the
+// statement instead.
+func (dc *driverConnection) attachWarnings(
+	req *requestState,
+	encoded []byte,
+) []byte {
+	if len(req.warnings) == 0 ||
+		req.frame.Header.Version < primitive.ProtocolVersion4 {
+		return encoded
+	}
+	if _, ok := req.frame.Body.Message.(*message.Prepare); ok {
+		if id, ok := dc.preparedQueryId(encoded); ok {
+			dc.executor.warnings.remember(id, req.warnings)
+		}
+		return encoded
+	}
+	return dc.amendResponse(encoded, func(frm *frame.Frame) {
+		frm.SetWarnings(appendWarnings(frm.Body.Warnings, req.warnings...))
+	})
+}
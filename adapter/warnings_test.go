@@ -0,0 +1,122 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasAllowFiltering(t *testing.T) {
+	assert.True(t, hasAllowFiltering("SELECT * FROM t WHERE a = 1 ALLOW FILTERING"))
+	assert.True(t, hasAllowFiltering("select * from t where a = ? allow  filtering;"))
+	assert.False(t, hasAllowFiltering("SELECT * FROM t WHERE a = 'allow filtering'"))
+	assert.False(t, hasAllowFiltering("SELECT filtering FROM t"))
+}
+
+func TestRequestWarnings(t *testing.T) {
+	state, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	warnings := newPreparedWarnings(state)
+	re := &requestExecutor{opts: &Options{}, warnings: warnings}
+	newFrame := func(msg message.Message) *frame.Frame {
+		return frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+	}
+
+	assert.Empty(t, re.requestWarnings(newFrame(&message.Query{
+		Query:   "SELECT * FROM t WHERE id = 1",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelQuorum},
+	})))
+	assert.Len(t, re.requestWarnings(newFrame(&message.Query{
+		Query:   "SELECT * FROM t WHERE a = 1 ALLOW FILTERING",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelAny},
+	})), 2)
+
+	prepared := re.requestWarnings(newFrame(&message.Prepare{
+		Query: "SELECT * FROM t WHERE a = ? ALLOW FILTERING",
+	}))
+	require.Len(t, prepared, 1)
+	warnings.remember([]byte("id1"), prepared)
+	assert.Equal(t, prepared, re.requestWarnings(newFrame(&message.Execute{
+		QueryId: []byte("id1"),
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	})))
+
+	// Warnings of repeated batch statements are returned once.
+	assert.Len(t, re.requestWarnings(newFrame(&message.Batch{
+		Type:        primitive.BatchTypeLogged,
+		Consistency: primitive.ConsistencyLevelQuorum,
+		Children: []*message.BatchChild{
+			{Query: "INSERT INTO t (id) VALUES (1) USING TIMESTAMP 5"},
+			{Query: "INSERT INTO t (id) VALUES (2) USING TIMESTAMP 5"},
+		},
+	})), 1)
+}
+
+func TestAttachWarnings(t *testing.T) {
+	state, err := NewDefaultGlobalState(10)
+	require.NoError(t, err)
+	warnings := newPreparedWarnings(state)
+	dc := &driverConnection{
+		executor: &requestExecutor{opts: &Options{}, warnings: warnings},
+		codec:    codec,
+	}
+	response := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{})
+	response.Header.IsResponse = true
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, codec.EncodeFrame(response, buf))
+	encoded := buf.Bytes()
+
+	req := &requestState{
+		frame: *frame.NewFrame(
+			primitive.ProtocolVersion4,
+			1,
+			&message.Query{Query: "SELECT * FROM t ALLOW FILTERING"},
+		),
+		warnings: []string{"warning"},
+	}
+	got, err := codec.DecodeFrame(bytes.NewBuffer(dc.attachWarnings(req, encoded)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"warning"}, got.Body.Warnings)
+	assert.True(t, got.Header.Flags.Contains(primitive.HeaderFlagWarning))
+
+	// Warnings are not supported before protocol v4.
+	req.frame.Header.Version = primitive.ProtocolVersion3
+	assert.Equal(t, encoded, dc.attachWarnings(req, encoded))
+
+	// Warnings of PREPARE requests are remembered for their executions.
+	req = &requestState{
+		frame: *frame.NewFrame(
+			primitive.ProtocolVersion4,
+			1,
+			&message.Prepare{Query: "SELECT * FROM t ALLOW FILTERING"},
+		),
+		warnings: []string{"warning"},
+	}
+	prepared := encodePreparedResult(t, 1, []byte("id1"))
+	assert.Equal(t, prepared, dc.attachWarnings(req, prepared))
+	assert.Equal(t, []string{"warning"}, warnings.lookup([]byte("id1")))
+}
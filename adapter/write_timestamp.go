@@ -0,0 +1,92 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+// Tables whose client supplied write timestamps were already reported.
+var reportedWriteTimestamps sync.Map
+
+// writeTimestampTable returns the table of an INSERT, UPDATE or DELETE
+// statement with a USING TIMESTAMP clause.
+func writeTimestampTable(query string) (string, bool) {
+	if !strings.Contains(strings.ToLower(query), "timestamp") {
+		return "", false
+	}
+	_, table, ok := parseDMLTarget(query)
+	if !ok {
+		return "", false
+	}
+	tokens := tokenizeCQL(query)
+	for i := range tokens {
+		if !tokens[i].is("using") {
+			continue
+		}
+		// The USING clause ends with the statement, or with the SET clause of
+		// UPDATE statements, the WHERE clause of DELETE statements, or the IF
+		// clause of conditional INSERT statements.
+		for _, token := range tokens[i+1:] {
+			if token.is("timestamp") {
+				return table, true
+			}
+			if token.is("set") || token.is("where") || token.is("if") {
+				break
+			}
+		}
+		break
+	}
+	return "", false
+}
+
+// tryCheckWriteTimestamp reports the statements of a QUERY, PREPARE or BATCH
+// request that set a client supplied write timestamp, which Spanner does not
+// apply: writes are ordered by their commit timestamp instead. Returns an
+// error message if Options.StrictWriteTimestamps is set.
+func (re *requestExecutor) tryCheckWriteTimestamp(
+	frm *frame.Frame,
+) message.Message {
+	for _, query := range queriesOf(frm) {
+		table, ok := writeTimestampTable(query)
+		if !ok {
+			continue
+		}
+		if re.opts.StrictWriteTimestamps {
+			return &message.Invalid{
+				ErrorMessage: fmt.Sprintf(
+					"USING TIMESTAMP is not supported on table %s: Spanner orders writes by commit timestamp",
+					table,
+				),
+			}
+		}
+		if _, reported := reportedWriteTimestamps.LoadOrStore(table, true); !reported {
+			logger.Warn(
+				"USING TIMESTAMP is ignored, Spanner orders writes by commit timestamp",
+				zap.String("table", table),
+			)
+		}
+	}
+	return nil
+}
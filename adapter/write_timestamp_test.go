@@ -0,0 +1,80 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteTimestampTable(t *testing.T) {
+	testCases := []struct {
+		query     string
+		wantTable string
+		wantOk    bool
+	}{
+		{"INSERT INTO ks.t (id) VALUES (1) USING TIMESTAMP 5", "ks.t", true},
+		{"INSERT INTO t (id) VALUES (1) USING TTL 5 AND TIMESTAMP ?", "t", true},
+		{"UPDATE t USING TIMESTAMP ? SET a = 1 WHERE id = 1", "t", true},
+		{"DELETE FROM t USING TIMESTAMP 5 WHERE id = 1", "t", true},
+		{"UPDATE t USING TTL 5 SET timestamp = 1 WHERE id = 1", "", false},
+		{"INSERT INTO t (id, timestamp) VALUES (1, 2)", "", false},
+		{"SELECT timestamp FROM t", "", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			table, ok := writeTimestampTable(tc.query)
+			assert.Equal(t, tc.wantOk, ok)
+			assert.Equal(t, tc.wantTable, table)
+		})
+	}
+}
+
+func TestTryCheckWriteTimestamp(t *testing.T) {
+	newFrame := func(msg message.Message) *frame.Frame {
+		return frame.NewFrame(primitive.ProtocolVersion4, 1, msg)
+	}
+	frames := []*frame.Frame{
+		newFrame(&message.Query{Query: "DELETE FROM t USING TIMESTAMP 5 WHERE id = 1"}),
+		newFrame(&message.Prepare{Query: "UPDATE t USING TIMESTAMP ? SET a = 1 WHERE id = 1"}),
+		newFrame(&message.Batch{
+			Type: primitive.BatchTypeLogged,
+			Children: []*message.BatchChild{
+				{Id: []byte("id")},
+				{Query: "INSERT INTO t (id) VALUES (1) USING TIMESTAMP 5"},
+			},
+		}),
+	}
+
+	lenient := &requestExecutor{opts: &Options{}}
+	strict := &requestExecutor{opts: &Options{StrictWriteTimestamps: true}}
+	for _, frm := range frames {
+		assert.Nil(t, lenient.tryCheckWriteTimestamp(frm))
+		assert.IsType(t, &message.Invalid{}, strict.tryCheckWriteTimestamp(frm))
+	}
+
+	assert.Nil(t, strict.tryCheckWriteTimestamp(
+		newFrame(&message.Query{Query: "INSERT INTO t (id) VALUES (1)"}),
+	))
+}
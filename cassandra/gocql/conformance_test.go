@@ -0,0 +1,355 @@
+//go:build integration
+// +build integration
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package spanner
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/googleapis/go-spanner-cassandra/cassandra/schema"
+	"gopkg.in/inf.v0"
+)
+
+// collectionElement describes values of a CQL type used as the elements of
+// the collections of the conformance suite.
+type collectionElement struct {
+	cqlType string
+	// Values in insertion order, with a duplicate.
+	values interface{}
+	// The distinct values in the order of the comparator of the type.
+	sorted interface{}
+	// A map keyed by the type.
+	mapped interface{}
+}
+
+func conformanceTimestamp(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
+
+var (
+	conformanceUUIDs = []gocql.UUID{
+		gocql.MustRandomUUID(),
+		gocql.MustRandomUUID(),
+	}
+	conformanceTimeUUIDs = []gocql.UUID{
+		gocql.UUIDFromTime(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+		gocql.UUIDFromTime(time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)),
+	}
+)
+
+// collectionElements are the element types of the conformance suite.
+var collectionElements = []collectionElement{
+	{
+		cqlType: "text",
+		values:  []string{"b", "é", "a", "b"},
+		sorted:  []string{"a", "b", "é"},
+		mapped:  map[string]string{"b": "1", "a": "2"},
+	},
+	{
+		cqlType: "ascii",
+		values:  []string{"b", "a", "b"},
+		sorted:  []string{"a", "b"},
+		mapped:  map[string]string{"b": "1", "a": "2"},
+	},
+	{
+		cqlType: "int",
+		values:  []int32{10, -1, 2, 10},
+		sorted:  []int32{-1, 2, 10},
+		mapped:  map[int32]string{10: "a", 2: "b", -1: "c"},
+	},
+	{
+		cqlType: "bigint",
+		values:  []int64{1 << 40, -5, 3, -5},
+		sorted:  []int64{-5, 3, 1 << 40},
+		mapped:  map[int64]string{1 << 40: "a", 3: "b"},
+	},
+	{
+		cqlType: "smallint",
+		values:  []int16{300, -2, 300},
+		sorted:  []int16{-2, 300},
+		mapped:  map[int16]string{300: "a", -2: "b"},
+	},
+	{
+		cqlType: "tinyint",
+		values:  []int8{7, -7, 7},
+		sorted:  []int8{-7, 7},
+		mapped:  map[int8]string{7: "a", -7: "b"},
+	},
+	{
+		cqlType: "boolean",
+		values:  []bool{true, false, true},
+		sorted:  []bool{false, true},
+		mapped:  map[bool]string{true: "a", false: "b"},
+	},
+	{
+		cqlType: "double",
+		values:  []float64{2.5, -1.25, 10, 2.5},
+		sorted:  []float64{-1.25, 2.5, 10},
+		mapped:  map[float64]string{2.5: "a", -1.25: "b"},
+	},
+	{
+		cqlType: "float",
+		values:  []float32{2.5, -1.25, 2.5},
+		sorted:  []float32{-1.25, 2.5},
+		mapped:  map[float32]string{2.5: "a", -1.25: "b"},
+	},
+	{
+		cqlType: "timestamp",
+		values: []time.Time{
+			conformanceTimestamp(2000),
+			conformanceTimestamp(1000),
+			conformanceTimestamp(2000),
+		},
+		sorted: []time.Time{conformanceTimestamp(1000), conformanceTimestamp(2000)},
+		mapped: map[time.Time]string{
+			conformanceTimestamp(2000): "a",
+			conformanceTimestamp(1000): "b",
+		},
+	},
+	{
+		cqlType: "uuid",
+		values:  []gocql.UUID{conformanceUUIDs[0], conformanceUUIDs[1], conformanceUUIDs[0]},
+		sorted:  sortedUUIDs(conformanceUUIDs),
+		mapped: map[gocql.UUID]string{
+			conformanceUUIDs[0]: "a",
+			conformanceUUIDs[1]: "b",
+		},
+	},
+	{
+		cqlType: "timeuuid",
+		values: []gocql.UUID{
+			conformanceTimeUUIDs[0],
+			conformanceTimeUUIDs[1],
+			conformanceTimeUUIDs[0],
+		},
+		sorted: []gocql.UUID{conformanceTimeUUIDs[1], conformanceTimeUUIDs[0]},
+		mapped: map[gocql.UUID]string{
+			conformanceTimeUUIDs[0]: "a",
+			conformanceTimeUUIDs[1]: "b",
+		},
+	},
+	{
+		cqlType: "blob",
+		values:  [][]byte{{2}, {1, 0}, {2}},
+		sorted:  [][]byte{{1, 0}, {2}},
+		mapped:  map[string][]byte{"a": {1}, "b": {2, 0}},
+	},
+	{
+		cqlType: "inet",
+		values:  []net.IP{net.ParseIP("10.0.0.2").To4(), net.ParseIP("10.0.0.1").To4()},
+		sorted:  []net.IP{net.ParseIP("10.0.0.1").To4(), net.ParseIP("10.0.0.2").To4()},
+		mapped:  map[string]net.IP{"a": net.ParseIP("10.0.0.2").To4()},
+	},
+	{
+		cqlType: "decimal",
+		values:  []*inf.Dec{inf.NewDec(15, 1), inf.NewDec(2, 0)},
+		sorted:  []*inf.Dec{inf.NewDec(15, 1), inf.NewDec(2, 0)},
+		mapped:  map[string]*inf.Dec{"a": inf.NewDec(15, 1)},
+	},
+	{
+		cqlType: "varint",
+		values:  []*big.Int{big.NewInt(100), big.NewInt(-3), big.NewInt(100)},
+		sorted:  []*big.Int{big.NewInt(-3), big.NewInt(100)},
+		mapped:  map[string]*big.Int{"a": big.NewInt(-3)},
+	},
+}
+
+// sortedUUIDs sorts random UUIDs as Cassandra does, by their bytes.
+func sortedUUIDs(uuids []gocql.UUID) []gocql.UUID {
+	sorted := append([]gocql.UUID(nil), uuids...)
+	if string(sorted[0][:]) > string(sorted[1][:]) {
+		sorted[0], sorted[1] = sorted[1], sorted[0]
+	}
+	return sorted
+}
+
+// mapType returns the CQL type of the mapped values of e.
+func (e collectionElement) mapType() string {
+	m := reflect.TypeOf(e.mapped)
+	key, value := e.cqlType, "text"
+	if m.Key().Kind() == reflect.String && e.cqlType != "text" &&
+		e.cqlType != "ascii" {
+		key, value = "text", e.cqlType
+	}
+	return fmt.Sprintf("map<%s, %s>", key, value)
+}
+
+// createCollectionTable creates a table with an int key and the given columns,
+// from the CQL types of its columns on both targets.
+func createCollectionTable(
+	t *testing.T,
+	session *gocql.Session,
+	table string,
+	columns map[string]string,
+) {
+	t.Helper()
+	var defs []string
+	for name, cqlType := range columns {
+		if env != "spanner" {
+			defs = append(defs, fmt.Sprintf("%s %s", name, cqlType))
+			continue
+		}
+		spannerType, err := schema.ConvertType(cqlType)
+		if err != nil {
+			t.Skipf("type %s is not supported by Spanner: %v", cqlType, err)
+		}
+		defs = append(defs, fmt.Sprintf(
+			"%s %s OPTIONS (cassandra_type = '%s')",
+			name,
+			spannerType,
+			strings.ReplaceAll(cqlType, " ", ""),
+		))
+	}
+	if env == "spanner" {
+		createSpannerTable(t, fmt.Sprintf(
+			"CREATE TABLE %s (id INT64 NOT NULL OPTIONS (cassandra_type = 'int'), %s) PRIMARY KEY (id)",
+			table,
+			strings.Join(defs, ", "),
+		))
+		return
+	}
+	createCqlTable(t, session, fmt.Sprintf(
+		"CREATE TABLE %s (id int PRIMARY KEY, %s)",
+		table,
+		strings.Join(defs, ", "),
+	))
+}
+
+// assertSameValues compares values by their formatting, as decoded decimals,
+// varints and timestamps are equal to the values written but not always
+// deeply equal.
+func assertSameValues(
+	t *testing.T,
+	description string,
+	expected, actual interface{},
+) {
+	t.Helper()
+	assertEqual(t, description, fmt.Sprint(expected), fmt.Sprint(actual))
+}
+
+// scanColumn reads column of row id into a new value of the type of like.
+func scanColumn(
+	t *testing.T,
+	session *gocql.Session,
+	table, column string,
+	id int,
+	like interface{},
+) interface{} {
+	t.Helper()
+	dest := reflect.New(reflect.TypeOf(like))
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", column, table)
+	if err := session.Query(query, id).Scan(dest.Interface()); err != nil {
+		t.Fatalf("select %s: %v", column, err)
+	}
+	return dest.Elem().Interface()
+}
+
+// TestCollectionConformance checks that lists, sets and maps of every element
+// type are marshaled and returned as Cassandra does: lists in insertion order
+// with duplicates, sets ordered by their element type without duplicates and
+// maps with all their entries.
+func TestCollectionConformance(t *testing.T) {
+	session := createSession(t)
+	defer session.Close()
+	for _, e := range collectionElements {
+		t.Run(e.cqlType, func(t *testing.T) {
+			table := "conformance_" + e.cqlType
+			createCollectionTable(t, session, table, map[string]string{
+				"l": fmt.Sprintf("list<%s>", e.cqlType),
+				"s": fmt.Sprintf("set<%s>", e.cqlType),
+				"m": e.mapType(),
+			})
+			insert := fmt.Sprintf("INSERT INTO %s (id, l, s, m) VALUES (?, ?, ?, ?)", table)
+			if err := session.Query(insert, 1, e.values, e.values, e.mapped).Exec(); err != nil {
+				t.Fatal("insert:", err)
+			}
+			assertSameValues(t, "list", e.values, scanColumn(t, session, table, "l", 1, e.values))
+			assertSameValues(t, "set", e.sorted, scanColumn(t, session, table, "s", 1, e.sorted))
+			assertSameValues(t, "map", e.mapped, scanColumn(t, session, table, "m", 1, e.mapped))
+
+			// Unset and empty collections are both read as empty.
+			if err := session.Query(fmt.Sprintf("INSERT INTO %s (id) VALUES (?)", table), 2).Exec(); err != nil {
+				t.Fatal("insert:", err)
+			}
+			empty := reflect.MakeSlice(reflect.TypeOf(e.values), 0, 0).Interface()
+			if err := session.Query(insert, 3, empty, empty, nil).Exec(); err != nil {
+				t.Fatal("insert:", err)
+			}
+			for _, id := range []int{2, 3} {
+				assertEqual(t, "list length", 0,
+					reflect.ValueOf(scanColumn(t, session, table, "l", id, e.values)).Len())
+				assertEqual(t, "set length", 0,
+					reflect.ValueOf(scanColumn(t, session, table, "s", id, e.sorted)).Len())
+				assertEqual(t, "map length", 0,
+					reflect.ValueOf(scanColumn(t, session, table, "m", id, e.mapped)).Len())
+			}
+		})
+	}
+}
+
+// TestNestedCollectionConformance checks collections of frozen collections.
+func TestNestedCollectionConformance(t *testing.T) {
+	session := createSession(t)
+	defer session.Close()
+	createCollectionTable(t, session, "conformance_nested", map[string]string{
+		"ml": "map<text, frozen<list<int>>>",
+		"ms": "map<int, frozen<set<text>>>",
+		"lm": "list<frozen<map<text, int>>>",
+	})
+	ml := map[string][]int{"b": {3, 1, 3}, "a": {2}}
+	ms := map[int][]string{10: {"z", "a", "z"}, 2: {"m"}}
+	lm := []map[string]int{{"b": 1, "a": 2}, {"c": 3}}
+	if err := session.Query(
+		"INSERT INTO conformance_nested (id, ml, ms, lm) VALUES (?, ?, ?, ?)",
+		1, ml, ms, lm,
+	).Exec(); err != nil {
+		t.Fatal("insert:", err)
+	}
+	assertDeepEqual(t, "map of lists", ml,
+		scanColumn(t, session, "conformance_nested", "ml", 1, ml))
+	assertDeepEqual(t, "map of sets", map[int][]string{10: {"a", "z"}, 2: {"m"}},
+		scanColumn(t, session, "conformance_nested", "ms", 1, ms))
+	assertDeepEqual(t, "list of maps", lm,
+		scanColumn(t, session, "conformance_nested", "lm", 1, lm))
+}
+
+// TestNullInCollectionConformance checks that null elements of collections
+// are rejected.
+func TestNullInCollectionConformance(t *testing.T) {
+	session := createSession(t)
+	defer session.Close()
+	createCollectionTable(t, session, "conformance_nulls", map[string]string{
+		"l": "list<int>",
+	})
+	one := 1
+	err := session.Query(
+		"INSERT INTO conformance_nulls (id, l) VALUES (?, ?)",
+		1, []*int{&one, nil},
+	).Exec()
+	if err == nil {
+		t.Fatal("expected null list element to be rejected")
+	}
+}
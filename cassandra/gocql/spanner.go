@@ -18,39 +18,428 @@ limitations under the License.
 package spanner
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"net"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"cloud.google.com/go/spanner/apiv1/spannerpb"
 	"github.com/gocql/gocql"
+	"github.com/googleapis/gax-go/v2"
 	"github.com/googleapis/go-spanner-cassandra/adapter"
 	"github.com/googleapis/go-spanner-cassandra/logger"
 	"google.golang.org/api/option"
 )
 
-// Map from cluster config to local proxies.
-var proxyMap = make(
-	map[*gocql.ClusterConfig]*adapter.TCPProxy,
+// Map from cluster config to local proxies, guarded by proxyMu since it is
+// read by monitoring goroutines (ie: ClusterStats) while clusters are created
+// and closed.
+var (
+	proxyMu  sync.RWMutex
+	proxyMap = make(
+		map[*gocql.ClusterConfig]*adapter.TCPProxy,
+	)
 )
 
+// lookupProxy returns the local proxy of the given cluster.
+func lookupProxy(cfg *gocql.ClusterConfig) (*adapter.TCPProxy, bool) {
+	proxyMu.RLock()
+	defer proxyMu.RUnlock()
+	proxy, ok := proxyMap[cfg]
+	return proxy, ok
+}
+
 // Options represents the configuration for a virtual Spanner cluster.
 type Options struct {
 	// Optional Spanner service endpoint. Defaults to spanner.googleapis.com:443
 	SpannerEndpoint string
+	// Optional Spanner endpoints in order of preference, which the proxy fails
+	// over to when SpannerEndpoint is unavailable. Defaults to empty.
+	FailoverEndpoints []string
+	// Optional Spanner endpoints probed at startup along with SpannerEndpoint,
+	// the fastest healthy one of which the proxy connects to. Defaults to
+	// empty.
+	CandidateEndpoints []string
+	// Optional Spanner databases of keyspace qualified tables (ie:
+	// "keyspace.table"), to serve tables that moved to another database.
+	// Statements on other tables are served by the database of the cluster.
+	// Defaults to empty.
+	TableRouting map[string]string
+	// Optional additional listeners of the proxy, serving their connections
+	// with their own database or labels. Defaults to empty.
+	Listeners []adapter.ListenerConfig
 	// Optional Endpoint to start TCP server. Defaults to localhost:9042
 	TCPEndpoint string
+	// Optional inclusive range of fallback ports (ie: "9043-9050") tried on the
+	// host of TCPEndpoint if TCPEndpoint is in use. Defaults to empty.
+	TCPPortRange string
+	// Optional boolean indicate whether to listen on an ephemeral port if
+	// TCPEndpoint and TCPPortRange are in use. Defaults to false.
+	FallbackToEphemeralPort bool
+	// Optional network of the listener: "tcp4" or "tcp6" to only listen on
+	// IPv4 or IPv6 addresses. Defaults to "tcp" (both).
+	ListenNetwork string
+	// Optional name of a network interface (ie: "eth0") whose address the
+	// listener binds to, in place of the host of TCPEndpoint. Defaults to
+	// empty.
+	ListenInterface string
+	// Optional boolean indicate whether to listen with SO_REUSEPORT, so that
+	// a new proxy process can listen on the same endpoints during an in place
+	// upgrade. Defaults to false.
+	ReusePort bool
+	// Optional listener accepting the driver connections in place of a
+	// listener on TCPEndpoint, ie: a listener inherited with systemd socket
+	// activation. Defaults to nil.
+	Listener net.Listener
+	// Optional number of listeners opened on TCPEndpoint with SO_REUSEPORT,
+	// each with its own accept loop. Defaults to 1.
+	ListenerShards int
+	// Optional addresses (ie: "127.0.0.2:9042") of other proxies serving the
+	// same database, ie: sidecar proxies, which the driver sends requests to
+	// along with the local proxy (active/active). Proxies whose connections
+	// fail are skipped until they accept connections again, so that they can
+	// be restarted one at a time without downtime. Statements prepared on
+	// another proxy are prepared again by the driver from their text. Every
+	// proxy must listen on its own IP address, as the driver identifies hosts
+	// by IP. Defaults to empty.
+	ProxyAddresses []string
 	// Required database uri to connect to.
 	DatabaseUri string
 	// Number of channels when dial grpc connection. Defaults to 4.
 	NumGrpcChannels int
+	// Optional lower bound of the number of grpc channels when autoscaling is
+	// enabled. Defaults to 1.
+	MinGrpcChannels int
+	// Optional upper bound of the number of grpc channels. If set, the number
+	// of channels follows the load within [MinGrpcChannels, MaxGrpcChannels].
+	// Defaults to 0, which disables autoscaling.
+	MaxGrpcChannels int
+	// Optional number of grpc channels dedicated to the reads. Defaults to 0,
+	// which sends the reads on the shared channels.
+	ReadChannels int
+	// Optional number of grpc channels dedicated to the writes. Defaults to 0,
+	// which sends the writes on the shared channels.
+	WriteChannels int
+	// Optional Spanner endpoint of the ReadChannels. Defaults to
+	// SpannerEndpoint.
+	ReadEndpoint string
+	// Optional Spanner endpoint of the WriteChannels. Defaults to
+	// SpannerEndpoint.
+	WriteEndpoint string
+	// Optional number of consecutive transport failures after which a grpc
+	// channel is re-dialed. Defaults to 5. A negative value disables channel
+	// rotation.
+	UnhealthyChannelThreshold int
+	// Optional boolean to send all the requests of a driver connection on the
+	// same grpc channel. Defaults to false (round-robin).
+	ChannelAffinity bool
+	// Optional interval of the keepalive pings sent on the grpc channels.
+	// Defaults to 0 (no keepalive pings).
+	GrpcKeepaliveTime time.Duration
+	// Optional time to wait for the acknowledgement of a keepalive ping.
+	// Defaults to 20s.
+	GrpcKeepaliveTimeout time.Duration
+	// Optional minimum time given to the grpc channels to connect to Spanner.
+	// Defaults to 20s.
+	GrpcMinConnectTimeout time.Duration
+	// Optional network of the grpc channels: "tcp4" or "tcp6" to only connect
+	// to the IPv4 or IPv6 addresses of Spanner. Defaults to "tcp" (both).
+	GrpcDialNetwork string
+	// Optional compression of the grpc messages exchanged with Spanner, "none"
+	// or "gzip". Defaults to "none".
+	GrpcCompression string
+	// Optional number of times the statements of an explicit transaction are
+	// sent again when Spanner aborts the transaction. Defaults to 10. A
+	// negative value disables replays.
+	MaxTransactionRetries int
+	// Optional number of consecutive failures to refresh the Spanner session
+	// after which the proxy stops accepting new connections. Defaults to 0
+	// (never stop accepting connections).
+	MaxSessionFailures int
+	// Optional maximum number of open driver connections. Connections accepted
+	// over the limit are answered with an Overloaded error. Defaults to 0
+	// (unlimited).
+	MaxConnections int
+	// Optional time given to connections to complete their handshake, up to
+	// READY or AUTH_SUCCESS, before they are closed. Defaults to 0 (no
+	// timeout).
+	HandshakeTimeout time.Duration
+	// Optional maximum number of driver connections accepted per second.
+	// Defaults to 0 (unlimited).
+	AcceptRate float64
+	// Optional number of connections accepted without delay after the proxy
+	// was idle, with AcceptRate. Defaults to one second of AcceptRate.
+	AcceptBurst int
+	// Optional maximum number of driver connections completing their
+	// handshake concurrently. Defaults to 0 (unlimited).
+	MaxConcurrentHandshakes int
+	// Optional boolean to read the PROXY protocol v2 header sent by L4 load
+	// balancers at the start of connections, and to report the address of the
+	// client it carries. Defaults to false.
+	AcceptProxyProtocol bool
+	// Optional maximum body length in bytes of the frames sent by the driver.
+	// Defaults to 256MiB.
+	MaxFrameSize int
+	// Optional maximum size in bytes of the requests sent to Spanner. Larger
+	// requests are rejected with an Invalid error without being sent. Defaults
+	// to 100MiB.
+	MaxRequestSize int
+	// Optional maximum size in bytes of the response to a request buffered
+	// before it is written to the driver. Larger responses are answered with
+	// an Invalid error. Defaults to 256MiB.
+	MaxResponseSize int
+	// Optional function called once the proxy stopped accepting connections
+	// after MaxSessionFailures consecutive session failures, ie: to terminate
+	// the process. With DrainTimeout, it is called once the open connections
+	// are closed, with an adapter.ErrDrainTimeout error if some were still
+	// open after DrainTimeout. Defaults to nil.
+	OnDrain func(err error)
+	// Optional time given to the open connections to close once the proxy
+	// stopped accepting connections, before they are closed. Defaults to 0
+	// (connections are left open).
+	DrainTimeout time.Duration
+	// Optional interval at which the connections waiting on Spanner or on
+	// their driver for longer than StuckConnectionThreshold are logged.
+	// Defaults to 0 (no periodic report).
+	ConnectionReportInterval time.Duration
+	// Optional time after which a connection waiting on Spanner or on its
+	// driver is reported as stuck. Defaults to 1m.
+	StuckConnectionThreshold time.Duration
+	// Optional interval at which recommendations to tune the proxy for the
+	// sizes of its requests and responses are logged. Defaults to 0 (no
+	// recommendations).
+	PayloadAdvisorInterval time.Duration
+	// Optional boolean to adapt the number of requests sent concurrently to
+	// Spanner to its latencies, answering the requests over the limit with
+	// Overloaded errors. Defaults to false.
+	AdaptiveConcurrency bool
+	// Optional maximum limit of the requests sent concurrently to Spanner
+	// with AdaptiveConcurrency. Defaults to 1000.
+	MaxConcurrencyLimit int
+	// Optional maximum time the errors of the requests Spanner rejected as
+	// overloaded are delayed by, following the retry delay suggested by
+	// Spanner. Defaults to 0 (not delayed).
+	MaxOverloadErrorDelay time.Duration
 	// Optional boolean indicate whether to disable automatic grpc retry for
 	// AdaptMessage API. Defauls to false.
 	DisableAdaptMessageRetry bool
+	// Optional backoff between the retries of AdaptMessage calls. Every retry
+	// waits a random delay between zero and the current backoff (full jitter),
+	// unless Spanner returned a retry delay. Defaults to DefaultRetryBackoff.
+	RetryBackoff gax.Backoff
+	// Optional fraction of the retry delays returned by Spanner added as
+	// random jitter, so that the requests rejected together by an overloaded
+	// Spanner are not retried together. Defaults to 0.
+	RetryJitter float64
+	// Optional number of retries each driver connection earns per request,
+	// ie: 0.1 allows one retry every ten requests. Once a connection spent its
+	// budget, failed requests are returned to the driver instead of being
+	// retried, so that retries can't amplify a Spanner brownout. Defaults to 0
+	// (unlimited retries).
+	RetryBudgetRatio float64
+	// Optional number of retries a driver connection can make beyond
+	// RetryBudgetRatio, ie: right after connecting. Defaults to 10.
+	RetryBudgetBurst int
+	// Optional maximum number of entries (prepared query ids and cached
+	// prepared results) kept in the global state cache. Defaults to 390625.
+	PreparedCacheSize int
+	// Optional store of the prepared query ids and cached prepared results in
+	// place of the default cache of PreparedCacheSize entries. Defaults to nil.
+	StateStore adapter.GlobalState
+	// Optional address (host:port) of a Redis or Memorystore instance the
+	// prepared query ids are shared through. Defaults to empty.
+	StateRedisAddress string
+	// Optional password of the Redis instance of StateRedisAddress. Defaults
+	// to empty.
+	StateRedisPassword string
+	// Optional boolean indicate whether to disable answering repeated PREPARE
+	// requests from the local prepared result cache. Defaults to false.
+	DisablePreparedResultCache bool
+	// Optional boolean indicate whether to return the elements of sets and the
+	// entries of maps in the order Spanner stores them, instead of ordering
+	// them as Cassandra does. Defaults to false.
+	DisableCollectionOrdering bool
+	// Optional boolean indicate whether to disable validating the values bound
+	// by requests against the bind variables of their prepared statements
+	// before sending them. Defaults to false.
+	DisableBindValidation bool
+	// Optional boolean indicate whether to prepare again the unknown prepared
+	// statements of a batch before executing it, instead of rejecting the batch
+	// with an Unprepared error. Defaults to false.
+	EnableBatchReprepare bool
+	// Optional boolean indicate whether to reject statements whose consistency
+	// level is not valid for them (ie: ANY or EACH_QUORUM reads, SERIAL
+	// writes) instead of logging them. Spanner serves all other levels with
+	// strong consistency. Defaults to false.
+	StrictConsistency bool
+	// Optional boolean to reject statements with a USING TIMESTAMP clause
+	// instead of logging them. Spanner orders writes by their commit
+	// timestamp and does not apply client supplied timestamps. Defaults to
+	// false.
+	StrictWriteTimestamps bool
+	// Optional staleness of reads sent with the ONE or LOCAL_ONE consistency
+	// levels. Such reads are served with strong consistency if zero. Defaults
+	// to 0.
+	WeakConsistencyStaleness time.Duration
+	// Optional boolean to make the exact staleness reads of a connection,
+	// either from WeakConsistencyStaleness or from ExactStalenessPayload,
+	// observe the previous writes of the connection. Defaults to false.
+	ReadYourWrites bool
+	// Optional boolean to add the latency of each statement in the proxy and
+	// in Spanner to the custom payload of its response, see
+	// ResponseLatencies. Defaults to false.
+	EnableLatencyPayload bool
+	// Optional boolean to add the number of rows affected by each DML
+	// statement or batch to the custom payload of its response, see
+	// AffectedRows. Defaults to false.
+	EnableRowCountPayload bool
+	// Optional boolean to export the latencies and counts of the operations
+	// and attempts of AdaptMessage calls to Cloud Monitoring, as the built-in
+	// client-side metrics of the Spanner client libraries. Defaults to false.
+	EnableBuiltInMetrics bool
+	// Optional boolean to count the executions, errors and latencies of the
+	// statements by fingerprint, see TopStatements. Defaults to false.
+	EnableStatementMetrics bool
+	// Optional maximum number of fingerprints tracked with
+	// EnableStatementMetrics. Defaults to 1000.
+	MaxStatementFingerprints int
 	// The maximum delay in milliseconds. Default is 0 (disabled).
 	MaxCommitDelay int
+	// Optional list of tables (ie: "keyspace.table" or "table") whose UPDATE and
+	// DELETE query statements are executed as Partitioned DML. Defaults to
+	// empty.
+	EnablePartitionedDMLFor []string
+	// Optional list of tables (ie: "keyspace.table" or "table") whose simple
+	// INSERT statements, without IF NOT EXISTS, USING or JSON clauses, are
+	// applied as Spanner mutations instead of DML. Mutations blindly insert or
+	// update rows. Defaults to empty.
+	EnableMutationsFor []string
+	// Optional policy restricting the statements served by the proxy, ie: to
+	// serve read-only traffic during a migration freeze. Defaults to nil (all
+	// statements are served).
+	StatementPolicy *adapter.StatementPolicy
+	// Optional attachment keys defined by the proxy that the Adapter backend
+	// honors, among read_timestamp, exact_staleness, partitioned_dml,
+	// insert_mutation, request_priority and directed_read_options. Requests
+	// relying on other keys, ie: sent with ReadTimestampPayload, fail with an
+	// Invalid error, and options relying on them fail NewCluster, rather than
+	// being served as if the key was not set. Defaults to empty.
+	SupportedAttachments []string
+	// Optional function computing custom attachments of QUERY, EXECUTE and
+	// BATCH requests, ie: request priorities or tags. Attachments set by the
+	// proxy itself are reserved and fail the request. Defaults to nil.
+	AttachmentProvider adapter.AttachmentProvider
+	// Optional policy for SELECT statements scanning a whole table (without a
+	// WHERE clause, with ALLOW FILTERING or with a token range restriction):
+	// adapter.FullScanAllow, adapter.FullScanLowPriority,
+	// adapter.FullScanWarn or adapter.FullScanReject. Defaults to
+	// adapter.FullScanAllow.
+	FullScanPolicy adapter.FullScanPolicy
+	// Optional replicas the SELECT requests are served by, except SERIAL
+	// reads. Defaults to nil (replicas chosen by Spanner).
+	DirectedReadOptions *spannerpb.DirectedReadOptions
+	// Optional time to live of the cached responses of SELECT statements on
+	// tables (ie: "keyspace.table" or "table"), for small and hot lookup
+	// tables. Requests identical to a previous one, including their bound
+	// values, are answered locally for up to the time to live. Writes through
+	// the proxy drop the cached responses of their table. Defaults to empty
+	// (no caching).
+	ReadCacheTTLs map[string]time.Duration
+	// Optional maximum number of cached responses per table of ReadCacheTTLs.
+	// Defaults to 1000.
+	ReadCacheSize int
+	// Optional expiration columns of tables (ie: "keyspace.table" or "table"),
+	// which USING TTL clauses of INSERT and UPDATE statements are translated
+	// to. The columns must be TIMESTAMP columns used by a row deletion policy.
+	// Statements setting a TTL on other tables are rejected. Defaults to empty.
+	TTLColumns map[string]string
+	// Optional CQL types of the tuple and user-defined type columns stored as
+	// JSON, by column (ie: "keyspace.table.column" or "table.column"), ie:
+	// "frozen<tuple<int, text>>" or "frozen<address>". Defaults to empty.
+	JSONColumns map[string]string
+	// Optional fields of the user-defined types of JSONColumns, by type (ie:
+	// "keyspace.address" or "address"), ie: "street text, zip int". Defaults
+	// to empty.
+	UserTypes map[string]string
+	// Optional names of the codecs registered with adapter.RegisterColumnCodec
+	// transforming the values of columns (ie: "keyspace.table.column" or
+	// "table.column"), by column. Defaults to empty.
+	ColumnCodecs map[string]string
+	// Optional file a sample of the requests is appended to, with their
+	// statements and bound values, to replay them with cmd/replay. Defaults
+	// to empty (disabled).
+	CaptureFile string
+	// Optional fraction of the requests recorded to CaptureFile, between 0 and
+	// 1. Defaults to 0.01.
+	CaptureSampleRate float64
+	// Optional boolean to evaluate calls of now(), uuid(), currentTimestamp()
+	// and similar functions in the proxy, and to rewrite writetime() calls to
+	// read the columns of WriteTimeColumns. Defaults to false.
+	EmulateFunctions bool
+	// Optional boolean to serve queries on the tables of the cdc keyspace from
+	// the Spanner change streams of the same name. Defaults to false.
+	EnableChangeStreams bool
+	// Optional maximum time spent reading a page of a change stream query,
+	// which should not exceed the request timeout of the driver. Defaults to
+	// 10s.
+	ChangeStreamReadTimeout time.Duration
+	// Optional commit timestamp columns of tables (ie: "keyspace.table" or
+	// "table") read by writetime() calls with EmulateFunctions. The columns
+	// must be TIMESTAMP columns set to the commit timestamp of every write.
+	// writetime() calls on other tables are rejected. Defaults to empty.
+	WriteTimeColumns map[string]string
+	// Optional boolean to emulate the Cassandra behaviors cqlsh relies on,
+	// ie: paging of SELECT results. Defaults to false.
+	CqlshCompatibility bool
+	// Optional page size the proxy reads the results of unpaged SELECT
+	// requests with from Spanner. Defaults to 0 (disabled).
+	UnpagedReadPageSize int
+	// Optional maximum number of statements of the UNLOGGED batches of plain
+	// INSERT statements sent to Spanner in a single request, larger batches
+	// being split into concurrent batches. Defaults to 0 (not split).
+	MaxBatchStatements int
+	// Optional maximum number of parts of a split batch sent concurrently.
+	// Defaults to 4.
+	BatchSplitParallelism int
+	// Optional atomicity of LOGGED batches: adapter.BatchAtomic,
+	// adapter.BatchPerPartition or adapter.BatchSinglePartition. Defaults to
+	// adapter.BatchAtomic.
+	LoggedBatchAtomicity adapter.BatchAtomicity
+	// Optional atomicity of UNLOGGED batches, as LoggedBatchAtomicity.
+	// Defaults to adapter.BatchAtomic.
+	UnloggedBatchAtomicity adapter.BatchAtomicity
+	// Optional number of idempotency tokens of batches, set with
+	// IdempotencyTokenPayload, remembered by the proxy to answer the replays
+	// of applied batches with their response. Defaults to 0 (tokens are
+	// ignored).
+	BatchDedupCacheSize int
+	// Optional time the responses of the batches of idempotency tokens are
+	// replayed for. Defaults to 10m.
+	BatchDedupWindow time.Duration
+	// Optional cluster name, data center, rack, host id and release version
+	// reported in system.local and system.peers in place of those of
+	// Spanner. Defaults to nil.
+	TopologyOverride *adapter.TopologyOverride
+	// Optional number of virtual peers reported to the driver, each served by
+	// the local proxy, so that the driver opens NumConns connections per peer.
+	// At most 254. Defaults to 0.
+	SyntheticPeerCount int
+	// Optional labels (ie: end user or tenant id) forwarded as
+	// x-goog-spanner-label-<key> metadata headers with every request of the
+	// cluster. Keys must be lower case. Defaults to empty.
+	ConnectionLabels map[string]string
 	// Optional log level. Defaults to info.
 	LogLevel string
+	// Optional redaction applied to statements, bound values and rows before
+	// they are written to debug logs. Defaults to no redaction.
+	LogRedactionPolicy logger.RedactionPolicy
+	// Optional boolean to connect to Spanner over DirectPath when running on
+	// Google Cloud. Defaults to false.
+	EnableDirectAccess bool
 	// Optional google api opts. Default to empty.
 	GoogleApiOpts []option.ClientOption
 	// Optional boolean indicate whether to use plain-text connection.
@@ -64,67 +453,102 @@ type Options struct {
 	ClientCertificate string
 	// Optional string client key file path for establishing mTLS connection
 	ClientKey string
+	// Optional Secret Manager secrets the credentials, TLS certificates and
+	// statement policy are read from. Defaults to nil.
+	SecretRefs *adapter.SecretRefs
 }
 
-type ProxyAddressTranslator struct {
-	proxyIP   net.IP
-	proxyPort int
+// Interval at which the driver reconnects to the proxies of
+// Options.ProxyAddresses it marked down.
+const proxyReconnectInterval = 5 * time.Second
+
+// proxyDialer dials the local proxy of a cluster for every host, regardless
+// of the addresses of the hosts the driver learns from the system tables, or
+// the address of every host if addr is empty.
+type proxyDialer struct {
+	addr   string
+	dialer net.Dialer
 }
 
-func (t *ProxyAddressTranslator) Translate(ip net.IP, port int) (net.IP, int) {
-	// Redirect all connections to the proxy
-	return t.proxyIP, t.proxyPort
+func (d *proxyDialer) DialHost(
+	ctx context.Context,
+	host *gocql.HostInfo,
+) (*gocql.DialedHost, error) {
+	addr := d.addr
+	if addr == "" {
+		addr = net.JoinHostPort(
+			host.ConnectAddress().String(),
+			strconv.Itoa(host.Port()),
+		)
+	}
+	conn, err := d.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &gocql.DialedHost{Conn: conn}, nil
 }
 
-// NewCluster returns a new cluster for the CQL driver.
+// NewCluster returns a new cluster for the CQL driver. It panics if the local
+// proxy can not be started, use NewClusterWithError to handle the error
+// instead.
 func NewCluster(
 	opts *Options,
 ) *gocql.ClusterConfig {
-	// Initialize a global logger with default INFO log level
-	err := logger.SetupGlobalLogger(opts.LogLevel)
+	cfg, err := NewClusterWithError(opts)
 	if err != nil {
 		panic(
 			err,
 		)
 	}
-	if opts.ExperimentalHost && !strings.Contains(opts.DatabaseUri, "/") {
-		opts.DatabaseUri = "projects/default/instances/default/databases/" + opts.DatabaseUri
+	return cfg
+}
+
+// NewClusterWithError returns a new cluster for the CQL driver, or an error if
+// the local proxy can not be started.
+func NewClusterWithError(
+	opts *Options,
+) (*gocql.ClusterConfig, error) {
+	return NewClusterWithContext(context.Background(), opts)
+}
+
+// NewClusterWithContext returns a new cluster for the CQL driver, or an error
+// if the local proxy can not be started before ctx is done, ie: to bound the
+// time spent resolving the credentials and reaching Spanner. Canceling ctx
+// once the cluster is returned has no effect on its proxy.
+func NewClusterWithContext(
+	ctx context.Context,
+	opts *Options,
+) (*gocql.ClusterConfig, error) {
+	// Initialize a global logger with default INFO log level
+	err := logger.SetupGlobalLogger(opts.LogLevel)
+	if err != nil {
+		return nil, err
 	}
+	logger.SetRedactionPolicy(opts.LogRedactionPolicy)
+	normalizeDatabaseUri(opts)
 	// Create a new local Cassandra proxy.
-	proxy, err := adapter.NewTCPProxy(
-		adapter.Options{
-			DatabaseUri:              opts.DatabaseUri,
-			SpannerEndpoint:          opts.SpannerEndpoint,
-			TCPEndpoint:              opts.TCPEndpoint,
-			Protocol:                 &cassandraProtocol{},
-			NumGrpcChannels:          opts.NumGrpcChannels,
-			DisableAdaptMessageRetry: opts.DisableAdaptMessageRetry,
-			MaxCommitDelay:           opts.MaxCommitDelay,
-			GoogleApiOpts:            opts.GoogleApiOpts,
-			UsePlainText:             opts.UsePlainText,
-			ExperimentalHost:         opts.ExperimentalHost,
-			CaCertificate:            opts.CaCertificate,
-			ClientCertificate:        opts.ClientCertificate,
-			ClientKey:                opts.ClientKey,
-		},
-	)
+	proxy, err := adapter.NewTCPProxyWithContext(ctx, adapterOptions(opts))
 	if err != nil {
-		panic(
-			err,
-		)
+		return nil, err
 	}
 
-	// Point the driver to this local proxy.
-	//
-	// TODO: Passing proxy.Addr().String() to NewCluster does not work, the port
-	// has to be specified explicitly. This is likely because the driver uses data
-	// returned from the peers query to determine the address to connect to. We
-	// should probably find a better scheme for this.
+	// Point the driver to this local proxy. The hosts only identify the
+	// cluster: connections to any host, including the peers returned by the
+	// system tables, are dialed to the proxy.
 	addr := proxy.Addr().(*net.TCPAddr)
 	cfg := gocql.NewCluster(
 		addr.IP.String(),
 	)
 	cfg.Port = addr.Port
+	cfg.HostDialer = &proxyDialer{addr: addr.String()}
+	if len(opts.ProxyAddresses) > 0 {
+		// Every proxy is a host of its own, dialed at its address. The peers
+		// reported by the proxies are ignored.
+		cfg.Hosts = append([]string{addr.String()}, opts.ProxyAddresses...)
+		cfg.HostDialer = &proxyDialer{}
+		cfg.DisableInitialHostLookup = true
+		cfg.ReconnectInterval = proxyReconnectInterval
+	}
 	cfg.ProtoVersion = 4
 	cfg.WriteCoalesceWaitTime = 0
 	// Use a non token aware routing policy by default
@@ -134,27 +558,400 @@ func NewCluster(
 	cfg.ConnectTimeout = 60 * time.Second
 
 	// Record the mapping between the cluster and the proxy.
+	proxyMu.Lock()
 	proxyMap[cfg] = proxy
+	proxyMu.Unlock()
 
-	return cfg
+	return cfg, nil
+}
+
+// Check verifies that a cluster could be created with opts and serve the
+// given tables (ie: keyspace.table), without starting the local proxy. See
+// adapter.Check for the steps of the check.
+func Check(
+	ctx context.Context,
+	opts *Options,
+	tables []string,
+) (adapter.CheckReport, error) {
+	err := logger.SetupGlobalLogger(opts.LogLevel)
+	if err != nil {
+		return adapter.CheckReport{}, err
+	}
+	normalizeDatabaseUri(opts)
+	return adapter.Check(ctx, adapterOptions(opts), tables), nil
+}
+
+// ValidateSchema reports the columns of the tables of keyspace that can not be
+// used through CQL because they are missing a cassandra_type option, or because
+// their cassandra_type can not be stored in their Spanner type. keyspace must
+// be the database of databaseUri. opts configure the Spanner client used to
+// read INFORMATION_SCHEMA.
+func ValidateSchema(
+	ctx context.Context,
+	databaseUri string,
+	keyspace string,
+	opts ...option.ClientOption,
+) (adapter.SchemaReport, error) {
+	return adapter.ValidateSchema(
+		ctx,
+		adapter.Options{DatabaseUri: databaseUri, GoogleApiOpts: opts},
+		keyspace,
+	)
+}
+
+// normalizeDatabaseUri expands a bare database name of an experimental host to
+// a full database URI.
+func normalizeDatabaseUri(opts *Options) {
+	if opts.ExperimentalHost && !strings.Contains(opts.DatabaseUri, "/") {
+		opts.DatabaseUri = "projects/default/instances/default/databases/" + opts.DatabaseUri
+	}
+}
+
+// adapterOptions converts opts to the options of the local proxy.
+func adapterOptions(opts *Options) adapter.Options {
+	return adapter.Options{
+		DatabaseUri:                opts.DatabaseUri,
+		SpannerEndpoint:            opts.SpannerEndpoint,
+		FailoverEndpoints:          opts.FailoverEndpoints,
+		CandidateEndpoints:         opts.CandidateEndpoints,
+		TableRouting:               opts.TableRouting,
+		Listeners:                  opts.Listeners,
+		TCPEndpoint:                opts.TCPEndpoint,
+		TCPPortRange:               opts.TCPPortRange,
+		FallbackToEphemeralPort:    opts.FallbackToEphemeralPort,
+		ListenNetwork:              opts.ListenNetwork,
+		ListenInterface:            opts.ListenInterface,
+		ReusePort:                  opts.ReusePort,
+		Listener:                   opts.Listener,
+		ListenerShards:             opts.ListenerShards,
+		Protocol:                   &cassandraProtocol{},
+		NumGrpcChannels:            opts.NumGrpcChannels,
+		MinGrpcChannels:            opts.MinGrpcChannels,
+		MaxGrpcChannels:            opts.MaxGrpcChannels,
+		ReadChannels:               opts.ReadChannels,
+		WriteChannels:              opts.WriteChannels,
+		ReadEndpoint:               opts.ReadEndpoint,
+		WriteEndpoint:              opts.WriteEndpoint,
+		UnhealthyChannelThreshold:  opts.UnhealthyChannelThreshold,
+		ChannelAffinity:            opts.ChannelAffinity,
+		GrpcKeepaliveTime:          opts.GrpcKeepaliveTime,
+		GrpcKeepaliveTimeout:       opts.GrpcKeepaliveTimeout,
+		GrpcMinConnectTimeout:      opts.GrpcMinConnectTimeout,
+		GrpcDialNetwork:            opts.GrpcDialNetwork,
+		GrpcCompression:            opts.GrpcCompression,
+		MaxTransactionRetries:      opts.MaxTransactionRetries,
+		MaxSessionFailures:         opts.MaxSessionFailures,
+		MaxConnections:             opts.MaxConnections,
+		HandshakeTimeout:           opts.HandshakeTimeout,
+		AcceptRate:                 opts.AcceptRate,
+		AcceptBurst:                opts.AcceptBurst,
+		MaxConcurrentHandshakes:    opts.MaxConcurrentHandshakes,
+		AcceptProxyProtocol:        opts.AcceptProxyProtocol,
+		MaxFrameSize:               opts.MaxFrameSize,
+		MaxRequestSize:             opts.MaxRequestSize,
+		MaxResponseSize:            opts.MaxResponseSize,
+		OnDrain:                    opts.OnDrain,
+		DrainTimeout:               opts.DrainTimeout,
+		ConnectionReportInterval:   opts.ConnectionReportInterval,
+		StuckConnectionThreshold:   opts.StuckConnectionThreshold,
+		PayloadAdvisorInterval:     opts.PayloadAdvisorInterval,
+		AdaptiveConcurrency:        opts.AdaptiveConcurrency,
+		MaxConcurrencyLimit:        opts.MaxConcurrencyLimit,
+		MaxOverloadErrorDelay:      opts.MaxOverloadErrorDelay,
+		DisableAdaptMessageRetry:   opts.DisableAdaptMessageRetry,
+		RetryBackoff:               opts.RetryBackoff,
+		RetryJitter:                opts.RetryJitter,
+		RetryBudgetRatio:           opts.RetryBudgetRatio,
+		RetryBudgetBurst:           opts.RetryBudgetBurst,
+		PreparedCacheSize:          opts.PreparedCacheSize,
+		StateStore:                 opts.StateStore,
+		StateRedisAddress:          opts.StateRedisAddress,
+		StateRedisPassword:         opts.StateRedisPassword,
+		DisablePreparedResultCache: opts.DisablePreparedResultCache,
+		DisableCollectionOrdering:  opts.DisableCollectionOrdering,
+		DisableBindValidation:      opts.DisableBindValidation,
+		EnableBatchReprepare:       opts.EnableBatchReprepare,
+		StrictConsistency:          opts.StrictConsistency,
+		StrictWriteTimestamps:      opts.StrictWriteTimestamps,
+		WeakConsistencyStaleness:   opts.WeakConsistencyStaleness,
+		ReadYourWrites:             opts.ReadYourWrites,
+		EnableLatencyPayload:       opts.EnableLatencyPayload,
+		EnableRowCountPayload:      opts.EnableRowCountPayload,
+		EnableBuiltInMetrics:       opts.EnableBuiltInMetrics,
+		EnableStatementMetrics:     opts.EnableStatementMetrics,
+		MaxStatementFingerprints:   opts.MaxStatementFingerprints,
+		MaxCommitDelay:             opts.MaxCommitDelay,
+		EnablePartitionedDMLFor:    opts.EnablePartitionedDMLFor,
+		EnableMutationsFor:         opts.EnableMutationsFor,
+		StatementPolicy:            opts.StatementPolicy,
+		SupportedAttachments:       opts.SupportedAttachments,
+		AttachmentProvider:         opts.AttachmentProvider,
+		FullScanPolicy:             opts.FullScanPolicy,
+		DirectedReadOptions:        opts.DirectedReadOptions,
+		ReadCacheTTLs:              opts.ReadCacheTTLs,
+		ReadCacheSize:              opts.ReadCacheSize,
+		TTLColumns:                 opts.TTLColumns,
+		JSONColumns:                opts.JSONColumns,
+		UserTypes:                  opts.UserTypes,
+		ColumnCodecs:               opts.ColumnCodecs,
+		CaptureFile:                opts.CaptureFile,
+		CaptureSampleRate:          opts.CaptureSampleRate,
+		EmulateFunctions:           opts.EmulateFunctions,
+		EnableChangeStreams:        opts.EnableChangeStreams,
+		ChangeStreamReadTimeout:    opts.ChangeStreamReadTimeout,
+		WriteTimeColumns:           opts.WriteTimeColumns,
+		CqlshCompatibility:         opts.CqlshCompatibility,
+		UnpagedReadPageSize:        opts.UnpagedReadPageSize,
+		MaxBatchStatements:         opts.MaxBatchStatements,
+		BatchSplitParallelism:      opts.BatchSplitParallelism,
+		LoggedBatchAtomicity:       opts.LoggedBatchAtomicity,
+		UnloggedBatchAtomicity:     opts.UnloggedBatchAtomicity,
+		BatchDedupCacheSize:        opts.BatchDedupCacheSize,
+		BatchDedupWindow:           opts.BatchDedupWindow,
+		TopologyOverride:           opts.TopologyOverride,
+		SyntheticPeerCount:         opts.SyntheticPeerCount,
+		ConnectionLabels:           opts.ConnectionLabels,
+		EnableDirectAccess:         opts.EnableDirectAccess,
+		GoogleApiOpts:              opts.GoogleApiOpts,
+		UsePlainText:               opts.UsePlainText,
+		ExperimentalHost:           opts.ExperimentalHost,
+		CaCertificate:              opts.CaCertificate,
+		ClientCertificate:          opts.ClientCertificate,
+		ClientKey:                  opts.ClientKey,
+		SecretRefs:                 opts.SecretRefs,
+	}
+}
+
+// ProxyAddr returns the address the local proxy of the given cluster listens
+// on, which may differ from Options.TCPEndpoint if a fallback port was used.
+func ProxyAddr(cfg *gocql.ClusterConfig) (net.Addr, bool) {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return nil, false
+	}
+	return proxy.Addr(), true
+}
+
+// ClusterStats returns the request counters of the local proxy of the given
+// cluster, ie: requests by opcode, bytes in and out, retries and active
+// connections.
+func ClusterStats(cfg *gocql.ClusterConfig) (adapter.Stats, bool) {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return adapter.Stats{}, false
+	}
+	return proxy.Stats(), true
+}
+
+// ConnectionReport returns the connections of the local proxy of the given
+// cluster waiting on Spanner or on their driver for longer than threshold, or
+// StuckConnectionThreshold if threshold is 0, along with the stacks of all
+// goroutines if stacks is set, ie: to diagnose connection leaks.
+func ConnectionReport(
+	cfg *gocql.ClusterConfig,
+	threshold time.Duration,
+	stacks bool,
+) (adapter.ConnectionReport, bool) {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return adapter.ConnectionReport{}, false
+	}
+	return proxy.ConnectionReport(threshold, stacks), true
 }
 
-// CloseCluster closes the local proxy for the given cluster.
+// TopStatements returns the metrics of the n statement fingerprints of the
+// local proxy of the given cluster with the highest total latency, or of all
+// fingerprints if n <= 0, with Options.EnableStatementMetrics.
+func TopStatements(cfg *gocql.ClusterConfig, n int) ([]adapter.StatementStats, bool) {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return nil, false
+	}
+	return proxy.TopStatements(n), true
+}
+
+// EffectiveOptions returns the options the local proxy of the given cluster
+// runs with, with the defaults of unset options and the environment variables
+// (ie: GOOGLE_SPANNER_ENABLE_DIRECT_ACCESS) applied, ie: to log them at
+// startup or to compare the configuration of deployments.
+func EffectiveOptions(cfg *gocql.ClusterConfig) (adapter.Options, bool) {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return adapter.Options{}, false
+	}
+	return proxy.EffectiveOptions(), true
+}
+
+// InvalidateReadCache drops the cached responses of a table of
+// Options.ReadCacheTTLs of the local proxy of the given cluster, or of all
+// tables if table is empty, ie: after the table was written by another
+// client. Returns false if the table is not cached.
+func InvalidateReadCache(cfg *gocql.ClusterConfig, table string) bool {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return false
+	}
+	return proxy.InvalidateReadCache(table)
+}
+
+// RegisterPreparedStatements prepares statements on the local proxy of the
+// given cluster, as connections to cfg.Keyspace would, and pins them in its
+// cache, exempt from eviction. Drivers preparing these statements are then
+// answered by the proxy without a round trip to Spanner, and never receive
+// Unprepared errors for them. Call it after NewCluster, before the first
+// session of the cluster is created.
+func RegisterPreparedStatements(cfg *gocql.ClusterConfig, statements []string) error {
+	return registerStatements(cfg, statements, (*adapter.TCPProxy).RegisterPreparedStatements)
+}
+
+// RegisterIdempotentStatements registers statements like
+// RegisterPreparedStatements, and declares them idempotent: the local proxy
+// retries them on any retryable Spanner error, like reads. Other writes are
+// only retried when the error guarantees that they were not executed, so that
+// they are never applied twice.
+func RegisterIdempotentStatements(cfg *gocql.ClusterConfig, statements []string) error {
+	return registerStatements(cfg, statements, (*adapter.TCPProxy).RegisterIdempotentStatements)
+}
+
+func registerStatements(
+	cfg *gocql.ClusterConfig,
+	statements []string,
+	register func(*adapter.TCPProxy, context.Context, string, []string) error,
+) error {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return errors.New("cluster was not created by NewCluster")
+	}
+	ctx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(len(statements)+1)*cfg.Timeout)
+		defer cancel()
+	}
+	return register(proxy, ctx, cfg.Keyspace, statements)
+}
+
+// AsSpannerError returns the Spanner failure carried by an error returned by
+// the CQL driver, if any.
+func AsSpannerError(err error) (*adapter.SpannerError, bool) {
+	var reqErr gocql.RequestError
+	if !errors.As(err, &reqErr) {
+		return nil, false
+	}
+	return adapter.ParseSpannerError(reqErr.Message())
+}
+
+// ReadTimestampPayload returns a custom payload that makes a read-only query
+// read data as of the given timestamp. Set it with gocql.Query.CustomPayload.
+func ReadTimestampPayload(t time.Time) map[string][]byte {
+	return map[string][]byte{
+		adapter.ReadTimestampPayloadKey: []byte(t.UTC().Format(time.RFC3339Nano)),
+	}
+}
+
+// ExactStalenessPayload returns a custom payload that makes a read-only query
+// read data that is exactly the given duration old. Set it with
+// gocql.Query.CustomPayload.
+func ExactStalenessPayload(d time.Duration) map[string][]byte {
+	return map[string][]byte{
+		adapter.ExactStalenessPayloadKey: []byte(d.String()),
+	}
+}
+
+// PartitionedDMLPayload returns a custom payload that makes an UPDATE or
+// DELETE statement execute as Partitioned DML. Set it with
+// gocql.Query.CustomPayload.
+func PartitionedDMLPayload() map[string][]byte {
+	return map[string][]byte{adapter.PartitionedDMLPayloadKey: []byte("true")}
+}
+
+// MutationPayload returns a custom payload that makes a simple INSERT
+// statement, or a batch of them, apply as Spanner mutations instead of DML.
+// Set it with gocql.Query.CustomPayload or gocql.Batch.CustomPayload.
+func MutationPayload() map[string][]byte {
+	return map[string][]byte{adapter.MutationPayloadKey: []byte("true")}
+}
+
+// IdempotencyTokenPayload returns a custom payload carrying the idempotency
+// token of a batch, ie: a payment id, with Options.BatchDedupCacheSize.
+// Replays of the batch with the same token, ie: after a timeout, are answered
+// with the response of the applied batch rather than applied again. Set it
+// with gocql.Batch.CustomPayload.
+func IdempotencyTokenPayload(token string) map[string][]byte {
+	return map[string][]byte{adapter.IdempotencyTokenPayloadKey: []byte(token)}
+}
+
+// ResponseLatencies returns the time a statement spent in the proxy, from
+// reading the request to writing the response, and in Spanner, from the
+// custom payload of its response (ie: gocql.Iter.GetCustomPayload()) with
+// Options.EnableLatencyPayload. The backend latency is zero if Spanner did not
+// report it.
+func ResponseLatencies(
+	payload map[string][]byte,
+) (request time.Duration, backend time.Duration, ok bool) {
+	request, err := time.ParseDuration(
+		string(payload[adapter.RequestLatencyPayloadKey]),
+	)
+	if err != nil {
+		return 0, 0, false
+	}
+	if value, found := payload[adapter.BackendLatencyPayloadKey]; found {
+		backend, _ = time.ParseDuration(string(value))
+	}
+	return request, backend, true
+}
+
+// AffectedRows returns the number of rows affected by a DML statement or batch
+// from the custom payload of its response (ie: gocql.Iter.GetCustomPayload())
+// with Options.EnableRowCountPayload. Returns false if Spanner did not report
+// it.
+func AffectedRows(payload map[string][]byte) (int64, bool) {
+	value, found := payload[adapter.RowCountPayloadKey]
+	if !found {
+		return 0, false
+	}
+	rows, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return rows, true
+}
+
+// CloseCluster closes the local proxy for the given cluster, along with its
+// open connections, whose outstanding requests are canceled.
 func CloseCluster(
 	cfg *gocql.ClusterConfig,
 ) {
-	if proxy, ok := proxyMap[cfg]; ok {
+	proxyMu.Lock()
+	proxy, ok := proxyMap[cfg]
+	delete(proxyMap, cfg)
+	proxyMu.Unlock()
+	if ok {
 		proxy.Close()
-		delete(
-			proxyMap,
-			cfg,
-		)
 	}
 }
 
+// ShutdownCluster stops the local proxy of the given cluster accepting
+// connections, waits for its open connections to close until ctx is done, and
+// closes it, ie: to hand its endpoint over to a new proxy process during an in
+// place upgrade. Connections still open once ctx is done are closed.
+func ShutdownCluster(ctx context.Context, cfg *gocql.ClusterConfig) error {
+	proxy, ok := lookupProxy(cfg)
+	if !ok {
+		return nil
+	}
+	err := proxy.Shutdown(ctx)
+	CloseCluster(cfg)
+	return err
+}
+
 type cassandraProtocol struct {
 }
 
+func init() {
+	adapter.RegisterProtocol(&cassandraProtocol{})
+}
+
 func (ca *cassandraProtocol) Name() string {
 	return "cassandra"
 }
@@ -163,17 +960,30 @@ func (ca *cassandraProtocol) FrameHeaderLength() int {
 	return 9
 }
 
+// FrameBodyLength returns the body length carried by a frame header, or -1 if
+// the header is truncated or the length is negative.
 func (ca *cassandraProtocol) FrameBodyLength(header []byte) int {
-	return int(binary.BigEndian.Uint32(header[5:9]))
+	if len(header) < 9 {
+		return -1
+	}
+	length := int32(binary.BigEndian.Uint32(header[5:9]))
+	if length < 0 {
+		return -1
+	}
+	return int(length)
 }
 
+// ExtractKeys returns the prepared query id of an EXECUTE frame, or nil if the
+// payload is not a well formed EXECUTE frame.
 func (ca *cassandraProtocol) ExtractKeys(payload []byte) []string {
-	// TODO: Bounds check.
-	if payload[4] != 0x0A {
+	if len(payload) < 11 || payload[4] != 0x0A {
 		return nil
 	}
 
 	idLen := int(binary.BigEndian.Uint16(payload[9:11]))
+	if len(payload) < 11+idLen {
+		return nil
+	}
 	id := string(payload[11 : 11+idLen])
 
 	return []string{id}
@@ -20,15 +20,21 @@ limitations under the License.
 package spanner
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/googleapis/go-spanner-cassandra/adapter"
 
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/gocql/gocql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
 )
 
 // sample command to run all unit tests
@@ -93,6 +99,16 @@ func TestNewCluster(t *testing.T) {
 			addr := proxy.Addr().(*net.TCPAddr)
 			assert.Equal(t, cluster.Hosts, []string{addr.IP.String()})
 			assert.Equal(t, cluster.Port, addr.Port)
+
+			// Connections to any host are dialed to the proxy.
+			require.NotNil(t, cluster.HostDialer)
+			dialed, err := cluster.HostDialer.DialHost(
+				context.Background(),
+				&gocql.HostInfo{},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, addr.String(), dialed.Conn.RemoteAddr().String())
+			dialed.Conn.Close()
 			teardownCluster(t, cluster)
 		})
 	}
@@ -131,6 +147,59 @@ func TestSelectQuery(t *testing.T) {
 		})
 	}
 }
+func TestProxyInfoTable(t *testing.T) {
+	cluster, session := setupCluster(t, false)
+	defer teardownCluster(t, cluster)
+
+	var version, protocol, features string
+	var connectionID int
+	err := session.Query(
+		"SELECT version, protocol, features, connection_id FROM system.spanner_proxy_info",
+	).Scan(&version, &protocol, &features, &connectionID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, version)
+	assert.Equal(t, "cassandra", protocol)
+	assert.Contains(t, features, "max_commit_delay")
+
+	err = session.Query("SELECT nope FROM system.spanner_proxy_info").Exec()
+	assert.Error(t, err)
+}
+
+func TestQueryTracing(t *testing.T) {
+	cluster, session := setupCluster(t, false)
+	defer teardownCluster(t, cluster)
+
+	var trace bytes.Buffer
+	var key, val string
+	err := session.Query("SELECT key,val FROM demo.keyval WHERE key = ?", "test_key").
+		Trace(gocql.NewTraceWriter(session, &trace)).
+		Scan(&key, &val)
+	require.NoError(t, err)
+	assert.Equal(t, "test_val", val)
+	assert.Contains(t, trace.String(), "Sent AdaptMessage request to Spanner")
+	assert.Contains(t, trace.String(), "Writing response to driver")
+}
+
+func TestStageLatencies(t *testing.T) {
+	cluster, session := setupCluster(t, false)
+	defer teardownCluster(t, cluster)
+
+	var key, val string
+	err := session.Query("SELECT key,val FROM demo.keyval WHERE key = ?", "test_key").
+		Scan(&key, &val)
+	require.NoError(t, err)
+
+	stats, ok := ClusterStats(cluster)
+	require.True(t, ok)
+	stages := stats.StageLatencies[primitive.OpCodeExecute.String()]
+	for _, stage := range []string{
+		"tcp_read", "decode", "attachments", "grpc_send",
+		"first_response", "last_response", "tcp_write",
+	} {
+		assert.NotZero(t, stages[stage].Count, stage)
+	}
+}
+
 func TestDML(t *testing.T) {
 	t.Cleanup(adapter.ResetGrpcFuncs())
 	testCases := []struct {
@@ -317,3 +386,142 @@ func TestNewClusterPanicsOnInvalidLogLevel(t *testing.T) {
 		})
 	}
 }
+
+func TestNewClusterWithError(t *testing.T) {
+	t.Cleanup(adapter.ResetGrpcFuncs())
+	adapter.MockCreateSessionGrpc()
+
+	_, err := NewClusterWithError(&Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		LogLevel:      "invalid",
+		GoogleApiOpts: adapter.SkipAuthOpts,
+	})
+	assert.Error(t, err)
+
+	cluster, err := NewClusterWithError(&Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		GoogleApiOpts: adapter.SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	addr, ok := ProxyAddr(cluster)
+	require.True(t, ok)
+	assert.Equal(t, cluster.Port, addr.(*net.TCPAddr).Port)
+	stats, ok := ClusterStats(cluster)
+	require.True(t, ok)
+	assert.Equal(t, int64(0), stats.ActiveConnections)
+	teardownCluster(t, cluster)
+
+	_, ok = ProxyAddr(cluster)
+	assert.False(t, ok)
+	_, ok = ClusterStats(cluster)
+	assert.False(t, ok)
+}
+
+type fakeRequestError struct {
+	message string
+}
+
+func (e *fakeRequestError) Code() int       { return 0 }
+func (e *fakeRequestError) Message() string { return e.message }
+func (e *fakeRequestError) Error() string   { return e.message }
+
+func TestAsSpannerError(t *testing.T) {
+	_, ok := AsSpannerError(errors.New("not a request error"))
+	assert.False(t, ok)
+
+	_, ok = AsSpannerError(&fakeRequestError{message: "plain server error"})
+	assert.False(t, ok)
+
+	cqlMessage := `spanner: code = "NotFound", desc = "not found" ` +
+		`spanner_error={"code":5,"message":"not found","retryable":false}`
+	spannerErr, ok := AsSpannerError(
+		fmt.Errorf("wrapped: %w", &fakeRequestError{message: cqlMessage}),
+	)
+	require.True(t, ok)
+	assert.Equal(t, codes.NotFound, spannerErr.Code)
+	assert.Equal(t, "not found", spannerErr.Message)
+	assert.False(t, spannerErr.Retryable)
+}
+
+func TestResponseLatencies(t *testing.T) {
+	_, _, ok := ResponseLatencies(nil)
+	assert.False(t, ok)
+
+	request, backend, ok := ResponseLatencies(map[string][]byte{
+		adapter.RequestLatencyPayloadKey: []byte("15ms"),
+		adapter.BackendLatencyPayloadKey: []byte("12.5ms"),
+	})
+	require.True(t, ok)
+	assert.Equal(t, 15*time.Millisecond, request)
+	assert.Equal(t, 12500*time.Microsecond, backend)
+
+	request, backend, ok = ResponseLatencies(map[string][]byte{
+		adapter.RequestLatencyPayloadKey: []byte("2ms"),
+	})
+	require.True(t, ok)
+	assert.Equal(t, 2*time.Millisecond, request)
+	assert.Zero(t, backend)
+}
+
+func TestAffectedRows(t *testing.T) {
+	_, ok := AffectedRows(nil)
+	assert.False(t, ok)
+	_, ok = AffectedRows(map[string][]byte{adapter.RowCountPayloadKey: []byte("x")})
+	assert.False(t, ok)
+
+	rows, ok := AffectedRows(map[string][]byte{adapter.RowCountPayloadKey: []byte("3")})
+	require.True(t, ok)
+	assert.Equal(t, int64(3), rows)
+}
+
+func FuzzExtractKeys(f *testing.F) {
+	f.Add([]byte{0x04, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x00, 0x04, 0x00, 0x02, 'i', 'd'})
+	f.Add([]byte{0x04, 0x00, 0x00, 0x01, 0x0a, 0x00, 0x00, 0x00, 0x04, 0xff, 0xff})
+	f.Add([]byte{0x04, 0x00, 0x00, 0x01, 0x0a})
+	protocol := &cassandraProtocol{}
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		keys := protocol.ExtractKeys(payload)
+		for _, key := range keys {
+			assert.LessOrEqual(t, len(key), len(payload))
+		}
+		if len(payload) >= protocol.FrameHeaderLength() {
+			assert.GreaterOrEqual(t, protocol.FrameBodyLength(payload), -1)
+		}
+	})
+}
+
+func TestNewClusterWithProxyAddresses(t *testing.T) {
+	t.Cleanup(adapter.ResetGrpcFuncs())
+	adapter.MockCreateSessionGrpc()
+	adapter.MockAdaptMessageGrpc(false)
+	other, err := adapter.NewTCPProxy(adapter.Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		TCPEndpoint:   "127.0.0.2:0",
+		Protocol:      &cassandraProtocol{},
+		GoogleApiOpts: adapter.SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	defer other.Close()
+
+	cluster := NewCluster(&Options{
+		DatabaseUri:    "projects/test/instances/test/databases/test",
+		TCPEndpoint:    "127.0.0.1:0",
+		ProxyAddresses: []string{other.Addr().String()},
+		GoogleApiOpts:  adapter.SkipAuthOpts,
+	})
+	defer teardownCluster(t, cluster)
+	local := proxyMap[cluster].Addr().String()
+	assert.Equal(t, []string{local, other.Addr().String()}, cluster.Hosts)
+	assert.True(t, cluster.DisableInitialHostLookup)
+
+	// Both proxies serve the session.
+	session, err := cluster.CreateSession()
+	require.NoError(t, err)
+	defer session.Close()
+	var key, val string
+	require.NoError(t, session.Query(
+		"SELECT key,val FROM demo.keyval WHERE key = ?", "test_key",
+	).Scan(&key, &val))
+	assert.Positive(t, proxyMap[cluster].Stats().TotalConnections)
+	assert.Positive(t, other.Stats().TotalConnections)
+}
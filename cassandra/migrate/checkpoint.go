@@ -0,0 +1,153 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rangeState is the progress of the copy of one token range.
+type rangeState struct {
+	tokenRange
+	// First token that is not known to be copied yet. Rows of this token may
+	// already be copied, they are written again on resume.
+	Next int64 `json:"next"`
+	Done bool  `json:"done"`
+	// Number of rows copied.
+	Rows int64 `json:"rows"`
+}
+
+// checkpoint tracks the progress of the copy of a table, and persists it to a
+// file if path is set so that an interrupted copy can be resumed.
+type checkpoint struct {
+	mu   sync.Mutex
+	path string
+
+	Keyspace string       `json:"keyspace"`
+	Table    string       `json:"table"`
+	Ranges   []rangeState `json:"ranges"`
+}
+
+// loadCheckpoint returns the checkpoint stored at path, or a new checkpoint
+// for splits token ranges if there is none. path may be empty to not persist
+// the progress.
+func loadCheckpoint(
+	path string,
+	keyspace string,
+	table string,
+	splits int,
+) (*checkpoint, error) {
+	cp := &checkpoint{path: path, Keyspace: keyspace, Table: table}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(data, cp); err != nil {
+				return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+			}
+			if cp.Keyspace != keyspace || cp.Table != table {
+				return nil, fmt.Errorf(
+					"checkpoint %s is for table %s.%s, not %s.%s",
+					path,
+					cp.Keyspace,
+					cp.Table,
+					keyspace,
+					table,
+				)
+			}
+			return cp, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+	for _, r := range splitTokenRanges(splits) {
+		cp.Ranges = append(cp.Ranges, rangeState{tokenRange: r, Next: r.Start})
+	}
+	return cp, nil
+}
+
+// pending returns the indexes of the ranges that are not copied yet.
+func (cp *checkpoint) pending() []int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	var indexes []int
+	for i, r := range cp.Ranges {
+		if !r.Done {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// state returns the progress of range i.
+func (cp *checkpoint) state(i int) rangeState {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.Ranges[i]
+}
+
+// advance records that rows up to, and including, token next were copied in
+// range i.
+func (cp *checkpoint) advance(i int, next int64, rows int) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Ranges[i].Next = next
+	cp.Ranges[i].Rows += int64(rows)
+	return cp.save()
+}
+
+// complete records that range i was copied.
+func (cp *checkpoint) complete(i int, rows int) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Ranges[i].Done = true
+	cp.Ranges[i].Rows += int64(rows)
+	return cp.save()
+}
+
+// progress returns the number of copied ranges and rows.
+func (cp *checkpoint) progress() (ranges int, rows int64) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	for _, r := range cp.Ranges {
+		if r.Done {
+			ranges++
+		}
+		rows += r.Rows
+	}
+	return ranges, rows
+}
+
+// save atomically replaces the checkpoint file. Must be called with mu held.
+func (cp *checkpoint) save() error {
+	if cp.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := cp.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cp.path)
+}
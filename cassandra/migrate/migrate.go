@@ -0,0 +1,323 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package migrate copies tables from a Cassandra cluster to Spanner through
+// the Spanner Cassandra adapter.
+//
+// Tables are read with token range scans, so the source cluster must use the
+// Murmur3 partitioner, and written with batches of INSERT statements. Since
+// INSERTs overwrite existing rows, an interrupted copy can be resumed from a
+// checkpoint file without duplicating data.
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gocql/gocql"
+)
+
+const (
+	defaultSplits      = 256
+	defaultParallelism = 4
+	defaultBatchSize   = 100
+	defaultPageSize    = 1000
+)
+
+var unquotedIdentifierPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// Options configures the copy of a table.
+type Options struct {
+	// Session connected to the Cassandra cluster rows are read from.
+	Source *gocql.Session
+	// Session connected to Spanner through the adapter (ie: created from the
+	// cluster returned by spanner.NewCluster), with its keyspace set to the
+	// database.
+	Target *gocql.Session
+	// Keyspace of Table in the source cluster.
+	Keyspace string
+	// Table to copy.
+	Table string
+	// Optional number of token ranges the table is split into. Defaults to 256.
+	Splits int
+	// Optional number of token ranges copied concurrently. Defaults to 4.
+	Parallelism int
+	// Optional number of rows written per batch. Defaults to 100.
+	BatchSize int
+	// Optional number of rows read per page. Defaults to 1000.
+	PageSize int
+	// Optional maximum number of rows written per second. Defaults to 0
+	// (unlimited).
+	RowsPerSecond float64
+	// Optional file the progress is persisted to. If the file exists, the copy
+	// resumes where it stopped. Defaults to empty (no checkpointing).
+	CheckpointFile string
+	// Optional callback invoked after each copied token range.
+	OnProgress func(Progress)
+}
+
+// Progress reports how much of a table was copied.
+type Progress struct {
+	Table string
+	// Number of token ranges copied, out of Ranges.
+	RangesDone int
+	Ranges     int
+	// Number of rows copied, including rows written before a resume.
+	Rows int64
+}
+
+// CopyTable copies the rows of a table from the source cluster to Spanner.
+// The table must already exist in Spanner (see the schema package).
+func CopyTable(ctx context.Context, opts Options) (Progress, error) {
+	if opts.Splits <= 0 {
+		opts.Splits = defaultSplits
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = defaultParallelism
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.PageSize <= 0 {
+		opts.PageSize = defaultPageSize
+	}
+	keyspace, err := opts.Source.KeyspaceMetadata(opts.Keyspace)
+	if err != nil {
+		return Progress{}, err
+	}
+	table, ok := keyspace.Tables[opts.Table]
+	if !ok {
+		return Progress{}, fmt.Errorf(
+			"table %s.%s does not exist",
+			opts.Keyspace,
+			opts.Table,
+		)
+	}
+	cp, err := loadCheckpoint(
+		opts.CheckpointFile,
+		opts.Keyspace,
+		opts.Table,
+		opts.Splits,
+	)
+	if err != nil {
+		return Progress{}, err
+	}
+	c := &copier{
+		opts:       opts,
+		checkpoint: cp,
+		limiter:    newRateLimiter(opts.RowsPerSecond),
+	}
+	c.prepareStatements(table)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pending := make(chan int)
+	errs := make(chan error, opts.Parallelism)
+	for w := 0; w < opts.Parallelism; w++ {
+		go func() {
+			for i := range pending {
+				if err := c.copyRange(ctx, i); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+				c.reportProgress()
+			}
+			errs <- nil
+		}()
+	}
+	go func() {
+		defer close(pending)
+		for _, i := range cp.pending() {
+			select {
+			case pending <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var firstErr error
+	for w := 0; w < opts.Parallelism; w++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return c.progress(), firstErr
+}
+
+// copier copies the token ranges of a table.
+type copier struct {
+	opts       Options
+	checkpoint *checkpoint
+	limiter    *rateLimiter
+	// Statement reading the rows of a token range, preceded by their token.
+	selectStmt string
+	insertStmt string
+}
+
+// quoteIdentifier quotes a CQL identifier unless it is a plain lower case
+// identifier.
+func quoteIdentifier(name string) string {
+	if unquotedIdentifierPattern.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func (c *copier) prepareStatements(table *gocql.TableMetadata) {
+	partitionKey := make([]string, len(table.PartitionKey))
+	for i, column := range table.PartitionKey {
+		partitionKey[i] = quoteIdentifier(column.Name)
+	}
+	columns := make([]string, len(table.OrderedColumns))
+	markers := make([]string, len(table.OrderedColumns))
+	for i, name := range table.OrderedColumns {
+		columns[i] = quoteIdentifier(name)
+		markers[i] = "?"
+	}
+	token := fmt.Sprintf("token(%s)", strings.Join(partitionKey, ", "))
+	c.selectStmt = fmt.Sprintf(
+		"SELECT %s, %s FROM %s.%s WHERE %s >= ? AND %s <= ?",
+		token,
+		strings.Join(columns, ", "),
+		quoteIdentifier(c.opts.Keyspace),
+		quoteIdentifier(c.opts.Table),
+		token,
+		token,
+	)
+	c.insertStmt = fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdentifier(c.opts.Table),
+		strings.Join(columns, ", "),
+		strings.Join(markers, ", "),
+	)
+}
+
+// copyRange copies the rows of token range i that were not copied yet.
+func (c *copier) copyRange(ctx context.Context, i int) error {
+	state := c.checkpoint.state(i)
+	iter := c.opts.Source.Query(c.selectStmt, state.Next, state.End).
+		WithContext(ctx).
+		PageSize(c.opts.PageSize).
+		Iter()
+	dest, err := scanDestinations(iter.Columns())
+	if err != nil {
+		iter.Close()
+		return err
+	}
+	var batch [][]interface{}
+	var token int64
+	for {
+		resetDestinations(dest)
+		if !iter.Scan(dest...) {
+			break
+		}
+		values := make([]interface{}, len(dest))
+		for j := range dest {
+			values[j] = reflect.ValueOf(dest[j]).Elem().Interface()
+		}
+		if t := values[0].(*int64); t != nil {
+			token = *t
+		}
+		batch = append(batch, values[1:])
+		if len(batch) == c.opts.BatchSize {
+			if err := c.write(ctx, batch); err != nil {
+				iter.Close()
+				return err
+			}
+			if err := c.checkpoint.advance(i, token, len(batch)); err != nil {
+				iter.Close()
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return fmt.Errorf(
+			"failed to read token range [%d, %d]: %w",
+			state.Next,
+			state.End,
+			err,
+		)
+	}
+	if len(batch) > 0 {
+		if err := c.write(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return c.checkpoint.complete(i, len(batch))
+}
+
+// write inserts rows in a single unlogged batch.
+func (c *copier) write(ctx context.Context, rows [][]interface{}) error {
+	if err := c.limiter.wait(ctx, len(rows)); err != nil {
+		return err
+	}
+	batch := c.opts.Target.NewBatch(gocql.UnloggedBatch).WithContext(ctx)
+	for _, row := range rows {
+		batch.Query(c.insertStmt, row...)
+	}
+	if err := c.opts.Target.ExecuteBatch(batch); err != nil {
+		return fmt.Errorf("failed to write %d rows: %w", len(rows), err)
+	}
+	return nil
+}
+
+func (c *copier) progress() Progress {
+	ranges, rows := c.checkpoint.progress()
+	return Progress{
+		Table:      c.opts.Table,
+		RangesDone: ranges,
+		Ranges:     len(c.checkpoint.Ranges),
+		Rows:       rows,
+	}
+}
+
+func (c *copier) reportProgress() {
+	if c.opts.OnProgress != nil {
+		c.opts.OnProgress(c.progress())
+	}
+}
+
+// scanDestinations returns scan destinations for columns that distinguish
+// null values: each destination is a pointer to a pointer of the column type,
+// which is left nil for nulls.
+func scanDestinations(columns []gocql.ColumnInfo) ([]interface{}, error) {
+	dest := make([]interface{}, len(columns))
+	for i, column := range columns {
+		value, err := column.TypeInfo.NewWithError()
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column.Name, err)
+		}
+		dest[i] = reflect.New(reflect.TypeOf(value)).Interface()
+	}
+	return dest, nil
+}
+
+// resetDestinations clears the destinations so that scanning a row allocates
+// new values, instead of overwriting the values of the previous row.
+func resetDestinations(dest []interface{}) {
+	for _, d := range dest {
+		v := reflect.ValueOf(d).Elem()
+		v.Set(reflect.Zero(v.Type()))
+	}
+}
@@ -0,0 +1,126 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitTokenRanges(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 7, 256} {
+		ranges := splitTokenRanges(n)
+		require.Len(t, ranges, n)
+		assert.Equal(t, int64(math.MinInt64), ranges[0].Start)
+		assert.Equal(t, int64(math.MaxInt64), ranges[n-1].End)
+		for i := 1; i < n; i++ {
+			assert.Equal(t, ranges[i-1].End+1, ranges[i].Start)
+			assert.Less(t, ranges[i].Start, ranges[i].End)
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp, err := loadCheckpoint(path, "ks", "users", 4)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2, 3}, cp.pending())
+
+	require.NoError(t, cp.complete(1, 10))
+	require.NoError(t, cp.advance(2, 42, 5))
+
+	resumed, err := loadCheckpoint(path, "ks", "users", 4)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2, 3}, resumed.pending())
+	assert.Equal(t, int64(42), resumed.state(2).Next)
+	assert.Equal(t, cp.state(2).End, resumed.state(2).End)
+	ranges, rows := resumed.progress()
+	assert.Equal(t, 1, ranges)
+	assert.Equal(t, int64(15), rows)
+
+	_, err = loadCheckpoint(path, "ks", "orders", 4)
+	assert.Error(t, err)
+}
+
+func TestCheckpointWithoutFile(t *testing.T) {
+	cp, err := loadCheckpoint("", "ks", "users", 2)
+	require.NoError(t, err)
+	require.NoError(t, cp.complete(0, 3))
+	assert.Equal(t, []int{1}, cp.pending())
+}
+
+func TestRateLimiter(t *testing.T) {
+	assert.NoError(t, newRateLimiter(0).wait(context.Background(), 1000))
+
+	limiter := newRateLimiter(1000)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		require.NoError(t, limiter.wait(context.Background(), 25))
+	}
+	// The first batch is not delayed, the next two wait 25ms each.
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limiter.wait(ctx, 1000)
+	assert.Error(t, limiter.wait(ctx, 1))
+}
+
+func TestPrepareStatements(t *testing.T) {
+	c := &copier{opts: Options{Keyspace: "shop", Table: "Orders"}}
+	c.prepareStatements(&gocql.TableMetadata{
+		PartitionKey: []*gocql.ColumnMetadata{
+			{Name: "customer_id"},
+			{Name: "OrderId"},
+		},
+		OrderedColumns: []string{"customer_id", "OrderId", "total"},
+	})
+	assert.Equal(
+		t,
+		`SELECT token(customer_id, "OrderId"), customer_id, "OrderId", total `+
+			`FROM shop."Orders" `+
+			`WHERE token(customer_id, "OrderId") >= ? `+
+			`AND token(customer_id, "OrderId") <= ?`,
+		c.selectStmt,
+	)
+	assert.Equal(
+		t,
+		`INSERT INTO "Orders" (customer_id, "OrderId", total) VALUES (?, ?, ?)`,
+		c.insertStmt,
+	)
+}
+
+func TestScanDestinationsKeepNulls(t *testing.T) {
+	dest, err := scanDestinations([]gocql.ColumnInfo{
+		{Name: "id", TypeInfo: gocql.NewNativeType(4, gocql.TypeInt, "")},
+	})
+	require.NoError(t, err)
+	value := 7
+	*dest[0].(**int) = &value
+	resetDestinations(dest)
+	assert.Nil(t, *dest[0].(**int))
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrate
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenRange is an inclusive range of Murmur3 partitioner tokens.
+type tokenRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// splitTokenRanges splits the Murmur3 token ring into n consecutive ranges of
+// equal size.
+func splitTokenRanges(n int) []tokenRange {
+	if n < 1 {
+		n = 1
+	}
+	// Tokens are computed as unsigned offsets from math.MinInt64, the last
+	// range absorbs the remainder of the division.
+	width := math.MaxUint64 / uint64(n)
+	ranges := make([]tokenRange, n)
+	for i := range ranges {
+		ranges[i].Start = int64(uint64(i)*width + 1<<63)
+		ranges[i].End = int64(uint64(i+1)*width + 1<<63 - 1)
+	}
+	ranges[n-1].End = math.MaxInt64
+	return ranges
+}
+
+// rateLimiter spaces out writes to a maximum number of rows per second. A nil
+// rateLimiter does not limit.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(rowsPerSecond float64) *rateLimiter {
+	if rowsPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		interval: time.Duration(float64(time.Second) / rowsPerSecond),
+	}
+}
+
+// wait blocks until rows can be written without exceeding the rate.
+func (l *rateLimiter) wait(ctx context.Context, rows int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(rows) * l.interval)
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
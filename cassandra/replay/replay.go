@@ -0,0 +1,217 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package replay replays the requests recorded by the Spanner Cassandra
+// adapter to its capture file (see adapter.Options.CaptureFile) against
+// another database, ie: to plan the capacity of a Spanner database before
+// cutting traffic over to it.
+//
+// Requests are replayed at the pace they were captured, or at a multiple of
+// it, with their original statements, bound values and consistency levels.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+)
+
+const (
+	defaultSpeed       = 1
+	defaultConcurrency = 64
+	// Maximum size of a captured record.
+	maxRecordSize = 256 << 20
+)
+
+// Options configures a replay.
+type Options struct {
+	// Session the requests are replayed with, ie: connected to Spanner through
+	// the adapter.
+	Session *gocql.Session
+	// Captured requests, ie: an opened capture file.
+	Records io.Reader
+	// Optional pace of the replay relative to the captured traffic, ie: 2 to
+	// replay twice as fast. Defaults to 1 (original pace).
+	Speed float64
+	// Optional boolean to replay the requests as fast as Concurrency allows,
+	// regardless of their capture times. Defaults to false.
+	Unpaced bool
+	// Optional maximum number of requests replayed concurrently. Requests
+	// wait for a slot, and fall behind their pace, once it is reached.
+	// Defaults to 64.
+	Concurrency int
+	// Optional callback invoked with each request that failed.
+	OnError func(rec *adapter.CaptureRecord, err error)
+}
+
+// Result reports the outcome of a replay.
+type Result struct {
+	// Number of requests replayed, including the failed ones.
+	Requests int64
+	// Number of requests that failed.
+	Errors int64
+	// Duration of the replay.
+	Elapsed time.Duration
+}
+
+// rawValue is a value bound in the encoding of the CQL type of its bind
+// marker, as captured.
+type rawValue []byte
+
+func (v rawValue) MarshalCQL(gocql.TypeInfo) ([]byte, error) {
+	return v, nil
+}
+
+// Replay replays the requests of opts.Records in order, until they are all
+// replayed or ctx is done. Failed requests are counted and reported to
+// opts.OnError, while malformed records stop the replay.
+func Replay(ctx context.Context, opts Options) (result Result, err error) {
+	if opts.Session == nil || opts.Records == nil {
+		return Result{}, errors.New("replay requires a session and records")
+	}
+	if opts.Speed <= 0 {
+		opts.Speed = defaultSpeed
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+
+	var requests, failures atomic.Int64
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, opts.Concurrency)
+	start := time.Now()
+	defer func() {
+		wg.Wait()
+		result.Requests = requests.Load()
+		result.Errors = failures.Load()
+		result.Elapsed = time.Since(start)
+	}()
+
+	scanner := bufio.NewScanner(opts.Records)
+	scanner.Buffer(nil, maxRecordSize)
+	var first time.Time
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := &adapter.CaptureRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), rec); err != nil {
+			return result, fmt.Errorf("malformed record on line %d: %w", line, err)
+		}
+		if len(rec.Statements) == 0 {
+			return result, fmt.Errorf("malformed record on line %d: no statement", line)
+		}
+		if first.IsZero() {
+			first = rec.Time
+		}
+		if !opts.Unpaced {
+			offset := time.Duration(float64(rec.Time.Sub(first)) / opts.Speed)
+			if err := sleepUntil(ctx, start.Add(offset)); err != nil {
+				return result, err
+			}
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			requests.Add(1)
+			if err := execute(ctx, opts.Session, rec); err != nil {
+				failures.Add(1)
+				if opts.OnError != nil {
+					opts.OnError(rec, err)
+				}
+			}
+		}()
+	}
+	return result, scanner.Err()
+}
+
+func sleepUntil(ctx context.Context, deadline time.Time) error {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// execute replays a record. Every page of the rows read is fetched, as the
+// driver that sent the request likely did.
+func execute(
+	ctx context.Context,
+	session *gocql.Session,
+	rec *adapter.CaptureRecord,
+) error {
+	consistency := gocql.Consistency(rec.Consistency)
+	if rec.BatchType != nil {
+		batch := session.NewBatch(gocql.BatchType(*rec.BatchType)).WithContext(ctx)
+		batch.SetConsistency(consistency)
+		for _, stmt := range rec.Statements {
+			batch.Query(stmt.Query, boundValues(stmt)...)
+		}
+		return session.ExecuteBatch(batch)
+	}
+	stmt := rec.Statements[0]
+	iter := session.Query(stmt.Query, boundValues(stmt)...).
+		WithContext(ctx).
+		Consistency(consistency).
+		Iter()
+	row := make(map[string]interface{})
+	for iter.MapScan(row) {
+		clear(row)
+	}
+	return iter.Close()
+}
+
+// boundValues returns the values bound to a statement, by name if they were
+// captured by name.
+func boundValues(stmt adapter.CapturedStatement) []interface{} {
+	values := make([]interface{}, len(stmt.Values))
+	for i, value := range stmt.Values {
+		values[i] = rawValue(value)
+	}
+	for _, i := range stmt.Unset {
+		if i < len(values) {
+			values[i] = gocql.UnsetValue
+		}
+	}
+	if len(stmt.Names) == len(values) && len(values) > 0 {
+		for i, name := range stmt.Names {
+			values[i] = gocql.NamedValue(name, values[i])
+		}
+	}
+	return values
+}
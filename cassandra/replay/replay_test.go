@@ -0,0 +1,82 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundValues(t *testing.T) {
+	values := boundValues(adapter.CapturedStatement{
+		Values: [][]byte{{0, 0, 0, 1}, nil, nil},
+		Unset:  []int{2},
+	})
+	assert.Equal(t, []interface{}{
+		rawValue{0, 0, 0, 1},
+		rawValue(nil),
+		gocql.UnsetValue,
+	}, values)
+
+	info := gocql.NewNativeType(4, gocql.TypeInt, "")
+	encoded, err := gocql.Marshal(info, values[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 1}, encoded)
+	encoded, err = gocql.Marshal(info, values[1])
+	assert.NoError(t, err)
+	assert.Nil(t, encoded)
+
+	named := boundValues(adapter.CapturedStatement{
+		Values: [][]byte{{1}},
+		Names:  []string{"id"},
+	})
+	assert.Equal(t, []interface{}{gocql.NamedValue("id", rawValue{1})}, named)
+}
+
+func TestSleepUntil(t *testing.T) {
+	assert.NoError(t, sleepUntil(context.Background(), time.Now().Add(-time.Second)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, sleepUntil(ctx, time.Now().Add(time.Hour)), context.Canceled)
+}
+
+func TestReplayMalformedRecords(t *testing.T) {
+	_, err := Replay(context.Background(), Options{Records: strings.NewReader("")})
+	assert.Error(t, err)
+
+	session := &gocql.Session{}
+	_, err = Replay(context.Background(), Options{
+		Session: session,
+		Records: strings.NewReader("\n{not json}\n"),
+	})
+	assert.ErrorContains(t, err, "line 2")
+	_, err = Replay(context.Background(), Options{
+		Session: session,
+		Records: strings.NewReader(`{"time":"2025-01-02T03:04:05Z","statements":[]}`),
+	})
+	assert.ErrorContains(t, err, "no statement")
+}
@@ -0,0 +1,285 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package schema converts Cassandra schemas to the equivalent Spanner DDL,
+// annotated with the cassandra_type column options used by the Spanner
+// Cassandra adapter.
+package schema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Spanner types of the CQL types that are stored without conversion.
+var spannerTypes = map[string]string{
+	"ascii":     "STRING(MAX)",
+	"bigint":    "INT64",
+	"blob":      "BYTES(MAX)",
+	"boolean":   "BOOL",
+	"counter":   "INT64",
+	"date":      "DATE",
+	"decimal":   "NUMERIC",
+	"double":    "FLOAT64",
+	"float":     "FLOAT32",
+	"inet":      "STRING(MAX)",
+	"int":       "INT64",
+	"smallint":  "INT64",
+	"text":      "STRING(MAX)",
+	"time":      "INT64",
+	"timestamp": "TIMESTAMP",
+	"timeuuid":  "STRING(MAX)",
+	"tinyint":   "INT64",
+	"uuid":      "STRING(MAX)",
+	"varchar":   "STRING(MAX)",
+	"varint":    "NUMERIC",
+}
+
+// Conversion is the outcome of converting a Cassandra schema.
+type Conversion struct {
+	// Spanner DDL statements, one per converted table, without trailing
+	// semicolon.
+	Statements []string
+	// Statements of the input that have no Spanner equivalent (ie: CREATE
+	// KEYSPACE, CREATE INDEX), with the reason they were skipped.
+	Skipped []string
+}
+
+// String formats the conversion as a DDL script.
+func (c Conversion) String() string {
+	var sb strings.Builder
+	for _, skipped := range c.Skipped {
+		fmt.Fprintf(&sb, "-- Skipped: %s\n", skipped)
+	}
+	if len(c.Skipped) > 0 && len(c.Statements) > 0 {
+		sb.WriteString("\n")
+	}
+	for i, stmt := range c.Statements {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(stmt)
+		sb.WriteString(";\n")
+	}
+	return sb.String()
+}
+
+// Convert reads a Cassandra schema, such as the output of DESCRIBE KEYSPACE
+// or a file of CQL statements, and converts its tables to Spanner DDL. Lists
+// and sets are converted to ARRAYs and maps to JSON. Returns an error if a
+// table uses a type without Spanner equivalent.
+func Convert(r io.Reader) (Conversion, error) {
+	cql, err := io.ReadAll(r)
+	if err != nil {
+		return Conversion{}, err
+	}
+	tokens, err := tokenize(string(cql))
+	if err != nil {
+		return Conversion{}, err
+	}
+	var conversion Conversion
+	for _, stmt := range splitStatements(tokens) {
+		if !isCreateTable(stmt) {
+			conversion.Skipped = append(
+				conversion.Skipped,
+				fmt.Sprintf("%s (no Spanner equivalent)", summarize(stmt)),
+			)
+			continue
+		}
+		t, err := parseCreateTable(stmt)
+		if err != nil {
+			return Conversion{}, err
+		}
+		ddl, err := t.ddl()
+		if err != nil {
+			return Conversion{}, err
+		}
+		conversion.Statements = append(conversion.Statements, ddl)
+	}
+	return conversion, nil
+}
+
+// ConvertType returns the Spanner type of a CQL type (ie: ARRAY<INT64> for
+// list<int>).
+func ConvertType(cqlType string) (string, error) {
+	tokens, err := tokenize(cqlType)
+	if err != nil {
+		return "", err
+	}
+	p := &parser{tokens: tokens}
+	t, err := p.parseType()
+	if err != nil {
+		return "", err
+	}
+	if !p.done() {
+		return "", fmt.Errorf("unexpected %q after type %s", p.peek().text, t)
+	}
+	return t.spannerType()
+}
+
+func isCreateTable(stmt []token) bool {
+	return len(stmt) >= 2 &&
+		stmt[0].is("create") &&
+		(stmt[1].is("table") || stmt[1].is("columnfamily"))
+}
+
+// summarize returns the head of a statement, up to its first parenthesis,
+// brace or WITH clause.
+func summarize(stmt []token) string {
+	var sb strings.Builder
+	for i, t := range stmt {
+		if t.is("(") || t.is("{") || t.is("with") {
+			break
+		}
+		if i > 0 && !t.is(".") && !stmt[i-1].is(".") {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(t.text)
+	}
+	return sb.String()
+}
+
+// cqlType is a parsed CQL type, with its parameters for collections.
+type cqlType struct {
+	name   string
+	params []cqlType
+}
+
+// String formats the type as a cassandra_type option value.
+func (t cqlType) String() string {
+	if len(t.params) == 0 {
+		return t.name
+	}
+	params := make([]string, len(t.params))
+	for i, p := range t.params {
+		params[i] = p.String()
+	}
+	return t.name + "<" + strings.Join(params, ",") + ">"
+}
+
+// unfrozen returns the type without frozen<> wrappers.
+func (t cqlType) unfrozen() cqlType {
+	for t.name == "frozen" && len(t.params) == 1 {
+		t = t.params[0]
+	}
+	return t
+}
+
+// spannerType returns the Spanner type values of t are stored as.
+func (t cqlType) spannerType() (string, error) {
+	t = t.unfrozen()
+	switch t.name {
+	case "list", "set":
+		if len(t.params) != 1 {
+			return "", fmt.Errorf("invalid type %s", t)
+		}
+		elem := t.params[0].unfrozen()
+		if elem.name == "list" || elem.name == "set" {
+			return "", fmt.Errorf(
+				"unsupported type %s: Spanner does not support nested arrays",
+				t,
+			)
+		}
+		elemType, err := elem.spannerType()
+		if err != nil {
+			return "", err
+		}
+		return "ARRAY<" + elemType + ">", nil
+	case "map":
+		if len(t.params) != 2 {
+			return "", fmt.Errorf("invalid type %s", t)
+		}
+		return "JSON", nil
+	}
+	if len(t.params) > 0 {
+		return "", fmt.Errorf("unsupported type %s", t)
+	}
+	spannerType, ok := spannerTypes[t.name]
+	if !ok {
+		return "", fmt.Errorf("unsupported type %s", t)
+	}
+	return spannerType, nil
+}
+
+// column is a column definition of a CQL table.
+type column struct {
+	name string
+	typ  cqlType
+}
+
+// table is a parsed CREATE TABLE statement.
+type table struct {
+	name          string
+	columns       []column
+	partitionKey  []string
+	clusteringKey []string
+	// Clustering columns sorted in descending order.
+	descending map[string]bool
+}
+
+// ddl returns the Spanner CREATE TABLE statement of t.
+func (t *table) ddl() (string, error) {
+	if len(t.partitionKey) == 0 {
+		return "", fmt.Errorf("table %s has no primary key", t.name)
+	}
+	primaryKey := append(
+		t.partitionKey[:len(t.partitionKey):len(t.partitionKey)],
+		t.clusteringKey...,
+	)
+	inKey := make(map[string]bool)
+	for _, name := range primaryKey {
+		inKey[name] = true
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "CREATE TABLE %s (\n", t.name)
+	defined := make(map[string]bool)
+	for _, c := range t.columns {
+		spannerType, err := c.typ.spannerType()
+		if err != nil {
+			return "", fmt.Errorf("column %s.%s: %w", t.name, c.name, err)
+		}
+		defined[c.name] = true
+		notNull := ""
+		if inKey[c.name] {
+			notNull = " NOT NULL"
+		}
+		fmt.Fprintf(
+			&sb,
+			"  %s %s%s OPTIONS (cassandra_type = '%s'),\n",
+			c.name,
+			spannerType,
+			notNull,
+			c.typ,
+		)
+	}
+	keyParts := make([]string, len(primaryKey))
+	for i, name := range primaryKey {
+		if !defined[name] {
+			return "", fmt.Errorf(
+				"table %s: primary key column %s is not defined",
+				t.name,
+				name,
+			)
+		}
+		keyParts[i] = name
+		if t.descending[name] {
+			keyParts[i] += " DESC"
+		}
+	}
+	fmt.Fprintf(&sb, ") PRIMARY KEY (%s)", strings.Join(keyParts, ", "))
+	return sb.String(), nil
+}
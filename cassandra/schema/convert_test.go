@@ -0,0 +1,108 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const describeOutput = `
+CREATE KEYSPACE shop WITH replication = {'class': 'SimpleStrategy', 'replication_factor': '1'}  AND durable_writes = true;
+
+/* Orders of a customer. */
+CREATE TABLE shop.orders (
+    customer_id uuid,
+    created timestamp,
+    "OrderId" bigint,
+    items list<frozen<text>>,
+    tags set<int>,
+    attributes map<text, text>,
+    total decimal,
+    PRIMARY KEY ((customer_id, "OrderId"), created)
+) WITH CLUSTERING ORDER BY (created DESC)
+    AND comment = 'orders; newest first'
+    AND compaction = {'class': 'org.apache.cassandra.db.compaction.SizeTieredCompactionStrategy'};
+
+CREATE INDEX orders_total_idx ON shop.orders (total);
+
+-- Counters.
+CREATE TABLE IF NOT EXISTS shop.visits (page text PRIMARY KEY, count counter);
+`
+
+func TestConvert(t *testing.T) {
+	conversion, err := Convert(strings.NewReader(describeOutput))
+	require.NoError(t, err)
+	want := []string{
+		`CREATE TABLE orders (
+  customer_id STRING(MAX) NOT NULL OPTIONS (cassandra_type = 'uuid'),
+  created TIMESTAMP NOT NULL OPTIONS (cassandra_type = 'timestamp'),
+  OrderId INT64 NOT NULL OPTIONS (cassandra_type = 'bigint'),
+  items ARRAY<STRING(MAX)> OPTIONS (cassandra_type = 'list<frozen<text>>'),
+  tags ARRAY<INT64> OPTIONS (cassandra_type = 'set<int>'),
+  attributes JSON OPTIONS (cassandra_type = 'map<text,text>'),
+  total NUMERIC OPTIONS (cassandra_type = 'decimal'),
+) PRIMARY KEY (customer_id, OrderId, created DESC)`,
+		`CREATE TABLE visits (
+  page STRING(MAX) NOT NULL OPTIONS (cassandra_type = 'text'),
+  count INT64 OPTIONS (cassandra_type = 'counter'),
+) PRIMARY KEY (page)`,
+	}
+	assert.Equal(t, want, conversion.Statements)
+	assert.Equal(t, []string{
+		"CREATE KEYSPACE shop (no Spanner equivalent)",
+		"CREATE INDEX orders_total_idx ON shop.orders (no Spanner equivalent)",
+	}, conversion.Skipped)
+}
+
+func TestConvertUnsupportedType(t *testing.T) {
+	tests := []string{
+		"CREATE TABLE t (id int PRIMARY KEY, d duration)",
+		"CREATE TABLE t (id int PRIMARY KEY, p frozen<tuple<int, text>>)",
+		"CREATE TABLE t (id int PRIMARY KEY, l list<frozen<list<int>>>)",
+		"CREATE TABLE t (id int PRIMARY KEY, a frozen<address>)",
+		"CREATE TABLE t (id int, value text)",
+		"CREATE TABLE t (id int, PRIMARY KEY (other))",
+		"CREATE TABLE t (id int PRIMARY KEY",
+	}
+	for _, cql := range tests {
+		_, err := Convert(strings.NewReader(cql))
+		assert.Error(t, err, cql)
+	}
+}
+
+func TestConvertType(t *testing.T) {
+	tests := map[string]string{
+		"int":                          "INT64",
+		"varchar":                      "STRING(MAX)",
+		"blob":                         "BYTES(MAX)",
+		"float":                        "FLOAT32",
+		"frozen<set<uuid>>":            "ARRAY<STRING(MAX)>",
+		"map<text, frozen<list<int>>>": "JSON",
+	}
+	for cqlType, want := range tests {
+		got, err := ConvertType(cqlType)
+		assert.NoError(t, err, cqlType)
+		assert.Equal(t, want, got, cqlType)
+	}
+}
@@ -0,0 +1,392 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	// Unquoted identifier, keyword or number.
+	wordToken tokenKind = iota
+	// Double quoted identifier.
+	quotedToken
+	// Single quoted string literal.
+	stringToken
+	// Any other single character.
+	symbolToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// is reports whether t is the unquoted keyword or symbol s, ignoring case.
+func (t token) is(s string) bool {
+	return t.kind != quotedToken &&
+		t.kind != stringToken &&
+		strings.EqualFold(t.text, s)
+}
+
+// tokenize splits CQL into tokens, dropping whitespace and comments.
+func tokenize(cql string) ([]token, error) {
+	var tokens []token
+	runes := []rune(cql)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-',
+			r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			end := i + 2
+			for end+1 < len(runes) && (runes[end] != '*' || runes[end+1] != '/') {
+				end++
+			}
+			if end+1 >= len(runes) {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i = end + 2
+		case r == '"' || r == '\'':
+			text, n, err := quoted(runes[i:])
+			if err != nil {
+				return nil, err
+			}
+			kind := quotedToken
+			if r == '\'' {
+				kind = stringToken
+			}
+			tokens = append(tokens, token{kind: kind, text: text})
+			i += n
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{
+				kind: wordToken,
+				text: string(runes[start:i]),
+			})
+		default:
+			tokens = append(tokens, token{kind: symbolToken, text: string(r)})
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// quoted returns the unescaped content of the quoted token at the start of
+// runes, and the number of runes it spans. Quotes are escaped by doubling them.
+func quoted(runes []rune) (string, int, error) {
+	quote := runes[0]
+	var sb strings.Builder
+	for i := 1; i < len(runes); i++ {
+		if runes[i] != quote {
+			sb.WriteRune(runes[i])
+			continue
+		}
+		if i+1 < len(runes) && runes[i+1] == quote {
+			sb.WriteRune(quote)
+			i++
+			continue
+		}
+		return sb.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated quoted text %s", string(runes))
+}
+
+// splitStatements splits tokens on semicolons, dropping empty statements.
+func splitStatements(tokens []token) [][]token {
+	var stmts [][]token
+	start := 0
+	for i, t := range tokens {
+		if t.kind == symbolToken && t.text == ";" {
+			if i > start {
+				stmts = append(stmts, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		stmts = append(stmts, tokens[start:])
+	}
+	return stmts
+}
+
+// parser consumes the tokens of a single statement.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: symbolToken, text: "end of statement"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// accept consumes the next tokens if they are the keywords or symbols words.
+func (p *parser) accept(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	for i, w := range words {
+		if !p.tokens[p.pos+i].is(w) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) expect(words ...string) error {
+	if !p.accept(words...) {
+		return fmt.Errorf(
+			"expected %q, got %q",
+			strings.Join(words, " "),
+			p.peek().text,
+		)
+	}
+	return nil
+}
+
+// identifier consumes an identifier. Unquoted identifiers are lower-cased,
+// like Cassandra does.
+func (p *parser) identifier() (string, error) {
+	t := p.next()
+	switch t.kind {
+	case quotedToken:
+		return t.text, nil
+	case wordToken:
+		return strings.ToLower(t.text), nil
+	default:
+		return "", fmt.Errorf("expected identifier, got %q", t.text)
+	}
+}
+
+// parseType consumes a CQL type, such as map<text, frozen<list<int>>>.
+func (p *parser) parseType() (cqlType, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return cqlType{}, err
+	}
+	t := cqlType{name: name}
+	if !p.accept("<") {
+		return t, nil
+	}
+	for {
+		param, err := p.parseType()
+		if err != nil {
+			return cqlType{}, err
+		}
+		t.params = append(t.params, param)
+		if p.accept(">") {
+			return t, nil
+		}
+		if err := p.expect(","); err != nil {
+			return cqlType{}, err
+		}
+	}
+}
+
+// identifierList consumes a parenthesized, comma separated list of
+// identifiers.
+func (p *parser) identifierList() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if p.accept(")") {
+			return names, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseCreateTable parses a CREATE TABLE statement.
+func parseCreateTable(stmt []token) (*table, error) {
+	p := &parser{tokens: stmt}
+	t := &table{descending: make(map[string]bool)}
+	if err := t.parse(p); err != nil {
+		if t.name != "" {
+			return nil, fmt.Errorf("table %s: %w", t.name, err)
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *table) parse(p *parser) error {
+	if !p.accept("create", "table") && !p.accept("create", "columnfamily") {
+		return fmt.Errorf("expected CREATE TABLE, got %q", p.peek().text)
+	}
+	p.accept("if", "not", "exists")
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	// Spanner databases hold a single keyspace, drop the qualifier.
+	if p.accept(".") {
+		if name, err = p.identifier(); err != nil {
+			return err
+		}
+	}
+	t.name = name
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for {
+		if err := t.parseDefinition(p); err != nil {
+			return err
+		}
+		if p.accept(")") {
+			break
+		}
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		// DESCRIBE does not emit trailing commas, but hand written files may.
+		if p.accept(")") {
+			break
+		}
+	}
+	if p.accept("with") {
+		return t.parseOptions(p)
+	}
+	if !p.done() {
+		return fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return nil
+}
+
+// parseDefinition consumes a column definition or a PRIMARY KEY clause.
+func (t *table) parseDefinition(p *parser) error {
+	if p.accept("primary", "key") {
+		return t.parsePrimaryKey(p)
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	typ, err := p.parseType()
+	if err != nil {
+		return fmt.Errorf("column %s: %w", name, err)
+	}
+	t.columns = append(t.columns, column{name: name, typ: typ})
+	p.accept("static")
+	if p.accept("primary", "key") {
+		t.partitionKey = []string{name}
+	}
+	return nil
+}
+
+// parsePrimaryKey consumes the column list of a PRIMARY KEY clause, whose
+// first element is either a single partition key column or a parenthesized
+// list of them.
+func (t *table) parsePrimaryKey(p *parser) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	if p.peek().is("(") {
+		partitionKey, err := p.identifierList()
+		if err != nil {
+			return err
+		}
+		t.partitionKey = partitionKey
+	} else {
+		name, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		t.partitionKey = []string{name}
+	}
+	for !p.accept(")") {
+		if err := p.expect(","); err != nil {
+			return err
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		t.clusteringKey = append(t.clusteringKey, name)
+	}
+	return nil
+}
+
+// parseOptions consumes the WITH clause of a table, of which only the
+// clustering order is relevant to Spanner.
+func (t *table) parseOptions(p *parser) error {
+	for !p.done() {
+		if !p.accept("clustering", "order", "by") {
+			p.next()
+			continue
+		}
+		if err := p.expect("("); err != nil {
+			return err
+		}
+		for {
+			name, err := p.identifier()
+			if err != nil {
+				return err
+			}
+			if p.accept("desc") {
+				t.descending[name] = true
+			} else {
+				p.accept("asc")
+			}
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -19,23 +19,37 @@ This file provides a simple launcher for the Cassandra-to-Spanner proxy.
 The launcher starts the proxy, allowing CQL clients (like cqlsh) to connect
 to it as if it were a Cassandra database. Once started, the proxy listens for connections (default
 localhost:9042) and remains active until a SIGINT or SIGTERM signal is received,
-at which point it shuts down gracefully.
+at which point it shuts down gracefully. With -reuse-port or systemd socket
+activation, a SIGHUP signal upgrades the launcher in place: a new launcher is
+started from the same binary and takes over the endpoint. A SIGUSR1 signal
+logs the stuck connections with the goroutine stacks, and the top statements
+with -enable-statement-metrics.
 */
 
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/googleapis/go-spanner-cassandra/adapter"
 	spanner "github.com/googleapis/go-spanner-cassandra/cassandra/gocql"
+	"github.com/googleapis/go-spanner-cassandra/encryption"
+	"github.com/googleapis/go-spanner-cassandra/internal/daemon"
 	"github.com/googleapis/go-spanner-cassandra/logger"
 	"go.uber.org/zap"
 )
 
+// Number of statement fingerprints logged on SIGUSR1.
+const topStatementsLogged = 20
+
 func main() {
 	databaseURI := flag.String(
 		"db",
@@ -49,18 +63,534 @@ func main() {
 		"The Spanner Adapter proxy listner address. Default to :9042 to bind all network interfaces due to docker forwarding",
 	)
 
+	tcpPortRange := flag.String(
+		"tcp-port-range",
+		"",
+		"Inclusive range of fallback ports (ie: 9043-9050) tried if the -tcp endpoint is in use (optional). Default to empty.",
+	)
+
+	ephemeralPortFallback := flag.Bool(
+		"tcp-ephemeral-fallback",
+		false,
+		"Whether to listen on an ephemeral port if the -tcp endpoint and -tcp-port-range are in use. Default to false.",
+	)
+
+	listenNetwork := flag.String(
+		"listen-network",
+		"tcp",
+		"Network of the listeners: tcp4 or tcp6 to only listen on IPv4 or IPv6 addresses (optional). Default to tcp (both).",
+	)
+
+	listenInterface := flag.String(
+		"listen-interface",
+		"",
+		"Name of the network interface (ie: eth0) whose address the -tcp listener binds to, in place of the host of -tcp (optional). Default to empty.",
+	)
+
+	reusePort := flag.Bool(
+		"reuse-port",
+		false,
+		"Whether to listen with SO_REUSEPORT, so that the launcher can be upgraded in place with SIGHUP (optional). Default to false.",
+	)
+
+	listenerShards := flag.Int(
+		"listener-shards",
+		1,
+		"Number of listeners opened on the -tcp endpoint with SO_REUSEPORT, each with its own accept loop, to spread thousands of client connections over (optional). Default to 1.",
+	)
+
+	pidFile := flag.String(
+		"pid-file",
+		"",
+		"Path of the file the PID of the launcher is written to once it serves connections, and removed from on shutdown (optional). Default to empty.",
+	)
+
+	upgradeTimeout := flag.Duration(
+		"upgrade-timeout",
+		time.Minute,
+		"Time given to the new launcher started by SIGHUP to serve connections before the upgrade is aborted (optional). Default to 1m.",
+	)
+
+	shutdownTimeout := flag.Duration(
+		"shutdown-timeout",
+		30*time.Second,
+		"Time given to the open client connections to close once the new launcher serves connections after SIGHUP, before they are closed (optional). Default to 30s.",
+	)
+
 	numGrpcChannels := flag.Int(
 		"grpc-channels",
 		4,
 		"The number of channels when dial grpc connection. Default to 4.",
 	)
 
+	minGrpcChannels := flag.Int(
+		"min-grpc-channels",
+		1,
+		"The minimum number of grpc channels when autoscaling. Default to 1.",
+	)
+
+	maxGrpcChannels := flag.Int(
+		"max-grpc-channels",
+		0,
+		"The maximum number of grpc channels. If set, the number of channels grows and shrinks with load. Default to 0 (disabled).",
+	)
+
+	readChannels := flag.Int(
+		"read-channels",
+		0,
+		"The number of grpc channels dedicated to reads (optional). Default to 0 (shared channels).",
+	)
+
+	writeChannels := flag.Int(
+		"write-channels",
+		0,
+		"The number of grpc channels dedicated to writes (optional). Default to 0 (shared channels).",
+	)
+
+	readEndpoint := flag.String(
+		"read-endpoint",
+		"",
+		"The Spanner endpoint of the channels dedicated to reads (optional). Default to the Spanner endpoint.",
+	)
+
+	writeEndpoint := flag.String(
+		"write-endpoint",
+		"",
+		"The Spanner endpoint of the channels dedicated to writes (optional). Default to the Spanner endpoint.",
+	)
+
+	channelAffinity := flag.Bool(
+		"channel-affinity",
+		false,
+		"Whether to send all the requests of a driver connection on the same grpc channel. Default to false (round-robin).",
+	)
+
+	grpcKeepaliveTime := flag.Duration(
+		"grpc-keepalive-time",
+		0,
+		"Interval (ie: 30s) of the keepalive pings sent on the grpc channels (optional). Default to 0 (no keepalive pings).",
+	)
+
+	grpcKeepaliveTimeout := flag.Duration(
+		"grpc-keepalive-timeout",
+		0,
+		"Time to wait for the acknowledgement of a keepalive ping before closing the grpc connection (optional). Default to 20s.",
+	)
+
+	grpcMinConnectTimeout := flag.Duration(
+		"grpc-min-connect-timeout",
+		0,
+		"Minimum time given to the grpc channels to connect to Spanner (optional). Default to 20s.",
+	)
+
+	grpcDialNetwork := flag.String(
+		"grpc-dial-network",
+		"tcp",
+		"Network the grpc channels connect to Spanner on: tcp4 or tcp6 to only connect to IPv4 or IPv6 addresses (optional). Default to tcp (both).",
+	)
+
+	grpcCompression := flag.String(
+		"grpc-compression",
+		"none",
+		"Compression of the grpc messages exchanged with Spanner: none or gzip (optional). Default to none.",
+	)
+
 	logLevel := flag.String(
 		"log",
 		"info",
 		"Log level. Default to info.",
 	)
 
+	logRedaction := flag.String(
+		"log-redaction",
+		"none",
+		"How statement literals, bound values and rows are redacted in debug logs: none, hash or drop. Default to none.",
+	)
+
+	logRedactionAllow := flag.String(
+		"log-redaction-allow",
+		"",
+		"Comma separated list of tables (ie: keyspace.table) logged without redaction (optional). Default to empty.",
+	)
+
+	labels := flag.String(
+		"labels",
+		"",
+		"Comma separated list of key=value labels (ie: tenant_id=acme) forwarded with every request (optional). Default to empty.",
+	)
+
+	maxSessionFailures := flag.Int(
+		"max-session-failures",
+		0,
+		"Number of consecutive Spanner session refresh failures after which the proxy stops accepting connections and exits with a non-zero exit code (optional). Default to 0 (disabled).",
+	)
+
+	drainTimeout := flag.Duration(
+		"drain-timeout",
+		0,
+		"Time given to the open connections to close after -max-session-failures before they are closed and the proxy exits with a non-zero exit code (optional). Default to 0 (exit right away).",
+	)
+
+	connectionReportInterval := flag.Duration(
+		"connection-report-interval",
+		0,
+		"Interval at which the connections stuck waiting on Spanner or on their driver are logged (optional). Default to 0 (disabled).",
+	)
+
+	stuckConnectionThreshold := flag.Duration(
+		"stuck-connection-threshold",
+		time.Minute,
+		"Time after which a connection waiting on Spanner or on its driver is reported as stuck (optional). Default to 1m.",
+	)
+
+	payloadAdvisorInterval := flag.Duration(
+		"payload-advisor-interval",
+		0,
+		"Interval at which recommendations to tune the proxy for the sizes of its requests and responses are logged (optional). Default to 0 (disabled).",
+	)
+
+	adaptiveConcurrency := flag.Bool(
+		"adaptive-concurrency",
+		false,
+		"Whether to adapt the number of requests sent concurrently to Spanner to its latencies, answering the requests over the limit with Overloaded errors (optional). Default to false.",
+	)
+
+	maxConcurrencyLimit := flag.Int(
+		"max-concurrency-limit",
+		1000,
+		"Maximum limit of the requests sent concurrently to Spanner with -adaptive-concurrency (optional). Default to 1000.",
+	)
+
+	maxOverloadErrorDelay := flag.Duration(
+		"max-overload-error-delay",
+		0,
+		"Maximum time the errors of the requests Spanner rejected as overloaded are delayed by, following the retry delay suggested by Spanner (optional). Default to 0 (not delayed).",
+	)
+
+	maxConnections := flag.Int(
+		"max-connections",
+		0,
+		"Maximum number of open client connections, further connections are answered with an Overloaded error (optional). Default to 0 (unlimited).",
+	)
+
+	handshakeTimeout := flag.Duration(
+		"handshake-timeout",
+		0,
+		"Time (ie: 10s) given to client connections to complete their handshake (STARTUP and authentication) before they are closed (optional). Default to 0 (no timeout).",
+	)
+
+	acceptRate := flag.Float64(
+		"accept-rate",
+		0,
+		"Maximum number of client connections accepted per second, further connections wait in the accept queue (optional). Default to 0 (unlimited).",
+	)
+
+	acceptBurst := flag.Int(
+		"accept-burst",
+		0,
+		"Number of client connections accepted without delay after the proxy was idle, with -accept-rate (optional). Default to one second of -accept-rate.",
+	)
+
+	maxConcurrentHandshakes := flag.Int(
+		"max-concurrent-handshakes",
+		0,
+		"Maximum number of client connections completing their handshake concurrently (optional). Default to 0 (unlimited).",
+	)
+
+	proxyProtocol := flag.Bool(
+		"proxy-protocol",
+		false,
+		"Whether client connections start with a PROXY protocol v2 header sent by a load balancer, carrying the address of the client. Default to false.",
+	)
+
+	maxFrameSize := flag.Int(
+		"max-frame-size",
+		0,
+		"Maximum size in bytes of the frames sent by clients, connections sending larger frames are closed (optional). Default to 268435456 (256MiB).",
+	)
+
+	maxRequestSize := flag.Int(
+		"max-request-size",
+		0,
+		"Maximum size in bytes of the requests sent to Spanner, larger requests are rejected with an Invalid error (optional). Default to 104857600 (100MiB).",
+	)
+
+	maxResponseSize := flag.Int(
+		"max-response-size",
+		0,
+		"Maximum size in bytes of the response to a request buffered before it is written to the client, larger responses are answered with an Invalid error (optional). Default to 268435456 (256MiB).",
+	)
+
+	strictConsistency := flag.Bool(
+		"strict-consistency",
+		false,
+		"Whether to reject statements whose consistency level is not valid for them (ie: ANY reads, SERIAL writes) instead of logging them. Default to false.",
+	)
+
+	strictWriteTimestamps := flag.Bool(
+		"strict-write-timestamps",
+		false,
+		"Whether to reject statements with a USING TIMESTAMP clause, which Spanner does not apply, instead of logging them. Default to false.",
+	)
+
+	weakConsistencyStaleness := flag.Duration(
+		"weak-consistency-staleness",
+		0,
+		"Staleness (ie: 10s) of reads sent with the ONE or LOCAL_ONE consistency levels (optional). Default to 0 (strong reads).",
+	)
+
+	readYourWrites := flag.Bool(
+		"read-your-writes",
+		false,
+		"Whether stale reads of a connection observe the previous writes of the connection. Default to false.",
+	)
+
+	latencyPayload := flag.Bool(
+		"latency-payload",
+		false,
+		"Whether to add the latency of each request in the proxy and in Spanner to the custom payload of its response. Default to false.",
+	)
+
+	rowCountPayload := flag.Bool(
+		"row-count-payload",
+		false,
+		"Whether to add the number of rows affected by each DML statement to the custom payload of its response. Default to false.",
+	)
+
+	builtInMetrics := flag.Bool(
+		"enable-builtin-metrics",
+		false,
+		"Whether to export the built-in client-side metrics of AdaptMessage calls to Cloud Monitoring. Default to false.",
+	)
+
+	statementMetrics := flag.Bool(
+		"enable-statement-metrics",
+		false,
+		"Whether to count the executions, errors and latencies of the statements by fingerprint, logged on SIGUSR1 (optional). Default to false.",
+	)
+
+	maxStatementFingerprints := flag.Int(
+		"max-statement-fingerprints",
+		1000,
+		"The maximum number of statement fingerprints tracked with -enable-statement-metrics (optional). Default to 1000.",
+	)
+
+	maxTransactionRetries := flag.Int(
+		"max-transaction-retries",
+		0,
+		"The number of times the statements of an explicit transaction are sent again when Spanner aborts the transaction (optional). Default to 10. A negative value disables replays.",
+	)
+
+	batchReprepare := flag.Bool(
+		"batch-reprepare",
+		false,
+		"Whether to prepare again the evicted prepared statements of a batch before executing it, instead of rejecting the batch with an Unprepared error. Default to false.",
+	)
+
+	stateRedisAddress := flag.String(
+		"state-redis-address",
+		"",
+		"The address (host:port) of a Redis or Memorystore instance the prepared query ids are shared through with the other proxies of the database (optional). Default to empty.",
+	)
+
+	stateRedisPassword := flag.String(
+		"state-redis-password",
+		"",
+		"The password of the Redis instance of -state-redis-address (optional). Default to empty.",
+	)
+
+	ttlColumns := flag.String(
+		"ttl-columns",
+		"",
+		"Comma separated list of table=column pairs (ie: ks.sessions=expires_at) of the expiration columns USING TTL clauses are translated to (optional). Default to empty.",
+	)
+
+	jsonColumns := flag.String(
+		"json-columns",
+		"",
+		"Semicolon separated list of column=type pairs (ie: ks.users.home=frozen<address>) of the tuple and UDT columns stored as JSON (optional). Default to empty.",
+	)
+
+	userTypes := flag.String(
+		"user-types",
+		"",
+		"Semicolon separated list of type=fields pairs (ie: ks.address=street text, zip int) of the user-defined types of -json-columns (optional). Default to empty.",
+	)
+
+	kmsKeyName := flag.String(
+		"kms-key-name",
+		"",
+		"Cloud KMS key (ie: projects/p/locations/l/keyRings/r/cryptoKeys/k) wrapping the data keys of -encrypted-columns (optional). Default to empty.",
+	)
+
+	encryptedColumns := flag.String(
+		"encrypted-columns",
+		"",
+		"Comma separated list of text or blob columns (ie: ks.users.ssn) encrypted with -kms-key-name (optional). Default to empty.",
+	)
+
+	emulateFunctions := flag.Bool(
+		"emulate-functions",
+		false,
+		"Whether to evaluate calls of now(), uuid() and similar functions in the proxy, and to rewrite writetime() calls to the columns of -write-time-columns. Default to false.",
+	)
+
+	changeStreams := flag.Bool(
+		"change-streams",
+		false,
+		"Whether to serve queries on the tables of the cdc keyspace from the Spanner change streams of the same name. Default to false.",
+	)
+
+	changeStreamReadTimeout := flag.Duration(
+		"change-stream-read-timeout",
+		0,
+		"Maximum time spent reading a page of a change stream query with -change-streams, which should not exceed the request timeout of the drivers (optional). Default to 10s.",
+	)
+
+	writeTimeColumns := flag.String(
+		"write-time-columns",
+		"",
+		"Comma separated list of table=column pairs (ie: ks.users=updated_at) of the commit timestamp columns read by writetime() calls with -emulate-functions (optional). Default to empty.",
+	)
+
+	unpagedReadPageSize := flag.Int(
+		"unpaged-read-page-size",
+		0,
+		"The page size the proxy reads the results of unpaged SELECT requests with from Spanner, answering the driver with all of their rows at once (optional). Default to 0 (disabled).",
+	)
+
+	cqlshCompat := flag.Bool(
+		"cqlsh-compat",
+		false,
+		"Whether to emulate the Cassandra behaviors cqlsh relies on, ie: paging of SELECT results (optional). Default to false.",
+	)
+
+	maxBatchStatements := flag.Int(
+		"max-batch-statements",
+		0,
+		"Maximum number of statements of the UNLOGGED batches of INSERT statements sent to Spanner in a single request, larger batches (ie: of cqlsh COPY FROM) being split into concurrent batches (optional). Default to 0 (not split).",
+	)
+
+	batchSplitParallelism := flag.Int(
+		"batch-split-parallelism",
+		4,
+		"Maximum number of parts of a batch split with -max-batch-statements sent concurrently (optional). Default to 4.",
+	)
+
+	loggedBatches := flag.String(
+		"logged-batch-atomicity",
+		"atomic",
+		"How LOGGED batches are applied: atomic (single transaction), per-partition (a transaction per partition) or single-partition (batches spanning more than one partition are rejected) (optional). Default to atomic.",
+	)
+
+	unloggedBatches := flag.String(
+		"unlogged-batch-atomicity",
+		"atomic",
+		"How UNLOGGED batches are applied: atomic (single transaction), per-partition (a transaction per partition) or single-partition (batches spanning more than one partition are rejected) (optional). Default to atomic.",
+	)
+
+	batchDedupCacheSize := flag.Int(
+		"batch-dedup-cache-size",
+		0,
+		"Number of idempotency tokens of batches (spanner_idempotency_token custom payload) remembered to answer the replays of applied batches with their response (optional). Default to 0 (tokens are ignored).",
+	)
+
+	batchDedupWindow := flag.Duration(
+		"batch-dedup-window",
+		10*time.Minute,
+		"Time the responses of the batches of idempotency tokens are replayed for (optional). Default to 10m.",
+	)
+
+	clusterName := flag.String(
+		"cluster-name",
+		"",
+		"Cluster name reported in system.local in place of the one of Spanner (optional). Default to empty.",
+	)
+
+	dataCenter := flag.String(
+		"data-center",
+		"",
+		"Data center reported in system.local and system.peers in place of the one of Spanner, ie: for DC-aware load balancing policies (optional). Default to empty.",
+	)
+
+	rack := flag.String(
+		"rack",
+		"",
+		"Rack reported in system.local and system.peers in place of the one of Spanner (optional). Default to empty.",
+	)
+
+	hostID := flag.String(
+		"host-id",
+		"",
+		"Host id (a UUID) reported in system.local in place of the one of Spanner (optional). Default to empty.",
+	)
+
+	releaseVersion := flag.String(
+		"release-version",
+		"",
+		"Cassandra release version reported in system.local and system.peers in place of the one of Spanner (optional). Default to empty.",
+	)
+
+	syntheticPeers := flag.Int(
+		"synthetic-peers",
+		0,
+		"Number of virtual peers reported in system.peers, so that drivers open more connections per proxy; drivers must translate their addresses (127.0.1.1 and onwards) to the address of the proxy (optional). Default to 0.",
+	)
+
+	readOnly := flag.Bool(
+		"read-only",
+		false,
+		"Whether to reject all statements but SELECT and USE statements with an Unauthorized error. Default to false.",
+	)
+
+	readOnlyTables := flag.String(
+		"read-only-tables",
+		"",
+		"Comma separated list of tables (ie: ks.users) whose DML, TRUNCATE and table DDL statements are rejected with an Unauthorized error (optional). Default to empty.",
+	)
+
+	fullScans := flag.String(
+		"full-scan-policy",
+		"allow",
+		"How SELECT statements scanning a whole table are served: allow, low-priority, warn or reject. Default to allow.",
+	)
+
+	directedReadReplicas := flag.String(
+		"directed-read-replicas",
+		"",
+		"Comma separated list of location[:type] replicas (ie: us-east1:READ_ONLY) the SELECT statements are served by (optional). Default to the replicas chosen by Spanner.",
+	)
+
+	excludeDirectedReadReplicas := flag.Bool(
+		"exclude-directed-read-replicas",
+		false,
+		"Whether the SELECT statements are served by other replicas than those of -directed-read-replicas (optional). Default to false.",
+	)
+
+	supportedAttachments := flag.String(
+		"supported-attachments",
+		"",
+		"Comma separated list of the attachments defined by the proxy the Spanner Adapter backend honors (ie: exact_staleness,directed_read_options), without which the requests and options relying on them fail (optional). Default to empty.",
+	)
+
+	readCacheTTLs := flag.String(
+		"read-cache-ttls",
+		"",
+		"Comma separated list of table=duration pairs (ie: ks.flags=30s) of the tables whose SELECT responses are cached, and for how long (optional). Default to empty.",
+	)
+
+	captureFile := flag.String(
+		"capture-file",
+		"",
+		"File a sample of the requests is appended to, to replay them with cmd/replay (optional). Default to empty (disabled).",
+	)
+
+	captureSampleRate := flag.Float64(
+		"capture-sample-rate",
+		0.01,
+		"Fraction of the requests recorded to -capture-file, between 0 and 1 (optional). Default to 0.01.",
+	)
+
 	maxCommitDelay := flag.Int(
 		"max_commit_delay",
 		0,
@@ -72,6 +602,36 @@ func main() {
 		"The Spanner service endpoint (optional). Default to Cloud Spanner endpoint: spanner.googleapis.com:443",
 	)
 
+	failoverEndpoints := flag.String(
+		"failover-endpoints",
+		"",
+		"Comma separated list of Spanner endpoints (ie: regional endpoints), in order of preference, to fail over to when the Spanner endpoint is unavailable (optional). Default to empty.",
+	)
+
+	candidateEndpoints := flag.String(
+		"candidate-endpoints",
+		"",
+		"Comma separated list of Spanner endpoints (ie: regional endpoints) probed at startup along with the Spanner endpoint, the fastest healthy one of which the proxy connects to (optional). Default to empty.",
+	)
+
+	tableRouting := flag.String(
+		"table-routing",
+		"",
+		"Comma separated list of table=database pairs (ie: ks.orders=projects/p/instances/i/databases/orders) of the Spanner databases serving keyspace qualified tables (optional). Default to empty.",
+	)
+
+	listeners := flag.String(
+		"listeners",
+		"",
+		"Comma separated list of endpoint=database pairs (ie: localhost:9043=projects/p/instances/i/databases/orders) of additional listeners serving their connections with another Spanner database (optional). Default to empty.",
+	)
+
+	enableDirectAccess := flag.Bool(
+		"enable-direct-access",
+		false,
+		"Whether to connect to Spanner over DirectPath when running on Google Cloud. Default to false.",
+	)
+
 	usePlainText := flag.Bool(
 		"usePlainText",
 		false,
@@ -102,6 +662,60 @@ func main() {
 		"The client key file path for establishing mTLS connection(optional). Default to empty.",
 	)
 
+	secretCredentials := flag.String(
+		"secret-credentials",
+		"",
+		"Secret Manager secret version (ie: projects/p/secrets/s/versions/latest) holding the JSON credentials used to reach Spanner (optional). Default to empty.",
+	)
+
+	secretCaCertificate := flag.String(
+		"secret-ca-certificate",
+		"",
+		"Secret Manager secret version holding the CA certificate of the TLS connections to experimental hosts, in place of -caCertificate (optional). Default to empty.",
+	)
+
+	secretClientCertificate := flag.String(
+		"secret-client-certificate",
+		"",
+		"Secret Manager secret version holding the client certificate of mTLS connections, in place of -clientCertificate (optional). Default to empty.",
+	)
+
+	secretClientKey := flag.String(
+		"secret-client-key",
+		"",
+		"Secret Manager secret version holding the client key of mTLS connections, in place of -clientKey (optional). Default to empty.",
+	)
+
+	secretStatementPolicy := flag.String(
+		"secret-statement-policy",
+		"",
+		"Secret Manager secret version holding the JSON statement policy, in place of -read-only and -read-only-tables (optional). Default to empty.",
+	)
+
+	secretRefreshInterval := flag.Duration(
+		"secret-refresh-interval",
+		0,
+		"Interval between the reads of the client certificate, client key and statement policy secrets (optional). Default to 0 (read once).",
+	)
+
+	check := flag.Bool(
+		"check",
+		false,
+		"Whether to validate the credentials, the database, the schema of the -check-tables and AdaptMessage reachability, print a report and exit instead of starting the proxy. Exits with a non-zero exit code if any check fails. Default to false.",
+	)
+
+	checkTables := flag.String(
+		"check-tables",
+		"",
+		"Comma separated list of tables (ie: keyspace.table) whose columns must have cassandra_type options in -check mode (optional). Default to empty.",
+	)
+
+	checkTimeout := flag.Duration(
+		"check-timeout",
+		time.Minute,
+		"Timeout of the -check mode. Default to 1m.",
+	)
+
 	flag.Parse()
 
 	if *databaseURI == "" {
@@ -110,35 +724,443 @@ func main() {
 		os.Exit(1)
 	}
 
+	redactionMode, err := logger.ParseRedactionMode(*logRedaction)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	var allowedTables []string
+	if *logRedactionAllow != "" {
+		allowedTables = strings.Split(*logRedactionAllow, ",")
+	}
+
+	connectionLabels := make(map[string]string)
+	if *labels != "" {
+		for _, label := range strings.Split(*labels, ",") {
+			key, value, ok := strings.Cut(label, "=")
+			if !ok {
+				fmt.Printf("Error: invalid label %q, expected key=value\n", label)
+				flag.Usage()
+				os.Exit(1)
+			}
+			connectionLabels[key] = value
+		}
+	}
+
+	var failover []string
+	if *failoverEndpoints != "" {
+		failover = strings.Split(*failoverEndpoints, ",")
+	}
+	var candidates []string
+	if *candidateEndpoints != "" {
+		candidates = strings.Split(*candidateEndpoints, ",")
+	}
+	var attachments []string
+	if *supportedAttachments != "" {
+		attachments = strings.Split(*supportedAttachments, ",")
+	}
+
+	tableDatabases := make(map[string]string)
+	if *tableRouting != "" {
+		for _, pair := range strings.Split(*tableRouting, ",") {
+			table, database, ok := strings.Cut(pair, "=")
+			if !ok {
+				fmt.Printf("Error: invalid table routing %q, expected table=database\n", pair)
+				flag.Usage()
+				os.Exit(1)
+			}
+			tableDatabases[table] = database
+		}
+	}
+
+	var listenerConfigs []adapter.ListenerConfig
+	if *listeners != "" {
+		for _, pair := range strings.Split(*listeners, ",") {
+			endpoint, database, ok := strings.Cut(pair, "=")
+			if !ok {
+				fmt.Printf("Error: invalid listener %q, expected endpoint=database\n", pair)
+				flag.Usage()
+				os.Exit(1)
+			}
+			listenerConfigs = append(listenerConfigs, adapter.ListenerConfig{
+				TCPEndpoint: endpoint,
+				DatabaseUri: database,
+			})
+		}
+	}
+
+	expirationColumns := make(map[string]string)
+	if *ttlColumns != "" {
+		for _, pair := range strings.Split(*ttlColumns, ",") {
+			table, column, ok := strings.Cut(pair, "=")
+			if !ok {
+				fmt.Printf("Error: invalid TTL column %q, expected table=column\n", pair)
+				flag.Usage()
+				os.Exit(1)
+			}
+			expirationColumns[table] = column
+		}
+	}
+
+	jsonColumnTypes := make(map[string]string)
+	if *jsonColumns != "" {
+		for _, pair := range strings.Split(*jsonColumns, ";") {
+			column, cqlType, ok := strings.Cut(pair, "=")
+			if !ok {
+				fmt.Printf("Error: invalid JSON column %q, expected column=type\n", pair)
+				flag.Usage()
+				os.Exit(1)
+			}
+			jsonColumnTypes[strings.TrimSpace(column)] = cqlType
+		}
+	}
+
+	userTypeFields := make(map[string]string)
+	if *userTypes != "" {
+		for _, pair := range strings.Split(*userTypes, ";") {
+			name, fields, ok := strings.Cut(pair, "=")
+			if !ok {
+				fmt.Printf("Error: invalid user type %q, expected type=fields\n", pair)
+				flag.Usage()
+				os.Exit(1)
+			}
+			userTypeFields[strings.TrimSpace(name)] = fields
+		}
+	}
+
+	var columnCodecs map[string]string
+	if *encryptedColumns != "" {
+		if *kmsKeyName == "" {
+			fmt.Println("Error: --kms-key-name is required by --encrypted-columns")
+			flag.Usage()
+			os.Exit(1)
+		}
+		kek, err := encryption.NewKMSKeyEncrypter(context.Background(), *kmsKeyName)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if err := encryption.Register("kms", kek, encryption.Options{}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		columnCodecs = make(map[string]string)
+		for _, column := range strings.Split(*encryptedColumns, ",") {
+			columnCodecs[strings.TrimSpace(column)] = "kms"
+		}
+	}
+
+	commitTimestampColumns := make(map[string]string)
+	if *writeTimeColumns != "" {
+		for _, pair := range strings.Split(*writeTimeColumns, ",") {
+			table, column, ok := strings.Cut(pair, "=")
+			if !ok {
+				fmt.Printf("Error: invalid write time column %q, expected table=column\n", pair)
+				flag.Usage()
+				os.Exit(1)
+			}
+			commitTimestampColumns[table] = column
+		}
+	}
+
+	cacheTTLs := make(map[string]time.Duration)
+	if *readCacheTTLs != "" {
+		for _, pair := range strings.Split(*readCacheTTLs, ",") {
+			table, value, ok := strings.Cut(pair, "=")
+			ttl, err := time.ParseDuration(value)
+			if !ok || err != nil {
+				fmt.Printf("Error: invalid read cache TTL %q, expected table=duration\n", pair)
+				flag.Usage()
+				os.Exit(1)
+			}
+			cacheTTLs[table] = ttl
+		}
+	}
+
+	fullScanPolicy, err := adapter.ParseFullScanPolicy(*fullScans)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	loggedBatchAtomicity, err := adapter.ParseBatchAtomicity(*loggedBatches)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+	unloggedBatchAtomicity, err := adapter.ParseBatchAtomicity(*unloggedBatches)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	directedReads, err := adapter.ParseDirectedReadOptions(
+		*directedReadReplicas,
+		*excludeDirectedReadReplicas,
+	)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	var policy *adapter.StatementPolicy
+	if *readOnly || *readOnlyTables != "" {
+		policy = &adapter.StatementPolicy{ReadOnly: *readOnly}
+		if *readOnlyTables != "" {
+			policy.ReadOnlyTables = strings.Split(*readOnlyTables, ",")
+		}
+	}
+
+	var topology *adapter.TopologyOverride
+	if *clusterName != "" || *dataCenter != "" || *rack != "" || *hostID != "" ||
+		*releaseVersion != "" {
+		topology = &adapter.TopologyOverride{
+			ClusterName:    *clusterName,
+			DataCenter:     *dataCenter,
+			Rack:           *rack,
+			HostID:         *hostID,
+			ReleaseVersion: *releaseVersion,
+		}
+	}
+
+	var secretRefs *adapter.SecretRefs
+	if *secretCredentials != "" || *secretCaCertificate != "" ||
+		*secretClientCertificate != "" || *secretClientKey != "" ||
+		*secretStatementPolicy != "" {
+		secretRefs = &adapter.SecretRefs{
+			Credentials:       *secretCredentials,
+			CaCertificate:     *secretCaCertificate,
+			ClientCertificate: *secretClientCertificate,
+			ClientKey:         *secretClientKey,
+			StatementPolicy:   *secretStatementPolicy,
+			RefreshInterval:   *secretRefreshInterval,
+		}
+	}
+
+	// Listener inherited from systemd socket activation, or from the launcher
+	// upgrading in place.
+	inherited, err := daemon.Listener()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	opts := &spanner.Options{
-		DatabaseUri:       *databaseURI,
-		TCPEndpoint:       *tcpEndpoint,
-		NumGrpcChannels:   *numGrpcChannels,
-		LogLevel:          *logLevel,
-		MaxCommitDelay:    *maxCommitDelay,
-		SpannerEndpoint:   *spannerEndpoint,
-		UsePlainText:      *usePlainText,
-		ExperimentalHost:  *experimentalHost,
-		CaCertificate:     *caCertificate,
-		ClientCertificate: *clientCertificate,
-		ClientKey:         *clientKey,
-	}
-
-	cluster := spanner.NewCluster(opts)
-	if cluster == nil {
-		logger.Error("Failed to initialize Spanner Cassandra Adapter")
+		DatabaseUri:             *databaseURI,
+		TCPEndpoint:             *tcpEndpoint,
+		TCPPortRange:            *tcpPortRange,
+		FallbackToEphemeralPort: *ephemeralPortFallback,
+		ListenNetwork:           *listenNetwork,
+		ListenInterface:         *listenInterface,
+		ReusePort:               *reusePort,
+		Listener:                inherited,
+		ListenerShards:          *listenerShards,
+		NumGrpcChannels:         *numGrpcChannels,
+		MinGrpcChannels:         *minGrpcChannels,
+		MaxGrpcChannels:         *maxGrpcChannels,
+		ReadChannels:            *readChannels,
+		WriteChannels:           *writeChannels,
+		ReadEndpoint:            *readEndpoint,
+		WriteEndpoint:           *writeEndpoint,
+		ChannelAffinity:         *channelAffinity,
+		GrpcKeepaliveTime:       *grpcKeepaliveTime,
+		GrpcKeepaliveTimeout:    *grpcKeepaliveTimeout,
+		GrpcMinConnectTimeout:   *grpcMinConnectTimeout,
+		GrpcDialNetwork:         *grpcDialNetwork,
+		GrpcCompression:         *grpcCompression,
+		MaxTransactionRetries:   *maxTransactionRetries,
+		LogLevel:                *logLevel,
+		LogRedactionPolicy: logger.RedactionPolicy{
+			Mode:          redactionMode,
+			AllowedTables: allowedTables,
+		},
+		ConnectionLabels:        connectionLabels,
+		MaxSessionFailures:      *maxSessionFailures,
+		MaxConnections:          *maxConnections,
+		HandshakeTimeout:        *handshakeTimeout,
+		AcceptRate:              *acceptRate,
+		AcceptBurst:             *acceptBurst,
+		MaxConcurrentHandshakes: *maxConcurrentHandshakes,
+		AcceptProxyProtocol:     *proxyProtocol,
+		MaxFrameSize:            *maxFrameSize,
+		MaxRequestSize:          *maxRequestSize,
+		MaxResponseSize:         *maxResponseSize,
+		AdaptiveConcurrency:     *adaptiveConcurrency,
+		MaxConcurrencyLimit:     *maxConcurrencyLimit,
+		MaxOverloadErrorDelay:   *maxOverloadErrorDelay,
+		DrainTimeout:            *drainTimeout,
+		OnDrain: func(err error) {
+			// Fatal logs exit with a non-zero exit code, whether the
+			// connections closed in time or not.
+			if errors.Is(err, adapter.ErrDrainTimeout) {
+				logger.Fatal(
+					"Spanner session can not be refreshed, exiting after closing the connections still open after -drain-timeout",
+					zap.Error(err),
+				)
+			}
+			logger.Fatal(
+				"Spanner session can not be refreshed, exiting",
+				zap.Error(err),
+			)
+		},
+		ConnectionReportInterval: *connectionReportInterval,
+		StuckConnectionThreshold: *stuckConnectionThreshold,
+		PayloadAdvisorInterval:   *payloadAdvisorInterval,
+		StrictConsistency:        *strictConsistency,
+		StrictWriteTimestamps:    *strictWriteTimestamps,
+		WeakConsistencyStaleness: *weakConsistencyStaleness,
+		ReadYourWrites:           *readYourWrites,
+		EnableLatencyPayload:     *latencyPayload,
+		EnableRowCountPayload:    *rowCountPayload,
+		EnableBuiltInMetrics:     *builtInMetrics,
+		EnableStatementMetrics:   *statementMetrics,
+		MaxStatementFingerprints: *maxStatementFingerprints,
+		EnableBatchReprepare:     *batchReprepare,
+		StateRedisAddress:        *stateRedisAddress,
+		StateRedisPassword:       *stateRedisPassword,
+		TTLColumns:               expirationColumns,
+		JSONColumns:              jsonColumnTypes,
+		UserTypes:                userTypeFields,
+		ColumnCodecs:             columnCodecs,
+		EmulateFunctions:         *emulateFunctions,
+		EnableChangeStreams:      *changeStreams,
+		ChangeStreamReadTimeout:  *changeStreamReadTimeout,
+		WriteTimeColumns:         commitTimestampColumns,
+		CqlshCompatibility:       *cqlshCompat,
+		UnpagedReadPageSize:      *unpagedReadPageSize,
+		MaxBatchStatements:       *maxBatchStatements,
+		BatchSplitParallelism:    *batchSplitParallelism,
+		LoggedBatchAtomicity:     loggedBatchAtomicity,
+		UnloggedBatchAtomicity:   unloggedBatchAtomicity,
+		BatchDedupCacheSize:      *batchDedupCacheSize,
+		BatchDedupWindow:         *batchDedupWindow,
+		TopologyOverride:         topology,
+		SyntheticPeerCount:       *syntheticPeers,
+		MaxCommitDelay:           *maxCommitDelay,
+		StatementPolicy:          policy,
+		FullScanPolicy:           fullScanPolicy,
+		DirectedReadOptions:      directedReads,
+		SupportedAttachments:     attachments,
+		ReadCacheTTLs:            cacheTTLs,
+		CaptureFile:              *captureFile,
+		CaptureSampleRate:        *captureSampleRate,
+		SpannerEndpoint:          *spannerEndpoint,
+		FailoverEndpoints:        failover,
+		CandidateEndpoints:       candidates,
+		TableRouting:             tableDatabases,
+		Listeners:                listenerConfigs,
+		EnableDirectAccess:       *enableDirectAccess,
+		UsePlainText:             *usePlainText,
+		ExperimentalHost:         *experimentalHost,
+		CaCertificate:            *caCertificate,
+		ClientCertificate:        *clientCertificate,
+		ClientKey:                *clientKey,
+		SecretRefs:               secretRefs,
+	}
+
+	if *check {
+		var tables []string
+		if *checkTables != "" {
+			tables = strings.Split(*checkTables, ",")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), *checkTimeout)
+		report, err := spanner.Check(ctx, opts, tables)
+		cancel()
+		if err != nil {
+			fmt.Printf("Failed to run checks: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(report)
+		if !report.Passed() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Signals interrupt the startup of the proxy, ie: while it is resolving
+	// the credentials or reaching Spanner.
+	startCtx, stopStart := signal.NotifyContext(
+		context.Background(),
+		syscall.SIGINT,
+		syscall.SIGTERM,
+	)
+	cluster, err := spanner.NewClusterWithContext(startCtx, opts)
+	stopStart()
+	if err != nil {
+		fmt.Printf("Failed to initialize Spanner Cassandra Adapter: %v\n", err)
+		os.Exit(1)
 	}
 	defer spanner.CloseCluster(cluster)
 
+	addr, _ := spanner.ProxyAddr(cluster)
 	logger.Info(
 		"Spanner Cassandra Adapter created successfully",
 		zap.String("connected database", *databaseURI),
+		zap.Stringer("listening address", addr),
 	)
 
+	if *pidFile != "" {
+		if err := daemon.WritePIDFile(*pidFile); err != nil {
+			logger.Fatal("Failed to write PID file", zap.Error(err))
+		}
+		defer daemon.RemovePIDFile(*pidFile)
+	}
+	if err := daemon.Ready(); err != nil {
+		logger.Warn("Failed to report readiness", zap.Error(err))
+	}
+
 	sigchan := make(chan os.Signal, 1)
-	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1)
+	// The launcher can only be upgraded in place if the new launcher can
+	// listen on the same endpoint.
+	if *reusePort || inherited != nil {
+		signal.Notify(sigchan, syscall.SIGHUP)
+	}
 
-	<-sigchan
+	for sig := range sigchan {
+		if sig == syscall.SIGUSR1 {
+			// Report the stuck connections with the goroutine stacks.
+			report, _ := spanner.ConnectionReport(cluster, 0, true)
+			logger.Info("Spanner Cassandra Adapter connection report",
+				zap.Int("open_connections", report.OpenConnections),
+				zap.Any("stuck_connections", report.Stuck),
+				zap.String("goroutines", report.Stacks),
+			)
+			if *statementMetrics {
+				top, _ := spanner.TopStatements(cluster, topStatementsLogged)
+				logger.Info("Spanner Cassandra Adapter top statements",
+					zap.Any("statements", top),
+				)
+			}
+			continue
+		}
+		if sig != syscall.SIGHUP {
+			break
+		}
+		logger.Info("Upgrading Spanner Cassandra Adapter...")
+		ctx, cancel := context.WithTimeout(context.Background(), *upgradeTimeout)
+		process, err := daemon.Upgrade(ctx, inherited)
+		cancel()
+		if err != nil {
+			logger.Error("Failed to upgrade Spanner Cassandra Adapter", zap.Error(err))
+			continue
+		}
+		// Systemd tracks the new launcher as the main process of the service.
+		daemon.Notify(fmt.Sprintf("MAINPID=%d", process.Pid))
+		logger.Info(
+			"Spanner Cassandra Adapter upgraded, waiting for the open connections to close",
+			zap.Int("pid", process.Pid),
+			zap.Duration("shutdown_timeout", *shutdownTimeout),
+		)
+		ctx, cancel = context.WithTimeout(context.Background(), *shutdownTimeout)
+		spanner.ShutdownCluster(ctx, cluster)
+		cancel()
+		return
+	}
 
 	logger.Info("Shutting down Spanner Cassandra Adapter...")
 }
@@ -0,0 +1,209 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+migrate copies tables from a Cassandra cluster to a Spanner database through
+an in-process Spanner Cassandra adapter. The tables must already exist in
+Spanner, see cmd/schema-convert. Progress is checkpointed to a directory so
+that an interrupted copy resumes where it stopped when run again:
+
+	go run ./cmd/migrate -source 10.0.0.1,10.0.0.2 -keyspace shop \
+	  -tables orders,customers \
+	  -db projects/my-project/instances/my-instance/databases/shop \
+	  -checkpoint-dir ./checkpoints
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/gocql/gocql"
+	spanner "github.com/googleapis/go-spanner-cassandra/cassandra/gocql"
+	"github.com/googleapis/go-spanner-cassandra/cassandra/migrate"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+func main() {
+	source := flag.String(
+		"source",
+		"",
+		"Comma separated list of contact points of the source Cassandra cluster (required).",
+	)
+	sourceUser := flag.String(
+		"source-user",
+		"",
+		"User name of the source Cassandra cluster (optional). Default to empty.",
+	)
+	sourcePassword := flag.String(
+		"source-password",
+		"",
+		"Password of the source Cassandra cluster (optional). Default to empty.",
+	)
+	keyspace := flag.String(
+		"keyspace",
+		"",
+		"The keyspace of the source tables (required).",
+	)
+	tables := flag.String(
+		"tables",
+		"",
+		"Comma separated list of tables to copy (required).",
+	)
+	databaseURI := flag.String(
+		"db",
+		"",
+		"The Spanner database URI (required).",
+	)
+	splits := flag.Int(
+		"splits",
+		256,
+		"The number of token ranges each table is split into. Default to 256.",
+	)
+	parallelism := flag.Int(
+		"parallelism",
+		4,
+		"The number of token ranges copied concurrently. Default to 4.",
+	)
+	batchSize := flag.Int(
+		"batch-size",
+		100,
+		"The number of rows written per batch. Default to 100.",
+	)
+	rowsPerSecond := flag.Float64(
+		"rows-per-second",
+		0,
+		"The maximum number of rows written per second (optional). Default to 0 (unlimited).",
+	)
+	checkpointDir := flag.String(
+		"checkpoint-dir",
+		"",
+		"Directory the progress of each table is persisted to, to resume interrupted copies (optional). Default to empty (no checkpointing).",
+	)
+	logLevel := flag.String(
+		"log",
+		"info",
+		"Log level. Default to info.",
+	)
+	flag.Parse()
+
+	if *source == "" || *keyspace == "" || *tables == "" || *databaseURI == "" {
+		fmt.Println("Error: --source, --keyspace, --tables and --db are required")
+		flag.Usage()
+		os.Exit(1)
+	}
+	if *checkpointDir != "" {
+		if err := os.MkdirAll(*checkpointDir, 0o755); err != nil {
+			fmt.Printf("Failed to create checkpoint directory: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	sourceCluster := gocql.NewCluster(strings.Split(*source, ",")...)
+	if *sourceUser != "" {
+		sourceCluster.Authenticator = gocql.PasswordAuthenticator{
+			Username: *sourceUser,
+			Password: *sourcePassword,
+		}
+	}
+	sourceSession, err := sourceCluster.CreateSession()
+	if err != nil {
+		fmt.Printf("Failed to connect to the source cluster: %v\n", err)
+		os.Exit(1)
+	}
+	defer sourceSession.Close()
+
+	targetCluster, err := spanner.NewClusterWithError(&spanner.Options{
+		DatabaseUri: *databaseURI,
+		// Use an ephemeral port to not conflict with a local Cassandra.
+		TCPEndpoint: "localhost:0",
+		LogLevel:    *logLevel,
+	})
+	if err != nil {
+		fmt.Printf("Failed to initialize Spanner Cassandra Adapter: %v\n", err)
+		os.Exit(1)
+	}
+	defer spanner.CloseCluster(targetCluster)
+	// Spanner databases hold a single keyspace named after the database.
+	targetCluster.Keyspace = path.Base(*databaseURI)
+	targetSession, err := targetCluster.CreateSession()
+	if err != nil {
+		fmt.Printf("Failed to connect to Spanner: %v\n", err)
+		os.Exit(1)
+	}
+	defer targetSession.Close()
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(),
+		syscall.SIGINT,
+		syscall.SIGTERM,
+	)
+	defer stop()
+
+	failed := false
+	for _, table := range strings.Split(*tables, ",") {
+		checkpointFile := ""
+		if *checkpointDir != "" {
+			checkpointFile = filepath.Join(
+				*checkpointDir,
+				*keyspace+"."+table+".json",
+			)
+		}
+		progress, err := migrate.CopyTable(ctx, migrate.Options{
+			Source:         sourceSession,
+			Target:         targetSession,
+			Keyspace:       *keyspace,
+			Table:          table,
+			Splits:         *splits,
+			Parallelism:    *parallelism,
+			BatchSize:      *batchSize,
+			RowsPerSecond:  *rowsPerSecond,
+			CheckpointFile: checkpointFile,
+			OnProgress: func(p migrate.Progress) {
+				logger.Info("Copied token range",
+					zap.String("table", p.Table),
+					zap.Int("ranges_done", p.RangesDone),
+					zap.Int("ranges", p.Ranges),
+					zap.Int64("rows", p.Rows))
+			},
+		})
+		if err != nil {
+			logger.Error("Failed to copy table",
+				zap.String("table", table),
+				zap.Int64("rows", progress.Rows),
+				zap.Error(err))
+			failed = true
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		logger.Info("Copied table",
+			zap.String("table", table),
+			zap.Int64("rows", progress.Rows))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
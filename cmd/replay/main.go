@@ -0,0 +1,160 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+replay replays the requests captured by a Spanner Cassandra adapter started
+with -capture-file against another Spanner database, through an in-process
+adapter, or against any Cassandra compatible endpoint, at the captured pace or
+a multiple of it:
+
+	go run ./cmd/replay -file ./capture.jsonl \
+	  -db projects/my-project/instances/my-instance/databases/staging \
+	  -speed 2
+*/
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"syscall"
+
+	"github.com/gocql/gocql"
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+	spanner "github.com/googleapis/go-spanner-cassandra/cassandra/gocql"
+	"github.com/googleapis/go-spanner-cassandra/cassandra/replay"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"go.uber.org/zap"
+)
+
+func main() {
+	file := flag.String(
+		"file",
+		"",
+		"The capture file of the requests to replay (required).",
+	)
+	databaseURI := flag.String(
+		"db",
+		"",
+		"The Spanner database URI the requests are replayed against (required unless -target is set).",
+	)
+	target := flag.String(
+		"target",
+		"",
+		"Comma separated list of contact points of a Cassandra compatible endpoint the requests are replayed against instead of -db (optional). Default to empty.",
+	)
+	keyspace := flag.String(
+		"keyspace",
+		"",
+		"Keyspace of the -target sessions (optional). Default to empty.",
+	)
+	speed := flag.Float64(
+		"speed",
+		1,
+		"Pace of the replay relative to the captured traffic, ie: 2 to replay twice as fast (optional). Default to 1.",
+	)
+	unpaced := flag.Bool(
+		"unpaced",
+		false,
+		"Replay the requests as fast as -concurrency allows, regardless of their capture times (optional). Default to false.",
+	)
+	concurrency := flag.Int(
+		"concurrency",
+		64,
+		"The maximum number of requests replayed concurrently. Default to 64.",
+	)
+	logLevel := flag.String(
+		"log",
+		"info",
+		"Log level. Default to info.",
+	)
+	flag.Parse()
+
+	if *file == "" || (*databaseURI == "") == (*target == "") {
+		fmt.Println("Error: --file and one of --db or --target are required")
+		flag.Usage()
+		os.Exit(1)
+	}
+	records, err := os.Open(*file)
+	if err != nil {
+		fmt.Printf("Failed to open capture file: %v\n", err)
+		os.Exit(1)
+	}
+	defer records.Close()
+
+	var cluster *gocql.ClusterConfig
+	if *databaseURI != "" {
+		cluster, err = spanner.NewClusterWithError(&spanner.Options{
+			DatabaseUri: *databaseURI,
+			// Use an ephemeral port to not conflict with a local Cassandra.
+			TCPEndpoint: "localhost:0",
+			LogLevel:    *logLevel,
+		})
+		if err != nil {
+			fmt.Printf("Failed to initialize Spanner Cassandra Adapter: %v\n", err)
+			os.Exit(1)
+		}
+		defer spanner.CloseCluster(cluster)
+		// Spanner databases hold a single keyspace named after the database.
+		cluster.Keyspace = path.Base(*databaseURI)
+	} else {
+		if err := logger.SetupGlobalLogger(*logLevel); err != nil {
+			fmt.Printf("Failed to set up logging: %v\n", err)
+			os.Exit(1)
+		}
+		cluster = gocql.NewCluster(strings.Split(*target, ",")...)
+		cluster.Keyspace = *keyspace
+	}
+	session, err := cluster.CreateSession()
+	if err != nil {
+		fmt.Printf("Failed to connect to the target: %v\n", err)
+		os.Exit(1)
+	}
+	defer session.Close()
+
+	ctx, stop := signal.NotifyContext(
+		context.Background(),
+		syscall.SIGINT,
+		syscall.SIGTERM,
+	)
+	defer stop()
+
+	result, err := replay.Replay(ctx, replay.Options{
+		Session:     session,
+		Records:     records,
+		Speed:       *speed,
+		Unpaced:     *unpaced,
+		Concurrency: *concurrency,
+		OnError: func(rec *adapter.CaptureRecord, err error) {
+			logger.Debug("Failed to replay request",
+				zap.Time("captured", rec.Time),
+				zap.String("statement", logger.RedactStatement(rec.Statements[0].Query)),
+				zap.Error(err))
+		},
+	})
+	logger.Info("Replayed requests",
+		zap.Int64("requests", result.Requests),
+		zap.Int64("errors", result.Errors),
+		zap.Duration("elapsed", result.Elapsed))
+	if err != nil {
+		logger.Error("Replay stopped", zap.Error(err))
+		os.Exit(1)
+	}
+}
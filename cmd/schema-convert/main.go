@@ -0,0 +1,87 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+schema-convert converts a Cassandra schema to Spanner DDL with the
+cassandra_type column options used by the Spanner Cassandra adapter. It reads
+the output of DESCRIBE KEYSPACE or CQL files given as arguments, or the
+standard input if there are none, and writes the DDL to the standard output:
+
+	cqlsh -e "DESCRIBE KEYSPACE my_keyspace" | go run ./cmd/schema-convert
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/googleapis/go-spanner-cassandra/cassandra/schema"
+)
+
+func main() {
+	output := flag.String(
+		"o",
+		"",
+		"The file the DDL is written to (optional). Default to the standard output.",
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(
+			flag.CommandLine.Output(),
+			"Usage: %s [-o output.sql] [schema.cql ...]\n",
+			os.Args[0],
+		)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var readers []io.Reader
+	for _, path := range flag.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		// Files may not end with a semicolon, keep statements apart.
+		readers = append(readers, f, strings.NewReader(";\n"))
+	}
+	if len(readers) == 0 {
+		readers = append(readers, os.Stdin)
+	}
+
+	conversion, err := schema.Convert(io.MultiReader(readers...))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := os.Stdout
+	if *output != "" {
+		w, err = os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer w.Close()
+	}
+	if _, err := fmt.Fprint(w, conversion); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package encryption implements the client-side envelope encryption of
+// columns as an adapter.ColumnCodec: values are encrypted in the proxy before
+// they are sent to Spanner, and decrypted when they are read.
+//
+// Values are encrypted with AES-256-GCM by a data key, which is wrapped by a
+// key encryption key, ie: a Cloud KMS key, and stored with every value so that
+// values remain readable after the data key and the key encryption key are
+// rotated. The ciphertexts are bound to their column and stored base64
+// encoded, which requires text or blob columns.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// Version of the format of the ciphertexts.
+	formatVersion = 1
+	// Size in bytes of the AES-256 data keys.
+	dataKeySize = 32
+
+	defaultRotationPeriod = 24 * time.Hour
+	defaultCacheSize      = 1000
+	defaultTimeout        = 10 * time.Second
+)
+
+// KeyEncrypter wraps and unwraps data keys with a key encryption key, ie: a
+// Cloud KMS key. Implementations must be safe for concurrent use.
+type KeyEncrypter interface {
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Options configures a Codec.
+type Options struct {
+	// Optional period after which a new data key encrypts the values written.
+	// Defaults to 24h.
+	RotationPeriod time.Duration
+	// Optional number of unwrapped data keys kept in memory, which saves a
+	// call of the KeyEncrypter per value read. Defaults to 1000.
+	CacheSize int
+	// Optional timeout of the calls of the KeyEncrypter. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// dataKey is a data key along with its wrapped form stored with the values it
+// encrypts.
+type dataKey struct {
+	wrapped []byte
+	aead    cipher.AEAD
+	created time.Time
+}
+
+// Codec encrypts and decrypts the values of columns with envelope encryption.
+type Codec struct {
+	kek  KeyEncrypter
+	opts Options
+
+	mu      sync.Mutex
+	current *dataKey
+	// Unwrapped data keys by wrapped data key.
+	keys *lru.Cache
+}
+
+var _ adapter.ColumnCodec = (*Codec)(nil)
+
+// NewCodec returns a codec encrypting values with data keys wrapped by kek.
+func NewCodec(kek KeyEncrypter, opts Options) (*Codec, error) {
+	if kek == nil {
+		return nil, errors.New("encryption: nil key encrypter")
+	}
+	if opts.RotationPeriod <= 0 {
+		opts.RotationPeriod = defaultRotationPeriod
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = defaultCacheSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	keys, err := lru.New(opts.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Codec{kek: kek, opts: opts, keys: keys}, nil
+}
+
+// Register registers a codec encrypting values with data keys wrapped by kek
+// under name, to be referenced by Options.ColumnCodecs.
+func Register(name string, kek KeyEncrypter, opts Options) error {
+	codec, err := NewCodec(kek, opts)
+	if err != nil {
+		return err
+	}
+	adapter.RegisterColumnCodec(name, codec)
+	return nil
+}
+
+// associatedData binds ciphertexts to their column, so that they can not be
+// copied to another column.
+func associatedData(column adapter.ColumnRef) []byte {
+	return []byte(column.Keyspace + "." + column.Table + "." + column.Name)
+}
+
+// dataKey returns the data key encrypting the values written, which is
+// replaced once older than RotationPeriod.
+func (c *Codec) dataKey() (*dataKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil && time.Since(c.current.created) < c.opts.RotationPeriod {
+		return c.current, nil
+	}
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+	defer cancel()
+	wrapped, err := c.kek.Wrap(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	if len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("wrapped data key of %d bytes is too large", len(wrapped))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	c.current = &dataKey{wrapped: wrapped, aead: aead, created: time.Now()}
+	c.keys.Add(string(wrapped), aead)
+	return c.current, nil
+}
+
+// unwrap returns the cipher of a wrapped data key.
+func (c *Codec) unwrap(wrapped []byte) (cipher.AEAD, error) {
+	if aead, ok := c.keys.Get(string(wrapped)); ok {
+		return aead.(cipher.AEAD), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.Timeout)
+	defer cancel()
+	key, err := c.kek.Unwrap(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	c.keys.Add(string(wrapped), aead)
+	return aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encode encrypts a value of column. The ciphertext holds the format version,
+// the length of the wrapped data key, the wrapped data key, the nonce and the
+// sealed value, and is base64 encoded.
+func (c *Codec) Encode(column adapter.ColumnRef, value []byte) ([]byte, error) {
+	key, err := c.dataKey()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	buf := []byte{formatVersion}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(key.wrapped)))
+	buf = append(buf, key.wrapped...)
+	buf = append(buf, nonce...)
+	buf = key.aead.Seal(buf, nonce, value, associatedData(column))
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(buf)))
+	base64.StdEncoding.Encode(encoded, buf)
+	return encoded, nil
+}
+
+// Decode decrypts a value of column encrypted by Encode, with any data key.
+func (c *Codec) Decode(column adapter.ColumnRef, value []byte) ([]byte, error) {
+	buf := make([]byte, base64.StdEncoding.DecodedLen(len(value)))
+	n, err := base64.StdEncoding.Decode(buf, value)
+	if err != nil {
+		return nil, fmt.Errorf("malformed ciphertext: %w", err)
+	}
+	buf = buf[:n]
+	if len(buf) < 3 || buf[0] != formatVersion {
+		return nil, errors.New("malformed ciphertext")
+	}
+	size := int(binary.BigEndian.Uint16(buf[1:]))
+	buf = buf[3:]
+	if len(buf) < size {
+		return nil, errors.New("malformed ciphertext")
+	}
+	aead, err := c.unwrap(buf[:size])
+	if err != nil {
+		return nil, err
+	}
+	buf = buf[size:]
+	if len(buf) < aead.NonceSize() {
+		return nil, errors.New("malformed ciphertext")
+	}
+	nonce, sealed := buf[:aead.NonceSize()], buf[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, associatedData(column))
+}
+
+// Rotate makes the next value written use a new data key, ie: after the key
+// encryption key was rotated.
+func (c *Codec) Rotate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = nil
+}
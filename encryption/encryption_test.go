@@ -0,0 +1,133 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKeyEncrypter wraps data keys by prefixing them, and counts its calls.
+type fakeKeyEncrypter struct {
+	wraps   atomic.Int32
+	unwraps atomic.Int32
+}
+
+func (f *fakeKeyEncrypter) Wrap(_ context.Context, dataKey []byte) ([]byte, error) {
+	f.wraps.Add(1)
+	return append([]byte("wrapped:"), dataKey...), nil
+}
+
+func (f *fakeKeyEncrypter) Unwrap(_ context.Context, wrapped []byte) ([]byte, error) {
+	f.unwraps.Add(1)
+	if !bytes.HasPrefix(wrapped, []byte("wrapped:")) {
+		return nil, errors.New("unknown key")
+	}
+	return wrapped[len("wrapped:"):], nil
+}
+
+var column = adapter.ColumnRef{Keyspace: "ks", Table: "users", Name: "ssn"}
+
+func TestCodec(t *testing.T) {
+	kek := &fakeKeyEncrypter{}
+	codec, err := NewCodec(kek, Options{})
+	require.NoError(t, err)
+
+	encrypted, err := codec.Encode(column, []byte("123-45-6789"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "123-45-6789")
+	_, err = base64.StdEncoding.DecodeString(string(encrypted))
+	assert.NoError(t, err)
+
+	decrypted, err := codec.Decode(column, encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("123-45-6789"), decrypted)
+
+	// The data key is wrapped once, and known to the codec that wrapped it.
+	_, err = codec.Encode(column, []byte("987-65-4321"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, kek.wraps.Load())
+	assert.EqualValues(t, 0, kek.unwraps.Load())
+
+	// Other codecs unwrap the data key once.
+	other, err := NewCodec(kek, Options{})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		decrypted, err = other.Decode(column, encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("123-45-6789"), decrypted)
+	}
+	assert.EqualValues(t, 1, kek.unwraps.Load())
+
+	// Ciphertexts can not be moved to other columns, nor be tampered with.
+	_, err = codec.Decode(adapter.ColumnRef{Keyspace: "ks", Table: "users", Name: "name"}, encrypted)
+	assert.Error(t, err)
+	raw, err := base64.StdEncoding.DecodeString(string(encrypted))
+	require.NoError(t, err)
+	raw[len(raw)-1] ^= 1
+	_, err = codec.Decode(column, []byte(base64.StdEncoding.EncodeToString(raw)))
+	assert.Error(t, err)
+	_, err = codec.Decode(column, []byte("plaintext"))
+	assert.Error(t, err)
+}
+
+func TestCodecRotation(t *testing.T) {
+	kek := &fakeKeyEncrypter{}
+	codec, err := NewCodec(kek, Options{RotationPeriod: time.Millisecond})
+	require.NoError(t, err)
+
+	first, err := codec.Encode(column, []byte("a"))
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	second, err := codec.Encode(column, []byte("b"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, kek.wraps.Load())
+
+	codec.Rotate()
+	_, err = codec.Encode(column, []byte("c"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, kek.wraps.Load())
+
+	// Values encrypted with previous data keys remain readable.
+	for encrypted, want := range map[string]string{string(first): "a", string(second): "b"} {
+		decrypted, err := codec.Decode(column, []byte(encrypted))
+		require.NoError(t, err)
+		assert.Equal(t, []byte(want), decrypted)
+	}
+}
+
+func TestNewCodec(t *testing.T) {
+	_, err := NewCodec(nil, Options{})
+	assert.Error(t, err)
+
+	require.NoError(t, Register("test-kms", &fakeKeyEncrypter{}, Options{}))
+	codec, ok := adapter.LookupColumnCodec("test-kms")
+	assert.True(t, ok)
+	assert.IsType(t, &Codec{}, codec)
+}
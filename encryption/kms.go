@@ -0,0 +1,72 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+
+	cloudkms "google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+)
+
+// kmsKeyEncrypter wraps data keys with a symmetric Cloud KMS key.
+type kmsKeyEncrypter struct {
+	keys    *cloudkms.ProjectsLocationsKeyRingsCryptoKeysService
+	keyName string
+}
+
+// NewKMSKeyEncrypter returns a KeyEncrypter wrapping data keys with the
+// symmetric Cloud KMS key keyName, ie:
+// projects/p/locations/l/keyRings/r/cryptoKeys/k. The data keys wrapped by any
+// enabled version of the key can be unwrapped, so that the key can be rotated
+// in Cloud KMS. The credentials need the cloudkms.cryptoKeyVersions.useToEncrypt
+// and useToDecrypt permissions.
+func NewKMSKeyEncrypter(
+	ctx context.Context,
+	keyName string,
+	opts ...option.ClientOption,
+) (KeyEncrypter, error) {
+	service, err := cloudkms.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &kmsKeyEncrypter{
+		keys:    service.Projects.Locations.KeyRings.CryptoKeys,
+		keyName: keyName,
+	}, nil
+}
+
+func (k *kmsKeyEncrypter) Wrap(ctx context.Context, dataKey []byte) ([]byte, error) {
+	resp, err := k.keys.Encrypt(k.keyName, &cloudkms.EncryptRequest{
+		Plaintext: base64.StdEncoding.EncodeToString(dataKey),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Ciphertext)
+}
+
+func (k *kmsKeyEncrypter) Unwrap(ctx context.Context, wrapped []byte) ([]byte, error) {
+	resp, err := k.keys.Decrypt(k.keyName, &cloudkms.DecryptRequest{
+		Ciphertext: base64.StdEncoding.EncodeToString(wrapped),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
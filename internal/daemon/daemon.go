@@ -0,0 +1,193 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package daemon runs the launcher as a long lived service: it accepts a
+// listener inherited with systemd socket activation, maintains a PID file,
+// and upgrades the launcher in place by re-executing its binary.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	// First file descriptor passed by systemd socket activation.
+	listenFdsStart = 3
+	// Environment variable holding the file descriptor of the listener
+	// handed over by the launcher upgrading in place.
+	listenFdEnv = "SPANNER_CASSANDRA_LISTEN_FD"
+	// Environment variable holding the file descriptor the new launcher
+	// reports its readiness on during an upgrade.
+	readyFdEnv = "SPANNER_CASSANDRA_READY_FD"
+)
+
+// Listener returns the listener inherited from systemd socket activation
+// (LISTEN_PID and LISTEN_FDS) or handed over by the launcher upgrading in
+// place, or nil if there is none. Only the first socket of systemd is used.
+func Listener() (net.Listener, error) {
+	fd := -1
+	if v := os.Getenv(listenFdEnv); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", listenFdEnv, v)
+		}
+		fd = n
+	} else if os.Getenv("LISTEN_PID") == strconv.Itoa(os.Getpid()) {
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+		}
+		fd = listenFdsStart
+	}
+	// The environment is not inherited by the processes started later.
+	os.Unsetenv(listenFdEnv)
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if fd < 0 {
+		return nil, nil
+	}
+	file := os.NewFile(uintptr(fd), "listener")
+	defer file.Close()
+	lis, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use inherited listener: %w", err)
+	}
+	return lis, nil
+}
+
+// WritePIDFile writes the PID of the process to path.
+func WritePIDFile(path string) error {
+	return os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+}
+
+// RemovePIDFile removes the PID file at path, unless it was overwritten by
+// another process, ie: the launcher which took over after an upgrade.
+func RemovePIDFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(content)) != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// Ready reports that the launcher serves connections to the launcher which
+// started it during an upgrade, and to systemd with Type=notify services.
+func Ready() error {
+	if v := os.Getenv(readyFdEnv); v != "" {
+		os.Unsetenv(readyFdEnv)
+		fd, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q", readyFdEnv, v)
+		}
+		pipe := os.NewFile(uintptr(fd), "ready")
+		_, err = pipe.Write([]byte{1})
+		pipe.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return Notify("READY=1")
+}
+
+// Notify sends state to systemd (sd_notify), ie: "READY=1". It does nothing
+// outside of systemd services.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Upgrade starts a new launcher from the binary of the running one, with the
+// same arguments, and waits until it reports its readiness or ctx is done.
+// lis, if not nil, is handed over to the new launcher; otherwise the new
+// launcher must listen on the same endpoints with SO_REUSEPORT. The new
+// launcher is killed if it does not become ready.
+func Upgrade(ctx context.Context, lis net.Listener) (*os.Process, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	ready, readyWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer ready.Close()
+	cmd := exec.Command(path, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{readyWriter}
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", readyFdEnv, listenFdsStart))
+	if lis != nil {
+		filer, ok := lis.(interface{ File() (*os.File, error) })
+		if !ok {
+			readyWriter.Close()
+			return nil, fmt.Errorf("listener %T can not be handed over", lis)
+		}
+		file, err := filer.File()
+		if err != nil {
+			readyWriter.Close()
+			return nil, err
+		}
+		defer file.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, file)
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%d", listenFdEnv, listenFdsStart+1))
+	}
+	err = cmd.Start()
+	readyWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// The pipe is closed without a byte if the new launcher exits before
+	// being ready.
+	readyc := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		_, err := ready.Read(b)
+		readyc <- err
+	}()
+	select {
+	case err = <-readyc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("new launcher did not become ready: %w", err)
+	}
+	// The new launcher is re-parented once this one exits.
+	go cmd.Wait()
+	return cmd.Process, nil
+}
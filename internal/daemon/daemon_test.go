@@ -0,0 +1,71 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "launcher.pid")
+	require.NoError(t, WritePIDFile(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(content))
+	require.NoError(t, RemovePIDFile(path))
+	assert.NoFileExists(t, path)
+
+	// PID files overwritten by another launcher are left in place.
+	require.NoError(t, os.WriteFile(path, []byte("1\n"), 0644))
+	require.NoError(t, RemovePIDFile(path))
+	assert.FileExists(t, path)
+}
+
+func TestListener(t *testing.T) {
+	lis, err := Listener()
+	require.NoError(t, err)
+	assert.Nil(t, lis)
+
+	tcp, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer file.Close()
+
+	t.Setenv(listenFdEnv, strconv.Itoa(int(file.Fd())))
+	lis, err = Listener()
+	require.NoError(t, err)
+	defer lis.Close()
+	assert.Equal(t, tcp.Addr().String(), lis.Addr().String())
+	assert.Empty(t, os.Getenv(listenFdEnv))
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "0")
+	_, err = Listener()
+	assert.Error(t, err)
+}
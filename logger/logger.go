@@ -30,7 +30,8 @@ import (
 )
 
 var (
-	zapLog *zap.Logger
+	// zapLog discards all logs until SetupGlobalLogger is called.
+	zapLog = zap.NewNop()
 	codec  = frame.NewCodec()
 )
 
@@ -70,6 +71,10 @@ func Debug(message string, fields ...zap.Field) {
 	zapLog.Debug(message, fields...)
 }
 
+func Warn(message string, fields ...zap.Field) {
+	zapLog.Warn(message, fields...)
+}
+
 func Error(message string, fields ...zap.Field) {
 	zapLog.Error(message, fields...)
 }
@@ -78,6 +83,13 @@ func Fatal(message string, fields ...zap.Field) {
 	zapLog.Fatal(message, fields...)
 }
 
+// DebugEnabled reports whether debug logs are emitted.
+func DebugEnabled() bool {
+	return zapLog.Core().Enabled(zapcore.DebugLevel)
+}
+
+// DumpRequest logs the decoded frame of req, redacted according to the
+// redaction policy.
 func DumpRequest(req *adapterpb.AdaptMessageRequest) error {
 	frame, err := codec.DecodeFrame(bytes.NewBuffer(req.Payload))
 	if err != nil {
@@ -86,11 +98,16 @@ func DumpRequest(req *adapterpb.AdaptMessageRequest) error {
 	}
 	zapLog.Debug(
 		"Sent AdaptMessageRequest: ",
-		zap.String("decoded frame", frame.Body.String()),
+		zap.String(
+			"decoded frame",
+			redactionPolicy.Load().describe(frame.Body.Message),
+		),
 	)
 	return nil
 }
 
+// DumpResponse logs the decoded frame of resp, redacted according to the
+// redaction policy.
 func DumpResponse(resp *adapterpb.AdaptMessageResponse) error {
 	frame, err := codec.DecodeFrame(bytes.NewBuffer(resp.Payload))
 	if err != nil {
@@ -99,7 +116,10 @@ func DumpResponse(resp *adapterpb.AdaptMessageResponse) error {
 	}
 	zapLog.Debug(
 		"Received AdaptMessageResponse: ",
-		zap.String("decoded frame", frame.Body.String()),
+		zap.String(
+			"decoded frame",
+			redactionPolicy.Load().describe(frame.Body.Message),
+		),
 	)
 	return nil
 }
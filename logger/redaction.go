@@ -0,0 +1,263 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+)
+
+// RedactionMode controls how bound values and statement literals are rendered
+// when frames are logged.
+type RedactionMode int
+
+const (
+	// RedactNone logs frames verbatim.
+	RedactNone RedactionMode = iota
+	// RedactHash replaces values and literals with a short SHA-256 hash, so
+	// that equal values can still be correlated across log entries.
+	RedactHash
+	// RedactDrop replaces values and literals with a fixed placeholder.
+	RedactDrop
+)
+
+// Placeholder logged in place of dropped values.
+const redactedPlaceholder = "<redacted>"
+
+// RedactionPolicy configures the redaction applied to frame contents before
+// they are logged.
+type RedactionPolicy struct {
+	// How values and literals are redacted. Defaults to RedactNone.
+	Mode RedactionMode
+	// Optional list of tables (ie: "keyspace.table" or "table") whose
+	// statements, values and rows are logged verbatim regardless of Mode.
+	AllowedTables []string
+}
+
+var (
+	redactionPolicy atomic.Pointer[RedactionPolicy]
+
+	literalPattern = regexp.MustCompile(
+		`'(?:[^']|'')*'|\$\$.*?\$\$|\b0[xX][0-9a-fA-F]*\b|` +
+			`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b|` +
+			`-?\b\d+(?:\.\d+)?(?:[eE][+-]?\d+)?\b`,
+	)
+	statementTablePattern = regexp.MustCompile(
+		`(?i)\b(?:from|into|update)\s+([\w."]+)`,
+	)
+)
+
+func init() {
+	redactionPolicy.Store(&RedactionPolicy{})
+}
+
+// SetRedactionPolicy sets the redaction policy applied to logged frames.
+func SetRedactionPolicy(policy RedactionPolicy) {
+	redactionPolicy.Store(&policy)
+}
+
+// RedactStatement renders a statement for logging, with its literals redacted
+// according to the redaction policy unless its table is allowed.
+func RedactStatement(statement string) string {
+	p := redactionPolicy.Load()
+	if p.Mode == RedactNone || p.allowsStatement(statement) {
+		return statement
+	}
+	return p.redactStatement(statement)
+}
+
+// ParseRedactionMode parses a redaction mode name (none, hash or drop).
+func ParseRedactionMode(mode string) (RedactionMode, error) {
+	switch strings.ToLower(mode) {
+	case "", "none":
+		return RedactNone, nil
+	case "hash":
+		return RedactHash, nil
+	case "drop":
+		return RedactDrop, nil
+	default:
+		return RedactNone, fmt.Errorf("invalid log redaction mode '%s'", mode)
+	}
+}
+
+// allows reports whether the policy logs the given table verbatim.
+func (p *RedactionPolicy) allows(keyspace, table string) bool {
+	keyspace = strings.ToLower(keyspace)
+	table = strings.ToLower(table)
+	for _, t := range p.AllowedTables {
+		t = strings.ToLower(t)
+		if t == table || (keyspace != "" && t == keyspace+"."+table) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsStatement reports whether the policy logs the given statement
+// verbatim.
+func (p *RedactionPolicy) allowsStatement(statement string) bool {
+	m := statementTablePattern.FindStringSubmatch(statement)
+	if m == nil {
+		return false
+	}
+	target := strings.ToLower(strings.ReplaceAll(m[1], `"`, ""))
+	keyspace, table := "", target
+	if i := strings.LastIndex(target, "."); i >= 0 {
+		keyspace, table = target[:i], target[i+1:]
+	}
+	return p.allows(keyspace, table)
+}
+
+func (p *RedactionPolicy) redactBytes(value []byte) string {
+	if value == nil {
+		return "null"
+	}
+	if p.Mode == RedactDrop {
+		return redactedPlaceholder
+	}
+	sum := sha256.Sum256(value)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+func (p *RedactionPolicy) redactStatement(statement string) string {
+	return literalPattern.ReplaceAllStringFunc(statement, func(literal string) string {
+		return p.redactBytes([]byte(literal))
+	})
+}
+
+func (p *RedactionPolicy) formatValues(
+	options *message.QueryOptions,
+	verbatim bool,
+) string {
+	if options == nil {
+		return "[]"
+	}
+	if len(options.NamedValues) > 0 {
+		names := make([]string, 0, len(options.NamedValues))
+		for name := range options.NamedValues {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		values := make([]string, 0, len(names))
+		for _, name := range names {
+			values = append(
+				values,
+				name+"="+p.formatValue(options.NamedValues[name], verbatim),
+			)
+		}
+		return "[" + strings.Join(values, " ") + "]"
+	}
+	return p.formatPositional(options.PositionalValues, verbatim)
+}
+
+func (p *RedactionPolicy) formatPositional(
+	positional []*primitive.Value,
+	verbatim bool,
+) string {
+	values := make([]string, 0, len(positional))
+	for _, v := range positional {
+		values = append(values, p.formatValue(v, verbatim))
+	}
+	return "[" + strings.Join(values, " ") + "]"
+}
+
+func (p *RedactionPolicy) formatValue(v *primitive.Value, verbatim bool) string {
+	if v == nil || v.Type != primitive.ValueTypeRegular {
+		return "null"
+	}
+	if verbatim {
+		return fmt.Sprintf("%x", v.Contents)
+	}
+	return p.redactBytes(v.Contents)
+}
+
+// describe renders msg for logging, redacting user data according to the
+// policy.
+func (p *RedactionPolicy) describe(msg message.Message) string {
+	if p.Mode == RedactNone {
+		return fmt.Sprint(msg)
+	}
+	switch m := msg.(type) {
+	case *message.Query:
+		if p.allowsStatement(m.Query) {
+			return fmt.Sprintf("QUERY %q values=%s", m.Query, p.formatValues(m.Options, true))
+		}
+		return fmt.Sprintf(
+			"QUERY %q values=%s",
+			p.redactStatement(m.Query),
+			p.formatValues(m.Options, false),
+		)
+	case *message.Prepare:
+		if p.allowsStatement(m.Query) {
+			return fmt.Sprintf("PREPARE %q", m.Query)
+		}
+		return fmt.Sprintf("PREPARE %q", p.redactStatement(m.Query))
+	case *message.Execute:
+		return fmt.Sprintf(
+			"EXECUTE id=%x values=%s",
+			m.QueryId,
+			p.formatValues(m.Options, false),
+		)
+	case *message.Batch:
+		children := make([]string, 0, len(m.Children))
+		for _, child := range m.Children {
+			if child.Query == "" {
+				children = append(children, fmt.Sprintf(
+					"id=%x values=%s", child.Id, p.formatPositional(child.Values, false),
+				))
+				continue
+			}
+			verbatim := p.allowsStatement(child.Query)
+			query := child.Query
+			if !verbatim {
+				query = p.redactStatement(query)
+			}
+			children = append(children, fmt.Sprintf(
+				"%q values=%s", query, p.formatPositional(child.Values, verbatim),
+			))
+		}
+		return fmt.Sprintf("BATCH %v [%s]", m.Type, strings.Join(children, ", "))
+	case *message.RowsResult:
+		verbatim := false
+		if m.Metadata != nil && len(m.Metadata.Columns) > 0 {
+			col := m.Metadata.Columns[0]
+			verbatim = p.allows(col.Keyspace, col.Table)
+		}
+		if verbatim {
+			return fmt.Sprint(msg)
+		}
+		rows := make([]string, 0, len(m.Data))
+		for _, row := range m.Data {
+			cols := make([]string, 0, len(row))
+			for _, col := range row {
+				cols = append(cols, p.redactBytes(col))
+			}
+			rows = append(rows, "["+strings.Join(cols, " ")+"]")
+		}
+		return fmt.Sprintf("RESULT ROWS rows=[%s]", strings.Join(rows, ", "))
+	default:
+		return fmt.Sprint(msg)
+	}
+}
@@ -0,0 +1,108 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logger
+
+import (
+	"testing"
+
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRedactionMode(t *testing.T) {
+	for input, want := range map[string]RedactionMode{
+		"":     RedactNone,
+		"none": RedactNone,
+		"HASH": RedactHash,
+		"drop": RedactDrop,
+	} {
+		got, err := ParseRedactionMode(input)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseRedactionMode("mask")
+	assert.Error(t, err)
+}
+
+func TestDescribeRedactsQuery(t *testing.T) {
+	query := &message.Query{
+		Query: "SELECT * FROM ks.users WHERE email = 'jane@example.com' AND age = ?",
+		Options: &message.QueryOptions{
+			PositionalValues: []*primitive.Value{
+				primitive.NewValue([]byte("secret")),
+			},
+		},
+	}
+
+	dropped := (&RedactionPolicy{Mode: RedactDrop}).describe(query)
+	assert.NotContains(t, dropped, "jane@example.com")
+	assert.NotContains(t, dropped, "736563726574") // hex of "secret"
+	assert.Contains(t, dropped, redactedPlaceholder)
+
+	hashed := (&RedactionPolicy{Mode: RedactHash}).describe(query)
+	assert.NotContains(t, hashed, "jane@example.com")
+	assert.Contains(t, hashed, "sha256:")
+	// Equal values hash to the same digest.
+	assert.Equal(t, hashed, (&RedactionPolicy{Mode: RedactHash}).describe(query))
+
+	allowed := (&RedactionPolicy{
+		Mode:          RedactDrop,
+		AllowedTables: []string{"ks.users"},
+	}).describe(query)
+	assert.Contains(t, allowed, "jane@example.com")
+	assert.Contains(t, allowed, "736563726574")
+}
+
+func TestDescribeRedactsRows(t *testing.T) {
+	rows := &message.RowsResult{
+		Metadata: &message.RowsMetadata{
+			ColumnCount: 1,
+			Columns: []*message.ColumnMetadata{
+				{Keyspace: "ks", Table: "users", Name: "email"},
+			},
+		},
+		Data: message.RowSet{{[]byte("jane@example.com")}},
+	}
+	described := (&RedactionPolicy{Mode: RedactDrop}).describe(rows)
+	assert.NotContains(t, described, "jane@example.com")
+	assert.Contains(t, described, redactedPlaceholder)
+
+	// Unqualified allowed tables match the table in any keyspace.
+	assert.True(t, (&RedactionPolicy{AllowedTables: []string{"users"}}).allows("ks", "users"))
+	assert.False(t, (&RedactionPolicy{AllowedTables: []string{"other.users"}}).allows("ks", "users"))
+}
+
+func TestRedactStatement(t *testing.T) {
+	defer SetRedactionPolicy(RedactionPolicy{})
+	statement := "UPDATE ks.users SET email = 'jane@example.com' WHERE id = ?"
+
+	assert.Equal(t, statement, RedactStatement(statement))
+
+	SetRedactionPolicy(RedactionPolicy{Mode: RedactDrop})
+	assert.Equal(
+		t,
+		"UPDATE ks.users SET email = "+redactedPlaceholder+" WHERE id = ?",
+		RedactStatement(statement),
+	)
+
+	SetRedactionPolicy(RedactionPolicy{Mode: RedactDrop, AllowedTables: []string{"users"}})
+	assert.Equal(t, statement, RedactStatement(statement))
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mongo implements a thin proxy for MongoDB wire protocol <-> gRPC
+// Spanner.
+package mongo
+
+import (
+	"strings"
+
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+	"github.com/googleapis/go-spanner-cassandra/logger"
+	"google.golang.org/api/option"
+)
+
+// Options represents the configuration for a local MongoDB proxy to Spanner.
+type Options struct {
+	// Optional Spanner service endpoint. Defaults to spanner.googleapis.com:443
+	SpannerEndpoint string
+	// Optional Endpoint to start TCP server. Defaults to localhost:27017
+	TCPEndpoint string
+	// Required database uri to connect to.
+	DatabaseUri string
+	// Number of channels when dial grpc connection. Defaults to 4.
+	NumGrpcChannels int
+	// Optional boolean indicate whether to disable automatic grpc retry for
+	// AdaptMessage API. Defauls to false.
+	DisableAdaptMessageRetry bool
+	// Optional labels (ie: end user or tenant id) forwarded as
+	// x-goog-spanner-label-<key> metadata headers with every request. Keys must
+	// be lower case. Defaults to empty.
+	ConnectionLabels map[string]string
+	// Optional log level. Defaults to info.
+	LogLevel string
+	// Optional google api opts. Default to empty.
+	GoogleApiOpts []option.ClientOption
+	// Optional boolean indicate whether to use plain-text connection.
+	// Defaults to false.
+	UsePlainText bool
+	// Optional boolean indicate whether spanner endpoint is a Experimental Host instance
+	ExperimentalHost bool
+	// Optional string CA certificate file path for establishing tls connection
+	CaCertificate string
+	// Optional string client certificate file path for establishing mTLS connection
+	ClientCertificate string
+	// Optional string client key file path for establishing mTLS connection
+	ClientKey string
+}
+
+// NewProxy starts a local proxy that MongoDB drivers can connect to, ie:
+//
+//	proxy, err := mongo.NewProxy(opts)
+//	client, err := mongodriver.Connect(ctx, options.Client().ApplyURI(
+//		"mongodb://"+proxy.Addr().String()+"/?directConnection=true",
+//	))
+//
+// Close the proxy once the clients are disconnected.
+func NewProxy(opts *Options) (*adapter.TCPProxy, error) {
+	// Initialize a global logger with default INFO log level
+	err := logger.SetupGlobalLogger(opts.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ExperimentalHost && !strings.Contains(opts.DatabaseUri, "/") {
+		opts.DatabaseUri = "projects/default/instances/default/databases/" + opts.DatabaseUri
+	}
+	tcpEndpoint := opts.TCPEndpoint
+	if tcpEndpoint == "" {
+		tcpEndpoint = "localhost:27017"
+	}
+	return adapter.NewTCPProxy(
+		adapter.Options{
+			DatabaseUri:              opts.DatabaseUri,
+			SpannerEndpoint:          opts.SpannerEndpoint,
+			TCPEndpoint:              tcpEndpoint,
+			Protocol:                 &mongoProtocol{},
+			NumGrpcChannels:          opts.NumGrpcChannels,
+			DisableAdaptMessageRetry: opts.DisableAdaptMessageRetry,
+			ConnectionLabels:         opts.ConnectionLabels,
+			GoogleApiOpts:            opts.GoogleApiOpts,
+			UsePlainText:             opts.UsePlainText,
+			ExperimentalHost:         opts.ExperimentalHost,
+			CaCertificate:            opts.CaCertificate,
+			ClientCertificate:        opts.ClientCertificate,
+			ClientKey:                opts.ClientKey,
+		},
+	)
+}
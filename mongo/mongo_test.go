@@ -0,0 +1,130 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// newOpMsg returns an OP_MSG whose body section holds a {<command>: <collection>}
+// document, preceded by an empty document sequence.
+func newOpMsg(requestId int32, command, collection string) []byte {
+	var body []byte
+	body = append(body, make([]byte, 4)...) // flagBits
+	body = append(body, sectionSequence)
+	body = binary.LittleEndian.AppendUint32(body, uint32(4+len("documents")+1))
+	body = append(body, "documents\x00"...)
+	body = append(body, sectionBody)
+	body = append(body, encodeDocument(bsonElement{bsonString, command, collection})...)
+
+	msg := binary.LittleEndian.AppendUint32(nil, uint32(headerLength+len(body)))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(requestId))
+	msg = binary.LittleEndian.AppendUint32(msg, 0)
+	msg = binary.LittleEndian.AppendUint32(msg, opMsg)
+	return append(msg, body...)
+}
+
+func TestReadRequest(t *testing.T) {
+	p := &mongoProtocol{}
+	insert := newOpMsg(1, "insert", "users")
+	find := newOpMsg(2, "find", "users")
+	r := bufio.NewReader(bytes.NewReader(append(append([]byte{}, insert...), find...)))
+
+	req, err := p.ReadRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, insert, req)
+	assert.Equal(t, "insert", commandName(req))
+	assert.True(t, p.IsDML(req))
+
+	req, err = p.ReadRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, "find", commandName(req))
+	assert.False(t, p.IsDML(req))
+
+	invalid := binary.LittleEndian.AppendUint32(nil, 4)
+	_, err = p.ReadRequest(bufio.NewReader(bytes.NewReader(append(invalid, make([]byte, 12)...))))
+	assert.Error(t, err)
+
+	// Truncated messages are not classified.
+	assert.Equal(t, "", commandName(insert[:headerLength+6]))
+}
+
+func TestErrorResponse(t *testing.T) {
+	p := &mongoProtocol{}
+	reply := p.ErrorResponse(
+		newOpMsg(7, "find", "users"),
+		status.Error(codes.NotFound, "table not found"),
+	)
+	assert.Equal(t, uint32(len(reply)), binary.LittleEndian.Uint32(reply))
+	assert.Equal(t, uint32(7), binary.LittleEndian.Uint32(reply[8:]))
+	assert.Equal(t, uint32(opMsg), binary.LittleEndian.Uint32(reply[12:]))
+	assert.Equal(t, "ok", firstKey(reply[headerLength+5:]))
+	assert.Contains(t, string(reply), "table not found")
+}
+
+func TestNewProxy(t *testing.T) {
+	t.Cleanup(adapter.ResetGrpcFuncs())
+	adapter.MockCreateSessionGrpc()
+	adapter.MockPayloadAdaptMessageGrpc(
+		"mongodb",
+		func(payload []byte) ([]byte, error) {
+			if commandName(payload) == "find" {
+				return nil, status.Error(codes.NotFound, "table not found")
+			}
+			return newOpMsg(100, "ok", "1"), nil
+		},
+	)
+	proxy, err := NewProxy(&Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		TCPEndpoint:   "localhost:0",
+		GoogleApiOpts: adapter.SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	p := &mongoProtocol{}
+
+	_, err = conn.Write(newOpMsg(1, "insert", "users"))
+	require.NoError(t, err)
+	reply, err := p.ReadRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, newOpMsg(100, "ok", "1"), reply)
+
+	_, err = conn.Write(newOpMsg(2, "find", "users"))
+	require.NoError(t, err)
+	reply, err = p.ReadRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(reply[8:]))
+	assert.Contains(t, string(reply), "table not found")
+}
@@ -0,0 +1,271 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mongo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync/atomic"
+
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+)
+
+const (
+	// Length of the standard message header.
+	headerLength = 16
+	// Maximum message size accepted by MongoDB servers.
+	maxMessageSize = 48 * 1000 * 1000
+
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+
+	// Section kinds of OP_MSG.
+	sectionBody     = 0
+	sectionSequence = 1
+	// OP_MSG flag indicating a trailing CRC-32C checksum.
+	flagChecksumPresent = 1 << 0
+	// OP_REPLY flag indicating a failed query.
+	flagQueryFailure = 1 << 1
+
+	// BSON element types.
+	bsonDouble = 0x01
+	bsonString = 0x02
+	bsonInt32  = 0x10
+
+	// Generic MongoDB error code used for failures returned by Spanner.
+	internalErrorCode = 1
+)
+
+// Commands which modify data and are routed to the leader.
+var writeCommands = map[string]bool{
+	"bulkwrite": true, "create": true, "createindexes": true, "delete": true,
+	"drop": true, "dropdatabase": true, "dropindexes": true,
+	"findandmodify": true, "insert": true, "update": true,
+}
+
+// Request ids of replies generated by the proxy.
+var nextRequestId atomic.Int32
+
+// mongoProtocol implements the MongoDB wire protocol.
+type mongoProtocol struct {
+}
+
+func init() {
+	adapter.RegisterProtocol(&mongoProtocol{})
+}
+
+func (p *mongoProtocol) Name() string {
+	return "mongodb"
+}
+
+// ReadRequest reads a complete message, including its header.
+func (p *mongoProtocol) ReadRequest(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, headerLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(int32(binary.LittleEndian.Uint32(header)))
+	if length < headerLength || length > maxMessageSize {
+		return nil, fmt.Errorf("invalid mongodb message length %d", length)
+	}
+	msg := make([]byte, length)
+	copy(msg, header)
+	if _, err := io.ReadFull(r, msg[headerLength:]); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// IsDML reports whether the command of the request modifies data.
+func (p *mongoProtocol) IsDML(request []byte) bool {
+	return writeCommands[strings.ToLower(commandName(request))]
+}
+
+// Attachments returns no attachments, requests are self-contained.
+func (p *mongoProtocol) Attachments(
+	_ []byte,
+	_ adapter.StateReader,
+) (map[string]string, error) {
+	return nil, nil
+}
+
+// ErrorResponse encodes err as a reply to request, an OP_REPLY for legacy
+// OP_QUERY requests and an OP_MSG otherwise.
+func (p *mongoProtocol) ErrorResponse(request []byte, err error) []byte {
+	var responseTo int32
+	var opCode int32 = opMsg
+	if len(request) >= headerLength {
+		responseTo = int32(binary.LittleEndian.Uint32(request[4:]))
+		if binary.LittleEndian.Uint32(request[12:]) == opQuery {
+			opCode = opReply
+		}
+	}
+	var doc []byte
+	if opCode == opReply {
+		doc = encodeDocument(
+			bsonElement{bsonString, "$err", err.Error()},
+			bsonElement{bsonInt32, "code", int32(internalErrorCode)},
+		)
+	} else {
+		doc = encodeDocument(
+			bsonElement{bsonDouble, "ok", float64(0)},
+			bsonElement{bsonString, "errmsg", err.Error()},
+			bsonElement{bsonInt32, "code", int32(internalErrorCode)},
+			bsonElement{bsonString, "codeName", "InternalError"},
+		)
+	}
+
+	var body bytes.Buffer
+	if opCode == opReply {
+		// responseFlags, cursorID, startingFrom and numberReturned.
+		body.Write(binary.LittleEndian.AppendUint32(nil, flagQueryFailure))
+		body.Write(make([]byte, 8+4))
+		body.Write(binary.LittleEndian.AppendUint32(nil, 1))
+	} else {
+		// flagBits and body section kind.
+		body.Write(make([]byte, 4))
+		body.WriteByte(sectionBody)
+	}
+	body.Write(doc)
+
+	out := make([]byte, 0, headerLength+body.Len())
+	out = binary.LittleEndian.AppendUint32(out, uint32(headerLength+body.Len()))
+	out = binary.LittleEndian.AppendUint32(out, uint32(nextRequestId.Add(1)))
+	out = binary.LittleEndian.AppendUint32(out, uint32(responseTo))
+	out = binary.LittleEndian.AppendUint32(out, uint32(opCode))
+	return append(out, body.Bytes()...)
+}
+
+// commandName returns the command name of an OP_MSG or OP_QUERY request, which
+// is the first key of its command document.
+func commandName(request []byte) string {
+	if len(request) < headerLength {
+		return ""
+	}
+	body := request[headerLength:]
+	switch binary.LittleEndian.Uint32(request[12:]) {
+	case opMsg:
+		if len(body) < 4 {
+			return ""
+		}
+		flags := binary.LittleEndian.Uint32(body)
+		body = body[4:]
+		if flags&flagChecksumPresent != 0 {
+			if len(body) < 4 {
+				return ""
+			}
+			body = body[:len(body)-4]
+		}
+		for len(body) > 0 {
+			kind := body[0]
+			body = body[1:]
+			size, ok := documentSize(body)
+			if !ok {
+				return ""
+			}
+			switch kind {
+			case sectionBody:
+				return firstKey(body[:size])
+			case sectionSequence:
+				body = body[size:]
+			default:
+				return ""
+			}
+		}
+		return ""
+	case opQuery:
+		// flags, fullCollectionName, numberToSkip and numberToReturn precede the
+		// query document.
+		if len(body) < 4 {
+			return ""
+		}
+		i := bytes.IndexByte(body[4:], 0)
+		if i < 0 || len(body) < 4+i+1+8 {
+			return ""
+		}
+		doc := body[4+i+1+8:]
+		size, ok := documentSize(doc)
+		if !ok {
+			return ""
+		}
+		return firstKey(doc[:size])
+	default:
+		return ""
+	}
+}
+
+// documentSize returns the int32 size prefix of a BSON document or document
+// sequence, if it fits in b.
+func documentSize(b []byte) (int, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	size := int(int32(binary.LittleEndian.Uint32(b)))
+	if size < 5 || size > len(b) {
+		return 0, false
+	}
+	return size, true
+}
+
+// firstKey returns the name of the first element of a BSON document.
+func firstKey(doc []byte) string {
+	if len(doc) < 6 {
+		return ""
+	}
+	name := doc[5:]
+	i := bytes.IndexByte(name, 0)
+	if i < 0 {
+		return ""
+	}
+	return string(name[:i])
+}
+
+type bsonElement struct {
+	kind  byte
+	name  string
+	value any
+}
+
+// encodeDocument encodes a BSON document of double, string and int32
+// elements.
+func encodeDocument(elements ...bsonElement) []byte {
+	var elems bytes.Buffer
+	for _, e := range elements {
+		elems.WriteByte(e.kind)
+		elems.WriteString(e.name)
+		elems.WriteByte(0)
+		switch v := e.value.(type) {
+		case float64:
+			elems.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v)))
+		case string:
+			elems.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(v)+1)))
+			elems.WriteString(v)
+			elems.WriteByte(0)
+		case int32:
+			elems.Write(binary.LittleEndian.AppendUint32(nil, uint32(v)))
+		}
+	}
+	doc := binary.LittleEndian.AppendUint32(nil, uint32(4+elems.Len()+1))
+	doc = append(doc, elems.Bytes()...)
+	return append(doc, 0)
+}
@@ -0,0 +1,108 @@
+//go:build unit
+// +build unit
+
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestReadRequest(t *testing.T) {
+	p := &respProtocol{}
+	input := "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nva\r\nl\r\n" +
+		"PING\r\n" +
+		"*2\r\n$3\r\nGET\r\n$-1\r\n"
+	r := bufio.NewReader(strings.NewReader(input))
+
+	req, err := p.ReadRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, "*3\r\n$3\r\nSET\r\n$3\r\nkey\r\n$5\r\nva\r\nl\r\n", string(req))
+	assert.True(t, p.IsDML(req))
+
+	req, err = p.ReadRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, "PING\r\n", string(req))
+	assert.False(t, p.IsDML(req))
+
+	req, err = p.ReadRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, "GET", commandName(req))
+	assert.False(t, p.IsDML(req))
+
+	_, err = p.ReadRequest(bufio.NewReader(strings.NewReader("*1\r\n$3\r\nGETX\r\n")))
+	assert.Error(t, err)
+	_, err = p.ReadRequest(bufio.NewReader(strings.NewReader("*1\r\n$999999999999\r\n")))
+	assert.Error(t, err)
+}
+
+func TestErrorResponse(t *testing.T) {
+	p := &respProtocol{}
+	got := p.ErrorResponse(nil, status.Error(codes.NotFound, "no\r\nsuch table"))
+	assert.True(t, bytes.HasPrefix(got, []byte("-ERR ")))
+	assert.True(t, bytes.HasSuffix(got, []byte("\r\n")))
+	assert.Equal(t, 1, bytes.Count(got, []byte("\r\n")))
+}
+
+func TestNewProxy(t *testing.T) {
+	t.Cleanup(adapter.ResetGrpcFuncs())
+	adapter.MockCreateSessionGrpc()
+	adapter.MockPayloadAdaptMessageGrpc(
+		"redis",
+		func(payload []byte) ([]byte, error) {
+			if commandName(payload) == "GET" {
+				return nil, status.Error(codes.NotFound, "key not found")
+			}
+			return []byte("+OK\r\n"), nil
+		},
+	)
+	proxy, err := NewProxy(&Options{
+		DatabaseUri:   "projects/test/instances/test/databases/test",
+		TCPEndpoint:   "localhost:0",
+		GoogleApiOpts: adapter.SkipAuthOpts,
+	})
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	_, err = conn.Write([]byte("*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n"))
+	require.NoError(t, err)
+	line, err := r.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", line)
+
+	_, err = conn.Write([]byte("*2\r\n$3\r\nGET\r\n$1\r\nk\r\n"))
+	require.NoError(t, err)
+	line, err = r.ReadString('\n')
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(line, "-ERR "), line)
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2025 Google LLC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/googleapis/go-spanner-cassandra/adapter"
+)
+
+const (
+	// Maximum length of a bulk string, matching Redis' default
+	// proto-max-bulk-len.
+	maxBulkLength = 512 * 1024 * 1024
+	// Maximum number of elements of an array.
+	maxArrayLength = 1024 * 1024
+	// Maximum length of a simple line (ie: an inline command).
+	maxLineLength = 64 * 1024
+)
+
+// Commands which modify data and are routed to the leader.
+var writeCommands = map[string]bool{
+	"APPEND": true, "COPY": true, "DECR": true, "DECRBY": true, "DEL": true,
+	"EXPIRE": true, "EXPIREAT": true, "FLUSHALL": true, "FLUSHDB": true,
+	"GETDEL": true, "GETEX": true, "GETSET": true, "HDEL": true,
+	"HINCRBY": true, "HINCRBYFLOAT": true, "HMSET": true, "HSET": true,
+	"HSETNX": true, "INCR": true, "INCRBY": true, "INCRBYFLOAT": true,
+	"LPOP": true, "LPUSH": true, "LREM": true, "LSET": true, "LTRIM": true,
+	"MSET": true, "MSETNX": true, "PERSIST": true, "PEXPIRE": true,
+	"PEXPIREAT": true, "PSETEX": true, "RENAME": true, "RENAMENX": true,
+	"RPOP": true, "RPUSH": true, "SADD": true, "SET": true, "SETEX": true,
+	"SETNX": true, "SPOP": true, "SREM": true, "UNLINK": true, "ZADD": true,
+	"ZINCRBY": true, "ZREM": true,
+}
+
+// respProtocol implements the Redis serialization protocol (RESP).
+type respProtocol struct {
+}
+
+func init() {
+	adapter.RegisterProtocol(&respProtocol{})
+}
+
+func (p *respProtocol) Name() string {
+	return "redis"
+}
+
+// ReadRequest reads a complete RESP value, or an inline command, and returns
+// its raw bytes.
+func (p *respProtocol) ReadRequest(r *bufio.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch first[0] {
+	case '*', '$', '+', '-', ':':
+		err = readValue(r, &buf)
+	default:
+		// Inline commands are a single line of space separated arguments.
+		_, err = readLine(r, &buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsDML reports whether the command of the request modifies data.
+func (p *respProtocol) IsDML(request []byte) bool {
+	return writeCommands[commandName(request)]
+}
+
+// Attachments returns no attachments, requests are self-contained.
+func (p *respProtocol) Attachments(
+	_ []byte,
+	_ adapter.StateReader,
+) (map[string]string, error) {
+	return nil, nil
+}
+
+// ErrorResponse encodes err as a RESP error reply.
+func (p *respProtocol) ErrorResponse(_ []byte, err error) []byte {
+	msg := strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error())
+	return []byte("-ERR " + msg + "\r\n")
+}
+
+// readLine appends the next CRLF terminated line to buf and returns it without
+// its terminator.
+func readLine(r *bufio.Reader, buf *bytes.Buffer) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxLineLength {
+			return nil, fmt.Errorf("resp line exceeds %d bytes", maxLineLength)
+		}
+		if !isPrefix {
+			break
+		}
+	}
+	buf.Write(line)
+	buf.WriteString("\r\n")
+	return line, nil
+}
+
+// readLength parses the length following the type byte of a line.
+func readLength(line []byte, max int) (int, error) {
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid resp length %q", line[1:])
+	}
+	if n > max {
+		return 0, fmt.Errorf("resp length %d exceeds %d", n, max)
+	}
+	return n, nil
+}
+
+// readValue appends the next complete RESP value to buf.
+func readValue(r *bufio.Reader, buf *bytes.Buffer) error {
+	line, err := readLine(r, buf)
+	if err != nil {
+		return err
+	}
+	if len(line) == 0 {
+		return fmt.Errorf("empty resp value")
+	}
+	switch line[0] {
+	case '+', '-', ':':
+		return nil
+	case '$':
+		n, err := readLength(line, maxBulkLength)
+		if err != nil || n < 0 {
+			return err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return err
+		}
+		if data[n] != '\r' || data[n+1] != '\n' {
+			return fmt.Errorf("resp bulk string is not terminated by CRLF")
+		}
+		buf.Write(data)
+		return nil
+	case '*':
+		n, err := readLength(line, maxArrayLength)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if err := readValue(r, buf); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown resp type %q", line[0])
+	}
+}
+
+// commandName returns the upper cased command name of a request.
+func commandName(request []byte) string {
+	r := bufio.NewReader(bytes.NewReader(request))
+	var discard bytes.Buffer
+	line, err := readLine(r, &discard)
+	if err != nil || len(line) == 0 {
+		return ""
+	}
+	if line[0] != '*' {
+		fields := strings.Fields(string(line))
+		if len(fields) == 0 {
+			return ""
+		}
+		return strings.ToUpper(fields[0])
+	}
+	line, err = readLine(r, &discard)
+	if err != nil || len(line) == 0 || line[0] != '$' {
+		return ""
+	}
+	n, err := readLength(line, maxBulkLength)
+	if err != nil || n < 0 {
+		return ""
+	}
+	name := make([]byte, n)
+	if _, err := io.ReadFull(r, name); err != nil {
+		return ""
+	}
+	return strings.ToUpper(string(name))
+}